@@ -22,6 +22,7 @@
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-multierror"
 	"github.com/mattn/go-colorable"
 	"github.com/rancher/remotedialer"
@@ -33,6 +34,7 @@
 	"github.com/rancher/rancher/pkg/agent/cluster"
 	"github.com/rancher/rancher/pkg/agent/node"
 	"github.com/rancher/rancher/pkg/agent/rancher"
+	"github.com/rancher/rancher/pkg/agent/tunnelproxy"
 	"github.com/rancher/rancher/pkg/features"
 	"github.com/rancher/rancher/pkg/logserver"
 	"github.com/rancher/rancher/pkg/rkenodeconfigclient"
@@ -129,6 +131,14 @@ func getTokenAndURL() (string, string, error) {
 	return token, url, nil
 }
 
+// getProxyConfig returns the proxy the cluster agent connects to Rancher through, if one is configured on the cluster.
+func getProxyConfig() (*tunnelproxy.Config, error) {
+	if !isCluster() {
+		return nil, nil
+	}
+	return tunnelproxy.Load(tunnelproxy.Folder)
+}
+
 func isConnect() bool {
 	if os.Getenv("CATTLE_AGENT_CONNECT") == "true" {
 		return true
@@ -212,6 +222,17 @@ func run(ctx context.Context) error {
 		rkenodeconfigclient.Params: {base64.StdEncoding.EncodeToString(bytes)},
 	}
 
+	proxyConfig, err := getProxyConfig()
+	if err != nil {
+		return err
+	}
+	var proxyTracker *tunnelproxy.Tracker
+	if proxyConfig != nil {
+		logrus.Infof("Connecting to Rancher through proxy %s", proxyConfig.URL.Redacted())
+		proxyConfig.ConfigureTransport(http.DefaultTransport.(*http.Transport))
+		proxyTracker = tunnelproxy.NewTracker(proxyConfig)
+	}
+
 	serverURL, err := url.Parse(server)
 	if err != nil {
 		return err
@@ -235,14 +256,18 @@ func run(ctx context.Context) error {
 			if strings.Contains(err.Error(), "because it doesn't contain any IP SANs") || strings.Contains(err.Error(), "certificate is not valid for any names, but wanted to match") || strings.Contains(err.Error(), "cannot validate certificate for") {
 				certErr = fmt.Errorf("Server certificate does not contain correct DNS and/or IP address entries in the Subject Alternative Names (SAN). Certificate information is displayed above. error: %s", err)
 			}
-			insecureClient := &http.Client{
-				Timeout: time.Second * 5,
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: true,
-					},
+			insecureTransport := &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
 				},
 			}
+			if proxyConfig != nil {
+				insecureTransport.Proxy = proxyConfig.Proxy
+			}
+			insecureClient := &http.Client{
+				Timeout:   time.Second * 5,
+				Transport: insecureTransport,
+			}
 			res, err := insecureClient.Get(server)
 			if err != nil {
 				logrus.Errorf("Could not connect to %s: %v", server, err)
@@ -313,6 +338,9 @@ func run(ctx context.Context) error {
 
 	onConnect := func(ctx context.Context, _ *remotedialer.Session) error {
 		connected()
+		if proxyTracker != nil {
+			proxyTracker.Reset()
+		}
 		connectConfig := fmt.Sprintf("https://%s/v3/connect/config", serverURL.Host)
 		interval, err := rkenodeconfigclient.ConfigClient(ctx, connectConfig, headers, writeCertsOnly)
 		if err != nil {
@@ -369,9 +397,20 @@ func run(ctx context.Context) error {
 			wsURL += "/register"
 		}
 
+		var dialer *websocket.Dialer
+		if proxyConfig != nil {
+			params["proxy"] = proxyTracker.Report()
+			bytes, err := json.Marshal(params)
+			if err != nil {
+				return err
+			}
+			headers.Set(rkenodeconfigclient.Params, base64.StdEncoding.EncodeToString(bytes))
+			dialer = proxyConfig.WebsocketDialer()
+		}
+
 		logrus.Infof("Connecting to %s with token starting with %s", wsURL, token[:len(token)/2])
 		logrus.Tracef("Connecting to %s with token %s", wsURL, token)
-		remotedialer.ClientConnect(ctx, wsURL, headers, nil, func(proto, address string) bool {
+		err := remotedialer.ClientConnect(ctx, wsURL, headers, dialer, func(proto, address string) bool {
 			switch proto {
 			case "tcp":
 				return true
@@ -382,6 +421,9 @@ func run(ctx context.Context) error {
 			}
 			return false
 		}, onConnect)
+		if proxyTracker != nil && proxyTracker.Observe(err) {
+			logrus.Errorf("Proxy %s rejected the connection to %s, check the proxy URL and credentials configured on the cluster: %v", proxyConfig.URL.Redacted(), wsURL, err)
+		}
 		time.Sleep(5 * time.Second)
 	}
 }
@@ -7,11 +7,13 @@
 	_ "net/http/pprof"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/ehazlett/simplelog"
 	_ "github.com/rancher/norman/controller"
 	"github.com/rancher/norman/pkg/kwrapper/k8s"
+	"github.com/rancher/rancher/pkg/auth/audit"
 	"github.com/rancher/rancher/pkg/data/management"
 	"github.com/rancher/rancher/pkg/logserver"
 	"github.com/rancher/rancher/pkg/rancher"
@@ -29,6 +31,7 @@
 func main() {
 	management.RegisterPasswordResetCommand()
 	management.RegisterEnsureDefaultAdminCommand()
+	audit.RegisterVerifyCommand()
 	if reexec.Init() {
 		return
 	}
@@ -147,6 +150,68 @@ func main() {
 			Usage:       "Audit log level: 0 - disable audit log, 1 - log event metadata, 2 - log event metadata and request body, 3 - log event metadata, request body and response body",
 			Destination: &config.AuditLevel,
 		},
+		cli.StringSliceFlag{
+			Name:   "audit-log-sink",
+			EnvVar: "AUDIT_LOG_SINKS",
+			Usage:  "Audit log destinations, may be repeated or comma separated: file, stdout, webhook, syslog. Default is file",
+			Value:  &config.AuditLogSinks,
+		},
+		cli.StringFlag{
+			Name:        "audit-log-webhook-url",
+			EnvVar:      "AUDIT_LOG_WEBHOOK_URL",
+			Usage:       "URL that batches of audit log records are POSTed to when the webhook sink is enabled",
+			Destination: &config.AuditLogWebhookURL,
+		},
+		cli.StringFlag{
+			Name:        "audit-log-webhook-ca-file",
+			EnvVar:      "AUDIT_LOG_WEBHOOK_CA_FILE",
+			Usage:       "Path to a PEM encoded CA bundle used to verify the audit log webhook",
+			Destination: &config.AuditLogWebhookCAFile,
+		},
+		cli.IntFlag{
+			Name:        "audit-log-webhook-batch-size",
+			Value:       100,
+			EnvVar:      "AUDIT_LOG_WEBHOOK_BATCH_SIZE",
+			Usage:       "Maximum number of audit log records sent to the webhook in a single request",
+			Destination: &config.AuditLogWebhookBatchSize,
+		},
+		cli.DurationFlag{
+			Name:        "audit-log-webhook-flush-interval",
+			Value:       5 * time.Second,
+			EnvVar:      "AUDIT_LOG_WEBHOOK_FLUSH_INTERVAL",
+			Usage:       "Maximum time audit log records are buffered before being sent to the webhook",
+			Destination: &config.AuditLogWebhookFlushInterval,
+		},
+		cli.StringFlag{
+			Name:        "audit-log-syslog-address",
+			EnvVar:      "AUDIT_LOG_SYSLOG_ADDRESS",
+			Usage:       "host:port of the syslog server audit log records are sent to when the syslog sink is enabled",
+			Destination: &config.AuditLogSyslogAddress,
+		},
+		cli.BoolFlag{
+			Name:        "audit-log-syslog-tls",
+			EnvVar:      "AUDIT_LOG_SYSLOG_TLS",
+			Usage:       "Use TLS when connecting to the audit log syslog server",
+			Destination: &config.AuditLogSyslogTLS,
+		},
+		cli.StringFlag{
+			Name:        "audit-log-syslog-ca-file",
+			EnvVar:      "AUDIT_LOG_SYSLOG_CA_FILE",
+			Usage:       "Path to a PEM encoded CA bundle used to verify the audit log syslog server",
+			Destination: &config.AuditLogSyslogCAFile,
+		},
+		cli.BoolFlag{
+			Name:        "audit-log-hash-chain",
+			EnvVar:      "AUDIT_LOG_HASH_CHAIN",
+			Usage:       "Add a sequence number and a hash chaining each audit log record to the previous one",
+			Destination: &config.AuditLogHashChain,
+		},
+		cli.StringFlag{
+			Name:        "audit-log-hmac-secret",
+			EnvVar:      "AUDIT_LOG_HMAC_SECRET",
+			Usage:       "Name of a secret in the cattle-system namespace whose \"key\" entry is used to HMAC sign the audit log hash chain",
+			Destination: &config.AuditLogHMACSecret,
+		},
 		cli.StringFlag{
 			Name:        "profile-listen-address",
 			Value:       "127.0.0.1:6060",
@@ -0,0 +1,58 @@
+package tunnelproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// Report is sent to Rancher with the tunnel parameters so it can reflect proxy problems on the cluster. It covers
+// the connection attempts since the last successful tunnel session.
+type Report struct {
+	URL               string `json:"url"`
+	Rejections        int    `json:"rejections,omitempty"`
+	LastRejection     string `json:"lastRejection,omitempty"`
+	LastRejectionTime string `json:"lastRejectionTime,omitempty"`
+}
+
+// Tracker counts proxy rejections between successful tunnel sessions.
+type Tracker struct {
+	sync.Mutex
+
+	url    string
+	report Report
+}
+
+func NewTracker(config *Config) *Tracker {
+	return &Tracker{
+		url:    config.URL.Redacted(),
+		report: Report{URL: config.URL.Redacted()},
+	}
+}
+
+// Observe records err if it was caused by the proxy rejecting the connection and returns true in that case.
+func (t *Tracker) Observe(err error) bool {
+	if !IsRejected(err) {
+		return false
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.report.Rejections++
+	t.report.LastRejection = err.Error()
+	t.report.LastRejectionTime = time.Now().UTC().Format(time.RFC3339)
+	return true
+}
+
+// Report returns the rejections recorded since the last call to Reset.
+func (t *Tracker) Report() Report {
+	t.Lock()
+	defer t.Unlock()
+	return t.report
+}
+
+// Reset clears the recorded rejections once a tunnel session has been established.
+func (t *Tracker) Reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.report = Report{URL: t.url}
+}
@@ -0,0 +1,264 @@
+// Package tunnelproxy dials the Rancher tunnel through the HTTP(S) proxy configured on the cluster. The proxy settings
+// are rendered by pkg/systemtemplate into a Secret that is mounted into the cluster agent.
+package tunnelproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/remotedialer"
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	// Folder is where the proxy Secret is mounted in the cluster agent.
+	Folder = "/cattle-agent-proxy"
+
+	URLKey      = "url"
+	UsernameKey = "username"
+	PasswordKey = "password"
+	CACertsKey  = "ca-certs"
+	NoProxyKey  = "no-proxy"
+)
+
+// RejectedError is returned when the proxy answers the CONNECT request with anything but 200.
+type RejectedError struct {
+	Proxy      string
+	StatusCode int
+	Status     string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("proxy %s rejected the tunnel connection: %s", e.Proxy, e.Status)
+}
+
+// IsRejected returns true if the error was caused by the proxy rejecting the connection.
+func IsRejected(err error) bool {
+	var rejected *RejectedError
+	return errors.As(err, &rejected)
+}
+
+// Config is the proxy configuration of the cluster agent.
+type Config struct {
+	URL      *url.URL
+	Username string
+	Password string
+	CACerts  []byte
+	NoProxy  []string
+
+	roots     *x509.CertPool
+	proxyFunc func(*url.URL) (*url.URL, error)
+}
+
+// Load reads the proxy configuration from dir. It returns nil if no proxy is configured.
+func Load(dir string) (*Config, error) {
+	rawURL, err := readKey(dir, URLKey)
+	if os.IsNotExist(err) || (err == nil && rawURL == "") {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, key := range []string{UsernameKey, PasswordKey, CACertsKey, NoProxyKey} {
+		value, err := readKey(dir, key)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	var noProxy []string
+	for _, entry := range strings.Split(values[NoProxyKey], ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			noProxy = append(noProxy, entry)
+		}
+	}
+
+	return New(rawURL, values[UsernameKey], values[PasswordKey], []byte(values[CACertsKey]), noProxy)
+}
+
+// New validates the proxy settings and returns a Config.
+func New(rawURL, username, password string, caCerts []byte, noProxy []string) (*Config, error) {
+	proxyURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid proxy URL %s: scheme must be http or https", proxyURL.Redacted())
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %s: host is empty", proxyURL.Redacted())
+	}
+	proxyURL.User = nil
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if len(caCerts) > 0 && !roots.AppendCertsFromPEM(caCerts) {
+		return nil, errors.New("invalid proxy CA certificates: no PEM certificates found")
+	}
+
+	c := &Config{
+		URL:      proxyURL,
+		Username: username,
+		Password: password,
+		CACerts:  caCerts,
+		NoProxy:  noProxy,
+		roots:    roots,
+	}
+	c.proxyFunc = (&httpproxy.Config{
+		HTTPProxy:  proxyURL.String(),
+		HTTPSProxy: proxyURL.String(),
+		NoProxy:    strings.Join(noProxy, ","),
+	}).ProxyFunc()
+	return c, nil
+}
+
+// Proxy returns the proxy URL, including credentials, for the request or nil if the request bypasses the proxy. It
+// can be used as http.Transport.Proxy.
+func (c *Config) Proxy(req *http.Request) (*url.URL, error) {
+	proxyURL, err := c.proxyFunc(req.URL)
+	if err != nil || proxyURL == nil {
+		return nil, err
+	}
+	if c.Username != "" {
+		proxyURL.User = url.UserPassword(c.Username, c.Password)
+	}
+	return proxyURL, nil
+}
+
+// TLSConfig returns a TLS configuration that trusts the system roots and the proxy CA certificates.
+func (c *Config) TLSConfig() *tls.Config {
+	return &tls.Config{
+		RootCAs: c.roots,
+	}
+}
+
+// ConfigureTransport makes the transport send its requests through the proxy.
+func (c *Config) ConfigureTransport(transport *http.Transport) {
+	transport.Proxy = c.Proxy
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = c.TLSConfig()
+	} else {
+		transport.TLSClientConfig.RootCAs = c.roots
+	}
+}
+
+// WebsocketDialer returns a dialer for remotedialer.ClientConnect that opens the tunnel through the proxy.
+func (c *Config) WebsocketDialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		NetDialContext:   c.DialContext,
+		TLSClientConfig:  c.TLSConfig(),
+		HandshakeTimeout: remotedialer.HandshakeTimeOut,
+	}
+}
+
+// DialContext connects to addr through the proxy using HTTP CONNECT, unless addr is excluded by NoProxy.
+func (c *Config) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	proxyURL, err := c.proxyFunc(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddress(c.URL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %w", c.URL.Host, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if c.URL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: c.URL.Hostname(),
+			RootCAs:    c.roots,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy %s failed: %w", c.URL.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if c.Username != "" {
+		credential := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to proxy %s: %w", c.URL.Host, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from proxy %s: %w", c.URL.Host, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &RejectedError{
+			Proxy:      c.URL.Host,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s sent unexpected data after the CONNECT response", c.URL.Host)
+	}
+
+	return conn, nil
+}
+
+func proxyAddress(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	if proxyURL.Scheme == "https" {
+		return net.JoinHostPort(proxyURL.Hostname(), "443")
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), "80")
+}
+
+func readKey(dir, key string) (string, error) {
+	bytes, err := os.ReadFile(path.Join(dir, key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
@@ -0,0 +1,191 @@
+package tunnelproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is a minimal CONNECT proxy that requires basic auth when user is set.
+func connectProxy(user, password string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodConnect {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if user != "" {
+			expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+			if req.Header.Get("Proxy-Authorization") != expected {
+				rw.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+		upstream, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+func TestDialContext(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("pong"))
+	}))
+	defer backend.Close()
+
+	proxy := connectProxy("user", "secret")
+	defer proxy.Close()
+
+	tests := []struct {
+		name         string
+		username     string
+		password     string
+		wantRejected bool
+	}{
+		{
+			name:     "valid credentials",
+			username: "user",
+			password: "secret",
+		},
+		{
+			name:         "wrong credentials",
+			username:     "user",
+			password:     "wrong",
+			wantRejected: true,
+		},
+		{
+			name:         "missing credentials",
+			wantRejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := New(proxy.URL, tt.username, tt.password, nil, nil)
+			require.NoError(t, err)
+
+			// httpproxy never proxies loopback addresses, so route through DialContext's proxy path directly
+			config.proxyFunc = func(_ *url.URL) (*url.URL, error) { return config.URL, nil }
+
+			conn, err := config.DialContext(context.Background(), "tcp", backend.Listener.Addr().String())
+			if tt.wantRejected {
+				require.Error(t, err)
+				assert.True(t, IsRejected(err))
+				var rejected *RejectedError
+				require.ErrorAs(t, err, &rejected)
+				assert.Equal(t, http.StatusProxyAuthRequired, rejected.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("GET / HTTP/1.0\r\nHost: backend\r\n\r\n"))
+			require.NoError(t, err)
+			body, err := io.ReadAll(conn)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), "pong")
+		})
+	}
+}
+
+func TestProxyHonorsNoProxy(t *testing.T) {
+	config, err := New("http://proxy.example.com:3128", "user", "secret", nil, []string{".internal.example.com", "10.0.0.0/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		url       string
+		wantProxy bool
+	}{
+		{url: "https://rancher.example.com/v3/connect", wantProxy: true},
+		{url: "https://rancher.internal.example.com/v3/connect", wantProxy: false},
+		{url: "https://10.1.2.3/v3/connect", wantProxy: false},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+		require.NoError(t, err)
+		proxyURL, err := config.Proxy(req)
+		require.NoError(t, err)
+		if !tt.wantProxy {
+			assert.Nil(t, proxyURL, tt.url)
+			continue
+		}
+		require.NotNil(t, proxyURL, tt.url)
+		assert.Equal(t, "proxy.example.com:3128", proxyURL.Host)
+		password, _ := proxyURL.User.Password()
+		assert.Equal(t, "secret", password)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := Load(dir)
+	require.NoError(t, err)
+	assert.Nil(t, config, "no proxy configured")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, URLKey), []byte("https://proxy.example.com\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, UsernameKey), []byte("user"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, NoProxyKey), []byte("localhost, .svc,10.0.0.0/8"), 0600))
+
+	config, err = Load(dir)
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	assert.Equal(t, "https", config.URL.Scheme)
+	assert.Equal(t, "proxy.example.com:443", proxyAddress(config.URL))
+	assert.Equal(t, "user", config.Username)
+	assert.Equal(t, []string{"localhost", ".svc", "10.0.0.0/8"}, config.NoProxy)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, CACertsKey), []byte("not a certificate"), 0600))
+	_, err = Load(dir)
+	assert.Error(t, err)
+}
+
+func TestNewRejectsInvalidURL(t *testing.T) {
+	for _, rawURL := range []string{"socks5://proxy:1080", "http://", "://bad"} {
+		_, err := New(rawURL, "", "", nil, nil)
+		assert.Error(t, err, rawURL)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	config, err := New("http://user:pw@proxy.example.com:3128", "", "", nil, nil)
+	require.NoError(t, err)
+	tracker := NewTracker(config)
+
+	assert.False(t, tracker.Observe(io.EOF))
+	assert.True(t, tracker.Observe(&RejectedError{Proxy: "proxy.example.com:3128", StatusCode: 407, Status: "407 Proxy Authentication Required"}))
+	assert.True(t, tracker.Observe(&RejectedError{Proxy: "proxy.example.com:3128", StatusCode: 403, Status: "403 Forbidden"}))
+
+	report := tracker.Report()
+	assert.Equal(t, "http://proxy.example.com:3128", report.URL)
+	assert.Equal(t, 2, report.Rejections)
+	assert.Contains(t, report.LastRejection, "403 Forbidden")
+	assert.NotEmpty(t, report.LastRejectionTime)
+
+	tracker.Reset()
+	assert.Equal(t, Report{URL: "http://proxy.example.com:3128"}, tracker.Report())
+}
@@ -141,6 +141,7 @@ func (a ActionHandler) RestoreFromEtcdBackupHandler(actionName string, action *t
 
 		cluster.Spec.ClusterAgentDeploymentCustomization = clusterBackup.Spec.ClusterAgentDeploymentCustomization
 		cluster.Spec.FleetAgentDeploymentCustomization = clusterBackup.Spec.FleetAgentDeploymentCustomization
+		cluster.Spec.ClusterAgentProxyConfig = clusterBackup.Spec.ClusterAgentProxyConfig
 	}
 
 	// flag cluster for restore
@@ -9,8 +9,10 @@
 	"time"
 
 	yaml2 "github.com/ghodss/yaml"
+	"github.com/rancher/norman/types"
 	"github.com/rancher/norman/types/convert"
 	"github.com/rancher/norman/types/values"
+	gaccess "github.com/rancher/rancher/pkg/api/norman/customization/globalnamespaceaccess"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/sirupsen/logrus"
@@ -154,6 +156,28 @@ func CanCreateRKETemplate(callerID string, subjectAccessReviewClient clientauthv
 	return result.Status.Allowed, nil
 }
 
+// canGetSecret reports whether the user making the request can get the given secret.
+func canGetSecret(request *types.APIContext, subjectAccessReviewClient clientauthv1.SubjectAccessReviewInterface, namespace, name string) (bool, error) {
+	review := authV1.SubjectAccessReview{
+		Spec: authV1.SubjectAccessReviewSpec{
+			User:   request.Request.Header.Get(gaccess.ImpersonateUserHeader),
+			Groups: request.Request.Header.Values(gaccess.ImpersonateGroupHeader),
+			ResourceAttributes: &authV1.ResourceAttributes{
+				Verb:      "get",
+				Resource:  "secrets",
+				Namespace: namespace,
+				Name:      name,
+			},
+		},
+	}
+
+	result, err := subjectAccessReviewClient.Create(request.Request.Context(), &review, v12.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return result.Status.Allowed, nil
+}
+
 // updateClusterWithRetryOnConflict attempts to update the cluster with the changes encoded in the updateFunc. It only retries if a conflict error is returned.
 func updateClusterWithRetryOnConflict(clusterClient v3.ClusterInterface, cluster *v3.Cluster, updateFunc func(*v3.Cluster) *v3.Cluster) error {
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -16,6 +16,7 @@
 	gaccess "github.com/rancher/rancher/pkg/api/norman/customization/globalnamespaceaccess"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	mgmtclient "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	clusterpkg "github.com/rancher/rancher/pkg/cluster"
 	"github.com/rancher/rancher/pkg/controllers/management/k3sbasedupgrade"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/kontainer-engine/service"
@@ -23,6 +24,7 @@
 	mgmtSchema "github.com/rancher/rancher/pkg/schemas/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 )
 
 type Validator struct {
@@ -33,6 +35,7 @@ type Validator struct {
 	Users                         v3.UserInterface
 	GrbLister                     v3.GlobalRoleBindingLister
 	GrLister                      v3.GlobalRoleLister
+	SubjectAccessReviewClient     clientauthv1.SubjectAccessReviewInterface
 }
 
 func (v *Validator) Validator(request *types.APIContext, schema *types.Schema, data map[string]interface{}) error {
@@ -58,6 +61,10 @@ func (v *Validator) Validator(request *types.APIContext, schema *types.Schema, d
 		return err
 	}
 
+	if err := v.validateAgentProxyConfig(request, &clusterSpec); err != nil {
+		return err
+	}
+
 	if err := v.validateGenericEngineConfig(request, &clusterSpec); err != nil {
 		return err
 	}
@@ -103,6 +110,47 @@ func (v *Validator) validateLocalClusterAuthEndpoint(request *types.APIContext,
 	return nil
 }
 
+// validateAgentProxyConfig checks that the cluster agent proxy credential secret may be served to the agent and that
+// the caller can read it, since its username and password end up in the import manifest.
+func (v *Validator) validateAgentProxyConfig(request *types.APIContext, spec *v32.ClusterSpec) error {
+	if spec.ClusterAgentProxyConfig == nil || spec.ClusterAgentProxyConfig.CredentialSecret == "" {
+		return nil
+	}
+
+	cluster := &v32.Cluster{Spec: *spec}
+	if request.ID != "" {
+		prevCluster, err := v.ClusterLister.Get("", request.ID)
+		if err != nil {
+			return err
+		}
+		cluster.Name = prevCluster.Name
+		if cluster.Spec.FleetWorkspaceName == "" {
+			cluster.Spec.FleetWorkspaceName = prevCluster.Spec.FleetWorkspaceName
+		}
+		// Only check the secret if it or the namespaces it may live in are being changed.
+		if prevCluster.Spec.ClusterAgentProxyConfig != nil &&
+			prevCluster.Spec.ClusterAgentProxyConfig.CredentialSecret == spec.ClusterAgentProxyConfig.CredentialSecret &&
+			prevCluster.Spec.FleetWorkspaceName == cluster.Spec.FleetWorkspaceName {
+			return nil
+		}
+	}
+
+	namespace, name, err := clusterpkg.ParseAgentProxyCredentialSecret(cluster)
+	if err != nil {
+		return httperror.NewFieldAPIError(httperror.InvalidOption, "clusterAgentProxyConfig.credentialSecret", err.Error())
+	}
+
+	allowed, err := canGetSecret(request, v.SubjectAccessReviewClient, namespace, name)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return httperror.NewFieldAPIError(httperror.PermissionDenied, "clusterAgentProxyConfig.credentialSecret",
+			fmt.Sprintf("cannot access cluster agent proxy credential secret [%s]", spec.ClusterAgentProxyConfig.CredentialSecret))
+	}
+	return nil
+}
+
 func (v *Validator) validateEnforcement(request *types.APIContext, data map[string]interface{}) error {
 
 	if !strings.EqualFold(settings.ClusterTemplateEnforcement.Get(), "true") {
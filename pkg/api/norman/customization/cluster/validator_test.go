@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/types"
+	gaccess "github.com/rancher/rancher/pkg/api/norman/customization/globalnamespaceaccess"
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestValidateAgentProxyConfig(t *testing.T) {
+	prevCluster := &apimgmtv3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"},
+		Spec: apimgmtv3.ClusterSpec{
+			FleetWorkspaceName: "fleet-default",
+			ClusterSpecBase: apimgmtv3.ClusterSpecBase{
+				ClusterAgentProxyConfig: &apimgmtv3.AgentProxyConfig{
+					URL:              "http://proxy.example.com:3128",
+					CredentialSecret: "c-abcde:proxy-credentials",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		id               string
+		credentialSecret string
+		readableSecrets  []string
+		wantSAR          bool
+		wantErrCode      *httperror.ErrorCode
+	}{
+		{
+			name:             "create with a readable secret in the fleet workspace",
+			credentialSecret: "fleet-default:proxy-credentials",
+			readableSecrets:  []string{"fleet-default/proxy-credentials"},
+			wantSAR:          true,
+		},
+		{
+			name:             "create with a secret in another namespace",
+			credentialSecret: "cattle-global-data:proxy-credentials",
+			readableSecrets:  []string{"cattle-global-data/proxy-credentials"},
+			wantErrCode:      &httperror.InvalidOption,
+		},
+		{
+			name:             "create with an unreadable secret",
+			credentialSecret: "fleet-default:proxy-credentials",
+			wantSAR:          true,
+			wantErrCode:      &httperror.PermissionDenied,
+		},
+		{
+			name:             "update with a readable secret in the cluster namespace",
+			id:               "c-abcde",
+			credentialSecret: "c-abcde:other-credentials",
+			readableSecrets:  []string{"c-abcde/other-credentials"},
+			wantSAR:          true,
+		},
+		{
+			name:             "update with an unreadable secret in the cluster namespace",
+			id:               "c-abcde",
+			credentialSecret: "c-abcde:other-credentials",
+			wantSAR:          true,
+			wantErrCode:      &httperror.PermissionDenied,
+		},
+		{
+			name:             "update without changing the secret",
+			id:               "c-abcde",
+			credentialSecret: "c-abcde:proxy-credentials",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sar *authzv1.SubjectAccessReview
+			k8s := k8sfake.NewSimpleClientset()
+			k8s.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				sar = action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+				attrs := sar.Spec.ResourceAttributes
+				for _, secret := range tt.readableSecrets {
+					if attrs.Verb == "get" && attrs.Resource == "secrets" && secret == attrs.Namespace+"/"+attrs.Name {
+						sar.Status.Allowed = true
+					}
+				}
+				return true, sar, nil
+			})
+
+			v := &Validator{
+				ClusterLister: &fakes.ClusterListerMock{
+					GetFunc: func(namespace, name string) (*apimgmtv3.Cluster, error) {
+						return prevCluster.DeepCopy(), nil
+					},
+				},
+				SubjectAccessReviewClient: k8s.AuthorizationV1().SubjectAccessReviews(),
+			}
+
+			req, err := http.NewRequest(http.MethodPost, "/v3/clusters", nil)
+			require.NoError(t, err)
+			req.Header.Set(gaccess.ImpersonateUserHeader, "u-editor")
+			req.Header.Add(gaccess.ImpersonateGroupHeader, "system:authenticated")
+
+			spec := &apimgmtv3.ClusterSpec{
+				ClusterSpecBase: apimgmtv3.ClusterSpecBase{
+					ClusterAgentProxyConfig: &apimgmtv3.AgentProxyConfig{
+						URL:              "http://proxy.example.com:3128",
+						CredentialSecret: tt.credentialSecret,
+					},
+				},
+			}
+			if tt.id == "" {
+				spec.FleetWorkspaceName = "fleet-default"
+			}
+
+			err = v.validateAgentProxyConfig(&types.APIContext{ID: tt.id, Request: req}, spec)
+			if tt.wantErrCode != nil {
+				require.Error(t, err)
+				assert.Equal(t, *tt.wantErrCode, err.(*httperror.APIError).Code)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if !tt.wantSAR {
+				assert.Nil(t, sar)
+				return
+			}
+			if assert.NotNil(t, sar) {
+				assert.Equal(t, "u-editor", sar.Spec.User)
+				assert.Equal(t, []string{"system:authenticated"}, sar.Spec.Groups)
+			}
+		})
+	}
+}
@@ -5,9 +5,16 @@
 
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
+	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
 )
 
 func Validator(request *types.APIContext, schema *types.Schema, data map[string]interface{}) error {
+	if ttl, _ := data[client.GlobalRoleBindingFieldTTL].(string); ttl != "" {
+		if _, err := pkgrbac.ParseTTL(ttl); err != nil {
+			return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+		}
+	}
 	if request.Method == http.MethodPut {
 		return nil
 	}
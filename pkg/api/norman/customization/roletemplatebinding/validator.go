@@ -8,6 +8,7 @@
 	"github.com/rancher/norman/types"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
 	"github.com/rancher/rancher/pkg/types/config"
 )
 
@@ -19,6 +20,9 @@ func NewCRTBValidator(management *config.ScaledContext) types.Validator {
 	return newValidator(management, client.ClusterRoleTemplateBindingFieldRoleTemplateID, "cluster")
 }
 
+// ttlField is the name of the TTL field of both cluster and project role template bindings.
+const ttlField = client.ClusterRoleTemplateBindingFieldTTL
+
 func newValidator(management *config.ScaledContext, field string, context string) types.Validator {
 	validator := &validator{
 		roleTemplateLister: management.Management.RoleTemplates("").Controller().Lister(),
@@ -36,6 +40,12 @@ type validator struct {
 }
 
 func (v *validator) validator(request *types.APIContext, schema *types.Schema, data map[string]interface{}) error {
+	if ttl, _ := data[ttlField].(string); ttl != "" {
+		if _, err := pkgrbac.ParseTTL(ttl); err != nil {
+			return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+		}
+	}
+
 	roleTemplateName := data[v.field]
 	if roleTemplateName == nil && request.Method == http.MethodPut {
 		return nil
@@ -0,0 +1,71 @@
+// Package accessrequests adds the approve and deny actions to AccessRequests, and lets every user
+// request access for themselves.
+package accessrequests
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/apis/management.cattle.io"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/wrangler"
+	schema2 "github.com/rancher/steve/pkg/schema"
+	steve "github.com/rancher/steve/pkg/server"
+	"github.com/rancher/wrangler/v2/pkg/schemas"
+)
+
+// ReviewInput is the input of the approve and deny actions.
+type ReviewInput struct {
+	// Comment is an optional comment recorded in the status of the AccessRequest.
+	Comment string `json:"comment,omitempty"`
+}
+
+// Register adds the approve and deny actions to the AccessRequest schema. The actions are only
+// shown on pending requests, and are authorized against the approver roles of the target
+// rather than the permissions the user has on AccessRequests. Users without RBAC access to
+// AccessRequests can create requests for themselves and see the requests they made or can review.
+func Register(server *steve.Server, wrangler *wrangler.Context) {
+	r := &reviewer{
+		accessRequests: wrangler.Mgmt.AccessRequest(),
+		crtbCache:      wrangler.Mgmt.ClusterRoleTemplateBinding().Cache(),
+		prtbCache:      wrangler.Mgmt.ProjectRoleTemplateBinding().Cache(),
+		rtCache:        wrangler.Mgmt.RoleTemplate().Cache(),
+		crCache:        wrangler.RBAC.ClusterRole().Cache(),
+		k8s:            wrangler.K8s,
+		now:            time.Now,
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(ReviewInput{}, nil)
+	server.SchemaFactory.AddTemplate(schema2.Template{
+		Group: management.GroupName,
+		Kind:  "AccessRequest",
+		Customize: func(apiSchema *types.APISchema) {
+			apiSchema.CollectionMethods = []string{http.MethodGet, http.MethodPost}
+			apiSchema.ResourceMethods = []string{http.MethodGet, http.MethodDelete}
+			apiSchema.ActionHandlers = map[string]http.Handler{
+				approveAction: r,
+				denyAction:    r,
+			}
+			apiSchema.ResourceActions = map[string]schemas.Action{
+				approveAction: {Input: "reviewInput"},
+				denyAction:    {Input: "reviewInput"},
+			}
+		},
+		StoreFactory: func(innerStore types.Store) types.Store {
+			return &store{
+				Store:    innerStore,
+				cache:    wrangler.Mgmt.AccessRequest().Cache(),
+				reviewer: r,
+			}
+		},
+		Formatter: func(request *types.APIRequest, resource *types.RawResource) {
+			state := resource.APIObject.Data().String("status", "state")
+			if state != "" && state != v3.AccessRequestStatePending {
+				return
+			}
+			resource.AddAction(request, approveAction)
+			resource.AddAction(request, denyAction)
+		},
+	})
+}
@@ -0,0 +1,303 @@
+package accessrequests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/apis/management.cattle.io"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
+	"github.com/rancher/rancher/pkg/settings"
+	rbacv1controllers "github.com/rancher/wrangler/v2/pkg/generated/controllers/rbac/v1"
+	"github.com/rancher/wrangler/v2/pkg/schemas/validation"
+	authzv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+	rbacvalidation "k8s.io/component-helpers/auth/rbac/validation"
+)
+
+const (
+	approveAction = "approve"
+	denyAction    = "deny"
+)
+
+// reviewer approves and denies AccessRequests on behalf of the users holding one of the approver
+// roles of their target. The status of AccessRequests is only writable through the status
+// subresource, so requesters cannot approve their own requests by editing them.
+type reviewer struct {
+	accessRequests mgmtcontrollers.AccessRequestClient
+	crtbCache      mgmtcontrollers.ClusterRoleTemplateBindingCache
+	prtbCache      mgmtcontrollers.ProjectRoleTemplateBindingCache
+	rtCache        mgmtcontrollers.RoleTemplateCache
+	crCache        rbacv1controllers.ClusterRoleCache
+	k8s            kubernetes.Interface
+	now            func() time.Time
+}
+
+func (r *reviewer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+
+	reviewer, ok := request.UserFrom(req.Context())
+	if !ok {
+		apiRequest.WriteError(validation.Unauthorized)
+		return
+	}
+
+	var input ReviewInput
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		apiRequest.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("failed to parse review: %v", err)))
+		return
+	}
+
+	if _, err := r.review(req.Context(), reviewer, apiRequest.Name, apiRequest.Action == approveAction, input.Comment); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// review approves or denies the AccessRequest with the given name as the reviewer.
+func (r *reviewer) review(ctx context.Context, reviewer user.Info, name string, approve bool, comment string) (*v3.AccessRequest, error) {
+	ar, err := r.accessRequests.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if ar.Status.State != "" && ar.Status.State != v3.AccessRequestStatePending {
+		return nil, apierror.NewAPIError(validation.Conflict, fmt.Sprintf("access request %s is already %s", ar.Name, strings.ToLower(ar.Status.State)))
+	}
+	if reviewer.GetName() == ar.Spec.UserName {
+		return nil, apierror.NewAPIError(validation.PermissionDenied, "users cannot review their own access requests")
+	}
+
+	clusterName, projectName, err := pkgrbac.AccessRequestTarget(ar)
+	if err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	isApprover, err := r.isApprover(reviewer, clusterName, projectName)
+	if err != nil {
+		return nil, err
+	}
+	if !isApprover {
+		return nil, apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("user %s does not hold an approver role for %s", reviewer.GetName(), targetName(clusterName, projectName)))
+	}
+
+	state := v3.AccessRequestStateDenied
+	if approve {
+		rt, err := r.validate(ar, projectName)
+		if err != nil {
+			return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+		}
+		// the binding is created by Rancher, so the approver must be allowed to create it themselves
+		canBind, err := r.canBind(ctx, reviewer, clusterName, projectName, rt)
+		if err != nil {
+			return nil, err
+		}
+		if !canBind {
+			return nil, apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("user %s is not allowed to bind role template %s in %s", reviewer.GetName(), rt.Name, targetName(clusterName, projectName)))
+		}
+		state = v3.AccessRequestStateApproved
+	}
+
+	ar = ar.DeepCopy()
+	ar.Status = v3.AccessRequestStatus{
+		State:              state,
+		ReviewedBy:         reviewer.GetName(),
+		ReviewedAt:         &metav1.Time{Time: r.now()},
+		ReviewedGeneration: ar.Generation,
+		Comment:            comment,
+	}
+	ar, err = r.accessRequests.UpdateStatus(ar)
+	if apierrors.IsConflict(err) {
+		return nil, apierror.NewAPIError(validation.Conflict, fmt.Sprintf("access request %s was modified during its review, try again", name))
+	}
+	return ar, err
+}
+
+// isApprover returns true if the user holds one of the approver roles of the cluster, or of the
+// project if projectName is set. The cluster approver roles also allow reviewing requests for
+// every project of the cluster.
+func (r *reviewer) isApprover(u user.Info, clusterName, projectName string) (bool, error) {
+	clusterRoles, projectRoles, err := r.heldRoles(u, clusterName, projectName)
+	if err != nil {
+		return false, err
+	}
+	return holdsAny(projectRoles, approverRoles(settings.AccessRequestProjectApproverRoles.Get())) ||
+		holdsAny(clusterRoles, approverRoles(settings.AccessRequestClusterApproverRoles.Get())), nil
+}
+
+// heldRoles returns the role templates the user is bound to, through bindings that have not expired, in the cluster
+// and in the project if projectName is set.
+func (r *reviewer) heldRoles(u user.Info, clusterName, projectName string) ([]string, []string, error) {
+	now := r.now()
+	var clusterRoles, projectRoles []string
+	if projectName != "" {
+		prtbs, err := r.prtbCache.List(projectName, labels.Everything())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, prtb := range prtbs {
+			if isSubject(u, prtb.UserName, prtb.GroupPrincipalName) && !pkgrbac.IsPRTBExpired(prtb, now) {
+				projectRoles = append(projectRoles, prtb.RoleTemplateName)
+			}
+		}
+	}
+
+	crtbs, err := r.crtbCache.List(clusterName, labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, crtb := range crtbs {
+		if isSubject(u, crtb.UserName, crtb.GroupPrincipalName) && !pkgrbac.IsCRTBExpired(crtb, now) {
+			clusterRoles = append(clusterRoles, crtb.RoleTemplateName)
+		}
+	}
+	return clusterRoles, projectRoles, nil
+}
+
+// canBind returns true if the user could create the binding of an approved request themselves: they must be allowed
+// to create bindings in the target, and either hold every permission the role template grants there or be allowed to
+// bind the role template.
+func (r *reviewer) canBind(ctx context.Context, u user.Info, clusterName, projectName string, rt *v3.RoleTemplate) (bool, error) {
+	resource, namespace := "clusterroletemplatebindings", clusterName
+	if projectName != "" {
+		resource, namespace = "projectroletemplatebindings", projectName
+	}
+	allowed, err := r.authorize(ctx, u, &authzv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "create",
+		Group:     management.GroupName,
+		Resource:  resource,
+	})
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	allowed, err = r.authorize(ctx, u, &authzv1.ResourceAttributes{
+		Verb:     "bind",
+		Group:    management.GroupName,
+		Resource: "roletemplates",
+		Name:     rt.Name,
+	})
+	if err != nil || allowed {
+		return allowed, err
+	}
+
+	clusterRoles, projectRoles, err := r.heldRoles(u, clusterName, projectName)
+	if err != nil {
+		return false, err
+	}
+	var held []rbacv1.PolicyRule
+	for _, name := range append(clusterRoles, projectRoles...) {
+		heldRT, err := r.rtCache.Get(name)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		rules, err := pkgrbac.RulesFromTemplate(r.crCache, r.rtCache, heldRT)
+		if err != nil {
+			return false, err
+		}
+		held = append(held, rules...)
+	}
+	requested, err := pkgrbac.RulesFromTemplate(r.crCache, r.rtCache, rt)
+	if err != nil {
+		return false, err
+	}
+	covers, _ := rbacvalidation.Covers(held, requested)
+	return covers, nil
+}
+
+// authorize returns true if the user is allowed to perform the action described by attributes.
+func (r *reviewer) authorize(ctx context.Context, u user.Info, attributes *authzv1.ResourceAttributes) (bool, error) {
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range u.GetExtra() {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	response, err := r.k8s.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               u.GetName(),
+			Groups:             u.GetGroups(),
+			Extra:              extra,
+			UID:                u.GetUID(),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create a SubjectAccessReview: %w", err)
+	}
+	return response.Status.Allowed, nil
+}
+
+// validate checks that the requested role template can be bound to the target of the request
+// and that the requested duration does not exceed the maximum duration. It returns the requested role template.
+func (r *reviewer) validate(ar *v3.AccessRequest, projectName string) (*v3.RoleTemplate, error) {
+	rt, err := r.rtCache.Get(ar.Spec.RoleTemplateName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role template %s: %w", ar.Spec.RoleTemplateName, err)
+	}
+	rtContext := "cluster"
+	if projectName != "" {
+		rtContext = "project"
+	}
+	if rt.Context != rtContext {
+		return nil, fmt.Errorf("role template %s cannot be bound to a %s", rt.Name, rtContext)
+	}
+	if rt.Locked {
+		return nil, fmt.Errorf("role template %s is locked", rt.Name)
+	}
+
+	d, err := time.ParseDuration(ar.Spec.Duration)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid duration %q: must be a positive duration such as 8h", ar.Spec.Duration)
+	}
+	if maxDuration, err := time.ParseDuration(settings.AccessRequestMaxDuration.Get()); err == nil && maxDuration > 0 && d > maxDuration {
+		return nil, fmt.Errorf("duration %s exceeds the maximum duration of %s", d, maxDuration)
+	}
+	return rt, nil
+}
+
+func targetName(clusterName, projectName string) string {
+	if projectName != "" {
+		return "project " + clusterName + ":" + projectName
+	}
+	return "cluster " + clusterName
+}
+
+func isSubject(u user.Info, userName, groupPrincipalName string) bool {
+	if userName != "" {
+		return userName == u.GetName()
+	}
+	return groupPrincipalName != "" && slices.Contains(u.GetGroups(), groupPrincipalName)
+}
+
+func holdsAny(held, roles []string) bool {
+	return slices.ContainsFunc(held, func(role string) bool {
+		return slices.Contains(roles, role)
+	})
+}
+
+func approverRoles(setting string) []string {
+	var roles []string
+	for _, role := range strings.Split(setting, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,226 @@
+package accessrequests
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rancher/apiserver/pkg/apierror"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authzv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReview(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	owner := &user.DefaultInfo{Name: "u-owner"}
+	ownerGroupMember := &user.DefaultInfo{Name: "u-member", Groups: []string{"okta_group://owners"}}
+	stranger := &user.DefaultInfo{Name: "u-stranger"}
+
+	crtbs := []*v3.ClusterRoleTemplateBinding{
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "owner", Namespace: "c-abc"},
+			ClusterName:      "c-abc",
+			RoleTemplateName: "cluster-owner",
+			UserName:         "u-owner",
+		},
+		{
+			ObjectMeta:         metav1.ObjectMeta{Name: "owners", Namespace: "c-abc"},
+			ClusterName:        "c-abc",
+			RoleTemplateName:   "cluster-owner",
+			GroupPrincipalName: "okta_group://owners",
+		},
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "expired-owner", Namespace: "c-abc", CreationTimestamp: metav1.Time{Time: now.Add(-2 * time.Hour)}},
+			ClusterName:      "c-abc",
+			RoleTemplateName: "cluster-owner",
+			UserName:         "u-stranger",
+			TTL:              "1h",
+		},
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "member", Namespace: "c-abc"},
+			ClusterName:      "c-abc",
+			RoleTemplateName: "cluster-member",
+			UserName:         "u-stranger",
+		},
+	}
+	prtbs := []*v3.ProjectRoleTemplateBinding{
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "project-owner", Namespace: "p-xyz"},
+			ProjectName:      "c-abc:p-xyz",
+			RoleTemplateName: "project-owner",
+			UserName:         "u-project-owner",
+		},
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "project-binder", Namespace: "p-xyz"},
+			ProjectName:      "c-abc:p-xyz",
+			RoleTemplateName: "project-owner",
+			UserName:         "u-project-binder",
+		},
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "project-restricted", Namespace: "p-xyz"},
+			ProjectName:      "c-abc:p-xyz",
+			RoleTemplateName: "project-owner",
+			UserName:         "u-project-restricted",
+		},
+	}
+	readPods := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}
+	roleTemplates := map[string]*v3.RoleTemplate{
+		"cluster-owner":     {ObjectMeta: metav1.ObjectMeta{Name: "cluster-owner"}, Context: "cluster", Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}},
+		"project-owner":     {ObjectMeta: metav1.ObjectMeta{Name: "project-owner"}, Context: "project", Rules: readPods},
+		"cluster-member":    {ObjectMeta: metav1.ObjectMeta{Name: "cluster-member"}, Context: "cluster", Rules: readPods},
+		"project-member":    {ObjectMeta: metav1.ObjectMeta{Name: "project-member"}, Context: "project", Rules: readPods},
+		"project-escalated": {ObjectMeta: metav1.ObjectMeta{Name: "project-escalated"}, Context: "project", Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}},
+		"locked":            {ObjectMeta: metav1.ObjectMeta{Name: "locked"}, Context: "cluster", Locked: true},
+	}
+	// u-project-restricted may not create bindings, u-project-binder may bind any role template
+	canCreateBindings := []string{"u-owner", "u-member", "u-project-owner", "u-project-binder"}
+	canBindRoleTemplates := []string{"u-project-binder"}
+
+	newReviewer := func(t *testing.T, ar *v3.AccessRequest) *reviewer {
+		ctrl := gomock.NewController(t)
+		accessRequests := fake.NewMockNonNamespacedClientInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		accessRequests.EXPECT().Get(ar.Name, gomock.Any()).Return(ar, nil).AnyTimes()
+		accessRequests.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(func(obj *v3.AccessRequest) (*v3.AccessRequest, error) {
+			return obj, nil
+		}).AnyTimes()
+		crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+		crtbCache.EXPECT().List("c-abc", gomock.Any()).Return(crtbs, nil).AnyTimes()
+		prtbCache := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+		prtbCache.EXPECT().List("p-xyz", gomock.Any()).Return(prtbs, nil).AnyTimes()
+		rtCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+		rtCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.RoleTemplate, error) {
+			return roleTemplates[name], nil
+		}).AnyTimes()
+		k8s := k8sfake.NewSimpleClientset()
+		k8s.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+			switch attributes := sar.Spec.ResourceAttributes; attributes.Verb {
+			case "create":
+				sar.Status.Allowed = slices.Contains(canCreateBindings, sar.Spec.User)
+			case "bind":
+				assert.Equal(t, "roletemplates", attributes.Resource)
+				assert.Equal(t, ar.Spec.RoleTemplateName, attributes.Name)
+				sar.Status.Allowed = slices.Contains(canBindRoleTemplates, sar.Spec.User)
+			}
+			return true, sar, nil
+		})
+		return &reviewer{
+			accessRequests: accessRequests,
+			crtbCache:      crtbCache,
+			prtbCache:      prtbCache,
+			rtCache:        rtCache,
+			k8s:            k8s,
+			now:            func() time.Time { return now },
+		}
+	}
+	newAccessRequest := func(spec v3.AccessRequestSpec) *v3.AccessRequest {
+		return &v3.AccessRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "ar-test", Generation: 3},
+			Spec:       spec,
+			Status:     v3.AccessRequestStatus{State: v3.AccessRequestStatePending},
+		}
+	}
+	clusterSpec := v3.AccessRequestSpec{
+		UserName:         "u-requester",
+		RoleTemplateName: "cluster-member",
+		ClusterName:      "c-abc",
+		Duration:         "8h",
+	}
+	projectSpec := v3.AccessRequestSpec{
+		UserName:         "u-requester",
+		RoleTemplateName: "project-member",
+		ProjectName:      "c-abc:p-xyz",
+		Duration:         "8h",
+	}
+
+	tests := []struct {
+		name       string
+		spec       v3.AccessRequestSpec
+		state      string
+		reviewer   user.Info
+		approve    bool
+		wantState  string
+		wantStatus int
+	}{
+		{name: "cluster owner approves", spec: clusterSpec, reviewer: owner, approve: true, wantState: v3.AccessRequestStateApproved},
+		{name: "cluster owner denies", spec: clusterSpec, reviewer: owner, wantState: v3.AccessRequestStateDenied},
+		{name: "member of an owner group approves", spec: clusterSpec, reviewer: ownerGroupMember, approve: true, wantState: v3.AccessRequestStateApproved},
+		{name: "project owner approves", spec: projectSpec, reviewer: &user.DefaultInfo{Name: "u-project-owner"}, approve: true, wantState: v3.AccessRequestStateApproved},
+		{name: "cluster owner approves project request", spec: projectSpec, reviewer: owner, approve: true, wantState: v3.AccessRequestStateApproved},
+		{name: "project owner cannot approve cluster request", spec: clusterSpec, reviewer: &user.DefaultInfo{Name: "u-project-owner"}, approve: true, wantStatus: http.StatusForbidden},
+		{name: "user without approver role cannot approve", spec: clusterSpec, reviewer: stranger, approve: true, wantStatus: http.StatusForbidden},
+		{name: "owner cannot approve own request", spec: v3.AccessRequestSpec{UserName: "u-owner", RoleTemplateName: "cluster-member", ClusterName: "c-abc", Duration: "8h"}, reviewer: owner, approve: true, wantStatus: http.StatusForbidden},
+		{name: "reviewed request cannot be reviewed again", spec: clusterSpec, state: v3.AccessRequestStateDenied, reviewer: owner, approve: true, wantStatus: http.StatusConflict},
+		{name: "project role template cannot be approved for cluster", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "project-member", ClusterName: "c-abc", Duration: "8h"}, reviewer: owner, approve: true, wantStatus: http.StatusUnprocessableEntity},
+		{name: "locked role template cannot be approved", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "locked", ClusterName: "c-abc", Duration: "8h"}, reviewer: owner, approve: true, wantStatus: http.StatusUnprocessableEntity},
+		{name: "duration above the maximum cannot be approved", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "cluster-member", ClusterName: "c-abc", Duration: "720h"}, reviewer: owner, approve: true, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid duration cannot be approved", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "cluster-member", ClusterName: "c-abc", Duration: "forever"}, reviewer: owner, approve: true, wantStatus: http.StatusUnprocessableEntity},
+		{name: "project owner cannot approve role with permissions they do not hold", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "project-escalated", ProjectName: "c-abc:p-xyz", Duration: "8h"}, reviewer: &user.DefaultInfo{Name: "u-project-owner"}, approve: true, wantStatus: http.StatusForbidden},
+		{name: "project owner allowed to bind the role template approves", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "project-escalated", ProjectName: "c-abc:p-xyz", Duration: "8h"}, reviewer: &user.DefaultInfo{Name: "u-project-binder"}, approve: true, wantState: v3.AccessRequestStateApproved},
+		{name: "cluster owner approves role with permissions they hold", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "project-escalated", ProjectName: "c-abc:p-xyz", Duration: "8h"}, reviewer: owner, approve: true, wantState: v3.AccessRequestStateApproved},
+		{name: "approver not allowed to create bindings cannot approve", spec: projectSpec, reviewer: &user.DefaultInfo{Name: "u-project-restricted"}, approve: true, wantStatus: http.StatusForbidden},
+		{name: "approver not allowed to create bindings can deny", spec: projectSpec, reviewer: &user.DefaultInfo{Name: "u-project-restricted"}, wantState: v3.AccessRequestStateDenied},
+		{name: "invalid duration can be denied", spec: v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "cluster-member", ClusterName: "c-abc", Duration: "forever"}, reviewer: owner, wantState: v3.AccessRequestStateDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := newAccessRequest(tt.spec)
+			if tt.state != "" {
+				ar.Status.State = tt.state
+			}
+			r := newReviewer(t, ar)
+
+			got, err := r.review(context.Background(), tt.reviewer, ar.Name, tt.approve, "ok")
+			if tt.wantStatus != 0 {
+				var apiErr *apierror.APIError
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantStatus, apiErr.Code.Status)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantState, got.Status.State)
+			assert.Equal(t, tt.reviewer.GetName(), got.Status.ReviewedBy)
+			assert.Equal(t, now, got.Status.ReviewedAt.Time)
+			assert.Equal(t, int64(3), got.Status.ReviewedGeneration)
+			assert.Equal(t, "ok", got.Status.Comment)
+		})
+	}
+}
+
+func TestReviewApproverRolesSetting(t *testing.T) {
+	original := settings.AccessRequestClusterApproverRoles.Get()
+	t.Cleanup(func() {
+		require.NoError(t, settings.AccessRequestClusterApproverRoles.Set(original))
+	})
+	require.NoError(t, settings.AccessRequestClusterApproverRoles.Set("cluster-owner, cluster-member"))
+
+	ctrl := gomock.NewController(t)
+	crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+	crtbCache.EXPECT().List("c-abc", gomock.Any()).Return([]*v3.ClusterRoleTemplateBinding{
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "member", Namespace: "c-abc"},
+			ClusterName:      "c-abc",
+			RoleTemplateName: "cluster-member",
+			UserName:         "u-member",
+		},
+	}, nil)
+	r := &reviewer{crtbCache: crtbCache, now: time.Now}
+
+	isApprover, err := r.isApprover(&user.DefaultInfo{Name: "u-member"}, "c-abc", "")
+	require.NoError(t, err)
+	assert.True(t, isApprover)
+}
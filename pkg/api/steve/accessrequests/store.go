@@ -0,0 +1,199 @@
+package accessrequests
+
+import (
+	"sort"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/v2/pkg/data/convert"
+	"github.com/rancher/wrangler/v2/pkg/schemas/validation"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// store serves AccessRequests to users without RBAC access to them. Users can create requests
+// for themselves, and can only see the requests they made and the requests they can review.
+// Users with RBAC access to AccessRequests are served by the inner store.
+type store struct {
+	types.Store
+	cache    mgmtcontrollers.AccessRequestCache
+	reviewer *reviewer
+}
+
+func (s *store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if accesscontrol.GetAccessListMap(schema).All("get") {
+		return s.Store.ByID(apiOp, schema, id)
+	}
+	u, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return types.APIObject{}, validation.Unauthorized
+	}
+	ar, err := s.cache.Get(id)
+	if apierrors.IsNotFound(err) {
+		return types.APIObject{}, validation.NotFound
+	} else if err != nil {
+		return types.APIObject{}, err
+	}
+	visible, err := s.visible(u, ar)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if !visible {
+		return types.APIObject{}, validation.NotFound
+	}
+	return toAPI(schema, ar)
+}
+
+func (s *store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	if accesscontrol.GetAccessListMap(schema).All("list") {
+		return s.Store.List(apiOp, schema)
+	}
+	u, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return types.APIObjectList{}, validation.Unauthorized
+	}
+	ars, err := s.cache.List(labels.Everything())
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	sort.Slice(ars, func(i, j int) bool {
+		return ars[i].Name < ars[j].Name
+	})
+
+	var result types.APIObjectList
+	for _, ar := range ars {
+		visible, err := s.visible(u, ar)
+		if err != nil {
+			return types.APIObjectList{}, err
+		}
+		if !visible {
+			continue
+		}
+		obj, err := toAPI(schema, ar)
+		if err != nil {
+			return types.APIObjectList{}, err
+		}
+		result.Objects = append(result.Objects, obj)
+	}
+	return result, nil
+}
+
+// Create creates an AccessRequest for the user making the request. Requests cannot be made on
+// behalf of other users.
+func (s *store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	u, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return types.APIObject{}, validation.Unauthorized
+	}
+	spec := data.Data().Map("spec")
+	if userName := spec.String("userName"); userName != "" && userName != u.GetName() {
+		return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, "access requests can only be made for the requesting user")
+	}
+	data.Data().SetNested(u.GetName(), "spec", "userName")
+
+	if accesscontrol.GetAccessListMap(schema).All("create") {
+		return s.Store.Create(apiOp, schema, data)
+	}
+
+	input := &v3.AccessRequest{}
+	if err := convert.ToObj(data.Data(), input); err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	ar := &v3.AccessRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:         input.Name,
+			GenerateName: "ar-",
+		},
+		Spec: input.Spec,
+	}
+	if ar.Name != "" {
+		ar.GenerateName = ""
+	}
+	ar, err := s.reviewer.accessRequests.Create(ar)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return toAPI(schema, ar)
+}
+
+func (s *store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	if accesscontrol.GetAccessListMap(schema).All("update") {
+		return s.Store.Update(apiOp, schema, data, id)
+	}
+	return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, "access requests cannot be updated, use the approve and deny actions to review them")
+}
+
+// Delete lets requesters withdraw their own requests.
+func (s *store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if accesscontrol.GetAccessListMap(schema).All("delete") {
+		return s.Store.Delete(apiOp, schema, id)
+	}
+	u, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return types.APIObject{}, validation.Unauthorized
+	}
+	ar, err := s.cache.Get(id)
+	if apierrors.IsNotFound(err) {
+		return types.APIObject{}, validation.NotFound
+	} else if err != nil {
+		return types.APIObject{}, err
+	}
+	if ar.Spec.UserName != u.GetName() {
+		visible, err := s.visible(u, ar)
+		if err != nil {
+			return types.APIObject{}, err
+		}
+		if !visible {
+			return types.APIObject{}, validation.NotFound
+		}
+		return types.APIObject{}, apierror.NewAPIError(validation.PermissionDenied, "access requests can only be deleted by the requesting user")
+	}
+	if err := s.reviewer.accessRequests.Delete(id, &metav1.DeleteOptions{}); err != nil {
+		return types.APIObject{}, err
+	}
+	return toAPI(schema, ar)
+}
+
+func (s *store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	if accesscontrol.GetAccessListMap(schema).All("watch") {
+		return s.Store.Watch(apiOp, schema, w)
+	}
+	return nil, nil
+}
+
+// visible returns true if the user made the request or can review it.
+func (s *store) visible(u user.Info, ar *v3.AccessRequest) (bool, error) {
+	if ar.Spec.UserName == u.GetName() {
+		return true, nil
+	}
+	clusterName, projectName, err := pkgrbac.AccessRequestTarget(ar)
+	if err != nil {
+		return false, nil
+	}
+	return s.reviewer.isApprover(u, clusterName, projectName)
+}
+
+func toAPI(schema *types.APISchema, ar *v3.AccessRequest) (types.APIObject, error) {
+	ar = ar.DeepCopy()
+	ar.APIVersion = v3.SchemeGroupVersion.String()
+	ar.Kind = "AccessRequest"
+	ar.ManagedFields = nil
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ar)
+	if err != nil {
+		return types.APIObject{}, apierror.NewAPIError(validation.ServerError, err.Error())
+	}
+	return types.APIObject{
+		Type:   schema.ID,
+		ID:     ar.Name,
+		Object: &unstructured.Unstructured{Object: data},
+	}, nil
+}
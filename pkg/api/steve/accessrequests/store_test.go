@@ -0,0 +1,147 @@
+package accessrequests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/rancher/wrangler/v2/pkg/schemas"
+	"github.com/rancher/wrangler/v2/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestStore(t *testing.T) {
+	accessRequests := []*v3.AccessRequest{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ar-other"},
+			Spec:       v3.AccessRequestSpec{UserName: "u-other", RoleTemplateName: "cluster-member", ClusterName: "c-other", Duration: "8h"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ar-mine"},
+			Spec:       v3.AccessRequestSpec{UserName: "u-requester", RoleTemplateName: "cluster-member", ClusterName: "c-abc", Duration: "8h"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ar-review"},
+			Spec:       v3.AccessRequestSpec{UserName: "u-other", RoleTemplateName: "cluster-member", ClusterName: "c-abc", Duration: "8h"},
+		},
+	}
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "management.cattle.io.accessrequest"}}
+
+	newStore := func(t *testing.T) (*store, *fake.MockNonNamespacedClientInterface[*v3.AccessRequest, *v3.AccessRequestList]) {
+		ctrl := gomock.NewController(t)
+		client := fake.NewMockNonNamespacedClientInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		cache := fake.NewMockNonNamespacedCacheInterface[*v3.AccessRequest](ctrl)
+		cache.EXPECT().List(gomock.Any()).Return(accessRequests, nil).AnyTimes()
+		cache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.AccessRequest, error) {
+			for _, ar := range accessRequests {
+				if ar.Name == name {
+					return ar, nil
+				}
+			}
+			return nil, apierrors.NewNotFound(v3.Resource("accessrequests"), name)
+		}).AnyTimes()
+		crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+		crtbCache.EXPECT().List("c-abc", gomock.Any()).Return([]*v3.ClusterRoleTemplateBinding{
+			{
+				ObjectMeta:       metav1.ObjectMeta{Name: "owner", Namespace: "c-abc"},
+				ClusterName:      "c-abc",
+				RoleTemplateName: "cluster-owner",
+				UserName:         "u-owner",
+			},
+		}, nil).AnyTimes()
+		crtbCache.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+		return &store{
+			cache: cache,
+			reviewer: &reviewer{
+				accessRequests: client,
+				crtbCache:      crtbCache,
+				now:            time.Now,
+			},
+		}, client
+	}
+	newAPIOp := func(u user.Info) *types.APIRequest {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		return &types.APIRequest{Request: req.WithContext(request.WithUser(req.Context(), u))}
+	}
+	names := func(list types.APIObjectList) []string {
+		var names []string
+		for _, obj := range list.Objects {
+			names = append(names, obj.ID)
+		}
+		return names
+	}
+
+	t.Run("requester lists own requests", func(t *testing.T) {
+		s, _ := newStore(t)
+		list, err := s.List(newAPIOp(&user.DefaultInfo{Name: "u-requester"}), schema)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ar-mine"}, names(list))
+	})
+
+	t.Run("approver lists requests they can review", func(t *testing.T) {
+		s, _ := newStore(t)
+		list, err := s.List(newAPIOp(&user.DefaultInfo{Name: "u-owner"}), schema)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ar-mine", "ar-review"}, names(list))
+	})
+
+	t.Run("requests of other users are not found", func(t *testing.T) {
+		s, _ := newStore(t)
+		_, err := s.ByID(newAPIOp(&user.DefaultInfo{Name: "u-requester"}), schema, "ar-review")
+		assert.Equal(t, validation.NotFound, err)
+	})
+
+	t.Run("create sets the requesting user", func(t *testing.T) {
+		s, client := newStore(t)
+		client.EXPECT().Create(gomock.Any()).DoAndReturn(func(ar *v3.AccessRequest) (*v3.AccessRequest, error) {
+			assert.Equal(t, "u-requester", ar.Spec.UserName)
+			assert.Equal(t, "ar-", ar.GenerateName)
+			ar.Name = "ar-new"
+			return ar, nil
+		})
+		obj, err := s.Create(newAPIOp(&user.DefaultInfo{Name: "u-requester"}), schema, types.APIObject{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"roleTemplateName": "cluster-member",
+				"clusterName":      "c-abc",
+				"duration":         "8h",
+			},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, "ar-new", obj.ID)
+		assert.Equal(t, "u-requester", obj.Data().String("spec", "userName"))
+	})
+
+	t.Run("create rejects requests for other users", func(t *testing.T) {
+		s, _ := newStore(t)
+		_, err := s.Create(newAPIOp(&user.DefaultInfo{Name: "u-requester"}), schema, types.APIObject{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"userName":         "u-other",
+				"roleTemplateName": "cluster-member",
+				"clusterName":      "c-abc",
+				"duration":         "8h",
+			},
+		}})
+		var apiErr *apierror.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusForbidden, apiErr.Code.Status)
+	})
+
+	t.Run("approver cannot delete requests of other users", func(t *testing.T) {
+		s, _ := newStore(t)
+		_, err := s.Delete(newAPIOp(&user.DefaultInfo{Name: "u-owner"}), schema, "ar-review")
+		var apiErr *apierror.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusForbidden, apiErr.Code.Status)
+	})
+}
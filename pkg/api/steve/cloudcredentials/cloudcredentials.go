@@ -0,0 +1,239 @@
+// Package cloudcredentials provides a HTTPHandler that lists the objects that reference a cloud credential, and that
+// rotates the fields of the credential. This handler should be registered at Endpoint.
+package cloudcredentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/rancher/pkg/auth/util"
+	"github.com/rancher/rancher/pkg/controllers/management/cloudcredential"
+	"github.com/rancher/rancher/pkg/types/config"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v2/pkg/kv"
+	"github.com/sirupsen/logrus"
+	authzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Endpoint The endpoint that this URL is accessible at - used for routing. The id is the ID of the cloud credential
+	// of the form namespace:name.
+	Endpoint = "/v1/cloudCredentialConsumers/{id}"
+
+	// ActionRotate replaces the fields of the cloud credential given in the body of the request and enqueues the objects
+	// referencing it.
+	ActionRotate = "rotate"
+
+	// maxBodySize is the maximum size of the body of a rotate request.
+	maxBodySize = 1 << 20
+
+	logPrefix = "cloud-credential-consumers"
+)
+
+type consumerIndex interface {
+	Consumers(id string) ([]cloudcredential.Consumer, error)
+}
+
+// Handler implements http.Handler - and serves the consumers of cloud credentials
+type Handler struct {
+	k8s        kubernetes.Interface
+	mapper     meta.RESTMapper
+	secrets    corecontrollers.SecretClient
+	consumers  consumerIndex
+	validators map[string]cloudcredential.Validator
+}
+
+func NewHandler(scaledContext *config.ScaledContext) *Handler {
+	return &Handler{
+		k8s:        scaledContext.K8sClient,
+		mapper:     scaledContext.Wrangler.RESTMapper,
+		secrets:    scaledContext.Wrangler.Core.Secret(),
+		consumers:  cloudcredential.NewConsumerIndex(scaledContext.Wrangler),
+		validators: cloudcredential.NewValidators(scaledContext.Wrangler.Mgmt.Token().Cache()),
+	}
+}
+
+// Consumers is the response of GET and rotate requests.
+type Consumers struct {
+	// ID is the ID of the cloud credential.
+	ID string `json:"id"`
+	// Consumers are the objects referencing the credential that the user can get. After a rotation they are enqueued
+	// by the management controllers once the changed credential is seen.
+	Consumers []cloudcredential.Consumer `json:"consumers"`
+}
+
+// RotateInput is the body of a rotate request.
+type RotateInput struct {
+	// Fields are the fields of the credential to replace, by name without the type prefix, such as accessKey.
+	Fields map[string]string `json:"fields"`
+}
+
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	var verb string
+	switch req.Method {
+	case http.MethodGet:
+		verb = "get"
+	case http.MethodPost:
+		verb = "update"
+	default:
+		util.ReturnHTTPError(writer, req, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+
+	id := mux.Vars(req)["id"]
+	ns, name := kv.Split(id, ":")
+	if ns == "" || name == "" {
+		util.ReturnHTTPError(writer, req, http.StatusBadRequest, fmt.Sprintf("invalid cloud credential ID %q, must be of the form namespace:name", id))
+		return
+	}
+
+	authorized, err := h.authorize(req, authzv1.ResourceAttributes{
+		Namespace: ns,
+		Resource:  "secrets",
+		Name:      name,
+		Verb:      verb,
+	})
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		logrus.Errorf("[%s] Failed to authorize user with error: %s", logPrefix, err.Error())
+		return
+	}
+	if !authorized {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		if action := req.URL.Query().Get("action"); action != ActionRotate {
+			util.ReturnHTTPError(writer, req, http.StatusBadRequest, fmt.Sprintf("invalid action %q, must be %s", action, ActionRotate))
+			return
+		}
+		if code, err := h.rotate(req, ns, name); err != nil {
+			util.ReturnHTTPError(writer, req, code, err.Error())
+			return
+		}
+	} else if _, err := h.secrets.Get(ns, name, metav1.GetOptions{}); err != nil {
+		code := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+		util.ReturnHTTPError(writer, req, code, err.Error())
+		return
+	}
+
+	consumers, err := h.consumers.Consumers(id)
+	if err == nil {
+		consumers, err = h.visible(req, consumers)
+	}
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusInternalServerError, err.Error())
+		logrus.Errorf("[%s] Failed to list consumers of cloud credential %s: %v", logPrefix, id, err)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodPost {
+		writer.WriteHeader(http.StatusAccepted)
+	}
+	if err := json.NewEncoder(writer).Encode(Consumers{ID: id, Consumers: consumers}); err != nil {
+		logrus.Errorf("[%s] Error when writing consumers of cloud credential %s: %v", logPrefix, id, err)
+	}
+}
+
+// visible returns the consumers the user can get, so that the response does not reveal the names of objects the user
+// cannot see.
+func (h *Handler) visible(r *http.Request, consumers []cloudcredential.Consumer) ([]cloudcredential.Consumer, error) {
+	result := []cloudcredential.Consumer{}
+	for _, consumer := range consumers {
+		gv, err := schema.ParseGroupVersion(consumer.APIVersion)
+		if err != nil {
+			return nil, err
+		}
+		mapping, err := h.mapper.RESTMapping(gv.WithKind(consumer.Kind).GroupKind(), gv.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the resource of %s %s: %w", consumer.APIVersion, consumer.Kind, err)
+		}
+		authorized, err := h.authorize(r, authzv1.ResourceAttributes{
+			Namespace: consumer.Namespace,
+			Group:     gv.Group,
+			Version:   gv.Version,
+			Resource:  mapping.Resource.Resource,
+			Name:      consumer.Name,
+			Verb:      "get",
+		})
+		if err != nil {
+			return nil, err
+		}
+		if authorized {
+			result = append(result, consumer)
+		}
+	}
+	return result, nil
+}
+
+// authorize checks to see if the user can perform the request described by attributes. Returns a bool (if the user is
+// authorized) and optionally an error.
+func (h *Handler) authorize(r *http.Request, attributes authzv1.ResourceAttributes) (bool, error) {
+	userInfo, ok := request.UserFrom(r.Context())
+	if !ok {
+		return false, fmt.Errorf("unable to extract user info from context")
+	}
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range userInfo.GetExtra() {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	response, err := h.k8s.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               userInfo.GetName(),
+			Groups:             userInfo.GetGroups(),
+			Extra:              extra,
+			UID:                userInfo.GetUID(),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create a SubjectAccessReview: %w", err)
+	}
+	return response.Status.Allowed, nil
+}
+
+// rotate replaces the fields of the cloud credential given in the body of the request. Returns the HTTP status code to
+// respond with if it fails.
+func (h *Handler) rotate(req *http.Request, namespace, name string) (int, error) {
+	var input RotateInput
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxBodySize)).Decode(&input); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid body: %w", err)
+	}
+
+	secret, err := h.secrets.Get(namespace, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	rotated, err := cloudcredential.Rotate(req.Context(), secret, input.Fields, h.validators)
+	var invalidErr *cloudcredential.InvalidError
+	if errors.As(err, &invalidErr) {
+		return http.StatusUnprocessableEntity, fmt.Errorf("rotated fields were rejected: %w", err)
+	} else if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if _, err := h.secrets.Update(rotated); apierrors.IsConflict(err) {
+		return http.StatusConflict, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	logrus.Infof("[%s] Cloud credential %s:%s rotated", logPrefix, namespace, name)
+	return http.StatusAccepted, nil
+}
@@ -0,0 +1,158 @@
+package cloudcredentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/rancher/rancher/pkg/controllers/management/cloudcredential"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const credentialID = "cattle-global-data:cc-abc"
+
+type fakeConsumerIndex []cloudcredential.Consumer
+
+func (f fakeConsumerIndex) Consumers(id string) ([]cloudcredential.Consumer, error) {
+	if id != credentialID {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// newTestHandler returns a handler for a user that is only allowed the requests given as "verb group/resource
+// namespace/name".
+func newTestHandler(t *testing.T, allowed ...string) (*Handler, *fake.MockControllerInterface[*corev1.Secret, *corev1.SecretList]) {
+	ctrl := gomock.NewController(t)
+
+	k8s := k8sfake.NewSimpleClientset()
+	k8s.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		assert.Equal(t, "u-admin", sar.Spec.User)
+		attributes := sar.Spec.ResourceAttributes
+		request := fmt.Sprintf("%s %s/%s %s/%s", attributes.Verb, attributes.Group, attributes.Resource, attributes.Namespace, attributes.Name)
+		for _, a := range allowed {
+			if request == a {
+				sar.Status.Allowed = true
+			}
+		}
+		return true, sar, nil
+	})
+
+	provisioning := schema.GroupVersion{Group: "provisioning.cattle.io", Version: "v1"}
+	management := schema.GroupVersion{Group: "management.cattle.io", Version: "v3"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{provisioning, management})
+	mapper.Add(provisioning.WithKind("Cluster"), meta.RESTScopeNamespace)
+	mapper.Add(management.WithKind("Cluster"), meta.RESTScopeRoot)
+
+	secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	secrets.EXPECT().Get("cattle-global-data", "cc-abc", gomock.Any()).Return(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc-abc", Namespace: "cattle-global-data"},
+		Data:       map[string][]byte{"digitaloceancredentialConfig-accessToken": []byte("abc")},
+	}, nil).AnyTimes()
+	secrets.EXPECT().Get("cattle-global-data", "cc-missing", gomock.Any()).Return(nil,
+		apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "cc-missing")).AnyTimes()
+
+	return &Handler{
+		k8s:     k8s,
+		mapper:  mapper,
+		secrets: secrets,
+		consumers: fakeConsumerIndex{
+			{APIVersion: "provisioning.cattle.io/v1", Kind: "Cluster", Namespace: "fleet-default", Name: "c1", Fields: []string{"spec.cloudCredentialSecretName"}},
+			{APIVersion: "provisioning.cattle.io/v1", Kind: "Cluster", Namespace: "fleet-other", Name: "c2", Fields: []string{"spec.cloudCredentialSecretName"}},
+			{APIVersion: "management.cattle.io/v3", Kind: "Cluster", Name: "c-eks", Fields: []string{"spec.eksConfig.amazonCredentialSecret"}},
+		},
+		validators: map[string]cloudcredential.Validator{
+			"digitalocean": cloudcredential.ValidatorFunc(func(_ context.Context, fields map[string]string) (time.Time, error) {
+				if fields["accessToken"] != "new" {
+					return time.Time{}, &cloudcredential.InvalidError{Err: assert.AnError}
+				}
+				return time.Time{}, nil
+			}),
+		},
+	}, secrets
+}
+
+func serve(h *Handler, method, id, query, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/v1/cloudCredentialConsumers/"+id+query, strings.NewReader(body))
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "u-admin"}))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeHTTPForbidden(t *testing.T) {
+	h, _ := newTestHandler(t, "get /secrets cattle-global-data/cc-abc")
+
+	assert.Equal(t, http.StatusForbidden, serve(h, http.MethodPost, credentialID, "?action=rotate", `{"fields":{"accessToken":"new"}}`).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(h, http.MethodDelete, credentialID, "", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodGet, "cc-abc", "", "").Code)
+}
+
+func TestServeHTTPGet(t *testing.T) {
+	h, _ := newTestHandler(t,
+		"get /secrets cattle-global-data/cc-abc",
+		"get /secrets cattle-global-data/cc-missing",
+		"get provisioning.cattle.io/clusters fleet-default/c1",
+		"get management.cattle.io/clusters /c-eks",
+	)
+
+	rec := serve(h, http.MethodGet, credentialID, "", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result Consumers
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, credentialID, result.ID)
+	// consumers the user cannot get are not listed
+	require.Len(t, result.Consumers, 2)
+	assert.Equal(t, "c1", result.Consumers[0].Name)
+	assert.Equal(t, "c-eks", result.Consumers[1].Name)
+
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodGet, "cattle-global-data:cc-missing", "", "").Code)
+}
+
+func TestServeHTTPRotate(t *testing.T) {
+	h, secrets := newTestHandler(t,
+		"update /secrets cattle-global-data/cc-abc",
+		"update /secrets cattle-global-data/cc-missing",
+	)
+
+	var updated *corev1.Secret
+	secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		updated = secret
+		return secret, nil
+	})
+	rec := serve(h, http.MethodPost, credentialID, "?action=rotate", `{"fields":{"accessToken":"new"}}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Equal(t, "new", string(updated.Data["digitaloceancredentialConfig-accessToken"]))
+	var result Consumers
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Empty(t, result.Consumers)
+
+	// rejected fields are not stored
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(h, http.MethodPost, credentialID, "?action=rotate", `{"fields":{"accessToken":"revoked"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, credentialID, "?action=rotate", `{"fields":{}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, credentialID, "?action=rotate", `{"fields":{"accesToken":"new"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, credentialID, "?action=delete", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodPost, "cattle-global-data:cc-missing", "?action=rotate", `{"fields":{"accessToken":"new"}}`).Code)
+}
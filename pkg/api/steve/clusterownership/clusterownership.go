@@ -0,0 +1,358 @@
+// Package clusterownership provides a HTTPHandler that lists which Rancher replica runs the owner controllers of each
+// cluster, and that drains replicas or rebalances the clusters over the replicas. This handler should be registered at
+// Endpoint.
+package clusterownership
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/util"
+	managementv3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/peermanager"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Endpoint The endpoint that this URL is accessible at - used for routing
+	Endpoint = "/v1/clusterOwnership"
+	// authorizationResource is the virtual resource of the management.cattle.io group that users must be allowed to get
+	// to list the owners, and to update to drain replicas or rebalance clusters.
+	authorizationResource = "clusterownership"
+
+	// ActionDrain moves the clusters owned by the replica in the peer query parameter to the other replicas.
+	ActionDrain = "drain"
+	// ActionUndrain lets the replica in the peer query parameter own clusters again.
+	ActionUndrain = "undrain"
+	// ActionRebalance makes every replica read the current replicas from the API server and reassign the clusters over
+	// them, which fixes replicas disagreeing on the owners because they missed a change of the replicas.
+	ActionRebalance = "rebalance"
+
+	logPrefix = "cluster-ownership"
+)
+
+type owners interface {
+	Peers() peermanager.Peers
+	Self() string
+	Names() []string
+	IsDrained(name string) bool
+	Assignment() string
+	Assign(clusterUIDs []string) map[string]string
+}
+
+// Handler implements http.Handler - and serves the cluster ownership of the Rancher replicas
+type Handler struct {
+	k8s        kubernetes.Interface
+	clusters   managementv3.ClusterCache
+	configMaps corecontrollers.ConfigMapCache
+	owners     owners
+	clustered  bool
+	now        func() time.Time
+}
+
+func NewHandler(scaledContext *config.ScaledContext, owners *peermanager.Owners) *Handler {
+	return &Handler{
+		k8s:        scaledContext.K8sClient,
+		clusters:   scaledContext.Wrangler.Mgmt.Cluster().Cache(),
+		configMaps: scaledContext.Wrangler.Core.ConfigMap().Cache(),
+		owners:     owners,
+		clustered:  scaledContext.PeerManager != nil,
+		now:        time.Now,
+	}
+}
+
+// Ownership is the response of a GET request.
+type Ownership struct {
+	// Clustered is false if Rancher runs as a single replica, which owns every cluster.
+	Clustered bool `json:"clustered"`
+	// Self is the name of the replica that served the request.
+	Self   string `json:"self,omitempty"`
+	Leader bool   `json:"leader"`
+	// Ready is false while the replica that served the request does not know the other replicas yet.
+	Ready bool `json:"ready"`
+	// Assignment is the assignment version clusters are assigned to replicas with, "legacy" while some replica does
+	// not support the current one.
+	Assignment string `json:"assignment,omitempty"`
+	// LastRebalance is the time the last rebalance was requested at.
+	LastRebalance string         `json:"lastRebalance,omitempty"`
+	Peers         []Peer         `json:"peers"`
+	Clusters      []ClusterOwner `json:"clusters"`
+}
+
+// Peer is a Rancher replica.
+type Peer struct {
+	// ID is the name of the pod of the replica.
+	ID      string `json:"id"`
+	Drained bool   `json:"drained"`
+	// Version is the assignment version the replica supports, empty if it does not report the clusters it owns.
+	Version string `json:"version,omitempty"`
+	// Clusters is the number of clusters the replica reports owning.
+	Clusters int `json:"clusters"`
+}
+
+// ClusterOwner is the replica that owns a cluster.
+type ClusterOwner struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Owner is the name of the replica that reports running the owner controllers of the cluster, empty if Rancher is
+	// not clustered or no replica reports owning it.
+	Owner string `json:"owner,omitempty"`
+	// Assigned is the name of the replica the cluster is assigned to as seen by the replica that served the request.
+	// It differs from Owner while the cluster moves to another replica.
+	Assigned string `json:"assigned,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	var verb string
+	switch req.Method {
+	case http.MethodGet:
+		verb = "get"
+	case http.MethodPost:
+		verb = "update"
+	default:
+		util.ReturnHTTPError(writer, req, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+
+	authorized, err := h.authorize(req, verb)
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		logrus.Errorf("[%s] Failed to authorize user with error: %s", logPrefix, err.Error())
+		return
+	}
+	if !authorized {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		if code, err := h.doAction(req); err != nil {
+			util.ReturnHTTPError(writer, req, code, err.Error())
+			return
+		}
+		// every replica reassigns the clusters once it sees the changed setting, clients GET to follow the progress
+		writer.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ownership, err := h.ownership()
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusInternalServerError, err.Error())
+		logrus.Errorf("[%s] Failed to list cluster owners: %v", logPrefix, err)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(ownership); err != nil {
+		logrus.Errorf("[%s] Error when writing cluster owners: %v", logPrefix, err)
+	}
+}
+
+// authorize checks to see if the user can perform verb on the clusterownership resource. Returns a bool (if the user is
+// authorized) and optionally an error.
+func (h *Handler) authorize(r *http.Request, verb string) (bool, error) {
+	userInfo, ok := request.UserFrom(r.Context())
+	if !ok {
+		return false, fmt.Errorf("unable to extract user info from context")
+	}
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range userInfo.GetExtra() {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	response, err := h.k8s.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Group:    "management.cattle.io",
+				Resource: authorizationResource,
+				Verb:     verb,
+			},
+			User:   userInfo.GetName(),
+			Groups: userInfo.GetGroups(),
+			Extra:  extra,
+			UID:    userInfo.GetUID(),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create a SubjectAccessReview: %w", err)
+	}
+	return response.Status.Allowed, nil
+}
+
+// doAction performs the action of a POST request. Returns the HTTP status code to respond with if it fails.
+func (h *Handler) doAction(req *http.Request) (int, error) {
+	if !h.clustered {
+		return http.StatusBadRequest, fmt.Errorf("rancher is not running with multiple replicas")
+	}
+
+	query := req.URL.Query()
+	action := query.Get("action")
+	switch action {
+	case ActionDrain, ActionUndrain:
+		peer := query.Get("peer")
+		if peer == "" {
+			return http.StatusBadRequest, fmt.Errorf("peer is required to %s a replica", action)
+		}
+		drained := drainedPeers()
+		if action == ActionDrain {
+			if !h.isPeer(peer) {
+				return http.StatusBadRequest, fmt.Errorf("unknown replica %q", peer)
+			}
+			drained[peer] = true
+		} else {
+			delete(drained, peer)
+		}
+		ids := make([]string, 0, len(drained))
+		for id := range drained {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		if err := settings.ClusterOwnerDrainedPeers.Set(strings.Join(ids, ",")); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to update drained replicas: %w", err)
+		}
+		logrus.Infof("[%s] Replica %s %sed, drained replicas: %v", logPrefix, peer, action, ids)
+	case ActionRebalance:
+		if err := settings.ClusterOwnerRebalanceRequested.Set(h.now().UTC().Format(time.RFC3339)); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to request rebalance: %w", err)
+		}
+		logrus.Infof("[%s] Rebalance of clusters requested", logPrefix)
+	default:
+		return http.StatusBadRequest, fmt.Errorf("invalid action %q, must be one of %s, %s or %s", action,
+			ActionDrain, ActionUndrain, ActionRebalance)
+	}
+	return http.StatusAccepted, nil
+}
+
+func (h *Handler) isPeer(name string) bool {
+	for _, peer := range h.owners.Names() {
+		if peer == name {
+			return true
+		}
+	}
+	return false
+}
+
+// drainedPeers returns the IDs of the replicas in the cluster-owner-drained-peers setting.
+func drainedPeers() map[string]bool {
+	drained := map[string]bool{}
+	for _, id := range strings.Split(settings.ClusterOwnerDrainedPeers.Get(), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			drained[id] = true
+		}
+	}
+	return drained
+}
+
+func (h *Handler) ownership() (*Ownership, error) {
+	clusters, err := h.clusters.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+
+	peers := h.owners.Peers()
+	result := &Ownership{
+		Clustered:     h.clustered,
+		Self:          h.owners.Self(),
+		Leader:        peers.Leader,
+		Ready:         peers.Ready,
+		LastRebalance: settings.ClusterOwnerRebalanceRequested.Get(),
+		Peers:         []Peer{},
+		Clusters:      []ClusterOwner{},
+	}
+	if !h.clustered {
+		result.Self = ""
+		result.Leader, result.Ready = true, true
+	}
+
+	// clusters without controllers have no owner, see userControllersController.peersSync
+	var (
+		uids   []string
+		byName = map[string]string{}
+	)
+	for _, cluster := range clusters {
+		if cluster.DeletionTimestamp == nil && apimgmtv3.ClusterConditionProvisioned.IsTrue(cluster) {
+			uids = append(uids, string(cluster.UID))
+			byName[cluster.Name] = string(cluster.UID)
+		}
+	}
+
+	var (
+		assigned map[string]string
+		reports  = map[string]peermanager.OwnerReport{}
+		owners   = map[string]string{}
+		counts   = map[string]int{}
+	)
+	if h.clustered {
+		assigned = h.owners.Assign(uids)
+		result.Assignment = h.owners.Assignment()
+		if reports, err = h.reports(); err != nil {
+			return nil, err
+		}
+		// reports of replicas that are gone are ignored
+		for _, name := range h.owners.Names() {
+			for _, cluster := range reports[name].Clusters {
+				// a cluster moving to another replica is briefly reported by both, show the one it is assigned to
+				if _, ok := owners[cluster]; !ok || name == assigned[byName[cluster]] {
+					owners[cluster] = name
+				}
+			}
+		}
+	}
+
+	for _, cluster := range clusters {
+		uid, ok := byName[cluster.Name]
+		if !ok {
+			continue
+		}
+		owner := ClusterOwner{
+			Name:        cluster.Name,
+			DisplayName: cluster.Spec.DisplayName,
+			Owner:       owners[cluster.Name],
+			Assigned:    assigned[uid],
+		}
+		if owner.Owner != "" {
+			counts[owner.Owner]++
+		}
+		result.Clusters = append(result.Clusters, owner)
+	}
+	if h.clustered {
+		for _, name := range h.owners.Names() {
+			result.Peers = append(result.Peers, Peer{
+				ID:       name,
+				Drained:  h.owners.IsDrained(name),
+				Version:  reports[name].Version,
+				Clusters: counts[name],
+			})
+		}
+	}
+	return result, nil
+}
+
+// reports returns the reports of the replicas by name.
+func (h *Handler) reports() (map[string]peermanager.OwnerReport, error) {
+	configMaps, err := h.configMaps.List(settings.Namespace.Get(), labels.SelectorFromSet(labels.Set{peermanager.OwnerReportLabel: "true"}))
+	if err != nil {
+		return nil, err
+	}
+	reports := map[string]peermanager.OwnerReport{}
+	for _, configMap := range configMaps {
+		if report, ok := peermanager.OwnerReportFromConfigMap(configMap); ok {
+			reports[report.Replica] = report
+		}
+	}
+	return reports, nil
+}
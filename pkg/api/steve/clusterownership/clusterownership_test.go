@@ -0,0 +1,205 @@
+package clusterownership
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/peermanager"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestHandler(t *testing.T, allowedVerbs ...string) *Handler {
+	ctrl := gomock.NewController(t)
+
+	k8s := k8sfake.NewSimpleClientset()
+	k8s.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		assert.Equal(t, "management.cattle.io", sar.Spec.ResourceAttributes.Group)
+		assert.Equal(t, "clusterownership", sar.Spec.ResourceAttributes.Resource)
+		assert.Equal(t, "u-admin", sar.Spec.User)
+		for _, verb := range allowedVerbs {
+			if sar.Spec.ResourceAttributes.Verb == verb {
+				sar.Status.Allowed = true
+			}
+		}
+		return true, sar, nil
+	})
+
+	provisioned := apimgmtv3.ClusterStatus{Conditions: []apimgmtv3.ClusterCondition{
+		{Type: apimgmtv3.ClusterConditionType(apimgmtv3.ClusterConditionProvisioned), Status: corev1.ConditionTrue},
+	}}
+	clusters := fake.NewMockNonNamespacedCacheInterface[*apimgmtv3.Cluster](ctrl)
+	clusters.EXPECT().List(gomock.Any()).Return([]*apimgmtv3.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "local", UID: types.UID("uid-local")}, Status: provisioned},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c-m-abc", UID: types.UID("uid-abc")}, Spec: apimgmtv3.ClusterSpec{DisplayName: "abc"}, Status: provisioned},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c-m-pending", UID: types.UID("uid-pending")}},
+	}, nil).AnyTimes()
+
+	// rancher-b still reports local after it moved to rancher-a, a replica that is gone reports c-m-abc
+	reports := []*corev1.ConfigMap{
+		peermanager.NewOwnerReportConfigMap("cattle-system", peermanager.OwnerReport{Replica: "rancher-a", Version: peermanager.AssignmentVersion, Clusters: []string{"local"}}),
+		peermanager.NewOwnerReportConfigMap("cattle-system", peermanager.OwnerReport{Replica: "rancher-b", Version: peermanager.AssignmentVersion, Clusters: []string{"c-m-abc", "local"}}),
+		peermanager.NewOwnerReportConfigMap("cattle-system", peermanager.OwnerReport{Replica: "rancher-old", Version: peermanager.AssignmentVersion, Clusters: []string{"c-m-abc"}}),
+	}
+	configMaps := fake.NewMockCacheInterface[*corev1.ConfigMap](ctrl)
+	configMaps.EXPECT().List("cattle-system", gomock.Any()).Return(reports, nil).AnyTimes()
+
+	owners := peermanager.NewOwners()
+	owners.SetPeers(peermanager.Peers{
+		SelfID:   "10.0.0.1",
+		SelfName: "rancher-a",
+		IDs:      []string{"10.0.0.1", "10.0.0.2"},
+		Names:    map[string]string{"10.0.0.2": "rancher-b"},
+		Ready:    true,
+		Leader:   true,
+	})
+	owners.SetReports([]peermanager.OwnerReport{
+		{Replica: "rancher-a", Version: peermanager.AssignmentVersion},
+		{Replica: "rancher-b", Version: peermanager.AssignmentVersion},
+	})
+
+	return &Handler{
+		k8s:        k8s,
+		clusters:   clusters,
+		configMaps: configMaps,
+		owners:     owners,
+		clustered:  true,
+		now:        func() time.Time { return now },
+	}
+}
+
+func serve(h *Handler, method, url string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "u-admin"}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func resetSettings(t *testing.T) {
+	require.NoError(t, settings.Namespace.Set("cattle-system"))
+	t.Cleanup(func() {
+		require.NoError(t, settings.Namespace.Set(""))
+		require.NoError(t, settings.ClusterOwnerDrainedPeers.Set(""))
+		require.NoError(t, settings.ClusterOwnerRebalanceRequested.Set(""))
+	})
+}
+
+func TestServeHTTPForbidden(t *testing.T) {
+	h := newTestHandler(t, "get")
+	assert.Equal(t, http.StatusForbidden, serve(newTestHandler(t), http.MethodGet, Endpoint).Code)
+	assert.Equal(t, http.StatusForbidden, serve(h, http.MethodPost, Endpoint+"?action=rebalance").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(h, http.MethodDelete, Endpoint).Code)
+}
+
+func TestServeHTTPList(t *testing.T) {
+	resetSettings(t)
+	h := newTestHandler(t, "get")
+	rec := serve(h, http.MethodGet, Endpoint)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var ownership Ownership
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ownership))
+	assert.True(t, ownership.Clustered)
+	assert.Equal(t, "rancher-a", ownership.Self)
+	assert.True(t, ownership.Ready)
+	assert.Equal(t, peermanager.AssignmentVersion, ownership.Assignment)
+
+	// the cluster that is not provisioned has no controllers and thus no owner
+	require.Len(t, ownership.Clusters, 2)
+	assert.Equal(t, "c-m-abc", ownership.Clusters[0].Name)
+	assert.Equal(t, "abc", ownership.Clusters[0].DisplayName)
+	assert.Equal(t, "local", ownership.Clusters[1].Name)
+
+	// owners are the replicas that report the clusters, not the assignment of the replica serving the request
+	assert.Equal(t, "rancher-b", ownership.Clusters[0].Owner)
+	assignment := h.owners.Assign([]string{"uid-abc", "uid-local"})
+	assert.Equal(t, assignment["uid-abc"], ownership.Clusters[0].Assigned)
+	assert.Equal(t, assignment["uid-local"], ownership.Clusters[1].Assigned)
+	wantLocalOwner := "rancher-a"
+	if assignment["uid-local"] == "rancher-b" {
+		wantLocalOwner = "rancher-b"
+	}
+	assert.Equal(t, wantLocalOwner, ownership.Clusters[1].Owner)
+
+	counts := map[string]int{}
+	for _, cluster := range ownership.Clusters {
+		counts[cluster.Owner]++
+	}
+	require.Len(t, ownership.Peers, 2)
+	for i, peer := range ownership.Peers {
+		assert.Equal(t, []string{"rancher-a", "rancher-b"}[i], peer.ID)
+		assert.False(t, peer.Drained)
+		assert.Equal(t, peermanager.AssignmentVersion, peer.Version)
+		assert.Equal(t, counts[peer.ID], peer.Clusters)
+	}
+}
+
+func TestServeHTTPNotClustered(t *testing.T) {
+	h := newTestHandler(t, "get", "update")
+	h.clustered = false
+	h.owners = peermanager.NewOwners()
+
+	rec := serve(h, http.MethodGet, Endpoint)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var ownership Ownership
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ownership))
+	assert.False(t, ownership.Clustered)
+	assert.Empty(t, ownership.Peers)
+	require.Len(t, ownership.Clusters, 2)
+	assert.Empty(t, ownership.Clusters[0].Owner)
+
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPost, Endpoint+"?action=rebalance").Code)
+}
+
+func TestServeHTTPActions(t *testing.T) {
+	resetSettings(t)
+	h := newTestHandler(t, "get", "update")
+
+	tests := []struct {
+		name          string
+		query         string
+		wantCode      int
+		wantDrained   string
+		wantRequested string
+	}{
+		{name: "missing action", query: "", wantCode: http.StatusBadRequest},
+		{name: "unknown action", query: "?action=shuffle", wantCode: http.StatusBadRequest},
+		{name: "drain without peer", query: "?action=drain", wantCode: http.StatusBadRequest},
+		{name: "drain unknown peer", query: "?action=drain&peer=rancher-x", wantCode: http.StatusBadRequest},
+		{name: "drain by IP", query: "?action=drain&peer=10.0.0.2", wantCode: http.StatusBadRequest},
+		{name: "drain", query: "?action=drain&peer=rancher-b", wantCode: http.StatusAccepted, wantDrained: "rancher-b"},
+		{name: "drain twice", query: "?action=drain&peer=rancher-b", wantCode: http.StatusAccepted, wantDrained: "rancher-b"},
+		{name: "drain another", query: "?action=drain&peer=rancher-a", wantCode: http.StatusAccepted, wantDrained: "rancher-a,rancher-b"},
+		{name: "undrain", query: "?action=undrain&peer=rancher-b", wantCode: http.StatusAccepted, wantDrained: "rancher-a"},
+		{name: "rebalance", query: "?action=rebalance", wantCode: http.StatusAccepted, wantDrained: "rancher-a", wantRequested: "2024-05-01T12:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, http.MethodPost, Endpoint+tt.query)
+			assert.Equal(t, tt.wantCode, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantDrained, settings.ClusterOwnerDrainedPeers.Get())
+			assert.Equal(t, tt.wantRequested, settings.ClusterOwnerRebalanceRequested.Get())
+		})
+	}
+}
@@ -48,6 +48,8 @@ func Register(ctx context.Context, server *steve.Server, wrangler *wrangler.Cont
 		auth:    requests.NewAuthenticator(ctx, clusterrouter.GetClusterID, sc),
 	}
 
+	go purgeShellRecordings(ctx)
+
 	server.ClusterCache.OnAdd(ctx, shell.impersonator.PurgeOldRoles)
 	server.ClusterCache.OnChange(ctx, func(gvk schema.GroupVersionKind, key string, obj, oldObj runtime.Object) error {
 		return shell.impersonator.PurgeOldRoles(gvk, key, obj)
@@ -64,6 +66,7 @@ func Register(ctx context.Context, server *steve.Server, wrangler *wrangler.Cont
 			}
 			schema.LinkHandlers["shell"] = shell
 			schema.LinkHandlers["log"] = log
+			schema.LinkHandlers["shellRecordings"] = &shellRecordings{}
 			if schema.ActionHandlers == nil {
 				schema.ActionHandlers = map[string]http.Handler{}
 			}
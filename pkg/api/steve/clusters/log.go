@@ -1,15 +1,18 @@
 package clusters
 
 import (
-	"bufio"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/clusterprovisioninglogger"
 	"github.com/rancher/steve/pkg/stores/proxy"
 	"github.com/rancher/wrangler/v2/pkg/schemas/validation"
 	"github.com/sirupsen/logrus"
@@ -47,7 +50,16 @@ func (l *log) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// printLog streams the entries of the provisioning log of the cluster as they are recorded. The query parameters
+// cursor, machine and level replay the entries after a cursor and select the entries of a machine or of a minimum
+// level. Entries are sent as base64 encoded text lines, or as JSON with format=json.
 func (l *log) printLog(resp http.ResponseWriter, req *http.Request) error {
+	filter, jsonFormat, err := parseLogQuery(req.URL.Query())
+	if err != nil {
+		onError(resp, req, http.StatusBadRequest, err)
+		return nil
+	}
+
 	conn, err := upgrader.Upgrade(resp, req, nil)
 	if err != nil {
 		return err
@@ -62,16 +74,12 @@ func (l *log) printLog(resp http.ResponseWriter, req *http.Request) error {
 
 	w, err := client.CoreV1().ConfigMaps(apiRequest.Name).Watch(req.Context(), metav1.ListOptions{
 		TimeoutSeconds: &timeout,
-		FieldSelector:  "metadata.name=provisioning-log",
+		FieldSelector:  "metadata.name=" + clusterprovisioninglogger.ConfigMapName,
 	})
 	if err != nil {
 		return err
 	}
-
-	var (
-		lastLine  = ""
-		printLine = true
-	)
+	defer w.Stop()
 
 	for event := range w.ResultChan() {
 		switch event.Type {
@@ -87,44 +95,64 @@ func (l *log) printLog(resp http.ResponseWriter, req *http.Request) error {
 			continue
 		}
 
-		scanner := bufio.NewScanner(strings.NewReader(cm.Data["log"]))
-		printed := false
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line == "" {
+		entries := clusterprovisioninglogger.Entries(cm)
+		if len(entries) == 0 || entries[len(entries)-1].Cursor < filter.After {
+			// the log was recreated, its cursors start over
+			filter.After = 0
+		}
+		for _, entry := range entries {
+			if !filter.Matches(entry) {
 				continue
 			}
-
-			if !printLine && lastLine == line {
-				printLine = true
-				continue
-			} else if printLine {
-				if err := printMessage(line, conn); err != nil {
-					return err
-				}
-				printed = true
-				lastLine = line
+			if err := printEntry(entry, jsonFormat, conn); err != nil {
+				return err
 			}
 		}
-		if !printed {
-			scanner := bufio.NewScanner(strings.NewReader(cm.Data["log"]))
-			for scanner.Scan() {
-				line := strings.TrimSpace(scanner.Text())
-				if line == "" {
-					continue
-				}
-				if err := printMessage(line, conn); err != nil {
-					return err
-				}
-				lastLine = line
-			}
+		if len(entries) > 0 {
+			filter.After = entries[len(entries)-1].Cursor
 		}
-		printLine = false
 	}
 
 	return nil
 }
 
+func parseLogQuery(query url.Values) (clusterprovisioninglogger.Filter, bool, error) {
+	var filter clusterprovisioninglogger.Filter
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || after < 0 {
+			return filter, false, fmt.Errorf("invalid cursor %q", cursor)
+		}
+		filter.After = after
+	}
+	filter.Machine = query.Get("machine")
+	if level := query.Get("level"); level != "" {
+		var err error
+		if filter.Level, err = clusterprovisioninglogger.ParseLevel(level); err != nil {
+			return filter, false, err
+		}
+	}
+	switch format := query.Get("format"); format {
+	case "", "text":
+		return filter, false, nil
+	case "json":
+		return filter, true, nil
+	default:
+		return filter, false, fmt.Errorf("invalid format %q, must be text or json", format)
+	}
+}
+
+func printEntry(entry clusterprovisioninglogger.Entry, jsonFormat bool, conn *websocket.Conn) error {
+	if !jsonFormat {
+		return printMessage(entry.String(), conn)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
 func printMessage(msg string, conn *websocket.Conn) error {
 	writer, err := conn.NextWriter(websocket.TextMessage)
 	if err != nil {
@@ -2,15 +2,21 @@
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"strings"
 	"time"
 
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/auth/audit"
 	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/shellrecording"
 	"github.com/rancher/steve/pkg/podimpersonation"
 	"github.com/rancher/steve/pkg/stores/proxy"
 	"github.com/rancher/wrangler/v2/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -53,10 +59,45 @@ func (s *shell) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		defer cancel()
 		_ = client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
 	}()
-	s.proxyRequest(rw, req, pod, client)
+
+	recorder, err := s.startRecording(req, user, pod)
+	if err != nil {
+		logrus.Errorf("Failed to start recording of shell session of user %s: %v", user.GetName(), err)
+		http.Error(rw, "shell sessions are recorded, but the recording of this session could not be started", http.StatusInternalServerError)
+		return
+	}
+	if recorder != nil {
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				logrus.Errorf("Failed to record shell session %s: %v", recorder.Session().ID, err)
+			}
+		}()
+	}
+	s.proxyRequest(rw, req, pod, client, recorder)
+}
+
+// startRecording starts the recording of the shell session if shell sessions are recorded. Sessions that cannot be
+// recorded must not be opened.
+func (s *shell) startRecording(req *http.Request, user user.Info, pod *v1.Pod) (*shellrecording.Recorder, error) {
+	dir := settings.ShellRecordingDir.Get()
+	if dir == "" {
+		return nil, nil
+	}
+	session := shellrecording.Session{
+		User:       user.GetName(),
+		RemoteAddr: req.RemoteAddr,
+		Pod:        pod.Name,
+	}
+	if apiRequest := types.GetAPIContext(req.Context()); apiRequest != nil {
+		session.Cluster = apiRequest.Name
+	}
+	if auditID, ok := audit.IDFromContext(req.Context()); ok {
+		session.AuditID = auditID
+	}
+	return shellrecording.NewStore(dir).Start(session, 0, 0)
 }
 
-func (s *shell) proxyRequest(rw http.ResponseWriter, req *http.Request, pod *v1.Pod, client kubernetes.Interface) {
+func (s *shell) proxyRequest(rw http.ResponseWriter, req *http.Request, pod *v1.Pod, client kubernetes.Interface, recorder *shellrecording.Recorder) {
 	attachURL := client.CoreV1().RESTClient().
 		Get().
 		Namespace(pod.Namespace).
@@ -86,14 +127,46 @@ func (s *shell) proxyRequest(rw http.ResponseWriter, req *http.Request, pod *v1.
 			delete(req.Header, "Impersonate-User")
 			delete(req.Header, "Authorization")
 			delete(req.Header, "Cookie")
+			if recorder != nil {
+				// compressed messages cannot be recorded
+				req.Header.Del("Sec-WebSocket-Extensions")
+			}
 		},
 		Transport:     httpClient.Transport,
 		FlushInterval: time.Millisecond * 100,
 	}
+	if recorder != nil {
+		p.Transport = &recordingTransport{next: httpClient.Transport, recorder: recorder}
+	}
 
 	p.ServeHTTP(rw, req)
 }
 
+// recordingTransport records the exec session of the upgraded connections of the responses.
+type recordingTransport struct {
+	next     http.RoundTripper
+	recorder *shellrecording.Recorder
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		return resp, err
+	}
+	// the reverse proxy requires the body of an upgraded connection to be writable
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, errors.New("shell session cannot be recorded: upgraded connection is not writable")
+	}
+	resp.Body = t.recorder.Tap(conn, resp.Header.Get("Sec-WebSocket-Protocol"))
+	return resp, nil
+}
+
 func (s *shell) contextAndClient(req *http.Request) (context.Context, user.Info, kubernetes.Interface, error) {
 	ctx := req.Context()
 	client, err := s.cg.AdminK8sInterface()
@@ -0,0 +1,98 @@
+package clusters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/shellrecording"
+	"github.com/sirupsen/logrus"
+)
+
+// shellRecordingsResource is the resource that users must be allowed to get to list and replay the recorded shell
+// sessions of clusters.
+const shellRecordingsResource = "management.cattle.io/shellrecordings"
+
+type shellRecordings struct{}
+
+// ServeHTTP lists the recorded shell sessions of the cluster, most recent first, or returns the asciicast recording of
+// the session selected by the id query parameter.
+func (s *shellRecordings) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiRequest := types.GetAPIContext(req.Context())
+	if err := apiRequest.AccessControl.CanDo(apiRequest, shellRecordingsResource, "get", "", ""); err != nil {
+		apiRequest.WriteError(err)
+		return
+	}
+
+	dir := settings.ShellRecordingDir.Get()
+	if dir == "" {
+		http.Error(rw, "shell sessions are not recorded", http.StatusNotFound)
+		return
+	}
+	store := shellrecording.NewStore(dir)
+
+	id := req.URL.Query().Get("id")
+	if id == "" {
+		sessions, err := store.List(apiRequest.Name)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if sessions == nil {
+			sessions = []shellrecording.Session{}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{"data": sessions})
+		return
+	}
+
+	session, err := store.Get(id)
+	if err == nil && session.Cluster != apiRequest.Name {
+		err = shellrecording.ErrNotFound
+	}
+	var recording io.ReadCloser
+	if err == nil {
+		recording, err = store.Open(id)
+	}
+	if errors.Is(err, shellrecording.ErrNotFound) {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer recording.Close()
+
+	rw.Header().Set("Content-Type", "application/x-asciicast")
+	rw.Header().Set("Content-Disposition", `attachment; filename="`+id+`.cast"`)
+	_, _ = io.Copy(rw, recording)
+}
+
+// purgeShellRecordings deletes the recordings of shell sessions that are older than the retention period every hour.
+func purgeShellRecordings(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		dir := settings.ShellRecordingDir.Get()
+		days := settings.ShellRecordingRetentionDays.GetInt()
+		if dir == "" || days <= 0 {
+			continue
+		}
+		purged, err := shellrecording.NewStore(dir).Purge(time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			logrus.Errorf("Failed to purge shell recordings: %v", err)
+		} else if purged > 0 {
+			logrus.Infof("Purged %d shell recordings older than %d days", purged, days)
+		}
+	}
+}
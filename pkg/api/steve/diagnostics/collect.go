@@ -0,0 +1,450 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/rancher/rancher/pkg/api/steve/proxy"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	rancherPodSelector = "app=rancher"
+	// maxEvents is the number of most recent events collected per file.
+	maxEvents = 500
+)
+
+// connectivityConditions are the conditions of management clusters that show whether Rancher can reach the cluster
+// agent.
+var connectivityConditions = []string{"Ready", "Connected", "AgentDeployed", "Provisioned", "Updated"}
+
+func (h *Handler) collectMetadata(_ context.Context, b *bundle, opts options) error {
+	metadata := map[string]interface{}{
+		"rancherVersion": version.Version,
+		"gitCommit":      version.GitCommit,
+		"generated":      b.now,
+		"logLines":       opts.logLines,
+	}
+	if opts.clusterName != "" {
+		metadata["cluster"] = opts.clusterNamespace + "/" + opts.clusterName
+	}
+	return b.addJSON("metadata.json", metadata)
+}
+
+type settingInfo struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	Default    string `json:"default"`
+	Source     string `json:"source,omitempty"`
+	Customized bool   `json:"customized,omitempty"`
+}
+
+func (h *Handler) collectSettings(_ context.Context, b *bundle, _ options) error {
+	settings, err := h.settings.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	result := make([]settingInfo, 0, len(settings.Items))
+	for _, s := range settings.Items {
+		info := settingInfo{Name: s.Name, Value: s.Value, Default: s.Default, Source: s.Source, Customized: s.Customized}
+		if isSensitiveKey(s.Name) {
+			info.Value = redactValue(info.Value).(string)
+			info.Default = redactValue(info.Default).(string)
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return b.addJSON("rancher/settings.json", result)
+}
+
+type featureInfo struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Value       *bool  `json:"value,omitempty"`
+	Default     bool   `json:"default"`
+	LockedValue *bool  `json:"lockedValue,omitempty"`
+	Dynamic     bool   `json:"dynamic"`
+}
+
+func (h *Handler) collectFeatures(_ context.Context, b *bundle, _ options) error {
+	features, err := h.features.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	result := make([]featureInfo, 0, len(features.Items))
+	for _, f := range features.Items {
+		info := featureInfo{
+			Name:        f.Name,
+			Enabled:     f.Status.Default,
+			Value:       f.Spec.Value,
+			Default:     f.Status.Default,
+			LockedValue: f.Status.LockedValue,
+			Dynamic:     f.Status.Dynamic,
+		}
+		switch {
+		case f.Status.LockedValue != nil:
+			info.Enabled = *f.Status.LockedValue
+		case f.Spec.Value != nil:
+			info.Enabled = *f.Spec.Value
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return b.addJSON("rancher/features.json", result)
+}
+
+type crdCount struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Count   int64  `json:"count"`
+	// Incomplete is set if the API server did not return the number of remaining objects, Count is then a minimum.
+	Incomplete bool   `json:"incomplete,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// collectCRDCounts counts the objects of every custom resource, listing a single object per resource and relying on the
+// number of remaining objects returned by the API server.
+func (h *Handler) collectCRDCounts(ctx context.Context, b *bundle, _ options) error {
+	crds, err := h.crds.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	result := make([]crdCount, 0, len(crds.Items))
+	for _, crd := range crds.Items {
+		count := crdCount{Name: crd.Name}
+		for _, v := range crd.Spec.Versions {
+			if v.Storage {
+				count.Version = v.Name
+			}
+		}
+		gvr := schema.GroupVersionResource{Group: crd.Spec.Group, Version: count.Version, Resource: crd.Spec.Names.Plural}
+		list, err := h.metadata.Resource(gvr).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			count.Error = err.Error()
+		} else {
+			count.Count = int64(len(list.Items))
+			if list.RemainingItemCount != nil {
+				count.Count += *list.RemainingItemCount
+			} else if list.Continue != "" {
+				count.Incomplete = true
+			}
+		}
+		result = append(result, count)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return b.addJSON("rancher/crd-counts.json", result)
+}
+
+type clusterCondition struct {
+	Status         string `json:"status"`
+	Reason         string `json:"reason,omitempty"`
+	Message        string `json:"message,omitempty"`
+	LastUpdateTime string `json:"lastUpdateTime,omitempty"`
+}
+
+type clusterConnectivity struct {
+	Name              string                      `json:"name"`
+	DisplayName       string                      `json:"displayName,omitempty"`
+	Driver            string                      `json:"driver,omitempty"`
+	Internal          bool                        `json:"internal,omitempty"`
+	KubernetesVersion string                      `json:"kubernetesVersion,omitempty"`
+	AgentImage        string                      `json:"agentImage,omitempty"`
+	Conditions        map[string]clusterCondition `json:"conditions,omitempty"`
+	// TunnelSession is whether the cluster agent is connected to the tunnel server of Rancher.
+	TunnelSession *bool `json:"tunnelSession,omitempty"`
+}
+
+func (h *Handler) collectClusterConnectivity(_ context.Context, b *bundle, _ options) error {
+	clusters, err := h.clusters.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	result := make([]clusterConnectivity, 0, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		info := clusterConnectivity{
+			Name:        cluster.Name,
+			DisplayName: cluster.Spec.DisplayName,
+			Driver:      cluster.Status.Driver,
+			Internal:    cluster.Spec.Internal,
+			AgentImage:  cluster.Status.AgentImage,
+			Conditions:  map[string]clusterCondition{},
+		}
+		if cluster.Status.Version != nil {
+			info.KubernetesVersion = cluster.Status.Version.GitVersion
+		}
+		for _, c := range cluster.Status.Conditions {
+			for _, name := range connectivityConditions {
+				if string(c.Type) == name {
+					info.Conditions[name] = clusterCondition{
+						Status:         string(c.Status),
+						Reason:         c.Reason,
+						Message:        c.Message,
+						LastUpdateTime: c.LastUpdateTime,
+					}
+				}
+			}
+		}
+		if h.tunnel != nil && !cluster.Spec.Internal {
+			hasSession := h.tunnel.HasSession(proxy.Prefix + cluster.Name)
+			info.TunnelSession = &hasSession
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return b.addJSON("clusters/connectivity.json", result)
+}
+
+type containerInfo struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	State        string `json:"state,omitempty"`
+	LastState    string `json:"lastState,omitempty"`
+}
+
+type podInfo struct {
+	Name       string          `json:"name"`
+	Node       string          `json:"node,omitempty"`
+	Phase      string          `json:"phase"`
+	Started    *metav1.Time    `json:"started,omitempty"`
+	Containers []containerInfo `json:"containers"`
+}
+
+// collectRancherPods collects the status and the logs of the Rancher pods. The logs of the previous instance of
+// containers that restarted are collected as well, as they usually hold the reason of the restart.
+func (h *Handler) collectRancherPods(ctx context.Context, b *bundle, opts options) error {
+	pods, err := h.k8s.CoreV1().Pods(namespace.System).List(ctx, metav1.ListOptions{LabelSelector: rancherPodSelector})
+	if err != nil {
+		return err
+	}
+	result := make([]podInfo, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		info := podInfo{Name: pod.Name, Node: pod.Spec.NodeName, Phase: string(pod.Status.Phase), Started: pod.Status.StartTime}
+		for _, status := range pod.Status.ContainerStatuses {
+			info.Containers = append(info.Containers, containerInfo{
+				Name:         status.Name,
+				Image:        status.Image,
+				Ready:        status.Ready,
+				RestartCount: status.RestartCount,
+				State:        describeContainerState(status.State),
+				LastState:    describeContainerState(status.LastTerminationState),
+			})
+		}
+		result = append(result, info)
+	}
+	if err := b.addJSON("rancher/pods.json", result); err != nil {
+		return err
+	}
+	if opts.logLines == 0 {
+		return nil
+	}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if err := h.collectLog(ctx, b, pod, status.Name, false, opts.logLines); err != nil {
+				return err
+			}
+			if status.RestartCount > 0 {
+				if err := h.collectLog(ctx, b, pod, status.Name, true, opts.logLines); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// collectLog adds the log of the container, recording the failure to get the log as a collection error.
+func (h *Handler) collectLog(ctx context.Context, b *bundle, pod corev1.Pod, container string, previous bool, lines int64) error {
+	name := path.Join("rancher/logs", pod.Name, container)
+	if previous {
+		name += ".previous"
+	}
+	name += ".log"
+	data, err := h.k8s.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &lines,
+	}).DoRaw(ctx)
+	if err != nil {
+		b.addError(name, err)
+		return nil
+	}
+	return b.addText(name, data)
+}
+
+func describeContainerState(state corev1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "running since " + state.Running.StartedAt.UTC().Format(time.RFC3339)
+	case state.Waiting != nil:
+		return fmt.Sprintf("waiting: %s %s", state.Waiting.Reason, state.Waiting.Message)
+	case state.Terminated != nil:
+		return fmt.Sprintf("terminated at %s with exit code %d: %s %s", state.Terminated.FinishedAt.UTC().Format(time.RFC3339),
+			state.Terminated.ExitCode, state.Terminated.Reason, state.Terminated.Message)
+	}
+	return ""
+}
+
+type eventInfo struct {
+	Namespace     string    `json:"namespace"`
+	Object        string    `json:"object"`
+	Type          string    `json:"type"`
+	Reason        string    `json:"reason"`
+	Message       string    `json:"message"`
+	Count         int32     `json:"count,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	LastTimestamp time.Time `json:"lastTimestamp"`
+}
+
+// collectEvents collects the recent events of the Rancher namespace and of the namespace of the selected cluster, and
+// the recent warnings of all namespaces.
+func (h *Handler) collectEvents(ctx context.Context, b *bundle, opts options) error {
+	namespaces := []string{namespace.System}
+	if opts.clusterNamespace != "" && opts.clusterNamespace != namespace.System {
+		namespaces = append(namespaces, opts.clusterNamespace)
+	}
+	for _, ns := range namespaces {
+		if err := h.collectEventsOf(ctx, b, ns, "", "events/"+ns+".json"); err != nil {
+			return err
+		}
+	}
+	return h.collectEventsOf(ctx, b, metav1.NamespaceAll, corev1.EventTypeWarning, "events/warnings.json")
+}
+
+// collectEventsOf adds the most recent events of the namespace, of the type if set.
+func (h *Handler) collectEventsOf(ctx context.Context, b *bundle, ns, eventType, name string) error {
+	var opts metav1.ListOptions
+	if eventType != "" {
+		opts.FieldSelector = "type=" + eventType
+	}
+	events, err := h.k8s.CoreV1().Events(ns).List(ctx, opts)
+	if err != nil {
+		b.addError(name, err)
+		return nil
+	}
+	result := make([]eventInfo, 0, len(events.Items))
+	for _, e := range events.Items {
+		if eventType != "" && e.Type != eventType {
+			continue
+		}
+		last := e.LastTimestamp.Time
+		if last.IsZero() {
+			last = e.EventTime.Time
+		}
+		if last.IsZero() {
+			last = e.CreationTimestamp.Time
+		}
+		result = append(result, eventInfo{
+			Namespace:     e.Namespace,
+			Object:        e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name,
+			Type:          e.Type,
+			Reason:        e.Reason,
+			Message:       e.Message,
+			Count:         e.Count,
+			Source:        e.Source.Component,
+			LastTimestamp: last.UTC(),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].LastTimestamp.After(result[j].LastTimestamp) })
+	if len(result) > maxEvents {
+		result = result[:maxEvents]
+	}
+	return b.addJSON(name, result)
+}
+
+type machinePlan struct {
+	Machine string     `json:"machine"`
+	Secret  string     `json:"secretName"`
+	Node    *plan.Node `json:"node,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// collectCluster collects the provisioning cluster, its control plane, its machines and the plans of the machines.
+// The contents of the files of the plans are removed, as they hold the configuration and credentials of the nodes.
+func (h *Handler) collectCluster(ctx context.Context, b *bundle, opts options) error {
+	dir := path.Join("cluster", opts.clusterNamespace, opts.clusterName)
+	cluster, err := h.provClusters.Get(opts.clusterNamespace, opts.clusterName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if err := b.addJSON(path.Join(dir, "cluster.json"), cluster); err != nil {
+		return err
+	}
+
+	controlPlane, err := h.controlPlanes.Get(opts.clusterNamespace, opts.clusterName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		// clusters that are not provisioned by Rancher do not have a control plane
+	case err != nil:
+		b.addError(path.Join(dir, "controlplane.json"), err)
+	default:
+		if err := b.addJSON(path.Join(dir, "controlplane.json"), controlPlane); err != nil {
+			return err
+		}
+	}
+
+	machines, err := h.machines.List(opts.clusterNamespace, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{capi.ClusterNameLabel: opts.clusterName}).String(),
+	})
+	if err != nil {
+		b.addError(path.Join(dir, "machines.json"), err)
+		return nil
+	}
+	if err := b.addJSON(path.Join(dir, "machines.json"), machines.Items); err != nil {
+		return err
+	}
+
+	for _, machine := range machines.Items {
+		ref := machine.Spec.Bootstrap.ConfigRef
+		if ref == nil || ref.Kind != "RKEBootstrap" || ref.Name == "" {
+			continue
+		}
+		result := machinePlan{Machine: machine.Name, Secret: capr.PlanSecretFromBootstrapName(ref.Name)}
+		secret, err := h.k8s.CoreV1().Secrets(machine.Namespace).Get(ctx, result.Secret, metav1.GetOptions{})
+		if err == nil {
+			result.Node, err = planner.SecretToNode(secret)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if result.Node != nil {
+			redactNodePlan(&result.Node.Plan)
+			if result.Node.AppliedPlan != nil {
+				redactNodePlan(result.Node.AppliedPlan)
+			}
+		}
+		if err := b.addJSON(path.Join(dir, "plans", machine.Name+".json"), result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func redactNodePlan(p *plan.NodePlan) {
+	for i := range p.Files {
+		if p.Files[i].Content != "" {
+			p.Files[i].Content = redacted
+		}
+	}
+	for i := range p.Instructions {
+		p.Instructions[i].Env = redactEnv(p.Instructions[i].Env)
+	}
+	for i := range p.PeriodicInstructions {
+		p.PeriodicInstructions[i].Env = redactEnv(p.PeriodicInstructions[i].Env)
+	}
+}
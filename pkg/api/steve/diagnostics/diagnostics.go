@@ -0,0 +1,288 @@
+// Package diagnostics provides a HTTPHandler to serve diagnostic bundles, a gzipped tar of the state of Rancher and
+// optionally of a provisioned cluster, redacted of secrets. Unlike supportconfigs it does not depend on a CSP adapter
+// being installed. This handler should be registered at Endpoint.
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/pkg/auth/util"
+	capicontrollers "github.com/rancher/rancher/pkg/generated/controllers/cluster.x-k8s.io/v1beta1"
+	managementv3 "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	provisioningv1 "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/types/config"
+	crdv1 "github.com/rancher/wrangler/v2/pkg/generated/controllers/apiextensions.k8s.io/v1"
+	"github.com/sirupsen/logrus"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+)
+
+const (
+	// Endpoint The endpoint that this URL is accessible at - used for routing
+	Endpoint = "/v1/generateDiagnosticBundle"
+	// authorizationResource is the virtual resource of the management.cattle.io group that users must be allowed to get
+	// to generate diagnostic bundles, which is granted to administrators through their access to all resources.
+	authorizationResource = "diagnostics"
+
+	defaultClusterNamespace = "fleet-default"
+	defaultLogLines         = 5000
+	maxLogLines             = 50000
+	logPrefix               = "diagnostic-bundle-generator"
+)
+
+type tunnelSessions interface {
+	HasSession(clientKey string) bool
+}
+
+// Handler implements http.Handler - and serves diagnostic bundles
+type Handler struct {
+	k8s           kubernetes.Interface
+	metadata      metadata.Interface
+	crds          crdv1.CustomResourceDefinitionClient
+	settings      managementv3.SettingClient
+	features      managementv3.FeatureClient
+	clusters      managementv3.ClusterClient
+	tunnel        tunnelSessions
+	provClusters  provisioningv1.ClusterClient
+	controlPlanes rkecontrollers.RKEControlPlaneClient
+	machines      capicontrollers.MachineClient
+}
+
+// NewHandler creates a handler using the clients defined in scaledContext
+func NewHandler(scaledContext *config.ScaledContext) (*Handler, error) {
+	metadataClient, err := metadata.NewForConfig(&scaledContext.RESTConfig)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		k8s:           scaledContext.K8sClient,
+		metadata:      metadataClient,
+		crds:          scaledContext.Wrangler.CRD.CustomResourceDefinition(),
+		settings:      scaledContext.Wrangler.Mgmt.Setting(),
+		features:      scaledContext.Wrangler.Mgmt.Feature(),
+		clusters:      scaledContext.Wrangler.Mgmt.Cluster(),
+		provClusters:  scaledContext.Wrangler.Provisioning.Cluster(),
+		controlPlanes: scaledContext.Wrangler.RKE.RKEControlPlane(),
+		machines:      scaledContext.Wrangler.CAPI.Machine(),
+	}
+	if scaledContext.Wrangler.TunnelServer != nil {
+		h.tunnel = scaledContext.Wrangler.TunnelServer
+	}
+	return h, nil
+}
+
+// options are the query parameters of a request for a diagnostic bundle.
+type options struct {
+	// clusterNamespace and clusterName select the provisioning cluster whose plans and status are collected, if set.
+	clusterNamespace string
+	clusterName      string
+	// logLines is the number of lines of the logs of every Rancher container that are collected.
+	logLines int64
+}
+
+func parseOptions(r *http.Request) (options, error) {
+	query := r.URL.Query()
+	opts := options{logLines: defaultLogLines}
+	if cluster := query.Get("cluster"); cluster != "" {
+		opts.clusterNamespace, opts.clusterName = defaultClusterNamespace, cluster
+		if namespace, name, ok := strings.Cut(cluster, "/"); ok {
+			opts.clusterNamespace, opts.clusterName = namespace, name
+		}
+		if opts.clusterNamespace == "" || opts.clusterName == "" {
+			return opts, fmt.Errorf("invalid cluster %q, must be <name> or <namespace>/<name>", cluster)
+		}
+	}
+	if lines := query.Get("logLines"); lines != "" {
+		n, err := strconv.ParseInt(lines, 10, 64)
+		if err != nil || n < 0 || n > maxLogLines {
+			return opts, fmt.Errorf("invalid logLines %q, must be between 0 and %d", lines, maxLogLines)
+		}
+		opts.logLines = n
+	}
+	return opts, nil
+}
+
+// ServeHTTP implements http.Handler - attempts to authorize the user and streams a diagnostic bundle. The query
+// parameter cluster selects the provisioning cluster, as <name> in fleet-default or <namespace>/<name>, whose plans and
+// status are included, and logLines the number of lines of the logs of every Rancher container.
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	authorized, err := h.authorize(req)
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		logrus.Errorf("[%s] Failed to authorize user with error: %s", logPrefix, err.Error())
+		return
+	}
+	if !authorized {
+		util.ReturnHTTPError(writer, req, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return
+	}
+	opts, err := parseOptions(req)
+	if err != nil {
+		util.ReturnHTTPError(writer, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	writer.Header().Set("Content-Type", "application/gzip")
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"rancher-diagnostics-%s.tar.gz\"", now.Format("20060102T150405Z")))
+
+	logrus.Infof("[%s] Generating diagnostic bundle", logPrefix)
+	if err := h.generate(req.Context(), writer, opts, now); err != nil {
+		// the response is already being streamed, the client sees a truncated archive
+		logrus.Errorf("[%s] Error when writing diagnostic bundle: %v", logPrefix, err)
+		return
+	}
+	logrus.Infof("[%s] Done generating diagnostic bundle", logPrefix)
+}
+
+// authorize checks to see if the user can get the diagnostics resource. Returns a bool (if the user is authorized)
+// and optionally an error
+func (h *Handler) authorize(r *http.Request) (bool, error) {
+	userInfo, ok := request.UserFrom(r.Context())
+	if !ok {
+		return false, fmt.Errorf("unable to extract user info from context")
+	}
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range userInfo.GetExtra() {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	response, err := h.k8s.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Group:    "management.cattle.io",
+				Resource: authorizationResource,
+				Verb:     "get",
+			},
+			User:   userInfo.GetName(),
+			Groups: userInfo.GetGroups(),
+			Extra:  extra,
+			UID:    userInfo.GetUID(),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create a SubjectAccessReview: %w", err)
+	}
+	return response.Status.Allowed, nil
+}
+
+// generate writes the diagnostic bundle to w. Failures to collect parts of the bundle are recorded in errors.json of the
+// bundle, only failures to write the bundle are returned.
+func (h *Handler) generate(ctx context.Context, w io.Writer, opts options, now time.Time) error {
+	gz := gzip.NewWriter(w)
+	b := &bundle{tw: tar.NewWriter(gz), now: now}
+
+	collectors := []collector{
+		{"metadata", h.collectMetadata},
+		{"settings", h.collectSettings},
+		{"features", h.collectFeatures},
+		{"crds", h.collectCRDCounts},
+		{"clusters", h.collectClusterConnectivity},
+		{"pods", h.collectRancherPods},
+		{"events", h.collectEvents},
+	}
+	if opts.clusterName != "" {
+		collectors = append(collectors, collector{"cluster", h.collectCluster})
+	}
+	for _, c := range collectors {
+		if err := c.collect(ctx, b, opts); err != nil {
+			if b.writeErr != nil {
+				return b.writeErr
+			}
+			b.addError(c.name, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if len(b.errors) > 0 {
+		b.addJSON("errors.json", b.errors)
+	}
+	if b.writeErr != nil {
+		return b.writeErr
+	}
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// collector adds a part of the diagnostic bundle.
+type collector struct {
+	name    string
+	collect func(context.Context, *bundle, options) error
+}
+
+// bundle writes the files of a diagnostic bundle, redacting their contents.
+type bundle struct {
+	tw  *tar.Writer
+	now time.Time
+	// errors are the failures to collect parts of the bundle
+	errors []collectionError
+	// writeErr is the first failure to write the bundle, after which nothing is written
+	writeErr error
+}
+
+type collectionError struct {
+	Source string `json:"source"`
+	Error  string `json:"error"`
+}
+
+func (b *bundle) addError(source string, err error) {
+	b.errors = append(b.errors, collectionError{Source: source, Error: RedactText(err.Error())})
+}
+
+// addJSON adds the object, redacted with RedactObject, as an indented JSON file.
+func (b *bundle) addJSON(name string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(RedactObject(generic), "", "  ")
+	if err != nil {
+		return err
+	}
+	return b.add(name, data)
+}
+
+// addText adds the text, redacted with RedactText.
+func (b *bundle) addText(name string, text []byte) error {
+	return b.add(name, []byte(RedactText(string(text))))
+}
+
+func (b *bundle) add(name string, data []byte) error {
+	if b.writeErr != nil {
+		return b.writeErr
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		b.writeErr = err
+		return err
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		b.writeErr = err
+		return err
+	}
+	return nil
+}
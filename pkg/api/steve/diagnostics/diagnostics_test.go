@@ -0,0 +1,289 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+type fakeTunnel map[string]bool
+
+func (f fakeTunnel) HasSession(clientKey string) bool {
+	return f[clientKey]
+}
+
+func newTestHandler(t *testing.T, allowed bool) *Handler {
+	ctrl := gomock.NewController(t)
+	trueValue := true
+
+	rancherPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "rancher-abc", Namespace: "cattle-system", Labels: map[string]string{"app": "rancher"}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "rancher", Image: "rancher/rancher:v2.9.0", Ready: true, RestartCount: 1},
+			},
+		},
+	}
+	now := metav1.NewTime(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	events := []runtime.Object{
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e1", Namespace: "cattle-system"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "rancher-abc"},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Started",
+			LastTimestamp:  now,
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e2", Namespace: "fleet-default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Machine", Name: "m1"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "Failed",
+			Message:        "bootstrap failed with token: abc",
+			LastTimestamp:  now,
+		},
+	}
+	planSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: capr.PlanSecretFromBootstrapName("m1-bootstrap"), Namespace: "fleet-default"},
+		Type:       capr.SecretTypeMachinePlan,
+		Data: map[string][]byte{
+			"plan": []byte(`{"files":[{"path":"/etc/rancher/rke2/config.yaml.d/50-rancher.yaml","content":"eyJ0b2tlbiI6ImFiYyJ9"}],` +
+				`"instructions":[{"name":"install","env":["INSTALL_RKE2_VERSION=v1.28.9+rke2r1","CATTLE_TOKEN=abc"]}]}`),
+		},
+	}
+	k8s := k8sfake.NewSimpleClientset(append(events, rancherPod, planSecret)...)
+	k8s.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		assert.Equal(t, "management.cattle.io", sar.Spec.ResourceAttributes.Group)
+		assert.Equal(t, "diagnostics", sar.Spec.ResourceAttributes.Resource)
+		assert.Equal(t, "u-admin", sar.Spec.User)
+		sar.Status.Allowed = allowed
+		return true, sar, nil
+	})
+
+	settings := fake.NewMockNonNamespacedClientInterface[*v3.Setting, *v3.SettingList](ctrl)
+	settings.EXPECT().List(gomock.Any()).Return(&v3.SettingList{Items: []v3.Setting{
+		{ObjectMeta: metav1.ObjectMeta{Name: "server-url"}, Value: "https://rancher.example.com", Source: "db"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "some-token"}, Value: "abc"},
+	}}, nil).AnyTimes()
+	features := fake.NewMockNonNamespacedClientInterface[*v3.Feature, *v3.FeatureList](ctrl)
+	features.EXPECT().List(gomock.Any()).Return(&v3.FeatureList{Items: []v3.Feature{
+		{ObjectMeta: metav1.ObjectMeta{Name: "fleet"}, Status: v3.FeatureStatus{Default: true}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "harvester"}, Spec: v3.FeatureSpec{Value: &trueValue}},
+	}}, nil).AnyTimes()
+	clusters := fake.NewMockNonNamespacedClientInterface[*v3.Cluster, *v3.ClusterList](ctrl)
+	clusters.EXPECT().List(gomock.Any()).Return(&v3.ClusterList{Items: []v3.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "local"}, Spec: v3.ClusterSpec{Internal: true}},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-m-abc"},
+			Status: v3.ClusterStatus{Conditions: []v3.ClusterCondition{
+				{Type: "Connected", Status: corev1.ConditionFalse, Message: "agent disconnected"},
+				{Type: "Pending", Status: corev1.ConditionFalse},
+			}},
+		},
+	}}, nil).AnyTimes()
+	crds := fake.NewMockNonNamespacedClientInterface[*apiextv1.CustomResourceDefinition, *apiextv1.CustomResourceDefinitionList](ctrl)
+	crds.EXPECT().List(gomock.Any()).Return(&apiextv1.CustomResourceDefinitionList{Items: []apiextv1.CustomResourceDefinition{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings.management.cattle.io"},
+			Spec: apiextv1.CustomResourceDefinitionSpec{
+				Group:    "management.cattle.io",
+				Names:    apiextv1.CustomResourceDefinitionNames{Plural: "settings"},
+				Versions: []apiextv1.CustomResourceDefinitionVersion{{Name: "v3", Storage: true}},
+			},
+		},
+	}}, nil).AnyTimes()
+	settingMeta := func(name string) runtime.Object {
+		return &metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "management.cattle.io/v3", Kind: "Setting"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		}
+	}
+	scheme := metadatafake.NewTestScheme()
+	require.NoError(t, metav1.AddMetaToScheme(scheme))
+	metadataClient := metadatafake.NewSimpleMetadataClient(scheme, settingMeta("server-url"), settingMeta("some-token"))
+
+	provClusters := fake.NewMockControllerInterface[*provv1.Cluster, *provv1.ClusterList](ctrl)
+	provClusters.EXPECT().Get("fleet-default", "downstream", gomock.Any()).Return(&provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "downstream", Namespace: "fleet-default"},
+		Spec:       provv1.ClusterSpec{CloudCredentialSecretName: "cattle-global-data:cc-abc"},
+	}, nil).AnyTimes()
+	provClusters.EXPECT().Get("fleet-default", "missing", gomock.Any()).Return(nil,
+		apierrors.NewNotFound(schema.GroupResource{Group: "provisioning.cattle.io", Resource: "clusters"}, "missing")).AnyTimes()
+	controlPlanes := fake.NewMockControllerInterface[*rkev1.RKEControlPlane, *rkev1.RKEControlPlaneList](ctrl)
+	controlPlanes.EXPECT().Get("fleet-default", "downstream", gomock.Any()).Return(&rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "downstream", Namespace: "fleet-default"},
+	}, nil).AnyTimes()
+	machines := fake.NewMockControllerInterface[*capi.Machine, *capi.MachineList](ctrl)
+	machines.EXPECT().List("fleet-default", gomock.Any()).Return(&capi.MachineList{Items: []capi.Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "m1", Namespace: "fleet-default"},
+			Spec: capi.MachineSpec{Bootstrap: capi.Bootstrap{ConfigRef: &corev1.ObjectReference{
+				Kind: "RKEBootstrap",
+				Name: "m1-bootstrap",
+			}}},
+		},
+	}}, nil).AnyTimes()
+
+	return &Handler{
+		k8s:           k8s,
+		metadata:      metadataClient,
+		crds:          crds,
+		settings:      settings,
+		features:      features,
+		clusters:      clusters,
+		tunnel:        fakeTunnel{"stv-cluster-c-m-abc": true},
+		provClusters:  provClusters,
+		controlPlanes: controlPlanes,
+		machines:      machines,
+	}
+}
+
+func serve(t *testing.T, h *Handler, url string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "u-admin"}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// readBundle returns the files of the gzipped tar.
+func readBundle(t *testing.T, data []byte) map[string][]byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = content
+	}
+	return files
+}
+
+func TestServeHTTPForbidden(t *testing.T) {
+	rec := serve(t, newTestHandler(t, false), Endpoint)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestServeHTTPInvalidOptions(t *testing.T) {
+	h := newTestHandler(t, true)
+	for _, query := range []string{"?logLines=-1", "?logLines=abc", "?cluster=fleet-default/"} {
+		rec := serve(t, h, Endpoint+query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	rec := serve(t, newTestHandler(t, true), Endpoint+"?cluster=downstream&logLines=100")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+
+	files := readBundle(t, rec.Body.Bytes())
+	for _, name := range []string{
+		"metadata.json",
+		"rancher/settings.json",
+		"rancher/features.json",
+		"rancher/crd-counts.json",
+		"rancher/pods.json",
+		"rancher/logs/rancher-abc/rancher.log",
+		"rancher/logs/rancher-abc/rancher.previous.log",
+		"clusters/connectivity.json",
+		"events/cattle-system.json",
+		"events/fleet-default.json",
+		"events/warnings.json",
+		"cluster/fleet-default/downstream/cluster.json",
+		"cluster/fleet-default/downstream/controlplane.json",
+		"cluster/fleet-default/downstream/machines.json",
+		"cluster/fleet-default/downstream/plans/m1.json",
+	} {
+		assert.Contains(t, files, name)
+	}
+	assert.NotContains(t, files, "errors.json")
+
+	var settings []settingInfo
+	require.NoError(t, json.Unmarshal(files["rancher/settings.json"], &settings))
+	assert.Equal(t, []settingInfo{
+		{Name: "server-url", Value: "https://rancher.example.com", Source: "db"},
+		{Name: "some-token", Value: redacted},
+	}, settings)
+
+	var features []featureInfo
+	require.NoError(t, json.Unmarshal(files["rancher/features.json"], &features))
+	require.Len(t, features, 2)
+	assert.True(t, features[0].Enabled)
+	assert.True(t, features[1].Enabled)
+
+	var counts []crdCount
+	require.NoError(t, json.Unmarshal(files["rancher/crd-counts.json"], &counts))
+	assert.Equal(t, []crdCount{{Name: "settings.management.cattle.io", Version: "v3", Count: 2}}, counts)
+
+	var connectivity []clusterConnectivity
+	require.NoError(t, json.Unmarshal(files["clusters/connectivity.json"], &connectivity))
+	require.Len(t, connectivity, 2)
+	assert.Equal(t, "c-m-abc", connectivity[0].Name)
+	assert.Equal(t, map[string]clusterCondition{"Connected": {Status: "False", Message: "agent disconnected"}}, connectivity[0].Conditions)
+	require.NotNil(t, connectivity[0].TunnelSession)
+	assert.True(t, *connectivity[0].TunnelSession)
+	assert.Nil(t, connectivity[1].TunnelSession)
+
+	var warnings []eventInfo
+	require.NoError(t, json.Unmarshal(files["events/warnings.json"], &warnings))
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "Machine/m1", warnings[0].Object)
+	assert.Equal(t, "bootstrap failed with token: [REDACTED]", warnings[0].Message)
+
+	assert.Contains(t, string(files["cluster/fleet-default/downstream/cluster.json"]), "cattle-global-data:cc-abc")
+	plan := string(files["cluster/fleet-default/downstream/plans/m1.json"])
+	assert.Contains(t, plan, "INSTALL_RKE2_VERSION=v1.28.9+rke2r1")
+	assert.Contains(t, plan, "CATTLE_TOKEN=[REDACTED]")
+	assert.NotContains(t, plan, "eyJ0b2tlbiI6ImFiYyJ9")
+}
+
+func TestServeHTTPRecordsErrors(t *testing.T) {
+	rec := serve(t, newTestHandler(t, true), Endpoint+"?cluster=missing&logLines=0")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	files := readBundle(t, rec.Body.Bytes())
+	assert.Contains(t, files, "rancher/settings.json")
+	assert.NotContains(t, files, "rancher/logs/rancher-abc/rancher.log")
+
+	var errors []collectionError
+	require.NoError(t, json.Unmarshal(files["errors.json"], &errors))
+	require.Len(t, errors, 1)
+	assert.Equal(t, "cluster", errors[0].Source)
+	assert.Contains(t, errors[0].Error, "not found")
+}
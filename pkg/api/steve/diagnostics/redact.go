@@ -0,0 +1,108 @@
+package diagnostics
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// sensitiveKeyRegexp matches the names of fields and settings whose values are secrets.
+	sensitiveKeyRegexp = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private[_-]?key|api[_-]?key|access[_-]?key|kubeconfig|cookie|authorization)`)
+	// referenceKeyRegexp matches the names of fields that refer to secrets by name, which are kept.
+	referenceKeyRegexp = regexp.MustCompile(`(?i)(name|namespace|ttl|ttlminutes|ttlseconds)$`)
+
+	textRedactions = []struct {
+		re          *regexp.Regexp
+		replacement string
+	}{
+		// PEM encoded private keys
+		{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), redacted},
+		// credentials of HTTP authorization headers
+		{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]{8,}`), "$1 " + redacted},
+		// credentials embedded in URLs
+		{regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`), "${1}" + redacted + "@"},
+		// Rancher API keys and tokens, as <name>:<key>
+		{regexp.MustCompile(`\b[a-z0-9-]+:[a-z0-9]{54,64}\b`), redacted},
+		// key value pairs with sensitive keys, as in key=value, key: value or "key":"value"
+		{regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|access[_-]?key|secret[_-]?key)[a-z_-]*"?\s*[:=]\s*"?)[^\s",}&]+`), "${1}" + redacted},
+	}
+)
+
+// RedactText replaces the secrets found in the text, such as private keys, authorization headers, tokens and the
+// values of password or token key value pairs.
+func RedactText(text string) string {
+	for _, r := range textRedactions {
+		text = r.re.ReplaceAllString(text, r.replacement)
+	}
+	return text
+}
+
+// RedactObject redacts the values of the fields of the decoded JSON object whose name suggests they hold secrets, and
+// the secrets found in the text of all other string values. Managed fields and last applied configurations are
+// dropped as they duplicate the object without redaction.
+func RedactObject(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			switch {
+			case key == "managedFields" || key == "kubectl.kubernetes.io/last-applied-configuration":
+				delete(v, key)
+			case isSensitiveKey(key):
+				v[key] = redactValue(value)
+			default:
+				v[key] = RedactObject(value)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = RedactObject(v[i])
+		}
+		return v
+	case string:
+		return RedactText(v)
+	default:
+		return v
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	return sensitiveKeyRegexp.MatchString(key) && !referenceKeyRegexp.MatchString(key)
+}
+
+// redactValue redacts every non-empty string of the value, so that it remains visible whether a secret was set.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return redacted
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = redactValue(v[key])
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactEnv redacts the values of environment variables, as NAME=value, whose name suggests they hold secrets.
+func redactEnv(env []string) []string {
+	result := make([]string, 0, len(env))
+	for _, e := range env {
+		if name, _, ok := strings.Cut(e, "="); ok && isSensitiveKey(name) {
+			e = name + "=" + redacted
+		}
+		result = append(result, e)
+	}
+	return result
+}
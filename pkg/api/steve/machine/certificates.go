@@ -0,0 +1,293 @@
+package machine
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/wrangler/v2/pkg/name"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	sshCASecretType = "rke.cattle.io/ssh-user-ca"
+	sshCAPrivateKey = "ssh-privatekey"
+	sshCAPublicKey  = "ssh-publickey"
+
+	// trustSSHUserCAAction adds the CA of the cluster of a machine to the user CAs trusted by its sshd.
+	trustSSHUserCAAction = "trustSSHUserCA"
+	// trustedSSHUserCAAnnotation holds the fingerprint of the CA that the sshd of a machine trusts.
+	trustedSSHUserCAAnnotation = "rke.cattle.io/trusted-ssh-user-ca"
+
+	// trustedUserCAKeysPath is the file on machines that holds the CA that sshd trusts to sign user certificates,
+	// unless sshd already has a TrustedUserCAKeys file.
+	trustedUserCAKeysPath = "/etc/ssh/rancher_user_ca.pub"
+	// shellCertificateTTL is the validity of the certificates of the shell sessions opened by Rancher, which are only
+	// used to authenticate the connection.
+	shellCertificateTTL = 5 * time.Minute
+	// clockSkew is how long before their issue certificates are valid, to allow for machines with a clock behind.
+	clockSkew = time.Minute
+)
+
+// errCANotTrusted is returned for machines that don't trust the CA of their cluster yet.
+var errCANotTrusted = errors.New("machine does not trust the SSH user CA of its cluster")
+
+// sshCASecretName returns the name of the secret of the cattle-system namespace that holds the CA that signs the SSH
+// certificates of the machines of the management cluster.
+func sshCASecretName(clusterName string) string {
+	return name.SafeConcatName(clusterName, "ssh", "user", "ca")
+}
+
+// getCA returns the CA of the cluster of the machine, creating it on first use. The CA is kept in the cattle-system
+// namespace, so that users with access to the secrets of the namespace of the machine cannot sign certificates, and is
+// owned by the management cluster so that it is deleted along with the cluster.
+func (s *sshClient) getCA(machine *capi.Machine) (ssh.Signer, error) {
+	cluster, err := s.mgmtCluster(machine)
+	if err != nil {
+		return nil, err
+	}
+	secretName := sshCASecretName(cluster.Name)
+	secret, err := s.secrets.Get(namespace.System, secretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret, err = s.createCA(cluster, secretName)
+		if apierrors.IsAlreadyExists(err) {
+			secret, err = s.secrets.Get(namespace.System, secretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if secret.Type != sshCASecretType {
+		return nil, fmt.Errorf("secret %s/%s is not of type %s", secret.Namespace, secret.Name, sshCASecretType)
+	}
+	return ssh.ParsePrivateKey(secret.Data[sshCAPrivateKey])
+}
+
+// mgmtCluster returns the management cluster of the machine.
+func (s *sshClient) mgmtCluster(machine *capi.Machine) (*v3.Cluster, error) {
+	if machine.Spec.ClusterName == "" {
+		return nil, fmt.Errorf("machine %s/%s does not belong to a cluster", machine.Namespace, machine.Name)
+	}
+	cluster, err := s.provClusters.Get(machine.Namespace, machine.Spec.ClusterName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Status.ClusterName == "" {
+		return nil, fmt.Errorf("cluster %s/%s has no management cluster yet", cluster.Namespace, cluster.Name)
+	}
+	return s.mgmtClusters.Get(cluster.Status.ClusterName, metav1.GetOptions{})
+}
+
+func (s *sshClient) createCA(cluster *v3.Cluster, secretName string) (*corev1.Secret, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "rancher-ssh-user-ca-"+cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("[machine-ssh] Creating SSH user CA for cluster %s", cluster.Name)
+	return s.secrets.Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace.System,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: v3.SchemeGroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       cluster.Name,
+				UID:        cluster.UID,
+			}},
+		},
+		Type: sshCASecretType,
+		Data: map[string][]byte{
+			sshCAPrivateKey: pem.EncodeToMemory(block),
+			sshCAPublicKey:  ssh.MarshalAuthorizedKey(signer.PublicKey()),
+		},
+	})
+}
+
+// newCertificate generates a key pair and a user certificate for it, signed by the CA, that allows to log in as the
+// principal until the TTL passed. The key ID of the certificate is logged by sshd when it is used.
+func newCertificate(ca ssh.Signer, principal, keyID string, ttl time.Duration) (ed25519.PrivateKey, *ssh.Certificate, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, nil, err
+	}
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             sshPublic,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: []string{principal},
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			// the extensions that ssh-keygen grants by default
+			Extensions: map[string]string{
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		return nil, nil, err
+	}
+	return private, cert, nil
+}
+
+// certificateSigner returns a signer that authenticates with a new certificate signed by the CA.
+func certificateSigner(ca ssh.Signer, principal, keyID string, ttl time.Duration) (ssh.Signer, error) {
+	key, cert, err := newCertificate(ca, principal, keyID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// trustCAScript adds the CA to the user CAs trusted by sshd and reloads sshd. The CA is added to the TrustedUserCAKeys
+// file that sshd already uses if there is one, otherwise sshd is configured to trust the CAs of trustedUserCAKeysPath.
+// The directive is added at the start of the configuration, so that it does not end up in a Match block.
+func trustCAScript(caPublicKey ssh.PublicKey) string {
+	return fmt.Sprintf(`set -e
+key='%s'
+if config=$(sshd -T 2>/dev/null); then
+  file=$(printf '%%s\n' "$config" | awk '$1 == "trustedusercakeys" { print $2; exit }')
+else
+  file=$(awk 'tolower($1) == "trustedusercakeys" { print $2; exit }' /etc/ssh/sshd_config)
+fi
+if [ -z "$file" ] || [ "$file" = "none" ]; then
+  file=%s
+  sed -i '1i TrustedUserCAKeys %s' /etc/ssh/sshd_config
+fi
+touch "$file"
+grep -qxF "$key" "$file" || printf '%%s\n' "$key" >> "$file"
+chmod 0644 "$file"
+sshd -t
+systemctl reload sshd 2>/dev/null || systemctl reload ssh 2>/dev/null || service sshd reload 2>/dev/null || service ssh reload
+`, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(caPublicKey))), trustedUserCAKeysPath, trustedUserCAKeysPath)
+}
+
+// trustSSHUserCA runs the trustSSHUserCA action, which adds the CA of the cluster of the machine to the CAs trusted by
+// its sshd with the private key of the machine. This is a one-time step for every machine, which records the CA the
+// machine trusts so that certificates are only used for machines that trust them.
+func (s *sshClient) trustSSHUserCA(apiRequest *types.APIRequest) error {
+	machine, err := s.machines.Get(apiRequest.Namespace, apiRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	machineInfo, err := s.getSSHKey(machine)
+	if err != nil {
+		return err
+	}
+	ca, err := s.getCA(machine)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("[machine-ssh] User %s is adding SSH user CA to machine %s/%s", requestUser(apiRequest), machine.Namespace, machine.Name)
+	if err := trustCA(machineInfo, ca); err != nil {
+		return err
+	}
+	fingerprint := ssh.FingerprintSHA256(ca.PublicKey())
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		machine, err := s.machines.Get(machine.Namespace, machine.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[trustedSSHUserCAAnnotation] = fingerprint
+		_, err = s.machines.Update(machine)
+		return err
+	})
+}
+
+// trustedCA returns the CA of the cluster of the machine, or errCANotTrusted if the machine does not trust it.
+func (s *sshClient) trustedCA(machine *capi.Machine) (ssh.Signer, error) {
+	ca, err := s.getCA(machine)
+	if err != nil {
+		return nil, err
+	}
+	if machine.Annotations[trustedSSHUserCAAnnotation] != ssh.FingerprintSHA256(ca.PublicKey()) {
+		return nil, errCANotTrusted
+	}
+	return ca, nil
+}
+
+// trustCA installs the CA on the machine, authenticating with the private key of the machine.
+func trustCA(machineInfo *machineInfo, ca ssh.Signer) error {
+	signer, err := ssh.ParsePrivateKey(machineInfo.IDRSA)
+	if err != nil {
+		return err
+	}
+	client, err := dial(machineInfo, signer)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	command := "sh -c " + shellQuote(trustCAScript(ca.PublicKey()))
+	if machineInfo.Driver.SSHUser != "root" {
+		command = "sudo -n " + command
+	}
+	if output, err := session.CombinedOutput(command); err != nil {
+		return fmt.Errorf("failed to add SSH user CA to machine %s: %w: %s", machineInfo.Driver.MachineName, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func dial(machineInfo *machineInfo, signer ssh.Signer) (*ssh.Client, error) {
+	addr := fmt.Sprintf("%s:%d", machineInfo.Driver.IPAddress, machineInfo.Driver.SSHPort)
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User: machineInfo.Driver.SSHUser,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
+	})
+}
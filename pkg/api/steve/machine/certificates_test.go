@@ -0,0 +1,145 @@
+package machine
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestGetCA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	secrets := fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl)
+	provClusters := fake.NewMockControllerInterface[*provv1.Cluster, *provv1.ClusterList](ctrl)
+	mgmtClusters := fake.NewMockNonNamespacedControllerInterface[*v3.Cluster, *v3.ClusterList](ctrl)
+	s := &sshClient{secrets: secrets, provClusters: provClusters, mgmtClusters: mgmtClusters}
+	machine := &capi.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "m1", Namespace: "fleet-default"},
+		Spec:       capi.MachineSpec{ClusterName: "downstream"},
+	}
+
+	provClusters.EXPECT().Get("fleet-default", "downstream", gomock.Any()).Return(&provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "downstream", Namespace: "fleet-default"},
+		Status:     provv1.ClusterStatus{ClusterName: "c-m-abc"},
+	}, nil).AnyTimes()
+	mgmtClusters.EXPECT().Get("c-m-abc", gomock.Any()).Return(&v3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-m-abc", UID: types.UID("uid-1")},
+	}, nil).AnyTimes()
+	var created *corev1.Secret
+	secrets.EXPECT().Get("cattle-system", "c-m-abc-ssh-user-ca", gomock.Any()).DoAndReturn(func(_, _ string, _ metav1.GetOptions) (*corev1.Secret, error) {
+		if created == nil {
+			return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "c-m-abc-ssh-user-ca")
+		}
+		return created, nil
+	}).AnyTimes()
+	secrets.EXPECT().Create(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		created = secret
+		return secret, nil
+	})
+
+	ca, err := s.getCA(machine)
+	require.NoError(t, err)
+	require.NotNil(t, created)
+	assert.Equal(t, "cattle-system", created.Namespace)
+	assert.Equal(t, corev1.SecretType(sshCASecretType), created.Type)
+	require.Len(t, created.OwnerReferences, 1)
+	assert.Equal(t, "Cluster", created.OwnerReferences[0].Kind)
+	assert.Equal(t, types.UID("uid-1"), created.OwnerReferences[0].UID)
+	assert.Equal(t, ssh.MarshalAuthorizedKey(ca.PublicKey()), created.Data[sshCAPublicKey])
+
+	// the CA is reused once it exists
+	again, err := s.getCA(machine)
+	require.NoError(t, err)
+	assert.Equal(t, ca.PublicKey().Marshal(), again.PublicKey().Marshal())
+
+	// certificates are only used for machines that trust the CA
+	_, err = s.trustedCA(machine)
+	assert.ErrorIs(t, err, errCANotTrusted)
+	machine.Annotations = map[string]string{trustedSSHUserCAAnnotation: ssh.FingerprintSHA256(ca.PublicKey())}
+	trusted, err := s.trustedCA(machine)
+	require.NoError(t, err)
+	assert.Equal(t, ca.PublicKey().Marshal(), trusted.PublicKey().Marshal())
+}
+
+func TestNewCertificate(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherCA, err := ssh.NewSignerFromKey(otherKey)
+	require.NoError(t, err)
+
+	key, cert, err := newCertificate(ca, "ubuntu", "rancher:u-abc:fleet-default/m1", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(ssh.UserCert), cert.CertType)
+	assert.Equal(t, []string{"ubuntu"}, cert.ValidPrincipals)
+	assert.Equal(t, "rancher:u-abc:fleet-default/m1", cert.KeyId)
+	assert.Contains(t, cert.Permissions.Extensions, "permit-pty")
+	assert.Equal(t, key.Public(), mustCryptoPublicKey(t, cert.Key))
+
+	checker := func(authority ssh.Signer, now time.Time) *ssh.CertChecker {
+		return &ssh.CertChecker{
+			IsUserAuthority: func(auth ssh.PublicKey) bool {
+				return bytes.Equal(auth.Marshal(), authority.PublicKey().Marshal())
+			},
+			Clock: func() time.Time { return now },
+		}
+	}
+	now := time.Now()
+	_, err = checker(ca, now).Authenticate(connMetadata("ubuntu"), cert)
+	assert.NoError(t, err)
+	_, err = checker(ca, now).Authenticate(connMetadata("root"), cert)
+	assert.Error(t, err, "principal")
+	_, err = checker(ca, now.Add(2*time.Hour)).Authenticate(connMetadata("ubuntu"), cert)
+	assert.Error(t, err, "expired")
+	_, err = checker(otherCA, now).Authenticate(connMetadata("ubuntu"), cert)
+	assert.Error(t, err, "authority")
+}
+
+func TestTrustCAScript(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+
+	script := trustCAScript(ca.PublicKey())
+	assert.Contains(t, script, string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(ca.PublicKey()))))
+	assert.Contains(t, script, "1i TrustedUserCAKeys "+trustedUserCAKeysPath)
+	// an existing TrustedUserCAKeys file is kept
+	assert.Contains(t, script, `"trustedusercakeys"`)
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
+
+func mustCryptoPublicKey(t *testing.T, key ssh.PublicKey) interface{} {
+	t.Helper()
+	cryptoKey, ok := key.(ssh.CryptoPublicKey)
+	require.True(t, ok)
+	return cryptoKey.CryptoPublicKey()
+}
+
+type connMetadata string
+
+func (c connMetadata) User() string          { return string(c) }
+func (c connMetadata) SessionID() []byte     { return nil }
+func (c connMetadata) ClientVersion() []byte { return nil }
+func (c connMetadata) ServerVersion() []byte { return nil }
+func (c connMetadata) RemoteAddr() net.Addr  { return nil }
+func (c connMetadata) LocalAddr() net.Addr   { return nil }
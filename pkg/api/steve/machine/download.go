@@ -4,15 +4,26 @@
 	"archive/zip"
 	"bytes"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 func (s *sshClient) download(apiContext *types.APIRequest) error {
-	machineInfo, err := s.getSSHKey(apiContext.Namespace, apiContext.Name)
+	machine, err := s.machines.Get(apiContext.Namespace, apiContext.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	machineInfo, err := s.getSSHKey(machine)
 	if err != nil {
 		return err
 	}
@@ -20,12 +31,22 @@ func (s *sshClient) download(apiContext *types.APIRequest) error {
 	buf := &bytes.Buffer{}
 	zw := zip.NewWriter(buf)
 	name := machineInfo.Driver.MachineName
+	userName := requestUser(apiContext)
 
-	if err := addFile(zw, name+"/id_rsa", machineInfo.IDRSA); err != nil {
-		return err
-	}
-	if err := addFile(zw, name+"/id_rsa.pub", machineInfo.IDRSAPub); err != nil {
-		return err
+	if settings.MachineSSHCertificates.Get() == "true" {
+		validUntil, err := s.addCertificate(zw, name, machine, machineInfo, userName)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("[machine-ssh] User %s downloaded SSH certificate for machine %s/%s valid until %s", userName, machine.Namespace, machine.Name, validUntil.UTC().Format(time.RFC3339))
+	} else {
+		if err := addFile(zw, name+"/id_rsa", machineInfo.IDRSA); err != nil {
+			return err
+		}
+		if err := addFile(zw, name+"/id_rsa.pub", machineInfo.IDRSAPub); err != nil {
+			return err
+		}
+		logrus.Infof("[machine-ssh] User %s downloaded SSH private key of machine %s/%s", userName, machine.Namespace, machine.Name)
 	}
 	machineConfigBytes, err := json.Marshal(machineInfo.Driver)
 	if err != nil {
@@ -49,6 +70,37 @@ func (s *sshClient) download(apiContext *types.APIRequest) error {
 	return err
 }
 
+// addCertificate adds a new key pair and a certificate for it, valid for machine-ssh-certificate-ttl, to the zip and
+// returns the end of the validity of the certificate. The machine must trust the CA of its cluster.
+func (s *sshClient) addCertificate(zw *zip.Writer, name string, machine *capi.Machine, machineInfo *machineInfo, userName string) (time.Time, error) {
+	ttl, err := time.ParseDuration(settings.MachineSSHCertificateTTL.Get())
+	if err != nil || ttl <= 0 {
+		return time.Time{}, fmt.Errorf("invalid machine-ssh-certificate-ttl %q", settings.MachineSSHCertificateTTL.Get())
+	}
+	ca, err := s.trustedCA(machine)
+	if err != nil {
+		return time.Time{}, err
+	}
+	key, cert, err := newCertificate(ca, machineInfo.Driver.SSHUser, certificateKeyID(userName, machine), ttl)
+	if err != nil {
+		return time.Time{}, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := addFile(zw, name+"/id_ed25519", pem.EncodeToMemory(block)); err != nil {
+		return time.Time{}, err
+	}
+	if err := addFile(zw, name+"/id_ed25519.pub", ssh.MarshalAuthorizedKey(cert.Key)); err != nil {
+		return time.Time{}, err
+	}
+	if err := addFile(zw, name+"/id_ed25519-cert.pub", ssh.MarshalAuthorizedKey(cert)); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(cert.ValidBefore), 0), nil
+}
+
 func addFile(zw *zip.Writer, name string, contents []byte) error {
 	fh := &zip.FileHeader{
 		Name: name,
@@ -5,15 +5,19 @@
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/wrangler"
 	schema2 "github.com/rancher/steve/pkg/schema"
 	steve "github.com/rancher/steve/pkg/server"
+	"github.com/rancher/wrangler/v2/pkg/schemas"
 )
 
 func Register(server *steve.Server, clients *wrangler.Context) {
 	sshClient := &sshClient{
-		machines: clients.CAPI.Machine(),
-		secrets:  clients.Core.Secret(),
+		machines:     clients.CAPI.Machine(),
+		provClusters: clients.Provisioning.Cluster(),
+		mgmtClusters: clients.Mgmt.Cluster(),
+		secrets:      clients.Core.Secret(),
 	}
 
 	server.SchemaFactory.AddTemplate(schema2.Template{
@@ -25,11 +29,21 @@ func Register(server *steve.Server, clients *wrangler.Context) {
 			}
 			schema.LinkHandlers["shell"] = sshClient
 			schema.LinkHandlers["sshkeys"] = sshClient
+			schema.ActionHandlers = map[string]http.Handler{
+				trustSSHUserCAAction: sshClient,
+			}
+			schema.ResourceActions = map[string]schemas.Action{
+				trustSSHUserCAAction: {},
+			}
 			schema.Formatter = func(request *types.APIRequest, resource *types.RawResource) {
 				if err := request.AccessControl.CanUpdate(request, types.APIObject{}, request.Schema); err != nil ||
 					resource.APIObject.Data().String("spec", "infrastructureRef", "apiVersion") != capr.RKEMachineAPIVersion {
 					delete(resource.Links, "shell")
 					delete(resource.Links, "sshkeys")
+					return
+				}
+				if settings.MachineSSHCertificates.Get() == "true" {
+					resource.AddAction(request, trustSSHUserCAAction)
 				}
 			}
 		},
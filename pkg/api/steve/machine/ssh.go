@@ -7,6 +7,7 @@
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,17 +16,27 @@
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/rancher/pkg/capr"
 	capicontrollers "github.com/rancher/rancher/pkg/generated/controllers/cluster.x-k8s.io/v1beta1"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	provcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/settings"
 	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v2/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 type sshClient struct {
-	secrets  corecontrollers.SecretClient
-	machines capicontrollers.MachineClient
+	secrets      corecontrollers.SecretClient
+	machines     capicontrollers.MachineClient
+	provClusters provcontrollers.ClusterClient
+	mgmtClusters mgmtcontrollers.ClusterClient
 }
 
 var upgrader = websocket.Upgrader{
@@ -47,20 +58,37 @@ func (s *sshClient) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if apiRequest.Action == trustSSHUserCAAction {
+		if err := s.trustSSHUserCA(apiRequest); err != nil {
+			apiRequest.WriteError(err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	switch apiRequest.Link {
 	case "shell":
 		if err := s.shell(apiRequest); err != nil {
-			apiRequest.WriteError(err)
+			apiRequest.WriteError(toAPIError(err))
 			return
 		}
 	case "sshkeys":
 		if err := s.download(apiRequest); err != nil {
-			apiRequest.WriteError(err)
+			apiRequest.WriteError(toAPIError(err))
 			return
 		}
 	}
 }
 
+// toAPIError tells users how to resolve the errors they can resolve.
+func toAPIError(err error) error {
+	if errors.Is(err, errCANotTrusted) {
+		return apierror.NewAPIError(validation.InvalidState, fmt.Sprintf("%v, run the %s action of the machine first", err, trustSSHUserCAAction))
+	}
+	return err
+}
+
 func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 	ctx, cancel := context.WithCancel(apiRequest.Context())
 	defer cancel()
@@ -72,30 +100,28 @@ func (s *sshClient) shell(apiRequest *types.APIRequest) error {
 	}
 
 	defer conn.Close()
-	machineInfo, err := s.getSSHKey(apiRequest.Namespace, apiRequest.Name)
+	machine, err := s.machines.Get(apiRequest.Namespace, apiRequest.Name, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
-
-	signer, err := ssh.ParsePrivateKey(machineInfo.IDRSA)
+	machineInfo, err := s.getSSHKey(machine)
 	if err != nil {
 		return err
 	}
 
-	addr := fmt.Sprintf("%s:%d", machineInfo.Driver.IPAddress, machineInfo.Driver.SSHPort)
-	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
-		User: machineInfo.Driver.SSHUser,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         30 * time.Second,
-	})
+	userName := requestUser(apiRequest)
+	client, method, err := s.connect(machine, machineInfo, userName)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
+	start := time.Now()
+	logrus.Infof("[machine-ssh] User %s started SSH session to machine %s/%s at %s with %s", userName, machine.Namespace, machine.Name, client.RemoteAddr(), method)
+	defer func() {
+		logrus.Infof("[machine-ssh] User %s ended SSH session to machine %s/%s after %s", userName, machine.Namespace, machine.Name, time.Since(start).Round(time.Second))
+	}()
+
 	session, err := client.NewSession()
 	if err != nil {
 		return err
@@ -175,15 +201,48 @@ type machineConfig struct {
 	MachineName string
 }
 
-func (s *sshClient) getSSHKey(machineNamespace, machineName string) (*machineInfo, error) {
-	result := &machineInfo{}
-	machine, err := s.machines.Get(machineNamespace, machineName, metav1.GetOptions{})
+// connect opens a SSH connection to the machine for the user. The connection is authenticated with a short-lived
+// certificate if machine-ssh-certificates is enabled, which requires the machine to trust the CA of its cluster, and
+// with the private key of the machine otherwise. The authentication method is returned for logging.
+func (s *sshClient) connect(machine *capi.Machine, machineInfo *machineInfo, userName string) (*ssh.Client, string, error) {
+	if settings.MachineSSHCertificates.Get() != "true" {
+		signer, err := ssh.ParsePrivateKey(machineInfo.IDRSA)
+		if err != nil {
+			return nil, "", err
+		}
+		client, err := dial(machineInfo, signer)
+		return client, "machine key", err
+	}
+
+	ca, err := s.trustedCA(machine)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	keyID := certificateKeyID(userName, machine)
+	signer, err := certificateSigner(ca, machineInfo.Driver.SSHUser, keyID, shellCertificateTTL)
+	if err != nil {
+		return nil, "", err
 	}
+	client, err := dial(machineInfo, signer)
+	return client, "certificate " + keyID, err
+}
+
+// certificateKeyID returns the key ID of the certificates issued to the user for the machine.
+func certificateKeyID(userName string, machine *capi.Machine) string {
+	return fmt.Sprintf("rancher:%s:%s/%s", userName, machine.Namespace, machine.Name)
+}
 
+func requestUser(apiRequest *types.APIRequest) string {
+	if user, ok := request.UserFrom(apiRequest.Context()); ok {
+		return user.GetName()
+	}
+	return "unknown"
+}
+
+func (s *sshClient) getSSHKey(machine *capi.Machine) (*machineInfo, error) {
+	result := &machineInfo{}
 	secretName := capr.MachineStateSecretName(machine.Spec.InfrastructureRef.Name)
-	secret, err := s.secrets.Get(machineNamespace, secretName, metav1.GetOptions{})
+	secret, err := s.secrets.Get(machine.Namespace, secretName, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -36,12 +36,32 @@ type Token struct {
 	Current         bool              `json:"current"`
 	ClusterName     string            `json:"clusterName,omitempty" norman:"noupdate,type=reference[cluster]"`
 	Enabled         *bool             `json:"enabled,omitempty" norman:"default=true"`
+	// Scopes restricts the requests the token can be used for. A request is allowed if it matches any
+	// of the scopes. A token without scopes can be used for any request its user is allowed to make.
+	Scopes []TokenScope `json:"scopes,omitempty" norman:"noupdate"`
+	// SourceCIDRs restricts the client addresses the token can be used from. Tokens with scopes or source CIDRs
+	// can't be used with the authorized cluster endpoint, which does not enforce them.
+	SourceCIDRs []string `json:"sourceCIDRs,omitempty" norman:"noupdate"`
+	// LastUsedAt is when the token was last used to authenticate a request. It is updated at most once a minute.
+	LastUsedAt *metav1.Time `json:"lastUsedAt,omitempty" norman:"nocreate,noupdate"`
+	// LastUsedFrom is the client address the token was last used from.
+	LastUsedFrom string `json:"lastUsedFrom,omitempty" norman:"nocreate,noupdate"`
 }
 
 func (t *Token) ObjClusterName() string {
 	return t.ClusterName
 }
 
+// TokenScope allows requests using any of its verbs on any of its resources in any of its API groups.
+// An empty list of API groups or resources, or "*", matches all of them. Subresources are only matched
+// when they are named, e.g. "pods/exec" or "pods/*". The group of the norman API (/v3) is
+// management.cattle.io and the core group is "".
+type TokenScope struct {
+	Verbs     []string `json:"verbs"`
+	APIGroups []string `json:"apiGroups,omitempty"`
+	Resources []string `json:"resources,omitempty"`
+}
+
 // +genclient
 // +kubebuilder:skipversion
 // +genclient:nonNamespaced
@@ -65,6 +85,13 @@ type User struct {
 
 type UserStatus struct {
 	Conditions []UserCondition `json:"conditions"`
+	// FailedLoginAttempts is the number of consecutive failed password logins of a local user.
+	FailedLoginAttempts int `json:"failedLoginAttempts,omitempty" norman:"nocreate,noupdate"`
+	// LastFailedLoginTime is the time of the last failed password login of a local user.
+	LastFailedLoginTime *metav1.Time `json:"lastFailedLoginTime,omitempty" norman:"nocreate,noupdate"`
+	// LockedUntil is set while the password logins of a local user are rejected after too many failed attempts. Users
+	// that can update users unlock it earlier with the unlock action.
+	LockedUntil *metav1.Time `json:"lockedUntil,omitempty" norman:"nocreate,noupdate"`
 }
 
 type UserCondition struct {
@@ -164,6 +191,23 @@ type SetPasswordInput struct {
 	NewPassword string `json:"newPassword" norman:"type=string,required"`
 }
 
+// TOTPEnrollment is the secret of a TOTP enrollment that was not confirmed yet. The secret is added to an authenticator
+// app, usually by scanning a QR code of the URI, and the enrollment confirmed with a code generated by the app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPCodeInput struct {
+	// Code is a TOTP code generated by the authenticator app of the user, or one of its recovery codes.
+	Code string `json:"code" norman:"type=string,required"`
+}
+
+type RecoveryCodesOutput struct {
+	// RecoveryCodes can each be used once instead of a TOTP code. They are only shown when they are generated.
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 // +genclient
 // +kubebuilder:skipversion
 // +genclient:nonNamespaced
@@ -478,6 +522,52 @@ type KeyCloakOIDCConfig struct {
 	OIDCConfig `json:",inline" mapstructure:",squash"`
 }
 
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GenericOAuthConfig configures an OAuth2 provider that does not implement OpenID Connect, such as GitLab. Users and
+// groups are read from the JSON responses of the provider's endpoints, the fields of these responses are dotted paths.
+type GenericOAuthConfig struct {
+	AuthConfig `json:",inline" mapstructure:",squash"`
+
+	ClientID     string `json:"clientId" norman:"required"`
+	ClientSecret string `json:"clientSecret,omitempty" norman:"required,type=password"`
+	Scopes       string `json:"scope,omitempty"`
+	RancherURL   string `json:"rancherUrl" norman:"required,notnullable"`
+	// Certificate is the PEM encoded CA bundle of the provider's endpoints, if they are not signed by a public CA.
+	Certificate string `json:"certificate,omitempty"`
+
+	AuthEndpoint     string `json:"authEndpoint" norman:"required,notnullable"`
+	TokenEndpoint    string `json:"tokenEndpoint" norman:"required,notnullable"`
+	UserInfoEndpoint string `json:"userInfoEndpoint" norman:"required,notnullable"`
+	// GroupsEndpoint lists the groups of the user, in addition to the groups of the GroupsField of the user info.
+	GroupsEndpoint string `json:"groupsEndpoint,omitempty"`
+	// UserSearchEndpoint and GroupSearchEndpoint search users and groups by name, {query} is replaced by the search
+	// value. Only principals matching the search value exactly are found without them.
+	UserSearchEndpoint  string `json:"userSearchEndpoint,omitempty"`
+	GroupSearchEndpoint string `json:"groupSearchEndpoint,omitempty"`
+	// ResultsField is the field of the list of results in the responses of the groups and search endpoints, for
+	// providers that do not respond with a list.
+	ResultsField string `json:"resultsField,omitempty"`
+
+	UserIDField           string `json:"userIdField,omitempty" norman:"default=sub"`
+	UserNameField         string `json:"userNameField,omitempty" norman:"default=preferred_username"`
+	DisplayNameField      string `json:"displayNameField,omitempty" norman:"default=name"`
+	ProfilePictureField   string `json:"profilePictureField,omitempty"`
+	GroupsField           string `json:"groupsField,omitempty"`
+	GroupIDField          string `json:"groupIdField,omitempty" norman:"default=id"`
+	GroupDisplayNameField string `json:"groupDisplayNameField,omitempty" norman:"default=name"`
+}
+
+type GenericOAuthConfigTestOutput struct {
+	RedirectURL string `json:"redirectUrl"`
+}
+
+type GenericOAuthConfigApplyInput struct {
+	GenericOAuthConfig GenericOAuthConfig `json:"genericOAuthConfig,omitempty"`
+	Code               string             `json:"code,omitempty"`
+	Enabled            bool               `json:"enabled,omitempty"`
+}
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
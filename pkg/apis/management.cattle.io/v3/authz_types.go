@@ -218,6 +218,21 @@ type GlobalRoleBinding struct {
 	// GlobalRoleName is the name of the Global Role that the subject will be bound to. Immutable.
 	// +kubebuilder:validation:Required
 	GlobalRoleName string `json:"globalRoleName" norman:"required,noupdate,type=reference[globalRole]"`
+
+	// ExpiresAt is the time at which the binding expires. Expired bindings are deleted, which revokes
+	// the permissions they grant. Takes precedence over TTL.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// TTL is how long after its creation the binding expires, as a duration string such as "8h".
+	// Ignored if ExpiresAt is set.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	TTL string `json:"ttl,omitempty"`
+
+	// Status is the most recently observed status of the binding.
+	// +optional
+	Status RoleBindingStatus `json:"status,omitempty" norman:"nocreate,noupdate"`
 }
 
 // +genclient
@@ -357,6 +372,21 @@ type ProjectRoleTemplateBinding struct {
 	// Deprecated.
 	// +optional
 	ServiceAccount string `json:"serviceAccount,omitempty" norman:"nocreate,noupdate"`
+
+	// ExpiresAt is the time at which the binding expires. Expired bindings are deleted, which revokes
+	// the permissions they grant. Takes precedence over TTL.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// TTL is how long after its creation the binding expires, as a duration string such as "8h".
+	// Ignored if ExpiresAt is set.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	TTL string `json:"ttl,omitempty"`
+
+	// Status is the most recently observed status of the binding.
+	// +optional
+	Status RoleBindingStatus `json:"status,omitempty" norman:"nocreate,noupdate"`
 }
 
 func (p *ProjectRoleTemplateBinding) ObjClusterName() string {
@@ -403,12 +433,136 @@ type ClusterRoleTemplateBinding struct {
 	// RoleTemplateName is the name of the role template that defines permissions to perform actions on resources in the cluster. Immutable.
 	// +kubebuilder:validation:Required
 	RoleTemplateName string `json:"roleTemplateName" norman:"required,noupdate,type=reference[roleTemplate]"`
+
+	// ExpiresAt is the time at which the binding expires. Expired bindings are deleted, which revokes
+	// the permissions they grant. Takes precedence over TTL.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// TTL is how long after its creation the binding expires, as a duration string such as "8h".
+	// Ignored if ExpiresAt is set.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	TTL string `json:"ttl,omitempty"`
+
+	// Status is the most recently observed status of the binding.
+	// +optional
+	Status RoleBindingStatus `json:"status,omitempty" norman:"nocreate,noupdate"`
 }
 
 func (c *ClusterRoleTemplateBinding) ObjClusterName() string {
 	return c.ClusterName
 }
 
+// RoleBindingStatus represents the most recently observed status of a GlobalRoleBinding,
+// ClusterRoleTemplateBinding or ProjectRoleTemplateBinding.
+type RoleBindingStatus struct {
+	// ExpiresAt is the time at which the binding expires, derived from its ExpiresAt or TTL. The remaining
+	// time is shown relative to it.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+const (
+	// AccessRequestStatePending is the state of an AccessRequest that has not been reviewed yet.
+	AccessRequestStatePending = "Pending"
+	// AccessRequestStateApproved is the state of an AccessRequest that has been approved.
+	AccessRequestStateApproved = "Approved"
+	// AccessRequestStateDenied is the state of an AccessRequest that has been denied.
+	AccessRequestStateDenied = "Denied"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AccessRequest is a request for a user to be granted a role template in a cluster or project. It is reviewed
+// through the approve and deny actions by users holding the approver role of the target, and once approved it
+// is granted with a ClusterRoleTemplateBinding or ProjectRoleTemplateBinding that expires after the requested duration.
+type AccessRequest struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the requested access.
+	Spec AccessRequestSpec `json:"spec"`
+
+	// Status is the most recently observed status of the request.
+	// +optional
+	Status AccessRequestStatus `json:"status,omitempty"`
+}
+
+// AccessRequestSpec is the access requested by an AccessRequest.
+type AccessRequestSpec struct {
+	// UserName is the name of the user the role template is requested for.
+	// +kubebuilder:validation:Required
+	UserName string `json:"userName"`
+
+	// RoleTemplateName is the name of the requested role template.
+	// +kubebuilder:validation:Required
+	RoleTemplateName string `json:"roleTemplateName"`
+
+	// ClusterName is the metadata.name of the cluster in which the role template is requested.
+	// Ignored if ProjectName is set.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// ProjectName is the name of the project in which the role template is requested, in the
+	// format "<cluster name>:<project name>".
+	// +optional
+	ProjectName string `json:"projectName,omitempty"`
+
+	// Justification is the reason given for the request.
+	// +optional
+	Justification string `json:"justification,omitempty"`
+
+	// Duration is how long the role template is granted for once the request is approved, as a
+	// duration string such as "8h".
+	// +kubebuilder:validation:Required
+	Duration string `json:"duration"`
+}
+
+// AccessRequestStatus is the most recently observed status of an AccessRequest.
+type AccessRequestStatus struct {
+	// State is one of "Pending", "Approved" or "Denied".
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// ReviewedBy is the name of the user who approved or denied the request.
+	// +optional
+	ReviewedBy string `json:"reviewedBy,omitempty"`
+
+	// ReviewedAt is the time at which the request was approved or denied.
+	// +optional
+	ReviewedAt *metav1.Time `json:"reviewedAt,omitempty"`
+
+	// ReviewedGeneration is the metadata.generation of the request that was reviewed. An approval
+	// is discarded if the request is modified before it is granted.
+	// +optional
+	ReviewedGeneration int64 `json:"reviewedGeneration,omitempty"`
+
+	// Comment is the comment left by the reviewer.
+	// +optional
+	Comment string `json:"comment,omitempty"`
+
+	// BindingNamespace is the namespace of the binding created for an approved request.
+	// +optional
+	BindingNamespace string `json:"bindingNamespace,omitempty"`
+
+	// BindingName is the name of the ClusterRoleTemplateBinding or ProjectRoleTemplateBinding
+	// created for an approved request.
+	// +optional
+	BindingName string `json:"bindingName,omitempty"`
+
+	// Message explains why a request could not be granted.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 type SetPodSecurityPolicyTemplateInput struct {
 	PodSecurityPolicyTemplateName string `json:"podSecurityPolicyTemplateId" norman:"type=reference[podSecurityPolicyTemplate]"`
 }
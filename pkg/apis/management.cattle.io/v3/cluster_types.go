@@ -70,6 +70,9 @@ func init() {
 	ClusterConditionHarvesterCloudProviderConfigMigrated condition.Cond = "HarvesterCloudProviderConfigMigrated"
 	ClusterConditionACISecretsMigrated                   condition.Cond = "ACISecretsMigrated"
 	ClusterConditionRKESecretsMigrated                   condition.Cond = "RKESecretsMigrated"
+	// ClusterConditionAgentProxyConnected false when the cluster agent reported that its proxy rejected tunnel
+	// connection attempts before its latest connection
+	ClusterConditionAgentProxyConnected condition.Cond = "AgentProxyConnected"
 
 	ClusterDriverImported = "imported"
 	ClusterDriverLocal    = "local"
@@ -120,6 +123,7 @@ type ClusterSpecBase struct {
 	ClusterSecrets                                       ClusterSecrets                          `json:"clusterSecrets" norman:"nocreate,noupdate"`
 	ClusterAgentDeploymentCustomization                  *AgentDeploymentCustomization           `json:"clusterAgentDeploymentCustomization,omitempty"`
 	FleetAgentDeploymentCustomization                    *AgentDeploymentCustomization           `json:"fleetAgentDeploymentCustomization,omitempty"`
+	ClusterAgentProxyConfig                              *AgentProxyConfig                       `json:"clusterAgentProxyConfig,omitempty"`
 }
 
 type AgentDeploymentCustomization struct {
@@ -128,6 +132,21 @@ type AgentDeploymentCustomization struct {
 	OverrideResourceRequirements *v1.ResourceRequirements `json:"overrideResourceRequirements,omitempty"`
 }
 
+// AgentProxyConfig configures the HTTP(S) proxy the cluster agent uses to open its tunnel to Rancher.
+type AgentProxyConfig struct {
+	// URL of the proxy, either http:// or https://.
+	URL string `json:"url"`
+	// CACerts is a PEM bundle trusted for the TLS connection to an https:// proxy and, for intercepting proxies,
+	// for the connection to Rancher itself.
+	CACerts string `json:"caCerts,omitempty"`
+	// CredentialSecret references a kubernetes.io/basic-auth Secret in the form <namespace>:<name> whose
+	// username and password are sent to the proxy. The Secret must be in the cluster's namespace or its fleet
+	// workspace.
+	CredentialSecret string `json:"credentialSecret,omitempty"`
+	// NoProxy lists hosts, domains (.example.com) and CIDRs the agent reaches without the proxy.
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
 type ClusterSpec struct {
 	ClusterSpecBase
 	DisplayName                         string                      `json:"displayName" norman:"required"`
@@ -201,6 +220,28 @@ type ClusterStatus struct {
 	AADClientCertSecret                  string                    `json:"aadClientCertSecret,omitempty" norman:"nocreate,noupdate"`   // Deprecated: use ClusterSpec.ClusterSecrets.AADClientCertSecret instead
 
 	AppliedClusterAgentDeploymentCustomization *AgentDeploymentCustomization `json:"appliedClusterAgentDeploymentCustomization,omitempty"`
+	AppliedClusterAgentProxyConfig             *AgentProxyConfig             `json:"appliedClusterAgentProxyConfig,omitempty"`
+
+	// ConnectionHistory lists the most recent tunnel sessions of the cluster agents, oldest first.
+	ConnectionHistory []ClusterConnectionEvent `json:"connectionHistory,omitempty" norman:"nocreate,noupdate"`
+}
+
+// ClusterConnectionEvent records a tunnel session of a cluster agent being established or closed.
+type ClusterConnectionEvent struct {
+	// Type is Connected or Disconnected.
+	Type string `json:"type"`
+	// Time the event happened.
+	Time string `json:"time"`
+	// ClientKey identifies the tunnel session.
+	ClientKey string `json:"clientKey,omitempty"`
+	// Server is the Rancher replica that handled the session.
+	Server string `json:"server,omitempty"`
+	// RemoteAddress the agent connected from.
+	RemoteAddress string `json:"remoteAddress,omitempty"`
+	// Reason the session was closed.
+	Reason string `json:"reason,omitempty"`
+	// Duration of the session that was closed.
+	Duration string `json:"duration,omitempty"`
 }
 
 type ClusterComponentStatus struct {
@@ -58,6 +58,9 @@ type BasicLogin struct {
 	GenericLogin `json:",inline"`
 	Username     string `json:"username" norman:"type=string,required"`
 	Password     string `json:"password" norman:"type=string,required"`
+	// MFACode is a TOTP code or a recovery code of the user. It is only used by the local provider, for users that
+	// enabled TOTP or must enroll.
+	MFACode string `json:"mfaCode,omitempty"`
 }
 
 // +genclient
@@ -229,3 +232,16 @@ type OIDCLogin struct {
 type KeyCloakOIDCProvider struct {
 	OIDCProvider `json:",inline"`
 }
+
+type GenericOAuthProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	AuthProvider      `json:",inline"`
+
+	RedirectURL string `json:"redirectUrl"`
+}
+
+type GenericOAuthLogin struct {
+	GenericLogin `json:",inline"`
+	Code         string `json:"code" norman:"type=string,required"`
+}
@@ -203,6 +203,103 @@ func (in *APIServiceStatus) DeepCopy() *APIServiceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRequest) DeepCopyInto(out *AccessRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRequest.
+func (in *AccessRequest) DeepCopy() *AccessRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AccessRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRequestList) DeepCopyInto(out *AccessRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AccessRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRequestList.
+func (in *AccessRequestList) DeepCopy() *AccessRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AccessRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRequestSpec) DeepCopyInto(out *AccessRequestSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRequestSpec.
+func (in *AccessRequestSpec) DeepCopy() *AccessRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRequestStatus) DeepCopyInto(out *AccessRequestStatus) {
+	*out = *in
+	if in.ReviewedAt != nil {
+		in, out := &in.ReviewedAt, &out.ReviewedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRequestStatus.
+func (in *AccessRequestStatus) DeepCopy() *AccessRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Action) DeepCopyInto(out *Action) {
 	*out = *in
@@ -397,6 +494,27 @@ func (in *AgentDeploymentCustomization) DeepCopy() *AgentDeploymentCustomization
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentProxyConfig) DeepCopyInto(out *AgentProxyConfig) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentProxyConfig.
+func (in *AgentProxyConfig) DeepCopy() *AgentProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentProxyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AlidnsProviderConfig) DeepCopyInto(out *AlidnsProviderConfig) {
 	*out = *in
@@ -1417,6 +1535,22 @@ func (in *ClusterCondition) DeepCopy() *ClusterCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConnectionEvent) DeepCopyInto(out *ClusterConnectionEvent) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConnectionEvent.
+func (in *ClusterConnectionEvent) DeepCopy() *ClusterConnectionEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConnectionEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterList) DeepCopyInto(out *ClusterList) {
 	*out = *in
@@ -1722,6 +1856,11 @@ func (in *ClusterRoleTemplateBinding) DeepCopyInto(out *ClusterRoleTemplateBindi
 	out.Namespaced = in.Namespaced
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -1895,6 +2034,11 @@ func (in *ClusterSpecBase) DeepCopyInto(out *ClusterSpecBase) {
 		*out = new(AgentDeploymentCustomization)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ClusterAgentProxyConfig != nil {
+		in, out := &in.ClusterAgentProxyConfig, &out.ClusterAgentProxyConfig
+		*out = new(AgentProxyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -1992,6 +2136,16 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 		*out = new(AgentDeploymentCustomization)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AppliedClusterAgentProxyConfig != nil {
+		in, out := &in.AppliedClusterAgentProxyConfig, &out.AppliedClusterAgentProxyConfig
+		*out = new(AgentProxyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConnectionHistory != nil {
+		in, out := &in.ConnectionHistory, &out.ConnectionHistory
+		*out = make([]ClusterConnectionEvent, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -3210,6 +3364,133 @@ func (in *GenericLogin) DeepCopy() *GenericLogin {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenericOAuthConfig) DeepCopyInto(out *GenericOAuthConfig) {
+	*out = *in
+	in.AuthConfig.DeepCopyInto(&out.AuthConfig)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenericOAuthConfig.
+func (in *GenericOAuthConfig) DeepCopy() *GenericOAuthConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GenericOAuthConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GenericOAuthConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenericOAuthConfigApplyInput) DeepCopyInto(out *GenericOAuthConfigApplyInput) {
+	*out = *in
+	in.GenericOAuthConfig.DeepCopyInto(&out.GenericOAuthConfig)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenericOAuthConfigApplyInput.
+func (in *GenericOAuthConfigApplyInput) DeepCopy() *GenericOAuthConfigApplyInput {
+	if in == nil {
+		return nil
+	}
+	out := new(GenericOAuthConfigApplyInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenericOAuthConfigList) DeepCopyInto(out *GenericOAuthConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GenericOAuthConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenericOAuthConfigList.
+func (in *GenericOAuthConfigList) DeepCopy() *GenericOAuthConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(GenericOAuthConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GenericOAuthConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenericOAuthConfigTestOutput) DeepCopyInto(out *GenericOAuthConfigTestOutput) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenericOAuthConfigTestOutput.
+func (in *GenericOAuthConfigTestOutput) DeepCopy() *GenericOAuthConfigTestOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(GenericOAuthConfigTestOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenericOAuthLogin) DeepCopyInto(out *GenericOAuthLogin) {
+	*out = *in
+	out.GenericLogin = in.GenericLogin
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenericOAuthLogin.
+func (in *GenericOAuthLogin) DeepCopy() *GenericOAuthLogin {
+	if in == nil {
+		return nil
+	}
+	out := new(GenericOAuthLogin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenericOAuthProvider) DeepCopyInto(out *GenericOAuthProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.AuthProvider.DeepCopyInto(&out.AuthProvider)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenericOAuthProvider.
+func (in *GenericOAuthProvider) DeepCopy() *GenericOAuthProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(GenericOAuthProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GithubConfig) DeepCopyInto(out *GithubConfig) {
 	*out = *in
@@ -3674,6 +3955,11 @@ func (in *GlobalRoleBinding) DeepCopyInto(out *GlobalRoleBinding) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -6914,6 +7200,11 @@ func (in *ProjectRoleTemplateBinding) DeepCopyInto(out *ProjectRoleTemplateBindi
 	out.Namespaced = in.Namespaced
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -7152,6 +7443,27 @@ func (in *RancherUserNotificationList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecoveryCodesOutput) DeepCopyInto(out *RecoveryCodesOutput) {
+	*out = *in
+	if in.RecoveryCodes != nil {
+		in, out := &in.RecoveryCodes, &out.RecoveryCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecoveryCodesOutput.
+func (in *RecoveryCodesOutput) DeepCopy() *RecoveryCodesOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(RecoveryCodesOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceQuotaLimit) DeepCopyInto(out *ResourceQuotaLimit) {
 	*out = *in
@@ -7383,6 +7695,26 @@ func (in *RkeK8sSystemImageList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleBindingStatus) DeepCopyInto(out *RoleBindingStatus) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleBindingStatus.
+func (in *RoleBindingStatus) DeepCopy() *RoleBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RoleTemplate) DeepCopyInto(out *RoleTemplate) {
 	*out = *in
@@ -8031,6 +8363,38 @@ func (in *SyslogConfig) DeepCopy() *SyslogConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TOTPCodeInput) DeepCopyInto(out *TOTPCodeInput) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TOTPCodeInput.
+func (in *TOTPCodeInput) DeepCopy() *TOTPCodeInput {
+	if in == nil {
+		return nil
+	}
+	out := new(TOTPCodeInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TOTPEnrollment) DeepCopyInto(out *TOTPEnrollment) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TOTPEnrollment.
+func (in *TOTPEnrollment) DeepCopy() *TOTPEnrollment {
+	if in == nil {
+		return nil
+	}
+	out := new(TOTPEnrollment)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Target) DeepCopyInto(out *Target) {
 	*out = *in
@@ -8355,6 +8719,22 @@ func (in *Token) DeepCopyInto(out *Token) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]TokenScope, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SourceCIDRs != nil {
+		in, out := &in.SourceCIDRs, &out.SourceCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastUsedAt != nil {
+		in, out := &in.LastUsedAt, &out.LastUsedAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -8409,6 +8789,37 @@ func (in *TokenList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenScope) DeepCopyInto(out *TokenScope) {
+	*out = *in
+	if in.Verbs != nil {
+		in, out := &in.Verbs, &out.Verbs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.APIGroups != nil {
+		in, out := &in.APIGroups, &out.APIGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenScope.
+func (in *TokenScope) DeepCopy() *TokenScope {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateGlobalDNSTargetsInput) DeepCopyInto(out *UpdateGlobalDNSTargetsInput) {
 	*out = *in
@@ -8681,6 +9092,14 @@ func (in *UserStatus) DeepCopyInto(out *UserStatus) {
 		*out = make([]UserCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.LastFailedLoginTime != nil {
+		in, out := &in.LastFailedLoginTime, &out.LastFailedLoginTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LockedUntil != nil {
+		in, out := &in.LockedUntil, &out.LockedUntil
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
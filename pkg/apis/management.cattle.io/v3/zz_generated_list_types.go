@@ -43,6 +43,23 @@ func NewAPIService(namespace, name string, obj APIService) *APIService {
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
+// AccessRequestList is a list of AccessRequest resources
+type AccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []AccessRequest `json:"items"`
+}
+
+func NewAccessRequest(namespace, name string, obj AccessRequest) *AccessRequest {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("AccessRequest").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 // ActiveDirectoryProviderList is a list of ActiveDirectoryProvider resources
 type ActiveDirectoryProviderList struct {
 	metav1.TypeMeta `json:",inline"`
@@ -29,6 +29,7 @@
 
 var (
 	APIServiceResourceName                                = "apiservices"
+	AccessRequestResourceName                             = "accessrequests"
 	ActiveDirectoryProviderResourceName                   = "activedirectoryproviders"
 	AuthConfigResourceName                                = "authconfigs"
 	AuthProviderResourceName                              = "authproviders"
@@ -120,6 +121,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&APIService{},
 		&APIServiceList{},
+		&AccessRequest{},
+		&AccessRequestList{},
 		&ActiveDirectoryProvider{},
 		&ActiveDirectoryProviderList{},
 		&AuthConfig{},
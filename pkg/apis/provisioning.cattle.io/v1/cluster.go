@@ -28,6 +28,7 @@ type ClusterSpec struct {
 
 	AgentEnvVars                                         []rkev1.EnvVar                `json:"agentEnvVars,omitempty"`
 	ClusterAgentDeploymentCustomization                  *AgentDeploymentCustomization `json:"clusterAgentDeploymentCustomization,omitempty"`
+	ClusterAgentProxyConfig                              *AgentProxyConfig             `json:"clusterAgentProxyConfig,omitempty"`
 	DefaultPodSecurityAdmissionConfigurationTemplateName string                        `json:"defaultPodSecurityAdmissionConfigurationTemplateName,omitempty"`
 	DefaultPodSecurityPolicyTemplateName                 string                        `json:"defaultPodSecurityPolicyTemplateName,omitempty" norman:"type=reference[podSecurityPolicyTemplate]"`
 	DefaultClusterRoleForProjectMembers                  string                        `json:"defaultClusterRoleForProjectMembers,omitempty" norman:"type=reference[roleTemplate]"`
@@ -43,6 +44,18 @@ type AgentDeploymentCustomization struct {
 	OverrideResourceRequirements *v1.ResourceRequirements `json:"overrideResourceRequirements,omitempty"`
 }
 
+// AgentProxyConfig configures the HTTP(S) proxy the cluster agent uses to open its tunnel to Rancher.
+type AgentProxyConfig struct {
+	// URL of the proxy, either http:// or https://.
+	URL string `json:"url"`
+	// CACerts is a PEM bundle trusted for the proxy and for TLS-intercepting proxies.
+	CACerts string `json:"caCerts,omitempty"`
+	// CredentialSecretName names a kubernetes.io/basic-auth Secret in the namespace of the cluster.
+	CredentialSecretName string `json:"credentialSecretName,omitempty"`
+	// NoProxy lists hosts, domains (.example.com) and CIDRs the agent reaches without the proxy.
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
 type ClusterStatus struct {
 	Ready              bool                                `json:"ready,omitempty"`
 	ClusterName        string                              `json:"clusterName,omitempty"`
@@ -63,6 +63,27 @@ func (in *AgentDeploymentCustomization) DeepCopy() *AgentDeploymentCustomization
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentProxyConfig) DeepCopyInto(out *AgentProxyConfig) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentProxyConfig.
+func (in *AgentProxyConfig) DeepCopy() *AgentProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentProxyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Cluster) DeepCopyInto(out *Cluster) {
 	*out = *in
@@ -174,6 +195,11 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 		*out = new(AgentDeploymentCustomization)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ClusterAgentProxyConfig != nil {
+		in, out := &in.ClusterAgentProxyConfig, &out.ClusterAgentProxyConfig
+		*out = new(AgentProxyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -55,6 +55,9 @@ type ETCDSnapshot struct {
 
 type ETCDSnapshotSpec struct {
 	ClusterName string `json:"clusterName,omitempty"`
+
+	// Changing the VerifyGeneration is the only thing required to initiate a verification of the snapshot.
+	VerifyGeneration int `json:"verifyGeneration,omitempty"`
 }
 
 type ETCDSnapshotFile struct {
@@ -71,6 +74,42 @@ type ETCDSnapshotFile struct {
 
 type ETCDSnapshotStatus struct {
 	Missing bool `json:"missing"`
+	// Verification is the result of the last verification of the snapshot.
+	Verification *ETCDSnapshotVerification `json:"verification,omitempty"`
+}
+
+const (
+	// ETCDSnapshotVerificationVerified means the snapshot was downloaded and is a consistent etcd database.
+	ETCDSnapshotVerificationVerified = "Verified"
+	// ETCDSnapshotVerificationFailed means the snapshot could not be downloaded or is corrupt.
+	ETCDSnapshotVerificationFailed = "Failed"
+	// ETCDSnapshotVerificationUnsupported means the snapshot cannot be verified, e.g. because it is only stored on a node.
+	ETCDSnapshotVerificationUnsupported = "Unsupported"
+)
+
+// ETCDSnapshotVerification records the outcome of verifying the integrity of a snapshot.
+type ETCDSnapshotVerification struct {
+	// Generation is the spec.verifyGeneration this verification was run for.
+	Generation int `json:"generation,omitempty"`
+	// Result is one of Verified, Failed or Unsupported.
+	Result  string `json:"result,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Checksum is the sha256 checksum of the snapshot file as it is stored, in the form "sha256:<hex>".
+	Checksum string `json:"checksum,omitempty"`
+	// Hash is the hash of the keys and values in the database, as reported by "etcdutl snapshot status".
+	Hash uint32 `json:"hash,omitempty"`
+	// Revision is the latest revision stored in the database.
+	Revision int64 `json:"revision,omitempty"`
+	// TotalKeys is the number of keys in the database.
+	TotalKeys int `json:"totalKeys,omitempty"`
+	// VerifiedAt is the time the verification finished.
+	VerifiedAt *metav1.Time `json:"verifiedAt,omitempty"`
+}
+
+// ETCDSnapshotVerificationPolicy configures the periodic verification of the latest snapshot of a cluster.
+type ETCDSnapshotVerificationPolicy struct {
+	// Interval is how often the latest snapshot stored in S3 is verified.
+	Interval metav1.Duration `json:"interval,omitempty"`
 }
 
 type ETCD struct {
@@ -78,4 +117,32 @@ type ETCD struct {
 	SnapshotScheduleCron string          `json:"snapshotScheduleCron,omitempty"`
 	SnapshotRetention    int             `json:"snapshotRetention,omitempty"`
 	S3                   *ETCDSnapshotS3 `json:"s3,omitempty"`
+	// SnapshotVerification enables the periodic verification of the latest snapshot stored in S3.
+	SnapshotVerification *ETCDSnapshotVerificationPolicy `json:"snapshotVerification,omitempty"`
+	// SnapshotTargets is the list of destinations snapshots are stored in, each with its own retention. S3 must be empty
+	// when a target stores snapshots in S3. Snapshots are uploaded to the first S3 target by the etcd nodes and copied to
+	// the other S3 targets. SnapshotRetention is the retention of targets, including the implicit local target, that do
+	// not have a retention of their own.
+	SnapshotTargets []ETCDSnapshotTarget `json:"snapshotTargets,omitempty"`
+}
+
+// ETCDSnapshotTarget is a destination etcd snapshots are stored in.
+type ETCDSnapshotTarget struct {
+	// Name identifies the target and must be unique within the cluster.
+	Name string `json:"name,omitempty"`
+	// S3 is the S3 location snapshots are stored in. Snapshots are stored on the etcd nodes if it is nil.
+	S3 *ETCDSnapshotS3 `json:"s3,omitempty"`
+	// Retention is the retention of the snapshots stored in the target.
+	Retention *ETCDSnapshotRetention `json:"retention,omitempty"`
+}
+
+// ETCDSnapshotRetention is a tiered retention policy. A snapshot is kept if it is one of the Count newest snapshots, or
+// if it is the newest snapshot of one of the last Hourly hours, Daily days, Weekly weeks or Monthly months that have a
+// snapshot.
+type ETCDSnapshotRetention struct {
+	Count   int `json:"count,omitempty"`
+	Hourly  int `json:"hourly,omitempty"`
+	Daily   int `json:"daily,omitempty"`
+	Weekly  int `json:"weekly,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
 }
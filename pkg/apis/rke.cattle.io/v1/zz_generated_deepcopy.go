@@ -219,8 +219,20 @@ func (in *ETCD) DeepCopyInto(out *ETCD) {
 	if in.S3 != nil {
 		in, out := &in.S3, &out.S3
 		*out = new(ETCDSnapshotS3)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotVerification != nil {
+		in, out := &in.SnapshotVerification, &out.SnapshotVerification
+		*out = new(ETCDSnapshotVerificationPolicy)
 		**out = **in
 	}
+	if in.SnapshotTargets != nil {
+		in, out := &in.SnapshotTargets, &out.SnapshotTargets
+		*out = make([]ETCDSnapshotTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -241,7 +253,7 @@ func (in *ETCDSnapshot) DeepCopyInto(out *ETCDSnapshot) {
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
 	in.SnapshotFile.DeepCopyInto(&out.SnapshotFile)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -289,7 +301,7 @@ func (in *ETCDSnapshotFile) DeepCopyInto(out *ETCDSnapshotFile) {
 	if in.S3 != nil {
 		in, out := &in.S3, &out.S3
 		*out = new(ETCDSnapshotS3)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -353,6 +365,22 @@ func (in *ETCDSnapshotRestore) DeepCopy() *ETCDSnapshotRestore {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotRetention) DeepCopyInto(out *ETCDSnapshotRetention) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotRetention.
+func (in *ETCDSnapshotRetention) DeepCopy() *ETCDSnapshotRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshotS3) DeepCopyInto(out *ETCDSnapshotS3) {
 	*out = *in
@@ -388,6 +416,11 @@ func (in *ETCDSnapshotSpec) DeepCopy() *ETCDSnapshotSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshotStatus) DeepCopyInto(out *ETCDSnapshotStatus) {
 	*out = *in
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(ETCDSnapshotVerification)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -401,6 +434,69 @@ func (in *ETCDSnapshotStatus) DeepCopy() *ETCDSnapshotStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotTarget) DeepCopyInto(out *ETCDSnapshotTarget) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(ETCDSnapshotS3)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(ETCDSnapshotRetention)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotTarget.
+func (in *ETCDSnapshotTarget) DeepCopy() *ETCDSnapshotTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotVerification) DeepCopyInto(out *ETCDSnapshotVerification) {
+	*out = *in
+	if in.VerifiedAt != nil {
+		in, out := &in.VerifiedAt, &out.VerifiedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotVerification.
+func (in *ETCDSnapshotVerification) DeepCopy() *ETCDSnapshotVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotVerificationPolicy) DeepCopyInto(out *ETCDSnapshotVerificationPolicy) {
+	*out = *in
+	out.Interval = in.Interval
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotVerificationPolicy.
+func (in *ETCDSnapshotVerificationPolicy) DeepCopy() *ETCDSnapshotVerificationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotVerificationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnvVar) DeepCopyInto(out *EnvVar) {
 	*out = *in
@@ -0,0 +1,158 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/parse"
+	"github.com/rancher/norman/types"
+	"github.com/rancher/rancher/pkg/auth/mfa"
+	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// enrollTOTP starts a TOTP enrollment for the current user and returns its secret. TOTP is only enabled once the
+// enrollment is confirmed with the confirmtotp action.
+func (h *Handler) enrollTOTP(request *types.APIContext) error {
+	user, err := h.localUser(request)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.MFA.BeginEnrollment(user)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		"type":                           client.TOTPEnrollmentType,
+		client.TOTPEnrollmentFieldSecret: enrollment.Secret,
+		client.TOTPEnrollmentFieldURI:    enrollment.URI,
+	})
+	return nil
+}
+
+// confirmTOTP enables TOTP for the current user if the code matches its pending enrollment, and returns its recovery codes.
+func (h *Handler) confirmTOTP(request *types.APIContext) error {
+	user, err := h.localUser(request)
+	if err != nil {
+		return err
+	}
+	code, err := readCode(request)
+	if err != nil {
+		return err
+	}
+
+	if err := h.MFA.ConfirmEnrollment(user.Name, code); err != nil {
+		return mfaError(err)
+	}
+	return h.writeRecoveryCodes(request, user.Name)
+}
+
+// disableTOTP disables TOTP for the current user, which must prove it still has access to its authenticator app or
+// recovery codes.
+func (h *Handler) disableTOTP(request *types.APIContext) error {
+	user, err := h.localUser(request)
+	if err != nil {
+		return err
+	}
+	code, err := readCode(request)
+	if err != nil {
+		return err
+	}
+
+	if err := h.MFA.Verify(user.Name, code); err != nil {
+		return mfaError(err)
+	}
+	if err := h.MFA.Disable(user.Name); err != nil {
+		return err
+	}
+	request.WriteResponse(http.StatusOK, nil)
+	return nil
+}
+
+// regenerateRecoveryCodes replaces the recovery codes of the current user.
+func (h *Handler) regenerateRecoveryCodes(request *types.APIContext) error {
+	user, err := h.localUser(request)
+	if err != nil {
+		return err
+	}
+	code, err := readCode(request)
+	if err != nil {
+		return err
+	}
+
+	if err := h.MFA.Verify(user.Name, code); err != nil {
+		return mfaError(err)
+	}
+	return h.writeRecoveryCodes(request, user.Name)
+}
+
+// resetTOTP disables TOTP for another user, for example one that lost its authenticator app and recovery codes. It
+// requires the permission to update users.
+func (h *Handler) resetTOTP(request *types.APIContext) error {
+	if !h.userCanUpdate(request) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "not allowed to reset the TOTP enrollment of users")
+	}
+	if err := h.MFA.Disable(request.ID); err != nil {
+		return err
+	}
+	request.WriteResponse(http.StatusOK, nil)
+	return nil
+}
+
+func (h *Handler) writeRecoveryCodes(request *types.APIContext, userName string) error {
+	codes, err := h.MFA.GenerateRecoveryCodes(userName)
+	if err != nil {
+		return mfaError(err)
+	}
+	request.WriteResponse(http.StatusOK, map[string]interface{}{
+		"type": client.RecoveryCodesOutputType,
+		client.RecoveryCodesOutputFieldRecoveryCodes: codes,
+	})
+	return nil
+}
+
+// localUser returns the user making the request, which must be able to log in with the local provider.
+func (h *Handler) localUser(request *types.APIContext) (*v3.User, error) {
+	userID := request.Request.Header.Get("Impersonate-User")
+	if userID == "" {
+		return nil, errors.New("can't find user")
+	}
+	user, err := h.UserClient.Get(userID, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if user.Password == "" {
+		return nil, httperror.NewAPIError(httperror.InvalidAction, "TOTP can only be enabled for users that log in with a local password")
+	}
+	return user, nil
+}
+
+func (h *Handler) userCanUpdate(request *types.APIContext) bool {
+	return request.AccessControl.CanDo(v3.UserGroupVersionKind.Group, v3.UserResource.Name, "update", request, nil, request.Schema) == nil
+}
+
+func readCode(request *types.APIContext) (string, error) {
+	actionInput, err := parse.ReadBody(request.Request)
+	if err != nil {
+		return "", err
+	}
+	code, ok := actionInput[client.TOTPCodeInputFieldCode].(string)
+	if !ok || len(code) == 0 {
+		return "", httperror.NewAPIError(httperror.InvalidBodyContent, "must specify code")
+	}
+	return code, nil
+}
+
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		return httperror.NewAPIError(httperror.InvalidBodyContent, err.Error())
+	case errors.Is(err, mfa.ErrAlreadyEnrolled), errors.Is(err, mfa.ErrNotEnrolled), errors.Is(err, mfa.ErrNoEnrollment):
+		return httperror.NewAPIError(httperror.InvalidState, err.Error())
+	}
+	return err
+}
@@ -9,6 +9,7 @@
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/parse"
 	"github.com/rancher/norman/types"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -19,6 +20,12 @@
 
 func (h *Handler) UserFormatter(apiContext *types.APIContext, resource *types.RawResource) {
 	resource.AddAction(apiContext, "setpassword")
+	if h.userCanUpdate(apiContext) {
+		resource.AddAction(apiContext, "resettotp")
+		if resource.Values[client.UserFieldLockedUntil] != nil || resource.Values[client.UserFieldFailedLoginAttempts] != nil {
+			resource.AddAction(apiContext, "unlock")
+		}
+	}
 
 	if canRefresh := h.userCanRefresh(apiContext); canRefresh {
 		resource.AddAction(apiContext, "refreshauthprovideraccess")
@@ -27,6 +34,10 @@ func (h *Handler) UserFormatter(apiContext *types.APIContext, resource *types.Ra
 
 func (h *Handler) CollectionFormatter(apiContext *types.APIContext, collection *types.GenericCollection) {
 	collection.AddAction(apiContext, "changepassword")
+	collection.AddAction(apiContext, "enrolltotp")
+	collection.AddAction(apiContext, "confirmtotp")
+	collection.AddAction(apiContext, "disabletotp")
+	collection.AddAction(apiContext, "regeneraterecoverycodes")
 	if canRefresh := h.userCanRefresh(apiContext); canRefresh {
 		collection.AddAction(apiContext, "refreshauthprovideraccess")
 	}
@@ -36,6 +47,7 @@ type Handler struct {
 	UserClient               v3.UserInterface
 	GlobalRoleBindingsClient v3.GlobalRoleBindingInterface
 	UserAuthRefresher        providerrefresh.UserAuthRefresher
+	MFA                      *mfa.Manager
 }
 
 func (h *Handler) Actions(actionName string, action *types.Action, apiContext *types.APIContext) error {
@@ -52,6 +64,18 @@ func (h *Handler) Actions(actionName string, action *types.Action, apiContext *t
 		if err := h.refreshAttributes(actionName, action, apiContext); err != nil {
 			return err
 		}
+	case "enrolltotp":
+		return h.enrollTOTP(apiContext)
+	case "confirmtotp":
+		return h.confirmTOTP(apiContext)
+	case "disabletotp":
+		return h.disableTOTP(apiContext)
+	case "regeneraterecoverycodes":
+		return h.regenerateRecoveryCodes(apiContext)
+	case "resettotp":
+		return h.resetTOTP(apiContext)
+	case "unlock":
+		return h.unlock(apiContext)
 	default:
 		return errors.Errorf("bad action %v", actionName)
 	}
@@ -162,6 +186,27 @@ func (h *Handler) setPassword(actionName string, action *types.Action, request *
 	return nil
 }
 
+// unlock clears the failed logins of a local user, lifting its lockout. It requires the permission to update users.
+func (h *Handler) unlock(request *types.APIContext) error {
+	if !h.userCanUpdate(request) {
+		return httperror.NewAPIError(httperror.PermissionDenied, "not allowed to unlock users")
+	}
+
+	user, err := h.UserClient.Get(request.ID, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	user.Status.FailedLoginAttempts = 0
+	user.Status.LastFailedLoginTime = nil
+	user.Status.LockedUntil = nil
+	if _, err := h.UserClient.Update(user); err != nil {
+		return err
+	}
+
+	request.WriteResponse(http.StatusOK, nil)
+	return nil
+}
+
 func (h *Handler) refreshAttributes(actionName string, action *types.Action, request *types.APIContext) error {
 	canRefresh := h.userCanRefresh(request)
 
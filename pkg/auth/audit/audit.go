@@ -12,6 +12,7 @@
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -64,6 +65,14 @@ type auditLog struct {
 	writer            *LogWriter
 	reqBody           []byte
 	keysToRedactRegex *regexp.Regexp
+	// decision is set when the request was evaluated against an audit policy and overrides the writer's level.
+	decision *policyDecision
+}
+
+// policyDecision is the outcome of evaluating an audit policy for a single request.
+type policyDecision struct {
+	level       Level
+	redactPaths []string
 }
 
 type log struct {
@@ -120,9 +129,23 @@ func FromContext(ctx context.Context) (*User, bool) {
 	return u, ok
 }
 
+type auditIDKey struct{}
+
+// IDFromContext gets the audit ID of the audit log entry of the request from the given context, for requests that are
+// audited.
+func IDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(auditIDKey{}).(string)
+	return id, ok && id != ""
+}
+
 func newAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp.Regexp) (*auditLog, error) {
+	return newPolicyAuditLog(writer, req, keysToRedactRegex, nil)
+}
+
+func newPolicyAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp.Regexp, decision *policyDecision) (*auditLog, error) {
 	auditLog := &auditLog{
-		writer: writer,
+		writer:   writer,
+		decision: decision,
 		log: &log{
 			AuditID:          k8stypes.UID(uuid.NewRandom().String()),
 			RequestURI:       req.RequestURI,
@@ -132,10 +155,11 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp
 		},
 		keysToRedactRegex: keysToRedactRegex,
 	}
+	level := auditLog.level()
 
 	contentType := req.Header.Get("Content-Type")
 	loginReq := isLoginRequest(req.RequestURI)
-	if writer.Level >= LevelRequest || loginReq {
+	if level >= LevelRequest || loginReq {
 		if bodyMethods[req.Method] && strings.HasPrefix(contentType, contentTypeJSON) {
 			reqBody, err := readBodyWithoutLosingContent(req)
 			if err != nil {
@@ -147,7 +171,7 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp
 					auditLog.log.UserLoginName = loginName
 				}
 			}
-			if writer.Level >= LevelRequest {
+			if level >= LevelRequest {
 				auditLog.reqBody = reqBody
 			}
 		}
@@ -155,6 +179,14 @@ func newAuditLog(writer *LogWriter, req *http.Request, keysToRedactRegex *regexp
 	return auditLog, nil
 }
 
+// level returns the level this request is logged at.
+func (a *auditLog) level() Level {
+	if a.decision != nil {
+		return a.decision.level
+	}
+	return a.writer.Level
+}
+
 func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, resCode int, resBody []byte) error {
 	a.log.User = userInfo
 	a.log.ResponseTimestamp = time.Now().Format(time.RFC3339)
@@ -193,10 +225,7 @@ func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, res
 		return fmt.Errorf("failed to compact audit log: %w", err)
 	}
 
-	compactBuffer.WriteString("\n")
-
-	_, err = a.writer.Output.Write(compactBuffer.Bytes())
-	if err != nil {
+	if err = a.writer.write(compactBuffer.Bytes()); err != nil {
 		return fmt.Errorf("failed to write log to output: %w", err)
 	}
 
@@ -205,7 +234,7 @@ func (a *auditLog) write(userInfo *User, reqHeaders, resHeaders http.Header, res
 
 // writeRequest attempts to write the API request to the log message.
 func (a *auditLog) writeRequest(buf *bytes.Buffer) {
-	if a.writer.Level < LevelRequest || len(a.reqBody) == 0 {
+	if a.level() < LevelRequest || len(a.reqBody) == 0 {
 		return
 	}
 
@@ -215,7 +244,7 @@ func (a *auditLog) writeRequest(buf *bytes.Buffer) {
 
 // writeResponse attempt to write the API response to the log message.
 func (a *auditLog) writeResponse(buf *bytes.Buffer, resHeaders http.Header, resBody []byte) (err error) {
-	if a.writer.Level < LevelRequestResponse || resHeaders.Get("Content-Type") != contentTypeJSON || len(resBody) == 0 {
+	if a.level() < LevelRequestResponse || resHeaders.Get("Content-Type") != contentTypeJSON || len(resBody) == 0 {
 		return nil
 	}
 
@@ -298,6 +327,12 @@ func (a *auditLog) redactSensitiveData(requestURI string, body []byte) []byte {
 		changed = redact(m, "config")
 	}
 
+	if a.decision != nil {
+		for _, path := range a.decision.redactPaths {
+			changed = redactPath(m, splitRedactPath(path)) || changed
+		}
+	}
+
 	// Redact values for data considered sensitive: passwords, tokens, etc.
 	if !a.redactMap(m) && !changed {
 		return body
@@ -318,6 +353,57 @@ func redact(body map[string]interface{}, key string) bool {
 	return true
 }
 
+func splitRedactPath(path string) []string {
+	return strings.Split(strings.Trim(path, "."), ".")
+}
+
+// redactPath redacts the value found by following path through nested maps and lists. A "*" segment matches
+// every key of a map or every element of a list.
+func redactPath(value interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	segment, rest := path[0], path[1:]
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		if segment != wildcard {
+			if _, ok := val[segment]; !ok {
+				return false
+			}
+			if len(rest) == 0 {
+				return redact(val, segment)
+			}
+			return redactPath(val[segment], rest)
+		}
+		var changed bool
+		for key := range val {
+			if len(rest) == 0 {
+				val[key] = redacted
+				changed = true
+				continue
+			}
+			changed = redactPath(val[key], rest) || changed
+		}
+		return changed
+	case []interface{}:
+		var changed bool
+		for i := range val {
+			if segment != wildcard && segment != strconv.Itoa(i) {
+				continue
+			}
+			if len(rest) == 0 {
+				val[i] = redacted
+				changed = true
+				continue
+			}
+			changed = redactPath(val[i], rest) || changed
+		}
+		return changed
+	}
+	return false
+}
+
 func (a *auditLog) redactSecretsData(requestURI string, body map[string]interface{}) bool {
 	var changed bool
 
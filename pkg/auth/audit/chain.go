@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+const chainReadBlockSize = 64 * 1024
+
+// hashSuffix matches the hash field that is always appended as the last field of a chained record.
+var hashSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// hashChain links every record to the one written before it. Each record carries a monotonically
+// increasing sequence number, the hash of the previous record and its own hash, which is a SHA-256
+// or, if a key is configured, an HMAC-SHA256 of the record up to and including prevHash.
+// Removing, reordering or editing a record therefore breaks the chain.
+type hashChain struct {
+	lock     sync.Mutex
+	key      []byte
+	sequence uint64
+	lastHash string
+}
+
+// chainFields are the fields added to every chained record.
+type chainFields struct {
+	Sequence uint64 `json:"sequence"`
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+func newHashChain(key []byte) *hashChain {
+	return &hashChain{key: key}
+}
+
+// resume continues the chain from the last record in the file at path so that restarting
+// the server does not show up as a break in the chain.
+func (c *hashChain) resume(path string) error {
+	line, err := readLastLine(path)
+	if err != nil || len(line) == 0 {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var fields chainFields
+	if err := json.Unmarshal(line, &fields); err != nil || fields.Hash == "" {
+		// The last record was not written with chaining enabled, start a new chain.
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sequence = fields.Sequence
+	c.lastHash = fields.Hash
+	return nil
+}
+
+// write seals record and writes it to out. The lock is held while writing so that records reach
+// the sink in sequence order. The chain advances even if the write fails so that lost records are
+// reported as a gap by the verifier.
+func (c *hashChain) write(out io.Writer, record []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sequence++
+	sealed, sum := sealRecord(c.key, record, c.sequence, c.lastHash)
+	c.lastHash = sum
+
+	_, err := out.Write(sealed)
+	return err
+}
+
+// sealRecord appends the sequence, prevHash and hash fields to a compact JSON object and terminates it with a newline.
+func sealRecord(key, record []byte, sequence uint64, prevHash string) ([]byte, string) {
+	record = bytes.TrimSuffix(bytes.TrimSuffix(record, []byte("\n")), []byte("}"))
+
+	var buf bytes.Buffer
+	buf.Write(record)
+	if len(record) > 1 {
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"sequence":`)
+	buf.WriteString(strconv.FormatUint(sequence, 10))
+	buf.WriteString(`,"prevHash":`)
+	buf.WriteString(strconv.Quote(prevHash))
+	buf.WriteByte('}')
+
+	sum := recordHash(key, buf.Bytes())
+
+	sealed := buf.Bytes()[:buf.Len()-1]
+	sealed = append(sealed, `,"hash":"`...)
+	sealed = append(sealed, sum...)
+	sealed = append(sealed, "\"}\n"...)
+	return sealed, sum
+}
+
+func recordHash(key, data []byte) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readLastLine returns the last non-empty line of the file at path without reading the whole file.
+func readLastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tail   []byte
+		offset = info.Size()
+	)
+	for offset > 0 {
+		size := int64(chainReadBlockSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		block := make([]byte, size)
+		if _, err := f.ReadAt(block, offset); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		tail = append(block, tail...)
+
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+	}
+	return bytes.TrimRight(tail, "\n"), nil
+}
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeChain(t *testing.T, chain *hashChain, path string, from, to int) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	defer f.Close()
+	for i := from; i <= to; i++ {
+		require.NoError(t, chain.write(f, []byte(fmt.Sprintf(`{"auditID":"%d","requestURI":"/v3/users"}`+"\n", i))))
+	}
+}
+
+func TestSealRecord(t *testing.T) {
+	sealed, sum := sealRecord(nil, []byte(`{"auditID":"1"}`+"\n"), 1, "")
+	assert.True(t, bytes.HasSuffix(sealed, []byte("\n")))
+
+	var fields chainFields
+	require.NoError(t, json.Unmarshal(sealed, &fields))
+	assert.Equal(t, uint64(1), fields.Sequence)
+	assert.Equal(t, "", fields.PrevHash)
+	assert.Equal(t, sum, fields.Hash)
+
+	hmacSealed, hmacSum := sealRecord([]byte("secret"), []byte(`{"auditID":"1"}`), 1, "")
+	assert.NotEqual(t, sum, hmacSum)
+	assert.NotEqual(t, sealed, hmacSealed)
+}
+
+func TestVerifyChain(t *testing.T) {
+	key := []byte("secret")
+
+	tests := []struct {
+		name      string
+		tamper    func(lines []string) []string
+		key       []byte
+		wantKinds []ProblemKind
+	}{
+		{
+			name: "intact",
+			key:  key,
+		},
+		{
+			name:      "wrong key",
+			key:       []byte("other"),
+			wantKinds: []ProblemKind{ProblemModified, ProblemModified, ProblemModified, ProblemModified, ProblemModified},
+		},
+		{
+			name: "modified record",
+			key:  key,
+			tamper: func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], "/v3/users", "/v3/tokens", 1)
+				return lines
+			},
+			wantKinds: []ProblemKind{ProblemModified},
+		},
+		{
+			name: "removed record",
+			key:  key,
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantKinds: []ProblemKind{ProblemGap},
+		},
+		{
+			name: "removed hash",
+			key:  key,
+			tamper: func(lines []string) []string {
+				lines[4] = `{"auditID":"5"}`
+				return lines
+			},
+			wantKinds: []ProblemKind{ProblemMalformed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			writeChain(t, newHashChain(key), path, 1, 5)
+
+			if tt.tamper != nil {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				lines := tt.tamper(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+				require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+			}
+
+			result, err := VerifyChain([]string{path}, tt.key)
+			require.NoError(t, err)
+
+			var kinds []ProblemKind
+			for _, p := range result.Problems {
+				kinds = append(kinds, p.Kind)
+			}
+			assert.Equal(t, tt.wantKinds, kinds)
+		})
+	}
+}
+
+func TestHashChainResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeChain(t, newHashChain(nil), path, 1, 3)
+
+	resumed := newHashChain(nil)
+	require.NoError(t, resumed.resume(path))
+	assert.Equal(t, uint64(3), resumed.sequence)
+	writeChain(t, resumed, path, 4, 6)
+
+	result, err := VerifyChain([]string{path}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, 6, result.Records)
+	assert.Equal(t, uint64(6), result.LastSequence)
+
+	// A chain that is not resumed is reported as a restart.
+	writeChain(t, newHashChain(nil), path, 7, 7)
+	result, err = VerifyChain([]string{path}, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Problems, 1)
+	assert.Equal(t, ProblemRestart, result.Problems[0].Kind)
+
+	// Resuming from a missing file starts a new chain.
+	fresh := newHashChain(nil)
+	require.NoError(t, fresh.resume(filepath.Join(t.TempDir(), "missing.log")))
+	assert.Equal(t, uint64(0), fresh.sequence)
+}
+
+func TestLogFilesAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rancher-api-audit.log")
+	older := filepath.Join(dir, "rancher-api-audit-2024-01-01T00-00-00.000.log")
+	newer := filepath.Join(dir, "rancher-api-audit-2024-02-01T00-00-00.000.log.gz")
+
+	chain := newHashChain(nil)
+	writeChain(t, chain, older, 1, 2)
+
+	// Lumberjack compresses backups when configured to, so write the middle file gzipped.
+	plain := filepath.Join(dir, "plain")
+	writeChain(t, chain, plain, 3, 4)
+	data, err := os.ReadFile(plain)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(plain))
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err = gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(newer, buf.Bytes(), 0600))
+
+	writeChain(t, chain, path, 5, 6)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated.log"), []byte("x\n"), 0600))
+
+	files, err := LogFiles(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{older, newer, path}, files)
+
+	result, err := VerifyChain(files, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, 3, result.Files)
+	assert.Equal(t, uint64(1), result.FirstSequence)
+	assert.Equal(t, uint64(6), result.LastSequence)
+
+	// Pruning the oldest backup is not a problem, removing one in the middle is.
+	result, err = VerifyChain([]string{newer, path}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Problems)
+	result, err = VerifyChain([]string{older, path}, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Problems, 1)
+	assert.Equal(t, ProblemGap, result.Problems[0].Kind)
+}
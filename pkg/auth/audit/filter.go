@@ -15,6 +15,7 @@
 
 	"github.com/rancher/rancher/pkg/auth/util"
 	"github.com/rancher/rancher/pkg/data/management"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,6 +32,7 @@ func NewAuditLogMiddleware(auditWriter *LogWriter) (func(http.Handler) http.Hand
 			sanitizingRegex: sensitiveRegex,
 			errMap:          make(map[string]time.Time),
 			errLock:         &sync.Mutex{},
+			policy:          newPolicyCache(settings.AuditLogPolicy.Get),
 		}
 	}, err
 }
@@ -60,6 +62,7 @@ type auditHandler struct {
 	sanitizingRegex *regexp.Regexp
 	errMap          map[string]time.Time
 	errLock         *sync.Mutex
+	policy          *policyCache
 }
 
 func (h auditHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -70,15 +73,27 @@ func (h auditHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	user := getUserInfo(req)
 
-	context := context.WithValue(req.Context(), userKey, user)
-	req = req.WithContext(context)
+	ctx := context.WithValue(req.Context(), userKey, user)
+	req = req.WithContext(ctx)
 
-	auditLog, err := newAuditLog(h.auditWriter, req, h.sanitizingRegex)
+	var decision *policyDecision
+	if policy := h.policy.Policy(); policy != nil && len(policy.Rules)+len(policy.RedactPaths) > 0 {
+		level, redactPaths := policy.evaluate(getRequestAttributes(req, user), h.auditWriter.Level)
+		if level == LevelNull {
+			h.next.ServeHTTP(rw, req)
+			return
+		}
+		decision = &policyDecision{level: level, redactPaths: redactPaths}
+	}
+
+	auditLog, err := newPolicyAuditLog(h.auditWriter, req, h.sanitizingRegex, decision)
 	if err != nil {
 		util.ReturnHTTPError(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	req = req.WithContext(context.WithValue(req.Context(), auditIDKey{}, string(auditLog.log.AuditID)))
+
 	wr := &wrapWriter{ResponseWriter: rw, auditWriter: h.auditWriter, statusCode: http.StatusOK}
 	h.next.ServeHTTP(wr, req)
 
@@ -2,13 +2,59 @@
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
+const (
+	// SinkFile writes audit records to a rotating local file.
+	SinkFile = "file"
+	// SinkStdout writes audit records to the process standard output.
+	SinkStdout = "stdout"
+	// SinkWebhook sends batches of audit records to an HTTP endpoint.
+	SinkWebhook = "webhook"
+	// SinkSyslog sends audit records to a remote syslog server using RFC 5424.
+	SinkSyslog = "syslog"
+)
+
 type LogWriter struct {
 	Level  Level
-	Output *lumberjack.Logger
+	Output Sink
+
+	// lock guards Output, which is replaced when the sinks are reconfigured.
+	lock  sync.RWMutex
+	chain *hashChain
+}
+
+// WriterOptions describes which sinks a LogWriter should write to and how each of them is configured.
+type WriterOptions struct {
+	// Sinks is the list of sink names to enable, e.g. "file", "stdout", "webhook" and "syslog".
+	Sinks []string
+
+	Path      string
+	MaxAge    int
+	MaxBackup int
+	MaxSize   int
+
+	WebhookURL           string
+	WebhookCAFile        string
+	WebhookBatchSize     int
+	WebhookFlushInterval time.Duration
+	WebhookMaxRetries    int
+
+	SyslogAddress string
+	SyslogTLS     bool
+	SyslogCAFile  string
+
+	// HashChain adds a sequence number and a hash linking each record to the previous one.
+	HashChain bool
+	// HMACKey signs the hash chain. Setting it implies HashChain.
+	HMACKey []byte
 }
 
 func (l *LogWriter) Start(ctx context.Context) {
@@ -17,10 +63,44 @@ func (l *LogWriter) Start(ctx context.Context) {
 	}
 	go func() {
 		<-ctx.Done()
+		l.lock.RLock()
+		defer l.lock.RUnlock()
 		l.Output.Close()
 	}()
 }
 
+// write writes a compacted JSON record to the sinks, adding the hash chain fields if chaining is enabled.
+func (l *LogWriter) write(record []byte) error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if l.chain != nil {
+		return l.chain.write(l.Output, record)
+	}
+	_, err := l.Output.Write(append(record, '\n'))
+	return err
+}
+
+// Reconfigure replaces the sinks of the writer with the sinks listed in opts. The level and the hash chain are kept,
+// so chained records continue the same sequence. The current sinks are kept if the new ones cannot be created.
+func (l *LogWriter) Reconfigure(opts WriterOptions) error {
+	if l == nil {
+		return nil
+	}
+	output, _, err := newOutput(opts)
+	if err != nil {
+		return err
+	}
+	if output == nil {
+		return fmt.Errorf("no audit log sinks are configured")
+	}
+
+	l.lock.Lock()
+	previous := l.Output
+	l.Output = output
+	l.lock.Unlock()
+	return previous.Close()
+}
+
 func NewLogWriter(path string, level Level, maxAge, maxBackup, maxSize int) *LogWriter {
 	if path == "" || level == LevelNull {
 		return nil
@@ -36,3 +116,96 @@ func NewLogWriter(path string, level Level, maxAge, maxBackup, maxSize int) *Log
 		},
 	}
 }
+
+// NewLogWriterWithOptions returns a LogWriter that writes to every sink listed in opts.
+// A nil LogWriter is returned if auditing is disabled or no sinks are configured.
+func NewLogWriterWithOptions(level Level, opts WriterOptions) (*LogWriter, error) {
+	if level == LevelNull {
+		return nil, nil
+	}
+
+	output, seen, err := newOutput(opts)
+	if err != nil || output == nil {
+		return nil, err
+	}
+	writer := &LogWriter{Level: level, Output: output}
+
+	if opts.HashChain || len(opts.HMACKey) > 0 {
+		writer.chain = newHashChain(opts.HMACKey)
+		if seen[SinkFile] && opts.Path != "" {
+			if err := writer.chain.resume(opts.Path); err != nil {
+				logrus.Warnf("auditLog: failed to resume hash chain from %s, starting a new chain: %v", opts.Path, err)
+			}
+		}
+	}
+
+	return writer, nil
+}
+
+// newOutput returns a Sink that writes to every sink listed in opts, and the names of those sinks. A nil Sink is
+// returned if no sinks are configured.
+func newOutput(opts WriterOptions) (Sink, map[string]bool, error) {
+	var sinks []Sink
+	seen := map[string]bool{}
+	for _, name := range opts.Sinks {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		sink, err := newSink(name, opts)
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, nil, err
+		}
+		if sink != nil {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	switch len(sinks) {
+	case 0:
+		return nil, seen, nil
+	case 1:
+		return sinks[0], seen, nil
+	default:
+		return NewMultiSink(sinks...), seen, nil
+	}
+}
+
+func newSink(name string, opts WriterOptions) (Sink, error) {
+	switch name {
+	case SinkFile:
+		// An empty path has always meant that file auditing is disabled.
+		if opts.Path == "" {
+			return nil, nil
+		}
+		return &lumberjack.Logger{
+			Filename:   opts.Path,
+			MaxAge:     opts.MaxAge,
+			MaxBackups: opts.MaxBackup,
+			MaxSize:    opts.MaxSize,
+		}, nil
+	case SinkStdout:
+		return NewStdoutSink(), nil
+	case SinkWebhook:
+		return NewWebhookSink(WebhookOptions{
+			URL:           opts.WebhookURL,
+			CAFile:        opts.WebhookCAFile,
+			BatchSize:     opts.WebhookBatchSize,
+			FlushInterval: opts.WebhookFlushInterval,
+			MaxRetries:    opts.WebhookMaxRetries,
+		})
+	case SinkSyslog:
+		return NewSyslogSink(SyslogOptions{
+			Address: opts.SyslogAddress,
+			TLS:     opts.SyslogTLS,
+			CAFile:  opts.SyslogCAFile,
+		})
+	default:
+		return nil, fmt.Errorf("unknown audit log sink %q", name)
+	}
+}
@@ -0,0 +1,234 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rancher/rancher/pkg/auth/requests/attributes"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/utils/strings/slices"
+)
+
+const (
+	// PolicyLevelNone disables auditing for matching requests.
+	PolicyLevelNone = "None"
+	// PolicyLevelMetadata logs request metadata for matching requests.
+	PolicyLevelMetadata = "Metadata"
+	// PolicyLevelRequest logs request metadata and the request body for matching requests.
+	PolicyLevelRequest = "Request"
+	// PolicyLevelRequestResponse logs request metadata, the request body and the response body for matching requests.
+	PolicyLevelRequestResponse = "RequestResponse"
+
+	wildcard = "*"
+)
+
+var (
+	policyLevels = map[string]Level{
+		PolicyLevelNone:            LevelNull,
+		PolicyLevelMetadata:        LevelMetadata,
+		PolicyLevelRequest:         LevelRequest,
+		PolicyLevelRequestResponse: LevelRequestResponse,
+	}
+)
+
+// Policy is a Kubernetes style audit policy. Rules are evaluated in order and the first
+// matching rule determines the audit level of a request. Requests that do not match any
+// rule are logged at the writer's default level.
+type Policy struct {
+	Rules []PolicyRule `json:"rules,omitempty"`
+	// RedactPaths are additional dotted paths to redact from every logged body, see PolicyRule.RedactPaths.
+	RedactPaths []string `json:"redactPaths,omitempty"`
+}
+
+// PolicyRule maps requests matching all of its non-empty selectors to an audit level.
+type PolicyRule struct {
+	// Level is one of None, Metadata, Request or RequestResponse.
+	Level string `json:"level"`
+	// Users matches the authenticated user name.
+	Users []string `json:"users,omitempty"`
+	// UserGroups matches if the user belongs to any of the listed groups.
+	UserGroups []string `json:"userGroups,omitempty"`
+	// Verbs matches the Kubernetes verb of the request, e.g. get, list, watch, create, update, patch or delete.
+	Verbs []string `json:"verbs,omitempty"`
+	// Resources matches the API group and resource of the request.
+	Resources []GroupResources `json:"resources,omitempty"`
+	// URIPrefixes matches requests whose path starts with one of the prefixes.
+	URIPrefixes []string `json:"uriPrefixes,omitempty"`
+	// RedactPaths are dotted paths into the request and response bodies, e.g. "spec.password" or
+	// "items.*.data.token", whose values are redacted. "*" matches any map key or list element.
+	RedactPaths []string `json:"redactPaths,omitempty"`
+}
+
+// GroupResources selects resources in an API group. The core group is "" and "*" matches any group.
+// Resources may be plural resource names, as used by the Kubernetes API, or "*" to match all resources in the group.
+type GroupResources struct {
+	Group     string   `json:"group"`
+	Resources []string `json:"resources,omitempty"`
+}
+
+// requestAttributes is the information about a request that rules are matched against.
+type requestAttributes struct {
+	attributes.Attributes
+	user *User
+}
+
+// ParsePolicy parses a JSON or YAML audit policy and validates its levels.
+func ParsePolicy(data string) (*Policy, error) {
+	policy := &Policy{}
+	if strings.TrimSpace(data) == "" {
+		return policy, nil
+	}
+	jsonData, err := yaml.ToJSON([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audit policy: %w", err)
+	}
+	if err := json.Unmarshal(jsonData, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse audit policy: %w", err)
+	}
+	for i, rule := range policy.Rules {
+		if _, ok := policyLevels[rule.Level]; !ok {
+			return nil, fmt.Errorf("audit policy rule %d has invalid level %q", i, rule.Level)
+		}
+	}
+	return policy, nil
+}
+
+// evaluate returns the level and extra redaction paths for a request. If no rule matches the defaultLevel is returned.
+func (p *Policy) evaluate(attrs *requestAttributes, defaultLevel Level) (Level, []string) {
+	if p == nil {
+		return defaultLevel, nil
+	}
+	for _, rule := range p.Rules {
+		if rule.matches(attrs) {
+			redactPaths := make([]string, 0, len(p.RedactPaths)+len(rule.RedactPaths))
+			redactPaths = append(redactPaths, p.RedactPaths...)
+			return policyLevels[rule.Level], append(redactPaths, rule.RedactPaths...)
+		}
+	}
+	return defaultLevel, p.RedactPaths
+}
+
+func (r *PolicyRule) matches(attrs *requestAttributes) bool {
+	if len(r.Users) > 0 && (attrs.user == nil || !matchesAny(r.Users, attrs.user.Name)) {
+		return false
+	}
+	if len(r.UserGroups) > 0 {
+		if attrs.user == nil {
+			return false
+		}
+		found := false
+		for _, group := range attrs.user.Group {
+			if matchesAny(r.UserGroups, group) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Verbs) > 0 && !matchesAny(r.Verbs, attrs.Verb) {
+		return false
+	}
+	if len(r.URIPrefixes) > 0 {
+		found := false
+		for _, prefix := range r.URIPrefixes {
+			if strings.HasPrefix(attrs.Path, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Resources) > 0 {
+		if attrs.Resource == "" {
+			return false
+		}
+		found := false
+		for _, gr := range r.Resources {
+			if gr.matches(attrs.Group, attrs.Resource) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *GroupResources) matches(group, resource string) bool {
+	if g.Group != wildcard && g.Group != group {
+		return false
+	}
+	if len(g.Resources) == 0 {
+		return true
+	}
+	for _, r := range g.Resources {
+		if r == wildcard || attributes.ResourceMatches(r, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(values []string, value string) bool {
+	return slices.Contains(values, wildcard) || slices.Contains(values, value)
+}
+
+// getRequestAttributes works out the verb, API group and resource of a request.
+func getRequestAttributes(req *http.Request, user *User) *requestAttributes {
+	return &requestAttributes{
+		Attributes: attributes.FromRequest(req),
+		user:       user,
+	}
+}
+
+// policyCache parses the policy setting on change and caches the result so that requests do not
+// pay the parsing cost.
+type policyCache struct {
+	lock   sync.RWMutex
+	raw    string
+	policy *Policy
+	get    func() string
+}
+
+func newPolicyCache(get func() string) *policyCache {
+	return &policyCache{get: get}
+}
+
+func (c *policyCache) Policy() *Policy {
+	if c == nil || c.get == nil {
+		return nil
+	}
+	raw := c.get()
+
+	c.lock.RLock()
+	if raw == c.raw {
+		defer c.lock.RUnlock()
+		return c.policy
+	}
+	c.lock.RUnlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if raw == c.raw {
+		return c.policy
+	}
+	policy, err := ParsePolicy(raw)
+	if err != nil {
+		// Keep auditing with the last valid policy rather than silently dropping records.
+		logrus.Errorf("auditLog: ignoring invalid audit policy: %v", err)
+	} else {
+		c.policy = policy
+	}
+	c.raw = raw
+	return c.policy
+}
@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+redactPaths:
+- spec.secretValue
+rules:
+- level: None
+  resources:
+  - group: ""
+    resources: ["events"]
+- level: Metadata
+  verbs: ["list", "watch"]
+  uriPrefixes: ["/v1/"]
+- level: RequestResponse
+  resources:
+  - group: rbac.authorization.k8s.io
+    resources: ["*"]
+  - group: management.cattle.io
+    resources: ["globalrolebindings", "clusterroletemplatebindings", "projectroletemplatebindings"]
+- level: Request
+  userGroups: ["system:serviceaccounts"]
+  redactPaths:
+  - items.*.data.token
+`
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(testPolicy)
+	require.NoError(t, err)
+	assert.Len(t, policy.Rules, 4)
+	assert.Equal(t, []string{"spec.secretValue"}, policy.RedactPaths)
+
+	policy, err = ParsePolicy("")
+	require.NoError(t, err)
+	assert.Empty(t, policy.Rules)
+
+	_, err = ParsePolicy(`{"rules":[{"level":"Everything"}]}`)
+	assert.Error(t, err)
+
+	_, err = ParsePolicy(`rules: [`)
+	assert.Error(t, err)
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := ParsePolicy(testPolicy)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		method          string
+		uri             string
+		user            *User
+		wantLevel       Level
+		wantRedactPaths []string
+	}{
+		{
+			name:            "events are not audited",
+			method:          http.MethodGet,
+			uri:             "/api/v1/namespaces/default/events",
+			wantLevel:       LevelNull,
+			wantRedactPaths: []string{"spec.secretValue"},
+		},
+		{
+			name:            "steve lists only log metadata",
+			method:          http.MethodGet,
+			uri:             "/v1/rbac.authorization.k8s.io.clusterrolebinding",
+			wantLevel:       LevelMetadata,
+			wantRedactPaths: []string{"spec.secretValue"},
+		},
+		{
+			name:            "rbac changes log the full body",
+			method:          http.MethodPost,
+			uri:             "/v1/rbac.authorization.k8s.io.clusterrolebinding",
+			wantLevel:       LevelRequestResponse,
+			wantRedactPaths: []string{"spec.secretValue"},
+		},
+		{
+			name:            "steve singular kinds match plural policy resources",
+			method:          http.MethodDelete,
+			uri:             "/v1/management.cattle.io.projectroletemplatebinding/p-abcde/prtb-xyz",
+			wantLevel:       LevelRequestResponse,
+			wantRedactPaths: []string{"spec.secretValue"},
+		},
+		{
+			name:            "user groups",
+			method:          http.MethodPost,
+			uri:             "/v1/secret",
+			user:            &User{Name: "system:serviceaccount:default:ci", Group: []string{"system:authenticated", "system:serviceaccounts"}},
+			wantLevel:       LevelRequest,
+			wantRedactPaths: []string{"spec.secretValue", "items.*.data.token"},
+		},
+		{
+			name:            "unmatched requests use the default level",
+			method:          http.MethodPost,
+			uri:             "/v1/secret",
+			user:            &User{Name: "admin", Group: []string{"system:authenticated"}},
+			wantLevel:       LevelMetadata,
+			wantRedactPaths: []string{"spec.secretValue"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.uri, nil)
+			require.NoError(t, err)
+			level, redactPaths := policy.evaluate(getRequestAttributes(req, tt.user), LevelMetadata)
+			assert.Equal(t, tt.wantLevel, level)
+			assert.Equal(t, tt.wantRedactPaths, redactPaths)
+		})
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		input       string
+		want        string
+		wantChanged bool
+	}{
+		{
+			name:        "nested key",
+			path:        "spec.config.apiKey",
+			input:       `{"spec":{"config":{"apiKey":"abc","region":"us"}}}`,
+			want:        `{"spec":{"config":{"apiKey":"[redacted]","region":"us"}}}`,
+			wantChanged: true,
+		},
+		{
+			name:        "wildcard list elements",
+			path:        "items.*.data",
+			input:       `{"items":[{"data":{"a":"b"}},{"name":"x"}]}`,
+			want:        `{"items":[{"data":"[redacted]"},{"name":"x"}]}`,
+			wantChanged: true,
+		},
+		{
+			name:        "list index",
+			path:        "args.1",
+			input:       `{"args":["--flag","value"]}`,
+			want:        `{"args":["--flag","[redacted]"]}`,
+			wantChanged: true,
+		},
+		{
+			name:        "wildcard map keys",
+			path:        "data.*",
+			input:       `{"data":{"a":"1","b":"2"}}`,
+			want:        `{"data":{"a":"[redacted]","b":"[redacted]"}}`,
+			wantChanged: true,
+		},
+		{
+			name:  "missing path",
+			path:  "spec.missing",
+			input: `{"spec":{"present":"yes"}}`,
+			want:  `{"spec":{"present":"yes"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.input), &m))
+			changed := redactPath(m, splitRedactPath(tt.path))
+			assert.Equal(t, tt.wantChanged, changed)
+			got, err := json.Marshal(m)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestPolicyCache(t *testing.T) {
+	raw := `{"rules":[{"level":"None"}]}`
+	cache := newPolicyCache(func() string { return raw })
+
+	policy := cache.Policy()
+	require.NotNil(t, policy)
+	assert.Len(t, policy.Rules, 1)
+
+	// An invalid policy keeps the last valid one in place.
+	raw = `{"rules":[{"level":"bogus"}]}`
+	assert.Same(t, policy, cache.Policy())
+
+	raw = ""
+	assert.Empty(t, cache.Policy().Rules)
+}
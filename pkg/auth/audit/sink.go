@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink is a destination for audit records. Each call to Write receives exactly one
+// newline terminated JSON encoded record.
+type Sink interface {
+	io.Writer
+	io.Closer
+}
+
+type multiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink returns a Sink that writes every record to all of the given sinks.
+// A failure in one sink does not prevent the record from reaching the others.
+func NewMultiSink(sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks}
+}
+
+func (m *multiSink) Write(p []byte) (int, error) {
+	var errs []error
+	for _, s := range m.sinks {
+		if _, err := s.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(p), errors.Join(errs...)
+}
+
+func (m *multiSink) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type stdoutSink struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+// NewStdoutSink returns a Sink that writes records to the process standard output.
+func NewStdoutSink() Sink {
+	return &stdoutSink{out: os.Stdout}
+}
+
+func (s *stdoutSink) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.out.Write(p)
+}
+
+func (s *stdoutSink) Close() error {
+	return nil
+}
+
+// loadCAPool returns a cert pool containing the certificates in caFile, or nil if caFile
+// is empty so that the system roots are used.
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificates found in CA file %s", caFile)
+	}
+	return pool, nil
+}
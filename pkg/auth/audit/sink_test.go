@@ -0,0 +1,285 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSinkBatches(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		batches [][]map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		assert.Equal(t, contentTypeJSON, r.Header.Get("Content-Type"))
+		lock.Lock()
+		batches = append(batches, batch)
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(WebhookOptions{URL: server.URL, BatchSize: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := sink.Write([]byte(`{"auditID":"` + strconv.Itoa(i) + `"}` + "\n"))
+		require.NoError(t, err)
+	}
+	// Close flushes the incomplete final batch.
+	require.NoError(t, sink.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 2)
+	assert.Len(t, batches[1], 1)
+	assert.Equal(t, "2", batches[1][0]["auditID"])
+
+	_, err = sink.Write([]byte(`{}`))
+	assert.ErrorIs(t, err, ErrSinkClosed)
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		attempts int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(WebhookOptions{URL: server.URL, BatchSize: 1, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	sink.(*webhookSink).backoff.Duration = time.Millisecond
+
+	_, err = sink.Write([]byte(`{"auditID":"1"}`))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWebhookSinkDoesNotRetryClientErrors(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		attempts int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(WebhookOptions{URL: server.URL, BatchSize: 1, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	sink.(*webhookSink).backoff.Duration = time.Millisecond
+
+	_, err = sink.Write([]byte(`{"auditID":"1"}`))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 1, attempts)
+}
+
+func TestSyslogSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+			if err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	sink, err := NewSyslogSink(SyslogOptions{Address: listener.Addr().String()})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	for _, record := range []string{`{"auditID":"1"}`, `{"auditID":"2"}`} {
+		_, err = sink.Write([]byte(record + "\n"))
+		require.NoError(t, err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		select {
+		case msg := <-received:
+			assert.True(t, strings.HasPrefix(msg, "<110>1 "), "unexpected header in %q", msg)
+			assert.Contains(t, msg, " rancher ")
+			assert.True(t, strings.HasSuffix(msg, ` audit - {"auditID":"`+id+`"}`), "unexpected message %q", msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for syslog message")
+		}
+	}
+}
+
+func TestSyslogSinkRequiresAddress(t *testing.T) {
+	_, err := NewSyslogSink(SyslogOptions{})
+	assert.Error(t, err)
+	_, err = NewSyslogSink(SyslogOptions{Address: "no-port"})
+	assert.Error(t, err)
+}
+
+type recordingSink struct {
+	records []string
+	err     error
+	closed  bool
+}
+
+func (r *recordingSink) Write(p []byte) (int, error) {
+	r.records = append(r.records, string(p))
+	return len(p), r.err
+}
+
+func (r *recordingSink) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestMultiSink(t *testing.T) {
+	failing := &recordingSink{err: io.ErrClosedPipe}
+	working := &recordingSink{}
+	sink := NewMultiSink(failing, working)
+
+	_, err := sink.Write([]byte("record\n"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	assert.Equal(t, []string{"record\n"}, working.records)
+
+	require.NoError(t, sink.Close())
+	assert.True(t, failing.closed)
+	assert.True(t, working.closed)
+}
+
+func TestNewLogWriterWithOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     Level
+		opts      WriterOptions
+		wantNil   bool
+		wantMulti bool
+		wantErr   bool
+	}{
+		{
+			name:    "disabled level",
+			level:   LevelNull,
+			opts:    WriterOptions{Sinks: []string{SinkStdout}},
+			wantNil: true,
+		},
+		{
+			name:    "file sink without path",
+			level:   LevelMetadata,
+			opts:    WriterOptions{Sinks: []string{SinkFile}},
+			wantNil: true,
+		},
+		{
+			name:  "single sink",
+			level: LevelMetadata,
+			opts:  WriterOptions{Sinks: []string{SinkStdout, " STDOUT "}},
+		},
+		{
+			name:      "combined sinks",
+			level:     LevelMetadata,
+			opts:      WriterOptions{Sinks: []string{SinkStdout, SinkFile}, Path: t.TempDir() + "/audit.log"},
+			wantMulti: true,
+		},
+		{
+			name:    "unknown sink",
+			level:   LevelMetadata,
+			opts:    WriterOptions{Sinks: []string{"carrier-pigeon"}},
+			wantErr: true,
+		},
+		{
+			name:    "webhook without url",
+			level:   LevelMetadata,
+			opts:    WriterOptions{Sinks: []string{SinkWebhook}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, err := NewLogWriterWithOptions(tt.level, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, writer)
+				return
+			}
+			require.NotNil(t, writer)
+			_, isMulti := writer.Output.(*multiSink)
+			assert.Equal(t, tt.wantMulti, isMulti)
+			assert.NoError(t, writer.Output.Close())
+		})
+	}
+}
+
+func TestLogWriterReconfigure(t *testing.T) {
+	previous := &recordingSink{}
+	writer := &LogWriter{Level: LevelMetadata, Output: previous}
+
+	// the current sinks are kept when the new ones cannot be created
+	assert.Error(t, writer.Reconfigure(WriterOptions{Sinks: []string{SinkWebhook}}))
+	assert.Error(t, writer.Reconfigure(WriterOptions{}))
+	assert.Same(t, previous, writer.Output)
+	assert.False(t, previous.closed)
+
+	path := t.TempDir() + "/audit.log"
+	require.NoError(t, writer.Reconfigure(WriterOptions{Sinks: []string{SinkFile}, Path: path}))
+	assert.True(t, previous.closed)
+
+	require.NoError(t, writer.write([]byte(`{"auditID":"1"}`)))
+	require.NoError(t, writer.Output.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"auditID\":\"1\"}\n", string(data))
+	assert.Empty(t, previous.records)
+}
@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// syslogFacilityAudit is the "log audit" facility from RFC 5424.
+	syslogFacilityAudit = 13
+	// syslogSeverityInfo is the "informational" severity from RFC 5424.
+	syslogSeverityInfo = 6
+	syslogAppName      = "rancher"
+	syslogMsgID        = "audit"
+	syslogDialTimeout  = 10 * time.Second
+	syslogWriteTimeout = 10 * time.Second
+)
+
+// SyslogOptions configures a syslog Sink.
+type SyslogOptions struct {
+	// Address is the host:port of the syslog server.
+	Address string
+	// TLS enables RFC 5425 transport over TLS.
+	TLS bool
+	// CAFile is an optional PEM bundle used to verify the server's certificate when TLS is enabled.
+	CAFile string
+}
+
+type syslogSink struct {
+	address   string
+	tlsConfig *tls.Config
+	hostname  string
+	procID    string
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink returns a Sink that sends each record as an RFC 5424 message over TCP, optionally using TLS.
+// Messages are framed using octet counting as described in RFC 6587.
+func NewSyslogSink(opts SyslogOptions) (Sink, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("audit log syslog sink requires an address")
+	}
+	if _, _, err := net.SplitHostPort(opts.Address); err != nil {
+		return nil, fmt.Errorf("invalid audit log syslog address %q: %w", opts.Address, err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &syslogSink{
+		address:  opts.Address,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+
+	if opts.TLS {
+		pool, err := loadCAPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		host, _, _ := net.SplitHostPort(opts.Address)
+		s.tlsConfig = &tls.Config{
+			RootCAs:    pool,
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return s, nil
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	msg := s.format(bytes.TrimSuffix(p, []byte("\n")), time.Now())
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// A broken connection is only detected on write, so try once more with a fresh connection.
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = s.connect(); err != nil {
+			continue
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+		if _, err = s.conn.Write(msg); err == nil {
+			return len(p), nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	return 0, fmt.Errorf("failed to write audit log to syslog server %s: %w", s.address, err)
+}
+
+func (s *syslogSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// connect must be called with the lock held.
+func (s *syslogSink) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.address)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// format builds an octet counted RFC 5424 message:
+// LEN SP <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA SP MSG
+func (s *syslogSink) format(record []byte, now time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "<%d>1 %s %s %s %s %s - ",
+		syslogFacilityAudit*8+syslogSeverityInfo,
+		now.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		syslogAppName,
+		s.procID,
+		syslogMsgID,
+	)
+	msg.Write(record)
+
+	framed := make([]byte, 0, msg.Len()+8)
+	framed = strconv.AppendInt(framed, int64(msg.Len()), 10)
+	framed = append(framed, ' ')
+	return append(framed, msg.Bytes()...)
+}
@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/urfave/cli"
+)
+
+const (
+	// lumberjackTimeFormat is the timestamp lumberjack adds to the name of rotated files.
+	lumberjackTimeFormat = "2006-01-02T15-04-05.000"
+	maxRecordSize        = 64 * 1024 * 1024
+)
+
+// ProblemKind classifies an integrity problem found while verifying a hash chain.
+type ProblemKind string
+
+const (
+	// ProblemMalformed is reported for lines that are not chained audit records.
+	ProblemMalformed ProblemKind = "malformed"
+	// ProblemModified is reported when a record does not match its hash.
+	ProblemModified ProblemKind = "modified"
+	// ProblemGap is reported when records are missing or out of order.
+	ProblemGap ProblemKind = "gap"
+	// ProblemRestart is reported when a new chain was started in the middle of the log.
+	ProblemRestart ProblemKind = "restart"
+)
+
+// Problem describes a single integrity problem.
+type Problem struct {
+	Kind    ProblemKind
+	File    string
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Kind, p.Message)
+}
+
+// VerifyResult summarizes the verification of a set of audit log files.
+type VerifyResult struct {
+	Files         int
+	Records       int
+	FirstSequence uint64
+	LastSequence  uint64
+	Problems      []Problem
+}
+
+// RegisterVerifyCommand registers the verify-audit-log command, which checks the hash chain of
+// the audit log files written by this server.
+func RegisterVerifyCommand() {
+	reexec.Register("/usr/bin/verify-audit-log", verifyAuditLog)
+	reexec.Register("verify-audit-log", verifyAuditLog)
+}
+
+func verifyAuditLog() {
+	app := cli.NewApp()
+	app.Usage = "Verify the hash chain of the audit log and its rotated backups"
+	app.ArgsUsage = "[audit log path]"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "hmac-key-file",
+			EnvVar: "AUDIT_LOG_HMAC_KEY_FILE",
+			Usage:  "File containing the HMAC key the audit log was signed with",
+		},
+	}
+
+	app.Action = func(c *cli.Context) error {
+		path := c.Args().First()
+		if path == "" {
+			path = "/var/log/auditlog/rancher-api-audit.log"
+		}
+
+		var key []byte
+		if keyFile := c.String("hmac-key-file"); keyFile != "" {
+			data, err := os.ReadFile(keyFile)
+			if err != nil {
+				return fmt.Errorf("failed to read HMAC key: %w", err)
+			}
+			key = []byte(strings.TrimSpace(string(data)))
+		}
+
+		files, err := LogFiles(path)
+		if err != nil {
+			return err
+		}
+		result, err := VerifyChain(files, key)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range result.Problems {
+			fmt.Fprintln(os.Stdout, p.String())
+		}
+		fmt.Fprintf(os.Stdout, "verified %d records in %d files, sequence %d to %d, %d problems\n",
+			result.Records, result.Files, result.FirstSequence, result.LastSequence, len(result.Problems))
+		if len(result.Problems) > 0 {
+			return cli.NewExitError("audit log integrity check failed", 1)
+		}
+		return nil
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// LogFiles returns the audit log at path together with the backups lumberjack rotated it into,
+// ordered from oldest to newest.
+func LogFiles(path string) ([]string, error) {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log directory: %w", err)
+	}
+
+	type backup struct {
+		name      string
+		timestamp string
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		timestamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		if len(timestamp) != len(lumberjackTimeFormat) {
+			continue
+		}
+		backups = append(backups, backup{name: name, timestamp: timestamp})
+	}
+	// The timestamp format sorts lexically in time order.
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp < backups[j].timestamp
+	})
+
+	files := make([]string, 0, len(backups)+1)
+	for _, b := range backups {
+		files = append(files, filepath.Join(dir, b.name))
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no audit log files found for %s", path)
+	}
+	return files, nil
+}
+
+// VerifyChain checks that the records in files, ordered from oldest to newest, form an unbroken hash chain.
+// The first record is allowed to start part way through a chain since older backups may have been pruned.
+func VerifyChain(files []string, key []byte) (*VerifyResult, error) {
+	result := &VerifyResult{}
+	var (
+		prevHash string
+		prevSeq  uint64
+		started  bool
+	)
+
+	for _, file := range files {
+		err := readRecords(file, func(line int, record []byte) {
+			problem := func(kind ProblemKind, format string, args ...interface{}) {
+				result.Problems = append(result.Problems, Problem{Kind: kind, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+			}
+
+			match := hashSuffix.FindSubmatchIndex(record)
+			if match == nil {
+				problem(ProblemMalformed, "record has no hash")
+				return
+			}
+			var fields chainFields
+			if err := json.Unmarshal(record, &fields); err != nil {
+				problem(ProblemMalformed, "invalid JSON: %v", err)
+				return
+			}
+			result.Records++
+
+			// The hash covers the record as it was before the hash field was appended.
+			unsealed := append(append([]byte{}, record[:match[0]]...), '}')
+			if !hmac.Equal([]byte(recordHash(key, unsealed)), []byte(fields.Hash)) {
+				problem(ProblemModified, "hash of record %d does not match its contents", fields.Sequence)
+			}
+
+			switch {
+			case !started:
+				result.FirstSequence = fields.Sequence
+			case fields.Sequence == 1 && fields.PrevHash == "":
+				problem(ProblemRestart, "a new chain was started after record %d", prevSeq)
+			case fields.Sequence != prevSeq+1:
+				problem(ProblemGap, "expected record %d but found record %d", prevSeq+1, fields.Sequence)
+			case fields.PrevHash != prevHash:
+				problem(ProblemGap, "record %d does not link to the hash of record %d", fields.Sequence, prevSeq)
+			}
+
+			started = true
+			prevSeq = fields.Sequence
+			prevHash = fields.Hash
+			result.LastSequence = fields.Sequence
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Files++
+	}
+
+	return result, nil
+}
+
+func readRecords(file string, fn func(line int, record []byte)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", file, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, chainReadBlockSize), maxRecordSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		fn(line, scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultWebhookBatchSize     = 100
+	defaultWebhookFlushInterval = 5 * time.Second
+	defaultWebhookMaxRetries    = 5
+	defaultWebhookQueueSize     = 10000
+	webhookTimeout              = 30 * time.Second
+)
+
+var (
+	// ErrSinkClosed is returned when writing to a sink that has already been closed.
+	ErrSinkClosed = errors.New("audit log sink is closed")
+	// ErrWebhookQueueFull is returned when the webhook sink cannot keep up with the rate of audit records.
+	ErrWebhookQueueFull = errors.New("audit webhook queue is full, dropping record")
+)
+
+// WebhookOptions configures a webhook Sink.
+type WebhookOptions struct {
+	// URL is the endpoint batches of records are POSTed to.
+	URL string
+	// CAFile is an optional PEM bundle used to verify the endpoint's certificate.
+	CAFile string
+	// BatchSize is the maximum number of records sent in a single request.
+	BatchSize int
+	// FlushInterval is the maximum amount of time a record is buffered before being sent.
+	FlushInterval time.Duration
+	// MaxRetries is the number of attempts made to deliver a batch before it is dropped.
+	MaxRetries int
+}
+
+type webhookSink struct {
+	url           string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	backoff       wait.Backoff
+
+	lock    sync.RWMutex
+	closed  bool
+	records chan []byte
+	done    chan struct{}
+}
+
+// NewWebhookSink returns a Sink that buffers records and sends them as a JSON array to an HTTP endpoint.
+// Failed deliveries are retried with exponential backoff.
+func NewWebhookSink(opts WebhookOptions) (Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("audit log webhook sink requires a URL")
+	}
+	pool, err := loadCAPool(opts.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if pool != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultWebhookBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultWebhookFlushInterval
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultWebhookMaxRetries
+	}
+
+	w := &webhookSink{
+		url:           opts.URL,
+		client:        &http.Client{Transport: transport, Timeout: webhookTimeout},
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		backoff: wait.Backoff{
+			Duration: 500 * time.Millisecond,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    opts.MaxRetries,
+			Cap:      time.Minute,
+		},
+		records: make(chan []byte, defaultWebhookQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write queues the record for delivery. It never blocks on the network, records are dropped
+// if the queue is full.
+func (w *webhookSink) Write(p []byte) (int, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		return 0, ErrSinkClosed
+	}
+
+	record := bytes.TrimSuffix(bytes.Clone(p), []byte("\n"))
+	select {
+	case w.records <- record:
+		return len(p), nil
+	default:
+		return 0, ErrWebhookQueueFull
+	}
+}
+
+// Close stops accepting records and flushes everything that is still buffered.
+func (w *webhookSink) Close() error {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.records)
+	w.lock.Unlock()
+
+	<-w.done
+	return nil
+}
+
+func (w *webhookSink) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.send(batch); err != nil {
+			logrus.Errorf("auditLog: dropping %d audit records: %v", len(batch), err)
+		}
+		batch = make([][]byte, 0, w.batchSize)
+	}
+
+	for {
+		select {
+		case record, ok := <-w.records:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (w *webhookSink) send(batch [][]byte) error {
+	body := make([]byte, 0, 2)
+	body = append(body, '[')
+	body = append(body, bytes.Join(batch, []byte(","))...)
+	body = append(body, ']')
+
+	var lastErr error
+	err := wait.ExponentialBackoff(w.backoff, func() (bool, error) {
+		var retry bool
+		retry, lastErr = w.post(body)
+		if lastErr == nil {
+			return true, nil
+		}
+		if !retry {
+			return false, lastErr
+		}
+		logrus.Debugf("auditLog: failed to send audit records to webhook, retrying: %v", lastErr)
+		return false, nil
+	})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("failed to send audit records to webhook after %d attempts: %w", w.backoff.Steps, lastErr)
+	}
+	return err
+}
+
+// post sends a single request to the webhook. The returned bool reports whether a failed request is worth retrying.
+func (w *webhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+}
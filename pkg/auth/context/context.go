@@ -1,6 +1,10 @@
 package auth
 
-import "context"
+import (
+	"context"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+)
 
 // saAuthenticatedContextKey is the context key for the SAAuthenticated flag.
 type saAuthenticatedContextKey struct{}
@@ -17,3 +21,17 @@ func IsSAAuthenticated(ctx context.Context) bool {
 func SetSAAuthenticated(ctx context.Context) context.Context {
 	return context.WithValue(ctx, saContextKey, true)
 }
+
+// tokenScopesContextKey is the context key for the scopes of the token a request was authenticated with.
+type tokenScopesContextKey struct{}
+
+// TokenScopes returns the scopes of the token the request was authenticated with, if it is scoped.
+func TokenScopes(ctx context.Context) []v3.TokenScope {
+	scopes, _ := ctx.Value(tokenScopesContextKey{}).([]v3.TokenScope)
+	return scopes
+}
+
+// SetTokenScopes records the scopes of the token the request was authenticated with in the context.
+func SetTokenScopes(ctx context.Context, scopes []v3.TokenScope) context.Context {
+	return context.WithValue(ctx, tokenScopesContextKey{}, scopes)
+}
@@ -3,6 +3,7 @@
 import (
 	"github.com/rancher/rancher/pkg/auth/providers/activedirectory"
 	"github.com/rancher/rancher/pkg/auth/providers/azure"
+	"github.com/rancher/rancher/pkg/auth/providers/genericoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/github"
 	"github.com/rancher/rancher/pkg/auth/providers/googleoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/keycloakoidc"
@@ -71,6 +72,10 @@ func AuthConfigs(management *config.ManagementContext) error {
 		return err
 	}
 
+	if err := addAuthConfig(genericoauth.Name, client.GenericOAuthConfigType, false, management); err != nil {
+		return err
+	}
+
 	return addAuthConfig(localprovider.Name, client.LocalConfigType, true, management)
 }
 
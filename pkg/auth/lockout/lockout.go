@@ -0,0 +1,329 @@
+// Package lockout protects password logins against brute-force attacks by tracking failed attempts per user and per
+// client address.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/rancher/pkg/auth/audit"
+	"github.com/rancher/rancher/pkg/auth/providers/local"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// auditExtraKey is the extra user info of the audit log recording why a login was rejected or locked a user.
+	auditExtraKey = "loginlockout"
+
+	minBackoff = time.Second
+	// maxMemoryStates bounds the number of users and addresses tracked in memory. States that expired are pruned
+	// when it is reached.
+	maxMemoryStates = 10000
+)
+
+// LoginLocked is the code of the errors of logins rejected because of too many failed attempts.
+var LoginLocked = httperror.ErrorCode{Code: "LoginLocked", Status: http.StatusTooManyRequests}
+
+// Attempt identifies a password login.
+type Attempt struct {
+	Provider string
+	Username string
+	// IP is the address of the client, or empty if it is unknown.
+	IP string
+}
+
+// state is the failed login state of a user or client address.
+type state struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// stateStore persists the states of users by key.
+type stateStore interface {
+	get(key string) (state, bool, error)
+	set(key string, s state) error
+}
+
+// Manager tracks failed password logins. Local users are tracked on their status, so that their lockout applies to
+// every Rancher replica and can be lifted with the unlock action. Users of other providers, unknown local users and
+// client addresses are tracked in memory and only expire.
+type Manager struct {
+	localUsers stateStore
+	users      *memoryStore
+	sources    *memoryStore
+	now        func() time.Time
+
+	// locks serializes the updates of the state of each user and client address so that concurrent failures are all
+	// counted, without serializing the logins of different users.
+	locks keyLocks
+}
+
+func NewManager(mgmt *config.ScaledContext) *Manager {
+	userInformer := mgmt.Management.Users("").Controller().Informer()
+	if err := userInformer.AddIndexers(map[string]cache.IndexFunc{userByUsernameIndex: userByUsername}); err != nil {
+		logrus.Fatalf("[lockout] Failed to add the users by username index: %v", err)
+	}
+	return &Manager{
+		localUsers: &localUserStore{
+			users:       mgmt.Management.Users(""),
+			userIndexer: userInformer.GetIndexer(),
+		},
+		users:   newMemoryStore(),
+		sources: newMemoryStore(),
+		now:     time.Now,
+	}
+}
+
+// Check returns a LoginLocked error if the attempt must be rejected without checking its credentials, because its user
+// or client address is locked or because the user must wait longer before its next attempt.
+func (m *Manager) Check(ctx context.Context, a Attempt) error {
+	now := m.now()
+	lockoutDuration := lockoutDuration()
+
+	if maxAttempts := settings.LoginSourceMaxFailedAttempts.GetInt(); maxAttempts > 0 && a.IP != "" {
+		s, _, _ := m.sources.get(a.IP)
+		s = current(s, now, lockoutDuration)
+		if s.failures >= maxAttempts {
+			return m.reject(ctx, a, "source-locked", s.lastFailure.Add(lockoutDuration).Sub(now))
+		}
+	}
+
+	if settings.LoginMaxFailedAttempts.GetInt() <= 0 {
+		return nil
+	}
+	s, err := m.userState(a)
+	if err != nil {
+		return err
+	}
+	if s.lockedUntil.After(now) {
+		return m.reject(ctx, a, "user-locked", s.lockedUntil.Sub(now))
+	}
+	s = current(s, now, lockoutDuration)
+	if s.failures > 0 {
+		if retryAt := s.lastFailure.Add(backoff(s.failures, lockoutDuration)); retryAt.After(now) {
+			return m.reject(ctx, a, "backoff", retryAt.Sub(now))
+		}
+	}
+	return nil
+}
+
+// Record records the outcome of an attempt that was authenticated with err. Only errors of invalid credentials count
+// as failures; other errors, such as a missing MFA code after a valid password, do not change the state.
+func (m *Manager) Record(ctx context.Context, a Attempt, err error) {
+	switch {
+	case err == nil:
+		m.succeeded(a)
+	case isInvalidCredentials(err):
+		m.failed(ctx, a)
+	}
+}
+
+func (m *Manager) succeeded(a Attempt) {
+	if settings.LoginMaxFailedAttempts.GetInt() <= 0 {
+		return
+	}
+
+	unlock := m.locks.lock(userLockKey(a))
+	defer unlock()
+
+	s, err := m.userState(a)
+	if err != nil {
+		logrus.Errorf("[lockout] Failed to get the failed logins of user %s: %v", a.Username, err)
+		return
+	}
+	if s == (state{}) {
+		return
+	}
+	if err := m.setUserState(a, state{}); err != nil {
+		logrus.Errorf("[lockout] Failed to reset the failed logins of user %s: %v", a.Username, err)
+	}
+}
+
+func (m *Manager) failed(ctx context.Context, a Attempt) {
+	now := m.now()
+	lockoutDuration := lockoutDuration()
+	m.sourceFailed(ctx, a, now, lockoutDuration)
+
+	maxAttempts := settings.LoginMaxFailedAttempts.GetInt()
+	if maxAttempts <= 0 {
+		return
+	}
+
+	unlock := m.locks.lock(userLockKey(a))
+	defer unlock()
+
+	s, err := m.userState(a)
+	if err != nil {
+		logrus.Errorf("[lockout] Failed to get the failed logins of user %s: %v", a.Username, err)
+		return
+	}
+	s = current(s, now, lockoutDuration)
+	s.failures++
+	s.lastFailure = now
+	if s.failures >= maxAttempts {
+		logrus.Warnf("[lockout] Locking user %s of provider %s for %s after %d failed logins", a.Username, a.Provider, lockoutDuration, s.failures)
+		annotateAudit(ctx, "user-locked")
+		s.failures = 0
+		s.lockedUntil = now.Add(lockoutDuration)
+	}
+	if err := m.setUserState(a, s); err != nil {
+		logrus.Errorf("[lockout] Failed to record the failed login of user %s: %v", a.Username, err)
+	}
+	m.users.prune(now, lockoutDuration)
+}
+
+func (m *Manager) sourceFailed(ctx context.Context, a Attempt, now time.Time, lockoutDuration time.Duration) {
+	maxAttempts := settings.LoginSourceMaxFailedAttempts.GetInt()
+	if maxAttempts <= 0 || a.IP == "" {
+		return
+	}
+
+	unlock := m.locks.lock("source/" + a.IP)
+	defer unlock()
+
+	s, _, _ := m.sources.get(a.IP)
+	s = current(s, now, lockoutDuration)
+	s.failures++
+	s.lastFailure = now
+	if s.failures == maxAttempts {
+		logrus.Warnf("[lockout] Rejecting logins from %s after %d failed attempts", a.IP, s.failures)
+		annotateAudit(ctx, "source-locked")
+	}
+	m.sources.set(a.IP, s)
+	m.sources.prune(now, lockoutDuration)
+}
+
+func (m *Manager) reject(ctx context.Context, a Attempt, reason string, retryAfter time.Duration) error {
+	logrus.Debugf("[lockout] Rejecting login of user %s from %s: %s", a.Username, a.IP, reason)
+	annotateAudit(ctx, reason)
+	retryAfter = retryAfter.Round(time.Second)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return httperror.NewAPIError(LoginLocked, fmt.Sprintf("too many failed login attempts, try again in %s", retryAfter))
+}
+
+// userState returns the state of the user of the attempt, from its status for local users that exist.
+func (m *Manager) userState(a Attempt) (state, error) {
+	if a.Provider == local.Name {
+		s, ok, err := m.localUsers.get(a.Username)
+		if err != nil || ok {
+			return s, err
+		}
+	}
+	s, _, err := m.users.get(memoryKey(a))
+	return s, err
+}
+
+func (m *Manager) setUserState(a Attempt, s state) error {
+	if a.Provider == local.Name {
+		if _, ok, err := m.localUsers.get(a.Username); err != nil {
+			return err
+		} else if ok {
+			return m.localUsers.set(a.Username, s)
+		}
+	}
+	return m.users.set(memoryKey(a), s)
+}
+
+func memoryKey(a Attempt) string {
+	return a.Provider + "/" + strings.ToLower(a.Username)
+}
+
+func userLockKey(a Attempt) string {
+	return "user/" + memoryKey(a)
+}
+
+// keyLocks is a set of mutexes by key. The mutex of a key is only kept while it is held or waited for.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// lock locks the mutex of the key and returns the function that unlocks it.
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyLock{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.waiters++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		l.waiters--
+		if l.waiters == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// current returns the state without the failures that are older than the lockout duration.
+func current(s state, now time.Time, lockoutDuration time.Duration) state {
+	if s.failures > 0 && now.Sub(s.lastFailure) >= lockoutDuration {
+		s.failures = 0
+	}
+	return s
+}
+
+// backoff returns the delay after the last of the given number of consecutive failures before the next attempt is
+// allowed. It doubles with every failure and is at most the lockout duration.
+func backoff(failures int, lockoutDuration time.Duration) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < lockoutDuration; i++ {
+		d *= 2
+	}
+	if d > lockoutDuration {
+		return lockoutDuration
+	}
+	return d
+}
+
+func lockoutDuration() time.Duration {
+	d, err := time.ParseDuration(settings.LoginLockoutDuration.Get())
+	if err != nil || d <= 0 {
+		logrus.Warnf("[lockout] Invalid %s setting %q, using %s", settings.LoginLockoutDuration.Name, settings.LoginLockoutDuration.Get(), settings.LoginLockoutDuration.Default)
+		d, _ = time.ParseDuration(settings.LoginLockoutDuration.Default)
+	}
+	return d
+}
+
+func isInvalidCredentials(err error) bool {
+	var apiErr *httperror.APIError
+	return errors.As(err, &apiErr) && apiErr.Code.Code == httperror.Unauthorized.Code
+}
+
+// annotateAudit records the reason in the audit log of the login request.
+func annotateAudit(ctx context.Context, reason string) {
+	auditUser, ok := audit.FromContext(ctx)
+	if !ok {
+		return
+	}
+	if auditUser.Extra == nil {
+		auditUser.Extra = map[string][]string{}
+	}
+	auditUser.Extra[auditExtraKey] = append(auditUser.Extra[auditExtraKey], reason)
+}
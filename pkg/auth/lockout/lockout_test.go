@@ -0,0 +1,223 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rancher/norman/httperror"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var invalidCredentials = httperror.NewAPIError(httperror.Unauthorized, "authentication failed")
+
+// localStore is a stateStore of local users that exist.
+type localStore map[string]state
+
+func (s localStore) get(username string) (state, bool, error) {
+	st, ok := s[username]
+	return st, ok, nil
+}
+
+func (s localStore) set(username string, st state) error {
+	s[username] = st
+	return nil
+}
+
+func setSettings(t *testing.T, maxAttempts, sourceMaxAttempts, duration string) {
+	t.Helper()
+	for setting, value := range map[*settings.Setting]string{
+		&settings.LoginMaxFailedAttempts:       maxAttempts,
+		&settings.LoginSourceMaxFailedAttempts: sourceMaxAttempts,
+		&settings.LoginLockoutDuration:         duration,
+	} {
+		original := setting.Get()
+		setting := setting
+		t.Cleanup(func() {
+			require.NoError(t, setting.Set(original))
+		})
+		require.NoError(t, setting.Set(value))
+	}
+}
+
+func newTestManager(now *time.Time, localUsers localStore) *Manager {
+	return &Manager{
+		localUsers: localUsers,
+		users:      newMemoryStore(),
+		sources:    newMemoryStore(),
+		now:        func() time.Time { return *now },
+	}
+}
+
+func requireLocked(t *testing.T, err error) {
+	t.Helper()
+	var apiErr *httperror.APIError
+	require.True(t, errors.As(err, &apiErr), "expected a LoginLocked error, got %v", err)
+	assert.Equal(t, LoginLocked, apiErr.Code)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1, time.Minute))
+	assert.Equal(t, 2*time.Second, backoff(2, time.Minute))
+	assert.Equal(t, 32*time.Second, backoff(6, time.Minute))
+	assert.Equal(t, time.Minute, backoff(7, time.Minute))
+	assert.Equal(t, time.Minute, backoff(100, time.Minute))
+}
+
+func TestUserLockout(t *testing.T) {
+	setSettings(t, "3", "0", "15m")
+	now := time.Unix(1700000000, 0)
+	localUsers := localStore{"admin": {}}
+	m := newTestManager(&now, localUsers)
+	ctx := context.Background()
+
+	for _, a := range []Attempt{
+		{Provider: "local", Username: "admin"},
+		{Provider: "local", Username: "unknown"},
+		{Provider: "openldap", Username: "jane"},
+	} {
+		t.Run(a.Provider+"/"+a.Username, func(t *testing.T) {
+			require.NoError(t, m.Check(ctx, a))
+			m.Record(ctx, a, invalidCredentials)
+
+			// the next attempt is delayed
+			requireLocked(t, m.Check(ctx, a))
+			now = now.Add(time.Second)
+			require.NoError(t, m.Check(ctx, a))
+			m.Record(ctx, a, invalidCredentials)
+			now = now.Add(time.Second)
+			requireLocked(t, m.Check(ctx, a))
+			now = now.Add(time.Second)
+			require.NoError(t, m.Check(ctx, a))
+
+			// the third failure locks the user
+			m.Record(ctx, a, invalidCredentials)
+			now = now.Add(10 * time.Minute)
+			requireLocked(t, m.Check(ctx, a))
+			now = now.Add(5 * time.Minute)
+			require.NoError(t, m.Check(ctx, a))
+
+			// a successful login resets the failures
+			m.Record(ctx, a, invalidCredentials)
+			now = now.Add(time.Minute)
+			m.Record(ctx, a, nil)
+			require.NoError(t, m.Check(ctx, a))
+		})
+	}
+	assert.Equal(t, state{}, localUsers["admin"], "the state of existing local users must be kept on the user")
+	assert.Empty(t, m.users.states)
+}
+
+func TestOldFailuresExpire(t *testing.T) {
+	setSettings(t, "3", "0", "15m")
+	now := time.Unix(1700000000, 0)
+	m := newTestManager(&now, localStore{})
+	a := Attempt{Provider: "activedirectory", Username: "jane"}
+
+	m.Record(context.Background(), a, invalidCredentials)
+	m.Record(context.Background(), a, invalidCredentials)
+	now = now.Add(15 * time.Minute)
+	m.Record(context.Background(), a, invalidCredentials)
+	assert.Equal(t, 1, m.users.states[memoryKey(a)].failures)
+}
+
+func TestOnlyInvalidCredentialsAreFailures(t *testing.T) {
+	setSettings(t, "1", "1", "15m")
+	now := time.Unix(1700000000, 0)
+	m := newTestManager(&now, localStore{})
+	a := Attempt{Provider: "local", Username: "admin", IP: "10.0.0.1"}
+
+	m.Record(context.Background(), a, httperror.NewAPIError(httperror.ErrorCode{Code: "MFARequired", Status: 401}, "code required"))
+	m.Record(context.Background(), a, errors.New("ldap server unavailable"))
+	require.NoError(t, m.Check(context.Background(), a))
+}
+
+func TestConcurrentFailuresAreCounted(t *testing.T) {
+	setSettings(t, "100", "100", "15m")
+	now := time.Unix(1700000000, 0)
+	m := newTestManager(&now, localStore{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.Record(context.Background(), Attempt{Provider: "openldap", Username: "jane", IP: "10.0.0.1"}, invalidCredentials)
+			m.Record(context.Background(), Attempt{Provider: "openldap", Username: fmt.Sprintf("user%d", i), IP: "10.0.0.2"}, invalidCredentials)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 20, m.users.states["openldap/jane"].failures)
+	assert.Equal(t, 20, m.sources.states["10.0.0.1"].failures)
+	assert.Equal(t, 20, m.sources.states["10.0.0.2"].failures)
+	assert.Empty(t, m.locks.locks, "the locks of users and addresses must be released")
+}
+
+func TestLocalUserStore(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{userByUsernameIndex: userByUsername})
+	require.NoError(t, indexer.Add(&v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-admin"},
+		Username:   "admin",
+		Password:   "hash",
+		Status:     v32.UserStatus{FailedLoginAttempts: 2},
+	}))
+	require.NoError(t, indexer.Add(&v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-external"},
+		Username:   "jane",
+	}))
+	s := &localUserStore{userIndexer: indexer}
+
+	st, ok, err := s.get("admin")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, st.failures)
+
+	_, ok, err = s.get("jane")
+	require.NoError(t, err)
+	assert.False(t, ok, "users without a password are not local users")
+}
+
+func TestSourceLockout(t *testing.T) {
+	setSettings(t, "0", "3", "15m")
+	now := time.Unix(1700000000, 0)
+	m := newTestManager(&now, localStore{})
+
+	ctx := context.Background()
+	for _, username := range []string{"a", "b", "c"} {
+		a := Attempt{Provider: "local", Username: username, IP: "10.0.0.1"}
+		require.NoError(t, m.Check(ctx, a))
+		m.Record(ctx, a, invalidCredentials)
+	}
+
+	requireLocked(t, m.Check(ctx, Attempt{Provider: "local", Username: "d", IP: "10.0.0.1"}))
+	require.NoError(t, m.Check(ctx, Attempt{Provider: "local", Username: "d", IP: "10.0.0.2"}))
+	require.NoError(t, m.Check(ctx, Attempt{Provider: "local", Username: "d"}), "attempts without address are not tracked by address")
+
+	now = now.Add(15 * time.Minute)
+	require.NoError(t, m.Check(ctx, Attempt{Provider: "local", Username: "d", IP: "10.0.0.1"}))
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newMemoryStore()
+	for i := 0; i < maxMemoryStates-1; i++ {
+		require.NoError(t, s.set(time.Duration(i).String(), state{failures: 1, lastFailure: now.Add(-time.Hour)}))
+	}
+	require.NoError(t, s.set("recent", state{failures: 1, lastFailure: now}))
+	require.NoError(t, s.set("locked", state{lockedUntil: now.Add(time.Minute), lastFailure: now.Add(-time.Hour)}))
+
+	s.prune(now, 15*time.Minute)
+	assert.Len(t, s.states, 2)
+	assert.Contains(t, s.states, "recent")
+	assert.Contains(t, s.states, "locked")
+}
@@ -0,0 +1,123 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+const userByUsernameIndex = "auth.management.cattle.io/lockout-user-by-username"
+
+// memoryStore keeps states in memory.
+type memoryStore struct {
+	mu     sync.Mutex
+	states map[string]state
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{states: map[string]state{}}
+}
+
+func (s *memoryStore) get(key string) (state, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[key]
+	return st, ok, nil
+}
+
+func (s *memoryStore) set(key string, st state) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st == (state{}) {
+		delete(s.states, key)
+		return nil
+	}
+	s.states[key] = st
+	return nil
+}
+
+// prune removes the states that no longer affect logins once the store reached its maximum size.
+func (s *memoryStore) prune(now time.Time, lockoutDuration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.states) < maxMemoryStates {
+		return
+	}
+	for key, st := range s.states {
+		if now.Sub(st.lastFailure) >= lockoutDuration && !st.lockedUntil.After(now) {
+			delete(s.states, key)
+		}
+	}
+}
+
+// localUserStore keeps the states of local users on their status, keyed by username.
+type localUserStore struct {
+	users       v3.UserInterface
+	userIndexer cache.Indexer
+}
+
+func (s *localUserStore) get(username string) (state, bool, error) {
+	user, err := s.getUser(username)
+	if err != nil || user == nil {
+		return state{}, false, err
+	}
+	st := state{failures: user.Status.FailedLoginAttempts}
+	if user.Status.LastFailedLoginTime != nil {
+		st.lastFailure = user.Status.LastFailedLoginTime.Time
+	}
+	if user.Status.LockedUntil != nil {
+		st.lockedUntil = user.Status.LockedUntil.Time
+	}
+	return st, true, nil
+}
+
+func (s *localUserStore) set(username string, st state) error {
+	cached, err := s.getUser(username)
+	if err != nil || cached == nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		user, err := s.users.Get(cached.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		user.Status.FailedLoginAttempts = st.failures
+		user.Status.LastFailedLoginTime = optionalTime(st.lastFailure)
+		user.Status.LockedUntil = optionalTime(st.lockedUntil)
+		_, err = s.users.Update(user)
+		return err
+	})
+}
+
+// getUser returns the user with the given username, or nil if there is none.
+func (s *localUserStore) getUser(username string) (*v3.User, error) {
+	objs, err := s.userIndexer.ByIndex(userByUsernameIndex, username)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if user, ok := obj.(*v3.User); ok && user.Password != "" {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func userByUsername(obj interface{}) ([]string, error) {
+	user, ok := obj.(*v3.User)
+	if !ok || user.Username == "" {
+		return nil, nil
+	}
+	return []string{user.Username}, nil
+}
+
+func optionalTime(t time.Time) *metav1.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &metav1.Time{Time: t}
+}
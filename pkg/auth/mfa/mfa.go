@@ -0,0 +1,354 @@
+// Package mfa implements TOTP multi-factor authentication for the users of the local auth provider.
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/norman/httperror"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	secretNamespace = "cattle-system"
+	secretPrefix    = "mfa-"
+
+	secretKey        = "totpSecret"
+	pendingSecretKey = "pendingTotpSecret"
+	lastStepKey      = "totpLastStep"
+	recoveryCodesKey = "recoveryCodes"
+
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+
+	// RequiredNone, RequiredAdmins and RequiredAll are the values of the local-mfa-required setting.
+	RequiredNone   = "none"
+	RequiredAdmins = "admins"
+	RequiredAll    = "all"
+)
+
+var (
+	ErrNotEnrolled     = errors.New("TOTP is not enabled for the user")
+	ErrAlreadyEnrolled = errors.New("TOTP is already enabled for the user")
+	ErrNoEnrollment    = errors.New("no TOTP enrollment was started for the user")
+	ErrInvalidCode     = errors.New("invalid MFA code")
+
+	recoveryCodeSeparators = strings.NewReplacer("-", "", " ", "")
+
+	// MFARequired is the code of login errors of users that enabled TOTP and did not send a code.
+	MFARequired = httperror.ErrorCode{Code: "MFARequired", Status: http.StatusUnauthorized}
+	// MFAEnrollmentRequired is the code of login errors of users that must use MFA and did not enroll yet.
+	MFAEnrollmentRequired = httperror.ErrorCode{Code: "MFAEnrollmentRequired", Status: http.StatusUnauthorized}
+)
+
+// SecretClient is the part of the secrets client used to store the MFA state of users. The state is read from and
+// written to the API server directly, rather than through a cache, and written with the resource version it was read
+// with, so that a code can only be used once even by concurrent logins on different replicas.
+type SecretClient interface {
+	Get(namespace, name string, opts metav1.GetOptions) (*corev1.Secret, error)
+	Create(secret *corev1.Secret) (*corev1.Secret, error)
+	Update(secret *corev1.Secret) (*corev1.Secret, error)
+	Delete(namespace, name string, opts *metav1.DeleteOptions) error
+}
+
+// Manager manages the TOTP enrollment, recovery codes and MFA policy of local users. The state of every user is kept
+// in a secret in the cattle-system namespace.
+type Manager struct {
+	secrets   SecretClient
+	grbLister v3.GlobalRoleBindingLister
+	grLister  v3.GlobalRoleLister
+	now       func() time.Time
+}
+
+func NewManager(mgmt *config.ScaledContext) *Manager {
+	return &Manager{
+		secrets:   mgmt.Wrangler.Core.Secret(),
+		grbLister: mgmt.Management.GlobalRoleBindings("").Controller().Lister(),
+		grLister:  mgmt.Management.GlobalRoles("").Controller().Lister(),
+		now:       time.Now,
+	}
+}
+
+// state is the MFA state of a user, along with the secret it was read from, which is nil if the user has no state yet.
+type state struct {
+	userName string
+	secret   *corev1.Secret
+	data     map[string]string
+}
+
+func (m *Manager) get(userName string) (*state, error) {
+	secret, err := m.secrets.Get(secretNamespace, secretPrefix+userName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return &state{userName: userName, data: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MFA state of user %s: %w", userName, err)
+	}
+	data := map[string]string{}
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+	return &state{userName: userName, secret: secret, data: data}, nil
+}
+
+// save writes the changes to the state of the user, removing the keys set to an empty value. The write fails with a
+// conflict if the state changed since it was read.
+func (m *Manager) save(s *state, changes map[string]string) error {
+	secret := s.secret
+	if secret == nil {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretPrefix + s.userName,
+				Namespace: secretNamespace,
+			},
+		}
+	} else {
+		secret = secret.DeepCopy()
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range changes {
+		if v == "" {
+			delete(secret.Data, k)
+		} else {
+			secret.Data[k] = []byte(v)
+		}
+	}
+
+	var err error
+	if s.secret == nil {
+		_, err = m.secrets.Create(secret)
+		if apierrors.IsAlreadyExists(err) {
+			return apierrors.NewConflict(corev1.Resource("secrets"), secret.Name, err)
+		}
+	} else {
+		_, err = m.secrets.Update(secret)
+	}
+	return err
+}
+
+// IsEnrolled returns true if the user confirmed a TOTP enrollment.
+func (m *Manager) IsEnrolled(userName string) (bool, error) {
+	s, err := m.get(userName)
+	if err != nil {
+		return false, err
+	}
+	return s.data[secretKey] != "", nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user, replacing any enrollment that was not confirmed yet. The
+// secret is only used to log in once the enrollment is confirmed with ConfirmEnrollment.
+func (m *Manager) BeginEnrollment(user *v3.User) (*v32.TOTPEnrollment, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := m.get(user.Name)
+		if err != nil {
+			return err
+		}
+		if s.data[secretKey] != "" {
+			return ErrAlreadyEnrolled
+		}
+		return m.save(s, map[string]string{pendingSecretKey: secret})
+	})
+	if err != nil {
+		if errors.Is(err, ErrAlreadyEnrolled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to store TOTP secret of user %s: %w", user.Name, err)
+	}
+	return &v32.TOTPEnrollment{Secret: secret, URI: enrollmentURI(user.Username, secret)}, nil
+}
+
+// ConfirmEnrollment enables TOTP for the user if the code is valid for the secret of its pending enrollment.
+func (m *Manager) ConfirmEnrollment(userName, code string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := m.get(userName)
+		if err != nil {
+			return err
+		}
+		if s.data[secretKey] != "" {
+			return ErrAlreadyEnrolled
+		}
+		secret := s.data[pendingSecretKey]
+		if secret == "" {
+			return ErrNoEnrollment
+		}
+		step, ok, err := validateTOTP(secret, code, m.now(), 0)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		if err := m.save(s, map[string]string{
+			secretKey:        secret,
+			pendingSecretKey: "",
+			lastStepKey:      strconv.FormatInt(step, 10),
+		}); err != nil {
+			return err
+		}
+		logrus.Infof("[mfa] Enabled TOTP for user %s", userName)
+		return nil
+	})
+}
+
+// Verify checks the TOTP code or one of the recovery codes of an enrolled user. TOTP codes cannot be reused and recovery
+// codes are removed once used. Concurrent uses of the same code conflict when saving the state, and are checked again
+// against the new state, so only one of them succeeds.
+func (m *Manager) Verify(userName, code string) error {
+	code = strings.TrimSpace(code)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := m.get(userName)
+		if err != nil {
+			return err
+		}
+		secret := s.data[secretKey]
+		if secret == "" {
+			return ErrNotEnrolled
+		}
+
+		if isTOTPCode(code) {
+			lastStep, _ := strconv.ParseInt(s.data[lastStepKey], 10, 64)
+			step, ok, err := validateTOTP(secret, code, m.now(), lastStep)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrInvalidCode
+			}
+			return m.save(s, map[string]string{lastStepKey: strconv.FormatInt(step, 10)})
+		}
+
+		hashes := splitHashes(s.data[recoveryCodesKey])
+		hash := hashRecoveryCode(code)
+		for i, h := range hashes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+				hashes = slices.Delete(hashes, i, i+1)
+				if err := m.save(s, map[string]string{recoveryCodesKey: strings.Join(hashes, ",")}); err != nil {
+					return err
+				}
+				logrus.Infof("[mfa] User %s logged in with a recovery code, %d recovery codes left", userName, len(hashes))
+				return nil
+			}
+		}
+		return ErrInvalidCode
+	})
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of an enrolled user and returns the new codes. Only the hashes of the
+// codes are stored, so they cannot be shown again.
+func (m *Manager) GenerateRecoveryCodes(userName string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(secretEncoding.EncodeToString(b))[:recoveryCodeLength]
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := m.get(userName)
+		if err != nil {
+			return err
+		}
+		if s.data[secretKey] == "" {
+			return ErrNotEnrolled
+		}
+		return m.save(s, map[string]string{recoveryCodesKey: strings.Join(hashes, ",")})
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotEnrolled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to store recovery codes of user %s: %w", userName, err)
+	}
+	return codes, nil
+}
+
+// Disable removes the TOTP enrollment and the recovery codes of the user.
+func (m *Manager) Disable(userName string) error {
+	logrus.Infof("[mfa] Disabling TOTP for user %s", userName)
+	err := m.secrets.Delete(secretNamespace, secretPrefix+userName, &metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// IsRequired returns true if the local-mfa-required setting requires the user to log in with a second factor.
+func (m *Manager) IsRequired(userName string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(settings.LocalMFARequired.Get())) {
+	case RequiredAll:
+		return true, nil
+	case RequiredAdmins:
+		return m.isAdmin(userName)
+	default:
+		return false, nil
+	}
+}
+
+// isAdmin returns true if the user is bound to a global role that allows all verbs on all resources.
+func (m *Manager) isAdmin(userName string) (bool, error) {
+	grbs, err := m.grbLister.List("", labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	now := m.now()
+	for _, grb := range grbs {
+		if grb.UserName != userName || pkgrbac.IsGRBExpired(grb, now) {
+			continue
+		}
+		gr, err := m.grLister.Get("", grb.GlobalRoleName)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		for _, rule := range gr.Rules {
+			if slices.Contains(rule.APIGroups, "*") && slices.Contains(rule.Resources, "*") && slices.Contains(rule.Verbs, "*") {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(recoveryCodeSeparators.Replace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+func splitHashes(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
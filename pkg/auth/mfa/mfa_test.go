@@ -0,0 +1,256 @@
+package mfa
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeSecrets stores secrets in memory and rejects updates of secrets that changed since they were read, like the API
+// server. beforeUpdate, if set, is called before every update.
+type fakeSecrets struct {
+	secrets      map[string]*corev1.Secret
+	beforeUpdate func()
+}
+
+func newFakeSecrets() *fakeSecrets {
+	return &fakeSecrets{secrets: map[string]*corev1.Secret{}}
+}
+
+func (f *fakeSecrets) Get(namespace, name string, _ metav1.GetOptions) (*corev1.Secret, error) {
+	secret, ok := f.secrets[namespace+"/"+name]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("secrets"), name)
+	}
+	return secret.DeepCopy(), nil
+}
+
+func (f *fakeSecrets) Create(secret *corev1.Secret) (*corev1.Secret, error) {
+	key := secret.Namespace + "/" + secret.Name
+	if _, ok := f.secrets[key]; ok {
+		return nil, apierrors.NewAlreadyExists(corev1.Resource("secrets"), secret.Name)
+	}
+	secret = secret.DeepCopy()
+	secret.ResourceVersion = "1"
+	f.secrets[key] = secret
+	return secret.DeepCopy(), nil
+}
+
+func (f *fakeSecrets) Update(secret *corev1.Secret) (*corev1.Secret, error) {
+	if f.beforeUpdate != nil {
+		beforeUpdate := f.beforeUpdate
+		f.beforeUpdate = nil
+		beforeUpdate()
+	}
+	key := secret.Namespace + "/" + secret.Name
+	current, ok := f.secrets[key]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("secrets"), secret.Name)
+	}
+	if current.ResourceVersion != secret.ResourceVersion {
+		return nil, apierrors.NewConflict(corev1.Resource("secrets"), secret.Name, errors.New("the object has been modified"))
+	}
+	version, _ := strconv.Atoi(current.ResourceVersion)
+	secret = secret.DeepCopy()
+	secret.ResourceVersion = strconv.Itoa(version + 1)
+	f.secrets[key] = secret
+	return secret.DeepCopy(), nil
+}
+
+func (f *fakeSecrets) Delete(namespace, name string, _ *metav1.DeleteOptions) error {
+	delete(f.secrets, namespace+"/"+name)
+	return nil
+}
+
+func (f *fakeSecrets) data(userName string) map[string]string {
+	data := map[string]string{}
+	if secret, ok := f.secrets[secretNamespace+"/"+secretPrefix+userName]; ok {
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+	}
+	return data
+}
+
+func TestEnrollment(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secrets := newFakeSecrets()
+	m := &Manager{secrets: secrets, now: func() time.Time { return now }}
+	user := &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-abc"}, Username: "jane"}
+
+	enrolled, err := m.IsEnrolled(user.Name)
+	require.NoError(t, err)
+	assert.False(t, enrolled)
+
+	_, err = m.GenerateRecoveryCodes(user.Name)
+	assert.ErrorIs(t, err, ErrNotEnrolled)
+	assert.ErrorIs(t, m.ConfirmEnrollment(user.Name, "123456"), ErrNoEnrollment)
+
+	enrollment, err := m.BeginEnrollment(user)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "Rancher:jane")
+
+	enrolled, err = m.IsEnrolled(user.Name)
+	require.NoError(t, err)
+	assert.False(t, enrolled, "the enrollment must be confirmed")
+
+	assert.ErrorIs(t, m.ConfirmEnrollment(user.Name, "000000"), ErrInvalidCode)
+	code, err := totpCode(enrollment.Secret, totpStep(now))
+	require.NoError(t, err)
+	require.NoError(t, m.ConfirmEnrollment(user.Name, code))
+
+	enrolled, err = m.IsEnrolled(user.Name)
+	require.NoError(t, err)
+	assert.True(t, enrolled)
+	assert.NotContains(t, secrets.data(user.Name), pendingSecretKey)
+
+	_, err = m.BeginEnrollment(user)
+	assert.ErrorIs(t, err, ErrAlreadyEnrolled)
+
+	// the code used to confirm the enrollment cannot be used to log in
+	assert.ErrorIs(t, m.Verify(user.Name, code), ErrInvalidCode)
+
+	now = now.Add(totpPeriod * time.Second)
+	code, err = totpCode(enrollment.Secret, totpStep(now))
+	require.NoError(t, err)
+	require.NoError(t, m.Verify(user.Name, code))
+	assert.ErrorIs(t, m.Verify(user.Name, code), ErrInvalidCode, "codes cannot be used twice")
+
+	require.NoError(t, m.Disable(user.Name))
+	enrolled, err = m.IsEnrolled(user.Name)
+	require.NoError(t, err)
+	assert.False(t, enrolled)
+	assert.ErrorIs(t, m.Verify(user.Name, code), ErrNotEnrolled)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	secrets := newFakeSecrets()
+	m := &Manager{secrets: secrets, now: time.Now}
+	require.NoError(t, m.save(&state{userName: "u-abc"}, map[string]string{secretKey: rfcSecret}))
+
+	codes, err := m.GenerateRecoveryCodes("u-abc")
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	for _, code := range codes {
+		assert.Len(t, code, recoveryCodeLength+1)
+		assert.NotContains(t, secrets.data("u-abc")[recoveryCodesKey], code, "only hashes of the codes must be stored")
+	}
+
+	// codes are accepted without their separator and in any case
+	require.NoError(t, m.Verify("u-abc", strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+	assert.ErrorIs(t, m.Verify("u-abc", codes[0]), ErrInvalidCode, "recovery codes cannot be used twice")
+	require.NoError(t, m.Verify("u-abc", codes[1]))
+
+	newCodes, err := m.GenerateRecoveryCodes("u-abc")
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Verify("u-abc", codes[2]), ErrInvalidCode, "regenerating the codes must invalidate the previous ones")
+	require.NoError(t, m.Verify("u-abc", newCodes[2]))
+}
+
+func TestVerifyConcurrentReplicas(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secrets := newFakeSecrets()
+	// each replica has its own manager, sharing only the secrets of the API server
+	replica1 := &Manager{secrets: secrets, now: func() time.Time { return now }}
+	replica2 := &Manager{secrets: secrets, now: func() time.Time { return now }}
+	require.NoError(t, replica1.save(&state{userName: "u-abc"}, map[string]string{secretKey: rfcSecret}))
+	codes, err := replica1.GenerateRecoveryCodes("u-abc")
+	require.NoError(t, err)
+
+	code, err := totpCode(rfcSecret, totpStep(now))
+	require.NoError(t, err)
+	require.NoError(t, replica1.Verify("u-abc", code))
+	assert.ErrorIs(t, replica2.Verify("u-abc", code), ErrInvalidCode, "a code used on another replica must be rejected")
+
+	// the second use of the code is checked while the first one is being saved
+	now = now.Add(totpPeriod * time.Second)
+	code, err = totpCode(rfcSecret, totpStep(now))
+	require.NoError(t, err)
+	var concurrentErr error
+	secrets.beforeUpdate = func() {
+		concurrentErr = replica2.Verify("u-abc", code)
+	}
+	err = replica1.Verify("u-abc", code)
+	require.NoError(t, concurrentErr)
+	assert.ErrorIs(t, err, ErrInvalidCode, "a code used concurrently must only be accepted once")
+
+	secrets.beforeUpdate = func() {
+		concurrentErr = replica2.Verify("u-abc", codes[0])
+	}
+	err = replica1.Verify("u-abc", codes[0])
+	require.NoError(t, concurrentErr)
+	assert.ErrorIs(t, err, ErrInvalidCode, "a recovery code used concurrently must only be accepted once")
+}
+
+func TestIsRequired(t *testing.T) {
+	original := settings.LocalMFARequired.Get()
+	t.Cleanup(func() {
+		require.NoError(t, settings.LocalMFARequired.Set(original))
+	})
+
+	now := time.Unix(1700000000, 0)
+	globalRoles := map[string]*v3.GlobalRole{
+		"admin": {
+			ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+		"user": {
+			ObjectMeta: metav1.ObjectMeta{Name: "user"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"management.cattle.io"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+	}
+	m := &Manager{
+		grbLister: &fakes.GlobalRoleBindingListerMock{
+			ListFunc: func(namespace string, selector labels.Selector) ([]*v3.GlobalRoleBinding, error) {
+				return []*v3.GlobalRoleBinding{
+					{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, UserName: "u-admin", GlobalRoleName: "admin"},
+					{ObjectMeta: metav1.ObjectMeta{Name: "user"}, UserName: "u-user", GlobalRoleName: "user"},
+					{ObjectMeta: metav1.ObjectMeta{Name: "deleted"}, UserName: "u-user", GlobalRoleName: "deleted"},
+					{ObjectMeta: metav1.ObjectMeta{Name: "expired", CreationTimestamp: metav1.Time{Time: now.Add(-2 * time.Hour)}}, UserName: "u-former-admin", GlobalRoleName: "admin", TTL: "1h"},
+				}, nil
+			},
+		},
+		grLister: &fakes.GlobalRoleListerMock{
+			GetFunc: func(namespace, name string) (*v3.GlobalRole, error) {
+				if gr, ok := globalRoles[name]; ok {
+					return gr, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "globalroles"}, name)
+			},
+		},
+		now: func() time.Time { return now },
+	}
+
+	tests := []struct {
+		setting string
+		user    string
+		want    bool
+	}{
+		{setting: RequiredNone, user: "u-admin", want: false},
+		{setting: RequiredAll, user: "u-user", want: true},
+		{setting: RequiredAdmins, user: "u-admin", want: true},
+		{setting: RequiredAdmins, user: "u-user", want: false},
+		{setting: RequiredAdmins, user: "u-former-admin", want: false},
+		{setting: "", user: "u-admin", want: false},
+	}
+	for _, tt := range tests {
+		require.NoError(t, settings.LocalMFARequired.Set(tt.setting))
+		got, err := m.IsRequired(tt.user)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "setting %q, user %s", tt.setting, tt.user)
+	}
+}
@@ -0,0 +1,102 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// totpPeriod is the lifetime of a TOTP code in seconds.
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is the number of periods before and after the current one whose codes are still accepted, to tolerate
+	// clock drift between Rancher and the authenticator app.
+	totpSkew   = 1
+	secretSize = 20
+	issuer     = "Rancher"
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newSecret returns a random base32 encoded TOTP secret.
+func newSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return secretEncoding.EncodeToString(b), nil
+}
+
+// totpStep returns the RFC 6238 time step of t.
+func totpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// totpCode returns the code of the base32 encoded secret for the given time step, as defined by RFC 4226 and RFC 6238
+// with HMAC-SHA1, which is the only algorithm supported by all common authenticator apps.
+func totpCode(secret string, step int64) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// validateTOTP returns the time step of the code if it is valid at now. Codes of lastStep and earlier are rejected so
+// that a code cannot be used twice.
+func validateTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool, error) {
+	if !isTOTPCode(code) {
+		return 0, false, nil
+	}
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	_, err := strconv.ParseUint(code, 10, 32)
+	return err == nil
+}
+
+// enrollmentURI returns the otpauth URI of the secret, which authenticator apps import from a QR code.
+func enrollmentURI(account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(totpDigits))
+	query.Set("period", strconv.Itoa(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + query.Encode()
+}
@@ -0,0 +1,85 @@
+package mfa
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the base32 encoding of the SHA1 secret "12345678901234567890" of the RFC 6238 test vectors.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfcSecret, totpStep(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "time %d", tt.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := totpStep(now)
+	codeAt := func(step int64) string {
+		code, err := totpCode(rfcSecret, step)
+		require.NoError(t, err)
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current code", code: codeAt(step), wantStep: step, wantOK: true},
+		{name: "previous code", code: codeAt(step - 1), wantStep: step - 1, wantOK: true},
+		{name: "next code", code: codeAt(step + 1), wantStep: step + 1, wantOK: true},
+		{name: "expired code", code: codeAt(step - 2)},
+		{name: "used code", code: codeAt(step), lastStep: step},
+		{name: "code older than the last used code", code: codeAt(step - 1), lastStep: step},
+		{name: "wrong code", code: "000000"},
+		{name: "not a code", code: "abcdef"},
+		{name: "too short", code: "12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok, err := validateTOTP(rfcSecret, tt.code, now, tt.lastStep)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantStep, gotStep)
+		})
+	}
+}
+
+func TestEnrollmentURI(t *testing.T) {
+	secret, err := newSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	u, err := url.Parse(enrollmentURI("jane doe", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Rancher:jane doe", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "Rancher", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}
@@ -0,0 +1,147 @@
+package genericoauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/types"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+)
+
+func (g *genericOAuthProvider) formatter(apiContext *types.APIContext, resource *types.RawResource) {
+	common.AddCommonActions(apiContext, resource)
+	resource.AddAction(apiContext, "configureTest")
+	resource.AddAction(apiContext, "testAndApply")
+}
+
+func (g *genericOAuthProvider) actionHandler(actionName string, action *types.Action, request *types.APIContext) error {
+	handled, err := common.HandleCommonAction(actionName, action, request, Name, g.authConfigs)
+	if err != nil {
+		return err
+	}
+	if handled {
+		return nil
+	}
+
+	if actionName == "configureTest" {
+		return g.configureTest(actionName, action, request)
+	} else if actionName == "testAndApply" {
+		return g.testAndApply(actionName, action, request)
+	}
+
+	return httperror.NewAPIError(httperror.ActionNotAvailable, "")
+}
+
+func (g *genericOAuthProvider) configureTest(actionName string, action *types.Action, request *types.APIContext) error {
+	config := &v32.GenericOAuthConfig{}
+	if err := json.NewDecoder(request.Request.Body).Decode(config); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent,
+			fmt.Sprintf("Failed to parse body: %v", err))
+	}
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"redirectUrl": redirectURL(config.AuthEndpoint, config.ClientID, config.RancherURL, config.Scopes),
+		"type":        client.GenericOAuthConfigTestOutputType,
+	}
+	request.WriteResponse(http.StatusOK, data)
+	return nil
+}
+
+func (g *genericOAuthProvider) testAndApply(actionName string, action *types.Action, request *types.APIContext) error {
+	applyInput := &v32.GenericOAuthConfigApplyInput{}
+	if err := json.NewDecoder(request.Request.Body).Decode(applyInput); err != nil {
+		return httperror.NewAPIError(httperror.InvalidBodyContent,
+			fmt.Sprintf("Failed to parse body: %v", err))
+	}
+	config := applyInput.GenericOAuthConfig
+	if err := validateConfig(&config); err != nil {
+		return err
+	}
+	login := &v32.GenericOAuthLogin{
+		Code: applyInput.Code,
+	}
+
+	if config.ClientSecret != "" {
+		value, err := common.ReadFromSecret(g.secrets, config.ClientSecret,
+			strings.ToLower(client.GenericOAuthConfigFieldClientSecret))
+		if err != nil {
+			return err
+		}
+		config.ClientSecret = value
+	}
+
+	userPrincipal, groupPrincipals, providerToken, err := g.loginUser(request.Request.Context(), login, &config, true)
+	if err != nil {
+		if httperror.IsAPIError(err) {
+			return err
+		}
+		return errors.Wrap(err, "server error while authenticating")
+	}
+
+	user, err := g.userMGR.SetPrincipalOnCurrentUser(request, userPrincipal)
+	if err != nil {
+		return err
+	}
+
+	config.Enabled = applyInput.Enabled
+	if err := g.saveGenericOAuthConfig(&config); err != nil {
+		return httperror.NewAPIError(httperror.ServerError, fmt.Sprintf("Failed to save generic oauth config: %v", err))
+	}
+
+	userExtraInfo := g.GetUserExtraAttributes(userPrincipal)
+
+	return g.tokenMGR.CreateTokenAndSetCookie(user.Name, userPrincipal, groupPrincipals, providerToken, 0, "Token via Generic OAuth Configuration", request, userExtraInfo)
+}
+
+// validateConfig checks that the endpoints of the config are absolute URLs.
+func validateConfig(config *v32.GenericOAuthConfig) error {
+	endpoints := []struct {
+		field, value string
+		required     bool
+	}{
+		{client.GenericOAuthConfigFieldAuthEndpoint, config.AuthEndpoint, true},
+		{client.GenericOAuthConfigFieldTokenEndpoint, config.TokenEndpoint, true},
+		{client.GenericOAuthConfigFieldUserInfoEndpoint, config.UserInfoEndpoint, true},
+		{client.GenericOAuthConfigFieldGroupsEndpoint, config.GroupsEndpoint, false},
+		{client.GenericOAuthConfigFieldUserSearchEndpoint, config.UserSearchEndpoint, false},
+		{client.GenericOAuthConfigFieldGroupSearchEndpoint, config.GroupSearchEndpoint, false},
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.value == "" {
+			if endpoint.required {
+				return httperror.NewFieldAPIError(httperror.MissingRequired, endpoint.field, "")
+			}
+			continue
+		}
+		u, err := url.Parse(searchURL(endpoint.value, ""))
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return httperror.NewFieldAPIError(httperror.InvalidFormat, endpoint.field, "must be an absolute URL")
+		}
+	}
+	return nil
+}
+
+func redirectURL(authEndpoint, clientID, rancherURL, scopes string) string {
+	query := url.Values{}
+	query.Set("client_id", clientID)
+	query.Set("response_type", "code")
+	query.Set("redirect_uri", rancherURL)
+	if scopes = strings.Join(strings.Fields(scopes), " "); scopes != "" {
+		query.Set("scope", scopes)
+	}
+	separator := "?"
+	if strings.Contains(authEndpoint, "?") {
+		separator = "&"
+	}
+	return authEndpoint + separator + query.Encode()
+}
@@ -0,0 +1,255 @@
+package genericoauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultUserIDField           = "sub"
+	defaultUserNameField         = "preferred_username"
+	defaultDisplayNameField      = "name"
+	defaultGroupIDField          = "id"
+	defaultGroupDisplayNameField = "name"
+
+	// queryPlaceholder is replaced by the search value in the search endpoints.
+	queryPlaceholder = "{query}"
+
+	maxResponseSize = 10 << 20
+)
+
+// account is a user or group of the provider, read from the provider's responses with the fields of the config.
+type account struct {
+	ID             string
+	LoginName      string
+	DisplayName    string
+	ProfilePicture string
+}
+
+// oauthClient calls the endpoints of a generic OAuth2 provider.
+type oauthClient struct {
+	config     *v32.GenericOAuthConfig
+	httpClient *http.Client
+}
+
+func newClient(config *v32.GenericOAuthConfig) (*oauthClient, error) {
+	httpClient := &http.Client{}
+	if config.Certificate != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(config.Certificate)) {
+			return nil, fmt.Errorf("invalid certificate")
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig.RootCAs = pool
+		httpClient.Transport = transport
+	}
+	return &oauthClient{config: config, httpClient: httpClient}, nil
+}
+
+// context returns a context that the oauth2 package uses the HTTP client of the provider with.
+func (c *oauthClient) context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, c.httpClient)
+}
+
+func (c *oauthClient) oauthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.config.ClientID,
+		ClientSecret: c.config.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  c.config.AuthEndpoint,
+			TokenURL: c.config.TokenEndpoint,
+		},
+		RedirectURL: c.config.RancherURL,
+		Scopes:      strings.Fields(c.config.Scopes),
+	}
+}
+
+func (c *oauthClient) exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return c.oauthConfig().Exchange(c.context(ctx), code)
+}
+
+// tokenSource returns a source of the token that refreshes it once it expired.
+func (c *oauthClient) tokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(token, c.oauthConfig().TokenSource(c.context(ctx), token))
+}
+
+// getUser returns the user the token belongs to, and the groups of the user info's groups field.
+func (c *oauthClient) getUser(ctx context.Context, ts oauth2.TokenSource) (account, []account, error) {
+	info, err := c.get(ctx, ts, c.config.UserInfoEndpoint)
+	if err != nil {
+		return account{}, nil, err
+	}
+	user := c.toUser(info)
+	if user.ID == "" {
+		return account{}, nil, fmt.Errorf("user info has no %s field", valueOrDefault(c.config.UserIDField, defaultUserIDField))
+	}
+	var groups []account
+	if c.config.GroupsField != "" {
+		list, _ := lookup(info, c.config.GroupsField).([]interface{})
+		groups = c.toGroups(list)
+	}
+	return user, groups, nil
+}
+
+// getGroups returns the groups of the groups endpoint, if the config has one.
+func (c *oauthClient) getGroups(ctx context.Context, ts oauth2.TokenSource) ([]account, error) {
+	if c.config.GroupsEndpoint == "" {
+		return nil, nil
+	}
+	list, err := c.getList(ctx, ts, c.config.GroupsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	return c.toGroups(list), nil
+}
+
+func (c *oauthClient) searchUsers(ctx context.Context, ts oauth2.TokenSource, query string) ([]account, error) {
+	list, err := c.getList(ctx, ts, searchURL(c.config.UserSearchEndpoint, query))
+	if err != nil {
+		return nil, err
+	}
+	var users []account
+	for _, item := range list {
+		if user := c.toUser(item); user.ID != "" {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (c *oauthClient) searchGroups(ctx context.Context, ts oauth2.TokenSource, query string) ([]account, error) {
+	list, err := c.getList(ctx, ts, searchURL(c.config.GroupSearchEndpoint, query))
+	if err != nil {
+		return nil, err
+	}
+	return c.toGroups(list), nil
+}
+
+func (c *oauthClient) toUser(obj interface{}) account {
+	return account{
+		ID:             stringValue(lookup(obj, valueOrDefault(c.config.UserIDField, defaultUserIDField))),
+		LoginName:      stringValue(lookup(obj, valueOrDefault(c.config.UserNameField, defaultUserNameField))),
+		DisplayName:    stringValue(lookup(obj, valueOrDefault(c.config.DisplayNameField, defaultDisplayNameField))),
+		ProfilePicture: stringValue(lookup(obj, c.config.ProfilePictureField)),
+	}
+}
+
+// toGroups converts a list of groups, which are either names or objects, to accounts.
+func (c *oauthClient) toGroups(list []interface{}) []account {
+	var groups []account
+	for _, item := range list {
+		var group account
+		if _, ok := item.(map[string]interface{}); ok {
+			group.ID = stringValue(lookup(item, valueOrDefault(c.config.GroupIDField, defaultGroupIDField)))
+			group.DisplayName = stringValue(lookup(item, valueOrDefault(c.config.GroupDisplayNameField, defaultGroupDisplayNameField)))
+		} else {
+			group.ID = stringValue(item)
+		}
+		if group.ID == "" {
+			continue
+		}
+		if group.DisplayName == "" {
+			group.DisplayName = group.ID
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// getList returns the list of results of the endpoint, which is either the response or its results field.
+func (c *oauthClient) getList(ctx context.Context, ts oauth2.TokenSource, endpoint string) ([]interface{}, error) {
+	resp, err := c.get(ctx, ts, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.ResultsField != "" {
+		resp = lookup(resp, c.config.ResultsField)
+	}
+	list, ok := resp.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("response of %s is not a list", endpoint)
+	}
+	return list, nil
+}
+
+func (c *oauthClient) get(ctx context.Context, ts oauth2.TokenSource, endpoint string) (interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauth2.NewClient(c.context(ctx), ts).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request to %s failed with status %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// numbers are decoded as json.Number so that large IDs keep their exact value
+	var obj interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("invalid response of %s: %w", endpoint, err)
+	}
+	return obj, nil
+}
+
+func searchURL(endpoint, query string) string {
+	return strings.ReplaceAll(endpoint, queryPlaceholder, url.QueryEscape(query))
+}
+
+// lookup returns the value of the dotted path in the object, or nil if there is none.
+func lookup(obj interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		obj = m[key]
+	}
+	return obj
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,410 @@
+// Package genericoauth implements an auth provider for OAuth2 identity providers that do not implement OpenID Connect,
+// such as GitLab. The endpoints of the provider and the fields of its JSON responses that users and groups are read
+// from are configured in the GenericOAuthConfig.
+package genericoauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/norman/httperror"
+	"github.com/rancher/norman/types"
+	"github.com/rancher/norman/types/convert"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/common"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	client "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	publicclient "github.com/rancher/rancher/pkg/client/generated/management/v3public"
+	corev1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/rancher/rancher/pkg/user"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	Name      = "genericoauth"
+	UserType  = "user"
+	GroupType = "group"
+)
+
+type genericOAuthProvider struct {
+	ctx         context.Context
+	authConfigs v3.AuthConfigInterface
+	secrets     corev1.SecretInterface
+	userMGR     user.Manager
+	tokenMGR    *tokens.Manager
+}
+
+func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, userMGR user.Manager, tokenMGR *tokens.Manager) common.AuthProvider {
+	return &genericOAuthProvider{
+		ctx:         ctx,
+		authConfigs: mgmtCtx.Management.AuthConfigs(""),
+		secrets:     mgmtCtx.Core.Secrets(""),
+		userMGR:     userMGR,
+		tokenMGR:    tokenMGR,
+	}
+}
+
+func (g *genericOAuthProvider) GetName() string {
+	return Name
+}
+
+func (g *genericOAuthProvider) CustomizeSchema(schema *types.Schema) {
+	schema.ActionHandler = g.actionHandler
+	schema.Formatter = g.formatter
+}
+
+func (g *genericOAuthProvider) TransformToAuthProvider(authConfig map[string]interface{}) (map[string]interface{}, error) {
+	p := common.TransformToAuthProvider(authConfig)
+	p[publicclient.GenericOAuthProviderFieldRedirectURL] = redirectURL(
+		convert.ToString(authConfig[client.GenericOAuthConfigFieldAuthEndpoint]),
+		convert.ToString(authConfig[client.GenericOAuthConfigFieldClientID]),
+		convert.ToString(authConfig[client.GenericOAuthConfigFieldRancherURL]),
+		convert.ToString(authConfig[client.GenericOAuthConfigFieldScopes]),
+	)
+	return p, nil
+}
+
+func (g *genericOAuthProvider) AuthenticateUser(ctx context.Context, input interface{}) (v3.Principal, []v3.Principal, string, error) {
+	login, ok := input.(*v32.GenericOAuthLogin)
+	if !ok {
+		return v3.Principal{}, nil, "", errors.New("unexpected input type")
+	}
+	return g.loginUser(ctx, login, nil, false)
+}
+
+func (g *genericOAuthProvider) loginUser(ctx context.Context, login *v32.GenericOAuthLogin, config *v32.GenericOAuthConfig, test bool) (v3.Principal, []v3.Principal, string, error) {
+	var err error
+	if config == nil {
+		config, err = g.getGenericOAuthConfig()
+		if err != nil {
+			return v3.Principal{}, nil, "", err
+		}
+	}
+	c, err := newClient(config)
+	if err != nil {
+		return v3.Principal{}, nil, "", err
+	}
+
+	token, err := c.exchange(ctx, login.Code)
+	if err != nil {
+		logrus.Infof("[generic oauth] Error exchanging the authorization code: %v", err)
+		return v3.Principal{}, nil, "", httperror.NewAPIError(httperror.Unauthorized, "failed to exchange the authorization code")
+	}
+	ts := c.tokenSource(ctx, token)
+
+	userPrincipal, groupPrincipals, err := g.getPrincipals(ctx, c, ts)
+	if err != nil {
+		return v3.Principal{}, nil, "", err
+	}
+	userPrincipal.Me = true
+
+	allowedPrincipals := config.AllowedPrincipalIDs
+	if test && config.AccessMode == "restricted" {
+		allowedPrincipals = append(allowedPrincipals, userPrincipal.Name)
+	}
+	allowed, err := g.userMGR.CheckAccess(config.AccessMode, allowedPrincipals, userPrincipal.Name, groupPrincipals)
+	if err != nil {
+		return v3.Principal{}, nil, "", err
+	}
+	if !allowed {
+		return v3.Principal{}, nil, "", httperror.NewAPIError(httperror.Unauthorized, "unauthorized")
+	}
+
+	// the entire token is stored because it contains the refresh token and the expiry of the access token
+	providerToken, err := json.Marshal(token)
+	if err != nil {
+		return v3.Principal{}, nil, "", err
+	}
+	return userPrincipal, groupPrincipals, string(providerToken), nil
+}
+
+// getPrincipals returns the principals of the user the token belongs to and of its groups.
+func (g *genericOAuthProvider) getPrincipals(ctx context.Context, c *oauthClient, ts oauth2.TokenSource) (v3.Principal, []v3.Principal, error) {
+	userAcct, groupAccts, err := c.getUser(ctx, ts)
+	if err != nil {
+		return v3.Principal{}, nil, err
+	}
+	endpointGroups, err := c.getGroups(ctx, ts)
+	if err != nil {
+		return v3.Principal{}, nil, err
+	}
+	groupAccts = append(groupAccts, endpointGroups...)
+
+	var groupPrincipals []v3.Principal
+	seen := map[string]bool{}
+	for _, acct := range groupAccts {
+		if seen[acct.ID] {
+			continue
+		}
+		seen[acct.ID] = true
+		groupPrincipal := g.toPrincipal(GroupType, acct, nil)
+		groupPrincipal.MemberOf = true
+		groupPrincipals = append(groupPrincipals, groupPrincipal)
+	}
+	return g.toPrincipal(UserType, userAcct, nil), groupPrincipals, nil
+}
+
+func (g *genericOAuthProvider) RefetchGroupPrincipals(principalID string, secret string) ([]v3.Principal, error) {
+	config, err := g.getGenericOAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	// the user is needed to save the refreshed token
+	user, err := g.userMGR.GetUserByPrincipalID(principalID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("no user found for principal %s", principalID)
+	}
+	ts, err := g.userTokenSource(c, secret, user.Name)
+	if err != nil {
+		return nil, err
+	}
+	_, groupPrincipals, err := g.getPrincipals(g.ctx, c, ts)
+	return groupPrincipals, err
+}
+
+func (g *genericOAuthProvider) SearchPrincipals(searchValue, principalType string, token v3.Token) ([]v3.Principal, error) {
+	config, err := g.getGenericOAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	var ts oauth2.TokenSource
+	if config.UserSearchEndpoint != "" || config.GroupSearchEndpoint != "" {
+		ts, err = g.searchTokenSource(c, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g.searchPrincipals(c, ts, searchValue, principalType, token), nil
+}
+
+// searchPrincipals searches the principals with the search endpoints of the config. Principals whose type has no
+// search endpoint, or that are searched without a token of the provider, can only be found by their ID.
+func (g *genericOAuthProvider) searchPrincipals(c *oauthClient, ts oauth2.TokenSource, searchValue, principalType string, token v3.Token) []v3.Principal {
+	principalTypes := []string{UserType, GroupType}
+	if principalType != "" {
+		principalTypes = []string{principalType}
+	}
+
+	var principals []v3.Principal
+	for _, pType := range principalTypes {
+		endpoint := c.config.UserSearchEndpoint
+		if pType == GroupType {
+			endpoint = c.config.GroupSearchEndpoint
+		}
+		if endpoint == "" || ts == nil {
+			if principalType != "" || pType == UserType {
+				principals = append(principals, g.toPrincipal(pType, account{ID: searchValue, LoginName: searchValue, DisplayName: searchValue}, &token))
+			}
+			continue
+		}
+
+		var accts []account
+		var err error
+		if pType == GroupType {
+			accts, err = c.searchGroups(g.ctx, ts, searchValue)
+		} else {
+			accts, err = c.searchUsers(g.ctx, ts, searchValue)
+		}
+		if err != nil {
+			logrus.Errorf("[generic oauth] Error searching %ss: %v", pType, err)
+			continue
+		}
+		for _, acct := range accts {
+			principals = append(principals, g.toPrincipal(pType, acct, &token))
+		}
+	}
+	return principals
+}
+
+// searchTokenSource returns the source of the provider token of the user the token belongs to, or nil if the user did
+// not log in with the provider.
+func (g *genericOAuthProvider) searchTokenSource(c *oauthClient, token v3.Token) (oauth2.TokenSource, error) {
+	if token.AuthProvider != Name {
+		return nil, nil
+	}
+	secret, err := g.tokenMGR.GetSecret(token.UserID, token.AuthProvider, []*v3.Token{&token})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, nil
+	}
+	return g.userTokenSource(c, secret, token.UserID)
+}
+
+// userTokenSource returns the source of the stored provider token of the user. The token is refreshed if it expired and
+// the refreshed token is saved.
+func (g *genericOAuthProvider) userTokenSource(c *oauthClient, secret, userName string) (oauth2.TokenSource, error) {
+	token := &oauth2.Token{}
+	if err := json.Unmarshal([]byte(secret), token); err != nil {
+		// tokens of older logins may hold the access token only
+		token = &oauth2.Token{AccessToken: secret}
+	}
+	ts := c.tokenSource(g.ctx, token)
+	refreshed, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		logrus.Debugf("[generic oauth] Saving refreshed access token of user %s", userName)
+		data, err := json.Marshal(refreshed)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.tokenMGR.UpdateSecret(userName, Name, string(data)); err != nil {
+			logrus.Errorf("[generic oauth] Error saving refreshed access token of user %s: %v", userName, err)
+		}
+	}
+	return ts, nil
+}
+
+func (g *genericOAuthProvider) GetPrincipal(principalID string, token v3.Token) (v3.Principal, error) {
+	// parsing id to get the external id and type. id looks like genericoauth_[user|group]://12345
+	parts := strings.SplitN(principalID, ":", 2)
+	if len(parts) != 2 {
+		return v3.Principal{}, errors.Errorf("invalid id %v", principalID)
+	}
+	externalID := strings.TrimPrefix(parts[1], "//")
+	parts = strings.SplitN(parts[0], "_", 2)
+	if len(parts) != 2 || parts[0] != Name || externalID == "" {
+		return v3.Principal{}, errors.Errorf("invalid id %v", principalID)
+	}
+
+	principalType := parts[1]
+	switch principalType {
+	case UserType:
+		if token.UserPrincipal.Name == principalID {
+			p := token.UserPrincipal
+			p.Me = true
+			return p, nil
+		}
+		return g.toPrincipal(UserType, account{ID: externalID, LoginName: externalID, DisplayName: externalID}, &token), nil
+	case GroupType:
+		return g.toPrincipal(GroupType, account{ID: externalID, DisplayName: externalID}, &token), nil
+	default:
+		return v3.Principal{}, fmt.Errorf("invalid principal type %v", principalType)
+	}
+}
+
+func (g *genericOAuthProvider) toPrincipal(principalType string, acct account, token *v3.Token) v3.Principal {
+	displayName := acct.DisplayName
+	if displayName == "" {
+		displayName = acct.LoginName
+	}
+	princ := v3.Principal{
+		ObjectMeta:     metav1.ObjectMeta{Name: Name + "_" + principalType + "://" + acct.ID},
+		DisplayName:    displayName,
+		LoginName:      acct.LoginName,
+		Provider:       Name,
+		PrincipalType:  principalType,
+		ProfilePicture: acct.ProfilePicture,
+	}
+	if token != nil {
+		if principalType == UserType {
+			princ.Me = isThisUserMe(token.UserPrincipal, princ)
+		} else {
+			princ.MemberOf = g.tokenMGR.IsMemberOf(*token, princ)
+		}
+	}
+	return princ
+}
+
+func isThisUserMe(me v3.Principal, other v3.Principal) bool {
+	return me.ObjectMeta.Name == other.ObjectMeta.Name && me.PrincipalType == other.PrincipalType
+}
+
+func (g *genericOAuthProvider) CanAccessWithGroupProviders(userPrincipalID string, groupPrincipals []v3.Principal) (bool, error) {
+	config, err := g.getGenericOAuthConfig()
+	if err != nil {
+		logrus.Errorf("[generic oauth] Error fetching config: %v", err)
+		return false, err
+	}
+	return g.userMGR.CheckAccess(config.AccessMode, config.AllowedPrincipalIDs, userPrincipalID, groupPrincipals)
+}
+
+func (g *genericOAuthProvider) GetUserExtraAttributes(userPrincipal v3.Principal) map[string][]string {
+	extras := make(map[string][]string)
+	if userPrincipal.Name != "" {
+		extras[common.UserAttributePrincipalID] = []string{userPrincipal.Name}
+	}
+	if userPrincipal.LoginName != "" {
+		extras[common.UserAttributeUserName] = []string{userPrincipal.LoginName}
+	}
+	return extras
+}
+
+// IsDisabledProvider checks if the generic OAuth auth provider is currently disabled in Rancher.
+func (g *genericOAuthProvider) IsDisabledProvider() (bool, error) {
+	config, err := g.getGenericOAuthConfig()
+	if err != nil {
+		return false, err
+	}
+	return !config.Enabled, nil
+}
+
+func (g *genericOAuthProvider) getGenericOAuthConfig() (*v32.GenericOAuthConfig, error) {
+	authConfigObj, err := g.authConfigs.ObjectClient().UnstructuredClient().Get(Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve GenericOAuthConfig, error: %v", err)
+	}
+	u, ok := authConfigObj.(runtime.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve GenericOAuthConfig, cannot read k8s Unstructured data")
+	}
+
+	storedConfig := &v32.GenericOAuthConfig{}
+	if err := common.Decode(u.UnstructuredContent(), storedConfig); err != nil {
+		return nil, fmt.Errorf("unable to decode GenericOAuthConfig: %w", err)
+	}
+
+	if storedConfig.ClientSecret != "" {
+		value, err := common.ReadFromSecret(g.secrets, storedConfig.ClientSecret,
+			strings.ToLower(client.GenericOAuthConfigFieldClientSecret))
+		if err != nil {
+			return nil, err
+		}
+		storedConfig.ClientSecret = value
+	}
+	return storedConfig, nil
+}
+
+func (g *genericOAuthProvider) saveGenericOAuthConfig(config *v32.GenericOAuthConfig) error {
+	storedConfig, err := g.getGenericOAuthConfig()
+	if err != nil {
+		return err
+	}
+	config.APIVersion = "management.cattle.io/v3"
+	config.Kind = v3.AuthConfigGroupVersionKind.Kind
+	config.Type = client.GenericOAuthConfigType
+	config.ObjectMeta = storedConfig.ObjectMeta
+
+	field := strings.ToLower(client.GenericOAuthConfigFieldClientSecret)
+	name, err := common.CreateOrUpdateSecrets(g.secrets, convert.ToString(config.ClientSecret), field, strings.ToLower(config.Type))
+	if err != nil {
+		return err
+	}
+	config.ClientSecret = name
+
+	_, err = g.authConfigs.ObjectClient().Update(config.ObjectMeta.Name, config)
+	return err
+}
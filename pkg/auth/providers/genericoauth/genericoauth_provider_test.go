@@ -0,0 +1,313 @@
+package genericoauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+const accessToken = "gitlab-access-token"
+
+// newGitLab returns a server that responds like the GitLab OAuth and REST APIs.
+func newGitLab(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		if req.Form.Get("code") != "valid-code" {
+			http.Error(rw, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"access_token":"` + accessToken + `","token_type":"Bearer","refresh_token":"refresh","expires_in":7200}`))
+	})
+	authorized := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer "+accessToken {
+				http.Error(rw, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			handler(rw, req)
+		}
+	}
+	mux.HandleFunc("/api/v4/user", authorized(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"id":12345678901,"username":"jdoe","name":"Jane Doe","avatar_url":"https://gitlab.example.com/jdoe.png"}`))
+	}))
+	mux.HandleFunc("/oauth/userinfo", authorized(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"sub":"12345678901","nickname":"jdoe","name":"Jane Doe","groups":["devs","devs/backend"]}`))
+	}))
+	mux.HandleFunc("/api/v4/groups", authorized(func(rw http.ResponseWriter, req *http.Request) {
+		if search := req.URL.Query().Get("search"); search != "" {
+			assert.Equal(t, "ops team", search)
+			rw.Write([]byte(`[{"id":7,"full_path":"ops","name":"Ops"}]`))
+			return
+		}
+		rw.Write([]byte(`[{"id":3,"full_path":"devs","name":"Developers"},{"id":4,"full_path":"devs/backend","name":"Backend"}]`))
+	}))
+	mux.HandleFunc("/api/v4/users", authorized(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "jan", req.URL.Query().Get("search"))
+		rw.Write([]byte(`[{"id":12345678901,"username":"jdoe","name":"Jane Doe"},{"id":2,"username":"jan"}]`))
+	}))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func gitLabConfig(url string) *v32.GenericOAuthConfig {
+	return &v32.GenericOAuthConfig{
+		AuthConfig:          v32.AuthConfig{AccessMode: "unrestricted"},
+		ClientID:            "client",
+		ClientSecret:        "secret",
+		Scopes:              "read_user read_api",
+		RancherURL:          "https://rancher.example.com/verify-auth",
+		AuthEndpoint:        url + "/oauth/authorize",
+		TokenEndpoint:       url + "/oauth/token",
+		UserInfoEndpoint:    url + "/api/v4/user",
+		GroupsEndpoint:      url + "/api/v4/groups",
+		UserSearchEndpoint:  url + "/api/v4/users?search={query}",
+		GroupSearchEndpoint: url + "/api/v4/groups?search={query}",
+		UserIDField:         "id",
+		UserNameField:       "username",
+		ProfilePictureField: "avatar_url",
+	}
+}
+
+type mockUserManager struct {
+	user.Manager
+	allowed []string
+}
+
+func (m *mockUserManager) CheckAccess(accessMode string, allowedPrincipalIDs []string, userPrincipalID string, groups []v3.Principal) (bool, error) {
+	m.allowed = allowedPrincipalIDs
+	if accessMode == "unrestricted" {
+		return true, nil
+	}
+	for _, id := range allowedPrincipalIDs {
+		if id == userPrincipalID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestLoginUser(t *testing.T) {
+	srv := newGitLab(t)
+	userMGR := &mockUserManager{}
+	provider := &genericOAuthProvider{ctx: context.Background(), userMGR: userMGR}
+
+	userPrincipal, groupPrincipals, providerToken, err := provider.loginUser(context.Background(), &v32.GenericOAuthLogin{Code: "valid-code"}, gitLabConfig(srv.URL), false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "genericoauth_user://12345678901", userPrincipal.Name)
+	assert.Equal(t, "jdoe", userPrincipal.LoginName)
+	assert.Equal(t, "Jane Doe", userPrincipal.DisplayName)
+	assert.Equal(t, "https://gitlab.example.com/jdoe.png", userPrincipal.ProfilePicture)
+	assert.Equal(t, "user", userPrincipal.PrincipalType)
+	assert.True(t, userPrincipal.Me)
+
+	require.Len(t, groupPrincipals, 2)
+	assert.Equal(t, "genericoauth_group://3", groupPrincipals[0].Name)
+	assert.Equal(t, "Developers", groupPrincipals[0].DisplayName)
+	assert.Equal(t, "group", groupPrincipals[0].PrincipalType)
+	assert.True(t, groupPrincipals[0].MemberOf)
+	assert.Equal(t, "genericoauth_group://4", groupPrincipals[1].Name)
+
+	token := &oauth2.Token{}
+	require.NoError(t, json.Unmarshal([]byte(providerToken), token))
+	assert.Equal(t, accessToken, token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+}
+
+func TestLoginUserWithGroupsClaim(t *testing.T) {
+	srv := newGitLab(t)
+	config := gitLabConfig(srv.URL)
+	config.UserInfoEndpoint = srv.URL + "/oauth/userinfo"
+	config.GroupsEndpoint = ""
+	config.UserIDField = ""
+	config.UserNameField = "nickname"
+	config.GroupsField = "groups"
+	provider := &genericOAuthProvider{ctx: context.Background(), userMGR: &mockUserManager{}}
+
+	userPrincipal, groupPrincipals, _, err := provider.loginUser(context.Background(), &v32.GenericOAuthLogin{Code: "valid-code"}, config, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "genericoauth_user://12345678901", userPrincipal.Name)
+	assert.Equal(t, "jdoe", userPrincipal.LoginName)
+	require.Len(t, groupPrincipals, 2)
+	assert.Equal(t, "genericoauth_group://devs", groupPrincipals[0].Name)
+	assert.Equal(t, "devs", groupPrincipals[0].DisplayName)
+	assert.Equal(t, "genericoauth_group://devs/backend", groupPrincipals[1].Name)
+}
+
+func TestLoginUserAccess(t *testing.T) {
+	srv := newGitLab(t)
+	config := gitLabConfig(srv.URL)
+	config.AccessMode = "restricted"
+	config.AllowedPrincipalIDs = []string{"genericoauth_user://1"}
+	login := &v32.GenericOAuthLogin{Code: "valid-code"}
+	userMGR := &mockUserManager{}
+	provider := &genericOAuthProvider{ctx: context.Background(), userMGR: userMGR}
+
+	_, _, _, err := provider.loginUser(context.Background(), login, config, false)
+	assert.ErrorContains(t, err, "unauthorized")
+
+	// the user testing the config is allowed
+	_, _, _, err = provider.loginUser(context.Background(), login, config, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"genericoauth_user://1", "genericoauth_user://12345678901"}, userMGR.allowed)
+
+	_, _, _, err = provider.loginUser(context.Background(), &v32.GenericOAuthLogin{Code: "invalid-code"}, config, false)
+	assert.ErrorContains(t, err, "failed to exchange the authorization code")
+}
+
+func TestSearch(t *testing.T) {
+	srv := newGitLab(t)
+	c, err := newClient(gitLabConfig(srv.URL))
+	require.NoError(t, err)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+
+	users, err := c.searchUsers(context.Background(), ts, "jan")
+	require.NoError(t, err)
+	assert.Equal(t, []account{
+		{ID: "12345678901", LoginName: "jdoe", DisplayName: "Jane Doe"},
+		{ID: "2", LoginName: "jan"},
+	}, users)
+
+	groups, err := c.searchGroups(context.Background(), ts, "ops team")
+	require.NoError(t, err)
+	assert.Equal(t, []account{{ID: "7", DisplayName: "Ops"}}, groups)
+
+	_, err = c.searchUsers(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "invalid"}), "jan")
+	assert.ErrorContains(t, err, "status 401")
+}
+
+func TestResultsField(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"data":{"groups":[{"uuid":"a1","title":"Admins"}]}}`))
+	}))
+	defer srv.Close()
+	config := gitLabConfig(srv.URL)
+	config.GroupsEndpoint = srv.URL
+	config.ResultsField = "data.groups"
+	config.GroupIDField = "uuid"
+	config.GroupDisplayNameField = "title"
+	c, err := newClient(config)
+	require.NoError(t, err)
+
+	groups, err := c.getGroups(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken}))
+	require.NoError(t, err)
+	assert.Equal(t, []account{{ID: "a1", DisplayName: "Admins"}}, groups)
+
+	config.ResultsField = "data"
+	_, err = c.getGroups(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken}))
+	assert.ErrorContains(t, err, "is not a list")
+}
+
+func TestSearchPrincipals(t *testing.T) {
+	srv := newGitLab(t)
+	config := gitLabConfig(srv.URL)
+	c, err := newClient(config)
+	require.NoError(t, err)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
+	provider := &genericOAuthProvider{ctx: context.Background()}
+	me := v3.Principal{PrincipalType: UserType}
+	me.Name = "genericoauth_user://12345678901"
+	token := v3.Token{UserPrincipal: me}
+
+	principals := provider.searchPrincipals(c, ts, "jan", UserType, token)
+	require.Len(t, principals, 2)
+	assert.Equal(t, "genericoauth_user://12345678901", principals[0].Name)
+	assert.True(t, principals[0].Me)
+	assert.Equal(t, "genericoauth_user://2", principals[1].Name)
+	assert.Equal(t, "jan", principals[1].DisplayName)
+	assert.False(t, principals[1].Me)
+
+	// without a token of the provider, users can only be found by their ID
+	principals = provider.searchPrincipals(c, nil, "jdoe", "", token)
+	require.Len(t, principals, 1)
+	assert.Equal(t, "genericoauth_user://jdoe", principals[0].Name)
+
+	// without a user search endpoint, users can only be found by their ID
+	config.UserSearchEndpoint = ""
+	principals = provider.searchPrincipals(c, ts, "jdoe", UserType, token)
+	require.Len(t, principals, 1)
+	assert.Equal(t, "genericoauth_user://jdoe", principals[0].Name)
+	assert.Equal(t, "jdoe", principals[0].LoginName)
+}
+
+func TestGetPrincipal(t *testing.T) {
+	provider := &genericOAuthProvider{}
+	me := v3.Principal{
+		DisplayName:   "Jane Doe",
+		LoginName:     "jdoe",
+		PrincipalType: UserType,
+		Provider:      Name,
+	}
+	me.Name = "genericoauth_user://12345678901"
+	token := v3.Token{UserPrincipal: me}
+
+	p, err := provider.GetPrincipal("genericoauth_user://12345678901", token)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", p.DisplayName)
+	assert.True(t, p.Me)
+
+	p, err = provider.GetPrincipal("genericoauth_user://2", token)
+	require.NoError(t, err)
+	assert.Equal(t, "2", p.LoginName)
+	assert.False(t, p.Me)
+
+	for _, id := range []string{"genericoauth_team://2", "github_user://2", "genericoauth_user://", "invalid"} {
+		_, err = provider.GetPrincipal(id, token)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestRedirectURL(t *testing.T) {
+	redirect := redirectURL("https://gitlab.example.com/oauth/authorize", "client", "https://rancher.example.com/verify-auth", " read_user  openid ")
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	assert.Equal(t, "gitlab.example.com", u.Host)
+	assert.Equal(t, url.Values{
+		"client_id":     {"client"},
+		"response_type": {"code"},
+		"redirect_uri":  {"https://rancher.example.com/verify-auth"},
+		"scope":         {"read_user openid"},
+	}, u.Query())
+
+	assert.Equal(t, "https://idp.example.com/authorize?tenant=1&client_id=client&redirect_uri=&response_type=code",
+		redirectURL("https://idp.example.com/authorize?tenant=1", "client", "", ""))
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := gitLabConfig("https://gitlab.example.com")
+	assert.NoError(t, validateConfig(config))
+
+	config.GroupSearchEndpoint = "/api/v4/groups?search={query}"
+	assert.ErrorContains(t, validateConfig(config), "groupSearchEndpoint")
+
+	config = gitLabConfig("https://gitlab.example.com")
+	config.TokenEndpoint = ""
+	assert.ErrorContains(t, validateConfig(config), "tokenEndpoint")
+}
+
+func TestStringValue(t *testing.T) {
+	obj := map[string]interface{}{
+		"id":      json.Number("12345678901234567890"),
+		"profile": map[string]interface{}{"login": "jdoe", "admin": true},
+	}
+	assert.Equal(t, "12345678901234567890", stringValue(lookup(obj, "id")))
+	assert.Equal(t, "jdoe", stringValue(lookup(obj, "profile.login")))
+	assert.Equal(t, "true", stringValue(lookup(obj, "profile.admin")))
+	assert.Equal(t, "", stringValue(lookup(obj, "profile.missing.login")))
+	assert.Equal(t, "", stringValue(lookup(obj, "")))
+}
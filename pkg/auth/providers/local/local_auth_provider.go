@@ -10,6 +10,7 @@
 	"github.com/pkg/errors"
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
+	"github.com/rancher/rancher/pkg/auth/mfa"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -38,6 +39,14 @@ type Provider struct {
 	groupIndexer cache.Indexer
 	tokenMGR     *tokens.Manager
 	invalidHash  []byte
+	mfaManager   mfaManager
+}
+
+// mfaManager is the part of mfa.Manager used to log in.
+type mfaManager interface {
+	IsEnrolled(userName string) (bool, error)
+	IsRequired(userName string) (bool, error)
+	Verify(userName, code string) error
 }
 
 func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, tokenMGR *tokens.Manager) common.AuthProvider {
@@ -63,6 +72,7 @@ func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, tokenMGR *tok
 		userLister:   mgmtCtx.Management.Users("").Controller().Lister(),
 		tokenMGR:     tokenMGR,
 		invalidHash:  invalidHash,
+		mfaManager:   mfa.NewManager(mgmtCtx),
 	}
 	return l
 }
@@ -124,6 +134,14 @@ func (l *Provider) AuthenticateUser(ctx context.Context, input interface{}) (v3.
 		return v3.Principal{}, nil, "", authFailedError
 	}
 
+	if err := l.verifyMFA(user, localInput.MFACode); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			logrus.Debugf("MFA failed for User [%s]: %v", username, err)
+			return v3.Principal{}, nil, "", authFailedError
+		}
+		return v3.Principal{}, nil, "", err
+	}
+
 	principalID := getLocalPrincipalID(user)
 	userPrincipal := l.toPrincipal("user", user.DisplayName, user.Username, principalID, nil)
 	userPrincipal.Me = true
@@ -136,6 +154,28 @@ func (l *Provider) AuthenticateUser(ctx context.Context, input interface{}) (v3.
 	return userPrincipal, groupPrincipals, "", nil
 }
 
+// verifyMFA checks the TOTP code or recovery code of users that enabled TOTP. Users that must use MFA but did not
+// enroll yet are rejected: enrolling requires an authenticated session, so that knowing the password of a user is not
+// enough to enroll an authenticator app in their name.
+func (l *Provider) verifyMFA(user *v3.User, code string) error {
+	enrolled, err := l.mfaManager.IsEnrolled(user.Name)
+	if err != nil {
+		return err
+	}
+	if enrolled {
+		if code == "" {
+			return httperror.NewAPIError(mfa.MFARequired, "a TOTP code or recovery code is required")
+		}
+		return l.mfaManager.Verify(user.Name, code)
+	}
+
+	required, err := l.mfaManager.IsRequired(user.Name)
+	if err != nil || !required {
+		return err
+	}
+	return httperror.NewAPIError(mfa.MFAEnrollmentRequired, "TOTP must be enabled for the user before it can log in")
+}
+
 func getLocalPrincipalID(user *v3.User) string {
 	// TODO error condition handling: no principal, more than one that would match
 	var principalID string
@@ -0,0 +1,88 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rancher/norman/httperror"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/mfa"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeMFAManager struct {
+	enrolled  bool
+	required  bool
+	validCode string
+}
+
+func (f *fakeMFAManager) IsEnrolled(string) (bool, error) {
+	return f.enrolled, nil
+}
+
+func (f *fakeMFAManager) IsRequired(string) (bool, error) {
+	return f.required, nil
+}
+
+func (f *fakeMFAManager) Verify(_, code string) error {
+	if code != f.validCode {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+func TestAuthenticateUserMFA(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	userIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{userNameIndex: userNameIndexer})
+	require.NoError(t, userIndexer.Add(&v3.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "u-abc"},
+		Username:   "jane",
+		Password:   string(hash),
+	}))
+
+	tests := []struct {
+		name        string
+		mfa         *fakeMFAManager
+		password    string
+		code        string
+		wantErrCode string
+	}{
+		{name: "mfa not enabled", mfa: &fakeMFAManager{}, password: "password"},
+		{name: "wrong password is rejected before mfa", mfa: &fakeMFAManager{enrolled: true, validCode: "123456"}, password: "wrong", code: "123456", wantErrCode: httperror.Unauthorized.Code},
+		{name: "enrolled user without code", mfa: &fakeMFAManager{enrolled: true, validCode: "123456"}, password: "password", wantErrCode: mfa.MFARequired.Code},
+		{name: "enrolled user with wrong code", mfa: &fakeMFAManager{enrolled: true, validCode: "123456"}, password: "password", code: "654321", wantErrCode: httperror.Unauthorized.Code},
+		{name: "enrolled user with code", mfa: &fakeMFAManager{enrolled: true, validCode: "123456"}, password: "password", code: "123456"},
+		{name: "required user must enroll", mfa: &fakeMFAManager{required: true, validCode: "123456"}, password: "password", wantErrCode: mfa.MFAEnrollmentRequired.Code},
+		{name: "required user cannot enroll while logging in", mfa: &fakeMFAManager{required: true, validCode: "123456"}, password: "password", code: "123456", wantErrCode: mfa.MFAEnrollmentRequired.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Provider{
+				userIndexer: userIndexer,
+				mfaManager:  tt.mfa,
+			}
+
+			principal, _, _, err := l.AuthenticateUser(context.Background(), &v32.BasicLogin{
+				Username: "jane",
+				Password: tt.password,
+				MFACode:  tt.code,
+			})
+			switch {
+			case tt.wantErrCode != "":
+				var apiErr *httperror.APIError
+				require.True(t, errors.As(err, &apiErr), "unexpected error %v", err)
+				assert.Equal(t, tt.wantErrCode, apiErr.Code.Code)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "jane", principal.LoginName)
+			}
+		})
+	}
+}
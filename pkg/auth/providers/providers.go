@@ -9,6 +9,7 @@
 	"github.com/rancher/rancher/pkg/auth/providers/activedirectory"
 	"github.com/rancher/rancher/pkg/auth/providers/azure"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
+	"github.com/rancher/rancher/pkg/auth/providers/genericoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/github"
 	"github.com/rancher/rancher/pkg/auth/providers/googleoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/keycloakoidc"
@@ -147,6 +148,13 @@ func Configure(ctx context.Context, mgmt *config.ScaledContext) {
 	Providers[keycloakoidc.Name] = p
 	providersByType[client.KeyCloakOIDCConfigType] = p
 	providersByType[publicclient.KeyCloakOIDCProviderType] = p
+
+	p = genericoauth.Configure(ctx, mgmt, userMGR, tokenMGR)
+	ProviderNames[genericoauth.Name] = true
+	providersWithSecrets[genericoauth.Name] = true
+	Providers[genericoauth.Name] = p
+	providersByType[client.GenericOAuthConfigType] = p
+	providersByType[publicclient.GenericOAuthProviderType] = p
 }
 
 func IsValidUserExtraAttribute(key string) bool {
@@ -47,6 +47,7 @@ func NewHandler(ctx context.Context, mgmtCtx *config.ScaledContext, opts ...Serv
 	v3public.GoogleOAuthProviderType,
 	v3public.OIDCProviderType,
 	v3public.KeyCloakOIDCProviderType,
+	v3public.GenericOAuthProviderType,
 }
 
 func authProviderSchemas(ctx context.Context, management *config.ScaledContext, schemas *types.Schemas) error {
@@ -11,9 +11,11 @@
 	"github.com/rancher/norman/httperror"
 	"github.com/rancher/norman/types"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/lockout"
 	"github.com/rancher/rancher/pkg/auth/providers"
 	"github.com/rancher/rancher/pkg/auth/providers/activedirectory"
 	"github.com/rancher/rancher/pkg/auth/providers/azure"
+	"github.com/rancher/rancher/pkg/auth/providers/genericoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/github"
 	"github.com/rancher/rancher/pkg/auth/providers/googleoauth"
 	"github.com/rancher/rancher/pkg/auth/providers/keycloakoidc"
@@ -21,6 +23,7 @@
 	"github.com/rancher/rancher/pkg/auth/providers/local"
 	"github.com/rancher/rancher/pkg/auth/providers/oidc"
 	"github.com/rancher/rancher/pkg/auth/providers/saml"
+	"github.com/rancher/rancher/pkg/auth/requests"
 	"github.com/rancher/rancher/pkg/auth/settings"
 	"github.com/rancher/rancher/pkg/auth/tokens"
 	"github.com/rancher/rancher/pkg/auth/util"
@@ -44,6 +47,7 @@ func newLoginHandler(ctx context.Context, mgmt *config.ScaledContext) *loginHand
 		tokenMGR:      tokens.NewManager(ctx, mgmt),
 		clusterLister: mgmt.Management.Clusters("").Controller().Lister(),
 		secretLister:  mgmt.Core.Secrets("").Controller().Lister(),
+		lockout:       lockout.NewManager(mgmt),
 	}
 }
 
@@ -53,6 +57,7 @@ type loginHandler struct {
 	tokenMGR      *tokens.Manager
 	clusterLister v3.ClusterLister
 	secretLister  v1.SecretLister
+	lockout       *lockout.Manager
 }
 
 func (h *loginHandler) login(actionName string, action *types.Action, request *types.APIContext) error {
@@ -168,6 +173,9 @@ func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, st
 	case client.KeyCloakOIDCProviderType:
 		input = &v32.OIDCLogin{}
 		providerName = keycloakoidc.Name
+	case client.GenericOAuthProviderType:
+		input = &v32.GenericOAuthLogin{}
+		providerName = genericoauth.Name
 	default:
 		return v3.Token{}, "", "", httperror.NewAPIError(httperror.ServerError, "unknown authentication provider")
 	}
@@ -189,7 +197,23 @@ func (h *loginHandler) createLoginToken(request *types.APIContext) (v3.Token, st
 	}
 
 	ctx := context.WithValue(request.Request.Context(), util.RequestKey, request.Request)
+
+	// password logins are subject to brute-force protection
+	var attempt *lockout.Attempt
+	if basicLogin, ok := input.(*v32.BasicLogin); ok {
+		attempt = &lockout.Attempt{Provider: providerName, Username: basicLogin.Username}
+		if ip := requests.ClientIP(request.Request); ip != nil {
+			attempt.IP = ip.String()
+		}
+		if err := h.lockout.Check(ctx, *attempt); err != nil {
+			return v3.Token{}, "", "", err
+		}
+	}
+
 	userPrincipal, groupPrincipals, providerToken, err = providers.AuthenticateUser(ctx, input, providerName)
+	if attempt != nil {
+		h.lockout.Record(ctx, *attempt, err)
+	}
 	if err != nil {
 		return v3.Token{}, "", "", err
 	}
@@ -27,6 +27,7 @@
 	client.GoogleOauthConfigType,
 	client.OIDCConfigType,
 	client.KeyCloakOIDCConfigType,
+	client.GenericOAuthConfigType,
 }
 
 func SetupAuthConfig(ctx context.Context, management *config.ScaledContext, schemas *types.Schemas) {
@@ -0,0 +1,153 @@
+// Package attributes works out which Kubernetes verb, API group and resource an HTTP request served by
+// Rancher acts on, so that requests to the different APIs can be matched against the same kind of rules.
+package attributes
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// NormanGroup is the API group of the resources served by the norman (/v3) API.
+const NormanGroup = "management.cattle.io"
+
+// connectSubresources are the subresources that open a stream to a pod or node. Websocket clients connect to them
+// with GET requests, which are treated as create, like the Kubernetes API server does, so that they are not allowed
+// by read only rules.
+var connectSubresources = sets.NewString("attach", "exec", "portforward", "proxy")
+
+var requestInfoFactory = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// Attributes is the verb, API group, resource and subresource of a request. Group and Resource are empty
+// for requests that do not act on a resource, Subresource is empty for requests that act on the resource itself.
+type Attributes struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Path        string
+}
+
+// FromRequest works out the attributes of a request for the Kubernetes API (/api, /apis and the
+// /k8s/clusters proxy), steve (/v1) and norman (/v3) endpoints.
+func FromRequest(req *http.Request) Attributes {
+	attrs := Attributes{
+		Verb: methodVerb(req),
+		Path: req.URL.Path,
+	}
+
+	path := req.URL.Path
+	if strings.HasPrefix(path, "/k8s/clusters/") {
+		parts := strings.SplitN(strings.TrimPrefix(path, "/k8s/clusters/"), "/", 2)
+		path = "/"
+		if len(parts) == 2 {
+			path += parts[1]
+		}
+	}
+
+	parts := splitPath(path)
+	if len(parts) == 0 {
+		return attrs
+	}
+
+	switch parts[0] {
+	case "api", "apis":
+		k8sReq := req.Clone(req.Context())
+		k8sReq.URL = &url.URL{Path: path, RawQuery: req.URL.RawQuery}
+		info, err := requestInfoFactory.NewRequestInfo(k8sReq)
+		if err != nil {
+			logrus.Debugf("failed to parse request info for %s: %v", req.URL.Path, err)
+			return attrs
+		}
+		if info.IsResourceRequest {
+			attrs.Verb = info.Verb
+			attrs.Group = info.APIGroup
+			attrs.Resource = info.Resource
+			attrs.Subresource = info.Subresource
+			if attrs.Verb == "get" && connectSubresources.Has(info.Subresource) {
+				attrs.Verb = "create"
+			}
+		}
+	case "v1":
+		if len(parts) < 2 {
+			return attrs
+		}
+		// Steve types are either a bare kind for the core group, e.g. "secret", or the group followed by the kind,
+		// e.g. "management.cattle.io.globalrole".
+		schemaID := strings.ToLower(parts[1])
+		if i := strings.LastIndex(schemaID, "."); i >= 0 {
+			attrs.Group = schemaID[:i]
+			attrs.Resource = schemaID[i+1:]
+		} else {
+			attrs.Resource = schemaID
+		}
+		attrs.Verb = collectionVerb(req, attrs.Verb, len(parts) == 2)
+	case "v3":
+		// Norman resources live in the management.cattle.io group and may be nested under a cluster or project,
+		// e.g. /v3/globalrolebindings or /v3/project/<id>/apps.
+		rest := parts[1:]
+		if len(rest) >= 3 && (rest[0] == "cluster" || rest[0] == "clusters" || rest[0] == "project" || rest[0] == "projects") {
+			rest = rest[2:]
+		}
+		if len(rest) == 0 {
+			return attrs
+		}
+		attrs.Group = NormanGroup
+		attrs.Resource = strings.ToLower(rest[0])
+		attrs.Verb = collectionVerb(req, attrs.Verb, len(rest) == 1)
+	}
+
+	return attrs
+}
+
+// ResourceMatches compares a plural resource name, as used by the Kubernetes API, with the resource of a request.
+// Steve addresses types by their singular kind, so the simple English plural forms of the request resource are
+// also accepted.
+func ResourceMatches(pluralResource, resource string) bool {
+	pluralResource = strings.ToLower(pluralResource)
+	if pluralResource == resource || pluralResource == resource+"s" || pluralResource == resource+"es" {
+		return true
+	}
+	return strings.HasSuffix(resource, "y") && pluralResource == strings.TrimSuffix(resource, "y")+"ies"
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func methodVerb(req *http.Request) string {
+	switch req.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+// collectionVerb turns get requests against a collection into list or watch.
+func collectionVerb(req *http.Request, verb string, collection bool) string {
+	if verb != "get" || !collection {
+		return verb
+	}
+	if req.URL.Query().Get("watch") == "true" {
+		return "watch"
+	}
+	return "list"
+}
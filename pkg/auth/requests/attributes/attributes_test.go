@@ -0,0 +1,118 @@
+package attributes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		uri          string
+		wantVerb     string
+		wantGroup    string
+		wantResource string
+		wantSubres   string
+	}{
+		{
+			name:         "kubernetes core list",
+			method:       http.MethodGet,
+			uri:          "/api/v1/namespaces/default/events",
+			wantVerb:     "list",
+			wantResource: "events",
+		},
+		{
+			name:         "kubernetes watch",
+			method:       http.MethodGet,
+			uri:          "/apis/apps/v1/deployments?watch=true",
+			wantVerb:     "watch",
+			wantGroup:    "apps",
+			wantResource: "deployments",
+		},
+		{
+			name:         "downstream cluster proxy",
+			method:       http.MethodPut,
+			uri:          "/k8s/clusters/c-m-abcde/apis/rbac.authorization.k8s.io/v1/clusterrolebindings/admin",
+			wantVerb:     "update",
+			wantGroup:    "rbac.authorization.k8s.io",
+			wantResource: "clusterrolebindings",
+		},
+		{
+			name:         "websocket exec is a create",
+			method:       http.MethodGet,
+			uri:          "/k8s/clusters/c-m-abcde/api/v1/namespaces/default/pods/web/exec?command=sh",
+			wantVerb:     "create",
+			wantResource: "pods",
+			wantSubres:   "exec",
+		},
+		{
+			name:         "pod logs",
+			method:       http.MethodGet,
+			uri:          "/api/v1/namespaces/default/pods/web/log",
+			wantVerb:     "get",
+			wantResource: "pods",
+			wantSubres:   "log",
+		},
+		{
+			name:         "steve list",
+			method:       http.MethodGet,
+			uri:          "/v1/management.cattle.io.globalrolebinding",
+			wantVerb:     "list",
+			wantGroup:    "management.cattle.io",
+			wantResource: "globalrolebinding",
+		},
+		{
+			name:         "steve core get",
+			method:       http.MethodGet,
+			uri:          "/v1/secret/default/name",
+			wantVerb:     "get",
+			wantResource: "secret",
+		},
+		{
+			name:         "norman create",
+			method:       http.MethodPost,
+			uri:          "/v3/globalRoleBindings",
+			wantVerb:     "create",
+			wantGroup:    "management.cattle.io",
+			wantResource: "globalrolebindings",
+		},
+		{
+			name:         "norman nested under a project",
+			method:       http.MethodDelete,
+			uri:          "/v3/project/c-abcde:p-abcde/apps/p-abcde:app",
+			wantVerb:     "delete",
+			wantGroup:    "management.cattle.io",
+			wantResource: "apps",
+		},
+		{
+			name:     "non resource request",
+			method:   http.MethodGet,
+			uri:      "/healthz",
+			wantVerb: "get",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.uri, nil)
+			require.NoError(t, err)
+			attrs := FromRequest(req)
+			assert.Equal(t, tt.wantVerb, attrs.Verb)
+			assert.Equal(t, tt.wantGroup, attrs.Group)
+			assert.Equal(t, tt.wantResource, attrs.Resource)
+			assert.Equal(t, tt.wantSubres, attrs.Subresource)
+		})
+	}
+}
+
+func TestResourceMatches(t *testing.T) {
+	assert.True(t, ResourceMatches("secrets", "secrets"))
+	assert.True(t, ResourceMatches("secrets", "secret"))
+	assert.True(t, ResourceMatches("ingresses", "ingress"))
+	assert.True(t, ResourceMatches("NetworkPolicies", "networkpolicy"))
+	assert.False(t, ResourceMatches("secrets", "configmap"))
+}
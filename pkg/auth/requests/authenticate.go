@@ -7,6 +7,8 @@
 
 	"github.com/pkg/errors"
 	"github.com/rancher/norman/httperror"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	authcontext "github.com/rancher/rancher/pkg/auth/context"
 	"github.com/rancher/rancher/pkg/auth/providerrefresh"
 	"github.com/rancher/rancher/pkg/auth/providers"
 	"github.com/rancher/rancher/pkg/auth/providers/common"
@@ -36,22 +38,37 @@ type AuthenticatorResponse struct {
 	UserPrincipal string
 	Groups        []string
 	Extras        map[string][]string
+	// Scopes are the scopes of the token the request was authenticated with.
+	Scopes []v32.TokenScope
 }
 
 func ToAuthMiddleware(a Authenticator) auth.Middleware {
-	f := func(req *http.Request) (user.Info, bool, error) {
-		authResp, err := a.Authenticate(req)
-		if err != nil {
-			return nil, false, err
-		}
-		return &user.DefaultInfo{
-			Name:   authResp.User,
-			UID:    authResp.User,
-			Groups: authResp.Groups,
-			Extra:  authResp.Extras,
-		}, authResp.IsAuthed, err
-	}
-	return auth.ToMiddleware(auth.AuthenticatorFunc(f))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			var scopes []v32.TokenScope
+			f := func(req *http.Request) (user.Info, bool, error) {
+				authResp, err := a.Authenticate(req)
+				if err != nil {
+					return nil, false, err
+				}
+				scopes = authResp.Scopes
+				return &user.DefaultInfo{
+					Name:   authResp.User,
+					UID:    authResp.User,
+					Groups: authResp.Groups,
+					Extra:  authResp.Extras,
+				}, authResp.IsAuthed, err
+			}
+			// Record the scopes of the token so that later handlers, such as impersonation, can enforce them.
+			withScopes := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if len(scopes) > 0 {
+					req = req.WithContext(authcontext.SetTokenScopes(req.Context(), scopes))
+				}
+				next.ServeHTTP(rw, req)
+			})
+			auth.ToMiddleware(auth.AuthenticatorFunc(f))(withScopes).ServeHTTP(rw, req)
+		})
+	}
 }
 
 type ClusterRouter func(req *http.Request) string
@@ -69,6 +86,7 @@ func NewAuthenticator(ctx context.Context, clusterRouter ClusterRouter, mgmtCtx
 		userLister:          mgmtCtx.Management.Users("").Controller().Lister(),
 		clusterRouter:       clusterRouter,
 		userAuthRefresher:   providerrefresh.NewUserAuthRefresher(ctx, mgmtCtx),
+		usageRecorder:       newTokenUsageRecorder(mgmtCtx.Management.Tokens("").ObjectClient()),
 	}
 }
 
@@ -81,6 +99,7 @@ type tokenAuthenticator struct {
 	userLister          v3.UserLister
 	clusterRouter       ClusterRouter
 	userAuthRefresher   providerrefresh.UserAuthRefresher
+	usageRecorder       *tokenUsageRecorder
 }
 
 const (
@@ -111,6 +130,9 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 	if token.ClusterName != "" && token.ClusterName != a.clusterRouter(req) {
 		return nil, errors.Wrapf(ErrMustAuthenticate, "clusterID does not match")
 	}
+	if err := checkTokenScopes(req, token); err != nil {
+		return nil, err
+	}
 
 	attribs, err := a.userAttributeLister.Get("", token.UserID)
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -158,6 +180,8 @@ func (a *tokenAuthenticator) Authenticate(req *http.Request) (*AuthenticatorResp
 	authResp.UserPrincipal = token.UserPrincipal.Name
 	authResp.Groups = groups
 	authResp.Extras = getUserExtraInfo(token, u, attribs)
+	authResp.Scopes = token.Scopes
+	a.usageRecorder.record(token, ClientIP(req))
 	logrus.Debugf("Extras returned %v", authResp.Extras)
 
 	return authResp, nil
@@ -235,6 +259,7 @@ func (a *tokenAuthenticator) TokenFromRequest(req *http.Request) (*v3.Token, err
 	if _, err := tokens.VerifyToken(storedToken, tokenName, tokenKey); err != nil {
 		return nil, errors.Wrapf(ErrMustAuthenticate, "failed to verify token: %v", err)
 	}
+	tokens.RehashTokenIfNeeded(a.tokenClient, storedToken, tokenKey)
 
 	return storedToken, nil
 }
@@ -5,7 +5,10 @@
 	"net/http"
 
 	"github.com/rancher/rancher/pkg/auth/audit"
+	authcontext "github.com/rancher/rancher/pkg/auth/context"
+	"github.com/rancher/rancher/pkg/auth/requests/attributes"
 	"github.com/rancher/rancher/pkg/auth/requests/sar"
+	"github.com/rancher/rancher/pkg/auth/tokens"
 	"github.com/rancher/steve/pkg/auth"
 	"k8s.io/apimachinery/pkg/util/sets"
 	k8sUser "k8s.io/apiserver/pkg/authentication/user"
@@ -48,7 +51,11 @@ func (h *impersonatingAuth) Authenticate(req *http.Request) (k8sUser.Info, bool,
 	// If there is an impersonate header, the incoming request is attempting to
 	// impersonate a different user, verify the token user is authz to impersonate
 	if h.sar != nil {
+		scopes := authcontext.TokenScopes(req.Context())
 		if reqUser != "" && reqUser != user {
+			if !tokens.ScopesAllow(scopes, attributes.Attributes{Verb: "impersonate", Resource: "users"}) {
+				return nil, false, errors.New("token scopes do not allow impersonating users")
+			}
 			canDo, err := h.sar.UserCanImpersonateUser(req, user, reqUser)
 			if err != nil {
 				return nil, false, err
@@ -59,6 +66,9 @@ func (h *impersonatingAuth) Authenticate(req *http.Request) (k8sUser.Info, bool,
 		}
 
 		if len(reqGroup) > 0 && !groupsEqual(reqGroup, groups) {
+			if !tokens.ScopesAllow(scopes, attributes.Attributes{Verb: "impersonate", Resource: "groups"}) {
+				return nil, false, errors.New("token scopes do not allow impersonating groups")
+			}
 			canDo, err := h.sar.UserCanImpersonateGroups(req, user, reqGroup)
 			if err != nil {
 				return nil, false, err
@@ -0,0 +1,95 @@
+package requests
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/rancher/pkg/auth/requests/attributes"
+	"github.com/rancher/rancher/pkg/auth/tokens"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
+)
+
+// checkTokenScopes returns an error if the request is not allowed by the scopes or source CIDRs of the token.
+func checkTokenScopes(req *http.Request, token *v3.Token) error {
+	if len(token.SourceCIDRs) > 0 {
+		ip := ClientIP(req)
+		if ip == nil || !containsIP(parseCIDRs(strings.Join(token.SourceCIDRs, ",")), ip) {
+			return errors.Wrapf(ErrMustAuthenticate, "token %s is not allowed from address %s", token.Name, ip)
+		}
+	}
+	if len(token.Scopes) > 0 {
+		attrs := attributes.FromRequest(req)
+		if !tokens.ScopesAllow(token.Scopes, attrs) {
+			resource := attrs.Resource
+			if attrs.Subresource != "" {
+				resource += "/" + attrs.Subresource
+			}
+			return errors.Wrapf(ErrMustAuthenticate, "token %s is not allowed to %s %s in group %q", token.Name, attrs.Verb, resource, attrs.Group)
+		}
+	}
+	return nil
+}
+
+// ClientIP returns the address of the client that made the request, trusting the X-Forwarded-For header of the
+// proxies in the trusted-proxy-cidrs setting.
+func ClientIP(req *http.Request) net.IP {
+	return clientIP(req, parseCIDRs(settings.TrustedProxyCIDRs.Get()))
+}
+
+// clientIP returns the address of the client that made the request. The X-Forwarded-For header is only
+// used if the request comes from a trusted proxy, in which case the right-most address that is not a
+// trusted proxy is the client.
+func clientIP(req *http.Request, trustedProxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(trustedProxies, ip) {
+		return ip
+	}
+
+	forwardedFor := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		forwarded := net.ParseIP(strings.TrimSpace(forwardedFor[i]))
+		if forwarded == nil {
+			// Anything to the left of an invalid address can't be trusted.
+			return ip
+		}
+		ip = forwarded
+		if !containsIP(trustedProxies, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func parseCIDRs(value string) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, cidr := range strings.Split(value, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid CIDR %q: %v", cidr, err)
+			continue
+		}
+		cidrs = append(cidrs, ipNet)
+	}
+	return cidrs
+}
+
+func containsIP(cidrs []*net.IPNet, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
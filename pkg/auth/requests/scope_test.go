@@ -0,0 +1,120 @@
+package requests
+
+import (
+	"net/http"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseCIDRs("10.42.0.0/16, 127.0.0.1/32")
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "192.168.0.10:51234",
+			want:       "192.168.0.10",
+		},
+		{
+			name:         "forwarded for ignored from untrusted address",
+			remoteAddr:   "192.168.0.10:51234",
+			forwardedFor: []string{"1.2.3.4"},
+			want:         "192.168.0.10",
+		},
+		{
+			name:         "forwarded by trusted proxy",
+			remoteAddr:   "10.42.0.5:51234",
+			forwardedFor: []string{"1.2.3.4"},
+			want:         "1.2.3.4",
+		},
+		{
+			name:         "spoofed address to the left of the client",
+			remoteAddr:   "10.42.0.5:51234",
+			forwardedFor: []string{"9.9.9.9, 1.2.3.4", "127.0.0.1"},
+			want:         "1.2.3.4",
+		},
+		{
+			name:         "invalid forwarded address",
+			remoteAddr:   "10.42.0.5:51234",
+			forwardedFor: []string{"garbage"},
+			want:         "10.42.0.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v3/clusters", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tt.remoteAddr
+			for _, f := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", f)
+			}
+			assert.Equal(t, tt.want, clientIP(req, trusted).String())
+		})
+	}
+}
+
+func TestCheckTokenScopes(t *testing.T) {
+	require.NoError(t, settings.TrustedProxyCIDRs.Set(""))
+	token := &v32.Token{
+		Scopes:      []v32.TokenScope{{Verbs: []string{"get", "list", "watch"}}},
+		SourceCIDRs: []string{"192.168.0.0/24"},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		uri        string
+		remoteAddr string
+		wantErr    bool
+	}{
+		{
+			name:       "allowed",
+			method:     http.MethodGet,
+			uri:        "/v1/management.cattle.io.clusters",
+			remoteAddr: "192.168.0.10:51234",
+		},
+		{
+			name:       "verb not allowed",
+			method:     http.MethodDelete,
+			uri:        "/k8s/clusters/c-m-abcde/api/v1/namespaces/default/pods/web",
+			remoteAddr: "192.168.0.10:51234",
+			wantErr:    true,
+		},
+		{
+			name:       "exec not allowed by a read only scope",
+			method:     http.MethodGet,
+			uri:        "/k8s/clusters/c-m-abcde/api/v1/namespaces/default/pods/web/exec?command=sh",
+			remoteAddr: "192.168.0.10:51234",
+			wantErr:    true,
+		},
+		{
+			name:       "address not allowed",
+			method:     http.MethodGet,
+			uri:        "/v1/management.cattle.io.clusters",
+			remoteAddr: "192.168.1.10:51234",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.uri, nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tt.remoteAddr
+			err = checkTokenScopes(req, token)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMustAuthenticate)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package requests
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// lastUsedUpdateInterval is how often the usage of a token is written, so that busy tokens
+	// don't cause a write for every request.
+	lastUsedUpdateInterval = time.Minute
+	// maxRecordedTokens is the number of tokens whose last write is remembered before old entries are pruned.
+	maxRecordedTokens = 10000
+)
+
+type tokenPatcher interface {
+	Patch(name string, o runtime.Object, patchType types.PatchType, data []byte, subresources ...string) (runtime.Object, error)
+}
+
+// tokenUsageRecorder records when and from where tokens were last used.
+type tokenUsageRecorder struct {
+	tokens tokenPatcher
+	now    func() time.Time
+
+	lock sync.Mutex
+	// recorded holds when the usage of a token was last written. The token in the cache may not
+	// have caught up with the write yet, so it can't be relied on alone.
+	recorded map[string]time.Time
+}
+
+func newTokenUsageRecorder(tokens tokenPatcher) *tokenUsageRecorder {
+	return &tokenUsageRecorder{
+		tokens:   tokens,
+		now:      time.Now,
+		recorded: map[string]time.Time{},
+	}
+}
+
+// record writes the last used time and address of the token in the background, unless it was
+// recorded less than lastUsedUpdateInterval ago.
+func (r *tokenUsageRecorder) record(token *v3.Token, ip net.IP) {
+	if r == nil || r.tokens == nil {
+		return
+	}
+	now := r.now()
+	if token.LastUsedAt != nil && now.Sub(token.LastUsedAt.Time) < lastUsedUpdateInterval {
+		return
+	}
+
+	r.lock.Lock()
+	if last, ok := r.recorded[token.Name]; ok && now.Sub(last) < lastUsedUpdateInterval {
+		r.lock.Unlock()
+		return
+	}
+	if len(r.recorded) >= maxRecordedTokens {
+		for name, last := range r.recorded {
+			if now.Sub(last) >= lastUsedUpdateInterval {
+				delete(r.recorded, name)
+			}
+		}
+	}
+	r.recorded[token.Name] = now
+	r.lock.Unlock()
+
+	lastUsedFrom := ""
+	if ip != nil {
+		lastUsedFrom = ip.String()
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"lastUsedAt":   metav1.NewTime(now),
+		"lastUsedFrom": lastUsedFrom,
+	})
+	if err != nil {
+		logrus.Errorf("Failed to build the usage patch of token %s: %v", token.Name, err)
+		return
+	}
+
+	token = token.DeepCopy()
+	go func() {
+		if _, err := r.tokens.Patch(token.Name, token, types.MergePatchType, patch); err != nil {
+			logrus.Debugf("Failed to record the usage of token %s: %v", token.Name, err)
+		}
+	}()
+}
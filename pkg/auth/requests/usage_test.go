@@ -0,0 +1,59 @@
+package requests
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeTokenPatcher struct {
+	lock    sync.Mutex
+	patches map[string][]byte
+	done    chan struct{}
+}
+
+func (f *fakeTokenPatcher) Patch(name string, o runtime.Object, patchType types.PatchType, data []byte, subresources ...string) (runtime.Object, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.patches[name] = data
+	f.done <- struct{}{}
+	return o, nil
+}
+
+func TestTokenUsageRecorder(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	patcher := &fakeTokenPatcher{patches: map[string][]byte{}, done: make(chan struct{}, 10)}
+	recorder := newTokenUsageRecorder(patcher)
+	recorder.now = func() time.Time { return now }
+
+	token := &v3.Token{ObjectMeta: metav1.ObjectMeta{Name: "token-abcde"}}
+	recorder.record(token, net.ParseIP("192.168.0.10"))
+	<-patcher.done
+
+	var patch map[string]string
+	require.NoError(t, json.Unmarshal(patcher.patches["token-abcde"], &patch))
+	assert.Equal(t, "2024-03-01T12:00:00Z", patch["lastUsedAt"])
+	assert.Equal(t, "192.168.0.10", patch["lastUsedFrom"])
+
+	// Further uses within the update interval are not written, even if the cache has not caught up yet.
+	now = now.Add(30 * time.Second)
+	recorder.record(token, net.ParseIP("192.168.0.11"))
+	recentlyUsed := &v3.Token{ObjectMeta: metav1.ObjectMeta{Name: "token-fghij"}, LastUsedAt: &metav1.Time{Time: now.Add(-time.Second)}}
+	recorder.record(recentlyUsed, nil)
+	assert.Len(t, patcher.done, 0)
+
+	now = now.Add(lastUsedUpdateInterval)
+	recorder.record(token, net.ParseIP("192.168.0.11"))
+	<-patcher.done
+	require.NoError(t, json.Unmarshal(patcher.patches["token-abcde"], &patch))
+	assert.Equal(t, "192.168.0.11", patch["lastUsedFrom"])
+}
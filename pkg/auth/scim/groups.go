@@ -0,0 +1,563 @@
+package scim
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+// Group is a SCIM group.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// group is a group stored by the SCIM server, its members are stored in their UserAttributes.
+type group struct {
+	ID          string `json:"id"`
+	ExternalID  string `json:"externalId,omitempty"`
+	DisplayName string `json:"displayName"`
+	Created     string `json:"created,omitempty"`
+}
+
+// groupPrincipalsKey returns the key of the group principals of the UserAttributes that the memberships of the SCIM
+// groups of the provider are stored under. Logins and provider refreshes replace the group principals of the provider
+// key with the groups the provider returns, which don't include the groups pushed to the SCIM server.
+func groupPrincipalsKey(provider string) string {
+	return "scim/" + provider
+}
+
+func (g *group) principalID(provider string) string {
+	if g.ExternalID != "" {
+		return groupPrincipalID(provider, g.ExternalID)
+	}
+	return groupPrincipalID(provider, g.DisplayName)
+}
+
+// groupID returns the ID of a new group with the given principal ID.
+func groupID(principalID string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(principalID))
+	sha := base32.StdEncoding.WithPadding(-1).EncodeToString(hasher.Sum(nil))[:10]
+	return "g-" + strings.ToLower(sha)
+}
+
+func (h *Handler) listGroups(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	f, err := parseFilter(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	groups, err := h.groups.list(provider)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	members, err := h.members(provider)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	excludeMembers := strings.EqualFold(req.URL.Query().Get("excludedAttributes"), "members")
+
+	resources := []interface{}{}
+	for i := range groups {
+		g := &groups[i]
+		if !f.matches(map[string]string{"id": g.ID, "displayName": g.DisplayName, "externalId": g.ExternalID}) {
+			continue
+		}
+		scimGroup := toSCIMGroup(provider, g, members[g.principalID(provider)])
+		if excludeMembers {
+			scimGroup.Members = nil
+		}
+		resources = append(resources, scimGroup)
+	}
+	writeList(rw, req, resources)
+}
+
+func (h *Handler) getGroup(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	g, err := h.getGroupByID(provider, mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.writeGroup(rw, req, http.StatusOK, provider, g)
+}
+
+func (h *Handler) createGroup(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	var in Group
+	if err := readJSON(req, &in); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if in.DisplayName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "displayName is required"))
+		return
+	}
+
+	g := &group{
+		ExternalID:  in.ExternalID,
+		DisplayName: in.DisplayName,
+		Created:     time.Now().UTC().Format(timeFormat),
+	}
+	principalID := g.principalID(provider)
+	g.ID = groupID(principalID)
+	if err := h.checkGroupUnique(provider, g, ""); err != nil {
+		writeError(rw, err)
+		return
+	}
+	members := memberIDs(in.Members)
+	if err := h.checkMembers(members); err != nil {
+		writeError(rw, err)
+		return
+	}
+	// the group is created before its members are set, so that only one of concurrent creations sets them
+	if err := h.groups.create(provider, *g); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.setMembers(provider, g, principalID, members); err != nil {
+		writeError(rw, err)
+		return
+	}
+	logrus.Infof("[scim] Provisioned group %s for principal %s", g.ID, principalID)
+	h.writeGroup(rw, req, http.StatusCreated, provider, g)
+}
+
+func (h *Handler) replaceGroup(rw http.ResponseWriter, req *http.Request) {
+	var in Group
+	if err := readJSON(req, &in); err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.modifyGroup(rw, req, func(g *group, members []string) ([]string, error) {
+		g.ExternalID = in.ExternalID
+		g.DisplayName = in.DisplayName
+		return memberIDs(in.Members), nil
+	})
+}
+
+func (h *Handler) patchGroup(rw http.ResponseWriter, req *http.Request) {
+	operations, err := readPatch(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.modifyGroup(rw, req, func(g *group, members []string) ([]string, error) {
+		for _, op := range operations {
+			var err error
+			if members, err = applyGroupPatch(g, members, op); err != nil {
+				return nil, err
+			}
+		}
+		return members, nil
+	})
+}
+
+// modifyGroup updates the group of the request and its members with the given modification.
+func (h *Handler) modifyGroup(rw http.ResponseWriter, req *http.Request, modify func(g *group, members []string) ([]string, error)) {
+	provider := mux.Vars(req)["provider"]
+
+	g, err := h.getGroupByID(provider, mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	oldPrincipalID := g.principalID(provider)
+	allMembers, err := h.members(provider)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	members, err := modify(g, allMembers[oldPrincipalID])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if g.DisplayName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "displayName is required"))
+		return
+	}
+	if err := h.checkGroupUnique(provider, g, g.ID); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.setMembers(provider, g, oldPrincipalID, members); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.groups.save(provider, *g); err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.writeGroup(rw, req, http.StatusOK, provider, g)
+}
+
+// deleteGroup deletes the group and removes it from the groups of its members.
+func (h *Handler) deleteGroup(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+
+	g, err := h.getGroupByID(provider, mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.setMembers(provider, g, g.principalID(provider), nil); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.groups.remove(provider, g.ID); err != nil {
+		writeError(rw, err)
+		return
+	}
+	logrus.Infof("[scim] Deprovisioned group %s", g.ID)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// applyGroupPatch applies the operation to the group and returns its new members.
+func applyGroupPatch(g *group, members []string, op patchOperation) ([]string, error) {
+	path := strings.ToLower(op.Path)
+	if match := memberFilterRegexp.FindStringSubmatch(op.Path); match != nil {
+		if !strings.EqualFold(op.Op, "remove") {
+			return nil, newError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported path %q", op.Path))
+		}
+		var id string
+		if err := json.Unmarshal([]byte(match[1]), &id); err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("invalid path %q", op.Path))
+		}
+		return removeMembers(members, []string{id}), nil
+	}
+
+	switch {
+	case path == "members":
+		var value []Member
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, newError(http.StatusBadRequest, "invalidValue", "members must be a list of members")
+			}
+		}
+		switch strings.ToLower(op.Op) {
+		case "add":
+			return addMembers(members, memberIDs(value)), nil
+		case "remove":
+			if len(op.Value) == 0 {
+				return nil, nil
+			}
+			return removeMembers(members, memberIDs(value)), nil
+		default:
+			return memberIDs(value), nil
+		}
+	case path == "":
+		if strings.EqualFold(op.Op, "remove") {
+			return nil, newError(http.StatusBadRequest, "noTarget", "remove operations require a path")
+		}
+		attributes, err := op.attributes()
+		if err != nil {
+			return nil, err
+		}
+		for attribute, value := range attributes {
+			if members, err = applyGroupPatch(g, members, patchOperation{Op: op.Op, Path: attribute, Value: value}); err != nil {
+				return nil, err
+			}
+		}
+		return members, nil
+	case strings.EqualFold(op.Op, "remove"):
+		if path == "externalid" {
+			g.ExternalID = ""
+		}
+		return members, nil
+	case path == "displayname":
+		displayName, err := stringValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		g.DisplayName = displayName
+	case path == "externalid":
+		externalID, err := stringValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		g.ExternalID = externalID
+	}
+	return members, nil
+}
+
+func addMembers(members, ids []string) []string {
+	for _, id := range ids {
+		if !contains(members, id) {
+			members = append(members, id)
+		}
+	}
+	return members
+}
+
+func removeMembers(members, ids []string) []string {
+	var result []string
+	for _, member := range members {
+		if !contains(ids, member) {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+func memberIDs(members []Member) []string {
+	var ids []string
+	for _, member := range members {
+		if !contains(ids, member.Value) {
+			ids = append(ids, member.Value)
+		}
+	}
+	return ids
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) getGroupByID(provider, id string) (*group, error) {
+	groups, err := h.groups.list(provider)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if groups[i].ID == id {
+			return &groups[i], nil
+		}
+	}
+	return nil, newError(http.StatusNotFound, "", fmt.Sprintf("group %s not found", id))
+}
+
+// checkGroupUnique returns a uniqueness error if a group of the provider other than the group with the given ID has
+// the principal of the group.
+func (h *Handler) checkGroupUnique(provider string, g *group, id string) error {
+	groups, err := h.groups.list(provider)
+	if err != nil {
+		return err
+	}
+	principalID := g.principalID(provider)
+	for i := range groups {
+		if groups[i].ID != id && groups[i].principalID(provider) == principalID {
+			return newError(http.StatusConflict, "uniqueness", fmt.Sprintf("group %s already exists", g.DisplayName))
+		}
+	}
+	return nil
+}
+
+// groupIDs returns the IDs of the groups of the provider by principal ID.
+func (h *Handler) groupIDs(provider string) (map[string]string, error) {
+	groups, err := h.groups.list(provider)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]string{}
+	for i := range groups {
+		ids[groups[i].principalID(provider)] = groups[i].ID
+	}
+	return ids, nil
+}
+
+// members returns the names of the users that are members of the group principals of the provider, by principal ID.
+func (h *Handler) members(provider string) (map[string][]string, error) {
+	attributes, err := h.userAttributeLister.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	members := map[string][]string{}
+	for _, attribs := range attributes {
+		for _, principal := range attribs.GroupPrincipals[groupPrincipalsKey(provider)].Items {
+			members[principal.Name] = append(members[principal.Name], attribs.Name)
+		}
+	}
+	for _, names := range members {
+		sort.Strings(names)
+	}
+	return members, nil
+}
+
+// setMembers makes the given users the only members of the group, which had the principal oldPrincipalID, by updating
+// the group principals of the provider in the UserAttributes of the users.
+func (h *Handler) setMembers(provider string, g *group, oldPrincipalID string, members []string) error {
+	if err := h.checkMembers(members); err != nil {
+		return err
+	}
+
+	principal := v32.Principal{
+		ObjectMeta:    metav1.ObjectMeta{Name: g.principalID(provider)},
+		DisplayName:   g.DisplayName,
+		PrincipalType: "group",
+		MemberOf:      true,
+		Provider:      provider,
+	}
+	isGroup := func(p v32.Principal) bool {
+		return p.Name == oldPrincipalID || p.Name == principal.Name
+	}
+
+	attributes, err := h.userAttributeLister.List("", labels.Everything())
+	if err != nil {
+		return err
+	}
+	pending := map[string]bool{}
+	for _, member := range members {
+		pending[member] = true
+	}
+	for _, attribs := range attributes {
+		isMember := pending[attribs.Name]
+		delete(pending, attribs.Name)
+
+		var current *v32.Principal
+		principals := attribs.GroupPrincipals[groupPrincipalsKey(provider)].Items
+		for i, p := range principals {
+			if isGroup(p) {
+				current = &principals[i]
+				break
+			}
+		}
+		if isMember && current != nil && current.Name == principal.Name && current.DisplayName == principal.DisplayName {
+			continue
+		}
+		if !isMember && current == nil {
+			continue
+		}
+		if err := h.updateGroupPrincipals(attribs.Name, provider, isGroup, principal, isMember); err != nil {
+			return err
+		}
+	}
+	for member := range pending {
+		if err := h.updateGroupPrincipals(member, provider, isGroup, principal, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMembers returns an error if one of the members is not a user.
+func (h *Handler) checkMembers(members []string) error {
+	for _, member := range members {
+		if _, err := h.userLister.Get("", member); apierrors.IsNotFound(err) {
+			return newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("member %s is not a user", member))
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateGroupPrincipals removes the principals of the group from the group principals of the provider of the user, and
+// adds the principal if the user is a member of the group. The UserAttribute is created if the user has none yet.
+func (h *Handler) updateGroupPrincipals(userName, provider string, isGroup func(v32.Principal) bool, principal v32.Principal, isMember bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		attribs, err := h.userAttributes.Get(userName, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if create {
+			user, err := h.userLister.Get("", userName)
+			if err != nil {
+				return err
+			}
+			attribs = newUserAttribute(user)
+		} else if err != nil {
+			return err
+		}
+		if attribs.GroupPrincipals == nil {
+			attribs.GroupPrincipals = map[string]v32.Principals{}
+		}
+
+		key := groupPrincipalsKey(provider)
+		var items []v32.Principal
+		for _, p := range attribs.GroupPrincipals[key].Items {
+			if !isGroup(p) {
+				items = append(items, p)
+			}
+		}
+		if isMember {
+			items = append(items, principal)
+		}
+		attribs.GroupPrincipals[key] = v32.Principals{Items: items}
+
+		if create {
+			_, err = h.userAttributes.Create(attribs)
+			if apierrors.IsAlreadyExists(err) {
+				// created by another replica in the meantime
+				return apierrors.NewConflict(v32.Resource("userattributes"), userName, err)
+			}
+		} else {
+			_, err = h.userAttributes.Update(attribs)
+		}
+		return err
+	})
+}
+
+func newUserAttribute(user *v3.User) *v3.UserAttribute {
+	return &v3.UserAttribute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: user.Name,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: user.APIVersion,
+					Kind:       user.Kind,
+					UID:        user.UID,
+					Name:       user.Name,
+				},
+			},
+		},
+		GroupPrincipals: map[string]v32.Principals{},
+		ExtraByProvider: map[string]map[string][]string{},
+	}
+}
+
+func (h *Handler) writeGroup(rw http.ResponseWriter, req *http.Request, status int, provider string, g *group) {
+	members, err := h.members(provider)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	scimGroup := toSCIMGroup(provider, g, members[g.principalID(provider)])
+	if status == http.StatusCreated {
+		rw.Header().Set("Location", scimGroup.Meta.Location)
+	}
+	writeJSON(rw, status, scimGroup)
+}
+
+func toSCIMGroup(provider string, g *group, members []string) *Group {
+	scimGroup := &Group{
+		Schemas:     []string{groupSchema},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      g.Created,
+			Location:     location(provider, "Groups", g.ID),
+		},
+	}
+	for _, member := range members {
+		scimGroup.Members = append(scimGroup.Members, Member{Value: member})
+	}
+	return scimGroup
+}
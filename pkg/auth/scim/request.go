@@ -0,0 +1,157 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// filterRegexp matches the `<attribute> eq "<value>"` filters identity providers use to look up resources before
+// provisioning them, the only filters the server supports.
+var filterRegexp = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9.]*)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// memberFilterRegexp matches the paths of the members of a group, e.g. members[value eq "u-abc"].
+var memberFilterRegexp = regexp.MustCompile(`^(?i:members)\[\s*(?i:value)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*\]$`)
+
+type filter struct {
+	attribute string
+	value     string
+}
+
+func parseFilter(req *http.Request) (*filter, error) {
+	s := req.URL.Query().Get("filter")
+	if s == "" {
+		return nil, nil
+	}
+	match := filterRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return nil, newError(http.StatusBadRequest, "invalidFilter", fmt.Sprintf("unsupported filter %q", s))
+	}
+	f := &filter{attribute: match[1]}
+	if err := json.Unmarshal([]byte(match[2]), &f.value); err != nil {
+		return nil, newError(http.StatusBadRequest, "invalidFilter", fmt.Sprintf("invalid filter value %s", match[2]))
+	}
+	return f, nil
+}
+
+// matches returns whether the value of the attribute is the value of the filter. Values of attributes the resource
+// does not have never match.
+func (f *filter) matches(attributes map[string]string) bool {
+	if f == nil {
+		return true
+	}
+	for attribute, value := range attributes {
+		if strings.EqualFold(attribute, f.attribute) {
+			return strings.EqualFold(value, f.value)
+		}
+	}
+	return false
+}
+
+type listResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// writeList writes the page of the resources requested by the startIndex and count parameters.
+func writeList(rw http.ResponseWriter, req *http.Request, resources []interface{}) {
+	startIndex := queryInt(req, "startIndex", 1)
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count := queryInt(req, "count", maxResults)
+	if count < 0 {
+		count = 0
+	}
+	if count > maxResults {
+		count = maxResults
+	}
+
+	page := []interface{}{}
+	if start := startIndex - 1; start < len(resources) {
+		end := start + count
+		if end > len(resources) {
+			end = len(resources)
+		}
+		page = resources[start:end]
+	}
+	writeJSON(rw, http.StatusOK, listResponse{
+		Schemas:      []string{listSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	})
+}
+
+func queryInt(req *http.Request, key string, def int) int {
+	value, err := strconv.Atoi(req.URL.Query().Get(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func readPatch(req *http.Request) ([]patchOperation, error) {
+	var patch patchRequest
+	if err := readJSON(req, &patch); err != nil {
+		return nil, err
+	}
+	for _, op := range patch.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace", "remove":
+		default:
+			return nil, newError(http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("unsupported patch operation %q", op.Op))
+		}
+	}
+	return patch.Operations, nil
+}
+
+// attributes returns the attributes set by the value of an operation without path, keyed by their path.
+func (op patchOperation) attributes() (map[string]json.RawMessage, error) {
+	attributes := map[string]json.RawMessage{}
+	if err := json.Unmarshal(op.Value, &attributes); err != nil {
+		return nil, newError(http.StatusBadRequest, "invalidValue", "the value of a patch operation without path must be an object")
+	}
+	return attributes, nil
+}
+
+func stringValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid string %s", raw))
+	}
+	return s, nil
+}
+
+// boolValue parses a boolean, some identity providers send them as strings such as "False".
+func boolValue(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, newError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid boolean %s", raw))
+}
@@ -0,0 +1,242 @@
+// Package scim implements a SCIM 2.0 server (RFC 7643 and RFC 7644) that identity providers push their users and
+// groups to, so that Rancher learns about provisioning changes immediately instead of at the next login or
+// auth-user-info-resync-cron refresh.
+//
+// The server of an enabled auth provider is served at /v1-scim/<provider> once the secret scim-<provider> of the
+// cattle-global-data namespace holds the bearer token of the identity provider in its token key. SCIM users are
+// mapped to the Rancher users of the principal <provider>_user://<externalId or userName> and SCIM groups to the
+// principal <provider>_group://<externalId or displayName>, the principals the provider authenticates users with.
+// Deactivating a user disables it and deletes its tokens, deleting a user deletes it along with its bindings. Users
+// that logged in before being provisioned are adopted, but only the users the server created can have their principal
+// changed. Group memberships are stored in the UserAttributes of the members, under the scim/<provider> key of their
+// group principals that logins and provider refreshes leave alone.
+//
+// Every replica serves the server, so all changes are made with optimistic concurrency instead of local locks.
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/rancher/pkg/auth/providers"
+	"github.com/rancher/rancher/pkg/auth/providers/local"
+	corev1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// PathPrefix is the path the SCIM servers of all providers are served under.
+	PathPrefix = "/v1-scim"
+
+	secretPrefix = "scim-"
+	tokenKey     = "token"
+
+	contentType = "application/scim+json"
+
+	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listSchema                  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	// maxResults is the maximum number of resources returned by a list request.
+	maxResults = 500
+)
+
+// userManager is the subset of user.Manager the server uses.
+type userManager interface {
+	EnsureUser(principalName, displayName string) (*v3.User, error)
+	GetUserByPrincipalID(principalName string) (*v3.User, error)
+}
+
+// Handler serves the SCIM servers of the auth providers.
+type Handler struct {
+	userManager         userManager
+	users               v3.UserInterface
+	userLister          v3.UserLister
+	userAttributes      v3.UserAttributeInterface
+	userAttributeLister v3.UserAttributeLister
+	tokens              v3.TokenInterface
+	tokenLister         v3.TokenLister
+	secretLister        corev1.SecretLister
+	groups              groupStore
+	providerEnabled     func(provider string) bool
+}
+
+func NewHandler(mgmt *config.ScaledContext) http.Handler {
+	h := &Handler{
+		userManager:         mgmt.UserManager,
+		users:               mgmt.Management.Users(""),
+		userLister:          mgmt.Management.Users("").Controller().Lister(),
+		userAttributes:      mgmt.Management.UserAttributes(""),
+		userAttributeLister: mgmt.Management.UserAttributes("").Controller().Lister(),
+		tokens:              mgmt.Management.Tokens(""),
+		tokenLister:         mgmt.Management.Tokens("").Controller().Lister(),
+		secretLister:        mgmt.Core.Secrets("").Controller().Lister(),
+		groups: &configMapGroupStore{
+			configMaps:      mgmt.Core.ConfigMaps(namespace.GlobalNamespace),
+			configMapLister: mgmt.Core.ConfigMaps("").Controller().Lister(),
+		},
+		providerEnabled: providerEnabled,
+	}
+	return h.router()
+}
+
+func (h *Handler) router() http.Handler {
+	root := mux.NewRouter()
+	root.UseEncodedPath()
+	r := root.PathPrefix(PathPrefix + "/{provider}").Subrouter()
+	r.Use(h.authenticate)
+	r.Methods(http.MethodGet).Path("/ServiceProviderConfig").HandlerFunc(serviceProviderConfig)
+	r.Methods(http.MethodGet).Path("/Users").HandlerFunc(h.listUsers)
+	r.Methods(http.MethodPost).Path("/Users").HandlerFunc(h.createUser)
+	r.Methods(http.MethodGet).Path("/Users/{id}").HandlerFunc(h.getUser)
+	r.Methods(http.MethodPut).Path("/Users/{id}").HandlerFunc(h.replaceUser)
+	r.Methods(http.MethodPatch).Path("/Users/{id}").HandlerFunc(h.patchUser)
+	r.Methods(http.MethodDelete).Path("/Users/{id}").HandlerFunc(h.deleteUser)
+	r.Methods(http.MethodGet).Path("/Groups").HandlerFunc(h.listGroups)
+	r.Methods(http.MethodPost).Path("/Groups").HandlerFunc(h.createGroup)
+	r.Methods(http.MethodGet).Path("/Groups/{id}").HandlerFunc(h.getGroup)
+	r.Methods(http.MethodPut).Path("/Groups/{id}").HandlerFunc(h.replaceGroup)
+	r.Methods(http.MethodPatch).Path("/Groups/{id}").HandlerFunc(h.patchGroup)
+	r.Methods(http.MethodDelete).Path("/Groups/{id}").HandlerFunc(h.deleteGroup)
+	root.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		writeError(rw, newError(http.StatusNotFound, "", "resource not found"))
+	})
+	return root
+}
+
+// authenticate rejects the requests to providers that are not enabled, or whose bearer token does not match the token
+// of the provider.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		provider := mux.Vars(req)["provider"]
+		token, err := h.providerToken(provider)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(bearer)), []byte(token)) != 1 {
+			writeError(rw, newError(http.StatusUnauthorized, "", "invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func (h *Handler) providerToken(provider string) (string, error) {
+	notEnabled := newError(http.StatusNotFound, "", fmt.Sprintf("SCIM is not enabled for provider %s", provider))
+	if provider == local.Name || !providers.ProviderNames[provider] {
+		return "", notEnabled
+	}
+	if !h.providerEnabled(provider) {
+		return "", notEnabled
+	}
+	secret, err := h.secretLister.Get(namespace.GlobalNamespace, secretPrefix+provider)
+	if apierrors.IsNotFound(err) {
+		return "", notEnabled
+	} else if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(secret.Data[tokenKey]))
+	if token == "" {
+		return "", notEnabled
+	}
+	return token, nil
+}
+
+// providerEnabled returns whether the provider is configured and enabled.
+func providerEnabled(provider string) bool {
+	disabled, err := providers.IsDisabledProvider(provider)
+	// providers that were never configured are not registered
+	return err == nil && !disabled
+}
+
+func userPrincipalID(provider, id string) string {
+	return provider + "_user://" + id
+}
+
+func groupPrincipalID(provider, id string) string {
+	return provider + "_group://" + id
+}
+
+// location returns the URL of the resource, relative to the server-url setting if it is set.
+func location(provider, resourceType, id string) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s", strings.TrimSuffix(settings.ServerURL.Get(), "/"), PathPrefix, provider, resourceType, id)
+}
+
+func serviceProviderConfig(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{serviceProviderConfigSchema},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxResults},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication with the bearer token of the scim-<provider> secret",
+		}},
+	})
+}
+
+// Error is a SCIM error response.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+
+	status int
+}
+
+func newError(status int, scimType, detail string) *Error {
+	return &Error{
+		Schemas:  []string{errorSchema},
+		Status:   fmt.Sprint(status),
+		ScimType: scimType,
+		Detail:   detail,
+		status:   status,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func writeError(rw http.ResponseWriter, err error) {
+	var scimErr *Error
+	if !errors.As(err, &scimErr) {
+		logrus.Errorf("[scim] Failed to process request: %v", err)
+		scimErr = newError(http.StatusInternalServerError, "", "internal error")
+	}
+	writeJSON(rw, scimErr.status, scimErr)
+}
+
+func writeJSON(rw http.ResponseWriter, status int, obj interface{}) {
+	rw.Header().Set("Content-Type", contentType)
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(obj); err != nil {
+		logrus.Errorf("[scim] Failed to write response: %v", err)
+	}
+}
+
+func readJSON(req *http.Request, obj interface{}) error {
+	if err := json.NewDecoder(req.Body).Decode(obj); err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("invalid request body: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers"
+	corefakes "github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	testProvider = "azuread"
+	testToken    = "scim-token"
+)
+
+// fakeUserManager creates users named after their principal.
+type fakeUserManager struct {
+	users map[string]*v3.User
+}
+
+func (f *fakeUserManager) EnsureUser(principalName, displayName string) (*v3.User, error) {
+	if user, _ := f.GetUserByPrincipalID(principalName); user != nil {
+		return user, nil
+	}
+	name := "u-" + strings.TrimPrefix(principalName, testProvider+"_user://")
+	f.users[name] = &v3.User{ObjectMeta: metav1.ObjectMeta{Name: name}, DisplayName: displayName, PrincipalIDs: []string{principalName}}
+	return f.users[name].DeepCopy(), nil
+}
+
+func (f *fakeUserManager) GetUserByPrincipalID(principalName string) (*v3.User, error) {
+	for _, user := range f.users {
+		for _, id := range user.PrincipalIDs {
+			if id == principalName {
+				return user.DeepCopy(), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+type fakeGroupStore map[string]group
+
+func (f fakeGroupStore) list(provider string) ([]group, error) {
+	var groups []group
+	for _, g := range f {
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+func (f fakeGroupStore) create(provider string, g group) error {
+	if _, ok := f[g.ID]; ok {
+		return newError(http.StatusConflict, "uniqueness", "group exists")
+	}
+	f[g.ID] = g
+	return nil
+}
+
+func (f fakeGroupStore) save(provider string, g group) error {
+	f[g.ID] = g
+	return nil
+}
+
+func (f fakeGroupStore) remove(provider, id string) error {
+	delete(f, id)
+	return nil
+}
+
+type testServer struct {
+	handler    http.Handler
+	users      map[string]*v3.User
+	attributes map[string]*v3.UserAttribute
+	tokens     map[string]*v3.Token
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	providers.ProviderNames[testProvider] = true
+	t.Cleanup(func() { delete(providers.ProviderNames, testProvider) })
+
+	s := &testServer{
+		users:      map[string]*v3.User{},
+		attributes: map[string]*v3.UserAttribute{},
+		tokens:     map[string]*v3.Token{},
+	}
+	usersNotFound := func(name string) error {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "users"}, name)
+	}
+	attributesNotFound := func(name string) error {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "userattributes"}, name)
+	}
+	getUser := func(name string) (*v3.User, error) {
+		if user, ok := s.users[name]; ok {
+			return user.DeepCopy(), nil
+		}
+		return nil, usersNotFound(name)
+	}
+	getAttributes := func(name string) (*v3.UserAttribute, error) {
+		if attribs, ok := s.attributes[name]; ok {
+			return attribs.DeepCopy(), nil
+		}
+		return nil, attributesNotFound(name)
+	}
+
+	h := &Handler{
+		userManager: &fakeUserManager{users: s.users},
+		users: &fakes.UserInterfaceMock{
+			GetFunc: func(name string, opts metav1.GetOptions) (*v3.User, error) {
+				return getUser(name)
+			},
+			UpdateFunc: func(user *v3.User) (*v3.User, error) {
+				s.users[user.Name] = user.DeepCopy()
+				return user, nil
+			},
+			DeleteFunc: func(name string, options *metav1.DeleteOptions) error {
+				delete(s.users, name)
+				return nil
+			},
+		},
+		userLister: &fakes.UserListerMock{
+			GetFunc: func(namespace, name string) (*v3.User, error) {
+				return getUser(name)
+			},
+			ListFunc: func(namespace string, selector labels.Selector) ([]*v3.User, error) {
+				var users []*v3.User
+				for _, user := range s.users {
+					if selector.Matches(labels.Set(user.Labels)) {
+						users = append(users, user.DeepCopy())
+					}
+				}
+				return users, nil
+			},
+		},
+		userAttributes: &fakes.UserAttributeInterfaceMock{
+			GetFunc: func(name string, opts metav1.GetOptions) (*v3.UserAttribute, error) {
+				return getAttributes(name)
+			},
+			CreateFunc: func(attribs *v3.UserAttribute) (*v3.UserAttribute, error) {
+				s.attributes[attribs.Name] = attribs.DeepCopy()
+				return attribs, nil
+			},
+			UpdateFunc: func(attribs *v3.UserAttribute) (*v3.UserAttribute, error) {
+				s.attributes[attribs.Name] = attribs.DeepCopy()
+				return attribs, nil
+			},
+		},
+		userAttributeLister: &fakes.UserAttributeListerMock{
+			GetFunc: func(namespace, name string) (*v3.UserAttribute, error) {
+				return getAttributes(name)
+			},
+			ListFunc: func(namespace string, selector labels.Selector) ([]*v3.UserAttribute, error) {
+				var attributes []*v3.UserAttribute
+				for _, attribs := range s.attributes {
+					attributes = append(attributes, attribs.DeepCopy())
+				}
+				return attributes, nil
+			},
+		},
+		tokens: &fakes.TokenInterfaceMock{
+			DeleteFunc: func(name string, options *metav1.DeleteOptions) error {
+				delete(s.tokens, name)
+				return nil
+			},
+		},
+		tokenLister: &fakes.TokenListerMock{
+			ListFunc: func(namespace string, selector labels.Selector) ([]*v3.Token, error) {
+				var tokens []*v3.Token
+				for _, token := range s.tokens {
+					tokens = append(tokens, token.DeepCopy())
+				}
+				return tokens, nil
+			},
+		},
+		secretLister: &corefakes.SecretListerMock{
+			GetFunc: func(ns, name string) (*corev1.Secret, error) {
+				if ns == namespace.GlobalNamespace && name == "scim-"+testProvider {
+					return &corev1.Secret{Data: map[string][]byte{"token": []byte(testToken + "\n")}}, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+			},
+		},
+		groups:          fakeGroupStore{},
+		providerEnabled: func(provider string) bool { return provider == testProvider },
+	}
+	s.handler = h.router()
+	return s
+}
+
+// do sends the request to the server and decodes its response into out, if it is not nil.
+func (s *testServer) do(t *testing.T, method, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, PathPrefix+"/"+testProvider+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rw := httptest.NewRecorder()
+	s.handler.ServeHTTP(rw, req)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), out), rw.Body.String())
+	}
+	return rw.Code
+}
+
+func (s *testServer) groupPrincipals(userName string) []string {
+	var names []string
+	if attribs, ok := s.attributes[userName]; ok {
+		for _, p := range attribs.GroupPrincipals[groupPrincipalsKey(testProvider)].Items {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{name: "valid token", path: "/v1-scim/azuread/ServiceProviderConfig", authorization: "Bearer " + testToken, want: http.StatusOK},
+		{name: "missing token", path: "/v1-scim/azuread/ServiceProviderConfig", want: http.StatusUnauthorized},
+		{name: "invalid token", path: "/v1-scim/azuread/Users", authorization: "Bearer invalid", want: http.StatusUnauthorized},
+		{name: "basic auth", path: "/v1-scim/azuread/Users", authorization: "Basic " + testToken, want: http.StatusUnauthorized},
+		{name: "local provider", path: "/v1-scim/local/Users", authorization: "Bearer " + testToken, want: http.StatusNotFound},
+		{name: "unknown provider", path: "/v1-scim/unknown/Users", authorization: "Bearer " + testToken, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rw := httptest.NewRecorder()
+			s.handler.ServeHTTP(rw, req)
+			assert.Equal(t, tt.want, rw.Code, rw.Body.String())
+			assert.Equal(t, contentType, rw.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	s := newTestServer(t)
+
+	var created User
+	require.Equal(t, http.StatusCreated, s.do(t, http.MethodPost, "/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"externalId": "1234",
+		"userName": "jane@example.com",
+		"name": {"givenName": "Jane", "familyName": "Doe"},
+		"active": true
+	}`, &created))
+	assert.Equal(t, "u-1234", created.ID)
+	assert.Equal(t, "Jane Doe", created.DisplayName)
+	user := s.users["u-1234"]
+	assert.Equal(t, []string{"azuread_user://1234"}, user.PrincipalIDs)
+	assert.Equal(t, testProvider, user.Labels[providerLabel])
+	assert.Equal(t, "jane@example.com", user.Annotations[userNameAnnotation])
+	assert.True(t, *user.Enabled)
+
+	assert.Equal(t, http.StatusConflict, s.do(t, http.MethodPost, "/Users", `{"externalId": "1234", "userName": "jane@example.com"}`, nil))
+
+	var list listResponse
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodGet, `/Users?filter=userName+eq+%22JANE@example.com%22`, "", &list))
+	assert.Equal(t, 1, list.TotalResults)
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodGet, `/Users?filter=userName+eq+%22john@example.com%22`, "", &list))
+	assert.Equal(t, 0, list.TotalResults)
+	assert.Equal(t, http.StatusBadRequest, s.do(t, http.MethodGet, `/Users?filter=userName+sw+%22j%22`, "", nil))
+
+	// deactivating the user disables it and deletes its tokens
+	s.tokens["token-1"] = &v3.Token{ObjectMeta: metav1.ObjectMeta{Name: "token-1"}, UserID: "u-1234"}
+	s.tokens["token-2"] = &v3.Token{ObjectMeta: metav1.ObjectMeta{Name: "token-2"}, UserID: "u-other"}
+	var patched User
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodPatch, "/Users/u-1234", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "Replace", "path": "active", "value": "False"}]
+	}`, &patched))
+	assert.False(t, *patched.Active)
+	assert.False(t, *s.users["u-1234"].Enabled)
+	assert.NotContains(t, s.tokens, "token-1")
+	assert.Contains(t, s.tokens, "token-2")
+
+	// replacing the user reactivates it and renames its principal
+	var replaced User
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodPut, "/Users/u-1234", `{
+		"externalId": "5678",
+		"userName": "jane.doe@example.com",
+		"displayName": "Jane D.",
+		"active": true
+	}`, &replaced))
+	assert.Equal(t, "jane.doe@example.com", replaced.UserName)
+	assert.True(t, *replaced.Active)
+	user = s.users["u-1234"]
+	assert.Equal(t, []string{"azuread_user://5678"}, user.PrincipalIDs)
+	assert.Equal(t, "Jane D.", user.DisplayName)
+	assert.True(t, *user.Enabled)
+
+	assert.Equal(t, http.StatusNoContent, s.do(t, http.MethodDelete, "/Users/u-1234", "", nil))
+	assert.NotContains(t, s.users, "u-1234")
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodGet, "/Users/u-1234", "", nil))
+}
+
+func TestUsersOfOtherProvidersAreNotServed(t *testing.T) {
+	s := newTestServer(t)
+	s.users["u-local"] = &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-local"}, Username: "admin"}
+
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodGet, "/Users/u-local", "", nil))
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodDelete, "/Users/u-local", "", nil))
+	assert.Contains(t, s.users, "u-local")
+}
+
+func TestAdoptedUserPrincipalCannotChange(t *testing.T) {
+	s := newTestServer(t)
+	s.users["u-jane"] = &v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-jane"}, PrincipalIDs: []string{"azuread_user://jane", "local://u-jane"}}
+
+	var created User
+	require.Equal(t, http.StatusCreated, s.do(t, http.MethodPost, "/Users", `{"userName": "jane"}`, &created))
+	assert.Equal(t, "u-jane", created.ID)
+	assert.NotContains(t, s.users["u-jane"].Annotations, createdAnnotation)
+
+	assert.Equal(t, http.StatusBadRequest, s.do(t, http.MethodPatch, "/Users/u-jane", `{
+		"Operations": [{"op": "replace", "path": "userName", "value": "mallory"}]
+	}`, nil))
+	assert.Equal(t, []string{"azuread_user://jane", "local://u-jane"}, s.users["u-jane"].PrincipalIDs)
+
+	// other attributes of adopted users can still be changed
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodPatch, "/Users/u-jane", `{
+		"Operations": [{"op": "replace", "path": "displayName", "value": "Jane"}]
+	}`, nil))
+	assert.Equal(t, "Jane", s.users["u-jane"].DisplayName)
+}
+
+func TestGroups(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"jane", "john"} {
+		require.Equal(t, http.StatusCreated, s.do(t, http.MethodPost, "/Users", `{"userName": "`+name+`"}`, nil))
+	}
+
+	assert.Equal(t, http.StatusBadRequest, s.do(t, http.MethodPost, "/Groups", `{"displayName": "devs", "members": [{"value": "u-unknown"}]}`, nil))
+
+	var created Group
+	require.Equal(t, http.StatusCreated, s.do(t, http.MethodPost, "/Groups", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"displayName": "devs",
+		"members": [{"value": "u-jane"}]
+	}`, &created))
+	assert.Equal(t, []Member{{Value: "u-jane"}}, created.Members)
+	assert.Equal(t, []string{"azuread_group://devs"}, s.groupPrincipals("u-jane"))
+	assert.Equal(t, http.StatusConflict, s.do(t, http.MethodPost, "/Groups", `{"displayName": "devs"}`, nil))
+
+	groupPath := "/Groups/" + created.ID
+	var patched Group
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodPatch, groupPath, `{
+		"Operations": [
+			{"op": "add", "path": "members", "value": [{"value": "u-john"}]},
+			{"op": "remove", "path": "members[value eq \"u-jane\"]"}
+		]
+	}`, &patched))
+	assert.Equal(t, []Member{{Value: "u-john"}}, patched.Members)
+	assert.Empty(t, s.groupPrincipals("u-jane"))
+	assert.Equal(t, []string{"azuread_group://devs"}, s.groupPrincipals("u-john"))
+
+	// renaming a group without external ID changes its principal
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodPatch, groupPath, `{
+		"Operations": [{"op": "replace", "value": {"displayName": "developers"}}]
+	}`, &patched))
+	assert.Equal(t, "developers", patched.DisplayName)
+	assert.Equal(t, []string{"azuread_group://developers"}, s.groupPrincipals("u-john"))
+
+	var user User
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodGet, "/Users/u-john", "", &user))
+	assert.Equal(t, []Member{{Value: created.ID, Display: "developers"}}, user.Groups)
+
+	var list listResponse
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodGet, `/Groups?filter=displayName+eq+%22developers%22`, "", &list))
+	assert.Equal(t, 1, list.TotalResults)
+
+	// the groups the provider refreshes are kept apart from the SCIM groups
+	assert.Empty(t, s.attributes["u-john"].GroupPrincipals[testProvider].Items)
+	s.attributes["u-john"].GroupPrincipals[testProvider] = v32.Principals{Items: []v32.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "azuread_group://refreshed"}}}}
+	require.Equal(t, http.StatusOK, s.do(t, http.MethodGet, "/Users/u-john", "", &user))
+	assert.Equal(t, []Member{{Value: created.ID, Display: "developers"}}, user.Groups)
+
+	assert.Equal(t, http.StatusNoContent, s.do(t, http.MethodDelete, groupPath, "", nil))
+	assert.Empty(t, s.groupPrincipals("u-john"))
+	assert.Len(t, s.attributes["u-john"].GroupPrincipals[testProvider].Items, 1)
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodGet, groupPath, "", nil))
+}
+
+func TestWriteListPages(t *testing.T) {
+	resources := []interface{}{"a", "b", "c"}
+	tests := []struct {
+		query string
+		want  []interface{}
+	}{
+		{query: "", want: []interface{}{"a", "b", "c"}},
+		{query: "?startIndex=2&count=1", want: []interface{}{"b"}},
+		{query: "?startIndex=3&count=5", want: []interface{}{"c"}},
+		{query: "?startIndex=4", want: []interface{}{}},
+		{query: "?count=0", want: []interface{}{}},
+	}
+	for _, tt := range tests {
+		rw := httptest.NewRecorder()
+		writeList(rw, httptest.NewRequest(http.MethodGet, "/Users"+tt.query, nil), resources)
+		var list listResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &list))
+		assert.Equal(t, 3, list.TotalResults, tt.query)
+		assert.Equal(t, tt.want, list.Resources, tt.query)
+	}
+}
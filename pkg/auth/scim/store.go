@@ -0,0 +1,119 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	corev1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"github.com/rancher/rancher/pkg/namespace"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	groupsConfigMapPrefix = "scim-groups-"
+	timeFormat            = time.RFC3339
+)
+
+// groupStore persists the groups of the providers.
+type groupStore interface {
+	// list returns the groups of the provider sorted by ID.
+	list(provider string) ([]group, error)
+	// create saves a new group, it fails with a uniqueness error if the provider already has a group with its ID.
+	create(provider string, g group) error
+	save(provider string, g group) error
+	remove(provider, id string) error
+}
+
+// configMapGroupStore stores the groups of a provider in the scim-groups-<provider> ConfigMap of the
+// cattle-global-data namespace, keyed by ID.
+type configMapGroupStore struct {
+	configMaps      corev1.ConfigMapInterface
+	configMapLister corev1.ConfigMapLister
+}
+
+func (s *configMapGroupStore) list(provider string) ([]group, error) {
+	cm, err := s.configMapLister.Get(namespace.GlobalNamespace, groupsConfigMapPrefix+provider)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	groups := make([]group, 0, len(cm.Data))
+	for id, data := range cm.Data {
+		var g group
+		if err := json.Unmarshal([]byte(data), &g); err != nil {
+			return nil, fmt.Errorf("invalid group %s of configmap %s: %w", id, cm.Name, err)
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups, nil
+}
+
+func (s *configMapGroupStore) create(provider string, g group) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return s.update(provider, func(cm *v1.ConfigMap) error {
+		if _, ok := cm.Data[g.ID]; ok {
+			return newError(http.StatusConflict, "uniqueness", fmt.Sprintf("group %s already exists", g.DisplayName))
+		}
+		cm.Data[g.ID] = string(data)
+		return nil
+	})
+}
+
+func (s *configMapGroupStore) save(provider string, g group) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return s.update(provider, func(cm *v1.ConfigMap) error {
+		cm.Data[g.ID] = string(data)
+		return nil
+	})
+}
+
+func (s *configMapGroupStore) remove(provider, id string) error {
+	return s.update(provider, func(cm *v1.ConfigMap) error {
+		delete(cm.Data, id)
+		return nil
+	})
+}
+
+// update applies the mutation to the latest version of the ConfigMap of the provider, so that concurrent changes of
+// other replicas are not lost.
+func (s *configMapGroupStore) update(provider string, mutate func(cm *v1.ConfigMap) error) error {
+	name := groupsConfigMapPrefix + provider
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := s.configMaps.Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.GlobalNamespace},
+				Data:       map[string]string{},
+			}
+			if err := mutate(cm); err != nil {
+				return err
+			}
+			_, err = s.configMaps.Create(cm)
+			return err
+		} else if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		if err := mutate(cm); err != nil {
+			return err
+		}
+		_, err = s.configMaps.Update(cm)
+		return err
+	})
+}
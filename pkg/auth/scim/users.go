@@ -0,0 +1,442 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	// providerLabel marks the users provisioned by the SCIM server of a provider.
+	providerLabel        = "auth.cattle.io/scim-provider"
+	userNameAnnotation   = "auth.cattle.io/scim-user-name"
+	externalIDAnnotation = "auth.cattle.io/scim-external-id"
+	// createdAnnotation marks the users created by the SCIM server, as opposed to the users it adopted.
+	createdAnnotation = "auth.cattle.io/scim-created"
+)
+
+// User is a SCIM user.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Name        *Name    `json:"name,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Groups      []Member `json:"groups,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Name is the name of a SCIM user, only used to default its display name.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Member references a user member of a group, or a group of a user.
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+// principalName is the name of the user in its principal ID, its external ID if the identity provider set one.
+func (u *User) principalName() string {
+	if u.ExternalID != "" {
+		return u.ExternalID
+	}
+	return u.UserName
+}
+
+func (u *User) displayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name == nil {
+		return ""
+	}
+	if u.Name.Formatted != "" {
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// setAttribute sets the attribute of the given path. Attributes that are not stored, e.g. emails, are ignored.
+func (u *User) setAttribute(path string, value json.RawMessage) error {
+	var err error
+	switch strings.ToLower(path) {
+	case "active":
+		var active bool
+		active, err = boolValue(value)
+		u.Active = &active
+	case "username":
+		u.UserName, err = stringValue(value)
+	case "externalid":
+		u.ExternalID, err = stringValue(value)
+	case "displayname":
+		u.DisplayName, err = stringValue(value)
+	}
+	return err
+}
+
+func (u *User) applyPatch(operations []patchOperation) error {
+	for _, op := range operations {
+		if strings.EqualFold(op.Op, "remove") {
+			switch strings.ToLower(op.Path) {
+			case "externalid":
+				u.ExternalID = ""
+			case "displayname":
+				u.DisplayName = ""
+			}
+			continue
+		}
+		if op.Path != "" {
+			if err := u.setAttribute(op.Path, op.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		attributes, err := op.attributes()
+		if err != nil {
+			return err
+		}
+		for path, value := range attributes {
+			if err := u.setAttribute(path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) listUsers(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	f, err := parseFilter(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	users, err := h.userLister.List("", labels.SelectorFromSet(labels.Set{providerLabel: provider}))
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	groupIDs, err := h.groupIDs(provider)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	resources := []interface{}{}
+	for _, user := range users {
+		scimUser, err := h.toSCIMUser(provider, user, groupIDs)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		if f.matches(map[string]string{
+			"id":          scimUser.ID,
+			"userName":    scimUser.UserName,
+			"externalId":  scimUser.ExternalID,
+			"displayName": scimUser.DisplayName,
+		}) {
+			resources = append(resources, scimUser)
+		}
+	}
+	writeList(rw, req, resources)
+}
+
+func (h *Handler) getUser(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	user, err := h.getProvisionedUser(provider, mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.writeUser(rw, req, http.StatusOK, provider, user)
+}
+
+func (h *Handler) createUser(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	var in User
+	if err := readJSON(req, &in); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if in.UserName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "userName is required"))
+		return
+	}
+
+	principalID := userPrincipalID(provider, in.principalName())
+	user, err := h.userManager.GetUserByPrincipalID(principalID)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if user != nil && user.Labels[providerLabel] == provider {
+		writeError(rw, newError(http.StatusConflict, "uniqueness", fmt.Sprintf("user %s already exists", in.UserName)))
+		return
+	}
+	// users that logged in before being provisioned are adopted
+	created := user == nil
+	if created {
+		user, err = h.userManager.EnsureUser(principalID, in.displayName())
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+
+	user, err = h.updateUser(user.Name, func(user *v3.User) {
+		applyUser(provider, &in, user)
+		if created {
+			user.Annotations[createdAnnotation] = "true"
+		}
+	})
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.revokeDisabled(user); err != nil {
+		writeError(rw, err)
+		return
+	}
+	logrus.Infof("[scim] Provisioned user %s for principal %s", user.Name, principalID)
+	h.writeUser(rw, req, http.StatusCreated, provider, user)
+}
+
+func (h *Handler) replaceUser(rw http.ResponseWriter, req *http.Request) {
+	var in User
+	if err := readJSON(req, &in); err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.modifyUser(rw, req, func(user *User) error {
+		if in.Active == nil {
+			in.Active = user.Active
+		}
+		*user = in
+		return nil
+	})
+}
+
+func (h *Handler) patchUser(rw http.ResponseWriter, req *http.Request) {
+	operations, err := readPatch(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.modifyUser(rw, req, func(user *User) error {
+		return user.applyPatch(operations)
+	})
+}
+
+// modifyUser updates the user of the request with the given modification of its SCIM representation.
+func (h *Handler) modifyUser(rw http.ResponseWriter, req *http.Request, modify func(user *User) error) {
+	provider := mux.Vars(req)["provider"]
+
+	user, err := h.getProvisionedUser(provider, mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	scimUser := fromUser(user)
+	if err := modify(scimUser); err != nil {
+		writeError(rw, err)
+		return
+	}
+	if scimUser.UserName == "" {
+		writeError(rw, newError(http.StatusBadRequest, "invalidValue", "userName is required"))
+		return
+	}
+
+	principalID := userPrincipalID(provider, scimUser.principalName())
+	if existing, err := h.userManager.GetUserByPrincipalID(principalID); err != nil {
+		writeError(rw, err)
+		return
+	} else if existing != nil && existing.Name != user.Name {
+		writeError(rw, newError(http.StatusConflict, "uniqueness", fmt.Sprintf("principal %s belongs to another user", principalID)))
+		return
+	}
+
+	oldPrincipalID := userPrincipalID(provider, fromUser(user).principalName())
+	if principalID != oldPrincipalID && user.Annotations[createdAnnotation] != "true" {
+		// the principal of an adopted user is how it logged in before being provisioned, changing it would let the
+		// identity provider hand the account to another identity
+		writeError(rw, newError(http.StatusBadRequest, "mutability", fmt.Sprintf("the principal of user %s was not created by the SCIM server and cannot be changed", user.Name)))
+		return
+	}
+	user, err = h.updateUser(user.Name, func(user *v3.User) {
+		if principalID != oldPrincipalID {
+			for i, id := range user.PrincipalIDs {
+				if id == oldPrincipalID {
+					user.PrincipalIDs[i] = principalID
+				}
+			}
+		}
+		applyUser(provider, scimUser, user)
+	})
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.revokeDisabled(user); err != nil {
+		writeError(rw, err)
+		return
+	}
+	h.writeUser(rw, req, http.StatusOK, provider, user)
+}
+
+// deleteUser deletes the user, which removes its tokens and bindings.
+func (h *Handler) deleteUser(rw http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+
+	user, err := h.getProvisionedUser(provider, mux.Vars(req)["id"])
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := h.users.Delete(user.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		writeError(rw, err)
+		return
+	}
+	logrus.Infof("[scim] Deprovisioned user %s", user.Name)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// getProvisionedUser returns the user with the given name if it was provisioned by the SCIM server of the provider.
+func (h *Handler) getProvisionedUser(provider, name string) (*v3.User, error) {
+	user, err := h.userLister.Get("", name)
+	if apierrors.IsNotFound(err) || (err == nil && user.Labels[providerLabel] != provider) {
+		return nil, newError(http.StatusNotFound, "", fmt.Sprintf("user %s not found", name))
+	}
+	return user, err
+}
+
+func (h *Handler) updateUser(name string, mutate func(user *v3.User)) (*v3.User, error) {
+	var result *v3.User
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		user, err := h.users.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(user)
+		result, err = h.users.Update(user)
+		return err
+	})
+	return result, err
+}
+
+// revokeDisabled deletes all tokens of the user if it is disabled, so that it is logged out of Rancher immediately.
+func (h *Handler) revokeDisabled(user *v3.User) error {
+	if user.Enabled == nil || *user.Enabled {
+		return nil
+	}
+	tokens, err := h.tokenLister.List("", labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.UserID != user.Name {
+			continue
+		}
+		if err := h.tokens.Delete(token.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logrus.Infof("[scim] Deleted token %s of deactivated user %s", token.Name, user.Name)
+	}
+	return nil
+}
+
+func (h *Handler) writeUser(rw http.ResponseWriter, req *http.Request, status int, provider string, user *v3.User) {
+	groupIDs, err := h.groupIDs(provider)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	scimUser, err := h.toSCIMUser(provider, user, groupIDs)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if status == http.StatusCreated {
+		rw.Header().Set("Location", scimUser.Meta.Location)
+	}
+	writeJSON(rw, status, scimUser)
+}
+
+// toSCIMUser returns the SCIM representation of the user, with its groups of the given IDs by principal ID.
+func (h *Handler) toSCIMUser(provider string, user *v3.User, groupIDs map[string]string) (*User, error) {
+	scimUser := fromUser(user)
+	scimUser.ID = user.Name
+	scimUser.Meta = &Meta{
+		ResourceType: "User",
+		Created:      user.CreationTimestamp.UTC().Format(timeFormat),
+		Location:     location(provider, "Users", user.Name),
+	}
+
+	attribs, err := h.userAttributeLister.Get("", user.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if attribs != nil {
+		for _, principal := range attribs.GroupPrincipals[groupPrincipalsKey(provider)].Items {
+			if id, ok := groupIDs[principal.Name]; ok {
+				scimUser.Groups = append(scimUser.Groups, Member{Value: id, Display: principal.DisplayName})
+			}
+		}
+	}
+	return scimUser, nil
+}
+
+// fromUser returns the attributes of the user that the SCIM server stores.
+func fromUser(user *v3.User) *User {
+	return &User{
+		Schemas:     []string{userSchema},
+		ExternalID:  user.Annotations[externalIDAnnotation],
+		UserName:    user.Annotations[userNameAnnotation],
+		DisplayName: user.DisplayName,
+		Active:      pointer.Bool(user.Enabled == nil || *user.Enabled),
+	}
+}
+
+// applyUser stores the attributes of the SCIM user in the user.
+func applyUser(provider string, in *User, user *v3.User) {
+	if user.Labels == nil {
+		user.Labels = map[string]string{}
+	}
+	user.Labels[providerLabel] = provider
+	if user.Annotations == nil {
+		user.Annotations = map[string]string{}
+	}
+	user.Annotations[userNameAnnotation] = in.UserName
+	if in.ExternalID != "" {
+		user.Annotations[externalIDAnnotation] = in.ExternalID
+	} else {
+		delete(user.Annotations, externalIDAnnotation)
+	}
+	if displayName := in.displayName(); displayName != "" {
+		user.DisplayName = displayName
+	}
+	user.Enabled = pointer.Bool(in.Active == nil || *in.Active)
+}
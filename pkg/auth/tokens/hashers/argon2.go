@@ -0,0 +1,192 @@
+package hashers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	argon2idHashFormat = "$%d:%d:%d:%d:%s:%s" // $version:memory:time:threads:salt:hash -> $4:19456:2:1:abc:def
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+
+	// DefaultArgon2idMemory is the default amount of memory in KiB used by the argon2id hasher.
+	DefaultArgon2idMemory = 19 * 1024
+	// DefaultArgon2idTime is the default number of passes over the memory made by the argon2id hasher.
+	DefaultArgon2idTime = 2
+	// DefaultArgon2idThreads is the default degree of parallelism of the argon2id hasher.
+	DefaultArgon2idThreads = 1
+
+	// argon2idVerifiedCacheSize is the number of successful verifications that are remembered.
+	argon2idVerifiedCacheSize = 4096
+	// argon2idVerifiedTTL is how long a successful verification is remembered. Tokens are verified on every request, so
+	// this avoids computing an argon2id key for every request of a client.
+	argon2idVerifiedTTL = 5 * time.Minute
+)
+
+var (
+	// argon2idVerified remembers the hash and key pairs that were successfully verified, by a SHA256 of both. The hash
+	// includes the random salt, so entries of a re-hashed or regenerated token are never used.
+	argon2idVerified = cache.NewLRUExpireCache(argon2idVerifiedCacheSize)
+	// argon2idSlots limits the number of concurrent argon2id computations, which use Memory KiB each, so that requests
+	// with invalid keys can not exhaust the memory of the server.
+	argon2idSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+)
+
+// Argon2idHasher implements the Hasher interface using a backing algorithm of Argon2id. The cost parameters are
+// stored in the hash, so changing them only affects new hashes.
+type Argon2idHasher struct {
+	// Memory is the amount of memory used in KiB.
+	Memory uint32
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Threads is the number of threads used.
+	Threads uint8
+}
+
+// NewArgon2idHasher returns an Argon2idHasher using the default cost parameters.
+func NewArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		Memory:  DefaultArgon2idMemory,
+		Time:    DefaultArgon2idTime,
+		Threads: DefaultArgon2idThreads,
+	}
+}
+
+// ParseArgon2idParams parses cost parameters in the form "m=<memory KiB>,t=<time>,p=<threads>". Parameters that are
+// omitted keep their default value.
+func ParseArgon2idParams(params string) (Argon2idHasher, error) {
+	hasher := NewArgon2idHasher()
+	for _, param := range strings.Split(params, ",") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return Argon2idHasher{}, fmt.Errorf("invalid argon2id parameter %q", param)
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil || n == 0 {
+			return Argon2idHasher{}, fmt.Errorf("invalid value for argon2id parameter %q", param)
+		}
+		switch strings.TrimSpace(key) {
+		case "m":
+			hasher.Memory = uint32(n)
+		case "t":
+			hasher.Time = uint32(n)
+		case "p":
+			if n > 255 {
+				return Argon2idHasher{}, fmt.Errorf("argon2id parallelism %d is greater than 255", n)
+			}
+			hasher.Threads = uint8(n)
+		default:
+			return Argon2idHasher{}, fmt.Errorf("unknown argon2id parameter %q", key)
+		}
+	}
+	if hasher.Memory < 8*uint32(hasher.Threads) {
+		return Argon2idHasher{}, fmt.Errorf("argon2id memory must be at least 8KiB per thread")
+	}
+	return hasher, nil
+}
+
+// CreateHash hashes secretKey using a random salt and Argon2id.
+func (a Argon2idHasher) CreateHash(secretKey string) (string, error) {
+	if a.Memory == 0 || a.Time == 0 || a.Threads == 0 {
+		return "", fmt.Errorf("argon2id cost parameters must be greater than zero")
+	}
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to read random values for salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(secretKey), salt, a.Time, a.Memory, a.Threads, argon2idKeyLength)
+	encSalt := base64.RawStdEncoding.EncodeToString(salt)
+	encKey := base64.RawStdEncoding.EncodeToString(key)
+	return fmt.Sprintf(argon2idHashFormat, Argon2idVersion, a.Memory, a.Time, a.Threads, encSalt, encKey), nil
+}
+
+// VerifyHash compares a key with the hash, and will produce an error if the hash does not match or if the hash is not
+// a valid Argon2id hash. The cost parameters stored in the hash are used rather than those of the hasher.
+func (a Argon2idHasher) VerifyHash(hash, secretKey string) error {
+	params, salt, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+	cacheKey := argon2idCacheKey(hash, secretKey)
+	if _, ok := argon2idVerified.Get(cacheKey); ok {
+		return nil
+	}
+
+	argon2idSlots <- struct{}{}
+	verify := argon2.IDKey([]byte(secretKey), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	<-argon2idSlots
+	if subtle.ConstantTimeCompare(key, verify) == 0 {
+		return fmt.Errorf("secretKey hash does not match")
+	}
+	argon2idVerified.Add(cacheKey, true, argon2idVerifiedTTL)
+	return nil
+}
+
+func argon2idCacheKey(hash, secretKey string) string {
+	sum := sha256.Sum256([]byte(hash + "\x00" + secretKey))
+	return string(sum[:])
+}
+
+// parseArgon2idHash splits an argon2id hash into its cost parameters, salt and key.
+func parseArgon2idHash(hash string) (Argon2idHasher, []byte, []byte, error) {
+	if !strings.HasPrefix(hash, "$") {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("hash format invalid")
+	}
+	splitHash := strings.Split(strings.TrimPrefix(hash, "$"), ":")
+	if len(splitHash) != 6 {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("hash format invalid")
+	}
+
+	version, err := strconv.Atoi(splitHash[0])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("unable to convert hash version")
+	}
+	if HashVersion(version) != Argon2idVersion {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("hash version %d does not match package version %d", version, Argon2idVersion)
+	}
+
+	memory, err := strconv.ParseUint(splitHash[1], 10, 32)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("unable to convert argon2id memory")
+	}
+	time, err := strconv.ParseUint(splitHash[2], 10, 32)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("unable to convert argon2id time")
+	}
+	threads, err := strconv.ParseUint(splitHash[3], 10, 8)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("unable to convert argon2id threads")
+	}
+	if memory == 0 || time == 0 || threads == 0 {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("argon2id cost parameters must be greater than zero")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(splitHash[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(splitHash[5])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, err
+	}
+	if len(key) < 1 {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("secretKey hash does not match") // Don't allow accidental empty string to succeed
+	}
+
+	return Argon2idHasher{Memory: uint32(memory), Time: uint32(time), Threads: uint8(threads)}, salt, key, nil
+}
@@ -0,0 +1,159 @@
+package hashers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicArgon2idHash(t *testing.T) {
+	secretKey := "hello world"
+	hasher := Argon2idHasher{Memory: 64, Time: 1, Threads: 1}
+	hash, err := hasher.CreateHash(secretKey)
+	require.NoError(t, err)
+	splitHash := strings.Split(hash, ":")
+	require.Len(t, splitHash, 6)
+	require.Equal(t, strconv.Itoa(int(Argon2idVersion)), splitHash[0][1:])
+	require.Equal(t, "64", splitHash[1])
+	require.Equal(t, "1", splitHash[2])
+	require.Equal(t, "1", splitHash[3])
+	// Now check it, the parameters stored in the hash are used rather than those of the verifying hasher
+	require.NoError(t, NewArgon2idHasher().VerifyHash(hash, secretKey))
+	require.Error(t, hasher.VerifyHash(hash, "incorrect"))
+}
+
+func TestArgon2idVerifyHash(t *testing.T) {
+	hasher := Argon2idHasher{Memory: 64, Time: 1, Threads: 1}
+	validHash, err := hasher.CreateHash("secret")
+	require.NoError(t, err)
+	splitHash := strings.Split(validHash, ":")
+
+	tests := []struct {
+		name      string
+		hash      string
+		wantError bool
+	}{
+		{
+			name: "valid hash",
+			hash: validHash,
+		},
+		{
+			name:      "invalid hash format",
+			hash:      strings.Join(splitHash[:5], ":"),
+			wantError: true,
+		},
+		{
+			name:      "invalid hash version",
+			hash:      "$3:" + strings.Join(splitHash[1:], ":"),
+			wantError: true,
+		},
+		{
+			name:      "missing $ prefix",
+			hash:      strings.TrimPrefix(validHash, "$"),
+			wantError: true,
+		},
+		{
+			name:      "zero cost",
+			hash:      strings.Join([]string{splitHash[0], "0", splitHash[2], splitHash[3], splitHash[4], splitHash[5]}, ":"),
+			wantError: true,
+		},
+		{
+			name:      "non base64 character in salt",
+			hash:      strings.Join([]string{splitHash[0], splitHash[1], splitHash[2], splitHash[3], "#", splitHash[5]}, ":"),
+			wantError: true,
+		},
+		{
+			name:      "empty key",
+			hash:      strings.Join([]string{splitHash[0], splitHash[1], splitHash[2], splitHash[3], splitHash[4], ""}, ":"),
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := hasher.VerifyHash(test.hash, "secret")
+			if test.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseArgon2idParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     string
+		wantHasher Argon2idHasher
+		wantError  bool
+	}{
+		{
+			name:       "defaults",
+			params:     "",
+			wantHasher: NewArgon2idHasher(),
+		},
+		{
+			name:       "all parameters",
+			params:     "m=65536, t=3, p=4",
+			wantHasher: Argon2idHasher{Memory: 65536, Time: 3, Threads: 4},
+		},
+		{
+			name:       "partial parameters",
+			params:     "t=4",
+			wantHasher: Argon2idHasher{Memory: DefaultArgon2idMemory, Time: 4, Threads: DefaultArgon2idThreads},
+		},
+		{
+			name:      "unknown parameter",
+			params:    "x=1",
+			wantError: true,
+		},
+		{
+			name:      "zero value",
+			params:    "t=0",
+			wantError: true,
+		},
+		{
+			name:      "too many threads",
+			params:    "p=256",
+			wantError: true,
+		},
+		{
+			name:      "too little memory",
+			params:    "m=8,p=2",
+			wantError: true,
+		},
+		{
+			name:      "missing value",
+			params:    "m",
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasher, err := ParseArgon2idParams(test.params)
+			if test.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.wantHasher, hasher)
+		})
+	}
+}
+
+func TestArgon2idVerifyHashCache(t *testing.T) {
+	hasher := Argon2idHasher{Memory: 64, Time: 1, Threads: 1}
+	hash, err := hasher.CreateHash("testsecret")
+	require.NoError(t, err)
+
+	require.Error(t, hasher.VerifyHash(hash, "wrongsecret"))
+	_, ok := argon2idVerified.Get(argon2idCacheKey(hash, "wrongsecret"))
+	require.False(t, ok, "failed verifications must not be cached")
+
+	require.NoError(t, hasher.VerifyHash(hash, "testsecret"))
+	_, ok = argon2idVerified.Get(argon2idCacheKey(hash, "testsecret"))
+	require.True(t, ok, "successful verifications must be cached")
+	require.NoError(t, hasher.VerifyHash(hash, "testsecret"))
+}
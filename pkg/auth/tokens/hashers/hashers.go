@@ -5,6 +5,9 @@
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
 )
 
 type HashVersion int
@@ -13,6 +16,14 @@
 	ScryptVersion HashVersion = iota + 1
 	SHA256Version
 	SHA3Version
+	Argon2idVersion
+)
+
+const (
+	// SHA3Algorithm selects the SHA3 hasher in the token-hash-algorithm setting.
+	SHA3Algorithm = "sha3"
+	// Argon2idAlgorithm selects the Argon2id hasher in the token-hash-algorithm setting.
+	Argon2idAlgorithm = "argon2id"
 )
 
 // Hasher describes an interface which allows a user to create a hash for a value or verify that a hash is correct.
@@ -36,14 +47,53 @@ func GetHasherForHash(hash string) (Hasher, error) {
 		return Sha256Hasher{}, nil
 	case SHA3Version:
 		return Sha3Hasher{}, nil
+	case Argon2idVersion:
+		return NewArgon2idHasher(), nil
 	default:
 		return nil, fmt.Errorf("invalid version %d, no hasher exists for that version", version)
 	}
 }
 
 // GetHasher produces the hasher which should be used for new tokens, for verifying existing tokens use GetHasherForHash.
+// The hasher is selected by the token-hash-algorithm setting and defaults to SHA3.
 func GetHasher() Hasher {
-	return Sha3Hasher{}
+	if settings.TokenHashAlgorithm.Get() != Argon2idAlgorithm {
+		return Sha3Hasher{}
+	}
+	hasher, err := ParseArgon2idParams(settings.TokenHashArgon2idParams.Get())
+	if err != nil {
+		logrus.Errorf("invalid token-hash-argon2id-params setting, using default argon2id parameters: %v", err)
+		return NewArgon2idHasher()
+	}
+	return hasher
+}
+
+// NeedsRehash reports whether hash was produced by a different hasher than the one returned by GetHasher, or by
+// the same hasher with weaker cost parameters than those currently configured.
+func NeedsRehash(hash string) bool {
+	return NeedsRehashWith(hash, GetHasher())
+}
+
+// NeedsRehashWith reports whether hash was produced by a different hasher than preferred, or by the same hasher with
+// weaker cost parameters.
+func NeedsRehashWith(hash string, preferred Hasher) bool {
+	version, err := GetHashVersion(hash)
+	if err != nil {
+		return false
+	}
+	switch preferred := preferred.(type) {
+	case Argon2idHasher:
+		if version != Argon2idVersion {
+			return true
+		}
+		params, _, _, err := parseArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		return params.Memory < preferred.Memory || params.Time < preferred.Time || params.Threads < preferred.Threads
+	default:
+		return version != SHA3Version
+	}
 }
 
 // GetHashVersion produces the hash version for a given hash.
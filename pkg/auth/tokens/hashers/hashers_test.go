@@ -3,6 +3,7 @@
 import (
 	"testing"
 
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +15,8 @@ func TestGetHasherForHash(t *testing.T) {
 	assert.NoError(t, err, "error when creating sha256 hash")
 	sha3Hash, err := Sha3Hasher{}.CreateHash(testSecret)
 	assert.NoError(t, err, "error when creating sha3 hash")
+	argon2idHash, err := NewArgon2idHasher().CreateHash(testSecret)
+	assert.NoError(t, err, "error when creating argon2id hash")
 
 	tests := []struct {
 		name       string
@@ -39,6 +42,12 @@ func TestGetHasherForHash(t *testing.T) {
 			wantHasher: Sha3Hasher{},
 			wantErr:    false,
 		},
+		{
+			name:       "argon2id hash",
+			hash:       argon2idHash,
+			wantHasher: Argon2idHasher{},
+			wantErr:    false,
+		},
 		{
 			name:       "invalid hash",
 			hash:       "thisisnotahash",
@@ -47,7 +56,7 @@ func TestGetHasherForHash(t *testing.T) {
 		},
 		{
 			name:       "invalid hash version",
-			hash:       "$4:some-salt-here:some-secret-here",
+			hash:       "$5:some-salt-here:some-secret-here",
 			wantHasher: nil,
 			wantErr:    true,
 		},
@@ -68,8 +77,92 @@ func TestGetHasherForHash(t *testing.T) {
 }
 
 func TestGetHasher(t *testing.T) {
-	t.Parallel()
 	assert.IsTypef(t, Sha3Hasher{}, GetHasher(), "expected SHA3 to be the default hasher")
+
+	setHashSettings(t, Argon2idAlgorithm, "m=1024,t=1,p=1")
+	assert.Equal(t, Argon2idHasher{Memory: 1024, Time: 1, Threads: 1}, GetHasher())
+
+	setHashSettings(t, Argon2idAlgorithm, "bogus")
+	assert.Equal(t, NewArgon2idHasher(), GetHasher(), "expected default parameters for invalid argon2id params")
+}
+
+func TestNeedsRehash(t *testing.T) {
+	const testSecret = "testsecret"
+	sha256Hash, err := Sha256Hasher{}.CreateHash(testSecret)
+	assert.NoError(t, err)
+	sha3Hash, err := Sha3Hasher{}.CreateHash(testSecret)
+	assert.NoError(t, err)
+	weakArgon2idHash, err := Argon2idHasher{Memory: 64, Time: 1, Threads: 1}.CreateHash(testSecret)
+	assert.NoError(t, err)
+	argon2idHash, err := Argon2idHasher{Memory: 1024, Time: 1, Threads: 1}.CreateHash(testSecret)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		hash      string
+		want      bool
+	}{
+		{
+			name:      "sha256 with sha3 preferred",
+			algorithm: SHA3Algorithm,
+			hash:      sha256Hash,
+			want:      true,
+		},
+		{
+			name:      "sha3 with sha3 preferred",
+			algorithm: SHA3Algorithm,
+			hash:      sha3Hash,
+			want:      false,
+		},
+		{
+			name:      "argon2id with sha3 preferred",
+			algorithm: SHA3Algorithm,
+			hash:      argon2idHash,
+			want:      true,
+		},
+		{
+			name:      "sha3 with argon2id preferred",
+			algorithm: Argon2idAlgorithm,
+			hash:      sha3Hash,
+			want:      true,
+		},
+		{
+			name:      "weaker argon2id parameters",
+			algorithm: Argon2idAlgorithm,
+			hash:      weakArgon2idHash,
+			want:      true,
+		},
+		{
+			name:      "current argon2id parameters",
+			algorithm: Argon2idAlgorithm,
+			hash:      argon2idHash,
+			want:      false,
+		},
+		{
+			name:      "invalid hash",
+			algorithm: Argon2idAlgorithm,
+			hash:      "thisisnotahash",
+			want:      false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setHashSettings(t, test.algorithm, "m=1024,t=1,p=1")
+			assert.Equal(t, test.want, NeedsRehash(test.hash))
+		})
+	}
+}
+
+func setHashSettings(t *testing.T, algorithm, params string) {
+	t.Helper()
+	oldAlgorithm, oldParams := settings.TokenHashAlgorithm.Get(), settings.TokenHashArgon2idParams.Get()
+	assert.NoError(t, settings.TokenHashAlgorithm.Set(algorithm))
+	assert.NoError(t, settings.TokenHashArgon2idParams.Set(params))
+	t.Cleanup(func() {
+		_ = settings.TokenHashAlgorithm.Set(oldAlgorithm)
+		_ = settings.TokenHashArgon2idParams.Set(oldParams)
+	})
 }
 
 func TestGetHashVersion(t *testing.T) {
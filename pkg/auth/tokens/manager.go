@@ -106,6 +106,15 @@ func (m *Manager) createDerivedToken(jsonInput clientv3.Token, tokenAuthValue st
 		return v3.Token{}, "", 500, fmt.Errorf("error validating max-ttl %v", err)
 	}
 
+	scopes, sourceCIDRs, err := parseScopes(jsonInput)
+	if err != nil {
+		return v3.Token{}, "", http.StatusBadRequest, err
+	}
+	scopes, sourceCIDRs, err = derivedTokenScopes(token, scopes, sourceCIDRs)
+	if err != nil {
+		return v3.Token{}, "", http.StatusForbidden, err
+	}
+
 	var unhashedTokenKey string
 	derivedToken := v3.Token{
 		UserPrincipal: token.UserPrincipal,
@@ -116,6 +125,8 @@ func (m *Manager) createDerivedToken(jsonInput clientv3.Token, tokenAuthValue st
 		ProviderInfo:  token.ProviderInfo,
 		Description:   jsonInput.Description,
 		ClusterName:   jsonInput.ClusterID,
+		Scopes:        scopes,
+		SourceCIDRs:   sourceCIDRs,
 	}
 	derivedToken, unhashedTokenKey, err = m.createToken(&derivedToken)
 
@@ -185,6 +196,7 @@ func (m *Manager) getToken(tokenAuthValue string) (*v3.Token, int, error) {
 	if code, err := VerifyToken(storedToken, tokenName, tokenKey); err != nil {
 		return nil, code, err
 	}
+	RehashTokenIfNeeded(m.tokensClient, storedToken, tokenKey)
 
 	return storedToken, 0, nil
 }
@@ -647,7 +659,7 @@ func (m *Manager) UserAttributeChanged(attribs *v32.UserAttribute, provider stri
 }
 
 // PerUserCacheProviders is a set of provider names for which the token manager creates a per-user login token.
-var PerUserCacheProviders = []string{"github", "azuread", "googleoauth", "oidc", "keycloakoidc"}
+var PerUserCacheProviders = []string{"github", "azuread", "googleoauth", "oidc", "keycloakoidc", "genericoauth"}
 
 func (m *Manager) NewLoginToken(userID string, userPrincipal v3.Principal, groupPrincipals []v3.Principal, providerToken string, ttl int64, description string, userExtraInfo map[string][]string) (v3.Token, string, error) {
 	provider := userPrincipal.Provider
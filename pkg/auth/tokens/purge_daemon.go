@@ -2,11 +2,13 @@
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/rancher/norman/clientbase"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,6 +20,7 @@
 
 func StartPurgeDaemon(ctx context.Context, mgmt *config.ManagementContext) {
 	p := &purger{
+		clusterLister:    mgmt.Management.Clusters("").Controller().Lister(),
 		tokenLister:      mgmt.Management.Tokens("").Controller().Lister(),
 		tokens:           mgmt.Management.Tokens(""),
 		samlTokensLister: mgmt.Management.SamlTokens("").Controller().Lister(),
@@ -27,6 +30,7 @@ func StartPurgeDaemon(ctx context.Context, mgmt *config.ManagementContext) {
 }
 
 type purger struct {
+	clusterLister    v3.ClusterLister
 	tokenLister      v3.TokenLister
 	tokens           v3.TokenInterface
 	samlTokens       v3.SamlTokenInterface
@@ -39,7 +43,17 @@ func (p *purger) purge() {
 		logrus.Errorf("Error listing tokens during purge: %v", err)
 	}
 
-	var count int
+	maxIdle := maxIdleDuration()
+	now := time.Now()
+	var aceClusters map[string]bool
+	if maxIdle > 0 {
+		if aceClusters, err = p.aceEnabledClusters(); err != nil {
+			logrus.Errorf("Error listing clusters during purge, idle tokens will not be purged: %v", err)
+			maxIdle = 0
+		}
+	}
+
+	var count, idleCount int
 	for _, token := range allTokens {
 		if IsExpired(*token) {
 			err = p.tokens.Delete(token.ObjectMeta.Name, &metav1.DeleteOptions{})
@@ -48,11 +62,21 @@ func (p *purger) purge() {
 				continue
 			}
 			count++
+		} else if isIdle(token, maxIdle, now) && !usableThroughACE(token, aceClusters) {
+			err = p.tokens.Delete(token.ObjectMeta.Name, &metav1.DeleteOptions{})
+			if err != nil && !clientbase.IsNotFound(err) {
+				logrus.Errorf("Error: while deleting idle token %v: %v", err, token.ObjectMeta.Name)
+				continue
+			}
+			idleCount++
 		}
 	}
 	if count > 0 {
 		logrus.Infof("Purged %v expired tokens", count)
 	}
+	if idleCount > 0 {
+		logrus.Infof("Purged %v tokens unused for %v", idleCount, maxIdle)
+	}
 
 	// saml tokens store encrypted token for login request from rancher cli
 	samlTokens, err := p.samlTokensLister.List(namespace.GlobalNamespace, labels.Everything())
@@ -76,3 +100,54 @@ func (p *purger) purge() {
 		logrus.Infof("Purged %v saml tokens", count)
 	}
 }
+
+// maxIdleDuration returns how long tokens may go unused before they are purged, or 0 if idle tokens are kept.
+func maxIdleDuration() time.Duration {
+	days, err := strconv.Atoi(settings.AuthTokenMaxIdleDays.Get())
+	if err != nil || days < 0 {
+		logrus.Errorf("Invalid value %q for setting %s, idle tokens will not be purged", settings.AuthTokenMaxIdleDays.Get(), settings.AuthTokenMaxIdleDays.Name)
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// aceEnabledClusters returns the names of the clusters with the authorized cluster endpoint enabled.
+func (p *purger) aceEnabledClusters() (map[string]bool, error) {
+	clusters, err := p.clusterLister.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	enabled := map[string]bool{}
+	for _, cluster := range clusters {
+		if cluster.Spec.LocalClusterAuthEndpoint.Enabled {
+			enabled[cluster.Name] = true
+		}
+	}
+	return enabled, nil
+}
+
+// usableThroughACE returns true if the token is synced to a cluster with the authorized cluster endpoint enabled.
+// Requests authenticated by the downstream cluster never reach Rancher, so their usage is not recorded and such
+// tokens are never purged for being idle. Tokens with scopes or source CIDRs are not synced downstream.
+func usableThroughACE(token *v3.Token, aceClusters map[string]bool) bool {
+	if len(token.Scopes) > 0 || len(token.SourceCIDRs) > 0 {
+		return false
+	}
+	if token.ClusterName != "" {
+		return aceClusters[token.ClusterName]
+	}
+	return len(aceClusters) > 0
+}
+
+// isIdle returns true if the token has not been used for longer than maxIdle. Tokens that were never
+// used are idle since they were created.
+func isIdle(token *v3.Token, maxIdle time.Duration, now time.Time) bool {
+	if maxIdle <= 0 {
+		return false
+	}
+	lastUsed := token.CreationTimestamp.Time
+	if token.LastUsedAt != nil && token.LastUsedAt.After(lastUsed) {
+		lastUsed = token.LastUsedAt.Time
+	}
+	return now.Sub(lastUsed) > maxIdle
+}
@@ -0,0 +1,109 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsIdle(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	maxIdle := 30 * 24 * time.Hour
+	created := metav1.NewTime(now.Add(-60 * 24 * time.Hour))
+
+	tests := []struct {
+		name    string
+		token   *v3.Token
+		maxIdle time.Duration
+		want    bool
+	}{
+		{
+			name:    "idle expiry disabled",
+			token:   &v3.Token{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}},
+			maxIdle: 0,
+		},
+		{
+			name:    "never used",
+			token:   &v3.Token{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}},
+			maxIdle: maxIdle,
+			want:    true,
+		},
+		{
+			name:    "recently created",
+			token:   &v3.Token{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))}},
+			maxIdle: maxIdle,
+		},
+		{
+			name: "recently used",
+			token: &v3.Token{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created},
+				LastUsedAt: &metav1.Time{Time: now.Add(-24 * time.Hour)},
+			},
+			maxIdle: maxIdle,
+		},
+		{
+			name: "used long ago",
+			token: &v3.Token{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created},
+				LastUsedAt: &metav1.Time{Time: now.Add(-31 * 24 * time.Hour)},
+			},
+			maxIdle: maxIdle,
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isIdle(tt.token, tt.maxIdle, now))
+		})
+	}
+}
+
+func TestUsableThroughACE(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       *v3.Token
+		aceClusters map[string]bool
+		want        bool
+	}{
+		{
+			name:  "no cluster has ACE enabled",
+			token: &v3.Token{},
+		},
+		{
+			name:        "unscoped token is synced to every ACE cluster",
+			token:       &v3.Token{},
+			aceClusters: map[string]bool{"c-1": true},
+			want:        true,
+		},
+		{
+			name:        "cluster token for an ACE cluster",
+			token:       &v3.Token{ClusterName: "c-1"},
+			aceClusters: map[string]bool{"c-1": true},
+			want:        true,
+		},
+		{
+			name:        "cluster token for a cluster without ACE",
+			token:       &v3.Token{ClusterName: "c-2"},
+			aceClusters: map[string]bool{"c-1": true},
+		},
+		{
+			name:        "token with scopes is not synced",
+			token:       &v3.Token{Scopes: []v32.TokenScope{{Resources: []string{"pods"}}}},
+			aceClusters: map[string]bool{"c-1": true},
+		},
+		{
+			name:        "token with source CIDRs is not synced",
+			token:       &v3.Token{SourceCIDRs: []string{"10.0.0.0/8"}},
+			aceClusters: map[string]bool{"c-1": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, usableThroughACE(tt.token, tt.aceClusters))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package tokens
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/requests/attributes"
+	clientv3 "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"k8s.io/utils/strings/slices"
+)
+
+const scopeWildcard = "*"
+
+// IsScoped returns true if the token is restricted to a set of requests or source addresses.
+func IsScoped(token *v3.Token) bool {
+	return len(token.Scopes) > 0 || len(token.SourceCIDRs) > 0
+}
+
+// ScopesAllow returns true if the request described by attrs matches any of the scopes, or if there are no scopes.
+// Requests that do not act on a resource only match scopes that do not restrict API groups or resources.
+func ScopesAllow(scopes []v32.TokenScope, attrs attributes.Attributes) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if scopeAllows(scope, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeAllows(scope v32.TokenScope, attrs attributes.Attributes) bool {
+	if !containsOrWildcard(scope.Verbs, attrs.Verb) {
+		return false
+	}
+	if attrs.Resource == "" {
+		return isUnrestricted(scope.APIGroups) && isUnrestricted(scope.Resources)
+	}
+	if !isUnrestricted(scope.APIGroups) && !containsOrWildcard(scope.APIGroups, attrs.Group) {
+		return false
+	}
+	if isUnrestricted(scope.Resources) {
+		return true
+	}
+	for _, resource := range scope.Resources {
+		if resourceMatches(resource, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceMatches compares a scope resource with the resource and subresource of a request. Subresources such as
+// pods/exec must be named by the scope, either explicitly or as resource/*, as a scope on a resource does not extend to
+// its subresources.
+func resourceMatches(resource string, attrs attributes.Attributes) bool {
+	resource, subresource, _ := strings.Cut(resource, "/")
+	if !attributes.ResourceMatches(resource, attrs.Resource) {
+		return false
+	}
+	return subresource == attrs.Subresource || (subresource == scopeWildcard && attrs.Subresource != "")
+}
+
+func isUnrestricted(values []string) bool {
+	return len(values) == 0 || slices.Contains(values, scopeWildcard)
+}
+
+func containsOrWildcard(values []string, value string) bool {
+	return slices.Contains(values, scopeWildcard) || slices.Contains(values, value)
+}
+
+// parseScopes converts and validates the scopes and source CIDRs requested for a new token.
+func parseScopes(input clientv3.Token) ([]v32.TokenScope, []string, error) {
+	var scopes []v32.TokenScope
+	for i, s := range input.Scopes {
+		if len(s.Verbs) == 0 {
+			return nil, nil, fmt.Errorf("scope %d must have at least one verb", i)
+		}
+		scope := v32.TokenScope{
+			Verbs:     s.Verbs,
+			APIGroups: s.APIGroups,
+			Resources: make([]string, 0, len(s.Resources)),
+		}
+		for _, verb := range s.Verbs {
+			if verb == "" {
+				return nil, nil, fmt.Errorf("scope %d has an empty verb", i)
+			}
+		}
+		for _, resource := range s.Resources {
+			if resource == "" {
+				return nil, nil, fmt.Errorf("scope %d has an empty resource", i)
+			}
+			scope.Resources = append(scope.Resources, strings.ToLower(resource))
+		}
+		if len(scope.Resources) == 0 {
+			scope.Resources = nil
+		}
+		scopes = append(scopes, scope)
+	}
+
+	var sourceCIDRs []string
+	for _, cidr := range input.SourceCIDRs {
+		normalized, err := normalizeCIDR(cidr)
+		if err != nil {
+			return nil, nil, err
+		}
+		sourceCIDRs = append(sourceCIDRs, normalized)
+	}
+	return scopes, sourceCIDRs, nil
+}
+
+// normalizeCIDR parses a CIDR, or a single address which is turned into a CIDR matching only that address.
+func normalizeCIDR(cidr string) (string, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return "", fmt.Errorf("invalid source CIDR %q", cidr)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid source CIDR %q", cidr)
+	}
+	return ipNet.String(), nil
+}
+
+// derivedTokenScopes returns the restrictions of a token derived from parent. A scoped token can only derive
+// tokens with the same restrictions, which they inherit if none are requested, so it can't be used to escalate.
+func derivedTokenScopes(parent *v3.Token, scopes []v32.TokenScope, sourceCIDRs []string) ([]v32.TokenScope, []string, error) {
+	if !IsScoped(parent) {
+		return scopes, sourceCIDRs, nil
+	}
+	if len(scopes) == 0 && len(sourceCIDRs) == 0 {
+		return parent.Scopes, parent.SourceCIDRs, nil
+	}
+	if !reflect.DeepEqual(scopes, parent.Scopes) || !reflect.DeepEqual(sourceCIDRs, parent.SourceCIDRs) {
+		return nil, nil, fmt.Errorf("a scoped token can only create tokens with the same scopes and source CIDRs")
+	}
+	return scopes, sourceCIDRs, nil
+}
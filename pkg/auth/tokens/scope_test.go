@@ -0,0 +1,162 @@
+package tokens
+
+import (
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/requests/attributes"
+	clientv3 "github.com/rancher/rancher/pkg/client/generated/management/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopesAllow(t *testing.T) {
+	readOnly := []v32.TokenScope{{Verbs: []string{"get", "list", "watch"}}}
+	deploy := []v32.TokenScope{
+		{Verbs: []string{"*"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+	}
+
+	tests := []struct {
+		name   string
+		scopes []v32.TokenScope
+		attrs  attributes.Attributes
+		want   bool
+	}{
+		{
+			name:  "unscoped",
+			attrs: attributes.Attributes{Verb: "delete", Resource: "secrets"},
+			want:  true,
+		},
+		{
+			name:   "read only allows list",
+			scopes: readOnly,
+			attrs:  attributes.Attributes{Verb: "list", Group: "management.cattle.io", Resource: "clusters"},
+			want:   true,
+		},
+		{
+			name:   "read only denies create",
+			scopes: readOnly,
+			attrs:  attributes.Attributes{Verb: "create", Group: "management.cattle.io", Resource: "clusters"},
+		},
+		{
+			name:   "read only allows non resource requests",
+			scopes: readOnly,
+			attrs:  attributes.Attributes{Verb: "get", Path: "/v3"},
+			want:   true,
+		},
+		{
+			name:   "deploy allows updating deployments",
+			scopes: deploy,
+			attrs:  attributes.Attributes{Verb: "update", Group: "apps", Resource: "deployments"},
+			want:   true,
+		},
+		{
+			name:   "steve singular resource",
+			scopes: deploy,
+			attrs:  attributes.Attributes{Verb: "patch", Group: "apps", Resource: "deployment"},
+			want:   true,
+		},
+		{
+			name:   "deploy denies other groups",
+			scopes: deploy,
+			attrs:  attributes.Attributes{Verb: "update", Group: "extensions", Resource: "deployments"},
+		},
+		{
+			name:   "deploy allows reading secrets",
+			scopes: deploy,
+			attrs:  attributes.Attributes{Verb: "get", Resource: "secrets"},
+			want:   true,
+		},
+		{
+			name:   "deploy denies listing secrets",
+			scopes: deploy,
+			attrs:  attributes.Attributes{Verb: "list", Resource: "secrets"},
+		},
+		{
+			name:   "restricted scopes deny non resource requests",
+			scopes: deploy,
+			attrs:  attributes.Attributes{Verb: "get", Path: "/v3"},
+		},
+		{
+			name:   "read only denies exec",
+			scopes: readOnly,
+			attrs:  attributes.Attributes{Verb: "create", Resource: "pods", Subresource: "exec"},
+		},
+		{
+			name:   "get pods denies exec",
+			scopes: []v32.TokenScope{{Verbs: []string{"get"}, Resources: []string{"pods"}}},
+			attrs:  attributes.Attributes{Verb: "get", Resource: "pods", Subresource: "exec"},
+		},
+		{
+			name:   "get pods allows getting pods",
+			scopes: []v32.TokenScope{{Verbs: []string{"get"}, Resources: []string{"pods"}}},
+			attrs:  attributes.Attributes{Verb: "get", Resource: "pods"},
+			want:   true,
+		},
+		{
+			name:   "named subresource",
+			scopes: []v32.TokenScope{{Verbs: []string{"get"}, Resources: []string{"pods/log"}}},
+			attrs:  attributes.Attributes{Verb: "get", Resource: "pods", Subresource: "log"},
+			want:   true,
+		},
+		{
+			name:   "named subresource denies the resource",
+			scopes: []v32.TokenScope{{Verbs: []string{"get"}, Resources: []string{"pods/log"}}},
+			attrs:  attributes.Attributes{Verb: "get", Resource: "pods"},
+		},
+		{
+			name:   "subresource wildcard",
+			scopes: []v32.TokenScope{{Verbs: []string{"create"}, Resources: []string{"pods/*"}}},
+			attrs:  attributes.Attributes{Verb: "create", Resource: "pods", Subresource: "exec"},
+			want:   true,
+		},
+		{
+			name:   "impersonation",
+			scopes: readOnly,
+			attrs:  attributes.Attributes{Verb: "impersonate", Resource: "users"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ScopesAllow(tt.scopes, tt.attrs))
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, sourceCIDRs, err := parseScopes(clientv3.Token{
+		Scopes: []clientv3.TokenScope{
+			{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"Deployments"}},
+		},
+		SourceCIDRs: []string{"10.0.0.1/8", "192.168.1.5", "2001:db8::1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []v32.TokenScope{{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}}, scopes)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.5/32", "2001:db8::1/128"}, sourceCIDRs)
+
+	_, _, err = parseScopes(clientv3.Token{Scopes: []clientv3.TokenScope{{Resources: []string{"pods"}}}})
+	assert.Error(t, err)
+
+	_, _, err = parseScopes(clientv3.Token{SourceCIDRs: []string{"10.0.0.0/33"}})
+	assert.Error(t, err)
+}
+
+func TestDerivedTokenScopes(t *testing.T) {
+	scopes := []v32.TokenScope{{Verbs: []string{"get"}}}
+	cidrs := []string{"10.0.0.0/8"}
+	scoped := &v32.Token{Scopes: scopes, SourceCIDRs: cidrs}
+
+	gotScopes, gotCIDRs, err := derivedTokenScopes(&v32.Token{}, scopes, nil)
+	require.NoError(t, err)
+	assert.Equal(t, scopes, gotScopes)
+	assert.Nil(t, gotCIDRs)
+
+	gotScopes, gotCIDRs, err = derivedTokenScopes(scoped, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, scopes, gotScopes)
+	assert.Equal(t, cidrs, gotCIDRs)
+
+	_, _, err = derivedTokenScopes(scoped, []v32.TokenScope{{Verbs: []string{"*"}}}, cidrs)
+	assert.Error(t, err)
+}
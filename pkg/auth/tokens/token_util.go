@@ -15,6 +15,7 @@
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/user"
 	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 func getAuthProviderName(principalID string) string {
@@ -157,13 +158,59 @@ func VerifyToken(storedToken *v3.Token, tokenName, tokenKey string) (int, error)
 	return http.StatusOK, nil
 }
 
+// TokenUpdater updates tokens, it is satisfied by the token clients.
+type TokenUpdater interface {
+	Update(*v3.Token) (*v3.Token, error)
+}
+
+// RehashTokenIfNeeded re-hashes the key of a hashed token that was stored with a hasher, or hasher parameters, other
+// than the preferred one. tokenKey must already have been verified against the stored hash. Failures are logged and
+// leave the existing hash in place, so the token remains usable and the re-hash is attempted again on its next use.
+func RehashTokenIfNeeded(client TokenUpdater, storedToken *v3.Token, tokenKey string) {
+	if client == nil || storedToken == nil || storedToken.Annotations[TokenHashed] != "true" {
+		return
+	}
+	hasher := hasherForToken(storedToken)
+	if !hashers.NeedsRehashWith(storedToken.Token, hasher) {
+		return
+	}
+
+	hashedToken, err := hasher.CreateHash(tokenKey)
+	if err != nil {
+		logrus.Errorf("Failed to re-hash token [%s]: %v", storedToken.Name, err)
+		return
+	}
+
+	token := storedToken.DeepCopy()
+	token.Token = hashedToken
+	if _, err := client.Update(token); err != nil {
+		if apierrors.IsConflict(err) {
+			// another request re-hashed or changed the token first
+			logrus.Debugf("Skipped re-hashing token [%s]: %v", storedToken.Name, err)
+			return
+		}
+		logrus.Errorf("Failed to update re-hashed token [%s]: %v", storedToken.Name, err)
+		return
+	}
+	logrus.Debugf("Re-hashed token [%s] with the preferred hasher", storedToken.Name)
+}
+
+// hasherForToken returns the hasher that the key of token should be hashed with. Tokens scoped to a cluster are synced
+// to the cluster for the authorized cluster endpoint, which only verifies SHA3 hashes, so they always use SHA3.
+func hasherForToken(token *v3.Token) hashers.Hasher {
+	if token.ClusterName != "" {
+		return hashers.Sha3Hasher{}
+	}
+	return hashers.GetHasher()
+}
+
 // ConvertTokenKeyToHash takes a token with an un-hashed key and converts it to a hashed key
 func ConvertTokenKeyToHash(token *v3.Token) error {
 	if !features.TokenHashing.Enabled() {
 		return nil
 	}
 	if token != nil && len(token.Token) > 0 {
-		hasher := hashers.GetHasher()
+		hasher := hasherForToken(token)
 		hashedToken, err := hasher.CreateHash(token.Token)
 		if err != nil {
 			logrus.Errorf("Failed to generate hash from token: %v", err)
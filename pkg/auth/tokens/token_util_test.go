@@ -7,8 +7,11 @@
 	"github.com/rancher/rancher/pkg/auth/tokens/hashers"
 	"github.com/rancher/rancher/pkg/features"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestVerifyToken(t *testing.T) {
@@ -213,3 +216,122 @@ func expireToken(token *v3.Token) *v3.Token {
 	newToken.TTLMillis = 1
 	return newToken
 }
+
+type fakeTokenUpdater struct {
+	updated *v3.Token
+	err     error
+}
+
+func (f *fakeTokenUpdater) Update(token *v3.Token) (*v3.Token, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.updated = token
+	return token, nil
+}
+
+func TestRehashTokenIfNeeded(t *testing.T) {
+	tokenKey := "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	sha256Hash, err := hashers.Sha256Hasher{}.CreateHash(tokenKey)
+	require.NoError(t, err)
+	sha3Hash, err := hashers.Sha3Hasher{}.CreateHash(tokenKey)
+	require.NoError(t, err)
+
+	newToken := func(hash string, hashed bool) *v3.Token {
+		token := &v3.Token{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-token", Annotations: map[string]string{}},
+			Token:      hash,
+		}
+		if hashed {
+			token.Annotations[TokenHashed] = "true"
+		}
+		return token
+	}
+
+	tests := []struct {
+		name        string
+		token       *v3.Token
+		updateErr   error
+		wantUpdated bool
+	}{
+		{
+			name:        "outdated hasher is re-hashed",
+			token:       newToken(sha256Hash, true),
+			wantUpdated: true,
+		},
+		{
+			name:  "preferred hasher is left alone",
+			token: newToken(sha3Hash, true),
+		},
+		{
+			name:  "unhashed token is left alone",
+			token: newToken(tokenKey, false),
+		},
+		{
+			name:      "update conflicts are ignored",
+			token:     newToken(sha256Hash, true),
+			updateErr: apierrors.NewConflict(schema.GroupResource{Resource: "tokens"}, "test-token", nil),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			updater := &fakeTokenUpdater{err: test.updateErr}
+			original := test.token.Token
+			RehashTokenIfNeeded(updater, test.token, tokenKey)
+
+			require.Equal(t, original, test.token.Token, "the stored token must not be modified in place")
+			if !test.wantUpdated {
+				require.Nil(t, updater.updated)
+				return
+			}
+			require.NotNil(t, updater.updated)
+			version, err := hashers.GetHashVersion(updater.updated.Token)
+			require.NoError(t, err)
+			require.Equal(t, hashers.SHA3Version, version)
+			code, err := VerifyToken(updater.updated, "test-token", tokenKey)
+			require.NoError(t, err)
+			require.Equal(t, 200, code)
+		})
+	}
+}
+
+func TestRehashClusterTokenKeepsSHA3(t *testing.T) {
+	require.NoError(t, settings.TokenHashAlgorithm.Set(hashers.Argon2idAlgorithm))
+	require.NoError(t, settings.TokenHashArgon2idParams.Set("m=64,t=1,p=1"))
+	t.Cleanup(func() {
+		require.NoError(t, settings.TokenHashAlgorithm.Set(settings.TokenHashAlgorithm.Default))
+		require.NoError(t, settings.TokenHashArgon2idParams.Set(settings.TokenHashArgon2idParams.Default))
+	})
+
+	tokenKey := "dddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+	sha3Hash, err := hashers.Sha3Hasher{}.CreateHash(tokenKey)
+	require.NoError(t, err)
+	argon2idHash, err := hashers.Argon2idHasher{Memory: 64, Time: 1, Threads: 1}.CreateHash(tokenKey)
+	require.NoError(t, err)
+	newToken := func(hash, clusterName string) *v3.Token {
+		return &v3.Token{
+			ObjectMeta:  metav1.ObjectMeta{Name: "test-token", Annotations: map[string]string{TokenHashed: "true"}},
+			Token:       hash,
+			ClusterName: clusterName,
+		}
+	}
+
+	// tokens synced downstream must stay verifiable by the authorized cluster endpoint
+	updater := &fakeTokenUpdater{}
+	RehashTokenIfNeeded(updater, newToken(sha3Hash, "c-abc"), tokenKey)
+	require.Nil(t, updater.updated)
+
+	RehashTokenIfNeeded(updater, newToken(argon2idHash, "c-abc"), tokenKey)
+	require.NotNil(t, updater.updated)
+	version, err := hashers.GetHashVersion(updater.updated.Token)
+	require.NoError(t, err)
+	require.Equal(t, hashers.SHA3Version, version)
+
+	updater = &fakeTokenUpdater{}
+	RehashTokenIfNeeded(updater, newToken(sha3Hash, ""), tokenKey)
+	require.NotNil(t, updater.updated)
+	version, err = hashers.GetHashVersion(updater.updated.Token)
+	require.NoError(t, err)
+	require.Equal(t, hashers.Argon2idVersion, version)
+}
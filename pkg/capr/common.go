@@ -49,6 +49,7 @@
 	DrainDoneAnnotation           = "rke.cattle.io/drain-done"
 	DrainErrorAnnotation          = "rke.cattle.io/drain-error"
 	EtcdRoleLabel                 = "rke.cattle.io/etcd-role"
+	ETCDSnapshotTargetLabel       = "rke.cattle.io/etcd-snapshot-target"
 	ForceRemoveEtcdAnnotation     = "rke.cattle.io/etcd-force-remove"
 	HostnameLengthLimitAnnotation = "rke.cattle.io/hostname-length-limit"
 	InitNodeLabel                 = "rke.cattle.io/init-node"
@@ -634,3 +635,56 @@ func ParseSnapshotClusterSpecOrError(snapshot *rkev1.ETCDSnapshot) (*provv1.Clus
 	}
 	return nil, fmt.Errorf("unable to find and decode snapshot ClusterSpec for snapshot")
 }
+
+// ETCDSnapshotS3 returns the S3 location the etcd nodes upload snapshots to, which is the S3 location of the first S3
+// snapshot target if there is one.
+func ETCDSnapshotS3(etcd *rkev1.ETCD) *rkev1.ETCDSnapshotS3 {
+	if etcd == nil {
+		return nil
+	}
+	if etcd.S3 != nil {
+		return etcd.S3
+	}
+	for _, target := range etcd.SnapshotTargets {
+		if target.S3 != nil {
+			return target.S3
+		}
+	}
+	return nil
+}
+
+// ValidateETCDSnapshotTargets checks that the snapshot targets have unique names and that the S3 location of the
+// cluster is not configured alongside S3 targets.
+func ValidateETCDSnapshotTargets(etcd *rkev1.ETCD) error {
+	if etcd == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, target := range etcd.SnapshotTargets {
+		if target.Name == "" {
+			return errors.New("etcd snapshot targets must have a name")
+		}
+		if names[target.Name] {
+			return fmt.Errorf("etcd snapshot target name %s is not unique", target.Name)
+		}
+		names[target.Name] = true
+		if target.S3 != nil && etcd.S3 != nil {
+			return fmt.Errorf("etcd snapshot target %s stores snapshots in S3, which cannot be combined with the etcd S3 configuration", target.Name)
+		}
+	}
+	return nil
+}
+
+// ETCDSnapshotTargetS3 returns the S3 location of the snapshot target with the given name, or nil if there is no such S3
+// target.
+func ETCDSnapshotTargetS3(etcd *rkev1.ETCD, name string) *rkev1.ETCDSnapshotS3 {
+	if etcd == nil {
+		return nil
+	}
+	for _, target := range etcd.SnapshotTargets {
+		if target.Name == name {
+			return target.S3
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package etcds3 downloads, uploads and removes etcd snapshots stored in S3 on behalf of the etcd snapshot controllers.
+package etcds3
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+)
+
+// defaultEndpoint is the endpoint k3s and rke2 use when none is configured.
+const defaultEndpoint = "s3.amazonaws.com"
+
+// Target is the resolved S3 location of etcd snapshots and the settings required to access it.
+type Target struct {
+	Endpoint      string
+	EndpointCA    string
+	SkipSSLVerify bool
+	Region        string
+	Bucket        string
+	Folder        string
+	AccessKey     string
+	SecretKey     string
+
+	client *minio.Client
+}
+
+// first returns the first non-blank string.
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Resolve merges s3 with the fallback S3 location and the cloud credential either of them refers to, following the
+// same precedence as the arguments used to save and restore snapshots.
+func Resolve(secretCache corecontrollers.SecretCache, namespace string, s3, fallback *rkev1.ETCDSnapshotS3) (*Target, error) {
+	if s3 == nil {
+		s3 = &rkev1.ETCDSnapshotS3{}
+	}
+	if fallback == nil {
+		fallback = &rkev1.ETCDSnapshotS3{}
+	}
+
+	cred, err := planner.GetS3Credential(secretCache, namespace, first(s3.CloudCredentialName, fallback.CloudCredentialName))
+	if err != nil {
+		return nil, err
+	}
+
+	target := &Target{
+		Endpoint:      first(s3.Endpoint, cred.Endpoint, fallback.Endpoint, defaultEndpoint),
+		SkipSSLVerify: s3.SkipSSLVerify || cred.SkipSSLVerify,
+		Region:        first(s3.Region, cred.Region, fallback.Region),
+		Bucket:        first(s3.Bucket, cred.Bucket, fallback.Bucket),
+		Folder:        first(s3.Folder, cred.Folder),
+		AccessKey:     cred.AccessKey,
+		SecretKey:     cred.SecretKey,
+	}
+	target.Endpoint = strings.TrimPrefix(strings.TrimPrefix(target.Endpoint, "https://"), "http://")
+
+	// The endpoint CA of a snapshot may be the path of the file the CA was written to on the node that took it.
+	ca := s3.EndpointCA
+	if ca == "" || strings.HasSuffix(ca, ".crt") {
+		ca = first(cred.EndpointCA, fallback.EndpointCA)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(ca); err == nil {
+		ca = string(decoded)
+	}
+	target.EndpointCA = ca
+
+	if target.Bucket == "" {
+		return nil, fmt.Errorf("no S3 bucket is configured")
+	}
+	return target, nil
+}
+
+// ForSnapshot resolves the S3 location of a snapshot stored in S3. Copies of snapshots in other snapshot targets fall
+// back to the settings of their target rather than to those of the S3 location the etcd nodes upload to.
+func ForSnapshot(secretCache corecontrollers.SecretCache, snapshot *rkev1.ETCDSnapshot, controlPlane *rkev1.RKEControlPlane) (*Target, error) {
+	if snapshot.SnapshotFile.S3 == nil {
+		return nil, fmt.Errorf("snapshot %s/%s is not stored in S3", snapshot.Namespace, snapshot.Name)
+	}
+	if snapshot.SnapshotFile.Name == "" {
+		return nil, fmt.Errorf("snapshot %s/%s has no file name", snapshot.Namespace, snapshot.Name)
+	}
+	fallback := capr.ETCDSnapshotS3(controlPlane.Spec.ETCD)
+	if targetName := snapshot.Labels[capr.ETCDSnapshotTargetLabel]; targetName != "" {
+		fallback = capr.ETCDSnapshotTargetS3(controlPlane.Spec.ETCD, targetName)
+	}
+	return Resolve(secretCache, controlPlane.Namespace, snapshot.SnapshotFile.S3, fallback)
+}
+
+// Key returns the object key of the snapshot with the given file name.
+func (t *Target) Key(name string) string {
+	return path.Join(t.Folder, name)
+}
+
+func (t *Target) getClient() (*minio.Client, error) {
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: t.SkipSSLVerify},
+	}
+	if t.EndpointCA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.EndpointCA)) {
+			return nil, fmt.Errorf("failed to parse S3 endpoint CA")
+		}
+		tr.TLSClientConfig.RootCAs = pool
+	}
+
+	creds := credentials.NewIAM("")
+	if t.AccessKey != "" && t.SecretKey != "" {
+		creds = credentials.NewStaticV4(t.AccessKey, t.SecretKey, "")
+	}
+
+	client, err := minio.New(t.Endpoint, &minio.Options{
+		Creds:        creds,
+		Region:       t.Region,
+		Secure:       true,
+		BucketLookup: minio.BucketLookupAuto,
+		Transport:    tr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	t.client = client
+	return client, nil
+}
+
+// Download fetches the snapshot with the given file name into dir and returns the path of the downloaded file.
+func (t *Target) Download(ctx context.Context, name, dir string) (string, error) {
+	client, err := t.getClient()
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, path.Base(name))
+	if err := client.FGetObject(ctx, t.Bucket, t.Key(name), dest, minio.GetObjectOptions{}); err != nil {
+		return "", fmt.Errorf("failed to download snapshot %s from bucket %s: %w", t.Key(name), t.Bucket, err)
+	}
+	return dest, nil
+}
+
+// Upload stores the file at filePath as the snapshot with the given file name.
+func (t *Target) Upload(ctx context.Context, name, filePath string) error {
+	client, err := t.getClient()
+	if err != nil {
+		return err
+	}
+
+	opts := minio.PutObjectOptions{ContentType: "application/octet-stream"}
+	if _, err := client.FPutObject(ctx, t.Bucket, t.Key(name), filePath, opts); err != nil {
+		return fmt.Errorf("failed to upload snapshot %s to bucket %s: %w", t.Key(name), t.Bucket, err)
+	}
+	return nil
+}
+
+// Remove deletes the snapshot with the given file name. Removing a snapshot that does not exist is not an error.
+func (t *Target) Remove(ctx context.Context, name string) error {
+	client, err := t.getClient()
+	if err != nil {
+		return err
+	}
+	if err := client.RemoveObject(ctx, t.Bucket, t.Key(name), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s from bucket %s: %w", t.Key(name), t.Bucket, err)
+	}
+	return nil
+}
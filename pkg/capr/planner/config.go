@@ -37,10 +37,17 @@ func (p *Planner) addETCD(config map[string]interface{}, controlPlane *rkev1.RKE
 		return nil, nil
 	}
 
+	if err := capr.ValidateETCDSnapshotTargets(controlPlane.Spec.ETCD); err != nil {
+		return nil, err
+	}
+
 	if controlPlane.Spec.ETCD.DisableSnapshots {
 		config["etcd-disable-snapshots"] = true
 	}
-	if controlPlane.Spec.ETCD.SnapshotRetention > 0 {
+	if len(controlPlane.Spec.ETCD.SnapshotTargets) > 0 {
+		// The retention of each snapshot target is enforced by Rancher and the periodic prune instruction instead.
+		config["etcd-snapshot-retention"] = 0
+	} else if controlPlane.Spec.ETCD.SnapshotRetention > 0 {
 		config["etcd-snapshot-retention"] = controlPlane.Spec.ETCD.SnapshotRetention
 	}
 	if controlPlane.Spec.ETCD.SnapshotScheduleCron != "" {
@@ -48,7 +55,7 @@ func (p *Planner) addETCD(config map[string]interface{}, controlPlane *rkev1.RKE
 	}
 
 	if renderS3 {
-		args, _, files, err := p.etcdS3Args.ToArgs(controlPlane.Spec.ETCD.S3, controlPlane, "etcd-", false)
+		args, _, files, err := p.etcdS3Args.ToArgs(capr.ETCDSnapshotS3(controlPlane.Spec.ETCD), controlPlane, "etcd-", false)
 		if err != nil {
 			return nil, err
 		}
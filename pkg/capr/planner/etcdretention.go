@@ -0,0 +1,199 @@
+package planner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/wrangler/v2/pkg/data/convert"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	etcdSnapshotRetentionInstallRoot = "/var/lib/rancher"
+	etcdSnapshotRetentionBinPrefix   = "capr/etcd-snapshot-retention/bin"
+
+	// defaultEtcdSnapshotRetention is the number of snapshots k3s and rke2 keep by default.
+	defaultEtcdSnapshotRetention = 5
+
+	etcdSnapshotPruneLocalInstructionName = "etcd-snapshot-prune-local"
+	etcdSnapshotPruneLocalPath            = "prune_local_snapshots.sh"
+	etcdSnapshotPruneLocalListPath        = "prune_local_snapshots.list"
+	// etcdSnapshotPruneLocalScript removes the snapshots named in a list from a directory. The list is computed by
+	// Rancher with RetainedETCDSnapshots, so that local and S3 snapshots are kept by the same retention, and only names
+	// snapshots Rancher knows of, so snapshots taken since the list was computed are never removed.
+	etcdSnapshotPruneLocalScript = `
+#!/bin/sh
+
+DIR="$1"
+LIST="$2"
+
+if [ ! -d "$DIR" ] || [ ! -f "$LIST" ]; then
+	exit 0
+fi
+
+while read -r f; do
+	case "$f" in
+	"" | */* | . | ..)
+		continue
+		;;
+	esac
+	if [ -f "$DIR/$f" ]; then
+		rm -f "$DIR/$f"
+		echo "pruned $f"
+	fi
+done < "$LIST"
+`
+)
+
+// localEtcdSnapshotRetention returns the retention of the snapshots stored on the etcd nodes. Snapshots are always
+// stored locally, so local snapshots that are not covered by a target are kept according to the snapshot retention.
+func localEtcdSnapshotRetention(etcd *rkev1.ETCD) rkev1.ETCDSnapshotRetention {
+	for i, target := range etcd.SnapshotTargets {
+		if target.S3 == nil {
+			return EtcdSnapshotTargetRetention(etcd, &etcd.SnapshotTargets[i])
+		}
+	}
+	return EtcdSnapshotTargetRetention(etcd, nil)
+}
+
+// EtcdSnapshotTargetRetention returns the retention of the snapshot target. Targets without a retention, or with a
+// retention that would not keep any snapshot, keep the snapshot retention number of snapshots.
+func EtcdSnapshotTargetRetention(etcd *rkev1.ETCD, target *rkev1.ETCDSnapshotTarget) rkev1.ETCDSnapshotRetention {
+	if target != nil && target.Retention != nil && *target.Retention != (rkev1.ETCDSnapshotRetention{}) {
+		return *target.Retention
+	}
+	if etcd.SnapshotRetention > 0 {
+		return rkev1.ETCDSnapshotRetention{Count: etcd.SnapshotRetention}
+	}
+	return rkev1.ETCDSnapshotRetention{Count: defaultEtcdSnapshotRetention}
+}
+
+// RetainedETCDSnapshots returns the file names of the snapshots that are kept by the retention. A snapshot is kept if
+// it is one of the Count newest snapshots, or the newest snapshot of one of the most recent Hourly hours, Daily days,
+// Weekly weeks or Monthly months that have a snapshot. Snapshots without a creation time are not considered.
+func RetainedETCDSnapshots(snapshots []*rkev1.ETCDSnapshot, retention rkev1.ETCDSnapshotRetention) map[string]bool {
+	var sorted []*rkev1.ETCDSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.SnapshotFile.CreatedAt != nil {
+			sorted = append(sorted, snapshot)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SnapshotFile.CreatedAt.After(sorted[j].SnapshotFile.CreatedAt.Time)
+	})
+
+	keep := map[string]bool{}
+	tier := func(limit int, period func(time.Time) string) {
+		var last string
+		n := 0
+		for _, snapshot := range sorted {
+			p := snapshot.SnapshotFile.Name
+			if period != nil {
+				p = period(snapshot.SnapshotFile.CreatedAt.UTC())
+			}
+			if n > 0 && p == last {
+				continue
+			}
+			if n++; n > limit {
+				return
+			}
+			keep[snapshot.SnapshotFile.Name] = true
+			last = p
+		}
+	}
+
+	tier(retention.Count, nil)
+	tier(retention.Hourly, func(t time.Time) string { return t.Format("2006010215") })
+	tier(retention.Daily, func(t time.Time) string { return t.Format("20060102") })
+	tier(retention.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d%02d", year, week)
+	})
+	tier(retention.Monthly, func(t time.Time) string { return t.Format("200601") })
+	return keep
+}
+
+// localEtcdSnapshotsToPrune returns the names of the local snapshots of the machine that are not kept by the retention
+// of local snapshots, as recorded by the etcd snapshot objects of the cluster.
+func (p *Planner) localEtcdSnapshotsToPrune(controlPlane *rkev1.RKEControlPlane, entry *planEntry) ([]string, error) {
+	if entry == nil || entry.Machine == nil || entry.Machine.Labels[capr.MachineIDLabel] == "" {
+		return nil, nil
+	}
+	snapshots, err := p.etcdSnapshotCache.List(controlPlane.Namespace, labels.SelectorFromSet(labels.Set{
+		capr.ClusterNameLabel: controlPlane.Name,
+		capr.MachineIDLabel:   entry.Machine.Labels[capr.MachineIDLabel],
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var local []*rkev1.ETCDSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.DeletionTimestamp == nil && snapshot.SnapshotFile.S3 == nil {
+			local = append(local, snapshot)
+		}
+	}
+	keep := RetainedETCDSnapshots(local, localEtcdSnapshotRetention(controlPlane.Spec.ETCD))
+
+	var prune []string
+	for _, snapshot := range local {
+		if snapshot.SnapshotFile.CreatedAt != nil && !keep[snapshot.SnapshotFile.Name] {
+			prune = append(prune, snapshot.SnapshotFile.Name)
+		}
+	}
+	sort.Strings(prune)
+	return prune, nil
+}
+
+// addEtcdSnapshotPruneLocalPeriodicInstruction adds the script, the list of snapshots to prune and the periodic
+// instruction that enforce the retention of local snapshots on etcd nodes of clusters with snapshot targets. The
+// retention built into k3s and rke2 is disabled for these clusters as it only supports keeping a number of snapshots.
+func (p *Planner) addEtcdSnapshotPruneLocalPeriodicInstruction(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, entry *planEntry, config map[string]interface{}) (plan.NodePlan, error) {
+	if controlPlane.Spec.ETCD == nil || len(controlPlane.Spec.ETCD.SnapshotTargets) == 0 {
+		return nodePlan, nil
+	}
+
+	prune, err := p.localEtcdSnapshotsToPrune(controlPlane, entry)
+	if err != nil {
+		return nodePlan, err
+	}
+	var list strings.Builder
+	for _, name := range prune {
+		list.WriteString(name + "\n")
+	}
+
+	runtime := capr.GetRuntime(controlPlane.Spec.KubernetesVersion)
+	snapshotDir := convert.ToString(config["etcd-snapshot-dir"])
+	if snapshotDir == "" {
+		snapshotDir = fmt.Sprintf("/var/lib/rancher/%s/server/db/snapshots", runtime)
+	}
+	binDir := fmt.Sprintf("%s/%s/%s", etcdSnapshotRetentionInstallRoot, runtime, etcdSnapshotRetentionBinPrefix)
+	scriptPath := binDir + "/" + etcdSnapshotPruneLocalPath
+	listPath := binDir + "/" + etcdSnapshotPruneLocalListPath
+
+	nodePlan.Files = append(nodePlan.Files,
+		plan.File{
+			Content: base64.StdEncoding.EncodeToString([]byte(etcdSnapshotPruneLocalScript)),
+			Path:    scriptPath,
+			Dynamic: true,
+		},
+		plan.File{
+			Content: base64.StdEncoding.EncodeToString([]byte(list.String())),
+			Path:    listPath,
+			Dynamic: true,
+			Minor:   true,
+		})
+	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
+		Name:          etcdSnapshotPruneLocalInstructionName,
+		Command:       "sh",
+		Args:          []string{scriptPath, snapshotDir, listPath},
+		PeriodSeconds: 600,
+	})
+	return nodePlan, nil
+}
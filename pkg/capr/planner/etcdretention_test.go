@@ -0,0 +1,193 @@
+package planner
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestEtcdSnapshotTargetRetention(t *testing.T) {
+	tests := []struct {
+		name   string
+		etcd   *rkev1.ETCD
+		target *rkev1.ETCDSnapshotTarget
+		want   rkev1.ETCDSnapshotRetention
+	}{
+		{
+			name: "default",
+			etcd: &rkev1.ETCD{},
+			want: rkev1.ETCDSnapshotRetention{Count: defaultEtcdSnapshotRetention},
+		},
+		{
+			name: "snapshot retention",
+			etcd: &rkev1.ETCD{SnapshotRetention: 3},
+			want: rkev1.ETCDSnapshotRetention{Count: 3},
+		},
+		{
+			name:   "empty target retention",
+			etcd:   &rkev1.ETCD{SnapshotRetention: 3},
+			target: &rkev1.ETCDSnapshotTarget{Name: "local", Retention: &rkev1.ETCDSnapshotRetention{}},
+			want:   rkev1.ETCDSnapshotRetention{Count: 3},
+		},
+		{
+			name:   "target retention",
+			etcd:   &rkev1.ETCD{SnapshotRetention: 3},
+			target: &rkev1.ETCDSnapshotTarget{Name: "local", Retention: &rkev1.ETCDSnapshotRetention{Daily: 7, Weekly: 4}},
+			want:   rkev1.ETCDSnapshotRetention{Daily: 7, Weekly: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, EtcdSnapshotTargetRetention(tt.etcd, tt.target))
+		})
+	}
+}
+
+func newSnapshot(name string, createdAt time.Time) *rkev1.ETCDSnapshot {
+	return &rkev1.ETCDSnapshot{
+		ObjectMeta:   metav1.ObjectMeta{Name: name},
+		SnapshotFile: rkev1.ETCDSnapshotFile{Name: name, CreatedAt: &metav1.Time{Time: createdAt}},
+	}
+}
+
+func TestRetainedETCDSnapshots(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	// A snapshot every 6 hours for 60 days, the newest snapshot is taken at the end of the last day.
+	var snapshots []*rkev1.ETCDSnapshot
+	var newest time.Time
+	for i := 0; i < 60*4; i++ {
+		newest = start.Add(time.Duration(i) * 6 * time.Hour)
+		snapshots = append(snapshots, newSnapshot(newest.Format(time.RFC3339), newest))
+	}
+	name := func(t time.Time) string { return t.Format(time.RFC3339) }
+
+	tests := []struct {
+		name      string
+		retention rkev1.ETCDSnapshotRetention
+		want      []string
+	}{
+		{
+			name:      "count",
+			retention: rkev1.ETCDSnapshotRetention{Count: 2},
+			want:      []string{name(newest), name(newest.Add(-6 * time.Hour))},
+		},
+		{
+			name:      "daily",
+			retention: rkev1.ETCDSnapshotRetention{Daily: 3},
+			want: []string{
+				name(newest),
+				name(newest.Add(-24 * time.Hour)),
+				name(newest.Add(-48 * time.Hour)),
+			},
+		},
+		{
+			name:      "monthly",
+			retention: rkev1.ETCDSnapshotRetention{Monthly: 12},
+			want: []string{
+				name(newest),
+				// the newest snapshot of March
+				name(time.Date(2024, 3, 31, 18, 0, 0, 0, time.UTC)),
+			},
+		},
+		{
+			name:      "tiers overlap",
+			retention: rkev1.ETCDSnapshotRetention{Count: 1, Hourly: 1, Daily: 2},
+			want:      []string{name(newest), name(newest.Add(-24 * time.Hour))},
+		},
+		{
+			name:      "empty",
+			retention: rkev1.ETCDSnapshotRetention{},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep := RetainedETCDSnapshots(snapshots, tt.retention)
+			var got []string
+			for k := range keep {
+				got = append(got, k)
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetainedETCDSnapshotsWeekly(t *testing.T) {
+	// 2024-01-01 is a Monday, a daily snapshot for three weeks.
+	var snapshots []*rkev1.ETCDSnapshot
+	for i := 0; i < 21; i++ {
+		createdAt := time.Date(2024, 1, 1+i, 12, 0, 0, 0, time.UTC)
+		snapshots = append(snapshots, newSnapshot(createdAt.Format("2006-01-02"), createdAt))
+	}
+	// snapshots without a creation time are not considered
+	snapshots = append(snapshots, &rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{Name: "unknown"}})
+
+	keep := RetainedETCDSnapshots(snapshots, rkev1.ETCDSnapshotRetention{Weekly: 2})
+	assert.Equal(t, map[string]bool{"2024-01-21": true, "2024-01-14": true}, keep)
+}
+
+func TestAddEtcdSnapshotPruneLocalPeriodicInstruction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	etcdSnapshotCache := fake.NewMockCacheInterface[*rkev1.ETCDSnapshot](ctrl)
+	controlPlane := &rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "c1"},
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion: "v1.27.4+rke2r1",
+		},
+	}
+	entry := &planEntry{Machine: &capi.Machine{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{capr.MachineIDLabel: "abc"}}}}
+	p := &Planner{etcdSnapshotCache: etcdSnapshotCache}
+
+	nodePlan, err := p.addEtcdSnapshotPruneLocalPeriodicInstruction(plan.NodePlan{}, controlPlane, entry, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Empty(t, nodePlan.Files)
+	assert.Empty(t, nodePlan.PeriodicInstructions)
+
+	controlPlane.Spec.ETCD = &rkev1.ETCD{
+		SnapshotTargets: []rkev1.ETCDSnapshotTarget{
+			{Name: "s3", S3: &rkev1.ETCDSnapshotS3{Bucket: "snapshots"}},
+			{Name: "local", Retention: &rkev1.ETCDSnapshotRetention{Count: 2}},
+		},
+	}
+	now := time.Now()
+	s3Snapshot := newSnapshot("on-s3", now.Add(-4*time.Hour))
+	s3Snapshot.SnapshotFile.S3 = &rkev1.ETCDSnapshotS3{Bucket: "snapshots"}
+	etcdSnapshotCache.EXPECT().List("fleet-default", labels.SelectorFromSet(labels.Set{
+		capr.ClusterNameLabel: "c1",
+		capr.MachineIDLabel:   "abc",
+	})).Return([]*rkev1.ETCDSnapshot{
+		newSnapshot("newest", now),
+		newSnapshot("oldest", now.Add(-3*time.Hour)),
+		newSnapshot("older", now.Add(-2*time.Hour)),
+		newSnapshot("newer", now.Add(-time.Hour)),
+		s3Snapshot,
+	}, nil)
+	nodePlan, err = p.addEtcdSnapshotPruneLocalPeriodicInstruction(plan.NodePlan{}, controlPlane, entry, map[string]interface{}{"etcd-snapshot-dir": "/snapshots"})
+	require.NoError(t, err)
+
+	binDir := "/var/lib/rancher/rke2/capr/etcd-snapshot-retention/bin"
+	require.Len(t, nodePlan.Files, 2)
+	assert.Equal(t, binDir+"/prune_local_snapshots.sh", nodePlan.Files[0].Path)
+	assert.True(t, nodePlan.Files[0].Dynamic)
+	assert.Equal(t, binDir+"/prune_local_snapshots.list", nodePlan.Files[1].Path)
+	assert.True(t, nodePlan.Files[1].Minor)
+	list, err := base64.StdEncoding.DecodeString(nodePlan.Files[1].Content)
+	require.NoError(t, err)
+	assert.Equal(t, "older\noldest\n", string(list))
+	require.Len(t, nodePlan.PeriodicInstructions, 1)
+	assert.Equal(t, etcdSnapshotPruneLocalInstructionName, nodePlan.PeriodicInstructions[0].Name)
+	assert.Equal(t, []string{binDir + "/prune_local_snapshots.sh", "/snapshots", binDir + "/prune_local_snapshots.list"}, nodePlan.PeriodicInstructions[0].Args)
+}
@@ -1139,7 +1139,11 @@ func (p *Planner) desiredPlan(controlPlane *rkev1.RKEControlPlane, tokensSecret
 		if err != nil {
 			return nodePlan, joinedTo, err
 		}
-		if controlPlane != nil && controlPlane.Spec.ETCD != nil && S3Enabled(controlPlane.Spec.ETCD.S3) && isInitNode(entry) {
+		nodePlan, err = p.addEtcdSnapshotPruneLocalPeriodicInstruction(nodePlan, controlPlane, entry, config)
+		if err != nil {
+			return nodePlan, joinedTo, err
+		}
+		if controlPlane != nil && S3Enabled(capr.ETCDSnapshotS3(controlPlane.Spec.ETCD)) && isInitNode(entry) {
 			nodePlan, err = p.addEtcdSnapshotListS3PeriodicInstruction(nodePlan, controlPlane)
 			if err != nil {
 				return nodePlan, joinedTo, err
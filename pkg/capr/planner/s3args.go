@@ -7,6 +7,7 @@
 
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/rancher/pkg/capr"
 	"github.com/rancher/rancher/pkg/controllers/capr/machineprovision"
 	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
 	"github.com/rancher/wrangler/v2/pkg/kv"
@@ -48,17 +49,18 @@ func (s *s3Args) ToArgs(s3 *rkev1.ETCDSnapshotS3, controlPlane *rkev1.RKEControl
 	}
 
 	var (
-		s3Cred s3Credential
+		s3Cred S3Credential
 	)
 
-	controlPlaneEtcdS3NotNil := controlPlane.Spec.ETCD != nil && controlPlane.Spec.ETCD.S3 != nil
+	controlPlaneS3 := capr.ETCDSnapshotS3(controlPlane.Spec.ETCD)
+	controlPlaneEtcdS3NotNil := controlPlaneS3 != nil
 
 	credName := s3.CloudCredentialName
 	if credName == "" && controlPlaneEtcdS3NotNil {
-		credName = controlPlane.Spec.ETCD.S3.CloudCredentialName
+		credName = controlPlaneS3.CloudCredentialName
 	}
 
-	s3Cred, err = getS3Credential(s.secretCache, controlPlane.Namespace, credName)
+	s3Cred, err = GetS3Credential(s.secretCache, controlPlane.Namespace, credName)
 	if err != nil {
 		return
 	}
@@ -101,8 +103,8 @@ func (s *s3Args) ToArgs(s3 *rkev1.ETCDSnapshotS3, controlPlane *rkev1.RKEControl
 				if possibleCA != nil {
 					files = append(files, *possibleCA)
 				}
-			} else if controlPlaneEtcdS3NotNil && controlPlane.Spec.ETCD.S3.EndpointCA != "" {
-				possibleCA := generateEndpointCAFileIfPathMatches(controlPlane, v, controlPlane.Spec.ETCD.S3.EndpointCA)
+			} else if controlPlaneEtcdS3NotNil && controlPlaneS3.EndpointCA != "" {
+				possibleCA := generateEndpointCAFileIfPathMatches(controlPlane, v, controlPlaneS3.EndpointCA)
 				if possibleCA != nil {
 					files = append(files, *possibleCA)
 				}
@@ -147,7 +149,8 @@ func generateEndpointCAFileIfPathMatches(controlPlane *rkev1.RKEControlPlane, ex
 	return nil
 }
 
-type s3Credential struct {
+// S3Credential holds the S3 settings stored in an S3 cloud credential.
+type S3Credential struct {
 	AccessKey     string
 	SecretKey     string
 	Region        string
@@ -158,7 +161,9 @@ type s3Credential struct {
 	Folder        string
 }
 
-func getS3Credential(secretCache corecontrollers.SecretCache, namespace, name string) (result s3Credential, _ error) {
+// GetS3Credential returns the S3 settings of the cloud credential with the given name. An empty credential is returned if
+// name is empty.
+func GetS3Credential(secretCache corecontrollers.SecretCache, namespace, name string) (result S3Credential, _ error) {
 	if name == "" {
 		return result, nil
 	}
@@ -174,7 +179,7 @@ func getS3Credential(secretCache corecontrollers.SecretCache, namespace, name st
 		data[k] = v
 	}
 
-	return s3Credential{
+	return S3Credential{
 		AccessKey:     string(data["accessKey"]),
 		SecretKey:     string(data["secretKey"]),
 		Region:        string(data["defaultRegion"]),
@@ -0,0 +1,16 @@
+package client
+
+const (
+	AgentProxyConfigType                  = "agentProxyConfig"
+	AgentProxyConfigFieldCACerts          = "caCerts"
+	AgentProxyConfigFieldCredentialSecret = "credentialSecret"
+	AgentProxyConfigFieldNoProxy          = "noProxy"
+	AgentProxyConfigFieldURL              = "url"
+)
+
+type AgentProxyConfig struct {
+	CACerts          string   `json:"caCerts,omitempty" yaml:"caCerts,omitempty"`
+	CredentialSecret string   `json:"credentialSecret,omitempty" yaml:"credentialSecret,omitempty"`
+	NoProxy          []string `json:"noProxy,omitempty" yaml:"noProxy,omitempty"`
+	URL              string   `json:"url,omitempty" yaml:"url,omitempty"`
+}
@@ -19,6 +19,7 @@
 	ClusterFieldAnnotations                                          = "annotations"
 	ClusterFieldAppliedAgentEnvVars                                  = "appliedAgentEnvVars"
 	ClusterFieldAppliedClusterAgentDeploymentCustomization           = "appliedClusterAgentDeploymentCustomization"
+	ClusterFieldAppliedClusterAgentProxyConfig                       = "appliedClusterAgentProxyConfig"
 	ClusterFieldAppliedEnableNetworkPolicy                           = "appliedEnableNetworkPolicy"
 	ClusterFieldAppliedPodSecurityPolicyTemplateName                 = "appliedPodSecurityPolicyTemplateId"
 	ClusterFieldAppliedSpec                                          = "appliedSpec"
@@ -28,6 +29,7 @@
 	ClusterFieldCapacity                                             = "capacity"
 	ClusterFieldCertificatesExpiration                               = "certificatesExpiration"
 	ClusterFieldClusterAgentDeploymentCustomization                  = "clusterAgentDeploymentCustomization"
+	ClusterFieldClusterAgentProxyConfig                              = "clusterAgentProxyConfig"
 	ClusterFieldClusterSecrets                                       = "clusterSecrets"
 	ClusterFieldClusterTemplateAnswers                               = "answers"
 	ClusterFieldClusterTemplateID                                    = "clusterTemplateId"
@@ -35,6 +37,7 @@
 	ClusterFieldClusterTemplateRevisionID                            = "clusterTemplateRevisionId"
 	ClusterFieldComponentStatuses                                    = "componentStatuses"
 	ClusterFieldConditions                                           = "conditions"
+	ClusterFieldConnectionHistory                                    = "connectionHistory"
 	ClusterFieldCreated                                              = "created"
 	ClusterFieldCreatorID                                            = "creatorId"
 	ClusterFieldCurrentCisRunName                                    = "currentCisRunName"
@@ -102,6 +105,7 @@ type Cluster struct {
 	Annotations                                          map[string]string              `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 	AppliedAgentEnvVars                                  []EnvVar                       `json:"appliedAgentEnvVars,omitempty" yaml:"appliedAgentEnvVars,omitempty"`
 	AppliedClusterAgentDeploymentCustomization           *AgentDeploymentCustomization  `json:"appliedClusterAgentDeploymentCustomization,omitempty" yaml:"appliedClusterAgentDeploymentCustomization,omitempty"`
+	AppliedClusterAgentProxyConfig                       *AgentProxyConfig              `json:"appliedClusterAgentProxyConfig,omitempty" yaml:"appliedClusterAgentProxyConfig,omitempty"`
 	AppliedEnableNetworkPolicy                           bool                           `json:"appliedEnableNetworkPolicy,omitempty" yaml:"appliedEnableNetworkPolicy,omitempty"`
 	AppliedPodSecurityPolicyTemplateName                 string                         `json:"appliedPodSecurityPolicyTemplateId,omitempty" yaml:"appliedPodSecurityPolicyTemplateId,omitempty"`
 	AppliedSpec                                          *ClusterSpec                   `json:"appliedSpec,omitempty" yaml:"appliedSpec,omitempty"`
@@ -111,6 +115,7 @@ type Cluster struct {
 	Capacity                                             map[string]string              `json:"capacity,omitempty" yaml:"capacity,omitempty"`
 	CertificatesExpiration                               map[string]CertExpiration      `json:"certificatesExpiration,omitempty" yaml:"certificatesExpiration,omitempty"`
 	ClusterAgentDeploymentCustomization                  *AgentDeploymentCustomization  `json:"clusterAgentDeploymentCustomization,omitempty" yaml:"clusterAgentDeploymentCustomization,omitempty"`
+	ClusterAgentProxyConfig                              *AgentProxyConfig              `json:"clusterAgentProxyConfig,omitempty" yaml:"clusterAgentProxyConfig,omitempty"`
 	ClusterSecrets                                       *ClusterSecrets                `json:"clusterSecrets,omitempty" yaml:"clusterSecrets,omitempty"`
 	ClusterTemplateAnswers                               *Answer                        `json:"answers,omitempty" yaml:"answers,omitempty"`
 	ClusterTemplateID                                    string                         `json:"clusterTemplateId,omitempty" yaml:"clusterTemplateId,omitempty"`
@@ -118,6 +123,7 @@ type Cluster struct {
 	ClusterTemplateRevisionID                            string                         `json:"clusterTemplateRevisionId,omitempty" yaml:"clusterTemplateRevisionId,omitempty"`
 	ComponentStatuses                                    []ClusterComponentStatus       `json:"componentStatuses,omitempty" yaml:"componentStatuses,omitempty"`
 	Conditions                                           []ClusterCondition             `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	ConnectionHistory                                    []ClusterConnectionEvent       `json:"connectionHistory,omitempty" yaml:"connectionHistory,omitempty"`
 	Created                                              string                         `json:"created,omitempty" yaml:"created,omitempty"`
 	CreatorID                                            string                         `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
 	CurrentCisRunName                                    string                         `json:"currentCisRunName,omitempty" yaml:"currentCisRunName,omitempty"`
@@ -0,0 +1,22 @@
+package client
+
+const (
+	ClusterConnectionEventType               = "clusterConnectionEvent"
+	ClusterConnectionEventFieldClientKey     = "clientKey"
+	ClusterConnectionEventFieldDuration      = "duration"
+	ClusterConnectionEventFieldReason        = "reason"
+	ClusterConnectionEventFieldRemoteAddress = "remoteAddress"
+	ClusterConnectionEventFieldServer        = "server"
+	ClusterConnectionEventFieldTime          = "time"
+	ClusterConnectionEventFieldType          = "type"
+)
+
+type ClusterConnectionEvent struct {
+	ClientKey     string `json:"clientKey,omitempty" yaml:"clientKey,omitempty"`
+	Duration      string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Reason        string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	RemoteAddress string `json:"remoteAddress,omitempty" yaml:"remoteAddress,omitempty"`
+	Server        string `json:"server,omitempty" yaml:"server,omitempty"`
+	Time          string `json:"time,omitempty" yaml:"time,omitempty"`
+	Type          string `json:"type,omitempty" yaml:"type,omitempty"`
+}
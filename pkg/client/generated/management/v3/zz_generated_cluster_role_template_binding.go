@@ -10,6 +10,7 @@
 	ClusterRoleTemplateBindingFieldClusterID        = "clusterId"
 	ClusterRoleTemplateBindingFieldCreated          = "created"
 	ClusterRoleTemplateBindingFieldCreatorID        = "creatorId"
+	ClusterRoleTemplateBindingFieldExpiresAt        = "expiresAt"
 	ClusterRoleTemplateBindingFieldGroupID          = "groupId"
 	ClusterRoleTemplateBindingFieldGroupPrincipalID = "groupPrincipalId"
 	ClusterRoleTemplateBindingFieldLabels           = "labels"
@@ -18,6 +19,8 @@
 	ClusterRoleTemplateBindingFieldOwnerReferences  = "ownerReferences"
 	ClusterRoleTemplateBindingFieldRemoved          = "removed"
 	ClusterRoleTemplateBindingFieldRoleTemplateID   = "roleTemplateId"
+	ClusterRoleTemplateBindingFieldStatus           = "status"
+	ClusterRoleTemplateBindingFieldTTL              = "ttl"
 	ClusterRoleTemplateBindingFieldUUID             = "uuid"
 	ClusterRoleTemplateBindingFieldUserID           = "userId"
 	ClusterRoleTemplateBindingFieldUserPrincipalID  = "userPrincipalId"
@@ -25,21 +28,24 @@
 
 type ClusterRoleTemplateBinding struct {
 	types.Resource
-	Annotations      map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
-	ClusterID        string            `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
-	Created          string            `json:"created,omitempty" yaml:"created,omitempty"`
-	CreatorID        string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
-	GroupID          string            `json:"groupId,omitempty" yaml:"groupId,omitempty"`
-	GroupPrincipalID string            `json:"groupPrincipalId,omitempty" yaml:"groupPrincipalId,omitempty"`
-	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
-	Name             string            `json:"name,omitempty" yaml:"name,omitempty"`
-	NamespaceId      string            `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
-	OwnerReferences  []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
-	Removed          string            `json:"removed,omitempty" yaml:"removed,omitempty"`
-	RoleTemplateID   string            `json:"roleTemplateId,omitempty" yaml:"roleTemplateId,omitempty"`
-	UUID             string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
-	UserID           string            `json:"userId,omitempty" yaml:"userId,omitempty"`
-	UserPrincipalID  string            `json:"userPrincipalId,omitempty" yaml:"userPrincipalId,omitempty"`
+	Annotations      map[string]string  `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	ClusterID        string             `json:"clusterId,omitempty" yaml:"clusterId,omitempty"`
+	Created          string             `json:"created,omitempty" yaml:"created,omitempty"`
+	CreatorID        string             `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	ExpiresAt        string             `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	GroupID          string             `json:"groupId,omitempty" yaml:"groupId,omitempty"`
+	GroupPrincipalID string             `json:"groupPrincipalId,omitempty" yaml:"groupPrincipalId,omitempty"`
+	Labels           map[string]string  `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Name             string             `json:"name,omitempty" yaml:"name,omitempty"`
+	NamespaceId      string             `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
+	OwnerReferences  []OwnerReference   `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+	Removed          string             `json:"removed,omitempty" yaml:"removed,omitempty"`
+	RoleTemplateID   string             `json:"roleTemplateId,omitempty" yaml:"roleTemplateId,omitempty"`
+	Status           *RoleBindingStatus `json:"status,omitempty" yaml:"status,omitempty"`
+	TTL              string             `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	UUID             string             `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	UserID           string             `json:"userId,omitempty" yaml:"userId,omitempty"`
+	UserPrincipalID  string             `json:"userPrincipalId,omitempty" yaml:"userPrincipalId,omitempty"`
 }
 
 type ClusterRoleTemplateBindingCollection struct {
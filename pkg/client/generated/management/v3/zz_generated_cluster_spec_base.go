@@ -5,6 +5,7 @@
 	ClusterSpecBaseFieldAgentEnvVars                                         = "agentEnvVars"
 	ClusterSpecBaseFieldAgentImageOverride                                   = "agentImageOverride"
 	ClusterSpecBaseFieldClusterAgentDeploymentCustomization                  = "clusterAgentDeploymentCustomization"
+	ClusterSpecBaseFieldClusterAgentProxyConfig                              = "clusterAgentProxyConfig"
 	ClusterSpecBaseFieldClusterSecrets                                       = "clusterSecrets"
 	ClusterSpecBaseFieldDefaultClusterRoleForProjectMembers                  = "defaultClusterRoleForProjectMembers"
 	ClusterSpecBaseFieldDefaultPodSecurityAdmissionConfigurationTemplateName = "defaultPodSecurityAdmissionConfigurationTemplateName"
@@ -23,6 +24,7 @@ type ClusterSpecBase struct {
 	AgentEnvVars                                         []EnvVar                       `json:"agentEnvVars,omitempty" yaml:"agentEnvVars,omitempty"`
 	AgentImageOverride                                   string                         `json:"agentImageOverride,omitempty" yaml:"agentImageOverride,omitempty"`
 	ClusterAgentDeploymentCustomization                  *AgentDeploymentCustomization  `json:"clusterAgentDeploymentCustomization,omitempty" yaml:"clusterAgentDeploymentCustomization,omitempty"`
+	ClusterAgentProxyConfig                              *AgentProxyConfig              `json:"clusterAgentProxyConfig,omitempty" yaml:"clusterAgentProxyConfig,omitempty"`
 	ClusterSecrets                                       *ClusterSecrets                `json:"clusterSecrets,omitempty" yaml:"clusterSecrets,omitempty"`
 	DefaultClusterRoleForProjectMembers                  string                         `json:"defaultClusterRoleForProjectMembers,omitempty" yaml:"defaultClusterRoleForProjectMembers,omitempty"`
 	DefaultPodSecurityAdmissionConfigurationTemplateName string                         `json:"defaultPodSecurityAdmissionConfigurationTemplateName,omitempty" yaml:"defaultPodSecurityAdmissionConfigurationTemplateName,omitempty"`
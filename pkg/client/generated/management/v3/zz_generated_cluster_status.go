@@ -11,6 +11,7 @@
 	ClusterStatusFieldAllocatable                                = "allocatable"
 	ClusterStatusFieldAppliedAgentEnvVars                        = "appliedAgentEnvVars"
 	ClusterStatusFieldAppliedClusterAgentDeploymentCustomization = "appliedClusterAgentDeploymentCustomization"
+	ClusterStatusFieldAppliedClusterAgentProxyConfig             = "appliedClusterAgentProxyConfig"
 	ClusterStatusFieldAppliedEnableNetworkPolicy                 = "appliedEnableNetworkPolicy"
 	ClusterStatusFieldAppliedPodSecurityPolicyTemplateName       = "appliedPodSecurityPolicyTemplateId"
 	ClusterStatusFieldAppliedSpec                                = "appliedSpec"
@@ -21,6 +22,7 @@
 	ClusterStatusFieldCertificatesExpiration                     = "certificatesExpiration"
 	ClusterStatusFieldComponentStatuses                          = "componentStatuses"
 	ClusterStatusFieldConditions                                 = "conditions"
+	ClusterStatusFieldConnectionHistory                          = "connectionHistory"
 	ClusterStatusFieldCurrentCisRunName                          = "currentCisRunName"
 	ClusterStatusFieldDriver                                     = "driver"
 	ClusterStatusFieldEKSStatus                                  = "eksStatus"
@@ -54,6 +56,7 @@ type ClusterStatus struct {
 	Allocatable                                map[string]string             `json:"allocatable,omitempty" yaml:"allocatable,omitempty"`
 	AppliedAgentEnvVars                        []EnvVar                      `json:"appliedAgentEnvVars,omitempty" yaml:"appliedAgentEnvVars,omitempty"`
 	AppliedClusterAgentDeploymentCustomization *AgentDeploymentCustomization `json:"appliedClusterAgentDeploymentCustomization,omitempty" yaml:"appliedClusterAgentDeploymentCustomization,omitempty"`
+	AppliedClusterAgentProxyConfig             *AgentProxyConfig             `json:"appliedClusterAgentProxyConfig,omitempty" yaml:"appliedClusterAgentProxyConfig,omitempty"`
 	AppliedEnableNetworkPolicy                 bool                          `json:"appliedEnableNetworkPolicy,omitempty" yaml:"appliedEnableNetworkPolicy,omitempty"`
 	AppliedPodSecurityPolicyTemplateName       string                        `json:"appliedPodSecurityPolicyTemplateId,omitempty" yaml:"appliedPodSecurityPolicyTemplateId,omitempty"`
 	AppliedSpec                                *ClusterSpec                  `json:"appliedSpec,omitempty" yaml:"appliedSpec,omitempty"`
@@ -64,6 +67,7 @@ type ClusterStatus struct {
 	CertificatesExpiration                     map[string]CertExpiration     `json:"certificatesExpiration,omitempty" yaml:"certificatesExpiration,omitempty"`
 	ComponentStatuses                          []ClusterComponentStatus      `json:"componentStatuses,omitempty" yaml:"componentStatuses,omitempty"`
 	Conditions                                 []ClusterCondition            `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	ConnectionHistory                          []ClusterConnectionEvent      `json:"connectionHistory,omitempty" yaml:"connectionHistory,omitempty"`
 	CurrentCisRunName                          string                        `json:"currentCisRunName,omitempty" yaml:"currentCisRunName,omitempty"`
 	Driver                                     string                        `json:"driver,omitempty" yaml:"driver,omitempty"`
 	EKSStatus                                  *EKSStatus                    `json:"eksStatus,omitempty" yaml:"eksStatus,omitempty"`
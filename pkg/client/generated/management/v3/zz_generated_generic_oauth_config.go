@@ -0,0 +1,72 @@
+package client
+
+const (
+	GenericOAuthConfigType                       = "genericOAuthConfig"
+	GenericOAuthConfigFieldAccessMode            = "accessMode"
+	GenericOAuthConfigFieldAllowedPrincipalIDs   = "allowedPrincipalIds"
+	GenericOAuthConfigFieldAnnotations           = "annotations"
+	GenericOAuthConfigFieldAuthEndpoint          = "authEndpoint"
+	GenericOAuthConfigFieldCertificate           = "certificate"
+	GenericOAuthConfigFieldClientID              = "clientId"
+	GenericOAuthConfigFieldClientSecret          = "clientSecret"
+	GenericOAuthConfigFieldCreated               = "created"
+	GenericOAuthConfigFieldCreatorID             = "creatorId"
+	GenericOAuthConfigFieldDisplayNameField      = "displayNameField"
+	GenericOAuthConfigFieldEnabled               = "enabled"
+	GenericOAuthConfigFieldGroupDisplayNameField = "groupDisplayNameField"
+	GenericOAuthConfigFieldGroupIDField          = "groupIdField"
+	GenericOAuthConfigFieldGroupSearchEndpoint   = "groupSearchEndpoint"
+	GenericOAuthConfigFieldGroupsEndpoint        = "groupsEndpoint"
+	GenericOAuthConfigFieldGroupsField           = "groupsField"
+	GenericOAuthConfigFieldLabels                = "labels"
+	GenericOAuthConfigFieldName                  = "name"
+	GenericOAuthConfigFieldOwnerReferences       = "ownerReferences"
+	GenericOAuthConfigFieldProfilePictureField   = "profilePictureField"
+	GenericOAuthConfigFieldRancherURL            = "rancherUrl"
+	GenericOAuthConfigFieldRemoved               = "removed"
+	GenericOAuthConfigFieldResultsField          = "resultsField"
+	GenericOAuthConfigFieldScopes                = "scope"
+	GenericOAuthConfigFieldStatus                = "status"
+	GenericOAuthConfigFieldTokenEndpoint         = "tokenEndpoint"
+	GenericOAuthConfigFieldType                  = "type"
+	GenericOAuthConfigFieldUUID                  = "uuid"
+	GenericOAuthConfigFieldUserIDField           = "userIdField"
+	GenericOAuthConfigFieldUserInfoEndpoint      = "userInfoEndpoint"
+	GenericOAuthConfigFieldUserNameField         = "userNameField"
+	GenericOAuthConfigFieldUserSearchEndpoint    = "userSearchEndpoint"
+)
+
+type GenericOAuthConfig struct {
+	AccessMode            string            `json:"accessMode,omitempty" yaml:"accessMode,omitempty"`
+	AllowedPrincipalIDs   []string          `json:"allowedPrincipalIds,omitempty" yaml:"allowedPrincipalIds,omitempty"`
+	Annotations           map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	AuthEndpoint          string            `json:"authEndpoint,omitempty" yaml:"authEndpoint,omitempty"`
+	Certificate           string            `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	ClientID              string            `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	ClientSecret          string            `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
+	Created               string            `json:"created,omitempty" yaml:"created,omitempty"`
+	CreatorID             string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	DisplayNameField      string            `json:"displayNameField,omitempty" yaml:"displayNameField,omitempty"`
+	Enabled               bool              `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	GroupDisplayNameField string            `json:"groupDisplayNameField,omitempty" yaml:"groupDisplayNameField,omitempty"`
+	GroupIDField          string            `json:"groupIdField,omitempty" yaml:"groupIdField,omitempty"`
+	GroupSearchEndpoint   string            `json:"groupSearchEndpoint,omitempty" yaml:"groupSearchEndpoint,omitempty"`
+	GroupsEndpoint        string            `json:"groupsEndpoint,omitempty" yaml:"groupsEndpoint,omitempty"`
+	GroupsField           string            `json:"groupsField,omitempty" yaml:"groupsField,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Name                  string            `json:"name,omitempty" yaml:"name,omitempty"`
+	OwnerReferences       []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+	ProfilePictureField   string            `json:"profilePictureField,omitempty" yaml:"profilePictureField,omitempty"`
+	RancherURL            string            `json:"rancherUrl,omitempty" yaml:"rancherUrl,omitempty"`
+	Removed               string            `json:"removed,omitempty" yaml:"removed,omitempty"`
+	ResultsField          string            `json:"resultsField,omitempty" yaml:"resultsField,omitempty"`
+	Scopes                string            `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Status                *AuthConfigStatus `json:"status,omitempty" yaml:"status,omitempty"`
+	TokenEndpoint         string            `json:"tokenEndpoint,omitempty" yaml:"tokenEndpoint,omitempty"`
+	Type                  string            `json:"type,omitempty" yaml:"type,omitempty"`
+	UUID                  string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	UserIDField           string            `json:"userIdField,omitempty" yaml:"userIdField,omitempty"`
+	UserInfoEndpoint      string            `json:"userInfoEndpoint,omitempty" yaml:"userInfoEndpoint,omitempty"`
+	UserNameField         string            `json:"userNameField,omitempty" yaml:"userNameField,omitempty"`
+	UserSearchEndpoint    string            `json:"userSearchEndpoint,omitempty" yaml:"userSearchEndpoint,omitempty"`
+}
@@ -0,0 +1,14 @@
+package client
+
+const (
+	GenericOAuthConfigApplyInputType                    = "genericOAuthConfigApplyInput"
+	GenericOAuthConfigApplyInputFieldCode               = "code"
+	GenericOAuthConfigApplyInputFieldEnabled            = "enabled"
+	GenericOAuthConfigApplyInputFieldGenericOAuthConfig = "genericOAuthConfig"
+)
+
+type GenericOAuthConfigApplyInput struct {
+	Code               string              `json:"code,omitempty" yaml:"code,omitempty"`
+	Enabled            bool                `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	GenericOAuthConfig *GenericOAuthConfig `json:"genericOAuthConfig,omitempty" yaml:"genericOAuthConfig,omitempty"`
+}
@@ -0,0 +1,10 @@
+package client
+
+const (
+	GenericOAuthConfigTestOutputType             = "genericOAuthConfigTestOutput"
+	GenericOAuthConfigTestOutputFieldRedirectURL = "redirectUrl"
+)
+
+type GenericOAuthConfigTestOutput struct {
+	RedirectURL string `json:"redirectUrl,omitempty" yaml:"redirectUrl,omitempty"`
+}
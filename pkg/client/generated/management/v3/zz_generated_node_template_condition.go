@@ -4,6 +4,7 @@
 	NodeTemplateConditionType                    = "nodeTemplateCondition"
 	NodeTemplateConditionFieldLastTransitionTime = "lastTransitionTime"
 	NodeTemplateConditionFieldLastUpdateTime     = "lastUpdateTime"
+	NodeTemplateConditionFieldMessage            = "message"
 	NodeTemplateConditionFieldReason             = "reason"
 	NodeTemplateConditionFieldStatus             = "status"
 	NodeTemplateConditionFieldType               = "type"
@@ -12,6 +13,7 @@
 type NodeTemplateCondition struct {
 	LastTransitionTime string `json:"lastTransitionTime,omitempty" yaml:"lastTransitionTime,omitempty"`
 	LastUpdateTime     string `json:"lastUpdateTime,omitempty" yaml:"lastUpdateTime,omitempty"`
+	Message            string `json:"message,omitempty" yaml:"message,omitempty"`
 	Reason             string `json:"reason,omitempty" yaml:"reason,omitempty"`
 	Status             string `json:"status,omitempty" yaml:"status,omitempty"`
 	Type               string `json:"type,omitempty" yaml:"type,omitempty"`
@@ -9,6 +9,7 @@
 	ProjectRoleTemplateBindingFieldAnnotations      = "annotations"
 	ProjectRoleTemplateBindingFieldCreated          = "created"
 	ProjectRoleTemplateBindingFieldCreatorID        = "creatorId"
+	ProjectRoleTemplateBindingFieldExpiresAt        = "expiresAt"
 	ProjectRoleTemplateBindingFieldGroupID          = "groupId"
 	ProjectRoleTemplateBindingFieldGroupPrincipalID = "groupPrincipalId"
 	ProjectRoleTemplateBindingFieldLabels           = "labels"
@@ -19,6 +20,8 @@
 	ProjectRoleTemplateBindingFieldRemoved          = "removed"
 	ProjectRoleTemplateBindingFieldRoleTemplateID   = "roleTemplateId"
 	ProjectRoleTemplateBindingFieldServiceAccount   = "serviceAccount"
+	ProjectRoleTemplateBindingFieldStatus           = "status"
+	ProjectRoleTemplateBindingFieldTTL              = "ttl"
 	ProjectRoleTemplateBindingFieldUUID             = "uuid"
 	ProjectRoleTemplateBindingFieldUserID           = "userId"
 	ProjectRoleTemplateBindingFieldUserPrincipalID  = "userPrincipalId"
@@ -26,22 +29,25 @@
 
 type ProjectRoleTemplateBinding struct {
 	types.Resource
-	Annotations      map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
-	Created          string            `json:"created,omitempty" yaml:"created,omitempty"`
-	CreatorID        string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
-	GroupID          string            `json:"groupId,omitempty" yaml:"groupId,omitempty"`
-	GroupPrincipalID string            `json:"groupPrincipalId,omitempty" yaml:"groupPrincipalId,omitempty"`
-	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
-	Name             string            `json:"name,omitempty" yaml:"name,omitempty"`
-	NamespaceId      string            `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
-	OwnerReferences  []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
-	ProjectID        string            `json:"projectId,omitempty" yaml:"projectId,omitempty"`
-	Removed          string            `json:"removed,omitempty" yaml:"removed,omitempty"`
-	RoleTemplateID   string            `json:"roleTemplateId,omitempty" yaml:"roleTemplateId,omitempty"`
-	ServiceAccount   string            `json:"serviceAccount,omitempty" yaml:"serviceAccount,omitempty"`
-	UUID             string            `json:"uuid,omitempty" yaml:"uuid,omitempty"`
-	UserID           string            `json:"userId,omitempty" yaml:"userId,omitempty"`
-	UserPrincipalID  string            `json:"userPrincipalId,omitempty" yaml:"userPrincipalId,omitempty"`
+	Annotations      map[string]string  `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Created          string             `json:"created,omitempty" yaml:"created,omitempty"`
+	CreatorID        string             `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
+	ExpiresAt        string             `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	GroupID          string             `json:"groupId,omitempty" yaml:"groupId,omitempty"`
+	GroupPrincipalID string             `json:"groupPrincipalId,omitempty" yaml:"groupPrincipalId,omitempty"`
+	Labels           map[string]string  `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Name             string             `json:"name,omitempty" yaml:"name,omitempty"`
+	NamespaceId      string             `json:"namespaceId,omitempty" yaml:"namespaceId,omitempty"`
+	OwnerReferences  []OwnerReference   `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+	ProjectID        string             `json:"projectId,omitempty" yaml:"projectId,omitempty"`
+	Removed          string             `json:"removed,omitempty" yaml:"removed,omitempty"`
+	RoleTemplateID   string             `json:"roleTemplateId,omitempty" yaml:"roleTemplateId,omitempty"`
+	ServiceAccount   string             `json:"serviceAccount,omitempty" yaml:"serviceAccount,omitempty"`
+	Status           *RoleBindingStatus `json:"status,omitempty" yaml:"status,omitempty"`
+	TTL              string             `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	UUID             string             `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	UserID           string             `json:"userId,omitempty" yaml:"userId,omitempty"`
+	UserPrincipalID  string             `json:"userPrincipalId,omitempty" yaml:"userPrincipalId,omitempty"`
 }
 
 type ProjectRoleTemplateBindingCollection struct {
@@ -0,0 +1,10 @@
+package client
+
+const (
+	RecoveryCodesOutputType               = "recoveryCodesOutput"
+	RecoveryCodesOutputFieldRecoveryCodes = "recoveryCodes"
+)
+
+type RecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recoveryCodes,omitempty" yaml:"recoveryCodes,omitempty"`
+}
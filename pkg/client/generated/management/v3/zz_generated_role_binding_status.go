@@ -0,0 +1,10 @@
+package client
+
+const (
+	RoleBindingStatusType           = "roleBindingStatus"
+	RoleBindingStatusFieldExpiresAt = "expiresAt"
+)
+
+type RoleBindingStatus struct {
+	ExpiresAt string `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+}
@@ -0,0 +1,14 @@
+package client
+
+const (
+	TokenScopeType           = "tokenScope"
+	TokenScopeFieldAPIGroups = "apiGroups"
+	TokenScopeFieldResources = "resources"
+	TokenScopeFieldVerbs     = "verbs"
+)
+
+type TokenScope struct {
+	APIGroups []string `json:"apiGroups,omitempty" yaml:"apiGroups,omitempty"`
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	Verbs     []string `json:"verbs,omitempty" yaml:"verbs,omitempty"`
+}
@@ -0,0 +1,10 @@
+package client
+
+const (
+	TOTPCodeInputType      = "totpCodeInput"
+	TOTPCodeInputFieldCode = "code"
+)
+
+type TOTPCodeInput struct {
+	Code string `json:"code,omitempty" yaml:"code,omitempty"`
+}
@@ -0,0 +1,12 @@
+package client
+
+const (
+	TOTPEnrollmentType        = "totpEnrollment"
+	TOTPEnrollmentFieldSecret = "secret"
+	TOTPEnrollmentFieldURI    = "uri"
+)
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	URI    string `json:"uri,omitempty" yaml:"uri,omitempty"`
+}
@@ -12,7 +12,10 @@
 	UserFieldCreatorID            = "creatorId"
 	UserFieldDescription          = "description"
 	UserFieldEnabled              = "enabled"
+	UserFieldFailedLoginAttempts  = "failedLoginAttempts"
 	UserFieldLabels               = "labels"
+	UserFieldLastFailedLoginTime  = "lastFailedLoginTime"
+	UserFieldLockedUntil          = "lockedUntil"
 	UserFieldMe                   = "me"
 	UserFieldMustChangePassword   = "mustChangePassword"
 	UserFieldName                 = "name"
@@ -35,7 +38,10 @@ type User struct {
 	CreatorID            string            `json:"creatorId,omitempty" yaml:"creatorId,omitempty"`
 	Description          string            `json:"description,omitempty" yaml:"description,omitempty"`
 	Enabled              *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	FailedLoginAttempts  int64             `json:"failedLoginAttempts,omitempty" yaml:"failedLoginAttempts,omitempty"`
 	Labels               map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	LastFailedLoginTime  string            `json:"lastFailedLoginTime,omitempty" yaml:"lastFailedLoginTime,omitempty"`
+	LockedUntil          string            `json:"lockedUntil,omitempty" yaml:"lockedUntil,omitempty"`
 	Me                   bool              `json:"me,omitempty" yaml:"me,omitempty"`
 	MustChangePassword   bool              `json:"mustChangePassword,omitempty" yaml:"mustChangePassword,omitempty"`
 	Name                 string            `json:"name,omitempty" yaml:"name,omitempty"`
@@ -71,11 +77,23 @@ type UserOperations interface {
 
 	ActionRefreshauthprovideraccess(resource *User) error
 
+	ActionResettotp(resource *User) error
+
 	ActionSetpassword(resource *User, input *SetPasswordInput) (*User, error)
 
+	ActionUnlock(resource *User) error
+
 	CollectionActionChangepassword(resource *UserCollection, input *ChangePasswordInput) error
 
+	CollectionActionConfirmtotp(resource *UserCollection, input *TOTPCodeInput) (*RecoveryCodesOutput, error)
+
+	CollectionActionDisabletotp(resource *UserCollection, input *TOTPCodeInput) error
+
+	CollectionActionEnrolltotp(resource *UserCollection) (*TOTPEnrollment, error)
+
 	CollectionActionRefreshauthprovideraccess(resource *UserCollection) error
+
+	CollectionActionRegeneraterecoverycodes(resource *UserCollection, input *TOTPCodeInput) (*RecoveryCodesOutput, error)
 }
 
 func newUserClient(apiClient *Client) *UserClient {
@@ -152,18 +170,51 @@ func (c *UserClient) ActionRefreshauthprovideraccess(resource *User) error {
 	return err
 }
 
+func (c *UserClient) ActionResettotp(resource *User) error {
+	err := c.apiClient.Ops.DoAction(UserType, "resettotp", &resource.Resource, nil, nil)
+	return err
+}
+
 func (c *UserClient) ActionSetpassword(resource *User, input *SetPasswordInput) (*User, error) {
 	resp := &User{}
 	err := c.apiClient.Ops.DoAction(UserType, "setpassword", &resource.Resource, input, resp)
 	return resp, err
 }
 
+func (c *UserClient) ActionUnlock(resource *User) error {
+	err := c.apiClient.Ops.DoAction(UserType, "unlock", &resource.Resource, nil, nil)
+	return err
+}
+
 func (c *UserClient) CollectionActionChangepassword(resource *UserCollection, input *ChangePasswordInput) error {
 	err := c.apiClient.Ops.DoCollectionAction(UserType, "changepassword", &resource.Collection, input, nil)
 	return err
 }
 
+func (c *UserClient) CollectionActionConfirmtotp(resource *UserCollection, input *TOTPCodeInput) (*RecoveryCodesOutput, error) {
+	resp := &RecoveryCodesOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "confirmtotp", &resource.Collection, input, resp)
+	return resp, err
+}
+
+func (c *UserClient) CollectionActionDisabletotp(resource *UserCollection, input *TOTPCodeInput) error {
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "disabletotp", &resource.Collection, input, nil)
+	return err
+}
+
+func (c *UserClient) CollectionActionEnrolltotp(resource *UserCollection) (*TOTPEnrollment, error) {
+	resp := &TOTPEnrollment{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "enrolltotp", &resource.Collection, nil, resp)
+	return resp, err
+}
+
 func (c *UserClient) CollectionActionRefreshauthprovideraccess(resource *UserCollection) error {
 	err := c.apiClient.Ops.DoCollectionAction(UserType, "refreshauthprovideraccess", &resource.Collection, nil, nil)
 	return err
 }
+
+func (c *UserClient) CollectionActionRegeneraterecoverycodes(resource *UserCollection, input *TOTPCodeInput) (*RecoveryCodesOutput, error) {
+	resp := &RecoveryCodesOutput{}
+	err := c.apiClient.Ops.DoCollectionAction(UserType, "regeneraterecoverycodes", &resource.Collection, input, resp)
+	return resp, err
+}
@@ -0,0 +1,16 @@
+package client
+
+const (
+	GenericOAuthLoginType              = "genericOAuthLogin"
+	GenericOAuthLoginFieldCode         = "code"
+	GenericOAuthLoginFieldDescription  = "description"
+	GenericOAuthLoginFieldResponseType = "responseType"
+	GenericOAuthLoginFieldTTLMillis    = "ttl"
+)
+
+type GenericOAuthLogin struct {
+	Code         string `json:"code,omitempty" yaml:"code,omitempty"`
+	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
+	ResponseType string `json:"responseType,omitempty" yaml:"responseType,omitempty"`
+	TTLMillis    int64  `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
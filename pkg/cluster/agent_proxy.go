@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/agent/tunnelproxy"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
+	"github.com/rancher/rancher/pkg/ref"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GetClusterAgentProxyData returns the content of the proxy Secret mounted into the cluster agent, keyed by the file
+// names the agent reads. If no proxy is configured, nil is returned.
+func GetClusterAgentProxyData(cluster *v3.Cluster, secretLister v1.SecretLister) (map[string]string, error) {
+	if cluster == nil || cluster.Spec.ClusterAgentProxyConfig == nil || cluster.Spec.ClusterAgentProxyConfig.URL == "" {
+		return nil, nil
+	}
+	proxyConfig := cluster.Spec.ClusterAgentProxyConfig
+
+	var username, password string
+	if proxyConfig.CredentialSecret != "" {
+		namespace, name, err := ParseAgentProxyCredentialSecret(cluster)
+		if err != nil {
+			return nil, err
+		}
+		secret, err := secretLister.Get(namespace, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cluster agent proxy credential secret [%s]: %w", proxyConfig.CredentialSecret, err)
+		}
+		username = string(secret.Data[corev1.BasicAuthUsernameKey])
+		password = string(secret.Data[corev1.BasicAuthPasswordKey])
+		if username == "" {
+			return nil, fmt.Errorf("cluster agent proxy credential secret [%s] has no %s", proxyConfig.CredentialSecret, corev1.BasicAuthUsernameKey)
+		}
+	}
+
+	// validate here so that a bad configuration is reported on the cluster instead of crash looping the agent
+	if _, err := tunnelproxy.New(proxyConfig.URL, username, password, []byte(proxyConfig.CACerts), proxyConfig.NoProxy); err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		tunnelproxy.URLKey: proxyConfig.URL,
+	}
+	if username != "" {
+		data[tunnelproxy.UsernameKey] = username
+		data[tunnelproxy.PasswordKey] = password
+	}
+	if proxyConfig.CACerts != "" {
+		data[tunnelproxy.CACertsKey] = proxyConfig.CACerts
+	}
+	if len(proxyConfig.NoProxy) > 0 {
+		data[tunnelproxy.NoProxyKey] = strings.Join(proxyConfig.NoProxy, ",")
+	}
+	return data, nil
+}
+
+// ParseAgentProxyCredentialSecret returns the namespace and name of the cluster agent proxy credential secret. The
+// secret is served to the agent through the import manifest, so it may only live in the cluster's own namespace or in
+// its fleet workspace; anything else would let a cluster editor read arbitrary basic-auth secrets.
+func ParseAgentProxyCredentialSecret(cluster *v3.Cluster) (string, string, error) {
+	credentialSecret := cluster.Spec.ClusterAgentProxyConfig.CredentialSecret
+	namespace, name := ref.Parse(credentialSecret)
+	if namespace == "" || name == "" {
+		return "", "", fmt.Errorf("cluster agent proxy credential secret [%s] must be in the form <namespace>:<name>", credentialSecret)
+	}
+	if namespace != cluster.Name && namespace != cluster.Spec.FleetWorkspaceName {
+		return "", "", fmt.Errorf("cluster agent proxy credential secret [%s] must be in the namespace of the cluster or its fleet workspace", credentialSecret)
+	}
+	return namespace, name, nil
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	corefakes "github.com/rancher/rancher/pkg/generated/norman/core/v1/fakes"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetClusterAgentProxyData(t *testing.T) {
+	secretLister := &corefakes.SecretListerMock{
+		GetFunc: func(namespace string, name string) (*corev1.Secret, error) {
+			if name != "proxy-credentials" {
+				return nil, apierror.NewNotFound(schema.GroupResource{}, namespace+":"+name)
+			}
+			return &corev1.Secret{
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("agent"),
+					corev1.BasicAuthPasswordKey: []byte("s3cret"),
+				},
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name             string
+		credentialSecret string
+		expectedUsername string
+		expectedError    string
+	}{
+		{
+			name:             "secret in the cluster namespace",
+			credentialSecret: "c-abcde:proxy-credentials",
+			expectedUsername: "agent",
+		},
+		{
+			name:             "secret in the fleet workspace",
+			credentialSecret: "fleet-default:proxy-credentials",
+			expectedUsername: "agent",
+		},
+		{
+			name:             "secret in another namespace",
+			credentialSecret: "cattle-global-data:proxy-credentials",
+			expectedError:    "cluster agent proxy credential secret [cattle-global-data:proxy-credentials] must be in the namespace of the cluster or its fleet workspace",
+		},
+		{
+			name:             "secret without namespace",
+			credentialSecret: "proxy-credentials",
+			expectedError:    "cluster agent proxy credential secret [proxy-credentials] must be in the form <namespace>:<name>",
+		},
+		{
+			name:             "missing secret",
+			credentialSecret: "c-abcde:missing",
+			expectedError:    "failed to get cluster agent proxy credential secret [c-abcde:missing]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"},
+				Spec: v3.ClusterSpec{
+					FleetWorkspaceName: "fleet-default",
+					ClusterSpecBase: v3.ClusterSpecBase{
+						ClusterAgentProxyConfig: &v3.AgentProxyConfig{
+							URL:              "http://proxy.example.com:3128",
+							CredentialSecret: tt.credentialSecret,
+						},
+					},
+				},
+			}
+
+			data, err := GetClusterAgentProxyData(cluster, secretLister)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedUsername, data["username"])
+		})
+	}
+}
@@ -23,6 +23,7 @@
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/kontainer-engine/drivers/gke"
+	"github.com/rancher/rancher/pkg/peermanager"
 	"github.com/rancher/rancher/pkg/rbac"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
@@ -52,6 +53,8 @@ type Manager struct {
 	rbac          rbacv1.Interface
 	dialer        dialer.Factory
 	startSem      *semaphore.Weighted
+	// Owners decides which clusters this replica runs the owner controllers of.
+	Owners *peermanager.Owners
 }
 
 type record struct {
@@ -74,6 +77,7 @@ func NewManager(httpsPort int, context *config.ScaledContext, asl accesscontrol.
 		clusters:      context.Management.Clusters(""),
 		secretLister:  context.Core.Secrets("").Controller().Lister(),
 		startSem:      semaphore.NewWeighted(int64(settings.ClusterControllerStartCount.GetInt())),
+		Owners:        peermanager.NewOwners(),
 	}
 }
 
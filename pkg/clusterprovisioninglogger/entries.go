@@ -0,0 +1,214 @@
+package clusterprovisioninglogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap of the cluster namespace that the provisioning log is stored in.
+	ConfigMapName = configMapName
+
+	// EntriesKey is the key of the provisioning log ConfigMap that holds the entries of the log, one JSON entry per
+	// line.
+	EntriesKey = "entries"
+	// legacyLogKey is the key of the provisioning log ConfigMap that held the log as text before entries were stored.
+	legacyLogKey = "log"
+
+	// MaxEntries is the number of entries kept per cluster, older entries are dropped.
+	MaxEntries = 500
+	// maxMessageLength is the length that messages are truncated to, so that MaxEntries fit in a ConfigMap.
+	maxMessageLength = 1024
+)
+
+// Level is the severity of a provisioning log entry.
+type Level string
+
+const (
+	LevelInfo    Level = "info"
+	LevelWarning Level = "warning"
+	LevelError   Level = "error"
+)
+
+var levelSeverity = map[Level]int{
+	LevelInfo:    0,
+	LevelWarning: 1,
+	LevelError:   2,
+}
+
+// ParseLevel returns the level of the name, case insensitive.
+func ParseLevel(name string) (Level, error) {
+	level := Level(strings.ToLower(strings.TrimSpace(name)))
+	if level == "warn" {
+		level = LevelWarning
+	}
+	if _, ok := levelSeverity[level]; !ok {
+		return "", fmt.Errorf("invalid level %q, must be one of info, warning or error", name)
+	}
+	return level, nil
+}
+
+// AtLeast returns whether the level is as severe as the other level or more.
+func (l Level) AtLeast(other Level) bool {
+	return levelSeverity[l] >= levelSeverity[other]
+}
+
+// Entry is an entry of the provisioning log of a cluster.
+type Entry struct {
+	// Cursor increases with every entry of the cluster, entries after a cursor are the entries with a greater cursor.
+	Cursor  int64     `json:"cursor"`
+	Time    time.Time `json:"time"`
+	Level   Level     `json:"level"`
+	Machine string    `json:"machine,omitempty"`
+	Message string    `json:"message"`
+}
+
+// String formats the entry like the lines of the provisioning log were formatted before entries were stored.
+func (e Entry) String() string {
+	prefix := "[INFO ]"
+	switch e.Level {
+	case LevelWarning:
+		prefix = "[WARN ]"
+	case LevelError:
+		prefix = "[ERROR]"
+	}
+	if e.Machine != "" {
+		prefix += " [" + e.Machine + "]"
+	}
+	return e.Time.Format(time.RFC3339) + " " + prefix + " " + e.Message
+}
+
+// Filter selects the entries of a provisioning log.
+type Filter struct {
+	// After selects the entries after the cursor.
+	After int64
+	// Machine selects the entries of the machine, if set.
+	Machine string
+	// Level selects the entries that are at least as severe as the level, if set.
+	Level Level
+}
+
+func (f Filter) Matches(e Entry) bool {
+	if e.Cursor <= f.After {
+		return false
+	}
+	if f.Machine != "" && e.Machine != f.Machine {
+		return false
+	}
+	return f.Level == "" || e.Level.AtLeast(f.Level)
+}
+
+// Entries returns the entries of the provisioning log ConfigMap, ordered by cursor. The text log of ConfigMaps that
+// were written before entries were stored is converted to entries.
+func Entries(cm *corev1.ConfigMap) []Entry {
+	if cm == nil {
+		return nil
+	}
+	if data, ok := cm.Data[EntriesKey]; ok {
+		return parseEntries(data)
+	}
+	return parseLegacyLog(cm.Data[legacyLogKey])
+}
+
+// Append appends the entries to the provisioning log ConfigMap, assigning their cursors, and drops the oldest entries
+// of the log once it holds more than MaxEntries entries. Entries without time are recorded at the current time.
+func Append(cm *corev1.ConfigMap, entries ...Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	all := Entries(cm)
+	var cursor int64
+	if len(all) > 0 {
+		cursor = all[len(all)-1].Cursor
+	}
+	for _, e := range entries {
+		cursor++
+		e.Cursor = cursor
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		e.Time = e.Time.UTC().Truncate(time.Second)
+		if e.Level == "" {
+			e.Level = LevelInfo
+		}
+		if len(e.Message) > maxMessageLength {
+			e.Message = e.Message[:maxMessageLength] + "..."
+		}
+		all = append(all, e)
+	}
+	if len(all) > MaxEntries {
+		all = all[len(all)-MaxEntries:]
+	}
+
+	var data strings.Builder
+	for _, e := range all {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		data.Write(line)
+		data.WriteByte('\n')
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[EntriesKey] = data.String()
+	delete(cm.Data, legacyLogKey)
+}
+
+func parseEntries(data string) []Entry {
+	var entries []Entry
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// parseLegacyLog converts the lines of a text log, formatted as "<RFC3339 time> [INFO ] <message>", to entries.
+func parseLegacyLog(log string) []Entry {
+	var entries []Entry
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		e := Entry{Level: LevelInfo, Message: line}
+		if timestamp, rest, ok := strings.Cut(line, " "); ok {
+			if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+				e.Time = t
+				rest = strings.TrimSpace(rest)
+				switch {
+				case strings.HasPrefix(rest, "[INFO ]"):
+					rest = strings.TrimPrefix(rest, "[INFO ]")
+				case strings.HasPrefix(rest, "[ERROR]"):
+					e.Level = LevelError
+					rest = strings.TrimPrefix(rest, "[ERROR]")
+				}
+				e.Message = strings.TrimSpace(rest)
+			}
+		}
+		e.Cursor = int64(len(entries) + 1)
+		entries = append(entries, e)
+	}
+	if len(entries) > MaxEntries {
+		entries = entries[len(entries)-MaxEntries:]
+	}
+	return entries
+}
@@ -0,0 +1,113 @@
+package clusterprovisioninglogger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestAppend(t *testing.T) {
+	cm := &corev1.ConfigMap{}
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	Append(cm, Entry{Time: now, Message: "waiting for infrastructure"})
+	Append(cm,
+		Entry{Time: now.Add(time.Minute), Level: LevelError, Machine: "pool1-abc", Message: "failed to create machine"},
+		Entry{Time: now.Add(2 * time.Minute), Level: LevelWarning, Message: strings.Repeat("x", 2000)},
+	)
+
+	entries := Entries(cm)
+	require.Len(t, entries, 3)
+	assert.Equal(t, Entry{Cursor: 1, Time: now, Level: LevelInfo, Message: "waiting for infrastructure"}, entries[0])
+	assert.Equal(t, Entry{Cursor: 2, Time: now.Add(time.Minute), Level: LevelError, Machine: "pool1-abc", Message: "failed to create machine"}, entries[1])
+	assert.Equal(t, int64(3), entries[2].Cursor)
+	assert.Len(t, entries[2].Message, maxMessageLength+len("..."))
+
+	assert.Equal(t, "2024-03-01T10:01:00Z [ERROR] [pool1-abc] failed to create machine", entries[1].String())
+}
+
+func TestAppendDropsOldestEntries(t *testing.T) {
+	cm := &corev1.ConfigMap{}
+	for i := 1; i <= MaxEntries+10; i++ {
+		Append(cm, Entry{Message: fmt.Sprintf("message %d", i)})
+	}
+
+	entries := Entries(cm)
+	require.Len(t, entries, MaxEntries)
+	assert.Equal(t, int64(11), entries[0].Cursor)
+	assert.Equal(t, "message 11", entries[0].Message)
+	assert.Equal(t, int64(MaxEntries+10), entries[len(entries)-1].Cursor)
+}
+
+func TestLegacyLog(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			"log": "2024-03-01T10:00:00Z [INFO ] provisioning bootstrap node(s)\n\n" +
+				"2024-03-01T10:05:00Z [ERROR] failed to bootstrap etcd\n" +
+				"unformatted line\n",
+			"last": "failed to bootstrap etcd",
+		},
+	}
+
+	entries := Entries(cm)
+	require.Len(t, entries, 3)
+	assert.Equal(t, Entry{Cursor: 1, Time: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), Level: LevelInfo, Message: "provisioning bootstrap node(s)"}, entries[0])
+	assert.Equal(t, LevelError, entries[1].Level)
+	assert.Equal(t, "failed to bootstrap etcd", entries[1].Message)
+	assert.Equal(t, Entry{Cursor: 3, Level: LevelInfo, Message: "unformatted line"}, entries[2])
+
+	// the legacy log is converted on the first append
+	Append(cm, Entry{Message: "provisioning done"})
+	assert.NotContains(t, cm.Data, "log")
+	assert.Equal(t, "failed to bootstrap etcd", cm.Data["last"])
+	entries = Entries(cm)
+	require.Len(t, entries, 4)
+	assert.Equal(t, int64(4), entries[3].Cursor)
+	assert.Equal(t, "provisioning bootstrap node(s)", entries[0].Message)
+}
+
+func TestFilter(t *testing.T) {
+	entries := []Entry{
+		{Cursor: 1, Level: LevelInfo, Message: "cluster"},
+		{Cursor: 2, Level: LevelWarning, Machine: "m1", Message: "m1 warning"},
+		{Cursor: 3, Level: LevelError, Machine: "m2", Message: "m2 error"},
+		{Cursor: 4, Level: LevelInfo, Machine: "m1", Message: "m1 info"},
+	}
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []int64
+	}{
+		{name: "all", expected: []int64{1, 2, 3, 4}},
+		{name: "after cursor", filter: Filter{After: 2}, expected: []int64{3, 4}},
+		{name: "machine", filter: Filter{Machine: "m1"}, expected: []int64{2, 4}},
+		{name: "level", filter: Filter{Level: LevelWarning}, expected: []int64{2, 3}},
+		{name: "all filters", filter: Filter{After: 1, Machine: "m1", Level: LevelWarning}, expected: []int64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cursors []int64
+			for _, e := range entries {
+				if tt.filter.Matches(e) {
+					cursors = append(cursors, e.Cursor)
+				}
+			}
+			assert.Equal(t, tt.expected, cursors)
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]Level{"info": LevelInfo, "WARN": LevelWarning, "warning": LevelWarning, " Error ": LevelError} {
+		level, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, level)
+	}
+	_, err := ParseLevel("debug")
+	assert.Error(t, err)
+}
@@ -1,7 +1,6 @@
 package clusterprovisioninglogger
 
 import (
-	"bytes"
 	"context"
 	"io"
 	"sync"
@@ -27,7 +26,8 @@ type logger struct {
 	Clusters   v3.ClusterInterface
 	ConfigMaps v1.ConfigMapInterface
 	done       chan struct{}
-	buffer     bytes.Buffer
+	// pending are the entries that were not saved yet
+	pending    []Entry
 	bufferLock sync.Mutex
 }
 
@@ -48,34 +48,33 @@ func (p *logger) saveMessage() {
 	p.bufferLock.Lock()
 	defer p.bufferLock.Unlock()
 
-	log := p.buffer.String()
-	if log == "" {
+	if len(p.pending) == 0 {
 		return
 	}
 	cm, err := p.ConfigMaps.GetNamespaced(p.Cluster.Name, configMapName, v12.GetOptions{})
 	if apierrors.IsNotFound(err) {
-		_, err := p.ConfigMaps.Create(&corev1.ConfigMap{
+		cm = &corev1.ConfigMap{
 			ObjectMeta: v12.ObjectMeta{
 				Name:      configMapName,
 				Namespace: p.Cluster.Name,
 			},
-			Data: map[string]string{
-				"log": log,
-			},
-		})
-		logrus.Errorf("Failed to save provisioning log for %s: %v", configMapName, err)
+		}
+		Append(cm, p.pending...)
+		if _, err := p.ConfigMaps.Create(cm); err != nil {
+			logrus.Errorf("Failed to save provisioning log for %s: %v", configMapName, err)
+			return
+		}
 	} else if err != nil {
 		logrus.Errorf("Failed to get provisioning log for %s: %v", configMapName, err)
-	} else if log != cm.Data["log"] {
-		if cm.Data == nil {
-			cm.Data = map[string]string{}
-		}
-		cm.Data["log"] = log
-		_, err := p.ConfigMaps.Update(cm)
-		if err != nil {
+		return
+	} else {
+		Append(cm, p.pending...)
+		if _, err := p.ConfigMaps.Update(cm); err != nil {
 			logrus.Errorf("Failed to update provisioning log for %s: %v", configMapName, err)
+			return
 		}
 	}
+	p.pending = nil
 }
 
 func (p *logger) saveInterval() {
@@ -102,15 +101,15 @@ func (p *logger) logEvent(cluster *v3.Cluster, event logstream.LogEvent, cond co
 	} else {
 		logrus.Infof("cluster [%s] provisioning: %s", cluster.Name, event.Message)
 	}
-	p.buffer.WriteString(time.Now().Format(time.RFC3339))
-	p.buffer.WriteString(" ")
+	level := LevelInfo
 	if event.Error {
-		p.buffer.WriteString("[ERROR] ")
-	} else {
-		p.buffer.WriteString("[INFO ] ")
+		level = LevelError
 	}
-	p.buffer.WriteString(event.Message)
-	p.buffer.WriteString("\n")
+	p.pending = append(p.pending, Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: event.Message,
+	})
 	return cluster
 }
 
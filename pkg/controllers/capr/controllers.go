@@ -7,6 +7,8 @@
 	"github.com/rancher/rancher/pkg/capr/planner"
 	"github.com/rancher/rancher/pkg/controllers/capr/bootstrap"
 	"github.com/rancher/rancher/pkg/controllers/capr/dynamicschema"
+	"github.com/rancher/rancher/pkg/controllers/capr/etcdsnapshottarget"
+	"github.com/rancher/rancher/pkg/controllers/capr/etcdsnapshotverify"
 	"github.com/rancher/rancher/pkg/controllers/capr/machinedrain"
 	"github.com/rancher/rancher/pkg/controllers/capr/machinenodelookup"
 	"github.com/rancher/rancher/pkg/controllers/capr/machineprovision"
@@ -44,4 +46,6 @@ func Register(ctx context.Context, clients *wrangler.Context, kubeconfigManager
 	rkecontrolplane.Register(ctx, clients)
 	managesystemagent.Register(ctx, clients)
 	machinedrain.Register(ctx, clients)
+	etcdsnapshotverify.Register(ctx, clients)
+	etcdsnapshottarget.Register(ctx, clients)
 }
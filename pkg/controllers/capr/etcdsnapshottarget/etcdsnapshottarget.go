@@ -0,0 +1,376 @@
+package etcdsnapshottarget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/capr/etcds3"
+	"github.com/rancher/rancher/pkg/capr/planner"
+	sb "github.com/rancher/rancher/pkg/controllers/managementuser/snapshotbackpopulate"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/rancher/wrangler/v2/pkg/condition"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v2/pkg/name"
+	"github.com/rancher/wrangler/v2/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// resyncInterval is how often the snapshot targets of a cluster are reconciled.
+	resyncInterval = 10 * time.Minute
+	// copyTimeout bounds how long copying a single snapshot to another target may take.
+	copyTimeout = 30 * time.Minute
+)
+
+// Synced is set on control planes with snapshot targets once their snapshots have been replicated and pruned, or to
+// the errors of the last attempt.
+var Synced = condition.Cond("ETCDSnapshotTargetsSynced")
+
+type handler struct {
+	ctx               context.Context
+	secretCache       corecontrollers.SecretCache
+	controlPlanes     rkecontrollers.RKEControlPlaneController
+	controlPlaneCache rkecontrollers.RKEControlPlaneCache
+	etcdSnapshotCache rkecontrollers.ETCDSnapshotCache
+	etcdSnapshots     rkecontrollers.ETCDSnapshotClient
+
+	lock sync.Mutex
+	jobs map[string]*job
+}
+
+// job tracks the background synchronization of the snapshot targets of a cluster. Copying snapshots can take up to
+// copyTimeout per snapshot, so it runs outside of the controller workers.
+type job struct {
+	running bool
+	// again is set when the control plane or its snapshots changed while the job was running.
+	again bool
+	err   error
+}
+
+// Register sets up the etcd snapshot target controller. For clusters with snapshot targets, it copies the snapshots
+// the etcd nodes upload to the first S3 target to the other S3 targets, and enforces the retention of every S3 target.
+// The retention of local snapshots is computed by the planner, which hands the etcd nodes the snapshots to prune.
+func Register(ctx context.Context, clients *wrangler.Context) {
+	h := &handler{
+		ctx:               ctx,
+		secretCache:       clients.Core.Secret().Cache(),
+		controlPlanes:     clients.RKE.RKEControlPlane(),
+		controlPlaneCache: clients.RKE.RKEControlPlane().Cache(),
+		etcdSnapshotCache: clients.RKE.ETCDSnapshot().Cache(),
+		etcdSnapshots:     clients.RKE.ETCDSnapshot(),
+		jobs:              map[string]*job{},
+	}
+
+	clients.RKE.RKEControlPlane().OnChange(ctx, "etcd-snapshot-targets", h.OnChange)
+	relatedresource.Watch(ctx, "etcd-snapshot-targets-trigger", func(namespace, _ string, obj runtime.Object) ([]relatedresource.Key, error) {
+		if snapshot, ok := obj.(*rkev1.ETCDSnapshot); ok && snapshot.Spec.ClusterName != "" {
+			return []relatedresource.Key{{Namespace: namespace, Name: snapshot.Spec.ClusterName}}, nil
+		}
+		return nil, nil
+	}, clients.RKE.RKEControlPlane(), clients.RKE.ETCDSnapshot())
+}
+
+// OnChange starts the synchronization of the snapshot targets of the cluster in the background, and records the
+// result of the previous synchronization in the Synced condition.
+func (h *handler) OnChange(key string, controlPlane *rkev1.RKEControlPlane) (*rkev1.RKEControlPlane, error) {
+	if controlPlane == nil || controlPlane.DeletionTimestamp != nil || controlPlane.Spec.ETCD == nil || len(controlPlane.Spec.ETCD.SnapshotTargets) == 0 {
+		h.forget(key)
+		return controlPlane, nil
+	}
+	if err := capr.ValidateETCDSnapshotTargets(controlPlane.Spec.ETCD); err != nil {
+		return controlPlane, err
+	}
+
+	finished, lastErr := h.start(key, controlPlane)
+	h.controlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, resyncInterval)
+	if !finished {
+		return controlPlane, nil
+	}
+
+	status := controlPlane.DeepCopy()
+	if lastErr != nil {
+		Synced.SetError(status, "", lastErr)
+	} else {
+		Synced.True(status)
+		Synced.Message(status, "")
+		Synced.Reason(status, "")
+	}
+	if equality.Semantic.DeepEqual(status.Status, controlPlane.Status) {
+		return controlPlane, nil
+	}
+	return h.controlPlanes.UpdateStatus(status)
+}
+
+// start runs a synchronization of the snapshot targets of the cluster unless one is already running, in which case
+// that one is repeated once it is done. It returns whether a previous synchronization finished, and its result.
+func (h *handler) start(key string, controlPlane *rkev1.RKEControlPlane) (bool, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	j, finished := h.jobs[key]
+	if !finished {
+		j = &job{}
+		h.jobs[key] = j
+	}
+	if j.running {
+		j.again = true
+		return false, nil
+	}
+	j.running = true
+	go h.run(key, j, controlPlane.DeepCopy())
+	return finished, j.err
+}
+
+func (h *handler) run(key string, j *job, controlPlane *rkev1.RKEControlPlane) {
+	for {
+		err := h.sync(controlPlane)
+		if err != nil {
+			logrus.Errorf("[etcdsnapshottarget] rkecontrolplane %s/%s: %v", controlPlane.Namespace, controlPlane.Name, err)
+		}
+
+		h.lock.Lock()
+		changed := (err == nil) != (j.err == nil) || (err != nil && err.Error() != j.err.Error())
+		j.err = err
+		again := j.again
+		j.again = false
+		j.running = again
+		h.lock.Unlock()
+
+		if changed {
+			// record the new result in the status of the control plane
+			h.controlPlanes.Enqueue(controlPlane.Namespace, controlPlane.Name)
+		}
+		if !again {
+			return
+		}
+
+		latest, err := h.controlPlaneCache.Get(controlPlane.Namespace, controlPlane.Name)
+		if err != nil || latest.DeletionTimestamp != nil || latest.Spec.ETCD == nil || len(latest.Spec.ETCD.SnapshotTargets) == 0 {
+			h.lock.Lock()
+			j.running = false
+			h.lock.Unlock()
+			h.forget(key)
+			return
+		}
+		controlPlane = latest.DeepCopy()
+	}
+}
+
+// forget drops the job of a cluster that no longer has snapshot targets, unless it is still running.
+func (h *handler) forget(key string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if j, ok := h.jobs[key]; ok && !j.running {
+		delete(h.jobs, key)
+	}
+}
+
+// sync copies the snapshots uploaded by the etcd nodes to the other targets, and then prunes every target.
+func (h *handler) sync(controlPlane *rkev1.RKEControlPlane) error {
+	snapshots, err := h.etcdSnapshotCache.List(controlPlane.Namespace, labels.SelectorFromSet(labels.Set{capr.ClusterNameLabel: controlPlane.Name}))
+	if err != nil {
+		return err
+	}
+	byTarget := groupByTarget(snapshots)
+
+	// The etcd nodes upload to the S3 location of the cluster, which is kept with the snapshot retention, or else to
+	// the first S3 target. Their snapshots are grouped under the empty target name.
+	var (
+		errs       []error
+		pruned     []string
+		retentions = map[string]rkev1.ETCDSnapshotRetention{}
+		replicated = true
+	)
+	if controlPlane.Spec.ETCD.S3 != nil {
+		pruned = append(pruned, "")
+		retentions[""] = planner.EtcdSnapshotTargetRetention(controlPlane.Spec.ETCD, nil)
+	}
+	for i := range controlPlane.Spec.ETCD.SnapshotTargets {
+		target := &controlPlane.Spec.ETCD.SnapshotTargets[i]
+		if target.S3 == nil {
+			continue
+		}
+		retention := planner.EtcdSnapshotTargetRetention(controlPlane.Spec.ETCD, target)
+		if _, ok := retentions[""]; !ok {
+			pruned = append(pruned, "")
+			retentions[""] = retention
+			continue
+		}
+		pruned = append(pruned, target.Name)
+		retentions[target.Name] = retention
+		if err := h.replicate(controlPlane, target, byTarget[""], byTarget[target.Name], retention); err != nil {
+			errs = append(errs, err)
+			replicated = false
+		}
+	}
+
+	// Snapshots are only pruned once they have been copied, using a fresh list that includes the copies made above.
+	// The uploaded snapshots are kept until they have been copied to every target.
+	if snapshots, err = h.list(controlPlane); err != nil {
+		errs = append(errs, err)
+	} else {
+		byTarget = groupByTarget(snapshots)
+		for _, targetName := range pruned {
+			if targetName == "" && !replicated {
+				continue
+			}
+			errs = append(errs, h.prune(controlPlane, byTarget[targetName], retentions[targetName])...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// list returns the etcd snapshots of the cluster from the API server rather than the cache, so that it includes the
+// copies that were just created.
+func (h *handler) list(controlPlane *rkev1.RKEControlPlane) ([]*rkev1.ETCDSnapshot, error) {
+	list, err := h.etcdSnapshots.List(controlPlane.Namespace, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{capr.ClusterNameLabel: controlPlane.Name}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*rkev1.ETCDSnapshot, 0, len(list.Items))
+	for i := range list.Items {
+		snapshots = append(snapshots, &list.Items[i])
+	}
+	return snapshots, nil
+}
+
+// groupByTarget groups the S3 snapshots by the target they are stored in. Snapshots uploaded by the etcd nodes are not
+// labeled with a target, copies made by this controller are.
+func groupByTarget(snapshots []*rkev1.ETCDSnapshot) map[string][]*rkev1.ETCDSnapshot {
+	byTarget := map[string][]*rkev1.ETCDSnapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.DeletionTimestamp != nil || snapshot.SnapshotFile.S3 == nil || snapshot.Status.Missing {
+			continue
+		}
+		targetName := snapshot.Labels[capr.ETCDSnapshotTargetLabel]
+		byTarget[targetName] = append(byTarget[targetName], snapshot)
+	}
+	return byTarget
+}
+
+// replicate copies the snapshots uploaded by the etcd nodes that the retention of the target would keep, and that are
+// not in the target yet, to the target.
+func (h *handler) replicate(controlPlane *rkev1.RKEControlPlane, target *rkev1.ETCDSnapshotTarget, sources, copies []*rkev1.ETCDSnapshot, retention rkev1.ETCDSnapshotRetention) error {
+	copied := map[string]bool{}
+	for _, snapshot := range copies {
+		copied[snapshot.SnapshotFile.Name] = true
+	}
+	var candidates []*rkev1.ETCDSnapshot
+	for _, snapshot := range sources {
+		if !copied[snapshot.SnapshotFile.Name] && snapshot.SnapshotFile.Status != "failed" {
+			candidates = append(candidates, snapshot)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	keep := planner.RetainedETCDSnapshots(append(append([]*rkev1.ETCDSnapshot{}, copies...), candidates...), retention)
+	var dest *etcds3.Target
+	for _, snapshot := range candidates {
+		if !keep[snapshot.SnapshotFile.Name] {
+			continue
+		}
+		if dest == nil {
+			var err error
+			if dest, err = etcds3.Resolve(h.secretCache, controlPlane.Namespace, target.S3, nil); err != nil {
+				return fmt.Errorf("failed to resolve etcd snapshot target %s: %w", target.Name, err)
+			}
+		}
+		if err := h.copySnapshot(controlPlane, snapshot, target, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *handler) copySnapshot(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot, target *rkev1.ETCDSnapshotTarget, dest *etcds3.Target) error {
+	source, err := etcds3.ForSnapshot(h.secretCache, snapshot, controlPlane)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "etcd-snapshot-copy-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(h.ctx, copyTimeout)
+	defer cancel()
+
+	logrus.Infof("[etcdsnapshottarget] rkecontrolplane %s/%s: copying etcd snapshot %s to target %s", controlPlane.Namespace, controlPlane.Name, snapshot.SnapshotFile.Name, target.Name)
+	file, err := source.Download(ctx, snapshot.SnapshotFile.Name, dir)
+	if err != nil {
+		return err
+	}
+	if err := dest.Upload(ctx, snapshot.SnapshotFile.Name, file); err != nil {
+		return err
+	}
+
+	snapshotCopy := &rkev1.ETCDSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.SafeConcatName(snapshot.Name, target.Name),
+			Namespace: snapshot.Namespace,
+			Labels: map[string]string{
+				capr.ClusterNameLabel:        controlPlane.Name,
+				capr.ETCDSnapshotTargetLabel: target.Name,
+			},
+			Annotations: map[string]string{
+				sb.SnapshotNameKey:      snapshot.SnapshotFile.Name,
+				sb.StorageAnnotationKey: sb.StorageS3,
+			},
+			OwnerReferences: snapshot.OwnerReferences,
+		},
+		Spec: rkev1.ETCDSnapshotSpec{
+			ClusterName: snapshot.Spec.ClusterName,
+		},
+		SnapshotFile: *snapshot.SnapshotFile.DeepCopy(),
+	}
+	snapshotCopy.SnapshotFile.S3 = target.S3.DeepCopy()
+	snapshotCopy.SnapshotFile.Location = fmt.Sprintf("s3://%s/%s", dest.Bucket, dest.Key(snapshot.SnapshotFile.Name))
+	if _, err := h.etcdSnapshots.Create(snapshotCopy); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create etcd snapshot %s/%s: %w", snapshotCopy.Namespace, snapshotCopy.Name, err)
+	}
+	return nil
+}
+
+// prune removes the snapshots that are not kept by the retention from S3 and deletes their etcd snapshot objects.
+func (h *handler) prune(controlPlane *rkev1.RKEControlPlane, snapshots []*rkev1.ETCDSnapshot, retention rkev1.ETCDSnapshotRetention) []error {
+	keep := planner.RetainedETCDSnapshots(snapshots, retention)
+
+	var errs []error
+	for _, snapshot := range snapshots {
+		if snapshot.SnapshotFile.CreatedAt == nil || keep[snapshot.SnapshotFile.Name] {
+			continue
+		}
+		target, err := etcds3.ForSnapshot(h.secretCache, snapshot, controlPlane)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logrus.Infof("[etcdsnapshottarget] rkecontrolplane %s/%s: pruning etcd snapshot %s/%s", controlPlane.Namespace, controlPlane.Name, snapshot.Namespace, snapshot.Name)
+		if err := target.Remove(h.ctx, snapshot.SnapshotFile.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := h.etcdSnapshots.Delete(snapshot.Namespace, snapshot.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,200 @@
+package etcdsnapshotverify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/capr/etcds3"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/wrangler"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// downloadTimeout bounds how long downloading a single snapshot may take.
+const downloadTimeout = 30 * time.Minute
+
+type handler struct {
+	ctx               context.Context
+	secretCache       corecontrollers.SecretCache
+	controlPlaneCache rkecontrollers.RKEControlPlaneCache
+	controlPlanes     rkecontrollers.RKEControlPlaneController
+	etcdSnapshotCache rkecontrollers.ETCDSnapshotCache
+	etcdSnapshots     rkecontrollers.ETCDSnapshotClient
+	now               func() time.Time
+}
+
+// Register sets up the etcd snapshot verification controller. A snapshot is verified by downloading it and checking
+// that it is a consistent etcd database whenever its spec.verifyGeneration changes. Clusters with a snapshot
+// verification policy periodically have the verification of their latest S3 snapshot triggered.
+func Register(ctx context.Context, clients *wrangler.Context) {
+	h := &handler{
+		ctx:               ctx,
+		secretCache:       clients.Core.Secret().Cache(),
+		controlPlaneCache: clients.RKE.RKEControlPlane().Cache(),
+		controlPlanes:     clients.RKE.RKEControlPlane(),
+		etcdSnapshotCache: clients.RKE.ETCDSnapshot().Cache(),
+		etcdSnapshots:     clients.RKE.ETCDSnapshot(),
+		now:               time.Now,
+	}
+
+	clients.RKE.ETCDSnapshot().OnChange(ctx, "etcd-snapshot-verify", h.OnChange)
+	clients.RKE.RKEControlPlane().OnChange(ctx, "etcd-snapshot-verify-policy", h.OnControlPlaneChange)
+}
+
+// OnChange verifies a snapshot when its spec.verifyGeneration differs from the generation of its last verification.
+func (h *handler) OnChange(_ string, snapshot *rkev1.ETCDSnapshot) (*rkev1.ETCDSnapshot, error) {
+	if snapshot == nil || snapshot.DeletionTimestamp != nil || !verificationPending(snapshot) {
+		return snapshot, nil
+	}
+
+	verification, err := h.verify(snapshot)
+	if err != nil {
+		return snapshot, err
+	}
+	verification.Generation = snapshot.Spec.VerifyGeneration
+	verification.VerifiedAt = &metav1.Time{Time: h.now()}
+
+	logrus.Infof("[etcdsnapshotverify] etcd snapshot %s/%s verification result: %s %s", snapshot.Namespace, snapshot.Name, verification.Result, verification.Message)
+
+	snapshot = snapshot.DeepCopy()
+	snapshot.Status.Verification = verification
+	return h.etcdSnapshots.UpdateStatus(snapshot)
+}
+
+// verify returns the result of verifying the snapshot. An error is only returned for problems that are expected to be
+// transient, anything that prevents the snapshot from being verified is recorded in the result.
+func (h *handler) verify(snapshot *rkev1.ETCDSnapshot) (*rkev1.ETCDSnapshotVerification, error) {
+	if snapshot.SnapshotFile.S3 == nil {
+		return &rkev1.ETCDSnapshotVerification{
+			Result:  rkev1.ETCDSnapshotVerificationUnsupported,
+			Message: fmt.Sprintf("snapshot is stored on node %s and can only be verified when it is stored in S3", snapshot.SnapshotFile.NodeName),
+		}, nil
+	}
+
+	controlPlane, err := h.controlPlaneCache.Get(snapshot.Namespace, snapshot.Spec.ClusterName)
+	if apierrors.IsNotFound(err) {
+		return failed(fmt.Errorf("cluster %s/%s not found", snapshot.Namespace, snapshot.Spec.ClusterName)), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	target, err := etcds3.ForSnapshot(h.secretCache, snapshot, controlPlane)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	dir, err := os.MkdirTemp("", "etcd-snapshot-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(h.ctx, downloadTimeout)
+	defer cancel()
+	file, err := target.Download(ctx, snapshot.SnapshotFile.Name, dir)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	status, err := verifyFile(file, dir)
+	verification := &rkev1.ETCDSnapshotVerification{
+		Result:    rkev1.ETCDSnapshotVerificationVerified,
+		Checksum:  status.Checksum,
+		Hash:      status.Hash,
+		Revision:  status.Revision,
+		TotalKeys: status.TotalKeys,
+	}
+	if err != nil {
+		verification.Result = rkev1.ETCDSnapshotVerificationFailed
+		verification.Message = err.Error()
+	}
+	return verification, nil
+}
+
+func failed(err error) *rkev1.ETCDSnapshotVerification {
+	return &rkev1.ETCDSnapshotVerification{
+		Result:  rkev1.ETCDSnapshotVerificationFailed,
+		Message: err.Error(),
+	}
+}
+
+// OnControlPlaneChange triggers the verification of the latest S3 snapshot of a cluster with a snapshot verification
+// policy once the interval has passed since it was last verified.
+func (h *handler) OnControlPlaneChange(_ string, controlPlane *rkev1.RKEControlPlane) (*rkev1.RKEControlPlane, error) {
+	if controlPlane == nil || controlPlane.DeletionTimestamp != nil || controlPlane.Spec.ETCD == nil ||
+		controlPlane.Spec.ETCD.SnapshotVerification == nil || controlPlane.Spec.ETCD.SnapshotVerification.Interval.Duration <= 0 {
+		return controlPlane, nil
+	}
+	interval := controlPlane.Spec.ETCD.SnapshotVerification.Interval.Duration
+
+	snapshots, err := h.etcdSnapshotCache.List(controlPlane.Namespace, labels.Everything())
+	if err != nil {
+		return controlPlane, err
+	}
+
+	latest := latestS3Snapshot(snapshots, controlPlane.Name)
+	if latest == nil {
+		h.controlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, interval)
+		return controlPlane, nil
+	}
+
+	if wait := nextVerification(latest, interval, h.now()); wait > 0 {
+		h.controlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, wait)
+		return controlPlane, nil
+	}
+
+	logrus.Debugf("[etcdsnapshotverify] rkecontrolplane %s/%s: triggering verification of etcd snapshot %s", controlPlane.Namespace, controlPlane.Name, latest.Name)
+	latest = latest.DeepCopy()
+	latest.Spec.VerifyGeneration++
+	if _, err := h.etcdSnapshots.Update(latest); err != nil {
+		return controlPlane, err
+	}
+	h.controlPlanes.EnqueueAfter(controlPlane.Namespace, controlPlane.Name, interval)
+	return controlPlane, nil
+}
+
+// verificationPending returns true if the snapshot has not been verified for its current spec.verifyGeneration.
+func verificationPending(snapshot *rkev1.ETCDSnapshot) bool {
+	if snapshot.Spec.VerifyGeneration == 0 {
+		return false
+	}
+	return snapshot.Status.Verification == nil || snapshot.Status.Verification.Generation != snapshot.Spec.VerifyGeneration
+}
+
+// latestS3Snapshot returns the most recently created snapshot of the cluster that is stored in S3.
+func latestS3Snapshot(snapshots []*rkev1.ETCDSnapshot, clusterName string) *rkev1.ETCDSnapshot {
+	var latest *rkev1.ETCDSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.Spec.ClusterName != clusterName || snapshot.SnapshotFile.S3 == nil || snapshot.SnapshotFile.CreatedAt == nil ||
+			snapshot.Status.Missing || snapshot.DeletionTimestamp != nil {
+			continue
+		}
+		if latest == nil || snapshot.SnapshotFile.CreatedAt.After(latest.SnapshotFile.CreatedAt.Time) {
+			latest = snapshot
+		}
+	}
+	return latest
+}
+
+// nextVerification returns how long to wait before the snapshot is due to be verified again. Zero is returned if it is
+// due now, and the interval if a verification is already in progress.
+func nextVerification(snapshot *rkev1.ETCDSnapshot, interval time.Duration, now time.Time) time.Duration {
+	if verificationPending(snapshot) {
+		return interval
+	}
+	verification := snapshot.Status.Verification
+	if verification == nil || verification.VerifiedAt == nil {
+		return 0
+	}
+	if wait := verification.VerifiedAt.Add(interval).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package etcdsnapshotverify
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// snapshotHashSize is the size of the sha256 hash etcd appends to the database when it saves a snapshot.
+	snapshotHashSize = sha256.Size
+	// boltPageSize is the size the database file is always a multiple of.
+	boltPageSize = 512
+	// keyBucket is the bolt bucket etcd stores its key-value revisions in.
+	keyBucket = "key"
+)
+
+// snapshotStatus is the result of checking the integrity of a snapshot file.
+type snapshotStatus struct {
+	Checksum  string
+	Hash      uint32
+	Revision  int64
+	TotalKeys int
+}
+
+// verifyFile checks that the snapshot at path, as it was downloaded, is intact. The sha256 checksum of the file is
+// always returned so that it can be recorded even when the integrity check fails. Compressed snapshots are unpacked
+// into dir before they are checked.
+func verifyFile(path, dir string) (snapshotStatus, error) {
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return snapshotStatus{}, err
+	}
+	status := snapshotStatus{Checksum: checksum}
+
+	dbPath := path
+	if strings.HasSuffix(path, ".zip") {
+		if dbPath, err = unzipSnapshot(path, dir); err != nil {
+			return status, err
+		}
+	}
+
+	if err := checkSnapshotHash(dbPath); err != nil {
+		return status, err
+	}
+
+	status.Hash, status.Revision, status.TotalKeys, err = dbStatus(dbPath)
+	return status, err
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unzipSnapshot extracts the database of a compressed snapshot, which is the only file in the archive.
+func unzipSnapshot(path, dir string) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open compressed snapshot: %w", err)
+	}
+	defer r.Close()
+
+	if len(r.File) != 1 {
+		return "", fmt.Errorf("compressed snapshot contains %d files, expected 1", len(r.File))
+	}
+
+	in, err := r.File[0].Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open compressed snapshot: %w", err)
+	}
+	defer in.Close()
+
+	dbPath := filepath.Join(dir, "snapshot.db")
+	out, err := os.OpenFile(dbPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return "", fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+	return dbPath, out.Close()
+}
+
+// checkSnapshotHash compares the sha256 hash etcd appends to a snapshot with the hash of the database, and then
+// truncates the file to the database so that it can be opened.
+func checkSnapshotHash(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size%boltPageSize != snapshotHashSize {
+		return fmt.Errorf("snapshot size %d does not include an integrity hash, the snapshot may be truncated", size)
+	}
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, size-snapshotHashSize); err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	expected := make([]byte, snapshotHashSize)
+	if _, err := io.ReadFull(f, expected); err != nil {
+		return fmt.Errorf("failed to read snapshot integrity hash: %w", err)
+	}
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return errors.New("snapshot integrity hash does not match its contents")
+	}
+
+	return f.Truncate(size - snapshotHashSize)
+}
+
+// dbStatus checks the consistency of the database and computes the same hash, revision and key count as
+// "etcdutl snapshot status".
+func dbStatus(path string) (hash uint32, revision int64, totalKeys int, err error) {
+	db, err := bolt.Open(path, 0400, &bolt.Options{ReadOnly: true, Timeout: 5 * time.Second})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to open snapshot database: %w", err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		// The channel has to be drained, the check stops once it has gone through the whole database.
+		var checkErr error
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+		if checkErr != nil {
+			return fmt.Errorf("snapshot database is inconsistent: %w", checkErr)
+		}
+		if tx.Bucket([]byte(keyBucket)) == nil {
+			return errors.New("snapshot is not an etcd database")
+		}
+
+		h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			h.Write(name)
+			isKeyBucket := string(name) == keyBucket
+			return b.ForEach(func(k, v []byte) error {
+				h.Write(k)
+				h.Write(v)
+				if isKeyBucket && len(k) >= 8 {
+					revision = int64(binary.BigEndian.Uint64(k[0:8]))
+				}
+				totalKeys++
+				return nil
+			})
+		})
+		hash = h.Sum32()
+		return err
+	})
+	return hash, revision, totalKeys, err
+}
@@ -0,0 +1,228 @@
+package etcdsnapshotverify
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// writeSnapshot writes an etcd database with the given number of revisions, followed by its sha256 hash like
+// "etcdctl snapshot save" does.
+func writeSnapshot(t *testing.T, path string, revisions int) {
+	t.Helper()
+	dbPath := path + ".db"
+	db, err := bolt.Open(dbPath, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(keyBucket))
+		if err != nil {
+			return err
+		}
+		for i := 1; i <= revisions; i++ {
+			key := make([]byte, 17)
+			binary.BigEndian.PutUint64(key[0:8], uint64(i))
+			if err := b.Put(key, []byte("value")); err != nil {
+				return err
+			}
+		}
+		meta, err := tx.CreateBucket([]byte("meta"))
+		if err != nil {
+			return err
+		}
+		return meta.Put([]byte("consistent_index"), []byte{0, 0, 0, 0, 0, 0, 0, 1})
+	}))
+	require.NoError(t, db.Close())
+
+	data, err := os.ReadFile(dbPath)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	require.NoError(t, os.WriteFile(path, append(data, sum[:]...), 0600))
+	require.NoError(t, os.Remove(dbPath))
+}
+
+func TestVerifyFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		tamper    func(t *testing.T, path string) string
+		wantError string
+	}{
+		{
+			name: "intact",
+		},
+		{
+			name: "compressed",
+			tamper: func(t *testing.T, path string) string {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				zipPath := path + ".zip"
+				f, err := os.Create(zipPath)
+				require.NoError(t, err)
+				w := zip.NewWriter(f)
+				entry, err := w.Create(filepath.Base(path))
+				require.NoError(t, err)
+				_, err = entry.Write(data)
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				require.NoError(t, f.Close())
+				return zipPath
+			},
+		},
+		{
+			name: "truncated",
+			tamper: func(t *testing.T, path string) string {
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				require.NoError(t, os.Truncate(path, info.Size()-100))
+				return path
+			},
+			wantError: "may be truncated",
+		},
+		{
+			name: "modified",
+			tamper: func(t *testing.T, path string) string {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				data[len(data)/2] ^= 0xff
+				require.NoError(t, os.WriteFile(path, data, 0600))
+				return path
+			},
+			wantError: "does not match",
+		},
+		{
+			name: "not an etcd database",
+			tamper: func(t *testing.T, path string) string {
+				db, err := bolt.Open(path+".other", 0600, nil)
+				require.NoError(t, err)
+				require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+					_, err := tx.CreateBucket([]byte("other"))
+					return err
+				}))
+				require.NoError(t, db.Close())
+				data, err := os.ReadFile(path + ".other")
+				require.NoError(t, err)
+				sum := sha256.Sum256(data)
+				require.NoError(t, os.WriteFile(path, append(data, sum[:]...), 0600))
+				return path
+			},
+			wantError: "not an etcd database",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "etcd-snapshot")
+			writeSnapshot(t, path, 3)
+			if tt.tamper != nil {
+				path = tt.tamper(t, path)
+			}
+
+			status, err := verifyFile(path, dir)
+			assert.Regexp(t, "^sha256:[0-9a-f]{64}$", status.Checksum)
+			if tt.wantError != "" {
+				require.ErrorContains(t, err, tt.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), status.Revision)
+			assert.Equal(t, 4, status.TotalKeys)
+			assert.NotZero(t, status.Hash)
+		})
+	}
+}
+
+func TestNextVerification(t *testing.T) {
+	now := time.Now()
+	interval := time.Hour
+
+	tests := []struct {
+		name     string
+		snapshot *rkev1.ETCDSnapshot
+		want     time.Duration
+	}{
+		{
+			name:     "never verified",
+			snapshot: &rkev1.ETCDSnapshot{},
+			want:     0,
+		},
+		{
+			name: "verification in progress",
+			snapshot: &rkev1.ETCDSnapshot{
+				Spec: rkev1.ETCDSnapshotSpec{VerifyGeneration: 2},
+				Status: rkev1.ETCDSnapshotStatus{Verification: &rkev1.ETCDSnapshotVerification{
+					Generation: 1,
+					VerifiedAt: &metav1.Time{Time: now.Add(-2 * time.Hour)},
+				}},
+			},
+			want: interval,
+		},
+		{
+			name: "recently verified",
+			snapshot: &rkev1.ETCDSnapshot{
+				Spec: rkev1.ETCDSnapshotSpec{VerifyGeneration: 1},
+				Status: rkev1.ETCDSnapshotStatus{Verification: &rkev1.ETCDSnapshotVerification{
+					Generation: 1,
+					VerifiedAt: &metav1.Time{Time: now.Add(-20 * time.Minute)},
+				}},
+			},
+			want: 40 * time.Minute,
+		},
+		{
+			name: "verification is due",
+			snapshot: &rkev1.ETCDSnapshot{
+				Spec: rkev1.ETCDSnapshotSpec{VerifyGeneration: 1},
+				Status: rkev1.ETCDSnapshotStatus{Verification: &rkev1.ETCDSnapshotVerification{
+					Generation: 1,
+					VerifiedAt: &metav1.Time{Time: now.Add(-2 * time.Hour)},
+				}},
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextVerification(tt.snapshot, interval, now))
+		})
+	}
+}
+
+func TestLatestS3Snapshot(t *testing.T) {
+	at := func(hours int) *metav1.Time {
+		return &metav1.Time{Time: time.Date(2024, 1, 1, hours, 0, 0, 0, time.UTC)}
+	}
+	snapshot := func(name, cluster string, createdAt *metav1.Time, s3, missing bool) *rkev1.ETCDSnapshot {
+		s := &rkev1.ETCDSnapshot{
+			ObjectMeta:   metav1.ObjectMeta{Name: name},
+			Spec:         rkev1.ETCDSnapshotSpec{ClusterName: cluster},
+			SnapshotFile: rkev1.ETCDSnapshotFile{CreatedAt: createdAt},
+			Status:       rkev1.ETCDSnapshotStatus{Missing: missing},
+		}
+		if s3 {
+			s.SnapshotFile.S3 = &rkev1.ETCDSnapshotS3{Bucket: "bucket"}
+		}
+		return s
+	}
+
+	latest := latestS3Snapshot([]*rkev1.ETCDSnapshot{
+		snapshot("old", "c1", at(1), true, false),
+		snapshot("latest", "c1", at(2), true, false),
+		snapshot("local", "c1", at(3), false, false),
+		snapshot("missing", "c1", at(4), true, true),
+		snapshot("other-cluster", "c2", at(5), true, false),
+	}, "c1")
+	require.NotNil(t, latest)
+	assert.Equal(t, "latest", latest.Name)
+
+	assert.Nil(t, latestS3Snapshot(nil, "c1"))
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/rancher/wrangler/v2/pkg/name"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	accessRequestController = "mgmt-auth-access-request-controller"
+	// accessRequestAnnotation is set on bindings created for an AccessRequest to the name of the request.
+	accessRequestAnnotation = "authz.management.cattle.io/access-request"
+	// approvedByAnnotation is set on bindings created for an AccessRequest to the name of the user who approved it.
+	approvedByAnnotation = "authz.management.cattle.io/approved-by"
+)
+
+// accessRequestHandler grants approved AccessRequests by creating a ClusterRoleTemplateBinding or
+// ProjectRoleTemplateBinding whose TTL is the requested duration. The bindings are then handled like any other
+// binding, including their expiry, and are owned by the request so that deleting the request revokes the access.
+type accessRequestHandler struct {
+	accessRequests mgmtcontrollers.AccessRequestController
+	crtbs          mgmtcontrollers.ClusterRoleTemplateBindingClient
+	prtbs          mgmtcontrollers.ProjectRoleTemplateBindingClient
+}
+
+func registerAccessRequests(ctx context.Context, management *config.ManagementContext) {
+	h := &accessRequestHandler{
+		accessRequests: management.Wrangler.Mgmt.AccessRequest(),
+		crtbs:          management.Wrangler.Mgmt.ClusterRoleTemplateBinding(),
+		prtbs:          management.Wrangler.Mgmt.ProjectRoleTemplateBinding(),
+	}
+	h.accessRequests.OnChange(ctx, accessRequestController, h.sync)
+}
+
+func (h *accessRequestHandler) sync(_ string, ar *v3.AccessRequest) (*v3.AccessRequest, error) {
+	if ar == nil || ar.DeletionTimestamp != nil {
+		return ar, nil
+	}
+
+	switch ar.Status.State {
+	case "":
+		ar = ar.DeepCopy()
+		ar.Status.State = v3.AccessRequestStatePending
+		return h.accessRequests.UpdateStatus(ar)
+	case v3.AccessRequestStateApproved:
+		if ar.Status.BindingName != "" {
+			return ar, nil
+		}
+		if ar.Status.ReviewedGeneration != ar.Generation {
+			// the request was modified after it was approved, the approval does not apply to the new spec
+			logrus.Infof("[%s] Discarding approval of modified accessRequest %s", accessRequestController, ar.Name)
+			ar = ar.DeepCopy()
+			ar.Status = v3.AccessRequestStatus{
+				State:   v3.AccessRequestStatePending,
+				Message: "the request was modified after it was approved and must be reviewed again",
+			}
+			return h.accessRequests.UpdateStatus(ar)
+		}
+		return h.grant(ar)
+	}
+	return ar, nil
+}
+
+// grant creates the binding of an approved AccessRequest and records it in the status of the request.
+func (h *accessRequestHandler) grant(ar *v3.AccessRequest) (*v3.AccessRequest, error) {
+	clusterName, projectName, err := pkgrbac.AccessRequestTarget(ar)
+	if err != nil {
+		ar = ar.DeepCopy()
+		ar.Status.Message = err.Error()
+		return h.accessRequests.UpdateStatus(ar)
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name: name.SafeConcatName("ar", ar.Name),
+		Annotations: map[string]string{
+			accessRequestAnnotation: ar.Name,
+			approvedByAnnotation:    ar.Status.ReviewedBy,
+		},
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				APIVersion: v3.SchemeGroupVersion.String(),
+				Kind:       "AccessRequest",
+				Name:       ar.Name,
+				UID:        ar.UID,
+			},
+		},
+	}
+	if projectName != "" {
+		objectMeta.Namespace = projectName
+		logrus.Infof("[%s] Creating projectRoleTemplateBinding %s/%s for accessRequest %s approved by %s", accessRequestController, objectMeta.Namespace, objectMeta.Name, ar.Name, ar.Status.ReviewedBy)
+		_, err = h.prtbs.Create(&v3.ProjectRoleTemplateBinding{
+			ObjectMeta:       objectMeta,
+			UserName:         ar.Spec.UserName,
+			ProjectName:      ar.Spec.ProjectName,
+			RoleTemplateName: ar.Spec.RoleTemplateName,
+			TTL:              ar.Spec.Duration,
+		})
+	} else {
+		objectMeta.Namespace = clusterName
+		logrus.Infof("[%s] Creating clusterRoleTemplateBinding %s/%s for accessRequest %s approved by %s", accessRequestController, objectMeta.Namespace, objectMeta.Name, ar.Name, ar.Status.ReviewedBy)
+		_, err = h.crtbs.Create(&v3.ClusterRoleTemplateBinding{
+			ObjectMeta:       objectMeta,
+			UserName:         ar.Spec.UserName,
+			ClusterName:      clusterName,
+			RoleTemplateName: ar.Spec.RoleTemplateName,
+			TTL:              ar.Spec.Duration,
+		})
+	}
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return ar, fmt.Errorf("failed to create binding for accessRequest %s: %w", ar.Name, err)
+	}
+
+	ar = ar.DeepCopy()
+	ar.Status.BindingNamespace = objectMeta.Namespace
+	ar.Status.BindingName = objectMeta.Name
+	ar.Status.Message = ""
+	return h.accessRequests.UpdateStatus(ar)
+}
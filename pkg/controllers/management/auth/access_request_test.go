@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAccessRequestSync(t *testing.T) {
+	newAccessRequest := func(spec v3.AccessRequestSpec, status v3.AccessRequestStatus) *v3.AccessRequest {
+		return &v3.AccessRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "ar-test",
+				UID:        "uid",
+				Generation: 1,
+			},
+			Spec:   spec,
+			Status: status,
+		}
+	}
+	approved := v3.AccessRequestStatus{
+		State:              v3.AccessRequestStateApproved,
+		ReviewedBy:         "u-owner",
+		ReviewedGeneration: 1,
+	}
+	clusterSpec := v3.AccessRequestSpec{
+		UserName:         "u-requester",
+		RoleTemplateName: "cluster-member",
+		ClusterName:      "c-abc",
+		Duration:         "8h",
+	}
+	projectSpec := v3.AccessRequestSpec{
+		UserName:         "u-requester",
+		RoleTemplateName: "project-member",
+		ProjectName:      "c-abc:p-xyz",
+		Duration:         "8h",
+	}
+	updateStatus := func(obj *v3.AccessRequest) (*v3.AccessRequest, error) {
+		return obj, nil
+	}
+
+	t.Run("new request is pending", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars}
+
+		ars.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(updateStatus)
+
+		got, err := h.sync("", newAccessRequest(clusterSpec, v3.AccessRequestStatus{}))
+		require.NoError(t, err)
+		assert.Equal(t, v3.AccessRequestStatePending, got.Status.State)
+	})
+
+	t.Run("pending and denied requests are left alone", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars}
+
+		for _, state := range []string{v3.AccessRequestStatePending, v3.AccessRequestStateDenied} {
+			ar := newAccessRequest(clusterSpec, v3.AccessRequestStatus{State: state})
+			got, err := h.sync("", ar)
+			require.NoError(t, err)
+			assert.Equal(t, ar, got)
+		}
+	})
+
+	t.Run("approved cluster request creates a crtb", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		crtbs := fake.NewMockClientInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars, crtbs: crtbs}
+
+		crtbs.EXPECT().Create(gomock.Any()).DoAndReturn(func(crtb *v3.ClusterRoleTemplateBinding) (*v3.ClusterRoleTemplateBinding, error) {
+			assert.Equal(t, "c-abc", crtb.Namespace)
+			assert.Equal(t, "ar-ar-test", crtb.Name)
+			assert.Equal(t, "c-abc", crtb.ClusterName)
+			assert.Equal(t, "u-requester", crtb.UserName)
+			assert.Equal(t, "cluster-member", crtb.RoleTemplateName)
+			assert.Equal(t, "8h", crtb.TTL)
+			assert.Equal(t, "u-owner", crtb.Annotations[approvedByAnnotation])
+			assert.Equal(t, "ar-test", crtb.Annotations[accessRequestAnnotation])
+			require.Len(t, crtb.OwnerReferences, 1)
+			assert.Equal(t, "AccessRequest", crtb.OwnerReferences[0].Kind)
+			return crtb, nil
+		})
+		ars.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(updateStatus)
+
+		got, err := h.sync("", newAccessRequest(clusterSpec, approved))
+		require.NoError(t, err)
+		assert.Equal(t, "c-abc", got.Status.BindingNamespace)
+		assert.Equal(t, "ar-ar-test", got.Status.BindingName)
+		assert.Equal(t, v3.AccessRequestStateApproved, got.Status.State)
+	})
+
+	t.Run("approved project request creates a prtb", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		prtbs := fake.NewMockClientInterface[*v3.ProjectRoleTemplateBinding, *v3.ProjectRoleTemplateBindingList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars, prtbs: prtbs}
+
+		prtbs.EXPECT().Create(gomock.Any()).DoAndReturn(func(prtb *v3.ProjectRoleTemplateBinding) (*v3.ProjectRoleTemplateBinding, error) {
+			assert.Equal(t, "p-xyz", prtb.Namespace)
+			assert.Equal(t, "c-abc:p-xyz", prtb.ProjectName)
+			assert.Equal(t, "project-member", prtb.RoleTemplateName)
+			assert.Equal(t, "8h", prtb.TTL)
+			return prtb, nil
+		})
+		ars.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(updateStatus)
+
+		got, err := h.sync("", newAccessRequest(projectSpec, approved))
+		require.NoError(t, err)
+		assert.Equal(t, "p-xyz", got.Status.BindingNamespace)
+	})
+
+	t.Run("existing binding is recorded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		crtbs := fake.NewMockClientInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars, crtbs: crtbs}
+
+		crtbs.EXPECT().Create(gomock.Any()).Return(nil, apierrors.NewAlreadyExists(schema.GroupResource{}, "ar-ar-test"))
+		ars.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(updateStatus)
+
+		got, err := h.sync("", newAccessRequest(clusterSpec, approved))
+		require.NoError(t, err)
+		assert.Equal(t, "ar-ar-test", got.Status.BindingName)
+	})
+
+	t.Run("granted request is left alone", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars}
+
+		status := approved
+		status.BindingNamespace, status.BindingName = "c-abc", "ar-ar-test"
+		ar := newAccessRequest(clusterSpec, status)
+		got, err := h.sync("", ar)
+		require.NoError(t, err)
+		assert.Equal(t, ar, got)
+	})
+
+	t.Run("request modified after its approval is pending again", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ars := fake.NewMockNonNamespacedControllerInterface[*v3.AccessRequest, *v3.AccessRequestList](ctrl)
+		h := &accessRequestHandler{accessRequests: ars}
+
+		ars.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(updateStatus)
+
+		ar := newAccessRequest(clusterSpec, approved)
+		ar.Generation = 2
+		got, err := h.sync("", ar)
+		require.NoError(t, err)
+		assert.Equal(t, v3.AccessRequestStatePending, got.Status.State)
+		assert.Empty(t, got.Status.ReviewedBy)
+		assert.NotEmpty(t, got.Status.Message)
+	})
+}
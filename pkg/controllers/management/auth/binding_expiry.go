@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	mgmtcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	pkgrbac "github.com/rancher/rancher/pkg/rbac"
+	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const bindingExpiryController = "mgmt-auth-binding-expiry-controller"
+
+// bindingExpiryHandler deletes GlobalRoleBindings, ClusterRoleTemplateBindings and ProjectRoleTemplateBindings once
+// they expire, which lets their lifecycles tear down the RBAC they created in the management and downstream clusters.
+// Until then, the expiry is recorded in the status of the bindings, which are requeued for when they expire.
+type bindingExpiryHandler struct {
+	grbs  mgmtcontrollers.GlobalRoleBindingController
+	crtbs mgmtcontrollers.ClusterRoleTemplateBindingController
+	prtbs mgmtcontrollers.ProjectRoleTemplateBindingController
+	now   func() time.Time
+}
+
+func registerBindingExpiry(ctx context.Context, management *config.ManagementContext) {
+	h := &bindingExpiryHandler{
+		grbs:  management.Wrangler.Mgmt.GlobalRoleBinding(),
+		crtbs: management.Wrangler.Mgmt.ClusterRoleTemplateBinding(),
+		prtbs: management.Wrangler.Mgmt.ProjectRoleTemplateBinding(),
+		now:   time.Now,
+	}
+	h.grbs.OnChange(ctx, bindingExpiryController, h.syncGRB)
+	h.crtbs.OnChange(ctx, bindingExpiryController, h.syncCRTB)
+	h.prtbs.OnChange(ctx, bindingExpiryController, h.syncPRTB)
+}
+
+// evaluate returns the status of a binding, whether it has expired, and how long until it expires. The zero status is
+// returned for bindings that do not expire.
+func (h *bindingExpiryHandler) evaluate(obj metav1.Object, expiresAt *metav1.Time, ttl string) (v3.RoleBindingStatus, bool, time.Duration, error) {
+	expiry, ok, err := pkgrbac.BindingExpiry(obj, expiresAt, ttl)
+	if err != nil || !ok {
+		return v3.RoleBindingStatus{}, false, 0, err
+	}
+	remaining := expiry.Sub(h.now())
+	return v3.RoleBindingStatus{ExpiresAt: &metav1.Time{Time: expiry}}, remaining <= 0, remaining, nil
+}
+
+func (h *bindingExpiryHandler) syncGRB(_ string, grb *v3.GlobalRoleBinding) (*v3.GlobalRoleBinding, error) {
+	if grb == nil || grb.DeletionTimestamp != nil {
+		return grb, nil
+	}
+	status, expired, remaining, err := h.evaluate(grb, grb.ExpiresAt, grb.TTL)
+	if err != nil {
+		return grb, err
+	}
+	if expired {
+		logrus.Infof("[%s] Deleting expired globalRoleBinding %s", bindingExpiryController, grb.Name)
+		if err := h.grbs.Delete(grb.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return grb, err
+		}
+		return grb, nil
+	}
+	if remaining > 0 {
+		h.grbs.EnqueueAfter(grb.Name, remaining)
+	}
+	// the status only changes when the expiry of the binding does
+	if equality.Semantic.DeepEqual(grb.Status, status) {
+		return grb, nil
+	}
+	grb = grb.DeepCopy()
+	grb.Status = status
+	return h.grbs.Update(grb)
+}
+
+func (h *bindingExpiryHandler) syncCRTB(_ string, crtb *v3.ClusterRoleTemplateBinding) (*v3.ClusterRoleTemplateBinding, error) {
+	if crtb == nil || crtb.DeletionTimestamp != nil {
+		return crtb, nil
+	}
+	status, expired, remaining, err := h.evaluate(crtb, crtb.ExpiresAt, crtb.TTL)
+	if err != nil {
+		return crtb, err
+	}
+	if expired {
+		logrus.Infof("[%s] Deleting expired clusterRoleTemplateBinding %s/%s", bindingExpiryController, crtb.Namespace, crtb.Name)
+		if err := h.crtbs.Delete(crtb.Namespace, crtb.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return crtb, err
+		}
+		return crtb, nil
+	}
+	if remaining > 0 {
+		h.crtbs.EnqueueAfter(crtb.Namespace, crtb.Name, remaining)
+	}
+	// the status only changes when the expiry of the binding does
+	if equality.Semantic.DeepEqual(crtb.Status, status) {
+		return crtb, nil
+	}
+	crtb = crtb.DeepCopy()
+	crtb.Status = status
+	return h.crtbs.Update(crtb)
+}
+
+func (h *bindingExpiryHandler) syncPRTB(_ string, prtb *v3.ProjectRoleTemplateBinding) (*v3.ProjectRoleTemplateBinding, error) {
+	if prtb == nil || prtb.DeletionTimestamp != nil {
+		return prtb, nil
+	}
+	status, expired, remaining, err := h.evaluate(prtb, prtb.ExpiresAt, prtb.TTL)
+	if err != nil {
+		return prtb, err
+	}
+	if expired {
+		logrus.Infof("[%s] Deleting expired projectRoleTemplateBinding %s/%s", bindingExpiryController, prtb.Namespace, prtb.Name)
+		if err := h.prtbs.Delete(prtb.Namespace, prtb.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return prtb, err
+		}
+		return prtb, nil
+	}
+	if remaining > 0 {
+		h.prtbs.EnqueueAfter(prtb.Namespace, prtb.Name, remaining)
+	}
+	// the status only changes when the expiry of the binding does
+	if equality.Semantic.DeepEqual(prtb.Status, status) {
+		return prtb, nil
+	}
+	prtb = prtb.DeepCopy()
+	prtb.Status = status
+	return h.prtbs.Update(prtb)
+}
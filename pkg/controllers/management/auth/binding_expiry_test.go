@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBindingExpirySyncCRTB(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newCRTB := func(ttl string) *v3.ClusterRoleTemplateBinding {
+		return &v3.ClusterRoleTemplateBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "crtb",
+				Namespace:         "c-abc",
+				CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)},
+			},
+			ClusterName:      "c-abc",
+			RoleTemplateName: "cluster-owner",
+			UserName:         "u-abc",
+			TTL:              ttl,
+		}
+	}
+
+	t.Run("permanent binding is left alone", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		crtbs := fake.NewMockControllerInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &bindingExpiryHandler{crtbs: crtbs, now: func() time.Time { return now }}
+
+		crtb := newCRTB("")
+		got, err := h.syncCRTB("", crtb)
+		require.NoError(t, err)
+		assert.Equal(t, crtb, got)
+	})
+
+	t.Run("status is updated and the binding requeued for its expiry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		crtbs := fake.NewMockControllerInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &bindingExpiryHandler{crtbs: crtbs, now: func() time.Time { return now }}
+
+		crtbs.EXPECT().EnqueueAfter("c-abc", "crtb", 2*time.Hour)
+		crtbs.EXPECT().Update(gomock.Any()).DoAndReturn(func(obj *v3.ClusterRoleTemplateBinding) (*v3.ClusterRoleTemplateBinding, error) {
+			return obj, nil
+		})
+
+		got, err := h.syncCRTB("", newCRTB("3h"))
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(2*time.Hour), got.Status.ExpiresAt.Time)
+	})
+
+	t.Run("status is not updated while the expiry is unchanged", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		crtbs := fake.NewMockControllerInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &bindingExpiryHandler{crtbs: crtbs, now: func() time.Time { return now }}
+
+		crtb := newCRTB("3h")
+		crtb.Status = v3.RoleBindingStatus{ExpiresAt: &metav1.Time{Time: now.Add(2 * time.Hour)}}
+		crtbs.EXPECT().EnqueueAfter("c-abc", "crtb", 2*time.Hour)
+
+		_, err := h.syncCRTB("", crtb)
+		require.NoError(t, err)
+	})
+
+	t.Run("expired binding is deleted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		crtbs := fake.NewMockControllerInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &bindingExpiryHandler{crtbs: crtbs, now: func() time.Time { return now }}
+
+		crtbs.EXPECT().Delete("c-abc", "crtb", gomock.Any())
+
+		_, err := h.syncCRTB("", newCRTB("1h"))
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid ttl is an error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		crtbs := fake.NewMockControllerInterface[*v3.ClusterRoleTemplateBinding, *v3.ClusterRoleTemplateBindingList](ctrl)
+		h := &bindingExpiryHandler{crtbs: crtbs, now: func() time.Time { return now }}
+
+		_, err := h.syncCRTB("", newCRTB("soon"))
+		assert.Error(t, err)
+	})
+}
+
+func TestBindingExpirySyncGRB(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctrl := gomock.NewController(t)
+	grbs := fake.NewMockNonNamespacedControllerInterface[*v3.GlobalRoleBinding, *v3.GlobalRoleBindingList](ctrl)
+	h := &bindingExpiryHandler{grbs: grbs, now: func() time.Time { return now }}
+
+	grbs.EXPECT().Delete("grb", gomock.Any())
+
+	_, err := h.syncGRB("", &v3.GlobalRoleBinding{
+		ObjectMeta:     metav1.ObjectMeta{Name: "grb"},
+		GlobalRoleName: "admin",
+		UserName:       "u-abc",
+		ExpiresAt:      &metav1.Time{Time: now},
+	})
+	require.NoError(t, err)
+}
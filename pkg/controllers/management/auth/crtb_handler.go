@@ -3,6 +3,7 @@
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -62,6 +63,10 @@ type crtbLifecycle struct {
 }
 
 func (c *crtbLifecycle) Create(obj *v3.ClusterRoleTemplateBinding) (runtime.Object, error) {
+	if pkgrbac.IsCRTBExpired(obj, time.Now()) {
+		// expired bindings are deleted by the binding expiry controller, don't grant their permissions in the meantime
+		return obj, nil
+	}
 	obj, err := c.reconcileSubject(obj)
 	if err != nil {
 		return nil, err
@@ -72,6 +77,9 @@ func (c *crtbLifecycle) Create(obj *v3.ClusterRoleTemplateBinding) (runtime.Obje
 }
 
 func (c *crtbLifecycle) Updated(obj *v3.ClusterRoleTemplateBinding) (runtime.Object, error) {
+	if pkgrbac.IsCRTBExpired(obj, time.Now()) {
+		return obj, nil
+	}
 	obj, err := c.reconcileSubject(obj)
 	if err != nil {
 		return nil, err
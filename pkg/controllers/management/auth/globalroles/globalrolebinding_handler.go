@@ -3,6 +3,7 @@
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -97,6 +98,10 @@ type globalRoleBindingLifecycle struct {
 }
 
 func (grb *globalRoleBindingLifecycle) Create(obj *v3.GlobalRoleBinding) (runtime.Object, error) {
+	if rbac.IsGRBExpired(obj, time.Now()) {
+		// expired bindings are deleted by the binding expiry controller, don't grant their permissions in the meantime
+		return obj, nil
+	}
 	var returnError error
 	err := grb.reconcileClusterPermissions(obj)
 	if err != nil {
@@ -114,6 +119,9 @@ func (grb *globalRoleBindingLifecycle) Create(obj *v3.GlobalRoleBinding) (runtim
 }
 
 func (grb *globalRoleBindingLifecycle) Updated(obj *v3.GlobalRoleBinding) (runtime.Object, error) {
+	if rbac.IsGRBExpired(obj, time.Now()) {
+		return obj, nil
+	}
 	var returnError error
 	err := grb.reconcileClusterPermissions(obj)
 	if err != nil {
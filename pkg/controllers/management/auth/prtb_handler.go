@@ -3,6 +3,7 @@
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -53,6 +54,10 @@ func (p *prtbLifecycle) Create(obj *v3.ProjectRoleTemplateBinding) (runtime.Obje
 	if obj.ServiceAccount != "" {
 		return obj, nil
 	}
+	if pkgrbac.IsPRTBExpired(obj, time.Now()) {
+		// expired bindings are deleted by the binding expiry controller, don't grant their permissions in the meantime
+		return obj, nil
+	}
 	obj, err := p.reconcileSubject(obj)
 	if err != nil {
 		return nil, err
@@ -62,7 +67,7 @@ func (p *prtbLifecycle) Create(obj *v3.ProjectRoleTemplateBinding) (runtime.Obje
 }
 
 func (p *prtbLifecycle) Updated(obj *v3.ProjectRoleTemplateBinding) (runtime.Object, error) {
-	if obj.ServiceAccount != "" {
+	if obj.ServiceAccount != "" || pkgrbac.IsPRTBExpired(obj, time.Now()) {
 		return obj, nil
 	}
 	obj, err := p.reconcileSubject(obj)
@@ -83,6 +83,8 @@ func RegisterEarly(ctx context.Context, management *config.ManagementContext, cl
 	management.Management.Settings("").AddHandler(ctx, authSettingController, s.sync)
 	management.Management.GlobalRoleBindings("").AddHandler(ctx, "legacy-grb-cleaner", grbLegacy.sync)
 	management.Management.RoleTemplates("").AddHandler(ctx, "legacy-rt-cleaner", rtLegacy.sync)
+	registerBindingExpiry(ctx, management)
+	registerAccessRequests(ctx, management)
 	globalroles.Register(ctx, management, clusterManager)
 }
 
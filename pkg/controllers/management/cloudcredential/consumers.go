@@ -0,0 +1,322 @@
+package cloudcredential
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/features"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	rkecontrollers "github.com/rancher/rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/rancher/wrangler/v2/pkg/kv"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ByCloudCredential is the index of the objects that reference a cloud credential, by the ID of the credential of the
+// form namespace:name.
+const ByCloudCredential = "cloudcredential.cattle.io/by-cloud-credential"
+
+var infraMachineGroupVersion = schema.GroupVersion{Group: "rke-machine.cattle.io", Version: "v1"}
+
+// Consumer is an object that references a cloud credential.
+type Consumer struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Fields are the paths of the fields of the object that reference the credential.
+	Fields []string `json:"fields"`
+}
+
+// dynamicController is the part of the dynamic controller used to find and enqueue infrastructure machines.
+type dynamicController interface {
+	GetByIndex(gvk schema.GroupVersionKind, indexName, key string) ([]runtime.Object, error)
+	Enqueue(gvk schema.GroupVersionKind, namespace, name string) error
+}
+
+// ConsumerIndex finds the provisioning clusters, machine pools, infrastructure machines, etcd S3 configs, hosted clusters
+// and node templates that reference a cloud credential, and enqueues them to pick up changes of the credential.
+type ConsumerIndex struct {
+	provClusters  provisioningcontrollers.ClusterController
+	controlPlanes rkecontrollers.RKEControlPlaneController
+	clusters      managementcontrollers.ClusterController
+	nodeTemplates managementcontrollers.NodeTemplateController
+	dynamic       dynamicController
+}
+
+func NewConsumerIndex(wrangler *wrangler.Context) *ConsumerIndex {
+	return &ConsumerIndex{
+		provClusters:  wrangler.Provisioning.Cluster(),
+		controlPlanes: wrangler.RKE.RKEControlPlane(),
+		clusters:      wrangler.Mgmt.Cluster(),
+		nodeTemplates: wrangler.Mgmt.NodeTemplate(),
+		dynamic:       wrangler.Dynamic,
+	}
+}
+
+// RegisterIndexers registers the ByCloudCredential index of all the objects that can reference a cloud credential. It
+// must be called before the caches are started.
+func RegisterIndexers(wrangler *wrangler.Context) {
+	wrangler.Mgmt.Cluster().Cache().AddIndexer(ByCloudCredential, func(obj *apimgmtv3.Cluster) ([]string, error) {
+		return clusterReferences(obj).ids(), nil
+	})
+	wrangler.Mgmt.NodeTemplate().Cache().AddIndexer(ByCloudCredential, func(obj *apimgmtv3.NodeTemplate) ([]string, error) {
+		return nodeTemplateReferences(obj).ids(), nil
+	})
+	if !features.ProvisioningV2.Enabled() {
+		return
+	}
+	wrangler.Provisioning.Cluster().Cache().AddIndexer(ByCloudCredential, func(obj *provv1.Cluster) ([]string, error) {
+		return provClusterReferences(obj).ids(), nil
+	})
+	wrangler.RKE.RKEControlPlane().Cache().AddIndexer(ByCloudCredential, func(obj *rkev1.RKEControlPlane) ([]string, error) {
+		return controlPlaneReferences(obj).ids(), nil
+	})
+	wrangler.Dynamic.AddIndexer(ByCloudCredential, isInfraMachine, func(obj runtime.Object) ([]string, error) {
+		refs, err := infraMachineReferences(obj)
+		return refs.ids(), err
+	})
+}
+
+// isInfraMachine matches the infrastructure machines of node driver machine pools, see machineprovision.validGVK.
+func isInfraMachine(gvk schema.GroupVersionKind) bool {
+	return gvk.GroupVersion() == infraMachineGroupVersion &&
+		strings.HasSuffix(gvk.Kind, "Machine") &&
+		gvk.Kind != "CustomMachine"
+}
+
+// CredentialID returns the ID of the cloud credential referenced by ref from an object in the given namespace. The
+// reference is resolved like machineprovision.GetCloudCredentialSecret does: a global cloud credential if it is
+// prefixed with the global namespace, a secret in the namespace of the object otherwise.
+func CredentialID(objNamespace, ref string) string {
+	if ns, name := kv.Split(ref, ":"); name != "" && ns == namespace.GlobalNamespace {
+		return ref
+	}
+	if objNamespace == "" {
+		objNamespace = namespace.GlobalNamespace
+	}
+	return objNamespace + ":" + ref
+}
+
+// references are the fields that reference cloud credentials, by credential ID.
+type references map[string][]string
+
+func (r references) add(objNamespace, ref, field string) {
+	if ref == "" {
+		return
+	}
+	id := CredentialID(objNamespace, ref)
+	r[id] = append(r[id], field)
+}
+
+func (r references) ids() []string {
+	ids := make([]string, 0, len(r))
+	for id := range r {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func clusterReferences(cluster *apimgmtv3.Cluster) references {
+	refs := references{}
+	switch {
+	case cluster.Spec.EKSConfig != nil:
+		refs.add("", cluster.Spec.EKSConfig.AmazonCredentialSecret, "spec.eksConfig.amazonCredentialSecret")
+	case cluster.Spec.AKSConfig != nil:
+		refs.add("", cluster.Spec.AKSConfig.AzureCredentialSecret, "spec.aksConfig.azureCredentialSecret")
+	case cluster.Spec.GKEConfig != nil:
+		refs.add("", cluster.Spec.GKEConfig.GoogleCredentialSecret, "spec.gkeConfig.googleCredentialSecret")
+	}
+	return refs
+}
+
+func nodeTemplateReferences(nodeTemplate *apimgmtv3.NodeTemplate) references {
+	refs := references{}
+	refs.add(nodeTemplate.Namespace, nodeTemplate.Spec.CloudCredentialName, "spec.cloudCredentialName")
+	return refs
+}
+
+func provClusterReferences(cluster *provv1.Cluster) references {
+	refs := references{}
+	refs.add(cluster.Namespace, cluster.Spec.CloudCredentialSecretName, "spec.cloudCredentialSecretName")
+	if cluster.Spec.RKEConfig == nil {
+		return refs
+	}
+	for _, pool := range cluster.Spec.RKEConfig.MachinePools {
+		refs.add(cluster.Namespace, pool.CloudCredentialSecretName,
+			fmt.Sprintf("spec.rkeConfig.machinePools[%s].cloudCredentialSecretName", pool.Name))
+	}
+	etcdReferences(refs, cluster.Namespace, cluster.Spec.RKEConfig.ETCD, "spec.rkeConfig.etcd")
+	return refs
+}
+
+func controlPlaneReferences(controlPlane *rkev1.RKEControlPlane) references {
+	refs := references{}
+	etcdReferences(refs, controlPlane.Namespace, controlPlane.Spec.ETCD, "spec.etcd")
+	return refs
+}
+
+func etcdReferences(refs references, objNamespace string, etcd *rkev1.ETCD, path string) {
+	if etcd == nil {
+		return
+	}
+	if etcd.S3 != nil {
+		refs.add(objNamespace, etcd.S3.CloudCredentialName, path+".s3.cloudCredentialName")
+	}
+	for _, target := range etcd.SnapshotTargets {
+		if target.S3 != nil {
+			refs.add(objNamespace, target.S3.CloudCredentialName,
+				fmt.Sprintf("%s.snapshotTargets[%s].s3.cloudCredentialName", path, target.Name))
+		}
+	}
+}
+
+func infraMachineReferences(obj runtime.Object) (references, error) {
+	refs := references{}
+	machine, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return refs, nil
+	}
+	ref, _, err := unstructured.NestedString(machine.Object, "spec", "common", "cloudCredentialSecretName")
+	if err != nil {
+		return nil, err
+	}
+	refs.add(machine.GetNamespace(), ref, "spec.common.cloudCredentialSecretName")
+	return refs, nil
+}
+
+// Consumers returns the objects that reference the cloud credential with the given ID, of the form namespace:name.
+func (c *ConsumerIndex) Consumers(id string) ([]Consumer, error) {
+	var result []Consumer
+
+	if features.ProvisioningV2.Enabled() {
+		provClusters, err := c.provClusters.Cache().GetByIndex(ByCloudCredential, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range provClusters {
+			result = append(result, newConsumer(provv1.SchemeGroupVersion.String(), "Cluster", cluster.Namespace, cluster.Name, provClusterReferences(cluster)[id]))
+		}
+
+		// machines are only looked up for the kinds of the machine pools of the clusters using the credential, the
+		// pools may default to the credential of their cluster
+		for _, gvk := range machineKinds(provClusters) {
+			machines, err := c.dynamic.GetByIndex(gvk, ByCloudCredential, id)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range machines {
+				machine, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				refs, err := infraMachineReferences(machine)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, newConsumer(gvk.GroupVersion().String(), gvk.Kind, machine.GetNamespace(), machine.GetName(), refs[id]))
+			}
+		}
+
+		controlPlanes, err := c.controlPlanes.Cache().GetByIndex(ByCloudCredential, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, controlPlane := range controlPlanes {
+			result = append(result, newConsumer(rkev1.SchemeGroupVersion.String(), "RKEControlPlane", controlPlane.Namespace, controlPlane.Name, controlPlaneReferences(controlPlane)[id]))
+		}
+	}
+
+	clusters, err := c.clusters.Cache().GetByIndex(ByCloudCredential, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		result = append(result, newConsumer(apimgmtv3.SchemeGroupVersion.String(), "Cluster", "", cluster.Name, clusterReferences(cluster)[id]))
+	}
+
+	nodeTemplates, err := c.nodeTemplates.Cache().GetByIndex(ByCloudCredential, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, nodeTemplate := range nodeTemplates {
+		result = append(result, newConsumer(apimgmtv3.SchemeGroupVersion.String(), "NodeTemplate", nodeTemplate.Namespace, nodeTemplate.Name, nodeTemplateReferences(nodeTemplate)[id]))
+	}
+
+	return result, nil
+}
+
+func newConsumer(apiVersion, kind, namespace, name string, fields []string) Consumer {
+	return Consumer{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+		Fields:     fields,
+	}
+}
+
+// machineKinds returns the kinds of the infrastructure machines of the machine pools of the clusters.
+func machineKinds(clusters []*provv1.Cluster) []schema.GroupVersionKind {
+	seen := map[string]bool{}
+	var result []schema.GroupVersionKind
+	for _, cluster := range clusters {
+		if cluster.Spec.RKEConfig == nil {
+			continue
+		}
+		for _, pool := range cluster.Spec.RKEConfig.MachinePools {
+			if pool.NodeConfig == nil || !strings.HasSuffix(pool.NodeConfig.Kind, "Config") {
+				continue
+			}
+			kind := strings.TrimSuffix(pool.NodeConfig.Kind, "Config") + "Machine"
+			if seen[kind] {
+				continue
+			}
+			seen[kind] = true
+			result = append(result, infraMachineGroupVersion.WithKind(kind))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Kind < result[j].Kind
+	})
+	return result
+}
+
+// Enqueue enqueues the consumers of a cloud credential so that their controllers pick up the changed credential:
+// provisioning clusters regenerate their machine templates, infrastructure machines rerun their provisioning jobs with
+// the new credential and control planes recompute the etcd S3 arguments of their plans.
+func (c *ConsumerIndex) Enqueue(consumers []Consumer) error {
+	var errs []error
+	for _, consumer := range consumers {
+		switch {
+		case consumer.APIVersion == provv1.SchemeGroupVersion.String() && consumer.Kind == "Cluster":
+			c.provClusters.Enqueue(consumer.Namespace, consumer.Name)
+		case consumer.APIVersion == rkev1.SchemeGroupVersion.String() && consumer.Kind == "RKEControlPlane":
+			c.controlPlanes.Enqueue(consumer.Namespace, consumer.Name)
+		case consumer.APIVersion == apimgmtv3.SchemeGroupVersion.String() && consumer.Kind == "Cluster":
+			c.clusters.Enqueue(consumer.Name)
+		case consumer.APIVersion == apimgmtv3.SchemeGroupVersion.String() && consumer.Kind == "NodeTemplate":
+			c.nodeTemplates.Enqueue(consumer.Namespace, consumer.Name)
+		default:
+			gvk := schema.FromAPIVersionAndKind(consumer.APIVersion, consumer.Kind)
+			if !isInfraMachine(gvk) {
+				errs = append(errs, fmt.Errorf("unknown consumer kind %s", gvk))
+				continue
+			}
+			if err := c.dynamic.Enqueue(gvk, consumer.Namespace, consumer.Name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
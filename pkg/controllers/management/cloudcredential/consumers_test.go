@@ -0,0 +1,177 @@
+package cloudcredential
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	eksv1 "github.com/rancher/eks-operator/pkg/apis/eks.cattle.io/v1"
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeDynamic struct {
+	machines map[schema.GroupVersionKind][]runtime.Object
+	enqueued []string
+}
+
+func (f *fakeDynamic) GetByIndex(gvk schema.GroupVersionKind, indexName, key string) ([]runtime.Object, error) {
+	var result []runtime.Object
+	for _, obj := range f.machines[gvk] {
+		refs, err := infraMachineReferences(obj)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := refs[key]; ok && indexName == ByCloudCredential {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeDynamic) Enqueue(gvk schema.GroupVersionKind, namespace, name string) error {
+	f.enqueued = append(f.enqueued, gvk.Kind+" "+namespace+"/"+name)
+	return nil
+}
+
+func newMachine(name, credential string) runtime.Object {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rke-machine.cattle.io/v1",
+		"kind":       "Amazonec2Machine",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "fleet-default"},
+		"spec": map[string]interface{}{
+			"common": map[string]interface{}{"cloudCredentialSecretName": credential},
+		},
+	}}
+}
+
+func TestCredentialID(t *testing.T) {
+	assert.Equal(t, "cattle-global-data:cc-abc", CredentialID("fleet-default", "cattle-global-data:cc-abc"))
+	assert.Equal(t, "fleet-default:s3-creds", CredentialID("fleet-default", "s3-creds"))
+	assert.Equal(t, "fleet-default:other:s3-creds", CredentialID("fleet-default", "other:s3-creds"))
+	assert.Equal(t, "cattle-global-data:cc-abc", CredentialID("", "cc-abc"))
+}
+
+func TestProvClusterReferences(t *testing.T) {
+	cluster := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "fleet-default"},
+		Spec: provv1.ClusterSpec{
+			CloudCredentialSecretName: "cattle-global-data:cc-abc",
+			RKEConfig: &provv1.RKEConfig{
+				MachinePools: []provv1.RKEMachinePool{
+					{Name: "pool1"},
+					{Name: "pool2", RKECommonNodeConfig: rkev1.RKECommonNodeConfig{CloudCredentialSecretName: "cattle-global-data:cc-def"}},
+				},
+				RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+					ETCD: &rkev1.ETCD{
+						S3: &rkev1.ETCDSnapshotS3{CloudCredentialName: "cattle-global-data:cc-abc"},
+						SnapshotTargets: []rkev1.ETCDSnapshotTarget{
+							{Name: "offsite", S3: &rkev1.ETCDSnapshotS3{CloudCredentialName: "s3-creds"}},
+							{Name: "local"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, references{
+		"cattle-global-data:cc-abc": {"spec.cloudCredentialSecretName", "spec.rkeConfig.etcd.s3.cloudCredentialName"},
+		"cattle-global-data:cc-def": {"spec.rkeConfig.machinePools[pool2].cloudCredentialSecretName"},
+		"fleet-default:s3-creds":    {"spec.rkeConfig.etcd.snapshotTargets[offsite].s3.cloudCredentialName"},
+	}, provClusterReferences(cluster))
+	assert.Equal(t, []string{"cattle-global-data:cc-abc", "cattle-global-data:cc-def", "fleet-default:s3-creds"}, provClusterReferences(cluster).ids())
+}
+
+func TestConsumersAndEnqueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	const id = "cattle-global-data:cc-abc"
+
+	provCluster := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "fleet-default"},
+		Spec: provv1.ClusterSpec{
+			RKEConfig: &provv1.RKEConfig{
+				MachinePools: []provv1.RKEMachinePool{
+					{Name: "pool1", NodeConfig: &corev1.ObjectReference{Kind: "Amazonec2Config"}, RKECommonNodeConfig: rkev1.RKECommonNodeConfig{CloudCredentialSecretName: id}},
+				},
+			},
+		},
+	}
+	provClusters := fake.NewMockControllerInterface[*provv1.Cluster, *provv1.ClusterList](ctrl)
+	provClusterCache := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+	provClusterCache.EXPECT().GetByIndex(ByCloudCredential, id).Return([]*provv1.Cluster{provCluster}, nil)
+	provClusters.EXPECT().Cache().Return(provClusterCache)
+
+	controlPlane := &rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "fleet-default"},
+		Spec: rkev1.RKEControlPlaneSpec{RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+			ETCD: &rkev1.ETCD{S3: &rkev1.ETCDSnapshotS3{CloudCredentialName: id}},
+		}},
+	}
+	controlPlanes := fake.NewMockControllerInterface[*rkev1.RKEControlPlane, *rkev1.RKEControlPlaneList](ctrl)
+	controlPlaneCache := fake.NewMockCacheInterface[*rkev1.RKEControlPlane](ctrl)
+	controlPlaneCache.EXPECT().GetByIndex(ByCloudCredential, id).Return([]*rkev1.RKEControlPlane{controlPlane}, nil)
+	controlPlanes.EXPECT().Cache().Return(controlPlaneCache)
+
+	cluster := &apimgmtv3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-abc"},
+		Spec: apimgmtv3.ClusterSpec{
+			EKSConfig: &eksv1.EKSClusterConfigSpec{AmazonCredentialSecret: id},
+		},
+	}
+	clusters := fake.NewMockNonNamespacedControllerInterface[*apimgmtv3.Cluster, *apimgmtv3.ClusterList](ctrl)
+	clusterCache := fake.NewMockNonNamespacedCacheInterface[*apimgmtv3.Cluster](ctrl)
+	clusterCache.EXPECT().GetByIndex(ByCloudCredential, id).Return([]*apimgmtv3.Cluster{cluster}, nil)
+	clusters.EXPECT().Cache().Return(clusterCache)
+
+	nodeTemplate := &apimgmtv3.NodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "nt-abc", Namespace: "cattle-global-nt"},
+		Spec:       apimgmtv3.NodeTemplateSpec{CloudCredentialName: id},
+	}
+	nodeTemplates := fake.NewMockControllerInterface[*apimgmtv3.NodeTemplate, *apimgmtv3.NodeTemplateList](ctrl)
+	nodeTemplateCache := fake.NewMockCacheInterface[*apimgmtv3.NodeTemplate](ctrl)
+	nodeTemplateCache.EXPECT().GetByIndex(ByCloudCredential, id).Return([]*apimgmtv3.NodeTemplate{nodeTemplate}, nil)
+	nodeTemplates.EXPECT().Cache().Return(nodeTemplateCache)
+
+	dynamic := &fakeDynamic{machines: map[schema.GroupVersionKind][]runtime.Object{
+		infraMachineGroupVersion.WithKind("Amazonec2Machine"): {
+			newMachine("c1-pool1-abc", id),
+			newMachine("c2-pool1-def", "cattle-global-data:cc-other"),
+		},
+	}}
+
+	index := &ConsumerIndex{
+		provClusters:  provClusters,
+		controlPlanes: controlPlanes,
+		clusters:      clusters,
+		nodeTemplates: nodeTemplates,
+		dynamic:       dynamic,
+	}
+
+	consumers, err := index.Consumers(id)
+	require.NoError(t, err)
+	assert.Equal(t, []Consumer{
+		{APIVersion: "provisioning.cattle.io/v1", Kind: "Cluster", Namespace: "fleet-default", Name: "c1", Fields: []string{"spec.rkeConfig.machinePools[pool1].cloudCredentialSecretName"}},
+		{APIVersion: "rke-machine.cattle.io/v1", Kind: "Amazonec2Machine", Namespace: "fleet-default", Name: "c1-pool1-abc", Fields: []string{"spec.common.cloudCredentialSecretName"}},
+		{APIVersion: "rke.cattle.io/v1", Kind: "RKEControlPlane", Namespace: "fleet-default", Name: "c1", Fields: []string{"spec.etcd.s3.cloudCredentialName"}},
+		{APIVersion: "management.cattle.io/v3", Kind: "Cluster", Name: "c-abc", Fields: []string{"spec.eksConfig.amazonCredentialSecret"}},
+		{APIVersion: "management.cattle.io/v3", Kind: "NodeTemplate", Namespace: "cattle-global-nt", Name: "nt-abc", Fields: []string{"spec.cloudCredentialName"}},
+	}, consumers)
+
+	provClusters.EXPECT().Enqueue("fleet-default", "c1")
+	controlPlanes.EXPECT().Enqueue("fleet-default", "c1")
+	clusters.EXPECT().Enqueue("c-abc")
+	nodeTemplates.EXPECT().Enqueue("cattle-global-nt", "nt-abc")
+	require.NoError(t, index.Enqueue(consumers))
+	assert.Equal(t, []string{"Amazonec2Machine fleet-default/c1-pool1-abc"}, dynamic.enqueued)
+
+	assert.Error(t, index.Enqueue([]Consumer{{APIVersion: "v1", Kind: "Pod", Name: "p"}}))
+}
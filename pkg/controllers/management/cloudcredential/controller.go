@@ -25,6 +25,8 @@ func Register(ctx context.Context, management *config.ManagementContext) {
 		managementContext: management,
 	}
 	management.Core.Secrets("").AddHandler(ctx, "management-cloudcredential-controller", m.ccSync)
+	registerValidation(ctx, management.Wrangler)
+	registerRotation(ctx, management.Wrangler)
 }
 
 func (n *Controller) ccSync(key string, cloudCredential *v1.Secret) (runtime.Object, error) {
@@ -0,0 +1,117 @@
+package cloudcredential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type consumerIndex interface {
+	Consumers(id string) ([]Consumer, error)
+	Enqueue(consumers []Consumer) error
+}
+
+// rotationController enqueues the objects that reference a cloud credential when its fields change. The hashes of the
+// fields are only kept in memory and nothing is written to the credentials: the controllers of the objects sync every
+// object when they start, so changes made before a credential is first seen are picked up either way.
+type rotationController struct {
+	consumers consumerIndex
+
+	lock   sync.Mutex
+	hashes map[string]string
+}
+
+func registerRotation(ctx context.Context, wrangler *wrangler.Context) {
+	r := &rotationController{
+		consumers: NewConsumerIndex(wrangler),
+		hashes:    map[string]string{},
+	}
+	wrangler.Core.Secret().OnChange(ctx, "management-cloudcredential-rotation", r.sync)
+}
+
+// sync enqueues the objects that reference a cloud credential when its fields changed. Handlers run once the secret
+// cache holds the changed fields, so the controllers of the objects read the new fields from the cache.
+func (r *rotationController) sync(key string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil {
+		r.lock.Lock()
+		delete(r.hashes, key)
+		r.lock.Unlock()
+		return secret, nil
+	}
+	if credentialType, _ := credentialFields(secret.Data); credentialType == "" {
+		return secret, nil
+	}
+	hash := fieldsHash(secret.Data)
+	r.lock.Lock()
+	previous, ok := r.hashes[key]
+	r.lock.Unlock()
+	if previous == hash {
+		return secret, nil
+	}
+
+	// credentials seen for the first time are new or changed before this replica started, the objects referencing them
+	// already use their fields
+	if ok {
+		id := secret.Namespace + ":" + secret.Name
+		consumers, err := r.consumers.Consumers(id)
+		if err != nil {
+			return secret, err
+		}
+		logrus.Infof("[cloud-credential-rotation] Fields of cloud credential %s changed, enqueueing %d objects referencing it", id, len(consumers))
+		if err := r.consumers.Enqueue(consumers); err != nil {
+			return secret, fmt.Errorf("failed to enqueue objects referencing cloud credential %s: %w", id, err)
+		}
+	}
+
+	r.lock.Lock()
+	r.hashes[key] = hash
+	r.lock.Unlock()
+	return secret, nil
+}
+
+// Rotate returns a copy of the cloud credential with the given fields replaced, the other fields keep their values.
+// Only fields the credential already has can be replaced. If there is a validator for the type of the credential, the
+// new fields are rejected with an InvalidError if the provider does not accept them. Fields are still replaced if the
+// provider could not be reached.
+func Rotate(ctx context.Context, secret *corev1.Secret, fields map[string]string, validators map[string]Validator) (*corev1.Secret, error) {
+	credentialType, current := credentialFields(secret.Data)
+	if credentialType == "" {
+		return nil, fmt.Errorf("secret %s/%s is not a cloud credential", secret.Namespace, secret.Name)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to rotate")
+	}
+	for field := range fields {
+		if field == "" || strings.Contains(field, "-") {
+			return nil, fmt.Errorf("invalid field name %q", field)
+		}
+		if _, ok := current[field]; !ok {
+			return nil, fmt.Errorf("cloud credential %s/%s of type %s has no field %q", secret.Namespace, secret.Name, credentialType, field)
+		}
+		current[field] = fields[field]
+	}
+
+	if validator, ok := validators[credentialType]; ok {
+		ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+		_, err := validator.Validate(ctx, current)
+		cancel()
+		var invalidErr *InvalidError
+		if errors.As(err, &invalidErr) {
+			return nil, err
+		} else if err != nil {
+			logrus.Warnf("[cloud-credential-rotation] Unable to validate rotated fields of cloud credential %s/%s of type %s: %v", secret.Namespace, secret.Name, credentialType, err)
+		}
+	}
+
+	secret = secret.DeepCopy()
+	for field, value := range fields {
+		secret.Data[credentialType+"credentialConfig-"+field] = []byte(value)
+	}
+	return secret, nil
+}
@@ -0,0 +1,106 @@
+package cloudcredential
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type fakeConsumerIndex struct {
+	consumers []Consumer
+	enqueued  int
+}
+
+func (f *fakeConsumerIndex) Consumers(id string) ([]Consumer, error) {
+	return f.consumers, nil
+}
+
+func (f *fakeConsumerIndex) Enqueue(consumers []Consumer) error {
+	f.enqueued += len(consumers)
+	return nil
+}
+
+func TestRotationSync(t *testing.T) {
+	index := &fakeConsumerIndex{consumers: []Consumer{
+		{APIVersion: "provisioning.cattle.io/v1", Kind: "Cluster", Namespace: "fleet-default", Name: "c1"},
+	}}
+	r := &rotationController{consumers: index, hashes: map[string]string{}}
+	const key = "cattle-global-data/cc-abc"
+
+	// new credentials are recorded without enqueueing anything or updating them
+	secret := newCredential()
+	_, err := r.sync(key, secret)
+	require.NoError(t, err)
+	assert.Empty(t, secret.Annotations)
+	assert.Equal(t, 0, index.enqueued)
+
+	// unchanged fields are not propagated
+	_, err = r.sync(key, secret)
+	require.NoError(t, err)
+	assert.Equal(t, 0, index.enqueued)
+
+	// changed fields are propagated once
+	secret.Data["digitaloceancredentialConfig-accessToken"] = []byte("def")
+	_, err = r.sync(key, secret)
+	require.NoError(t, err)
+	assert.Equal(t, 1, index.enqueued)
+	_, err = r.sync(key, secret)
+	require.NoError(t, err)
+	assert.Equal(t, 1, index.enqueued)
+
+	// deleted credentials are forgotten
+	_, err = r.sync(key, nil)
+	require.NoError(t, err)
+	assert.Empty(t, r.hashes)
+
+	// secrets that are not cloud credentials are ignored
+	_, err = r.sync("default/other", &corev1.Secret{Data: map[string][]byte{"token": []byte("abc")}})
+	require.NoError(t, err)
+	assert.Empty(t, r.hashes)
+}
+
+func TestRotate(t *testing.T) {
+	validators := map[string]Validator{
+		"digitalocean": ValidatorFunc(func(_ context.Context, fields map[string]string) (time.Time, error) {
+			switch fields["accessToken"] {
+			case "good":
+				return time.Time{}, nil
+			case "unreachable":
+				return time.Time{}, errors.New("connection refused")
+			}
+			return time.Time{}, invalid("unauthorized")
+		}),
+	}
+	secret := newCredential()
+	secret.Data["digitaloceancredentialConfig-region"] = []byte("nyc1")
+
+	rotated, err := Rotate(context.Background(), secret, map[string]string{"accessToken": "good"}, validators)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"digitaloceancredentialConfig-accessToken": []byte("good"),
+		"digitaloceancredentialConfig-region":      []byte("nyc1"),
+	}, rotated.Data)
+	assert.Equal(t, "abc", string(secret.Data["digitaloceancredentialConfig-accessToken"]), "the original secret must not be changed")
+
+	// the fields are rotated if the provider cannot be reached
+	_, err = Rotate(context.Background(), secret, map[string]string{"accessToken": "unreachable"}, validators)
+	assert.NoError(t, err)
+
+	_, err = Rotate(context.Background(), secret, map[string]string{"accessToken": "revoked"}, validators)
+	assertInvalid(t, err)
+
+	_, err = Rotate(context.Background(), secret, nil, validators)
+	assert.Error(t, err)
+	_, err = Rotate(context.Background(), secret, map[string]string{"access-token": "good"}, validators)
+	assert.Error(t, err)
+	// fields the credential does not have are rejected, so that misspelled fields are not added
+	_, err = Rotate(context.Background(), secret, map[string]string{"accesToken": "good"}, validators)
+	assert.ErrorContains(t, err, `has no field "accesToken"`)
+	_, err = Rotate(context.Background(), &corev1.Secret{Data: map[string][]byte{"token": []byte("abc")}}, map[string]string{"accessToken": "good"}, validators)
+	assert.Error(t, err)
+}
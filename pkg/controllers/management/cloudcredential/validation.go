@@ -0,0 +1,466 @@
+package cloudcredential
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher/pkg/features"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/rancher/wrangler/v2/pkg/condition"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v2/pkg/genericcondition"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// CredentialValidated is the condition of a cloud credential that records whether its provider accepted it. Its
+	// last update time is the time the credential was last validated.
+	CredentialValidated = condition.Cond("Validated")
+	// CredentialExpired is the condition of a cloud credential whose expiry is known, with the expiry in its message.
+	CredentialExpired = condition.Cond("Expired")
+	// CloudCredentialValid is the condition of clusters and node templates that warns that the cloud credential they
+	// reference was rejected by its provider or expired.
+	CloudCredentialValid = condition.Cond("CloudCredentialValid")
+
+	// ExpiresAtAnnotation is the time a cloud credential expires at, if it is known.
+	ExpiresAtAnnotation = "cloudcredential.cattle.io/expires-at"
+	// validatedHashAnnotation is the hash of the fields of a cloud credential at its last validation, so that changed
+	// fields are validated right away.
+	validatedHashAnnotation = "cloudcredential.cattle.io/validated-hash"
+	// statusAnnotation holds the conditions of objects without status, such as secrets.
+	statusAnnotation = "cattle.io/status"
+
+	reasonInvalid         = "Invalid"
+	reasonValidationError = "ValidationError"
+	reasonExpiresSoon     = "ExpiresSoon"
+
+	validationTimeout = 30 * time.Second
+	// retryInterval is how long to wait before validating a credential again that could not be tested.
+	retryInterval = 5 * time.Minute
+	// expiryWarningPeriod is how long before its expiry a credential is reported to expire soon.
+	expiryWarningPeriod = 7 * 24 * time.Hour
+	// validationWorkers is how many credentials are validated at the same time. Validations call the providers and
+	// run on their own workers, so that unreachable providers do not hold up the workers of the secret controller.
+	validationWorkers = 5
+	// validationQueueSize is how many validations may wait for a worker, others are retried after queueRetryInterval.
+	validationQueueSize = 100
+	queueRetryInterval  = 10 * time.Second
+)
+
+// credentialStatus is the JSON of the status annotation of a cloud credential.
+type credentialStatus struct {
+	Conditions []genericcondition.GenericCondition `json:"conditions"`
+}
+
+// validationJob is the validation of the fields of a cloud credential with the given hash.
+type validationJob struct {
+	namespace      string
+	name           string
+	credentialType string
+	validator      Validator
+	fields         map[string]string
+	hash           string
+}
+
+// validationResult is the result of a validationJob, kept until it is saved to the credential.
+type validationResult struct {
+	hash   string
+	expiry time.Time
+	err    error
+	at     time.Time
+}
+
+type validationController struct {
+	ctx           context.Context
+	secrets       corecontrollers.SecretController
+	provClusters  provisioningcontrollers.ClusterController
+	clusters      managementcontrollers.ClusterController
+	nodeTemplates managementcontrollers.NodeTemplateController
+	validators    map[string]Validator
+	now           func() time.Time
+
+	jobs    chan validationJob
+	lock    sync.Mutex
+	running map[string]bool
+	results map[string]validationResult
+}
+
+func newValidationController(ctx context.Context, wrangler *wrangler.Context) *validationController {
+	return &validationController{
+		ctx:           ctx,
+		secrets:       wrangler.Core.Secret(),
+		provClusters:  wrangler.Provisioning.Cluster(),
+		clusters:      wrangler.Mgmt.Cluster(),
+		nodeTemplates: wrangler.Mgmt.NodeTemplate(),
+		validators:    NewValidators(wrangler.Mgmt.Token().Cache()),
+		now:           time.Now,
+		jobs:          make(chan validationJob, validationQueueSize),
+		running:       map[string]bool{},
+		results:       map[string]validationResult{},
+	}
+}
+
+func registerValidation(ctx context.Context, wrangler *wrangler.Context) {
+	v := newValidationController(ctx, wrangler)
+	for i := 0; i < validationWorkers; i++ {
+		go v.worker()
+	}
+	wrangler.Core.Secret().OnChange(ctx, "management-cloudcredential-validator", v.sync)
+}
+
+// sync validates cloud credentials when their fields changed or their last validation is older than the validation
+// interval, and warns the clusters and node templates that reference them about the result. Validations run on the
+// validation workers, which enqueue the credential again to save their result.
+func (v *validationController) sync(key string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil {
+		v.lock.Lock()
+		delete(v.results, key)
+		v.lock.Unlock()
+		return secret, nil
+	}
+	if secret.Namespace != namespace.GlobalNamespace {
+		return secret, nil
+	}
+	credentialType, fields := credentialFields(secret.Data)
+	validator, ok := v.validators[credentialType]
+	if !ok {
+		return secret, nil
+	}
+	interval, err := time.ParseDuration(settings.CloudCredentialValidationInterval.Get())
+	if err != nil {
+		return secret, fmt.Errorf("invalid %s setting: %w", settings.CloudCredentialValidationInterval.Name, err)
+	}
+	if interval <= 0 {
+		return secret, nil
+	}
+
+	now := v.now()
+	hash := fieldsHash(secret.Data)
+	if secret.Annotations[validatedHashAnnotation] != hash || v.nextValidation(secret, interval, now) <= 0 {
+		key := secret.Namespace + "/" + secret.Name
+		v.lock.Lock()
+		result, ok := v.results[key]
+		v.lock.Unlock()
+		if !ok || result.hash != hash {
+			// the credential is enqueued again once it is validated
+			v.submit(validationJob{
+				namespace:      secret.Namespace,
+				name:           secret.Name,
+				credentialType: credentialType,
+				validator:      validator,
+				fields:         fields,
+				hash:           hash,
+			})
+			return secret, nil
+		}
+		if secret, err = v.apply(secret, credentialType, result); err != nil {
+			return secret, err
+		}
+		v.lock.Lock()
+		if v.results[key].hash == hash {
+			delete(v.results, key)
+		}
+		v.lock.Unlock()
+	}
+
+	if err := v.warnDependents(secret); err != nil {
+		return secret, err
+	}
+	v.secrets.EnqueueAfter(secret.Namespace, secret.Name, v.nextValidation(secret, interval, now))
+	return secret, nil
+}
+
+// submit queues the validation of a credential, unless it is already being validated.
+func (v *validationController) submit(job validationJob) {
+	key := job.namespace + "/" + job.name
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.running[key] {
+		return
+	}
+	select {
+	case v.jobs <- job:
+		v.running[key] = true
+	default:
+		v.secrets.EnqueueAfter(job.namespace, job.name, queueRetryInterval)
+	}
+}
+
+func (v *validationController) worker() {
+	for {
+		select {
+		case <-v.ctx.Done():
+			return
+		case job := <-v.jobs:
+			v.run(job)
+		}
+	}
+}
+
+// run validates the fields of a credential and enqueues the credential to save the result.
+func (v *validationController) run(job validationJob) {
+	ctx, cancel := context.WithTimeout(v.ctx, validationTimeout)
+	expiry, err := job.validator.Validate(ctx, job.fields)
+	cancel()
+
+	key := job.namespace + "/" + job.name
+	v.lock.Lock()
+	delete(v.running, key)
+	v.results[key] = validationResult{
+		hash:   job.hash,
+		expiry: expiry,
+		err:    err,
+		at:     v.now(),
+	}
+	v.lock.Unlock()
+	v.secrets.Enqueue(job.namespace, job.name)
+}
+
+// apply saves the result of the validation of the credential to its status.
+func (v *validationController) apply(secret *corev1.Secret, credentialType string, result validationResult) (*corev1.Secret, error) {
+	expiry, validationErr, now := result.expiry, result.err, result.at
+
+	secret = secret.DeepCopy()
+	status, err := readStatus(secret)
+	if err != nil {
+		logrus.Warnf("[cloud-credential-validator] Resetting invalid status of cloud credential %s/%s: %v", secret.Namespace, secret.Name, err)
+		status = &credentialStatus{}
+	}
+
+	var invalidErr *InvalidError
+	switch {
+	case validationErr == nil:
+		CredentialValidated.SetStatus(status, "True")
+		CredentialValidated.Reason(status, "")
+		CredentialValidated.Message(status, "")
+	case errors.As(validationErr, &invalidErr):
+		logrus.Infof("[cloud-credential-validator] Cloud credential %s/%s of type %s is invalid: %v", secret.Namespace, secret.Name, credentialType, validationErr)
+		CredentialValidated.SetStatus(status, "False")
+		CredentialValidated.Reason(status, reasonInvalid)
+		CredentialValidated.Message(status, validationErr.Error())
+	default:
+		logrus.Warnf("[cloud-credential-validator] Unable to validate cloud credential %s/%s of type %s: %v", secret.Namespace, secret.Name, credentialType, validationErr)
+		CredentialValidated.SetStatus(status, "Unknown")
+		CredentialValidated.Reason(status, reasonValidationError)
+		CredentialValidated.Message(status, validationErr.Error())
+	}
+	CredentialValidated.LastUpdated(status, now.UTC().Format(time.RFC3339))
+
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	if expiry.IsZero() {
+		delete(secret.Annotations, ExpiresAtAnnotation)
+		removeCondition(status, CredentialExpired)
+	} else {
+		secret.Annotations[ExpiresAtAnnotation] = expiry.UTC().Format(time.RFC3339)
+		setExpired(status, expiry, now)
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	secret.Annotations[statusAnnotation] = string(data)
+	secret.Annotations[validatedHashAnnotation] = result.hash
+	return v.secrets.Update(secret)
+}
+
+func setExpired(status *credentialStatus, expiry, now time.Time) {
+	expiresAt := expiry.UTC().Format(time.RFC3339)
+	switch {
+	case !now.Before(expiry):
+		CredentialExpired.SetStatus(status, "True")
+		CredentialExpired.Reason(status, "")
+		CredentialExpired.Message(status, "expired at "+expiresAt)
+	case expiry.Sub(now) < expiryWarningPeriod:
+		CredentialExpired.SetStatus(status, "False")
+		CredentialExpired.Reason(status, reasonExpiresSoon)
+		CredentialExpired.Message(status, "expires at "+expiresAt)
+	default:
+		CredentialExpired.SetStatus(status, "False")
+		CredentialExpired.Reason(status, "")
+		CredentialExpired.Message(status, "expires at "+expiresAt)
+	}
+}
+
+// nextValidation returns how long to wait until the credential has to be validated again: after the validation
+// interval, sooner if it could not be tested, and once it expires or enters the expiry warning period.
+func (v *validationController) nextValidation(secret *corev1.Secret, interval time.Duration, now time.Time) time.Duration {
+	status, err := readStatus(secret)
+	if err != nil {
+		return 0
+	}
+	lastValidated, err := time.Parse(time.RFC3339, CredentialValidated.GetLastUpdated(status))
+	if err != nil {
+		return 0
+	}
+	if CredentialValidated.GetStatus(status) == "Unknown" && retryInterval < interval {
+		interval = retryInterval
+	}
+	next := lastValidated.Add(interval).Sub(now)
+	if expiry, err := time.Parse(time.RFC3339, secret.Annotations[ExpiresAtAnnotation]); err == nil {
+		for _, at := range []time.Time{expiry.Add(-expiryWarningPeriod), expiry} {
+			if wait := at.Sub(now); wait > 0 && wait < next {
+				next = wait
+			}
+		}
+	}
+	return next
+}
+
+// brokenMessage returns why the credential must not be used, or an empty string if it is not known to be broken.
+func brokenMessage(secret *corev1.Secret) string {
+	status, err := readStatus(secret)
+	if err != nil {
+		return ""
+	}
+	id := secret.Namespace + ":" + secret.Name
+	if CredentialExpired.IsTrue(status) {
+		return fmt.Sprintf("cloud credential %s %s", id, CredentialExpired.GetMessage(status))
+	}
+	if CredentialValidated.IsFalse(status) {
+		return fmt.Sprintf("cloud credential %s is invalid: %s", id, CredentialValidated.GetMessage(status))
+	}
+	return ""
+}
+
+// warnDependents sets the CloudCredentialValid condition of the clusters and node templates that reference the
+// credential. Objects without the condition are only updated once the credential is broken.
+func (v *validationController) warnDependents(secret *corev1.Secret) error {
+	id := secret.Namespace + ":" + secret.Name
+	message := brokenMessage(secret)
+	var errs []error
+
+	if features.ProvisioningV2.Enabled() {
+		provClusters, err := v.provClusters.Cache().GetByIndex(ByCloudCredential, id)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range provClusters {
+			if !needsWarning(cluster, message) {
+				continue
+			}
+			cluster = cluster.DeepCopy()
+			setWarning(cluster, message)
+			if _, err := v.provClusters.UpdateStatus(cluster); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	clusters, err := v.clusters.Cache().GetByIndex(ByCloudCredential, id)
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		if !needsWarning(cluster, message) {
+			continue
+		}
+		cluster = cluster.DeepCopy()
+		setWarning(cluster, message)
+		if _, err := v.clusters.Update(cluster); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	nodeTemplates, err := v.nodeTemplates.Cache().GetByIndex(ByCloudCredential, id)
+	if err != nil {
+		return err
+	}
+	for _, nodeTemplate := range nodeTemplates {
+		if !needsWarning(nodeTemplate, message) {
+			continue
+		}
+		nodeTemplate = nodeTemplate.DeepCopy()
+		setWarning(nodeTemplate, message)
+		if _, err := v.nodeTemplates.Update(nodeTemplate); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to update objects referencing cloud credential %s: %v", id, errs)
+	}
+	return nil
+}
+
+// needsWarning returns true if the CloudCredentialValid condition of obj does not match the message of the broken
+// credential, an empty message meaning that the credential is fine.
+func needsWarning(obj interface{}, message string) bool {
+	if message == "" {
+		return CloudCredentialValid.IsFalse(obj)
+	}
+	return !CloudCredentialValid.IsFalse(obj) || CloudCredentialValid.GetMessage(obj) != message
+}
+
+func setWarning(obj interface{}, message string) {
+	CloudCredentialValid.SetStatusBool(obj, message == "")
+	CloudCredentialValid.Message(obj, message)
+}
+
+// credentialFields returns the type of the cloud credential and its fields, from keys of the form
+// <type>credentialConfig-<field>. The type is empty if data is not a cloud credential.
+func credentialFields(data map[string][]byte) (string, map[string]string) {
+	var credentialType string
+	fields := map[string]string{}
+	for key, value := range data {
+		prefix, field, ok := strings.Cut(key, "credentialConfig-")
+		if !ok || prefix == "" || strings.Contains(field, "-") {
+			continue
+		}
+		credentialType = prefix
+		fields[field] = string(value)
+	}
+	return credentialType, fields
+}
+
+// fieldsHash returns a hash of all the data of the secret.
+func fieldsHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write(data[key])
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func readStatus(secret *corev1.Secret) (*credentialStatus, error) {
+	status := &credentialStatus{}
+	if data := secret.Annotations[statusAnnotation]; data != "" {
+		if err := json.Unmarshal([]byte(data), status); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func removeCondition(status *credentialStatus, cond condition.Cond) {
+	conditions := status.Conditions[:0]
+	for _, c := range status.Conditions {
+		if c.Type != string(cond) {
+			conditions = append(conditions, c)
+		}
+	}
+	status.Conditions = conditions
+}
@@ -0,0 +1,375 @@
+package cloudcredential
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	eksv1 "github.com/rancher/eks-operator/pkg/apis/eks.cattle.io/v1"
+	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+type testController struct {
+	*validationController
+	secrets       *fake.MockControllerInterface[*corev1.Secret, *corev1.SecretList]
+	provClusters  *fake.MockControllerInterface[*provv1.Cluster, *provv1.ClusterList]
+	clusters      *fake.MockNonNamespacedControllerInterface[*apimgmtv3.Cluster, *apimgmtv3.ClusterList]
+	nodeTemplates *fake.MockControllerInterface[*apimgmtv3.NodeTemplate, *apimgmtv3.NodeTemplateList]
+	validations   int
+}
+
+func newTestController(t *testing.T, validate ValidatorFunc, provClusters []*provv1.Cluster, clusters []*apimgmtv3.Cluster, nodeTemplates []*apimgmtv3.NodeTemplate) *testController {
+	ctrl := gomock.NewController(t)
+	c := &testController{
+		secrets:       fake.NewMockControllerInterface[*corev1.Secret, *corev1.SecretList](ctrl),
+		provClusters:  fake.NewMockControllerInterface[*provv1.Cluster, *provv1.ClusterList](ctrl),
+		clusters:      fake.NewMockNonNamespacedControllerInterface[*apimgmtv3.Cluster, *apimgmtv3.ClusterList](ctrl),
+		nodeTemplates: fake.NewMockControllerInterface[*apimgmtv3.NodeTemplate, *apimgmtv3.NodeTemplateList](ctrl),
+	}
+
+	provClusterCache := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+	provClusterCache.EXPECT().GetByIndex(ByCloudCredential, gomock.Any()).DoAndReturn(func(_, id string) ([]*provv1.Cluster, error) {
+		return referencing(provClusters, id, provClusterReferences), nil
+	}).AnyTimes()
+	c.provClusters.EXPECT().Cache().Return(provClusterCache).AnyTimes()
+	clusterCache := fake.NewMockNonNamespacedCacheInterface[*apimgmtv3.Cluster](ctrl)
+	clusterCache.EXPECT().GetByIndex(ByCloudCredential, gomock.Any()).DoAndReturn(func(_, id string) ([]*apimgmtv3.Cluster, error) {
+		return referencing(clusters, id, clusterReferences), nil
+	}).AnyTimes()
+	c.clusters.EXPECT().Cache().Return(clusterCache).AnyTimes()
+	nodeTemplateCache := fake.NewMockCacheInterface[*apimgmtv3.NodeTemplate](ctrl)
+	nodeTemplateCache.EXPECT().GetByIndex(ByCloudCredential, gomock.Any()).DoAndReturn(func(_, id string) ([]*apimgmtv3.NodeTemplate, error) {
+		return referencing(nodeTemplates, id, nodeTemplateReferences), nil
+	}).AnyTimes()
+	c.nodeTemplates.EXPECT().Cache().Return(nodeTemplateCache).AnyTimes()
+	c.secrets.EXPECT().Enqueue("cattle-global-data", "cc-abc").AnyTimes()
+
+	c.validationController = &validationController{
+		ctx:           context.Background(),
+		secrets:       c.secrets,
+		provClusters:  c.provClusters,
+		clusters:      c.clusters,
+		nodeTemplates: c.nodeTemplates,
+		validators: map[string]Validator{
+			"digitalocean": ValidatorFunc(func(ctx context.Context, fields map[string]string) (time.Time, error) {
+				c.validations++
+				assert.Equal(t, map[string]string{"accessToken": "abc"}, fields)
+				return validate(ctx, fields)
+			}),
+		},
+		now:     func() time.Time { return now },
+		jobs:    make(chan validationJob, validationQueueSize),
+		running: map[string]bool{},
+		results: map[string]validationResult{},
+	}
+	return c
+}
+
+// referencing returns the objects that reference the cloud credential with the given ID, like the ByCloudCredential
+// index does.
+func referencing[T any](objs []T, id string, refs func(T) references) []T {
+	var result []T
+	for _, obj := range objs {
+		if _, ok := refs(obj)[id]; ok {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+// syncValidated syncs the credential, runs the validation it queued and syncs it again to save the result.
+func (c *testController) syncValidated(t *testing.T, secret *corev1.Secret) (*corev1.Secret, error) {
+	t.Helper()
+	validations := c.validations
+	_, err := c.sync(secret.Namespace+"/"+secret.Name, secret)
+	require.NoError(t, err)
+	assert.Equal(t, validations, c.validations, "credentials must not be validated by the secret controller")
+	require.Len(t, c.jobs, 1)
+	c.run(<-c.jobs)
+	return c.sync(secret.Namespace+"/"+secret.Name, secret)
+}
+
+func newCredential() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cc-abc", Namespace: "cattle-global-data"},
+		Data:       map[string][]byte{"digitaloceancredentialConfig-accessToken": []byte("abc")},
+	}
+}
+
+func conditionsOf(t *testing.T, secret *corev1.Secret) *credentialStatus {
+	t.Helper()
+	status := &credentialStatus{}
+	require.NoError(t, json.Unmarshal([]byte(secret.Annotations[statusAnnotation]), status))
+	return status
+}
+
+func TestSyncValid(t *testing.T) {
+	c := newTestController(t, func(context.Context, map[string]string) (time.Time, error) {
+		return time.Time{}, nil
+	}, nil, nil, nil)
+
+	var updated *corev1.Secret
+	c.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		updated = secret
+		return secret, nil
+	})
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", 6*time.Hour)
+
+	_, err := c.syncValidated(t, newCredential())
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.validations)
+
+	status := conditionsOf(t, updated)
+	assert.True(t, CredentialValidated.IsTrue(status))
+	assert.Equal(t, "2024-05-01T12:00:00Z", CredentialValidated.GetLastUpdated(status))
+	assert.Equal(t, "", CredentialExpired.GetStatus(status))
+	assert.Equal(t, fieldsHash(updated.Data), updated.Annotations[validatedHashAnnotation])
+
+	// the credential is not validated again until the interval passed
+	c.now = func() time.Time { return now.Add(time.Hour) }
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", 5*time.Hour)
+	_, err = c.sync("", updated)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.validations)
+
+	// changed fields are validated right away
+	changed := updated.DeepCopy()
+	changed.Data["extra"] = []byte("x")
+	c.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		return secret, nil
+	})
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", 6*time.Hour)
+	_, err = c.syncValidated(t, changed)
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.validations)
+}
+
+func TestSyncInvalidWarnsDependents(t *testing.T) {
+	provClusters := []*provv1.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "uses", Namespace: "fleet-default"}, Spec: provv1.ClusterSpec{CloudCredentialSecretName: "cattle-global-data:cc-abc"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "fleet-default"}, Spec: provv1.ClusterSpec{CloudCredentialSecretName: "cattle-global-data:cc-other"}},
+	}
+	clusters := []*apimgmtv3.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "c-eks"}, Spec: apimgmtv3.ClusterSpec{
+			EKSConfig: &eksv1.EKSClusterConfigSpec{AmazonCredentialSecret: "cattle-global-data:cc-abc"},
+		}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c-aks"}, Spec: apimgmtv3.ClusterSpec{
+			AKSConfig: &aksv1.AKSClusterConfigSpec{AzureCredentialSecret: "cattle-global-data:cc-other"},
+		}},
+	}
+	nodeTemplates := []*apimgmtv3.NodeTemplate{
+		{ObjectMeta: metav1.ObjectMeta{Name: "nt-abc", Namespace: "cattle-global-nt"}, Spec: apimgmtv3.NodeTemplateSpec{CloudCredentialName: "cattle-global-data:cc-abc"}},
+	}
+	valid := false
+	c := newTestController(t, func(context.Context, map[string]string) (time.Time, error) {
+		if valid {
+			return time.Time{}, nil
+		}
+		return time.Time{}, invalid("DigitalOcean rejected the access token")
+	}, provClusters, clusters, nodeTemplates)
+
+	var updated *corev1.Secret
+	c.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		updated = secret
+		return secret, nil
+	})
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", 6*time.Hour)
+	const message = "cloud credential cattle-global-data:cc-abc is invalid: DigitalOcean rejected the access token"
+	c.provClusters.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(func(cluster *provv1.Cluster) (*provv1.Cluster, error) {
+		assert.Equal(t, "uses", cluster.Name)
+		assert.True(t, CloudCredentialValid.IsFalse(cluster))
+		assert.Equal(t, message, CloudCredentialValid.GetMessage(cluster))
+		return cluster, nil
+	})
+	c.nodeTemplates.EXPECT().Update(gomock.Any()).DoAndReturn(func(nodeTemplate *apimgmtv3.NodeTemplate) (*apimgmtv3.NodeTemplate, error) {
+		assert.True(t, CloudCredentialValid.IsFalse(nodeTemplate))
+		assert.Equal(t, message, CloudCredentialValid.GetMessage(nodeTemplate))
+		return nodeTemplate, nil
+	})
+	c.clusters.EXPECT().Update(gomock.Any()).DoAndReturn(func(cluster *apimgmtv3.Cluster) (*apimgmtv3.Cluster, error) {
+		assert.Equal(t, "c-eks", cluster.Name)
+		assert.True(t, CloudCredentialValid.IsFalse(cluster))
+		return cluster, nil
+	})
+
+	_, err := c.syncValidated(t, newCredential())
+	require.NoError(t, err)
+	status := conditionsOf(t, updated)
+	assert.True(t, CredentialValidated.IsFalse(status))
+	assert.Equal(t, reasonInvalid, CredentialValidated.GetReason(status))
+
+	// the warning is cleared once the credential is valid again, objects without the warning are not updated
+	setWarning(provClusters[0], message)
+	valid = true
+	fixed := updated.DeepCopy()
+	fixed.Data["extra"] = []byte("x")
+	c.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		return secret, nil
+	})
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", 6*time.Hour)
+	c.provClusters.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(func(cluster *provv1.Cluster) (*provv1.Cluster, error) {
+		assert.True(t, CloudCredentialValid.IsTrue(cluster))
+		assert.Empty(t, CloudCredentialValid.GetMessage(cluster))
+		return cluster, nil
+	})
+	_, err = c.syncValidated(t, fixed)
+	require.NoError(t, err)
+}
+
+func TestSyncValidationError(t *testing.T) {
+	c := newTestController(t, func(context.Context, map[string]string) (time.Time, error) {
+		return time.Time{}, context.DeadlineExceeded
+	}, nil, nil, nil)
+
+	var updated *corev1.Secret
+	c.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		updated = secret
+		return secret, nil
+	})
+	// credentials that could not be tested are retried sooner
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", retryInterval)
+
+	_, err := c.syncValidated(t, newCredential())
+	require.NoError(t, err)
+	status := conditionsOf(t, updated)
+	assert.Equal(t, "Unknown", CredentialValidated.GetStatus(status))
+	assert.Equal(t, reasonValidationError, CredentialValidated.GetReason(status))
+}
+
+func TestSyncExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiry      time.Time
+		wantExpired string
+		wantReason  string
+		wantMessage string
+		wantNext    time.Duration
+	}{
+		{
+			name:        "expires later",
+			expiry:      now.Add(30 * 24 * time.Hour),
+			wantExpired: "False",
+			wantMessage: "expires at 2024-05-31T12:00:00Z",
+			wantNext:    6 * time.Hour,
+		},
+		{
+			name:        "expires soon",
+			expiry:      now.Add(2 * time.Hour),
+			wantExpired: "False",
+			wantReason:  reasonExpiresSoon,
+			wantMessage: "expires at 2024-05-01T14:00:00Z",
+			wantNext:    2 * time.Hour,
+		},
+		{
+			name:        "expired",
+			expiry:      now.Add(-time.Hour),
+			wantExpired: "True",
+			wantMessage: "expired at 2024-05-01T11:00:00Z",
+			wantNext:    6 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestController(t, func(context.Context, map[string]string) (time.Time, error) {
+				return tt.expiry, nil
+			}, nil, nil, nil)
+			var updated *corev1.Secret
+			c.secrets.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+				updated = secret
+				return secret, nil
+			})
+			c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", tt.wantNext)
+
+			_, err := c.syncValidated(t, newCredential())
+			require.NoError(t, err)
+			status := conditionsOf(t, updated)
+			assert.Equal(t, tt.wantExpired, CredentialExpired.GetStatus(status))
+			assert.Equal(t, tt.wantReason, CredentialExpired.GetReason(status))
+			assert.Equal(t, tt.wantMessage, CredentialExpired.GetMessage(status))
+			assert.Equal(t, tt.expiry.Format(time.RFC3339), updated.Annotations[ExpiresAtAnnotation])
+			if tt.wantExpired == "True" {
+				assert.Equal(t, "cloud credential cattle-global-data:cc-abc "+tt.wantMessage, brokenMessage(updated))
+			} else {
+				assert.Empty(t, brokenMessage(updated))
+			}
+		})
+	}
+}
+
+func TestSyncQueuesValidation(t *testing.T) {
+	c := newTestController(t, func(context.Context, map[string]string) (time.Time, error) {
+		return time.Time{}, nil
+	}, nil, nil, nil)
+	secret := newCredential()
+
+	// a credential is validated once while it waits for a worker
+	for i := 0; i < 2; i++ {
+		_, err := c.sync("cattle-global-data/cc-abc", secret)
+		require.NoError(t, err)
+	}
+	require.Len(t, c.jobs, 1)
+	c.run(<-c.jobs)
+	assert.Equal(t, 1, c.validations)
+
+	// results of fields that changed meanwhile are not saved
+	changed := secret.DeepCopy()
+	changed.Data["extra"] = []byte("x")
+	_, err := c.sync("cattle-global-data/cc-abc", changed)
+	require.NoError(t, err)
+	assert.Len(t, c.jobs, 1)
+
+	// results are dropped with the credential
+	_, err = c.sync("cattle-global-data/cc-abc", nil)
+	require.NoError(t, err)
+	assert.Empty(t, c.results)
+
+	// credentials are retried later if too many wait for a worker
+	for len(c.jobs) < cap(c.jobs) {
+		c.jobs <- validationJob{}
+	}
+	c.running = map[string]bool{}
+	c.secrets.EXPECT().EnqueueAfter("cattle-global-data", "cc-abc", queueRetryInterval)
+	_, err = c.sync("cattle-global-data/cc-abc", secret)
+	require.NoError(t, err)
+}
+
+func TestSyncIgnoresOtherSecrets(t *testing.T) {
+	c := newTestController(t, nil, nil, nil, nil)
+
+	other := newCredential()
+	other.Namespace = "default"
+	_, err := c.sync("", other)
+	require.NoError(t, err)
+
+	untested := newCredential()
+	untested.Data = map[string][]byte{"linodecredentialConfig-token": []byte("abc")}
+	_, err = c.sync("", untested)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, c.validations)
+}
+
+func TestCredentialFields(t *testing.T) {
+	credentialType, fields := credentialFields(map[string][]byte{
+		"amazonec2credentialConfig-accessKey": []byte("key"),
+		"amazonec2credentialConfig-secretKey": []byte("secret"),
+		"other":                               []byte("x"),
+	})
+	assert.Equal(t, "amazonec2", credentialType)
+	assert.Equal(t, map[string]string{"accessKey": "key", "secretKey": "secret"}, fields)
+
+	credentialType, _ = credentialFields(map[string][]byte{"foo": []byte("bar")})
+	assert.Empty(t, credentialType)
+}
@@ -0,0 +1,430 @@
+package cloudcredential
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	authzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Validator tests the fields of a cloud credential against the API of its provider.
+type Validator interface {
+	// Validate returns the time the credential expires at, or the zero time if that is not known. It returns an
+	// *InvalidError if the provider rejected the credential, any other error means that it could not be tested.
+	Validate(ctx context.Context, fields map[string]string) (time.Time, error)
+}
+
+// ValidatorFunc is a function that implements Validator.
+type ValidatorFunc func(ctx context.Context, fields map[string]string) (time.Time, error)
+
+func (f ValidatorFunc) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	return f(ctx, fields)
+}
+
+// InvalidError is returned by a Validator if the provider rejected the credential.
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &InvalidError{Err: fmt.Errorf(format, args...)}
+}
+
+// NewValidators returns the validators of the cloud credential types that can be tested, keyed by the type, which is
+// the prefix of the credentialConfig field of the credential.
+func NewValidators(tokens managementcontrollers.TokenCache) map[string]Validator {
+	client := &http.Client{Timeout: validationTimeout}
+	return map[string]Validator{
+		"amazonec2": &awsValidator{},
+		"azure":     &azureValidator{client: client, environments: azureEnvironments},
+		"google":    &gcpValidator{client: client, iamURL: "https://iam.googleapis.com"},
+		"vmwarevsphere": &vsphereValidator{client: &http.Client{
+			Timeout: validationTimeout,
+			// like the vSphere API handlers, as credentials do not include the CA of vCenter
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}},
+		"digitalocean": &digitalOceanValidator{client: client, url: "https://api.digitalocean.com"},
+		"harvester":    &harvesterValidator{tokens: tokens},
+	}
+}
+
+// awsValidator gets the caller identity of the access key.
+type awsValidator struct {
+	endpoint string
+}
+
+func (v *awsValidator) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	if fields["accessKey"] == "" || fields["secretKey"] == "" {
+		return time.Time{}, invalid("accessKey and secretKey are required")
+	}
+	region := fields["defaultRegion"]
+	if region == "" {
+		region = "us-east-1"
+	}
+	config := &aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(fields["accessKey"], fields["secretKey"], ""),
+	}
+	if v.endpoint != "" {
+		config.Endpoint = aws.String(v.endpoint)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting new aws session: %w", err)
+	}
+
+	_, err = sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "InvalidClientTokenId", "SignatureDoesNotMatch", "ExpiredToken", "AccessDenied", "UnrecognizedClientException":
+			return time.Time{}, invalid("AWS rejected the access key: %s", awsErr.Message())
+		}
+	}
+	return time.Time{}, err
+}
+
+type azureEndpoints struct {
+	login           string
+	resourceManager string
+}
+
+var azureEnvironments = map[string]azureEndpoints{
+	"":                       {login: "https://login.microsoftonline.com/", resourceManager: "https://management.azure.com/"},
+	"AzurePublicCloud":       {login: "https://login.microsoftonline.com/", resourceManager: "https://management.azure.com/"},
+	"AzureChinaCloud":        {login: "https://login.chinacloudapi.cn/", resourceManager: "https://management.chinacloudapi.cn/"},
+	"AzureUSGovernmentCloud": {login: "https://login.microsoftonline.us/", resourceManager: "https://management.usgovcloudapi.net/"},
+}
+
+// azureValidator gets a token for the service principal and reads its subscription with it.
+type azureValidator struct {
+	client       *http.Client
+	environments map[string]azureEndpoints
+}
+
+func (v *azureValidator) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	env, ok := v.environments[fields["environment"]]
+	if !ok {
+		return time.Time{}, invalid("unknown Azure environment %q", fields["environment"])
+	}
+	if fields["clientId"] == "" || fields["clientSecret"] == "" || fields["subscriptionId"] == "" {
+		return time.Time{}, invalid("clientId, clientSecret and subscriptionId are required")
+	}
+	subscriptionURL := env.resourceManager + "subscriptions/" + url.PathEscape(fields["subscriptionId"]) + "?api-version=2020-01-01"
+
+	tenant := fields["tenantId"]
+	if tenant == "" {
+		var err error
+		if tenant, err = v.tenantOfSubscription(ctx, subscriptionURL, fields["subscriptionId"]); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {fields["clientId"]},
+		"client_secret": {fields["clientSecret"]},
+		"scope":         {env.resourceManager + ".default"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, env.login+url.PathEscape(tenant)+"/oauth2/v2.0/token",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := doJSON(v.client, req, &token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case status == http.StatusBadRequest || status == http.StatusUnauthorized:
+		return time.Time{}, invalid("Azure rejected the client secret: %s", token.ErrorDescription)
+	case status != http.StatusOK:
+		return time.Time{}, fmt.Errorf("unexpected status %d from Azure token endpoint", status)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, subscriptionURL, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	status, err = doJSON(v.client, req, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch status {
+	case http.StatusOK:
+		return time.Time{}, nil
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return time.Time{}, invalid("service principal has no access to subscription %s", fields["subscriptionId"])
+	default:
+		return time.Time{}, fmt.Errorf("unexpected status %d when reading Azure subscription", status)
+	}
+}
+
+// tenantOfSubscription returns the tenant that an unauthenticated request for the subscription is redirected to.
+func (v *azureValidator) tenantOfSubscription(ctx context.Context, subscriptionURL, subscriptionID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscriptionURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	// Bearer authorization_uri="https://login.microsoftonline.com/<tenant>", error="invalid_token", ...
+	_, uri, _ := strings.Cut(resp.Header.Get("WWW-Authenticate"), `authorization_uri="`)
+	uri, _, _ = strings.Cut(uri, `"`)
+	if tenant := uri[strings.LastIndex(uri, "/")+1:]; tenant != "" {
+		return tenant, nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", invalid("subscription %s not found", subscriptionID)
+	}
+	return "", fmt.Errorf("unable to find the tenant of the subscription, status %d", resp.StatusCode)
+}
+
+// gcpValidator gets a token for the service account key and reads when the key expires.
+type gcpValidator struct {
+	client *http.Client
+	iamURL string
+}
+
+func (v *gcpValidator) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	conf, err := google.JWTConfigFromJSON([]byte(fields["authEncodedJson"]), "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return time.Time{}, invalid("invalid service account key: %v", err)
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, v.client)
+	if _, err := conf.TokenSource(ctx).Token(); err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.Response != nil &&
+			(retrieveErr.Response.StatusCode == http.StatusBadRequest || retrieveErr.Response.StatusCode == http.StatusUnauthorized) {
+			return time.Time{}, invalid("Google rejected the service account key: %s", retrieveErr.Body)
+		}
+		return time.Time{}, err
+	}
+
+	// the service account may not be allowed to read its own keys, the expiry is then unknown
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s/keys/%s",
+		v.iamURL, url.PathEscape(conf.Email), url.PathEscape(conf.PrivateKeyID)), nil)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	var key struct {
+		ValidBeforeTime time.Time `json:"validBeforeTime"`
+	}
+	if status, err := doJSON(conf.Client(ctx), req, &key); err != nil || status != http.StatusOK {
+		return time.Time{}, nil
+	}
+	// keys that do not expire are valid until the year 9999
+	if key.ValidBeforeTime.Year() >= 9999 {
+		return time.Time{}, nil
+	}
+	return key.ValidBeforeTime, nil
+}
+
+// vsphereValidator creates a session of the vCenter REST API and deletes it again.
+type vsphereValidator struct {
+	client *http.Client
+}
+
+func (v *vsphereValidator) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	if fields["vcenter"] == "" {
+		return time.Time{}, invalid("vcenter is required")
+	}
+	port := fields["vcenterPort"]
+	if port == "" {
+		port = "443"
+	}
+	sessionURL := "https://" + net.JoinHostPort(fields["vcenter"], port) + "/rest/com/vmware/cis/session"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sessionURL, nil)
+	if err != nil {
+		return time.Time{}, invalid("invalid vcenter %q: %v", fields["vcenter"], err)
+	}
+	req.SetBasicAuth(fields["username"], fields["password"])
+	var login struct {
+		Value string `json:"value"`
+	}
+	status, err := doJSON(v.client, req, &login)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch status {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return time.Time{}, invalid("vCenter rejected the username or password")
+	default:
+		return time.Time{}, fmt.Errorf("unexpected status %d when logging in to vCenter", status)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodDelete, sessionURL, nil)
+	if err == nil {
+		req.Header.Set("vmware-api-session-id", login.Value)
+		_, _ = doJSON(v.client, req, nil)
+	}
+	return time.Time{}, nil
+}
+
+// digitalOceanValidator reads the account of the access token.
+type digitalOceanValidator struct {
+	client *http.Client
+	url    string
+}
+
+func (v *digitalOceanValidator) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	if fields["accessToken"] == "" {
+		return time.Time{}, invalid("accessToken is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url+"/v2/account", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+fields["accessToken"])
+	status, err := doJSON(v.client, req, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch status {
+	case http.StatusOK:
+		return time.Time{}, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return time.Time{}, invalid("DigitalOcean rejected the access token")
+	default:
+		return time.Time{}, fmt.Errorf("unexpected status %d when reading DigitalOcean account", status)
+	}
+}
+
+// harvesterValidator reviews the access of the kubeconfig to Harvester. The kubeconfig expires with its client
+// certificate or, if it was generated by Rancher, with the Rancher token it contains.
+type harvesterValidator struct {
+	tokens managementcontrollers.TokenCache
+}
+
+func (v *harvesterValidator) Validate(ctx context.Context, fields map[string]string) (time.Time, error) {
+	config, err := restConfigFromKubeconfig([]byte(fields["kubeconfigContent"]))
+	if err != nil {
+		return time.Time{}, err
+	}
+	config.Timeout = validationTimeout
+
+	var expiry time.Time
+	if block, _ := pem.Decode(config.CertData); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			expiry = cert.NotAfter
+		}
+	}
+	if name, _, ok := strings.Cut(config.BearerToken, ":"); ok && v.tokens != nil {
+		if token, err := v.tokens.Get(name); err == nil && token.ExpiresAt != "" {
+			if expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt); err == nil {
+				expiry = expiresAt
+			}
+		}
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return expiry, invalid("invalid kubeconfig: %v", err)
+	}
+	_, err = client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authzv1.SelfSubjectAccessReview{
+		Spec: authzv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Verb:     "get",
+				Group:    "harvesterhci.io",
+				Resource: "settings",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsUnauthorized(err) {
+		return expiry, invalid("Harvester rejected the kubeconfig: %v", err)
+	}
+	return expiry, err
+}
+
+// restConfigFromKubeconfig returns the client config of a kubeconfig supplied by a user. The config is used from the
+// Rancher pod, so kubeconfigs that run commands or read local files are rejected: exec and auth provider plugins, and
+// token, client certificate, client key and certificate authority files. Only inline credentials are accepted.
+func restConfigFromKubeconfig(kubeconfig []byte) (*rest.Config, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, invalid("invalid kubeconfig: %v", err)
+	}
+	for name, authInfo := range config.AuthInfos {
+		switch {
+		case authInfo.Exec != nil:
+			return nil, invalid("kubeconfig user %s must not use an exec plugin", name)
+		case authInfo.AuthProvider != nil:
+			return nil, invalid("kubeconfig user %s must not use an auth provider", name)
+		case authInfo.TokenFile != "":
+			return nil, invalid("kubeconfig user %s must not use a token file", name)
+		case authInfo.ClientCertificate != "", authInfo.ClientKey != "":
+			return nil, invalid("kubeconfig user %s must not use client certificate or key files", name)
+		}
+	}
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, invalid("kubeconfig cluster %s must not use a certificate authority file", name)
+		}
+	}
+
+	restConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, invalid("invalid kubeconfig: %v", err)
+	}
+	return restConfig, nil
+}
+
+// doJSON sends the request and decodes a JSON response body into out, if set. Returns the status code of the response.
+func doJSON(client *http.Client, req *http.Request, out interface{}) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if out != nil && len(body) > 0 {
+		// error responses are not always JSON, the status code is what matters
+		_ = json.Unmarshal(body, out)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,251 @@
+package cloudcredential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func assertInvalid(t *testing.T, err error) {
+	t.Helper()
+	var invalidErr *InvalidError
+	assert.True(t, errors.As(err, &invalidErr), "expected invalid credential, got %v", err)
+}
+
+func TestDigitalOceanValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/account", r.URL.Path)
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			w.Write([]byte(`{"account":{}}`))
+		case "Bearer flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	v := &digitalOceanValidator{client: server.Client(), url: server.URL}
+
+	_, err := v.Validate(context.Background(), map[string]string{"accessToken": "good"})
+	assert.NoError(t, err)
+	_, err = v.Validate(context.Background(), map[string]string{"accessToken": "revoked"})
+	assertInvalid(t, err)
+	_, err = v.Validate(context.Background(), map[string]string{})
+	assertInvalid(t, err)
+
+	_, err = v.Validate(context.Background(), map[string]string{"accessToken": "flaky"})
+	var invalidErr *InvalidError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &invalidErr), "unavailable provider must not invalidate the credential")
+}
+
+func TestVsphereValidator(t *testing.T) {
+	var loggedOut bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/com/vmware/cis/session", r.URL.Path)
+		if r.Method == http.MethodDelete {
+			assert.Equal(t, "session-id", r.Header.Get("vmware-api-session-id"))
+			loggedOut = true
+			return
+		}
+		if user, password, _ := r.BasicAuth(); user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value":"session-id"}`))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	v := &vsphereValidator{client: server.Client()}
+
+	_, err = v.Validate(context.Background(), map[string]string{"vcenter": host, "vcenterPort": port, "username": "admin", "password": "secret"})
+	assert.NoError(t, err)
+	assert.True(t, loggedOut)
+	_, err = v.Validate(context.Background(), map[string]string{"vcenter": host, "vcenterPort": port, "username": "admin", "password": "old"})
+	assertInvalid(t, err)
+}
+
+func TestAzureValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant-abc/oauth2/v2.0/token":
+			require.NoError(t, r.ParseForm())
+			if r.PostForm.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client","error_description":"AADSTS7000222: The provided client secret keys are expired."}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"token"}`))
+		case "/subscriptions/sub-abc":
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer authorization_uri="%s/tenant-abc", error="invalid_token"`, "https://login.example.com"))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"subscriptionId":"sub-abc"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	v := &azureValidator{
+		client:       server.Client(),
+		environments: map[string]azureEndpoints{"": {login: server.URL + "/", resourceManager: server.URL + "/"}},
+	}
+
+	fields := map[string]string{"clientId": "client", "clientSecret": "secret", "subscriptionId": "sub-abc", "tenantId": "tenant-abc"}
+	_, err := v.Validate(context.Background(), fields)
+	assert.NoError(t, err)
+
+	// the tenant is looked up from the subscription if not set
+	fields["tenantId"] = ""
+	_, err = v.Validate(context.Background(), fields)
+	assert.NoError(t, err)
+
+	fields["clientSecret"] = "expired"
+	_, err = v.Validate(context.Background(), fields)
+	assertInvalid(t, err)
+	assert.Contains(t, err.Error(), "AADSTS7000222")
+
+	fields["clientSecret"], fields["subscriptionId"] = "secret", "sub-other"
+	fields["tenantId"] = "tenant-abc"
+	_, err = v.Validate(context.Background(), fields)
+	assertInvalid(t, err)
+
+	fields["environment"] = "AzureMoonCloud"
+	_, err = v.Validate(context.Background(), fields)
+	assertInvalid(t, err)
+}
+
+func TestHarvesterValidator(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"kind":"SelfSubjectAccessReview","apiVersion":"authorization.k8s.io/v1","status":{"allowed":true}}`))
+	}))
+	defer server.Close()
+	kubeconfig := func(token string) string {
+		return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: harvester
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+users:
+- name: harvester
+  user:
+    token: %s
+contexts:
+- name: harvester
+  context:
+    cluster: harvester
+    user: harvester
+current-context: harvester
+`, server.URL, token)
+	}
+	v := &harvesterValidator{}
+
+	expiry, err := v.Validate(context.Background(), map[string]string{"kubeconfigContent": kubeconfig("good")})
+	assert.NoError(t, err)
+	assert.True(t, expiry.IsZero())
+	_, err = v.Validate(context.Background(), map[string]string{"kubeconfigContent": kubeconfig("revoked")})
+	assertInvalid(t, err)
+	_, err = v.Validate(context.Background(), map[string]string{"kubeconfigContent": "not a kubeconfig"})
+	assertInvalid(t, err)
+}
+
+func TestHarvesterValidatorRejectsLocalCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		cluster string
+	}{
+		{
+			name: "exec plugin",
+			user: `
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: /bin/sh
+      args: ["-c", "cat /var/run/secrets/kubernetes.io/serviceaccount/token"]`,
+		},
+		{
+			name: "auth provider",
+			user: `
+    auth-provider:
+      name: gcp`,
+		},
+		{
+			name: "token file",
+			user: `
+    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token`,
+		},
+		{
+			name: "client certificate file",
+			user: `
+    client-certificate: /etc/rancher/ssl/cert.pem`,
+		},
+		{
+			name: "client key file",
+			user: `
+    client-key: /etc/rancher/ssl/key.pem`,
+		},
+		{
+			name: "certificate authority file",
+			user: `
+    token: abc`,
+			cluster: `
+    certificate-authority: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: harvester
+  cluster:
+    server: https://127.0.0.1:1%s
+users:
+- name: harvester
+  user:%s
+contexts:
+- name: harvester
+  context:
+    cluster: harvester
+    user: harvester
+current-context: harvester
+`, tt.cluster, tt.user)
+
+			v := &harvesterValidator{}
+			_, err := v.Validate(context.Background(), map[string]string{"kubeconfigContent": kubeconfig})
+			assertInvalid(t, err)
+
+			// rotating a credential runs the same validation
+			secret := &corev1.Secret{Data: map[string][]byte{"harvestercredentialConfig-kubeconfigContent": []byte("old")}}
+			_, err = Rotate(context.Background(), secret, map[string]string{"kubeconfigContent": kubeconfig}, map[string]Validator{"harvester": v})
+			assertInvalid(t, err)
+		})
+	}
+}
@@ -10,6 +10,7 @@
 	"github.com/rancher/rancher/pkg/api/steve/proxy"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/rancher/pkg/wrangler"
 	"github.com/rancher/remotedialer"
 	"github.com/rancher/wrangler/v2/pkg/condition"
@@ -75,6 +76,7 @@ func (c *checker) hasSession(cluster *v3.Cluster) bool {
 	client := &http.Client{
 		Transport: transport,
 	}
+	start := time.Now()
 	resp, err := client.Get("http://not-used/ping")
 	if err != nil {
 		return false
@@ -83,7 +85,11 @@ func (c *checker) hasSession(cluster *v3.Cluster) bool {
 		ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	}()
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	tunnelserver.ObserveRoundTrip(clientKey, time.Since(start))
+	return true
 }
 
 func (c *checker) checkCluster(cluster *v3.Cluster) error {
@@ -0,0 +1,134 @@
+package clusterconnected
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/pkg/api/steve/proxy"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/tunnelserver"
+	"github.com/rancher/rancher/pkg/wrangler"
+	"github.com/sirupsen/logrus"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// maxConnectionHistory is the number of tunnel session events kept on the cluster status.
+	maxConnectionHistory = 20
+	// historyQueueSize bounds the events waiting to be written, a burst beyond it is dropped.
+	historyQueueSize = 256
+	// historyFlushInterval is how often pending events are written. All events of a cluster within an interval are
+	// written with a single update, so a flapping agent does not trigger the cluster handlers on every reconnect.
+	historyFlushInterval = 15 * time.Second
+)
+
+// RegisterConnectionHistory records the tunnel sessions of cluster agents handled by this Rancher server in the
+// connection history of the clusters. It has to run on every replica as each one only sees its own sessions.
+func RegisterConnectionHistory(ctx context.Context, wrangler *wrangler.Context) {
+	server, err := os.Hostname()
+	if err != nil {
+		logrus.Warnf("Failed to get hostname for cluster connection history: %v", err)
+	}
+	h := &connectionHistory{
+		clusters: wrangler.Mgmt.Cluster(),
+		server:   server,
+		events:   make(chan tunnelserver.SessionEvent, historyQueueSize),
+	}
+	wrangler.TunnelSessions.AddListener(h.onSession)
+	go h.run(ctx)
+}
+
+type connectionHistory struct {
+	clusters managementcontrollers.ClusterClient
+	server   string
+	events   chan tunnelserver.SessionEvent
+}
+
+func (h *connectionHistory) onSession(event tunnelserver.SessionEvent) {
+	if _, ok := clusterNameForClientKey(event.ClientKey); !ok {
+		return
+	}
+	select {
+	case h.events <- event:
+	default:
+		logrus.Warnf("Dropping connection history event %s for [%s], too many pending events", event.Type, event.ClientKey)
+	}
+}
+
+func (h *connectionHistory) run(ctx context.Context) {
+	pending := map[string][]v3.ClusterConnectionEvent{}
+	flush := time.NewTicker(historyFlushInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-h.events:
+			clusterName, _ := clusterNameForClientKey(event.ClientKey)
+			pending[clusterName] = appendConnectionEvent(pending[clusterName], h.entry(event))
+		case <-flush.C:
+			for clusterName, entries := range pending {
+				if err := h.record(clusterName, entries); err != nil {
+					logrus.Errorf("Failed to record %d connection history events for cluster [%s]: %v", len(entries), clusterName, err)
+				}
+				delete(pending, clusterName)
+			}
+		}
+	}
+}
+
+func (h *connectionHistory) entry(event tunnelserver.SessionEvent) v3.ClusterConnectionEvent {
+	entry := v3.ClusterConnectionEvent{
+		Type:          event.Type,
+		Time:          event.Time.UTC().Format(time.RFC3339),
+		ClientKey:     event.ClientKey,
+		Server:        h.server,
+		RemoteAddress: event.RemoteAddress,
+		Reason:        event.Reason,
+	}
+	if event.Type == tunnelserver.SessionDisconnected {
+		entry.Duration = event.Duration.Round(time.Second).String()
+	}
+	return entry
+}
+
+func (h *connectionHistory) record(clusterName string, entries []v3.ClusterConnectionEvent) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster, err := h.clusters.Get(clusterName, metav1.GetOptions{})
+		if apierror.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		cluster = cluster.DeepCopy()
+		for _, entry := range entries {
+			cluster.Status.ConnectionHistory = appendConnectionEvent(cluster.Status.ConnectionHistory, entry)
+		}
+		_, err = h.clusters.Update(cluster)
+		return err
+	})
+}
+
+// clusterNameForClientKey returns the cluster of a tunnel session. Cluster agents connect with the cluster name as
+// client key and open a second session for the steve proxy, node agents and API services are not tracked.
+func clusterNameForClientKey(clientKey string) (string, bool) {
+	clusterName := strings.TrimPrefix(clientKey, proxy.Prefix)
+	if clusterName == "" || strings.Contains(clusterName, ":") {
+		return "", false
+	}
+	return clusterName, true
+}
+
+func appendConnectionEvent(history []v3.ClusterConnectionEvent, event v3.ClusterConnectionEvent) []v3.ClusterConnectionEvent {
+	history = append(history, event)
+	if len(history) > maxConnectionHistory {
+		history = history[len(history)-maxConnectionHistory:]
+	}
+	return history
+}
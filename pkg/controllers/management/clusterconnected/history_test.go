@@ -0,0 +1,45 @@
+package clusterconnected
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/tunnelserver"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordConnectionHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	clusters := fake.NewMockNonNamespacedControllerInterface[*v3.Cluster, *v3.ClusterList](ctrl)
+	h := &connectionHistory{clusters: clusters, server: "rancher-0"}
+
+	existing := make([]v3.ClusterConnectionEvent, maxConnectionHistory)
+	clusters.EXPECT().Get("c-abcde", gomock.Any()).Return(&v3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"},
+		Status:     v3.ClusterStatus{ConnectionHistory: existing},
+	}, nil)
+
+	var updated *v3.Cluster
+	clusters.EXPECT().Update(gomock.Any()).DoAndReturn(func(cluster *v3.Cluster) (*v3.Cluster, error) {
+		updated = cluster
+		return cluster, nil
+	}).Times(1)
+
+	start := time.Now()
+	entries := []v3.ClusterConnectionEvent{
+		h.entry(tunnelserver.SessionEvent{Type: tunnelserver.SessionConnected, ClientKey: "c-abcde", Time: start}),
+		h.entry(tunnelserver.SessionEvent{Type: tunnelserver.SessionDisconnected, ClientKey: "c-abcde", Time: start, Reason: tunnelserver.DisconnectReasonTimeout, Duration: 3 * time.Second}),
+	}
+	require.NoError(t, h.record("c-abcde", entries))
+
+	history := updated.Status.ConnectionHistory
+	require.Len(t, history, maxConnectionHistory)
+	assert.Equal(t, entries, history[len(history)-2:])
+	assert.Equal(t, "rancher-0", history[len(history)-1].Server)
+	assert.Equal(t, "3s", history[len(history)-1].Duration)
+}
@@ -269,6 +269,11 @@ func redeployAgent(cluster *apimgmtv3.Cluster, desiredAgent, desiredAuth string,
 		return true
 	}
 
+	if !reflect.DeepEqual(cluster.Spec.ClusterAgentProxyConfig, cluster.Status.AppliedClusterAgentProxyConfig) {
+		logrus.Infof("clusterDeploy: redeployAgent: redeploy Rancher agents due to agent proxy config mismatch for [%s]", cluster.Name)
+		return true
+	}
+
 	logrus.Tracef("clusterDeploy: redeployAgent: returning false for redeployAgent")
 
 	return false
@@ -405,6 +410,8 @@ func (cd *clusterDeploy) deployAgent(cluster *apimgmtv3.Cluster) error {
 
 	cluster.Status.AppliedClusterAgentDeploymentCustomization = cluster.Spec.ClusterAgentDeploymentCustomization
 
+	cluster.Status.AppliedClusterAgentProxyConfig = cluster.Spec.ClusterAgentProxyConfig
+
 	return nil
 }
 
@@ -0,0 +1,85 @@
+package usercontrollers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	tpeermanager "github.com/rancher/rancher/pkg/peermanager"
+	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ownerReporter reports the clusters this replica runs the owner controllers of in a ConfigMap, so that the cluster
+// ownership API shows the actual owners and the replicas know which assignment version each of them supports.
+type ownerReporter struct {
+	namespace      string
+	configMaps     corecontrollers.ConfigMapClient
+	configMapCache corecontrollers.ConfigMapCache
+	pods           corecontrollers.PodClient
+	last           *tpeermanager.OwnerReport
+}
+
+// reports returns the reports of all replicas.
+func (r *ownerReporter) reports() ([]tpeermanager.OwnerReport, error) {
+	configMaps, err := r.configMapCache.List(r.namespace, labels.SelectorFromSet(labels.Set{tpeermanager.OwnerReportLabel: "true"}))
+	if err != nil {
+		return nil, err
+	}
+	var reports []tpeermanager.OwnerReport
+	for _, configMap := range configMaps {
+		if report, ok := tpeermanager.OwnerReportFromConfigMap(configMap); ok {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// report saves the clusters owned by the replica if they changed since the last report.
+func (r *ownerReporter) report(replica string, clusters []string) error {
+	sort.Strings(clusters)
+	report := tpeermanager.OwnerReport{
+		Replica:  replica,
+		Version:  tpeermanager.AssignmentVersion,
+		Clusters: clusters,
+	}
+	if r.last != nil && reflect.DeepEqual(*r.last, report) {
+		if _, err := r.configMapCache.Get(r.namespace, tpeermanager.OwnerReportName(replica)); err == nil {
+			return nil
+		}
+	}
+
+	desired := tpeermanager.NewOwnerReportConfigMap(r.namespace, report)
+	// the report is deleted with the pod of the replica, a name is never reused by another replica
+	if pod, err := r.pods.Get(r.namespace, replica, metav1.GetOptions{}); err == nil {
+		desired.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       pod.Name,
+			UID:        pod.UID,
+		}}
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get pod of replica %s: %w", replica, err)
+	} else {
+		logrus.Debugf("No pod found for replica %s, its cluster owner report will not be garbage collected", replica)
+	}
+
+	existing, err := r.configMaps.Get(r.namespace, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = r.configMaps.Create(desired)
+	} else if err == nil {
+		existing = existing.DeepCopy()
+		existing.Labels = desired.Labels
+		existing.OwnerReferences = desired.OwnerReferences
+		existing.Data = desired.Data
+		_, err = r.configMaps.Update(existing)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save cluster owner report of replica %s: %w", replica, err)
+	}
+	r.last = &report
+	return nil
+}
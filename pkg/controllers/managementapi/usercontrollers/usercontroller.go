@@ -3,9 +3,8 @@
 import (
 	"context"
 	"fmt"
-	"hash/crc32"
-	"math"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,9 +15,12 @@
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/metrics"
 	tpeermanager "github.com/rancher/rancher/pkg/peermanager"
+	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
+	"github.com/rancher/wrangler/v2/pkg/kv"
 	"github.com/rancher/wrangler/v2/pkg/relatedresource"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,13 +37,24 @@ func Register(ctx context.Context, scaledContext *config.ScaledContext, clusterM
 		clusterLister: scaledContext.Management.Clusters("").Controller().Lister(),
 		clusters:      scaledContext.Management.Clusters(""),
 		clustered:     scaledContext.PeerManager != nil,
+		owners:        clusterManager.Owners,
+		peerManager:   scaledContext.PeerManager,
 		ctx:           ctx,
 		start:         time.Now(),
 	}
 
 	scaledContext.Management.Clusters("").AddHandler(ctx, "user-controllers-controller", u.sync)
+	scaledContext.Management.Settings("").AddHandler(ctx, "cluster-owner-settings-controller", u.syncSetting)
 
 	if scaledContext.PeerManager != nil {
+		u.reporter = &ownerReporter{
+			namespace:      settings.Namespace.Get(),
+			configMaps:     scaledContext.Wrangler.Core.ConfigMap(),
+			configMapCache: scaledContext.Wrangler.Core.ConfigMap().Cache(),
+			pods:           scaledContext.Wrangler.Core.Pod(),
+		}
+		scaledContext.Wrangler.Core.ConfigMap().OnChange(ctx, "cluster-owner-report-controller", u.syncOwnerReport)
+
 		c := make(chan tpeermanager.Peers, 100)
 		scaledContext.PeerManager.AddListener(c)
 
@@ -88,7 +101,12 @@ type userControllersController struct {
 	clusters      v3.ClusterInterface
 	ctx           context.Context
 	peers         tpeermanager.Peers
-	start         time.Time
+	owners        *tpeermanager.Owners
+	peerManager   tpeermanager.PeerManager
+	reporter      *ownerReporter
+	// assignment is the owner of each cluster by UID as of the last peersSync.
+	assignment map[string]string
+	start      time.Time
 }
 
 func (u *userControllersController) sync(key string, cluster *v3.Cluster) (runtime.Object, error) {
@@ -133,8 +151,11 @@ func (u *userControllersController) sync(key string, cluster *v3.Cluster) (runti
 			return cluster, nil
 		}
 
+		u.Lock()
+		amOwner := u.amOwner(cluster)
+		u.Unlock()
 		u.starter.Stop(cluster)
-		err = u.starter.Start(u.ctx, cluster, u.amOwner(u.peers, cluster))
+		err = u.starter.Start(u.ctx, cluster, amOwner)
 		if err != nil {
 			return nil, fmt.Errorf("userControllersController: unable to restart controllers for cluster %s: %w", cluster.Name, err)
 		}
@@ -179,6 +200,7 @@ func (u *userControllersController) setPeers(peers *tpeermanager.Peers) error {
 		u.peers = *peers
 		u.peers.IDs = append(u.peers.IDs, u.peers.SelfID)
 		sort.Strings(u.peers.IDs)
+		u.owners.SetPeers(u.peers)
 	}
 
 	return u.peersSync()
@@ -190,19 +212,38 @@ func (u *userControllersController) peersSync() error {
 		return err
 	}
 
+	if u.reporter != nil {
+		reports, err := u.reporter.reports()
+		if err != nil {
+			return err
+		}
+		u.owners.SetReports(reports)
+	}
+
+	var uids []string
+	for _, cluster := range clusters {
+		if cluster.DeletionTimestamp == nil && v33.ClusterConditionProvisioned.IsTrue(cluster) {
+			uids = append(uids, string(cluster.UID))
+		}
+	}
+	u.assignment = u.owners.Assign(uids)
+	self := u.owners.Self()
+
 	var (
-		errs []error
+		errs  []error
+		owned []string
 	)
 
 	for _, cluster := range clusters {
 		if cluster.DeletionTimestamp != nil || !v33.ClusterConditionProvisioned.IsTrue(cluster) {
 			u.starter.Stop(cluster)
 		} else {
-			amOwner := u.amOwner(u.peers, cluster)
+			amOwner := u.amOwner(cluster)
 			if amOwner {
-				metrics.SetClusterOwner(u.peers.SelfID, cluster.Name)
+				metrics.SetClusterOwner(self, cluster.Name)
+				owned = append(owned, cluster.Name)
 			} else {
-				metrics.UnsetClusterOwner(u.peers.SelfID, cluster.Name)
+				metrics.UnsetClusterOwner(self, cluster.Name)
 			}
 			if err := u.starter.Start(u.ctx, cluster, amOwner); err != nil {
 				errs = append(errs, errors.Wrapf(err, "failed to start user controllers for cluster %s", cluster.Name))
@@ -210,27 +251,74 @@ func (u *userControllersController) peersSync() error {
 		}
 	}
 
+	if u.reporter != nil && u.assignment != nil {
+		if err := u.reporter.report(self, owned); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	return types.NewErrors(errs...)
 }
 
-func (u *userControllersController) amOwner(peers tpeermanager.Peers, cluster *v3.Cluster) bool {
+func (u *userControllersController) amOwner(cluster *v3.Cluster) bool {
 	if !u.clustered {
 		return true
 	}
 
-	if !peers.Ready || len(peers.IDs) == 0 || (len(peers.IDs) == 1 && !peers.Leader) {
-		return false
+	owner := u.assignment[string(cluster.UID)]
+	logrus.Debugf("%s(%v): owner = %v, self = %v", cluster.Name, cluster.UID, owner, u.owners.Self())
+	return owner != "" && owner == u.owners.Self()
+}
+
+// syncSetting reassigns the clusters when replicas are drained or a rebalance is requested.
+func (u *userControllersController) syncSetting(key string, setting *v3.Setting) (runtime.Object, error) {
+	if !u.clustered || setting == nil || setting.DeletionTimestamp != nil {
+		return setting, nil
 	}
 
-	ck := crc32.ChecksumIEEE([]byte(cluster.UID))
-	if ck == math.MaxUint32 {
-		ck--
+	switch setting.Name {
+	case settings.ClusterOwnerDrainedPeers.Name:
+		value := setting.Value
+		if value == "" {
+			value = setting.Default
+		}
+		u.owners.SetDrained(strings.Split(value, ","))
+	case settings.ClusterOwnerRebalanceRequested.Name:
+		if setting.Value == "" {
+			return setting, nil
+		}
+		// replicas that missed a change of the peers after a rolling restart assign clusters differently than the
+		// others, reading the peers again makes all of them agree
+		logrus.Infof("Rebalancing cluster ownership requested at %s, resyncing peers", setting.Value)
+		if err := u.peerManager.Resync(); err != nil {
+			return nil, fmt.Errorf("userControllersController: failed to resync peers: %w", err)
+		}
+	default:
+		return setting, nil
 	}
 
-	scaled := int(ck) * len(peers.IDs) / math.MaxUint32
-	logrus.Debugf("%s(%v): (%v * %v) / %v = %v[%v] = %v, self = %v\n", cluster.Name, cluster.UID, ck,
-		uint32(len(peers.IDs)), math.MaxUint32, peers.IDs, scaled, peers.IDs[scaled], peers.SelfID)
-	return peers.IDs[scaled] == peers.SelfID
+	if err := u.setPeers(nil); err != nil {
+		return nil, fmt.Errorf("userControllersController: failed to reassign clusters after setting %s changed: %w", setting.Name, err)
+	}
+	return setting, nil
+}
+
+// syncOwnerReport reassigns the clusters when a replica reports a different assignment version, and reports again if
+// the report of this replica was deleted.
+func (u *userControllersController) syncOwnerReport(key string, configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if configMap == nil {
+		if namespace, _ := kv.Split(key, "/"); namespace == u.reporter.namespace {
+			u.clusters.Controller().Enqueue("", relatedresource.AllKey)
+		}
+		return nil, nil
+	}
+	if configMap.Namespace != u.reporter.namespace {
+		return configMap, nil
+	}
+	if _, ok := tpeermanager.OwnerReportFromConfigMap(configMap); ok {
+		u.clusters.Controller().Enqueue("", relatedresource.AllKey)
+	}
+	return configMap, nil
 }
 
 func (u *userControllersController) cleanFinalizers(key string, cluster *v3.Cluster) error {
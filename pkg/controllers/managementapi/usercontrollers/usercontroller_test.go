@@ -3,14 +3,25 @@
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"testing"
 
+	"github.com/golang/mock/gomock"
 	apimgmtv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	tpeermanager "github.com/rancher/rancher/pkg/peermanager"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/wrangler/v2/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/version"
 )
 
@@ -259,3 +270,88 @@ func TestClusterControllerEnqueuesControllers(t *testing.T) {
 		})
 	}
 }
+
+type fakePeerManager struct {
+	tpeermanager.PeerManager
+	resynced int
+}
+
+func (f *fakePeerManager) Resync() error {
+	f.resynced++
+	return nil
+}
+
+func TestRebalanceResyncsPeersAndReportsOwnedClusters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provisioned := apimgmtv3.ClusterStatus{Conditions: []apimgmtv3.ClusterCondition{
+		{Type: apimgmtv3.ClusterConditionType(apimgmtv3.ClusterConditionProvisioned), Status: corev1.ConditionTrue},
+	}}
+	var clusters []*apimgmtv3.Cluster
+	for i := 0; i < 10; i++ {
+		clusters = append(clusters, &apimgmtv3.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("c-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))},
+			Status:     provisioned,
+		})
+	}
+
+	configMaps := fake.NewMockControllerInterface[*corev1.ConfigMap, *corev1.ConfigMapList](ctrl)
+	configMapCache := fake.NewMockCacheInterface[*corev1.ConfigMap](ctrl)
+	pods := fake.NewMockControllerInterface[*corev1.Pod, *corev1.PodList](ctrl)
+	configMapCache.EXPECT().List("cattle-system", gomock.Any()).Return([]*corev1.ConfigMap{
+		tpeermanager.NewOwnerReportConfigMap("cattle-system", tpeermanager.OwnerReport{Replica: "rancher-a", Version: tpeermanager.AssignmentVersion}),
+		tpeermanager.NewOwnerReportConfigMap("cattle-system", tpeermanager.OwnerReport{Replica: "rancher-b", Version: tpeermanager.AssignmentVersion}),
+	}, nil)
+	pods.EXPECT().Get("cattle-system", "rancher-a", gomock.Any()).Return(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "rancher-a", UID: "pod-uid"}}, nil)
+	configMaps.EXPECT().Get("cattle-system", "cattle-cluster-owners-rancher-a", gomock.Any()).Return(nil, apierrors.NewNotFound(schema.GroupResource{}, ""))
+	var report *corev1.ConfigMap
+	configMaps.EXPECT().Create(gomock.Any()).DoAndReturn(func(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+		report = configMap
+		return configMap, nil
+	})
+
+	owners := tpeermanager.NewOwners()
+	owners.SetPeers(tpeermanager.Peers{
+		SelfID:   "10.0.0.1",
+		SelfName: "rancher-a",
+		IDs:      []string{"10.0.0.1", "10.0.0.2"},
+		Names:    map[string]string{"10.0.0.2": "rancher-b"},
+		Ready:    true,
+	})
+	peerManager := &fakePeerManager{}
+	u := newMockUserControllersController(&simpleControllerStarter{})
+	u.clustered = true
+	u.owners = owners
+	u.peerManager = peerManager
+	u.clusterLister = &fakes.ClusterListerMock{
+		ListFunc: func(namespace string, selector labels.Selector) ([]*apimgmtv3.Cluster, error) {
+			return clusters, nil
+		},
+	}
+	u.reporter = &ownerReporter{
+		namespace:      "cattle-system",
+		configMaps:     configMaps,
+		configMapCache: configMapCache,
+		pods:           pods,
+	}
+
+	_, err := u.syncSetting("", &apimgmtv3.Setting{
+		ObjectMeta: metav1.ObjectMeta{Name: settings.ClusterOwnerRebalanceRequested.Name},
+		Value:      "2024-05-01T12:00:00Z",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, peerManager.resynced)
+	assert.Equal(t, tpeermanager.AssignmentVersion, owners.Assignment())
+
+	var want []string
+	for _, cluster := range clusters {
+		if u.assignment[string(cluster.UID)] == "rancher-a" {
+			want = append(want, cluster.Name)
+		}
+	}
+	require.NotNil(t, report)
+	assert.Equal(t, "pod-uid", string(report.OwnerReferences[0].UID))
+	got, ok := tpeermanager.OwnerReportFromConfigMap(report)
+	require.True(t, ok)
+	sort.Strings(want)
+	assert.Equal(t, want, got.Clusters)
+}
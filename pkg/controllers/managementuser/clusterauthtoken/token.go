@@ -42,6 +42,9 @@ func (h *tokenHandler) Create(token *managementv3.Token) (runtime.Object, error)
 	_, err := h.clusterAuthTokenLister.Get(h.namespace, token.Name)
 	if !errors.IsNotFound(err) {
 		return h.Updated(token)
+	} else if isRestricted(token) {
+		logrus.Debugf("token [%s] will not be synced or useable for ACE because it has scopes or source CIDRs", token.Name)
+		return nil, nil
 	} else if features.TokenHashing.Enabled() {
 		// we can sync tokens which are hashed by copying the hash downstream
 		if token.Annotations[tokens.TokenHashed] != "true" {
@@ -65,8 +68,9 @@ func (h *tokenHandler) Create(token *managementv3.Token) (runtime.Object, error)
 		return nil, generic.ErrSkip
 
 	}
-	// token isn't hashed, hash the value only for downstream
-	hasher := hashers.GetHasher()
+	// token isn't hashed, hash the value only for downstream. SHA3 is used regardless of the preferred hasher since
+	// it is the only version synced downstream.
+	hasher := hashers.Sha3Hasher{}
 	hashedValue, err := hasher.CreateHash(token.Token)
 	if err != nil {
 		return nil, fmt.Errorf("unable to hash value for token [%s]: %w", token.Name, err)
@@ -100,6 +104,14 @@ func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error
 	if err != nil {
 		return nil, err
 	}
+	if isRestricted(token) {
+		// the cluster can't enforce the restrictions, so the token must only be usable through Rancher
+		err = h.clusterAuthToken.Delete(token.Name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
 
 	err = h.updateClusterUserAttribute(token)
 	if err != nil {
@@ -152,6 +164,12 @@ func (h *tokenHandler) Updated(token *managementv3.Token) (runtime.Object, error
 	return nil, err
 }
 
+// isRestricted returns true if the token is restricted to scopes or source CIDRs, which are only enforced by Rancher and
+// not by the authorized cluster endpoint of downstream clusters.
+func isRestricted(token *managementv3.Token) bool {
+	return len(token.Scopes) > 0 || len(token.SourceCIDRs) > 0
+}
+
 func (h *tokenHandler) Remove(token *managementv3.Token) (runtime.Object, error) {
 
 	tokens, err := h.tokenIndexer.ByIndex(tokenByUserAndClusterIndex, tokenUserClusterKey(token))
@@ -532,3 +532,45 @@ func runCreateUpdateTest(t *testing.T, testInput *testInput) *testOutput {
 		Error:                    err,
 	}
 }
+
+func TestRestrictedTokensAreNotSynced(t *testing.T) {
+	restrictedToken := &managementv3.Token{
+		ObjectMeta:  metav1.ObjectMeta{Name: "test-token"},
+		UserID:      userID,
+		Token:       tokenKey,
+		SourceCIDRs: []string{"10.0.0.0/8"},
+	}
+	scopedToken := restrictedToken.DeepCopy()
+	scopedToken.SourceCIDRs = nil
+	scopedToken.Scopes = []v3.TokenScope{{Verbs: []string{"get"}}}
+
+	for _, token := range []*managementv3.Token{restrictedToken, scopedToken} {
+		// a new restricted token is not synced
+		output := runCreateUpdateTest(t, &testInput{
+			Token:              token,
+			ExistingTokenError: apierrors.NewNotFound(schema.GroupResource{Group: "cluster.cattle.io", Resource: "ClusterAuthToken"}, token.Name),
+			CallCreate:         true,
+		})
+		require.NoError(t, output.Error)
+		require.Nil(t, output.ModifiedClusterAuthToken)
+	}
+
+	// a cluster auth token synced before the token was restricted is removed
+	var deleted string
+	h := tokenHandler{
+		clusterAuthTokenLister: &fakes.ClusterAuthTokenListerMock{
+			GetFunc: func(namespace, name string) (*clusterv3.ClusterAuthToken, error) {
+				return &clusterv3.ClusterAuthToken{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+			},
+		},
+		clusterAuthToken: &fakes.ClusterAuthTokenInterfaceMock{
+			DeleteFunc: func(name string, options *metav1.DeleteOptions) error {
+				deleted = name
+				return nil
+			},
+		},
+	}
+	_, err := h.Updated(restrictedToken)
+	require.NoError(t, err)
+	require.Equal(t, "test-token", deleted)
+}
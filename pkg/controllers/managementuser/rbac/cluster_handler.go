@@ -2,6 +2,7 @@
 
 import (
 	"fmt"
+	"time"
 
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -75,7 +76,7 @@ func (h *clusterHandler) doSync(cluster *v3.Cluster) error {
 
 		for _, x := range grbs {
 			grb, ok := x.(*v32.GlobalRoleBinding)
-			if !ok || grb == nil {
+			if !ok || grb == nil || rbac.IsGRBExpired(grb, time.Now()) {
 				continue
 			}
 			bindingName := rbac.GrbCRBName(grb)
@@ -1,6 +1,8 @@
 package rbac
 
 import (
+	"time"
+
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
@@ -41,6 +43,11 @@ func (c *crtbLifecycle) Remove(obj *v3.ClusterRoleTemplateBinding) (runtime.Obje
 }
 
 func (c *crtbLifecycle) syncCRTB(binding *v3.ClusterRoleTemplateBinding) error {
+	if pkgrbac.IsCRTBExpired(binding, time.Now()) {
+		// expired bindings are deleted in the management cluster, revoke their permissions without waiting for it
+		return c.ensureCRTBDelete(binding)
+	}
+
 	if binding.RoleTemplateName == "" {
 		logrus.Warnf("ClusterRoleTemplateBinding %v has no role template set. Skipping.", binding.Name)
 		return nil
@@ -2,6 +2,7 @@
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/rancher/norman/types/slice"
 	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
@@ -77,7 +78,7 @@ type grbHandler struct {
 }
 
 func (c *grbHandler) sync(key string, obj *apisv3.GlobalRoleBinding) (runtime.Object, error) {
-	if obj == nil || obj.DeletionTimestamp != nil {
+	if obj == nil || obj.DeletionTimestamp != nil || rbac.IsGRBExpired(obj, time.Now()) {
 		return obj, nil
 	}
 	isAdmin, err := c.isAdminRole(obj.GlobalRoleName)
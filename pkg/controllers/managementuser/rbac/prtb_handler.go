@@ -4,6 +4,7 @@
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -84,6 +85,11 @@ func (p *prtbLifecycle) Remove(obj *v3.ProjectRoleTemplateBinding) (runtime.Obje
 }
 
 func (p *prtbLifecycle) syncPRTB(binding *v3.ProjectRoleTemplateBinding) error {
+	if pkgrbac.IsPRTBExpired(binding, time.Now()) {
+		// expired bindings are deleted in the management cluster, revoke their permissions without waiting for it
+		return p.ensurePRTBDelete(binding)
+	}
+
 	if binding.RoleTemplateName == "" {
 		logrus.Warnf("ProjectRoleTemplateBinding %v has no role template set. Skipping.", binding.Name)
 		return nil
@@ -125,6 +125,10 @@ func (h *handler) OnChange(key string, configMap *corev1.ConfigMap) (runtime.Obj
 	// and no machine can be found for it, go ahead and delete it.
 	// if the snapshot object is found in the configmap, add it to the currentEtcdSnapshotsToKeep for reconciliation
 	for _, existingSnapshotCR := range currentEtcdSnapshots {
+		if existingSnapshotCR.Labels[capr.ETCDSnapshotTargetLabel] != "" {
+			// copies of snapshots in other snapshot targets are managed by the etcdsnapshottarget controller.
+			continue
+		}
 		storageLocation, ok := existingSnapshotCR.GetAnnotations()[StorageAnnotationKey]
 		if !ok {
 			storageLocation = StorageLocal
@@ -336,6 +336,17 @@ func (h *handler) createNewCluster(cluster *v1.Cluster, status v1.ClusterStatus,
 			OverrideResourceRequirements: fleetAgentCustomizationCopy.OverrideResourceRequirements,
 		}
 	}
+	if cluster.Spec.ClusterAgentProxyConfig != nil {
+		proxyConfigCopy := cluster.Spec.ClusterAgentProxyConfig.DeepCopy()
+		spec.ClusterAgentProxyConfig = &v3.AgentProxyConfig{
+			URL:     proxyConfigCopy.URL,
+			CACerts: proxyConfigCopy.CACerts,
+			NoProxy: proxyConfigCopy.NoProxy,
+		}
+		if proxyConfigCopy.CredentialSecretName != "" {
+			spec.ClusterAgentProxyConfig.CredentialSecret = cluster.Namespace + ":" + proxyConfigCopy.CredentialSecretName
+		}
+	}
 
 	if cluster.Spec.RKEConfig != nil {
 		if err := h.updateFeatureLockedValue(true); err != nil {
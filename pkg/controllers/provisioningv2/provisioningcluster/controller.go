@@ -277,6 +277,10 @@ func reconcileClusterSpecEtcdRestore(cluster *rancherv1.Cluster, desiredSpec ran
 		changed = true
 		cluster.Spec.FleetAgentDeploymentCustomization = desiredSpec.FleetAgentDeploymentCustomization
 	}
+	if !equality.Semantic.DeepEqual(cluster.Spec.ClusterAgentProxyConfig, desiredSpec.ClusterAgentProxyConfig) {
+		changed = true
+		cluster.Spec.ClusterAgentProxyConfig = desiredSpec.ClusterAgentProxyConfig
+	}
 	return changed
 }
 
@@ -9,18 +9,21 @@
 
 	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/capr"
+	"github.com/rancher/rancher/pkg/clusterprovisioninglogger"
 	"github.com/rancher/rancher/pkg/controllers/dashboard/clusterindex"
 	provisioningcontrollers "github.com/rancher/rancher/pkg/generated/controllers/provisioning.cattle.io/v1"
 	"github.com/rancher/rancher/pkg/wrangler"
 	corev1controllers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v2/pkg/summary"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 const (
-	provisioningLogName = "provisioning-log"
-	maxLen              = 10000
+	provisioningLogName = clusterprovisioninglogger.ConfigMapName
 )
 
 var (
@@ -36,6 +39,7 @@ func Register(ctx context.Context, clients *wrangler.Context) {
 
 	clients.Core.Namespace().OnChange(ctx, "prov-log-namespace", h.OnNamespace)
 	clients.Core.ConfigMap().OnChange(ctx, "prov-log-configmap", h.OnConfigMap)
+	clients.CAPI.Machine().OnChange(ctx, "prov-log-machine", h.OnMachine)
 }
 
 type handler struct {
@@ -67,29 +71,15 @@ func (h *handler) OnConfigMap(key string, cm *corev1.ConfigMap) (*corev1.ConfigM
 	return h.recordMessage(provCluster[0], cm)
 }
 
-func appendLog(error bool, oldLog, log string) string {
-	if len(oldLog) > maxLen {
-		oldLog = oldLog[:maxLen]
-		oldLog = strings.TrimRightFunc(oldLog, func(r rune) bool {
-			return r != '\n'
-		})
-	}
-	prefix := " [INFO ] "
-	if error {
-		prefix = " [ERROR] "
-	}
-	return oldLog + time.Now().Format(time.RFC3339) + prefix + log + "\n"
-}
-
 func (h *handler) recordMessage(provCluster *provv1.Cluster, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
 	msg := capr.Provisioned.GetMessage(provCluster)
-	error := capr.Provisioned.IsFalse(provCluster)
+	failed := capr.Provisioned.IsFalse(provCluster)
 	done := capr.Provisioned.IsTrue(provCluster)
 
 	if done && msg == "" {
 		done = capr.Updated.IsTrue(provCluster)
 		msg = capr.Updated.GetMessage(provCluster)
-		error = capr.Updated.IsFalse(provCluster)
+		failed = capr.Updated.IsFalse(provCluster)
 	}
 
 	if done && msg == "" && provCluster.Status.Ready {
@@ -100,8 +90,13 @@ func (h *handler) recordMessage(provCluster *provv1.Cluster, cm *corev1.ConfigMa
 		return cm, nil
 	}
 
+	level := clusterprovisioninglogger.LevelInfo
+	if failed {
+		level = clusterprovisioninglogger.LevelError
+	}
 	if strings.Contains(msg, "the object has been modified; please apply your changes to the latest version and try again") {
 		msg = fmt.Sprintf("Transient error encountered: %s", msg)
+		level = clusterprovisioninglogger.LevelWarning
 	}
 
 	last := cm.Data["last"]
@@ -114,11 +109,79 @@ func (h *handler) recordMessage(provCluster *provv1.Cluster, cm *corev1.ConfigMa
 		cm.Data = map[string]string{}
 	}
 
-	cm.Data["log"] = appendLog(error, cm.Data["log"], msg)
+	clusterprovisioninglogger.Append(cm, clusterprovisioninglogger.Entry{
+		Level:   level,
+		Message: msg,
+	})
 	cm.Data["last"] = msg
 	return h.configMaps.Update(cm)
 }
 
+// OnMachine records the changes of the state of the machines of RKE clusters in the provisioning logs of the clusters.
+func (h *handler) OnMachine(key string, machine *capi.Machine) (*capi.Machine, error) {
+	if machine == nil || machine.Spec.ClusterName == "" {
+		return machine, nil
+	}
+	provCluster, err := h.clusterCache.Get(machine.Namespace, machine.Spec.ClusterName)
+	if apierrors.IsNotFound(err) {
+		return machine, nil
+	} else if err != nil {
+		return machine, err
+	}
+	if provCluster.Spec.RKEConfig == nil || provCluster.Status.ClusterName == "" {
+		return machine, nil
+	}
+	return machine, h.recordMachineEntry(provCluster.Status.ClusterName, machineEntry(machine))
+}
+
+// machineEntry returns the entry of the current state of the machine.
+func machineEntry(machine *capi.Machine) clusterprovisioninglogger.Entry {
+	s := summary.Summarize(machine)
+	msg := s.State
+	if len(s.Message) > 0 {
+		msg += ": " + strings.Join(s.Message, "; ")
+	}
+	level := clusterprovisioninglogger.LevelInfo
+	if s.Error {
+		level = clusterprovisioninglogger.LevelError
+	}
+	return clusterprovisioninglogger.Entry{
+		Level:   level,
+		Machine: machine.Name,
+		Message: msg,
+	}
+}
+
+// recordMachineEntry appends the entry to the provisioning log of the cluster namespace, unless it is the last entry
+// of the machine.
+func (h *handler) recordMachineEntry(namespace string, entry clusterprovisioninglogger.Entry) error {
+	if entry.Message == "" {
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := h.configMaps.Get(namespace, provisioningLogName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			// the log is created along with the cluster namespace
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entries := clusterprovisioninglogger.Entries(cm)
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Machine != entry.Machine {
+				continue
+			}
+			if entries[i].Message == entry.Message && entries[i].Level == entry.Level {
+				return nil
+			}
+			break
+		}
+		clusterprovisioninglogger.Append(cm, entry)
+		_, err = h.configMaps.Update(cm)
+		return err
+	})
+}
+
 func (h *handler) OnNamespace(key string, ns *corev1.Namespace) (*corev1.Namespace, error) {
 	if ns == nil || !ns.DeletionTimestamp.IsZero() {
 		return nil, nil
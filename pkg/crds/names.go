@@ -106,6 +106,7 @@ func AuthCRDs() []string {
 		"users.management.cattle.io",
 		"userattributes.management.cattle.io",
 		"clusterproxyconfigs.management.cattle.io",
+		"accessrequests.management.cattle.io",
 	}
 }
 
@@ -191,6 +192,7 @@ func UIPluginsCRD() []string {
 
 // MigratedResources map list of resource that have been migrated after all resource have a CRD this can be removed.
 var MigratedResources = map[string]bool{
+	"accessrequests.management.cattle.io":                             true,
 	"activedirectoryproviders.management.cattle.io":                   false,
 	"apiservices.management.cattle.io":                                false,
 	"apprevisions.project.cattle.io":                                  false,
@@ -32,6 +32,7 @@ func init() {
 
 type Interface interface {
 	APIService() APIServiceController
+	AccessRequest() AccessRequestController
 	ActiveDirectoryProvider() ActiveDirectoryProviderController
 	AuthConfig() AuthConfigController
 	AuthProvider() AuthProviderController
@@ -114,6 +115,10 @@ func (v *version) APIService() APIServiceController {
 	return generic.NewNonNamespacedController[*v3.APIService, *v3.APIServiceList](schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "APIService"}, "apiservices", v.controllerFactory)
 }
 
+func (v *version) AccessRequest() AccessRequestController {
+	return generic.NewNonNamespacedController[*v3.AccessRequest, *v3.AccessRequestList](schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "AccessRequest"}, "accessrequests", v.controllerFactory)
+}
+
 func (v *version) ActiveDirectoryProvider() ActiveDirectoryProviderController {
 	return generic.NewNonNamespacedController[*v3.ActiveDirectoryProvider, *v3.ActiveDirectoryProviderList](schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "ActiveDirectoryProvider"}, "activedirectoryproviders", v.controllerFactory)
 }
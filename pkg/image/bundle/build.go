@@ -0,0 +1,212 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// BuildOptions configures the bundle built by Build.
+type BuildOptions struct {
+	// Dir is the directory the bundle is written to. It is created if it does not exist.
+	Dir            string
+	RancherVersion string
+	// Images maps an operating system, linux or windows, to the images to bundle for it.
+	Images map[string][]string
+	// Files maps the name of a file in the bundle to the file or directory it is read from.
+	// Directories, such as chart repositories, are stored as gzipped tarballs.
+	Files map[string]string
+	// Platform restricts the linux images to a single platform, i.e. linux/amd64. All platforms are
+	// bundled if it is nil. Windows images always include all their platforms.
+	Platform *ocispec.Platform
+	// Source configures how images are pulled.
+	Source RegistryOptions
+	// Attempts is the number of times pulling an image is attempted.
+	Attempts int
+}
+
+// Build pulls the images and copies the files of opts into a bundle, and returns its manifest.
+// Images that are already in the bundle are not pulled again, so an interrupted build can be resumed.
+func Build(ctx context.Context, opts BuildOptions) (*Manifest, error) {
+	store, err := oci.NewWithContext(ctx, filepath.Join(opts.Dir, ImagesDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCI image layout: %w", err)
+	}
+
+	manifest := &Manifest{
+		RancherVersion: opts.RancherVersion,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	osTypes := make([]string, 0, len(opts.Images))
+	for osType := range opts.Images {
+		osTypes = append(osTypes, osType)
+	}
+	sort.Strings(osTypes)
+	for _, osType := range osTypes {
+		for _, image := range opts.Images[osType] {
+			var platform *ocispec.Platform
+			if osType == "linux" {
+				platform = opts.Platform
+			}
+			desc, err := pull(ctx, store, image, platform, opts)
+			if err != nil {
+				return nil, err
+			}
+			manifest.Images = append(manifest.Images, Image{
+				Name:      image,
+				OS:        osType,
+				Digest:    desc.Digest,
+				MediaType: desc.MediaType,
+			})
+		}
+	}
+
+	names := make([]string, 0, len(opts.Files))
+	for name := range opts.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := addFile(opts.Dir, name, opts.Files[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to bundle: %w", opts.Files[name], err)
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if err := writeManifest(opts.Dir, manifest); err != nil {
+		return nil, fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// pull copies the image into the OCI image layout, tagged with its fully qualified reference.
+func pull(ctx context.Context, store *oci.Store, image string, platform *ocispec.Platform, opts BuildOptions) (ocispec.Descriptor, error) {
+	ref, err := qualify(image)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if desc, err := store.Resolve(ctx, ref.String()); err == nil {
+		log.Printf("Image %s is already bundled\n", image)
+		return desc, nil
+	}
+
+	repo, err := newRepository(ref, opts.Source)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	copyOpts := oras.DefaultCopyOptions
+	copyOpts.WithTargetPlatform(platform)
+
+	var desc ocispec.Descriptor
+	err = withRetry(ctx, opts.Attempts, func() error {
+		log.Printf("Pulling %s\n", image)
+		desc, err = oras.Copy(ctx, repo, ref.Reference, store, ref.String(), copyOpts)
+		return err
+	})
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+	return desc, nil
+}
+
+// addFile copies the file or directory at src into the files directory of the bundle.
+func addFile(dir, name, src string) (File, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return File{}, err
+	}
+
+	path := filepath.ToSlash(filepath.Join(FilesDir, name))
+	if info.IsDir() && !strings.HasSuffix(path, ".tar.gz") {
+		path += ".tar.gz"
+	}
+	dest := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return File{}, err
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	digester := digest.Canonical.Digester()
+	w := io.MultiWriter(f, digester.Hash())
+	if info.IsDir() {
+		err = archiveDir(src, w)
+	} else {
+		err = copyFile(src, w)
+	}
+	if err != nil {
+		return File{}, err
+	}
+	return File{Path: path, Digest: digester.Digest()}, f.Close()
+}
+
+func copyFile(src string, w io.Writer) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// archiveDir writes the directory at src to w as a gzipped tarball, leaving out the .git directory
+// of chart repositories.
+func archiveDir(src string, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyFile(path, tw)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,299 @@
+// Package bundle builds air-gap bundles of the images, charts and KDM data used by Rancher, and loads
+// them into a private registry without requiring a docker daemon.
+//
+// A bundle is a directory, or a tarball of that directory, with the following layout:
+//
+//	bundle.json  the bundle manifest
+//	images/      an OCI image layout holding every image, tagged with its fully qualified reference
+//	files/       the charts and KDM data referenced by the manifest
+package bundle
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+const (
+	// ManifestFile is the name of the bundle manifest.
+	ManifestFile = "bundle.json"
+	// ImagesDir is the directory of the OCI image layout within a bundle.
+	ImagesDir = "images"
+	// FilesDir is the directory of the charts and KDM data within a bundle.
+	FilesDir = "files"
+
+	defaultRegistry = "docker.io"
+)
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	RancherVersion string    `json:"rancherVersion"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Images         []Image   `json:"images"`
+	Files          []File    `json:"files"`
+}
+
+// Image is an image stored in the OCI image layout of a bundle.
+type Image struct {
+	// Name is the image as listed in rancher-images.txt, i.e. rancher/rancher:v2.8.0.
+	Name string `json:"name"`
+	// OS is the operating system the image is listed for, linux or windows.
+	OS string `json:"os"`
+	// Digest is the digest of the manifest or index the image refers to.
+	Digest digest.Digest `json:"digest"`
+	// MediaType is the media type of the manifest or index the image refers to.
+	MediaType string `json:"mediaType"`
+}
+
+// File is a file stored in the files directory of a bundle.
+type File struct {
+	// Path is the path of the file relative to the bundle root.
+	Path string `json:"path"`
+	// Digest is the digest of the file content.
+	Digest digest.Digest `json:"digest"`
+}
+
+// RegistryOptions configures how a registry is accessed.
+type RegistryOptions struct {
+	// Username and Password authenticate with the registry. When empty, the credentials of the docker
+	// config file and credential helpers are used, if any.
+	Username string
+	Password string
+	// PlainHTTP accesses the registry over HTTP instead of HTTPS.
+	PlainHTTP bool
+	// InsecureSkipTLSVerify disables the verification of the registry certificate.
+	InsecureSkipTLSVerify bool
+}
+
+// Default values for the exponential backoff used by oras to retry a HTTP call when a 429 or 5xx
+// response code is hit, or the connection fails.
+var retryPolicy = retry.GenericPolicy{
+	Retryable: retry.DefaultPredicate,
+	Backoff:   retry.ExponentialBackoff(time.Second, 2, 0.2),
+	MinWait:   time.Second,
+	MaxWait:   30 * time.Second,
+	MaxRetry:  5,
+}
+
+// ReadManifest reads the manifest of the bundle in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle manifest: %w", err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeManifest(dir string, manifest *Manifest) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), b, 0644)
+}
+
+// qualify returns the fully qualified reference of an image, applying the same defaults as docker,
+// i.e. rancher/rancher:v2.8.0 is qualified as docker.io/rancher/rancher:v2.8.0.
+func qualify(image string) (registry.Reference, error) {
+	name := image
+	if i := strings.Index(name, "/"); i == -1 {
+		name = defaultRegistry + "/library/" + name
+	} else if first := name[:i]; !strings.ContainsAny(first, ".:") && first != "localhost" {
+		name = defaultRegistry + "/" + name
+	}
+	ref, err := registry.ParseReference(name)
+	if err != nil {
+		return registry.Reference{}, fmt.Errorf("failed to parse image %s: %w", image, err)
+	}
+	if ref.Reference == "" {
+		ref.Reference = "latest"
+	}
+	return ref, nil
+}
+
+// newRepository returns a client for the repository of ref that retries failed requests.
+func newRepository(ref registry.Reference, opts RegistryOptions) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(ref.Registry + "/" + ref.Repository)
+	if err != nil {
+		return nil, err
+	}
+	repo.PlainHTTP = opts.PlainHTTP
+
+	baseTransport := http.DefaultTransport.(*http.Transport).Clone()
+	baseTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: opts.InsecureSkipTLSVerify}
+	retryTransport := retry.NewTransport(baseTransport)
+	retryTransport.Policy = func() retry.Policy {
+		return &retryPolicy
+	}
+
+	credential := auth.StaticCredential(ref.Registry, auth.Credential{Username: opts.Username, Password: opts.Password})
+	if opts.Username == "" && opts.Password == "" {
+		credential = nil
+		if store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{}); err == nil {
+			credential = credentials.Credential(store)
+		}
+	}
+
+	repo.Client = &auth.Client{
+		Cache:      auth.DefaultCache,
+		Credential: credential,
+		Client:     &http.Client{Transport: retryTransport},
+	}
+	return repo, nil
+}
+
+// Archive writes the bundle in dir to w as a tarball.
+func Archive(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive bundle: %w", err)
+	}
+	return tw.Close()
+}
+
+// Extract extracts the bundle tarball read from r into dir.
+func Extract(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read bundle tarball: %w", err)
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("bundle tarball entry %s is outside of the bundle", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := extractFile(tr, path, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("bundle tarball entry %s has unsupported type %c", header.Name, header.Typeflag)
+		}
+	}
+}
+
+func extractFile(r io.Reader, path string, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Open returns the directory of the bundle at path. Bundle tarballs are extracted into a temporary
+// directory, which is removed by the returned cleanup function.
+func Open(path string) (string, func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return path, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "rancher-bundle-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	f, err := os.Open(path)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer f.Close()
+	if err := Extract(f, dir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+// withRetry calls fn up to attempts times, waiting exponentially longer between attempts, until it
+// succeeds or ctx is done.
+func withRetry(ctx context.Context, attempts int, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	wait := 2 * time.Second
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if i == attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	return err
+}
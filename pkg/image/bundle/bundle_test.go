@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualify(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "rancher/rancher:v2.8.0", want: "docker.io/rancher/rancher:v2.8.0"},
+		{image: "busybox", want: "docker.io/library/busybox:latest"},
+		{image: "registry.example.com/rancher/rancher:v2.8.0", want: "registry.example.com/rancher/rancher:v2.8.0"},
+		{image: "localhost/rancher/rancher:v2.8.0", want: "localhost/rancher/rancher:v2.8.0"},
+		{image: "registry:5000/rancher/rancher:v2.8.0", want: "registry:5000/rancher/rancher:v2.8.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			ref, err := qualify(tt.image)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref.String())
+		})
+	}
+}
+
+func TestTargetReference(t *testing.T) {
+	src, err := qualify("rancher/rancher:v2.8.0")
+	require.NoError(t, err)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "registry.example.com:5000", want: "registry.example.com:5000/rancher/rancher:v2.8.0"},
+		{target: "https://registry.example.com/", want: "registry.example.com/rancher/rancher:v2.8.0"},
+		{target: "registry.example.com/mirror", want: "registry.example.com/mirror/rancher/rancher:v2.8.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			ref, err := targetReference(tt.target, src)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref.String())
+		})
+	}
+}
+
+func TestBuildArchiveVerify(t *testing.T) {
+	src := t.TempDir()
+	charts := filepath.Join(src, "charts")
+	require.NoError(t, os.MkdirAll(filepath.Join(charts, "assets"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(charts, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(charts, "assets", "index.yaml"), []byte("apiVersion: v1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(charts, ".git", "HEAD"), []byte("ref: refs/heads/main"), 0644))
+	data := filepath.Join(src, "data.json")
+	require.NoError(t, os.WriteFile(data, []byte("{}"), 0644))
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	manifest, err := Build(ctx, BuildOptions{
+		Dir:            dir,
+		RancherVersion: "v2.8.0",
+		Files: map[string]string{
+			"charts":    charts,
+			"data.json": data,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	assert.Equal(t, "files/charts.tar.gz", manifest.Files[0].Path)
+	assert.Equal(t, "files/data.json", manifest.Files[1].Path)
+
+	var archive bytes.Buffer
+	require.NoError(t, Archive(dir, &archive))
+	extracted := t.TempDir()
+	require.NoError(t, Extract(&archive, extracted))
+
+	verified, err := Verify(ctx, extracted)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.Files, verified.Files)
+	assert.Equal(t, "v2.8.0", verified.RancherVersion)
+
+	require.NoError(t, os.WriteFile(filepath.Join(extracted, "files", "data.json"), []byte("{\"tampered\": true}"), 0644))
+	_, err = Verify(ctx, extracted)
+	assert.ErrorContains(t, err, "bundle file files/data.json has digest")
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../bundle.json", Mode: 0644, Size: 2, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	err = Extract(&archive, t.TempDir())
+	assert.ErrorContains(t, err, "is outside of the bundle")
+}
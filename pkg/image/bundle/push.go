@@ -0,0 +1,155 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry"
+)
+
+// PushOptions configures how Push loads a bundle into a registry.
+type PushOptions struct {
+	// Registry is the target registry, optionally followed by a path that is prepended to the
+	// repository of every image, i.e. registry.example.com:5000/mirror.
+	Registry string
+	// Target configures how the target registry is accessed.
+	Target RegistryOptions
+	// Attempts is the number of times pushing an image is attempted.
+	Attempts int
+}
+
+// Verify checks that the files of the bundle in dir match the digests in its manifest, and that
+// every image of the manifest is in its OCI image layout with the expected digest.
+func Verify(ctx context.Context, dir string) (*Manifest, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range manifest.Files {
+		if err := verifyFile(dir, file); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := oci.NewFromFS(ctx, os.DirFS(filepath.Join(dir, ImagesDir)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI image layout: %w", err)
+	}
+	for _, image := range manifest.Images {
+		ref, err := qualify(image.Name)
+		if err != nil {
+			return nil, err
+		}
+		desc, err := store.Resolve(ctx, ref.String())
+		if err != nil {
+			return nil, fmt.Errorf("image %s is missing from the bundle: %w", image.Name, err)
+		}
+		if desc.Digest != image.Digest {
+			return nil, fmt.Errorf("image %s has digest %s in the bundle, expected %s", image.Name, desc.Digest, image.Digest)
+		}
+	}
+	return manifest, nil
+}
+
+func verifyFile(dir string, file File) error {
+	path := filepath.Join(dir, filepath.FromSlash(file.Path))
+	if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return fmt.Errorf("bundle file %s is outside of the bundle", file.Path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("bundle file %s is missing: %w", file.Path, err)
+	}
+	defer f.Close()
+
+	actual, err := file.Digest.Algorithm().FromReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle file %s: %w", file.Path, err)
+	}
+	if actual != file.Digest {
+		return fmt.Errorf("bundle file %s has digest %s, expected %s", file.Path, actual, file.Digest)
+	}
+	return nil
+}
+
+// Push verifies the bundle in dir and copies its images to the target registry. Every image is
+// resolved in the target registry after it is pushed to check that it has the digest recorded in
+// the bundle manifest.
+func Push(ctx context.Context, dir string, opts PushOptions) error {
+	manifest, err := Verify(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	store, err := oci.NewFromFS(ctx, os.DirFS(filepath.Join(dir, ImagesDir)))
+	if err != nil {
+		return fmt.Errorf("failed to open OCI image layout: %w", err)
+	}
+
+	for _, image := range manifest.Images {
+		src, err := qualify(image.Name)
+		if err != nil {
+			return err
+		}
+		dest, err := targetReference(opts.Registry, src)
+		if err != nil {
+			return err
+		}
+		repo, err := newRepository(dest, opts.Target)
+		if err != nil {
+			return err
+		}
+
+		err = withRetry(ctx, opts.Attempts, func() error {
+			log.Printf("Pushing %s to %s\n", image.Name, dest)
+			if _, err := oras.Copy(ctx, store, src.String(), repo, dest.Reference, oras.DefaultCopyOptions); err != nil {
+				return err
+			}
+			desc, err := repo.Resolve(ctx, dest.Reference)
+			if err != nil {
+				return fmt.Errorf("failed to resolve pushed image: %w", err)
+			}
+			return checkDigest(desc.Digest, image.Digest)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to push image %s to %s: %w", image.Name, dest, err)
+		}
+	}
+	return nil
+}
+
+func checkDigest(actual, expected digest.Digest) error {
+	if actual != expected {
+		return fmt.Errorf("image has digest %s in the target registry, expected %s", actual, expected)
+	}
+	return nil
+}
+
+// targetReference returns the reference of the image in the target registry, which keeps the
+// repository and tag of the image but not its registry, i.e. docker.io/rancher/rancher:v2.8.0 is
+// pushed as registry.example.com/rancher/rancher:v2.8.0.
+func targetReference(target string, src registry.Reference) (registry.Reference, error) {
+	target = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(target, "https://"), "http://"), "/")
+	registryHost, prefix, _ := strings.Cut(target, "/")
+	repository := src.Repository
+	if prefix != "" {
+		repository = prefix + "/" + repository
+	}
+	ref := registry.Reference{
+		Registry:   registryHost,
+		Repository: repository,
+		Reference:  src.Reference,
+	}
+	if err := ref.Validate(); err != nil {
+		return registry.Reference{}, fmt.Errorf("invalid target for image %s: %w", src, err)
+	}
+	return ref, nil
+}
@@ -1,16 +1,45 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	img "github.com/rancher/rancher/pkg/image"
+	"github.com/rancher/rancher/pkg/image/bundle"
 	"github.com/rancher/rancher/pkg/image/utilities"
 )
 
+const usage = `Usage:
+  go run main.go [SYSTEM_CHART_PATH] [CHART_PATH] [OPTIONAL]...
+      Writes the image lists and the docker scripts to save, load and mirror them.
+  go run main.go bundle [FLAGS] [SYSTEM_CHART_PATH] [CHART_PATH] [OPTIONAL]...
+      Pulls the images, charts and KDM data into an air-gap bundle.
+  go run main.go push [FLAGS] [BUNDLE] [REGISTRY]
+      Loads the images of an air-gap bundle into a registry.`
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bundle":
+			if err := runBundle(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "push":
+			if err := runPush(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	if len(os.Args) < 3 {
-		log.Fatal("\"main.go\" requires 2 arguments. Usage: go run main.go [SYSTEM_CHART_PATH] [CHART_PATH] [OPTIONAL]...")
+		log.Fatal("\"main.go\" requires 2 arguments. " + usage)
 	}
 
 	if err := run(os.Args[1], os.Args[2], os.Args[3:]); err != nil {
@@ -65,3 +94,116 @@ type imageTextLists struct {
 
 	return nil
 }
+
+// registryFlags registers the flags that configure how a registry is accessed. Credentials default
+// to the REGISTRY_USERNAME and REGISTRY_PASSWORD environment variables.
+func registryFlags(fs *flag.FlagSet, opts *bundle.RegistryOptions) {
+	fs.StringVar(&opts.Username, "username", os.Getenv("REGISTRY_USERNAME"), "registry username, the docker config is used when empty")
+	fs.StringVar(&opts.Password, "password", os.Getenv("REGISTRY_PASSWORD"), "registry password")
+	fs.BoolVar(&opts.PlainHTTP, "plain-http", false, "access the registry over HTTP")
+	fs.BoolVar(&opts.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "skip the verification of the registry certificate")
+}
+
+func runBundle(args []string) error {
+	opts := bundle.BuildOptions{}
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	output := fs.String("output", "rancher-bundle.tar", "path of the bundle tarball, or of the bundle directory if it ends with /")
+	platform := fs.String("platform", "", "restrict the linux images to a platform, i.e. linux/amd64")
+	fs.IntVar(&opts.Attempts, "attempts", 3, "number of times pulling an image is attempted")
+	registryFlags(fs, &opts.Source)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("\"bundle\" requires 2 arguments. %s", usage)
+	}
+	systemChartsPath, chartsPath := fs.Arg(0), fs.Arg(1)
+
+	if *platform != "" {
+		p, err := parsePlatform(*platform)
+		if err != nil {
+			return err
+		}
+		opts.Platform = p
+	}
+
+	targetsAndSources, err := utilities.GatherTargetImagesAndSources(systemChartsPath, chartsPath, fs.Args()[2:])
+	if err != nil {
+		return err
+	}
+
+	opts.RancherVersion = os.Getenv("TAG")
+	opts.Images = map[string][]string{
+		"linux":   utilities.SaveImages(targetsAndSources.TargetLinuxImages),
+		"windows": utilities.SaveImages(targetsAndSources.TargetWindowsImages),
+	}
+	opts.Files = map[string]string{
+		"system-charts": systemChartsPath,
+		"charts":        chartsPath,
+		"data.json":     utilities.KDMDataPath(),
+	}
+
+	opts.Dir = *output
+	archive := !strings.HasSuffix(*output, "/")
+	if archive {
+		if opts.Dir, err = os.MkdirTemp("", "rancher-bundle-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(opts.Dir)
+	}
+
+	manifest, err := bundle.Build(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	log.Printf("Bundled %d images and %d files\n", len(manifest.Images), len(manifest.Files))
+
+	if !archive {
+		return nil
+	}
+	log.Printf("Creating %s\n", *output)
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Archive(opts.Dir, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runPush(args []string) error {
+	opts := bundle.PushOptions{}
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	fs.IntVar(&opts.Attempts, "attempts", 3, "number of times pushing an image is attempted")
+	registryFlags(fs, &opts.Target)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("\"push\" requires 2 arguments. %s", usage)
+	}
+	opts.Registry = fs.Arg(1)
+
+	dir, cleanup, err := bundle.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return bundle.Push(context.Background(), dir, opts)
+}
+
+// parsePlatform parses a platform in the os/arch[/variant] format.
+func parsePlatform(platform string) (*ocispec.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid platform %s, expected os/arch[/variant]", platform)
+	}
+	p := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
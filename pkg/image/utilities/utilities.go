@@ -69,11 +69,7 @@ func GatherTargetImagesAndSources(systemChartsPath, chartsPath string, imagesFro
 	}
 	rancherVersion = strings.TrimPrefix(rancherVersion, "v")
 
-	// already downloaded in dapper
-	b, err := os.ReadFile(filepath.Join("data.json"))
-	if os.IsNotExist(err) {
-		b, err = os.ReadFile(filepath.Join(os.Getenv("HOME"), "bin", "data.json"))
-	}
+	b, err := os.ReadFile(KDMDataPath())
 	if err != nil {
 		return ImageTargetsAndSources{}, fmt.Errorf("could not read data.json: %w", err)
 	}
@@ -162,6 +158,15 @@ func GatherTargetImagesAndSources(systemChartsPath, chartsPath string, imagesFro
 	}, nil
 }
 
+// KDMDataPath returns the path of the KDM data.json file, which is already downloaded in dapper.
+// The file in the working directory takes precedence over the one in $HOME/bin.
+func KDMDataPath() string {
+	if _, err := os.Stat("data.json"); err == nil {
+		return "data.json"
+	}
+	return filepath.Join(os.Getenv("HOME"), "bin", "data.json")
+}
+
 // LoadScript produces executable files for Linux and Windows
 // which will load all images used by Rancher into a given image repository.
 func LoadScript(arch string, targetImages []string) error {
@@ -208,7 +213,7 @@ func ImagesText(arch string, targetImages []string) error {
 	defer save.Close()
 	save.Chmod(0755)
 
-	for _, image := range saveImages(targetImages) {
+	for _, image := range SaveImages(targetImages) {
 		err := checkImage(image)
 		if err != nil {
 			return err
@@ -274,7 +279,9 @@ func MirrorScript(arch string, targetImages []string) error {
 	return nil
 }
 
-func saveImages(targetImages []string) []string {
+// SaveImages returns the images that are saved for air-gapped installs, which are
+// the target images that have a mirror.
+func SaveImages(targetImages []string) []string {
 	var saveImages []string
 	for _, targetImage := range targetImages {
 		_, ok := image.Mirrors[targetImage]
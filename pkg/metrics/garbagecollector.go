@@ -6,9 +6,11 @@
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/rancher/rancher/pkg/api/steve/proxy"
 	v1 "github.com/rancher/rancher/pkg/generated/norman/core/v1"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	rm "github.com/rancher/remotedialer/metrics"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/labels"
@@ -20,6 +22,11 @@
 		rm.TotalTransmitBytesOnWS, rm.TotalTransmitErrorBytesOnWS, rm.TotalReceiveBytesOnWS,
 	}
 
+	targetTunnelMetricsForClientKey = []interface{}{
+		tunnelserver.TotalSessionConnects, tunnelserver.TotalSessionDisconnects, tunnelserver.TotalSessionReceiveBytes,
+		tunnelserver.TotalSessionTransmitBytes, tunnelserver.SessionRoundTripSeconds,
+	}
+
 	targetMetricsByIPForPeer = []interface{}{
 		rm.TotalAddPeerAttempt, rm.TotalPeerConnected, rm.TotalPeerDisConnected,
 	}
@@ -51,6 +58,8 @@ func (gc *metricGarbageCollector) metricGarbageCollection() {
 		if _, ok := observedResourceNames[cluster.Name]; !ok {
 			observedResourceNames[cluster.Name] = true
 		}
+		// the cluster agent opens a second tunnel session for the steve proxy
+		observedResourceNames[proxy.Prefix+cluster.Name] = true
 	}
 	// Get Nodes
 	nodes, err := gc.nodeLister.List("", labels.Everything())
@@ -83,6 +92,7 @@ func (gc *metricGarbageCollector) metricGarbageCollection() {
 	}
 
 	buildObservedLabelMaps(targetMetricsByNameForClientKey, "clientkey", observedLabelsMap)
+	buildObservedLabelMaps(targetTunnelMetricsForClientKey, "clientkey", observedLabelsMap)
 	buildObservedLabelMaps(targetMetricsByIPForPeer, "peer", observedLabelsMap)
 	buildObservedLabelMaps([]interface{}{clusterOwner}, "cluster", observedLabelsMap)
 
@@ -152,6 +162,12 @@ func removeMetricsForDeletedResource(observedMetrics map[string]map[interface{}]
 					} else {
 						logrus.Errorf("[metrics-garbage-collector] failed to delete %T metrics related to %s: %v", v, m, label)
 					}
+				case *prometheus.HistogramVec:
+					if v.Delete(label) {
+						removedCount++
+					} else {
+						logrus.Errorf("[metrics-garbage-collector] failed to delete %T metrics related to %s: %v", v, m, label)
+					}
 				default:
 					logrus.Errorf("[metrics-garbage-collector] saw unknown Metric definition %T", v)
 				}
@@ -13,6 +13,7 @@
 	"github.com/rancher/rancher/pkg/auth/util"
 	"github.com/rancher/rancher/pkg/clustermanager"
 	"github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/pkg/tunnelserver"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/wrangler/v2/pkg/ticker"
 	authV1 "k8s.io/api/authorization/v1"
@@ -222,6 +223,9 @@ func Register(ctx context.Context, scaledContext *config.ScaledContext) {
 	// Cluster Owner
 	prometheus.MustRegister(clusterOwner)
 
+	// Agent tunnel sessions
+	tunnelserver.RegisterMetrics()
+
 	// node and node core metrics
 	prometheus.MustRegister(numNodes)
 	prometheus.MustRegister(numCores)
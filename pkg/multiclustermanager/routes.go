@@ -14,6 +14,9 @@
 	"github.com/rancher/rancher/pkg/api/norman/customization/oci"
 	"github.com/rancher/rancher/pkg/api/norman/customization/vsphere"
 	managementapi "github.com/rancher/rancher/pkg/api/norman/server"
+	"github.com/rancher/rancher/pkg/api/steve/cloudcredentials"
+	"github.com/rancher/rancher/pkg/api/steve/clusterownership"
+	"github.com/rancher/rancher/pkg/api/steve/diagnostics"
 	"github.com/rancher/rancher/pkg/api/steve/supportconfigs"
 	"github.com/rancher/rancher/pkg/auth/providers/publicapi"
 	"github.com/rancher/rancher/pkg/auth/providers/saml"
@@ -23,7 +26,6 @@
 	"github.com/rancher/rancher/pkg/auth/webhook"
 	"github.com/rancher/rancher/pkg/channelserver"
 	"github.com/rancher/rancher/pkg/clustermanager"
-	rancherdialer "github.com/rancher/rancher/pkg/dialer"
 	"github.com/rancher/rancher/pkg/httpproxy"
 	k8sProxyPkg "github.com/rancher/rancher/pkg/k8sproxy"
 	"github.com/rancher/rancher/pkg/metrics"
@@ -40,7 +42,7 @@
 func router(ctx context.Context, localClusterEnabled bool, tunnelAuthorizer *mcmauthorizer.Authorizer, scaledContext *config.ScaledContext, clusterManager *clustermanager.Manager) (func(http.Handler) http.Handler, error) {
 	var (
 		k8sProxy             = k8sProxyPkg.New(scaledContext, scaledContext.Dialer, clusterManager)
-		connectHandler       = scaledContext.Dialer.(*rancherdialer.Factory).TunnelServer
+		connectHandler       = scaledContext.Wrangler.TunnelSessions
 		connectConfigHandler = rkenodeconfigserver.Handler(tunnelAuthorizer, scaledContext)
 		clusterImport        = clusterregistrationtokens.ClusterImport{Clusters: scaledContext.Management.Clusters("")}
 	)
@@ -70,6 +72,10 @@ func router(ctx context.Context, localClusterEnabled bool, tunnelAuthorizer *mcm
 	channelserver := channelserver.NewHandler(ctx)
 
 	supportConfigGenerator := supportconfigs.NewHandler(scaledContext)
+	diagnosticBundleGenerator, err := diagnostics.NewHandler(scaledContext)
+	if err != nil {
+		return nil, err
+	}
 	// Unauthenticated routes
 	unauthed := mux.NewRouter()
 	unauthed.UseEncodedPath()
@@ -118,6 +124,9 @@ func router(ctx context.Context, localClusterEnabled bool, tunnelAuthorizer *mcm
 	authed.Path("/v3/tokenreview").Methods(http.MethodPost).Handler(&webhook.TokenReviewer{})
 	authed.Path("/metrics/{clusterID}").Handler(metricsHandler)
 	authed.Path(supportconfigs.Endpoint).Handler(&supportConfigGenerator)
+	authed.Path(diagnostics.Endpoint).Methods(http.MethodGet).Handler(diagnosticBundleGenerator)
+	authed.Path(cloudcredentials.Endpoint).Handler(cloudcredentials.NewHandler(scaledContext))
+	authed.Path(clusterownership.Endpoint).Handler(clusterownership.NewHandler(scaledContext, clusterManager.Owners))
 	authed.PathPrefix("/meta/proxy").Handler(metaProxy)
 	authed.PathPrefix("/v1-telemetry").Handler(telemetry.NewProxy())
 	authed.PathPrefix("/v3/identit").Handler(tokenAPI)
@@ -0,0 +1,208 @@
+package peermanager
+
+import (
+	"hash/crc32"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loadFactor bounds how many clusters a replica owns above the average.
+	loadFactor = 0.25
+	// reportGracePeriod is how long a new peer may take to report its assignment version before it is assumed to run
+	// a version that assigns clusters the legacy way.
+	reportGracePeriod = 2 * time.Minute
+)
+
+// Owners assigns every cluster to the Rancher replica that runs its owner controllers. Clusters are spread over the
+// ready replicas that are not drained with consistent hashing of their UID with bounded loads, so that a replica joining
+// or leaving mostly moves the clusters it gains or loses and no replica owns much more than its share.
+//
+// Replicas are identified by the names of their pods. As long as a peer does not report supporting AssignmentVersion,
+// clusters are assigned the way older versions do, so that replicas of different versions agree during an upgrade.
+type Owners struct {
+	sync.RWMutex
+	peers     Peers
+	drained   map[string]bool
+	versions  map[string]string
+	firstSeen map[string]time.Time
+	ring      bool
+	now       func() time.Time
+}
+
+func NewOwners() *Owners {
+	return &Owners{
+		drained:   map[string]bool{},
+		versions:  map[string]string{},
+		firstSeen: map[string]time.Time{},
+		now:       time.Now,
+	}
+}
+
+// SetPeers updates the replicas clusters are assigned to. The IDs of peers must include SelfID.
+func (o *Owners) SetPeers(peers Peers) {
+	o.Lock()
+	defer o.Unlock()
+	peers.IDs = append([]string(nil), peers.IDs...)
+	sort.Strings(peers.IDs)
+	o.peers = peers
+
+	firstSeen := map[string]time.Time{}
+	for _, name := range o.names() {
+		if seen, ok := o.firstSeen[name]; ok {
+			firstSeen[name] = seen
+		} else {
+			firstSeen[name] = o.now()
+		}
+	}
+	o.firstSeen = firstSeen
+}
+
+// SetDrained updates the names of the replicas that must not own clusters.
+func (o *Owners) SetDrained(names []string) {
+	o.Lock()
+	defer o.Unlock()
+	o.drained = map[string]bool{}
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			o.drained[name] = true
+		}
+	}
+}
+
+// SetReports updates the assignment versions the replicas support from their reports.
+func (o *Owners) SetReports(reports []OwnerReport) {
+	o.Lock()
+	defer o.Unlock()
+	o.versions = map[string]string{}
+	for _, report := range reports {
+		o.versions[report.Replica] = report.Version
+	}
+}
+
+// Peers returns the replicas known to this replica.
+func (o *Owners) Peers() Peers {
+	o.RLock()
+	defer o.RUnlock()
+	return o.peers
+}
+
+// Self returns the name of this replica.
+func (o *Owners) Self() string {
+	o.RLock()
+	defer o.RUnlock()
+	return o.name(o.peers.SelfID)
+}
+
+// Names returns the sorted names of the replicas known to this replica.
+func (o *Owners) Names() []string {
+	o.RLock()
+	defer o.RUnlock()
+	return o.names()
+}
+
+// IsDrained returns true if the replica with the given name must not own clusters.
+func (o *Owners) IsDrained(name string) bool {
+	o.RLock()
+	defer o.RUnlock()
+	return o.drained[name]
+}
+
+// Assignment returns AssignmentVersion if clusters are assigned with it, or "legacy" if some replica does not support
+// it yet.
+func (o *Owners) Assignment() string {
+	o.RLock()
+	defer o.RUnlock()
+	if o.ring {
+		return AssignmentVersion
+	}
+	return "legacy"
+}
+
+// Assign returns the name of the replica that owns each of the clusters with the given UIDs. It returns nil while this
+// replica does not know the replicas yet.
+func (o *Owners) Assign(clusterUIDs []string) map[string]string {
+	o.Lock()
+	defer o.Unlock()
+
+	if !o.peers.Ready || len(o.peers.IDs) == 0 || (len(o.peers.IDs) == 1 && !o.peers.Leader) {
+		return nil
+	}
+
+	o.ring = o.useRing()
+	if !o.ring {
+		owners := make(map[string]string, len(clusterUIDs))
+		for _, uid := range clusterUIDs {
+			owners[uid] = o.name(legacyOwner(o.peers.IDs, uid))
+		}
+		return owners
+	}
+
+	var candidates []string
+	for _, name := range o.names() {
+		if !o.drained[name] {
+			candidates = append(candidates, name)
+		}
+	}
+	// never leave clusters without an owner, even if every replica is drained
+	if len(candidates) == 0 {
+		candidates = o.names()
+	}
+	return NewRing(candidates).Assign(clusterUIDs, loadFactor)
+}
+
+// useRing returns true once every peer reports supporting AssignmentVersion. Peers that did not report yet keep the
+// current assignment for reportGracePeriod, so that replacing a replica does not move every cluster back and forth.
+func (o *Owners) useRing() bool {
+	pending := false
+	for _, name := range o.names() {
+		version, ok := o.versions[name]
+		switch {
+		case ok && version == AssignmentVersion:
+		case ok:
+			return false
+		case o.now().Sub(o.firstSeen[name]) > reportGracePeriod:
+			return false
+		default:
+			pending = true
+		}
+	}
+	if pending {
+		return o.ring
+	}
+	return true
+}
+
+func (o *Owners) names() []string {
+	names := make([]string, 0, len(o.peers.IDs))
+	for _, id := range o.peers.IDs {
+		names = append(names, o.name(id))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// name returns the name of the replica with the given ID, or the ID if its name is not known.
+func (o *Owners) name(id string) string {
+	if id == o.peers.SelfID && o.peers.SelfName != "" {
+		return o.peers.SelfName
+	}
+	if name := o.peers.Names[id]; name != "" {
+		return name
+	}
+	return id
+}
+
+// legacyOwner returns the ID of the replica that owns the cluster with the given UID in versions before
+// AssignmentVersion. ids must be sorted.
+func legacyOwner(ids []string, clusterUID string) string {
+	ck := crc32.ChecksumIEEE([]byte(clusterUID))
+	if ck == math.MaxUint32 {
+		ck--
+	}
+	scaled := int(ck) * len(ids) / math.MaxUint32
+	return ids[scaled]
+}
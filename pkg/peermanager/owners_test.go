@@ -0,0 +1,156 @@
+package peermanager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPeers = Peers{
+	SelfID:   "10.0.0.1",
+	SelfName: "rancher-a",
+	IDs:      []string{"10.0.0.2", "10.0.0.1", "10.0.0.3"},
+	Names:    map[string]string{"10.0.0.2": "rancher-b", "10.0.0.3": "rancher-c"},
+	Ready:    true,
+}
+
+func testUIDs(n int) []string {
+	uids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		uids = append(uids, fmt.Sprintf("uid-%d", i))
+	}
+	return uids
+}
+
+func reportAll(owners *Owners, version string) {
+	owners.SetReports([]OwnerReport{
+		{Replica: "rancher-a", Version: version},
+		{Replica: "rancher-b", Version: version},
+		{Replica: "rancher-c", Version: version},
+	})
+}
+
+func TestOwnersNotReady(t *testing.T) {
+	owners := NewOwners()
+	assert.Nil(t, owners.Assign([]string{"uid"}))
+
+	owners.SetPeers(Peers{SelfID: "10.0.0.1", IDs: []string{"10.0.0.1", "10.0.0.2"}})
+	assert.Nil(t, owners.Assign([]string{"uid"}), "no owner before the peers are ready")
+
+	owners.SetPeers(Peers{SelfID: "10.0.0.1", IDs: []string{"10.0.0.1"}, Ready: true})
+	assert.Nil(t, owners.Assign([]string{"uid"}), "a single replica that is not the leader must not own clusters")
+
+	owners.SetPeers(Peers{SelfID: "10.0.0.1", SelfName: "rancher-a", IDs: []string{"10.0.0.1"}, Ready: true, Leader: true})
+	assert.Equal(t, map[string]string{"uid": "rancher-a"}, owners.Assign([]string{"uid"}))
+}
+
+func TestOwnersNames(t *testing.T) {
+	owners := NewOwners()
+	owners.SetPeers(testPeers)
+	assert.Equal(t, "rancher-a", owners.Self())
+	assert.Equal(t, []string{"rancher-a", "rancher-b", "rancher-c"}, owners.Names())
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, owners.Peers().IDs)
+}
+
+func TestOwnersLegacyAssignment(t *testing.T) {
+	owners := NewOwners()
+	owners.SetPeers(testPeers)
+	owners.SetDrained([]string{"rancher-a"})
+
+	// until every peer reports the assignment version, clusters are assigned like older versions do
+	owners.SetReports([]OwnerReport{{Replica: "rancher-a", Version: AssignmentVersion}})
+	uids := testUIDs(100)
+	assignment := owners.Assign(uids)
+	assert.Equal(t, "legacy", owners.Assignment())
+	names := map[string]string{"10.0.0.1": "rancher-a", "10.0.0.2": "rancher-b", "10.0.0.3": "rancher-c"}
+	for _, uid := range uids {
+		assert.Equal(t, names[legacyOwner([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, uid)], assignment[uid], uid)
+	}
+
+	reportAll(owners, AssignmentVersion)
+	owners.Assign(uids)
+	assert.Equal(t, AssignmentVersion, owners.Assignment())
+
+	// a replica supporting another version is running a different Rancher version
+	owners.SetReports([]OwnerReport{
+		{Replica: "rancher-a", Version: AssignmentVersion},
+		{Replica: "rancher-b", Version: AssignmentVersion},
+		{Replica: "rancher-c", Version: "other"},
+	})
+	owners.Assign(uids)
+	assert.Equal(t, "legacy", owners.Assignment())
+}
+
+func TestOwnersNewPeerGracePeriod(t *testing.T) {
+	now := time.Now()
+	owners := NewOwners()
+	owners.now = func() time.Time { return now }
+	owners.SetPeers(testPeers)
+	reportAll(owners, AssignmentVersion)
+	owners.Assign(testUIDs(10))
+	require.Equal(t, AssignmentVersion, owners.Assignment())
+
+	// a replica replacing another one keeps the assignment while it did not report yet
+	peers := testPeers
+	peers.Names = map[string]string{"10.0.0.2": "rancher-b", "10.0.0.4": "rancher-d"}
+	peers.IDs = []string{"10.0.0.1", "10.0.0.2", "10.0.0.4"}
+	owners.SetPeers(peers)
+	owners.Assign(testUIDs(10))
+	assert.Equal(t, AssignmentVersion, owners.Assignment())
+
+	now = now.Add(reportGracePeriod + time.Second)
+	owners.Assign(testUIDs(10))
+	assert.Equal(t, "legacy", owners.Assignment(), "a replica that never reports runs an older version")
+}
+
+func TestOwnersDrained(t *testing.T) {
+	owners := NewOwners()
+	owners.SetPeers(testPeers)
+	reportAll(owners, AssignmentVersion)
+
+	owners.SetDrained([]string{" rancher-a", ""})
+	assert.True(t, owners.IsDrained("rancher-a"))
+	uids := testUIDs(100)
+	for uid, owner := range owners.Assign(uids) {
+		assert.NotEqual(t, "rancher-a", owner, uid)
+	}
+
+	// clusters keep an owner even if every replica is drained
+	owners.SetDrained([]string{"rancher-a", "rancher-b", "rancher-c"})
+	assert.Len(t, owners.Assign(uids), len(uids))
+
+	owners.SetDrained(nil)
+	assert.False(t, owners.IsDrained("rancher-a"))
+}
+
+func TestOwnersBalanced(t *testing.T) {
+	owners := NewOwners()
+	owners.SetPeers(testPeers)
+	reportAll(owners, AssignmentVersion)
+
+	counts := map[string]int{}
+	for _, owner := range owners.Assign(testUIDs(30)) {
+		counts[owner]++
+	}
+	for _, name := range owners.Names() {
+		assert.LessOrEqual(t, counts[name], 13, name)
+	}
+}
+
+func TestOwnerReportConfigMap(t *testing.T) {
+	report := OwnerReport{Replica: "rancher-a", Version: AssignmentVersion, Clusters: []string{"local", "c-m-abc"}}
+	configMap := NewOwnerReportConfigMap("cattle-system", report)
+	assert.Equal(t, "cattle-cluster-owners-rancher-a", configMap.Name)
+	assert.Equal(t, "cattle-system", configMap.Namespace)
+
+	parsed, ok := OwnerReportFromConfigMap(configMap)
+	require.True(t, ok)
+	assert.Equal(t, OwnerReport{Replica: "rancher-a", Version: AssignmentVersion, Clusters: []string{"c-m-abc", "local"}}, parsed)
+
+	configMap.Labels = nil
+	_, ok = OwnerReportFromConfigMap(configMap)
+	assert.False(t, ok)
+}
@@ -5,6 +5,10 @@ type Peers struct {
 	IDs    []string
 	Ready  bool
 	Leader bool
+	// SelfName and Names are the names of the pods of this replica and of the peers by ID. Unlike the IDs, which are
+	// pod IPs, a name is never reused by another replica.
+	SelfName string
+	Names    map[string]string
 }
 
 type PeerManager interface {
@@ -12,4 +16,6 @@ type PeerManager interface {
 	Leader()
 	AddListener(l chan<- Peers)
 	RemoveListener(l chan<- Peers)
+	// Resync reads the peers from the API server instead of the cache and notifies the listeners.
+	Resync() error
 }
@@ -0,0 +1,72 @@
+package peermanager
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// OwnerReportLabel marks the ConfigMaps in which every replica reports the clusters it runs the owner controllers of.
+	OwnerReportLabel = "cattle.io/cluster-owner-report"
+	// AssignmentVersion identifies the way Owners assigns clusters to replicas. Replicas only use it once every peer
+	// reports supporting it, until then clusters are assigned the way older versions do.
+	AssignmentVersion = "bounded-ring-v1"
+
+	ownerReportPrefix   = "cattle-cluster-owners-"
+	ownerReportReplica  = "replica"
+	ownerReportVersion  = "version"
+	ownerReportClusters = "clusters"
+)
+
+// OwnerReport is what a replica reports about the clusters it owns.
+type OwnerReport struct {
+	// Replica is the name of the replica.
+	Replica string
+	// Version is the assignment version the replica supports.
+	Version string
+	// Clusters are the names of the clusters the replica runs the owner controllers of.
+	Clusters []string
+}
+
+// OwnerReportName returns the name of the ConfigMap the given replica reports in.
+func OwnerReportName(replica string) string {
+	return ownerReportPrefix + replica
+}
+
+// NewOwnerReportConfigMap returns the ConfigMap holding the report.
+func NewOwnerReportConfigMap(namespace string, report OwnerReport) *corev1.ConfigMap {
+	clusters := append([]string(nil), report.Clusters...)
+	sort.Strings(clusters)
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      OwnerReportName(report.Replica),
+			Namespace: namespace,
+			Labels: map[string]string{
+				OwnerReportLabel: "true",
+			},
+		},
+		Data: map[string]string{
+			ownerReportReplica:  report.Replica,
+			ownerReportVersion:  report.Version,
+			ownerReportClusters: strings.Join(clusters, "\n"),
+		},
+	}
+}
+
+// OwnerReportFromConfigMap returns the report held by the ConfigMap, or false if it does not hold one.
+func OwnerReportFromConfigMap(configMap *corev1.ConfigMap) (OwnerReport, bool) {
+	if configMap == nil || configMap.Labels[OwnerReportLabel] != "true" || configMap.Data[ownerReportReplica] == "" {
+		return OwnerReport{}, false
+	}
+	report := OwnerReport{
+		Replica: configMap.Data[ownerReportReplica],
+		Version: configMap.Data[ownerReportVersion],
+	}
+	if clusters := configMap.Data[ownerReportClusters]; clusters != "" {
+		report.Clusters = strings.Split(clusters, "\n")
+	}
+	return report, true
+}
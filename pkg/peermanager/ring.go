@@ -0,0 +1,107 @@
+package peermanager
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// pointsPerPeer is the number of positions of every peer on the ring. More positions spread the keys more evenly.
+const pointsPerPeer = 128
+
+// Ring assigns keys to peers with consistent hashing. Adding or removing a peer only moves the keys that the peer
+// gains or loses, the other keys keep their owner.
+type Ring struct {
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash uint64
+	id   string
+}
+
+// NewRing returns a ring of the given peer IDs.
+func NewRing(ids []string) *Ring {
+	r := &Ring{}
+	for _, id := range ids {
+		for i := 0; i < pointsPerPeer; i++ {
+			r.points = append(r.points, ringPoint{
+				hash: hash(id + "#" + strconv.Itoa(i)),
+				id:   id,
+			})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash == r.points[j].hash {
+			return r.points[i].id < r.points[j].id
+		}
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// Owner returns the ID of the peer that owns key, or an empty string if the ring has no peers.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].id
+}
+
+// Assign assigns every key to a peer with consistent hashing with bounded loads: a key goes to the first peer clockwise
+// from it that owns less than the average number of keys times 1+loadFactor. Unlike Owner this never leaves a peer with
+// a disproportionate share of the keys, at the cost of moving some more keys when peers or keys change.
+func (r *Ring) Assign(keys []string, loadFactor float64) map[string]string {
+	owners := make(map[string]string, len(keys))
+	peers := len(r.points) / pointsPerPeer
+	if peers == 0 || len(keys) == 0 {
+		return owners
+	}
+	capacity := int(math.Ceil(float64(len(keys)) * (1 + loadFactor) / float64(peers)))
+
+	type hashedKey struct {
+		key  string
+		hash uint64
+	}
+	hashed := make([]hashedKey, 0, len(keys))
+	for _, key := range keys {
+		hashed = append(hashed, hashedKey{key: key, hash: hash(key)})
+	}
+	// the order in which keys fill up the peers must not depend on the order of the keys
+	sort.Slice(hashed, func(i, j int) bool {
+		if hashed[i].hash == hashed[j].hash {
+			return hashed[i].key < hashed[j].key
+		}
+		return hashed[i].hash < hashed[j].hash
+	})
+
+	load := map[string]int{}
+	for _, k := range hashed {
+		start := sort.Search(len(r.points), func(i int) bool {
+			return r.points[i].hash >= k.hash
+		})
+		for i := 0; i < len(r.points); i++ {
+			id := r.points[(start+i)%len(r.points)].id
+			if load[id] < capacity {
+				owners[k.key] = id
+				load[id]++
+				break
+			}
+		}
+	}
+	return owners
+}
+
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
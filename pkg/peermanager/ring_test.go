@@ -0,0 +1,70 @@
+package peermanager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingOwner(t *testing.T) {
+	assert.Equal(t, "", NewRing(nil).Owner("c-abc"))
+	assert.Equal(t, "10.0.0.1", NewRing([]string{"10.0.0.1"}).Owner("c-abc"))
+
+	ring := NewRing([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	assert.Equal(t, ring.Owner("c-abc"), NewRing([]string{"10.0.0.3", "10.0.0.1", "10.0.0.2"}).Owner("c-abc"),
+		"owner must not depend on the order of the peers")
+}
+
+func TestRingBalance(t *testing.T) {
+	peers := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	ring := NewRing(peers)
+
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		counts[ring.Owner(fmt.Sprintf("cluster-uid-%d", i))]++
+	}
+	for _, peer := range peers {
+		// each peer should own about a third of the keys
+		assert.InDelta(t, 1000, counts[peer], 250, peer)
+	}
+}
+
+func TestRingRemovePeerOnlyMovesItsKeys(t *testing.T) {
+	before := NewRing([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	after := NewRing([]string{"10.0.0.1", "10.0.0.2"})
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("cluster-uid-%d", i)
+		if owner := before.Owner(key); owner != "10.0.0.3" {
+			assert.Equal(t, owner, after.Owner(key), key)
+		}
+	}
+}
+
+func TestRingAssignBoundedLoad(t *testing.T) {
+	peers := []string{"rancher-a", "rancher-b", "rancher-c"}
+	ring := NewRing(peers)
+
+	var keys []string
+	for i := 0; i < 300; i++ {
+		keys = append(keys, fmt.Sprintf("cluster-uid-%d", i))
+	}
+	owners := ring.Assign(keys, 0.1)
+	assert.Len(t, owners, len(keys))
+
+	counts := map[string]int{}
+	for _, owner := range owners {
+		counts[owner]++
+	}
+	for _, peer := range peers {
+		assert.LessOrEqual(t, counts[peer], 110, peer)
+	}
+
+	reversed := make([]string, len(keys))
+	for i, key := range keys {
+		reversed[len(keys)-1-i] = key
+	}
+	assert.Equal(t, owners, ring.Assign(reversed, 0.1), "owners must not depend on the order of the keys")
+	assert.Empty(t, NewRing(nil).Assign(keys, 0.1))
+}
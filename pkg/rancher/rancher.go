@@ -6,7 +6,9 @@
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
@@ -27,6 +29,8 @@
 	"github.com/rancher/rancher/pkg/controllers/dashboard/plugin"
 	"github.com/rancher/rancher/pkg/controllers/dashboardapi"
 	managementauth "github.com/rancher/rancher/pkg/controllers/management/auth"
+	"github.com/rancher/rancher/pkg/controllers/management/cloudcredential"
+	"github.com/rancher/rancher/pkg/controllers/management/clusterconnected"
 	"github.com/rancher/rancher/pkg/controllers/nodedriver"
 	provisioningv2 "github.com/rancher/rancher/pkg/controllers/provisioningv2/cluster"
 	"github.com/rancher/rancher/pkg/crds"
@@ -63,26 +67,39 @@
 	"k8s.io/client-go/util/retry"
 )
 
-const encryptionConfigUpdate = "provisioner.cattle.io/encrypt-migrated"
+const (
+	encryptionConfigUpdate = "provisioner.cattle.io/encrypt-migrated"
+	auditLogHMACSecretKey  = "key"
+)
 
 type Options struct {
-	ACMEDomains       cli.StringSlice
-	AddLocal          string
-	Embedded          bool
-	BindHost          string
-	HTTPListenPort    int
-	HTTPSListenPort   int
-	K8sMode           string
-	Debug             bool
-	Trace             bool
-	NoCACerts         bool
-	AuditLogPath      string
-	AuditLogMaxage    int
-	AuditLogMaxsize   int
-	AuditLogMaxbackup int
-	AuditLevel        int
-	Features          string
-	ClusterRegistry   string
+	ACMEDomains                  cli.StringSlice
+	AddLocal                     string
+	Embedded                     bool
+	BindHost                     string
+	HTTPListenPort               int
+	HTTPSListenPort              int
+	K8sMode                      string
+	Debug                        bool
+	Trace                        bool
+	NoCACerts                    bool
+	AuditLogPath                 string
+	AuditLogMaxage               int
+	AuditLogMaxsize              int
+	AuditLogMaxbackup            int
+	AuditLevel                   int
+	AuditLogSinks                cli.StringSlice
+	AuditLogWebhookURL           string
+	AuditLogWebhookCAFile        string
+	AuditLogWebhookBatchSize     int
+	AuditLogWebhookFlushInterval time.Duration
+	AuditLogSyslogAddress        string
+	AuditLogSyslogTLS            bool
+	AuditLogSyslogCAFile         string
+	AuditLogHashChain            bool
+	AuditLogHMACSecret           string
+	Features                     string
+	ClusterRegistry              string
 }
 
 type Rancher struct {
@@ -92,8 +109,12 @@ type Rancher struct {
 	Steve    *steveserver.Server
 
 	auditLog   *audit.LogWriter
+	auditOpts  audit.WriterOptions
 	authServer *auth.Server
 	opts       *Options
+
+	auditLock    sync.Mutex
+	auditApplied audit.WriterOptions
 }
 
 func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options) (*Rancher, error) {
@@ -153,6 +174,7 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 	podsecuritypolicytemplate.RegisterIndexers(wranglerContext)
 	kontainerdriver.RegisterIndexers(wranglerContext)
 	managementauth.RegisterWranglerIndexers(wranglerContext)
+	cloudcredential.RegisterIndexers(wranglerContext)
 
 	if features.ProvisioningV2.Enabled() {
 		// ensure indexers are registered for all replicas
@@ -226,7 +248,21 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 		return nil, err
 	}
 
-	auditLogWriter := audit.NewLogWriter(opts.AuditLogPath, audit.Level(opts.AuditLevel), opts.AuditLogMaxage, opts.AuditLogMaxbackup, opts.AuditLogMaxsize)
+	auditOpts := auditWriterOptions(opts)
+	if opts.AuditLogHMACSecret != "" {
+		secret, err := wranglerContext.Core.Secret().Get(namespace.System, opts.AuditLogHMACSecret, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get audit log HMAC secret %s/%s: %w", namespace.System, opts.AuditLogHMACSecret, err)
+		}
+		auditOpts.HMACKey = secret.Data[auditLogHMACSecretKey]
+		if len(auditOpts.HMACKey) == 0 {
+			return nil, fmt.Errorf("audit log HMAC secret %s/%s has no %q key", namespace.System, opts.AuditLogHMACSecret, auditLogHMACSecretKey)
+		}
+	}
+	auditLogWriter, err := audit.NewLogWriterWithOptions(audit.Level(opts.AuditLevel), auditOpts)
+	if err != nil {
+		return nil, err
+	}
 	auditFilter, err := audit.NewAuditLogMiddleware(auditLogWriter)
 	if err != nil {
 		return nil, err
@@ -253,11 +289,91 @@ func New(ctx context.Context, clientConfg clientcmd.ClientConfig, opts *Options)
 		Wrangler:   wranglerContext,
 		Steve:      steve,
 		auditLog:   auditLogWriter,
+		auditOpts:  auditOpts,
 		authServer: authServer,
 		opts:       opts,
 	}, nil
 }
 
+func auditWriterOptions(opts *Options) audit.WriterOptions {
+	// the flag may be repeated and each value may list several sinks separated by commas
+	var sinks []string
+	for _, value := range opts.AuditLogSinks.Value() {
+		sinks = append(sinks, splitAuditSinks(value)...)
+	}
+	if len(sinks) == 0 {
+		sinks = []string{audit.SinkFile}
+	}
+	return audit.WriterOptions{
+		Sinks:                sinks,
+		Path:                 opts.AuditLogPath,
+		MaxAge:               opts.AuditLogMaxage,
+		MaxBackup:            opts.AuditLogMaxbackup,
+		MaxSize:              opts.AuditLogMaxsize,
+		WebhookURL:           opts.AuditLogWebhookURL,
+		WebhookCAFile:        opts.AuditLogWebhookCAFile,
+		WebhookBatchSize:     opts.AuditLogWebhookBatchSize,
+		WebhookFlushInterval: opts.AuditLogWebhookFlushInterval,
+		SyslogAddress:        opts.AuditLogSyslogAddress,
+		SyslogTLS:            opts.AuditLogSyslogTLS,
+		SyslogCAFile:         opts.AuditLogSyslogCAFile,
+		HashChain:            opts.AuditLogHashChain,
+	}
+}
+
+func splitAuditSinks(value string) []string {
+	var sinks []string
+	for _, sink := range strings.Split(value, ",") {
+		if sink = strings.TrimSpace(sink); sink != "" {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
+
+// auditSettingsOptions applies the audit log settings on top of the options Rancher was started with. Settings that
+// are empty keep the value of the corresponding flag.
+func auditSettingsOptions(opts audit.WriterOptions) audit.WriterOptions {
+	if sinks := splitAuditSinks(settings.AuditLogSinks.Get()); len(sinks) > 0 {
+		opts.Sinks = sinks
+	}
+	if url := settings.AuditLogWebhookURL.Get(); url != "" {
+		opts.WebhookURL = url
+	}
+	if address := settings.AuditLogSyslogAddress.Get(); address != "" {
+		opts.SyslogAddress = address
+	}
+	opts.SyslogTLS = opts.SyslogTLS || settings.AuditLogSyslogTLS.Get() == "true"
+	return opts
+}
+
+// syncAuditLogSettings reconfigures the audit log sinks of this replica when the audit log settings change.
+func (r *Rancher) syncAuditLogSettings(_ string, setting *v3.Setting) (*v3.Setting, error) {
+	if setting == nil {
+		return nil, nil
+	}
+	switch setting.Name {
+	case settings.AuditLogSinks.Name, settings.AuditLogWebhookURL.Name, settings.AuditLogSyslogAddress.Name, settings.AuditLogSyslogTLS.Name:
+	default:
+		return setting, nil
+	}
+
+	r.auditLock.Lock()
+	defer r.auditLock.Unlock()
+
+	opts := auditSettingsOptions(r.auditOpts)
+	if reflect.DeepEqual(opts, r.auditApplied) {
+		return setting, nil
+	}
+	if err := r.auditLog.Reconfigure(opts); err != nil {
+		// the previous sinks are kept, so a bad value does not stop auditing
+		logrus.Errorf("[audit] failed to apply the audit log settings: %v", err)
+		return setting, nil
+	}
+	r.auditApplied = opts
+	return setting, nil
+}
+
 func (r *Rancher) Start(ctx context.Context) error {
 	if err := dashboardapi.Register(ctx, r.Wrangler); err != nil {
 		return err
@@ -278,6 +394,9 @@ func (r *Rancher) Start(ctx context.Context) error {
 		}
 	}
 
+	// Records the agent tunnel sessions handled by this replica, so it has to run on every replica
+	clusterconnected.RegisterConnectionHistory(ctx, r.Wrangler)
+
 	r.Wrangler.OnLeader(func(ctx context.Context) error {
 		if err := dashboarddata.Add(ctx, r.Wrangler, localClusterEnabled(r.opts), r.opts.AddLocal == "false", r.opts.Embedded); err != nil {
 			return err
@@ -297,6 +416,11 @@ func (r *Rancher) Start(ctx context.Context) error {
 
 	r.Wrangler.OnLeader(r.authServer.OnLeader)
 	r.auditLog.Start(ctx)
+	if r.auditLog != nil {
+		// Every replica writes its own audit log, so the sinks are reconfigured on all of them
+		r.auditApplied = r.auditOpts
+		r.Wrangler.Mgmt.Setting().OnChange(ctx, "audit-log-settings", r.syncAuditLogSettings)
+	}
 
 	return r.Wrangler.Start(ctx)
 }
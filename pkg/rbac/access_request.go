@@ -0,0 +1,24 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+)
+
+// AccessRequestTarget returns the cluster and project an AccessRequest is for. The project is empty for requests for
+// a cluster.
+func AccessRequestTarget(ar *v3.AccessRequest) (string, string, error) {
+	if ar.Spec.ProjectName == "" {
+		if ar.Spec.ClusterName == "" {
+			return "", "", fmt.Errorf("access request %s has neither a cluster nor a project", ar.Name)
+		}
+		return ar.Spec.ClusterName, "", nil
+	}
+	clusterName, projectName, ok := strings.Cut(ar.Spec.ProjectName, ":")
+	if !ok || clusterName == "" || projectName == "" {
+		return "", "", fmt.Errorf("invalid project name %s, expected <cluster name>:<project name>", ar.Spec.ProjectName)
+	}
+	return clusterName, projectName, nil
+}
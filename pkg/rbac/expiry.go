@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"fmt"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BindingExpiry returns the time at which a binding with the given ExpiresAt and TTL expires. ExpiresAt takes precedence
+// over TTL, which is relative to the creation of the binding. False is returned for bindings that do not expire.
+func BindingExpiry(obj metav1.Object, expiresAt *metav1.Time, ttl string) (time.Time, bool, error) {
+	if expiresAt != nil {
+		return expiresAt.Time, true, nil
+	}
+	if ttl == "" {
+		return time.Time{}, false, nil
+	}
+	d, err := ParseTTL(ttl)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return obj.GetCreationTimestamp().Add(d), true, nil
+}
+
+// ParseTTL parses the TTL of a binding, which must be a positive duration.
+func ParseTTL(ttl string) (time.Duration, error) {
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q: must be a positive duration such as 8h", ttl)
+	}
+	return d, nil
+}
+
+// IsBindingExpired returns true if a binding with the given ExpiresAt and TTL has expired at now. Bindings with an
+// invalid TTL are considered expired, so that a mistyped TTL never grants permanent access.
+func IsBindingExpired(obj metav1.Object, expiresAt *metav1.Time, ttl string, now time.Time) bool {
+	expiry, ok, err := BindingExpiry(obj, expiresAt, ttl)
+	if err != nil {
+		return true
+	}
+	return ok && !now.Before(expiry)
+}
+
+// IsGRBExpired returns true if the GlobalRoleBinding has expired at now.
+func IsGRBExpired(grb *v3.GlobalRoleBinding, now time.Time) bool {
+	return IsBindingExpired(grb, grb.ExpiresAt, grb.TTL, now)
+}
+
+// IsCRTBExpired returns true if the ClusterRoleTemplateBinding has expired at now.
+func IsCRTBExpired(crtb *v3.ClusterRoleTemplateBinding, now time.Time) bool {
+	return IsBindingExpired(crtb, crtb.ExpiresAt, crtb.TTL, now)
+}
+
+// IsPRTBExpired returns true if the ProjectRoleTemplateBinding has expired at now.
+func IsPRTBExpired(prtb *v3.ProjectRoleTemplateBinding, now time.Time) bool {
+	return IsBindingExpired(prtb, prtb.ExpiresAt, prtb.TTL, now)
+}
@@ -0,0 +1,110 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBindingExpiry(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := created.Add(2 * time.Hour)
+
+	tests := []struct {
+		name      string
+		expiresAt *metav1.Time
+		ttl       string
+		want      time.Time
+		wantOK    bool
+		wantErr   bool
+	}{
+		{
+			name: "no expiry",
+		},
+		{
+			name:      "expires at",
+			expiresAt: &metav1.Time{Time: expiresAt},
+			want:      expiresAt,
+			wantOK:    true,
+		},
+		{
+			name:   "ttl",
+			ttl:    "8h",
+			want:   created.Add(8 * time.Hour),
+			wantOK: true,
+		},
+		{
+			name:      "expires at takes precedence over ttl",
+			expiresAt: &metav1.Time{Time: expiresAt},
+			ttl:       "8h",
+			want:      expiresAt,
+			wantOK:    true,
+		},
+		{
+			name:    "invalid ttl",
+			ttl:     "eight hours",
+			wantErr: true,
+		},
+		{
+			name:    "negative ttl",
+			ttl:     "-1h",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crtb := &v3.ClusterRoleTemplateBinding{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Time{Time: created}},
+				ExpiresAt:  tt.expiresAt,
+				TTL:        tt.ttl,
+			}
+			got, ok, err := BindingExpiry(crtb, crtb.ExpiresAt, crtb.TTL)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.True(t, IsCRTBExpired(crtb, created), "bindings with an invalid ttl must be considered expired")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+			if ok {
+				assert.False(t, IsCRTBExpired(crtb, got.Add(-time.Second)))
+				assert.True(t, IsCRTBExpired(crtb, got))
+			} else {
+				assert.False(t, IsCRTBExpired(crtb, created.Add(100*365*24*time.Hour)))
+			}
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		ttl     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{ttl: "8h", want: 8 * time.Hour},
+		{ttl: "1h30m", want: 90 * time.Minute},
+		{ttl: "0s", wantErr: true},
+		{ttl: "-1h", wantErr: true},
+		{ttl: "8 hours", wantErr: true},
+		{ttl: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ttl, func(t *testing.T) {
+			got, err := ParseTTL(tt.ttl)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -443,6 +443,9 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 		MustImport(&Version, v3.SearchPrincipalsInput{}).
 		MustImport(&Version, v3.ChangePasswordInput{}).
 		MustImport(&Version, v3.SetPasswordInput{}).
+		MustImport(&Version, v3.TOTPEnrollment{}).
+		MustImport(&Version, v3.TOTPCodeInput{}).
+		MustImport(&Version, v3.RecoveryCodesOutput{}).
 		MustImportAndCustomize(&Version, v3.User{}, func(schema *types.Schema) {
 			schema.ResourceActions = map[string]types.Action{
 				"setpassword": {
@@ -450,12 +453,28 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 					Output: "user",
 				},
 				"refreshauthprovideraccess": {},
+				"resettotp":                 {},
+				"unlock":                    {},
 			}
 			schema.CollectionActions = map[string]types.Action{
 				"changepassword": {
 					Input: "changePasswordInput",
 				},
 				"refreshauthprovideraccess": {},
+				"enrolltotp": {
+					Output: "totpEnrollment",
+				},
+				"confirmtotp": {
+					Input:  "totpCodeInput",
+					Output: "recoveryCodesOutput",
+				},
+				"disabletotp": {
+					Input: "totpCodeInput",
+				},
+				"regeneraterecoverycodes": {
+					Input:  "totpCodeInput",
+					Output: "recoveryCodesOutput",
+				},
 			}
 		}).
 		MustImportAndCustomize(&Version, v3.AuthConfig{}, func(schema *types.Schema) {
@@ -625,7 +644,25 @@ func authnTypes(schemas *types.Schemas) *types.Schemas {
 			}
 			schema.CollectionMethods = []string{}
 			schema.ResourceMethods = []string{http.MethodGet, http.MethodPut}
-		})
+		}).
+		//Generic OAuth Config
+		MustImportAndCustomize(&Version, v3.GenericOAuthConfig{}, func(schema *types.Schema) {
+			schema.BaseType = "authConfig"
+			schema.ResourceActions = map[string]types.Action{
+				"disable": {},
+				"configureTest": {
+					Input:  "genericOAuthConfig",
+					Output: "genericOAuthConfigTestOutput",
+				},
+				"testAndApply": {
+					Input: "genericOAuthConfigApplyInput",
+				},
+			}
+			schema.CollectionMethods = []string{}
+			schema.ResourceMethods = []string{http.MethodGet, http.MethodPut}
+		}).
+		MustImport(&Version, v3.GenericOAuthConfigApplyInput{}).
+		MustImport(&Version, v3.GenericOAuthConfigTestOutput{})
 }
 
 func configSchema(schema *types.Schema) {
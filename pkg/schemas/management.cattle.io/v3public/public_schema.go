@@ -204,5 +204,18 @@ func authProvidersTypes(schemas *types.Schemas) *types.Schemas {
 			schema.CollectionMethods = []string{}
 			schema.ResourceMethods = []string{http.MethodGet}
 		}).
-		MustImport(&PublicVersion, v3.OIDCLogin{})
+		MustImport(&PublicVersion, v3.OIDCLogin{}).
+		// Generic OAuth provider
+		MustImportAndCustomize(&PublicVersion, v3.GenericOAuthProvider{}, func(schema *types.Schema) {
+			schema.BaseType = "authProvider"
+			schema.ResourceActions = map[string]types.Action{
+				"login": {
+					Input:  "genericOAuthLogin",
+					Output: "token",
+				},
+			}
+			schema.CollectionMethods = []string{}
+			schema.ResourceMethods = []string{http.MethodGet}
+		}).
+		MustImport(&PublicVersion, v3.GenericOAuthLogin{})
 }
@@ -136,15 +136,117 @@
 	Rke2DefaultVersion = NewSetting("rke2-default-version", "")
 	K3sDefaultVersion  = NewSetting("k3s-default-version", "")
 
+	// AccessRequestClusterApproverRoles is a comma separated list of the role templates whose members can approve or deny
+	// AccessRequests for a cluster and for the projects of that cluster.
+	AccessRequestClusterApproverRoles = NewSetting("access-request-cluster-approver-roles", "cluster-owner")
+
+	// AccessRequestProjectApproverRoles is a comma separated list of the role templates whose members can approve or deny
+	// AccessRequests for a project.
+	AccessRequestProjectApproverRoles = NewSetting("access-request-project-approver-roles", "project-owner")
+
+	// AccessRequestMaxDuration is the longest duration for which an AccessRequest can be approved.
+	AccessRequestMaxDuration = NewSetting("access-request-max-duration", "168h") // 7 days
+
 	// AuthTokenMaxTTLMinutes is the max allowable time to live for tokens. Excluding those created for UI sessions which is controlled by AuthUserSessionTTLMinutes.
 	AuthTokenMaxTTLMinutes = NewSetting("auth-token-max-ttl-minutes", "129600") // 90 days
 
+	// AuthTokenMaxIdleDays is the number of days after which tokens that have not been used are deleted. Tokens that were
+	// never used are considered idle since their creation. Usage is only recorded when a token authenticates a request to
+	// Rancher, so tokens that can be used against the authorized cluster endpoint of a downstream cluster are never purged
+	// for being idle. 0 disables idle expiry.
+	AuthTokenMaxIdleDays = NewSetting("auth-token-max-idle-days", "0")
+
+	// AuditLogPolicy is a JSON or YAML audit policy that selects the audit level and additional redacted fields per
+	// user, group, verb, resource and URI prefix. Requests not matched by a rule use the level the server was started with.
+	AuditLogPolicy = NewSetting("audit-log-policy", "")
+
+	// AuditLogSinks is a comma separated list of the destinations of audit records: file, stdout, webhook and syslog.
+	// The sinks the server was started with are used if it is empty.
+	AuditLogSinks = NewSetting("audit-log-sinks", "")
+
+	// AuditLogWebhookURL is the URL batches of audit records are POSTed to by the webhook sink. It overrides the URL the
+	// server was started with if it is set.
+	AuditLogWebhookURL = NewSetting("audit-log-webhook-url", "")
+
+	// AuditLogSyslogAddress is the host:port of the syslog server the syslog sink sends audit records to. It overrides the
+	// address the server was started with if it is set.
+	AuditLogSyslogAddress = NewSetting("audit-log-syslog-address", "")
+
+	// AuditLogSyslogTLS enables TLS for the syslog sink when it is "true", in addition to the server being started with it.
+	AuditLogSyslogTLS = NewSetting("audit-log-syslog-tls", "false")
+
 	// AuthUserInfoMaxAgeSeconds represents the maximum age of a users auth tokens before an auth provider group membership sync will be performed.
 	AuthUserInfoMaxAgeSeconds = NewSetting("auth-user-info-max-age-seconds", "3600") // 1 hour
 
+	// ClusterOwnerDrainedPeers is a comma separated list of the pod names of Rancher replicas that do not own any
+	// cluster, so that the controllers of their clusters move to the other replicas. It is ignored if no other replica is
+	// ready, and while some replica still assigns clusters the legacy way during an upgrade.
+	ClusterOwnerDrainedPeers = NewSetting("cluster-owner-drained-peers", "")
+
+	// ClusterOwnerRebalanceRequested is the time the cluster ownership was last rebalanced. Every replica reads its peers
+	// from the API server and recomputes which clusters it owns when it changes.
+	ClusterOwnerRebalanceRequested = NewSetting("cluster-owner-rebalance-requested", "")
+
+	// CloudCredentialValidationInterval is how often cloud credentials are tested against the API of their provider. 0
+	// disables the validation of cloud credentials.
+	CloudCredentialValidationInterval = NewSetting("cloud-credential-validation-interval", "6h")
+
+	// LocalMFARequired selects the local users that must log in with a TOTP code: "none", "admins" for users bound to a
+	// global role that allows all verbs on all resources, or "all". Users that must but did not enroll yet can't log in:
+	// they enroll from an authenticated session with the enrolltotp and confirmtotp actions, so the requirement should
+	// only be turned on once they did.
+	LocalMFARequired = NewSetting("local-mfa-required", "none")
+
+	// LoginMaxFailedAttempts is the number of consecutive failed password logins after which logins of a user are
+	// rejected for login-lockout-duration. Every failed attempt also delays the next attempt of the user exponentially,
+	// from one second up to login-lockout-duration. 0, the default, disables the tracking of failed logins per user: once
+	// enabled, anyone who knows a username, such as admin, can keep that user locked out.
+	LoginMaxFailedAttempts = NewSetting("login-max-failed-attempts", "0")
+
+	// LoginSourceMaxFailedAttempts is the number of failed password logins from a single client address after which
+	// logins from that address are rejected, until no attempt failed for login-lockout-duration. 0 disables the
+	// tracking of failed logins per address.
+	LoginSourceMaxFailedAttempts = NewSetting("login-source-max-failed-attempts", "100")
+
+	// LoginLockoutDuration is how long logins are rejected once a user or client address reached its maximum number of
+	// failed attempts, and how long failed attempts are remembered.
+	LoginLockoutDuration = NewSetting("login-lockout-duration", "15m")
+
+	// MachineSSHCertificates selects whether SSH access to provisioned machines uses short-lived certificates signed by
+	// a CA per cluster instead of the private key of the machine. The CA is added to the keys trusted by sshd of a
+	// machine once, with the trustSSHUserCA action of the machine, and the SSH keys download of a machine then contains
+	// a new key pair with a certificate instead of the key of the machine.
+	MachineSSHCertificates = NewSetting("machine-ssh-certificates", "false")
+
+	// MachineSSHCertificateTTL is how long the SSH certificates in the SSH keys download of a machine are valid.
+	MachineSSHCertificateTTL = NewSetting("machine-ssh-certificate-ttl", "1h")
+
+	// ShellRecordingDir is the directory that the input and output of cluster shell sessions are recorded in, along with
+	// the user and audit log entry of each session. Recordings are only available from every Rancher replica if the
+	// directory is on a shared volume. Empty disables the recording of shell sessions.
+	ShellRecordingDir = NewSetting("shell-recording-dir", "")
+
+	// ShellRecordingRetentionDays is the number of days after which the recordings of cluster shell sessions are
+	// deleted. 0 keeps recordings forever.
+	ShellRecordingRetentionDays = NewSetting("shell-recording-retention-days", "90")
+
 	// AuthUserSessionTTLMinutes represents the time to live for tokens used for login sessions in minutes.
 	AuthUserSessionTTLMinutes = NewSetting("auth-user-session-ttl-minutes", "960") // 16 hours
 
+	// TokenHashAlgorithm is the algorithm used to hash new tokens, either "sha3" or "argon2id". Hashed tokens stored with
+	// a different algorithm are re-hashed the next time they are successfully used. Tokens scoped to a cluster always use
+	// SHA3, which is the only algorithm the authorized cluster endpoint verifies.
+	TokenHashAlgorithm = NewSetting("token-hash-algorithm", "sha3")
+
+	// TokenHashArgon2idParams are the cost parameters of the argon2id token hasher in the form "m=<memory KiB>,t=<passes>,p=<threads>".
+	// Successful verifications are cached for a few minutes, higher costs still increase the latency and memory usage of
+	// the first request of a client and of requests with invalid tokens.
+	TokenHashArgon2idParams = NewSetting("token-hash-argon2id-params", "m=19456,t=2,p=1")
+
+	// TrustedProxyCIDRs is a comma separated list of CIDRs of the proxies and load balancers in front of Rancher. The client
+	// address of requests from these addresses is taken from the X-Forwarded-For header, e.g. to enforce the source CIDRs of tokens.
+	TrustedProxyCIDRs = NewSetting("trusted-proxy-cidrs", "")
+
 	// ChartDefaultURL represents the default URL for the system charts repo. It should only be set for test or
 	// debug purposes.
 	ChartDefaultURL = NewSetting("chart-default-url", "https://git.rancher.io/")
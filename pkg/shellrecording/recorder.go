@@ -0,0 +1,135 @@
+// Package shellrecording records the sessions of the cluster shell as asciicast v2 files
+// (https://docs.asciinema.org/manual/asciicast/v2/) that hold the input of the user along with the output of the shell,
+// so that interactive access to clusters can be audited and replayed.
+package shellrecording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	eventOutput = "o"
+	eventInput  = "i"
+	eventResize = "r"
+
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// header is the first line of an asciicast v2 file.
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes the events of a session to its recording.
+type Recorder struct {
+	store   *Store
+	session Session
+	start   time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	err    error
+	closed bool
+}
+
+// Session returns the metadata of the recorded session.
+func (r *Recorder) Session() Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session
+}
+
+// Input records data typed by the user.
+func (r *Recorder) Input(data []byte) {
+	r.event(eventInput, string(data))
+}
+
+// Output records data printed by the shell.
+func (r *Recorder) Output(data []byte) {
+	r.event(eventOutput, string(data))
+}
+
+// Resize records the resize of the terminal of the user.
+func (r *Recorder) Resize(width, height int) {
+	r.event(eventResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) event(code, data string) {
+	if data == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+	line, err := json.Marshal([]interface{}{time.Since(r.start).Seconds(), code, data})
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err := r.writer.Write(append(line, '\n')); err != nil {
+		r.err = err
+		return
+	}
+	// events are flushed immediately so that sessions are recorded up to the point of a crash of the server
+	if err := r.writer.Flush(); err != nil {
+		r.err = err
+		return
+	}
+	r.session.Size += int64(len(line) + 1)
+}
+
+// Err returns the error that stopped the recording, if any. Events are not recorded after an error.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// fail stops the recording with the error, if it was not stopped yet.
+func (r *Recorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Close ends the recording and saves the metadata of the session.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+	if err := r.writer.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	ended := time.Now().UTC()
+	r.session.Ended = &ended
+	if r.err != nil {
+		r.session.Error = r.err.Error()
+		r.session.Incomplete = true
+	}
+	if err := r.store.saveSession(r.session); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
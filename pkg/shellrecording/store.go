@@ -0,0 +1,207 @@
+package shellrecording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	recordingExt = ".cast"
+	sessionExt   = ".json"
+)
+
+var (
+	// ErrNotFound is returned for sessions that were not recorded or were purged.
+	ErrNotFound = errors.New("recording not found")
+
+	idRegexp = regexp.MustCompile("^[a-f0-9-]{36}$")
+)
+
+// Session is the metadata of a recorded session.
+type Session struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+	User    string `json:"user"`
+	// AuditID is the ID of the audit log entry of the request that opened the session.
+	AuditID    string     `json:"auditId,omitempty"`
+	RemoteAddr string     `json:"remoteAddr,omitempty"`
+	Pod        string     `json:"pod,omitempty"`
+	Started    time.Time  `json:"started"`
+	Ended      *time.Time `json:"ended,omitempty"`
+	// Size is the size of the recorded events in bytes.
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+	// Incomplete is set if events of the session are missing from the recording, the session is ended when its events
+	// cannot be recorded.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// Store stores the recordings of sessions in a directory, the events of a session in <id>.cast and its metadata in
+// <id>.json.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Start starts the recording of a session. The ID and start time of the session are assigned.
+func (s *Store) Start(session Session, width, height int) (*Recorder, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+	session.ID = uuid.NewRandom().String()
+	start := time.Now()
+	session.Started = start.UTC().Truncate(time.Second)
+
+	file, err := os.OpenFile(s.path(session.ID, recordingExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		width, height = defaultWidth, defaultHeight
+	}
+	data, err := json.Marshal(header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Title:     fmt.Sprintf("%s@%s", session.User, session.Cluster),
+		Env:       map[string]string{"TERM": "xterm"},
+	})
+	if err == nil {
+		_, err = file.Write(append(data, '\n'))
+	}
+	if err == nil {
+		err = s.saveSession(session)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &Recorder{
+		store:   s,
+		session: session,
+		start:   start,
+		file:    file,
+		writer:  bufio.NewWriter(file),
+	}, nil
+}
+
+// List returns the sessions of the cluster, the most recent first. The sessions of all clusters are returned if
+// cluster is empty.
+func (s *Store) List(cluster string) ([]Session, error) {
+	files, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, file := range files {
+		id, ok := strings.CutSuffix(file.Name(), sessionExt)
+		if !ok || !idRegexp.MatchString(id) {
+			continue
+		}
+		session, err := s.Get(id)
+		if err != nil {
+			// the session may have been purged since the directory was read
+			continue
+		}
+		if cluster == "" || session.Cluster == cluster {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Started.After(sessions[j].Started)
+	})
+	return sessions, nil
+}
+
+// Get returns the metadata of the session.
+func (s *Store) Get(id string) (Session, error) {
+	var session Session
+	if !idRegexp.MatchString(id) {
+		return session, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id, sessionExt))
+	if os.IsNotExist(err) {
+		return session, ErrNotFound
+	} else if err != nil {
+		return session, err
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, fmt.Errorf("invalid metadata of session %s: %w", id, err)
+	}
+	return session, nil
+}
+
+// Open returns the recording of the session.
+func (s *Store) Open(id string) (io.ReadCloser, error) {
+	if !idRegexp.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(s.path(id, recordingExt))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// Purge deletes the recordings of the sessions that ended before the time and returns the number of deleted sessions.
+// Sessions that never ended, because the server stopped while they were recorded, are deleted once they started before
+// the time.
+func (s *Store) Purge(before time.Time) (int, error) {
+	sessions, err := s.List("")
+	if err != nil {
+		return 0, err
+	}
+	var purged int
+	for _, session := range sessions {
+		ended := session.Started
+		if session.Ended != nil {
+			ended = *session.Ended
+		}
+		if !ended.Before(before) {
+			continue
+		}
+		if err := os.Remove(s.path(session.ID, recordingExt)); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		if err := os.Remove(s.path(session.ID, sessionExt)); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// saveSession atomically writes the metadata of the session.
+func (s *Store) saveSession(session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(session.ID, sessionExt+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(session.ID, sessionExt))
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
@@ -0,0 +1,163 @@
+package shellrecording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "recordings"))
+
+	recorder, err := store.Start(Session{Cluster: "c-abc", User: "u-xyz", AuditID: "audit-1", Pod: "dashboard-shell-1"}, 0, 0)
+	require.NoError(t, err)
+	id := recorder.Session().ID
+
+	recorder.Resize(120, 40)
+	recorder.Input([]byte("ls\r"))
+	recorder.Output([]byte("ls\r\nfile\r\n"))
+	require.NoError(t, recorder.Close())
+
+	session, err := store.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "c-abc", session.Cluster)
+	assert.Equal(t, "u-xyz", session.User)
+	assert.Equal(t, "audit-1", session.AuditID)
+	assert.NotNil(t, session.Ended)
+	assert.Empty(t, session.Error)
+	assert.Greater(t, session.Size, int64(0))
+
+	recording, err := store.Open(id)
+	require.NoError(t, err)
+	defer recording.Close()
+	scanner := bufio.NewScanner(recording)
+
+	require.True(t, scanner.Scan())
+	var h header
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &h))
+	assert.Equal(t, 2, h.Version)
+	assert.Equal(t, defaultWidth, h.Width)
+	assert.Equal(t, defaultHeight, h.Height)
+	assert.Equal(t, "u-xyz@c-abc", h.Title)
+
+	var events [][]interface{}
+	for scanner.Scan() {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Len(t, event, 3)
+		events = append(events, event)
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, []interface{}{"r", "120x40"}, events[0][1:])
+	assert.Equal(t, []interface{}{"i", "ls\r"}, events[1][1:])
+	assert.Equal(t, []interface{}{"o", "ls\r\nfile\r\n"}, events[2][1:])
+
+	// events after the end of the recording are dropped
+	recorder.Input([]byte("exit\r"))
+	session, err = store.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, recorder.Session().Size, session.Size)
+}
+
+func TestList(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	sessions, err := NewStore(filepath.Join(t.TempDir(), "missing")).List("")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	var ids []string
+	for _, cluster := range []string{"c-1", "c-2", "c-1"} {
+		recorder, err := store.Start(Session{Cluster: cluster, User: "u"}, 80, 24)
+		require.NoError(t, err)
+		require.NoError(t, recorder.Close())
+		ids = append(ids, recorder.Session().ID)
+	}
+	// sessions are ordered by their start time, which is recorded in seconds
+	setStarted(t, store, ids[0], time.Now().Add(-time.Hour))
+
+	sessions, err = store.List("c-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, ids[2], sessions[0].ID)
+	assert.Equal(t, ids[0], sessions[1].ID)
+
+	sessions, err = store.List("")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 3)
+}
+
+func TestGetAndOpenInvalidID(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, id := range []string{"", "../etc/passwd", "0b0ef05d-44de-4e4b-a5a4-0b3e6bb4c1c0"} {
+		_, err := store.Get(id)
+		assert.ErrorIs(t, err, ErrNotFound, id)
+		_, err = store.Open(id)
+		assert.ErrorIs(t, err, ErrNotFound, id)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	old, err := store.Start(Session{Cluster: "c-1"}, 80, 24)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+	recent, err := store.Start(Session{Cluster: "c-1"}, 80, 24)
+	require.NoError(t, err)
+	require.NoError(t, recent.Close())
+	// a session that was never closed, as if the server stopped while it was recorded
+	abandoned, err := store.Start(Session{Cluster: "c-1"}, 80, 24)
+	require.NoError(t, err)
+
+	ended := time.Now().Add(-48 * time.Hour)
+	session := old.Session()
+	session.Ended = &ended
+	require.NoError(t, store.saveSession(session))
+	setStarted(t, store, abandoned.Session().ID, time.Now().Add(-72*time.Hour))
+
+	purged, err := store.Purge(time.Now().Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	sessions, err := store.List("")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, recent.Session().ID, sessions[0].ID)
+	_, err = store.Open(old.Session().ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = os.Stat(store.path(abandoned.Session().ID, recordingExt))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func setStarted(t *testing.T, store *Store, id string, started time.Time) {
+	t.Helper()
+	session, err := store.Get(id)
+	require.NoError(t, err)
+	session.Started = started.UTC().Truncate(time.Second)
+	require.NoError(t, store.saveSession(session))
+}
+
+func readEvents(t *testing.T, store *Store, id string) [][]interface{} {
+	t.Helper()
+	recording, err := store.Open(id)
+	require.NoError(t, err)
+	defer recording.Close()
+
+	var events [][]interface{}
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(nil, 1<<20)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event[1:])
+	}
+	return events
+}
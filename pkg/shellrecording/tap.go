@@ -0,0 +1,293 @@
+package shellrecording
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Channels of the Kubernetes exec websocket protocols, see k8s.io/apimachinery/pkg/util/remotecommand.
+const (
+	channelStdin  = 0
+	channelStdout = 1
+	channelStderr = 2
+	channelResize = 4
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+
+	// chunkSize is the size of the data of a message that is buffered before it is recorded, larger messages are
+	// recorded in several events as their frames pass through.
+	chunkSize = 64 << 10
+)
+
+// Tap returns a connection that records the exec session of the websocket connection to the Kubernetes API server it
+// wraps. Reads from the connection return the messages of the API server, which are recorded as the output of the
+// session, writes send the messages of the user, which are recorded as its input. protocol is the subprotocol the API
+// server accepted.
+//
+// Data is only passed on once it is recorded. If the session cannot be recorded, e.g. because its frames cannot be
+// decoded, the recording is marked incomplete and the connection is closed.
+func (r *Recorder) Tap(conn io.ReadWriteCloser, protocol string) io.ReadWriteCloser {
+	base64Encoded := strings.Contains(protocol, "base64.channel.k8s.io")
+	return &tap{
+		ReadWriteCloser: conn,
+		recorder:        r,
+		fromServer:      &frameReader{masked: false, base64: base64Encoded, onMessage: r.serverMessage},
+		fromClient:      &frameReader{masked: true, base64: base64Encoded, onMessage: r.clientMessage},
+	}
+}
+
+func (r *Recorder) serverMessage(channel byte, data []byte) {
+	switch channel {
+	case channelStdout, channelStderr:
+		r.Output(data)
+	}
+}
+
+func (r *Recorder) clientMessage(channel byte, data []byte) {
+	switch channel {
+	case channelStdin:
+		r.Input(data)
+	case channelResize:
+		var size struct {
+			Width  int
+			Height int
+		}
+		if err := json.Unmarshal(data, &size); err == nil && size.Width > 0 && size.Height > 0 {
+			r.Resize(size.Width, size.Height)
+		}
+	}
+}
+
+type tap struct {
+	io.ReadWriteCloser
+	recorder   *Recorder
+	fromServer *frameReader
+	fromClient *frameReader
+}
+
+func (t *tap) Read(p []byte) (int, error) {
+	n, err := t.ReadWriteCloser.Read(p)
+	if n > 0 {
+		if err := t.record(t.fromServer, p[:n]); err != nil {
+			return 0, err
+		}
+	}
+	return n, err
+}
+
+func (t *tap) Write(p []byte) (int, error) {
+	if err := t.record(t.fromClient, p); err != nil {
+		return 0, err
+	}
+	return t.ReadWriteCloser.Write(p)
+}
+
+// record records the bytes passing through the connection, and ends the session if they cannot be recorded.
+func (t *tap) record(f *frameReader, p []byte) error {
+	err := f.feed(p)
+	if err != nil {
+		t.recorder.fail(err)
+	} else {
+		err = t.recorder.Err()
+	}
+	if err != nil {
+		t.ReadWriteCloser.Close()
+		return fmt.Errorf("shell session cannot be recorded: %w", err)
+	}
+	return nil
+}
+
+// frameReader decodes the websocket frames (RFC 6455) of one direction of a connection from the bytes passing through
+// and calls onMessage with the channel and data of the data messages. The payload of frames is decoded as it arrives,
+// so that large messages are not buffered. It returns an error once the stream cannot be decoded.
+type frameReader struct {
+	masked    bool
+	base64    bool
+	onMessage func(channel byte, data []byte)
+
+	buf []byte
+	// frame is the frame whose payload is being read, nil between frames
+	frame *frameHeader
+	// inMessage is set between the first and the last frame of a data message
+	inMessage bool
+	// channel is the channel of the current message, once its first byte was read
+	channel    byte
+	hasChannel bool
+	// pending is the data of the current message that is not recorded yet
+	pending []byte
+	err     error
+}
+
+type frameHeader struct {
+	fin       bool
+	opcode    byte
+	mask      []byte
+	remaining uint64
+	// offset is the number of payload bytes read, the position in the mask
+	offset uint64
+}
+
+func (f *frameReader) feed(p []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.buf = append(f.buf, p...)
+	for {
+		if f.frame == nil {
+			n, err := f.readHeader()
+			if err != nil {
+				f.err = err
+				f.buf = nil
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			f.buf = f.buf[n:]
+		}
+		if f.frame.remaining > 0 && len(f.buf) == 0 {
+			break
+		}
+
+		n := len(f.buf)
+		if uint64(n) > f.frame.remaining {
+			n = int(f.frame.remaining)
+		}
+		payload := f.buf[:n]
+		if f.frame.mask != nil {
+			for i := range payload {
+				payload[i] ^= f.frame.mask[(f.frame.offset+uint64(i))%4]
+			}
+		}
+		f.frame.remaining -= uint64(n)
+		f.frame.offset += uint64(n)
+		f.buf = f.buf[n:]
+
+		// control frames carry no session data
+		if f.frame.opcode < opClose {
+			if err := f.messageData(payload, f.frame.remaining == 0 && f.frame.fin); err != nil {
+				f.err = err
+				f.buf = nil
+				return err
+			}
+		}
+		if f.frame.remaining == 0 {
+			f.frame = nil
+		}
+	}
+	if len(f.buf) == 0 {
+		f.buf = nil
+	}
+	return nil
+}
+
+// readHeader reads the header of the frame at the start of the buffer and returns its length, or 0 if the header is
+// not complete.
+func (f *frameReader) readHeader() (int, error) {
+	if len(f.buf) < 2 {
+		return 0, nil
+	}
+	fin := f.buf[0]&0x80 != 0
+	reserved := f.buf[0] & 0x70
+	opcode := f.buf[0] & 0x0f
+	masked := f.buf[1]&0x80 != 0
+	length := uint64(f.buf[1] & 0x7f)
+	offset := 2
+	switch length {
+	case 126:
+		if len(f.buf) < offset+2 {
+			return 0, nil
+		}
+		length = uint64(binary.BigEndian.Uint16(f.buf[offset:]))
+		offset += 2
+	case 127:
+		if len(f.buf) < offset+8 {
+			return 0, nil
+		}
+		length = binary.BigEndian.Uint64(f.buf[offset:])
+		offset += 8
+	}
+	var mask []byte
+	if masked {
+		if len(f.buf) < offset+4 {
+			return 0, nil
+		}
+		mask = append([]byte(nil), f.buf[offset:offset+4]...)
+		offset += 4
+	}
+
+	switch {
+	case masked != f.masked:
+		return 0, errors.New("unexpected websocket frame mask")
+	case reserved != 0:
+		// compressed messages cannot be decoded without the state of the whole connection
+		return 0, errors.New("websocket extensions are not supported")
+	case opcode == opContinuation && !f.inMessage:
+		return 0, errors.New("unexpected websocket continuation frame")
+	case (opcode == opText || opcode == opBinary) && f.inMessage:
+		return 0, errors.New("unexpected websocket data frame in fragmented message")
+	case opcode > opBinary && opcode < opClose, opcode > 0xa:
+		return 0, fmt.Errorf("unknown websocket opcode %d", opcode)
+	}
+	if opcode < opClose {
+		f.inMessage = true
+	}
+	f.frame = &frameHeader{fin: fin, opcode: opcode, mask: mask, remaining: length}
+	return offset, nil
+}
+
+// messageData handles the payload of a data frame, last is set for the end of the message.
+func (f *frameReader) messageData(payload []byte, last bool) error {
+	if !f.hasChannel && len(payload) > 0 {
+		f.channel = payload[0]
+		if f.base64 {
+			f.channel -= '0'
+		}
+		f.hasChannel = true
+		payload = payload[1:]
+	}
+	f.pending = append(f.pending, payload...)
+	if last || len(f.pending) >= chunkSize {
+		if err := f.flush(last); err != nil {
+			return err
+		}
+	}
+	if last {
+		f.inMessage = false
+		f.hasChannel = false
+		f.pending = nil
+	}
+	return nil
+}
+
+// flush records the pending data of the message. Until the end of the message, base64 data is only decoded up to the
+// last complete group of 4 characters.
+func (f *frameReader) flush(last bool) error {
+	n := len(f.pending)
+	data := f.pending
+	if f.base64 {
+		if !last {
+			n -= n % 4
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(f.pending[:n]))
+		if err != nil {
+			return fmt.Errorf("invalid base64 websocket message: %w", err)
+		}
+		data = decoded
+	}
+	if f.hasChannel && len(data) > 0 {
+		f.onMessage(f.channel, data)
+	}
+	f.pending = append(f.pending[:0], f.pending[n:]...)
+	return nil
+}
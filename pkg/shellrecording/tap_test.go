@@ -0,0 +1,215 @@
+package shellrecording
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn returns the bytes of in on reads and collects writes.
+type fakeConn struct {
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+func (c *fakeConn) Close() error                { return nil }
+
+// frame encodes a websocket frame, masked like the frames sent by clients if mask is set.
+func frame(fin bool, opcode byte, payload []byte, mask []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	data := []byte{first}
+	switch {
+	case len(payload) < 126:
+		data = append(data, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		data = append(data, maskBit|126)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(payload)))
+	default:
+		data = append(data, maskBit|127)
+		data = binary.BigEndian.AppendUint64(data, uint64(len(payload)))
+	}
+	if mask == nil {
+		return append(data, payload...)
+	}
+	data = append(data, mask...)
+	for i, b := range payload {
+		data = append(data, b^mask[i%4])
+	}
+	return data
+}
+
+func base64Message(channel byte, data string) []byte {
+	return append([]byte{'0' + channel}, base64.StdEncoding.EncodeToString([]byte(data))...)
+}
+
+func TestTap(t *testing.T) {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	long := bytes.Repeat([]byte("a"), 300)
+	tests := []struct {
+		name     string
+		protocol string
+		server   [][]byte
+		client   [][]byte
+		expected [][]interface{}
+	}{
+		{
+			name:     "base64 channels",
+			protocol: "base64.channel.k8s.io",
+			client: [][]byte{
+				frame(true, opText, base64Message(channelResize, `{"Width":100,"Height":30}`), mask),
+				frame(true, opText, base64Message(channelStdin, "ls\r"), mask),
+			},
+			server: [][]byte{
+				frame(true, opText, base64Message(channelStdout, "file\r\n"), nil),
+				frame(true, opText, base64Message(3, `{"status":"Success"}`), nil),
+			},
+			expected: [][]interface{}{{"r", "100x30"}, {"i", "ls\r"}, {"o", "file\r\n"}},
+		},
+		{
+			name:     "binary channels",
+			protocol: "v4.channel.k8s.io",
+			client: [][]byte{
+				frame(true, opBinary, append([]byte{channelStdin}, "id\r"...), mask),
+				// ping
+				frame(true, 0x9, nil, mask),
+			},
+			server: [][]byte{
+				frame(true, opBinary, append([]byte{channelStderr}, "denied"...), nil),
+				frame(true, opBinary, append([]byte{channelStdout}, long...), nil),
+			},
+			expected: [][]interface{}{{"i", "id\r"}, {"o", "denied"}, {"o", string(long)}},
+		},
+		{
+			name:     "fragmented messages",
+			protocol: "base64.channel.k8s.io",
+			server: [][]byte{
+				append(
+					frame(false, opText, base64Message(channelStdout, "hello")[:3], nil),
+					frame(true, opContinuation, base64Message(channelStdout, "hello")[3:], nil)...,
+				),
+			},
+			expected: [][]interface{}{{"o", "hello"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(t.TempDir())
+			recorder, err := store.Start(Session{}, 80, 24)
+			require.NoError(t, err)
+
+			conn := &fakeConn{in: bytes.NewReader(bytes.Join(tt.server, nil))}
+			tapped := recorder.Tap(conn, tt.protocol)
+			for _, data := range tt.client {
+				// frames are written in pieces to check that partial frames are buffered
+				for i := 0; i < len(data); i += 3 {
+					_, err := tapped.Write(data[i:min(i+3, len(data))])
+					require.NoError(t, err)
+				}
+			}
+			buf := make([]byte, 5)
+			for {
+				_, err := tapped.Read(buf)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+			}
+			require.NoError(t, recorder.Close())
+
+			assert.Equal(t, string(bytes.Join(tt.client, nil)), conn.out.String())
+			assert.Equal(t, tt.expected, readEvents(t, store, recorder.Session().ID))
+		})
+	}
+}
+
+func TestTapRecordsLargeMessages(t *testing.T) {
+	for _, protocol := range []string{"v4.channel.k8s.io", "base64.channel.k8s.io"} {
+		t.Run(protocol, func(t *testing.T) {
+			store := NewStore(t.TempDir())
+			recorder, err := store.Start(Session{}, 80, 24)
+			require.NoError(t, err)
+
+			data := bytes.Repeat([]byte("0123456789abcdef"), 3<<16)
+			message := append([]byte{channelStdout}, data...)
+			if protocol == "base64.channel.k8s.io" {
+				message = base64Message(channelStdout, string(data))
+			}
+			// the message is larger than the chunks it is recorded in, and is split in two frames
+			stream := append(frame(false, opBinary, message[:len(message)/3], nil), frame(true, opContinuation, message[len(message)/3:], nil)...)
+			conn := &fakeConn{in: bytes.NewReader(stream)}
+			_, err = io.Copy(io.Discard, recorder.Tap(conn, protocol))
+			require.NoError(t, err)
+			require.NoError(t, recorder.Close())
+
+			var output string
+			for _, event := range readEvents(t, store, recorder.Session().ID) {
+				assert.Equal(t, "o", event[0])
+				output += event[1].(string)
+			}
+			assert.Equal(t, string(data), output)
+			assert.False(t, recorder.Session().Incomplete)
+		})
+	}
+}
+
+func TestTapEndsSessionOnInvalidStream(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{
+			// client frames must be masked
+			name:  "unmasked client frame",
+			frame: frame(true, opBinary, append([]byte{channelStdin}, "a"...), nil),
+		},
+		{
+			name: "compressed message",
+			frame: func() []byte {
+				data := frame(true, opBinary, []byte{channelStdin, 1, 2, 3}, []byte{1, 2, 3, 4})
+				data[0] |= 0x40
+				return data
+			}(),
+		},
+		{
+			name:  "continuation without message",
+			frame: frame(true, opContinuation, []byte("a"), []byte{1, 2, 3, 4}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(t.TempDir())
+			recorder, err := store.Start(Session{}, 80, 24)
+			require.NoError(t, err)
+
+			conn := &fakeConn{in: bytes.NewReader(nil)}
+			tapped := recorder.Tap(conn, "v4.channel.k8s.io")
+			_, err = tapped.Write(tt.frame)
+			assert.Error(t, err)
+			// nothing passes through once the session cannot be recorded
+			_, err = tapped.Write(frame(true, opBinary, append([]byte{channelStdin}, "b"...), []byte{1, 2, 3, 4}))
+			assert.Error(t, err)
+			assert.Empty(t, conn.out.Bytes())
+
+			assert.Error(t, recorder.Close())
+			session, err := store.Get(recorder.Session().ID)
+			require.NoError(t, err)
+			assert.True(t, session.Incomplete)
+			assert.NotEmpty(t, session.Error)
+			assert.Empty(t, readEvents(t, store, recorder.Session().ID))
+		})
+	}
+}
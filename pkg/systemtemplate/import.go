@@ -48,6 +48,8 @@ type context struct {
 	Affinity              string
 	ResourceRequirements  string
 	ClusterRegistry       string
+	AgentProxyData        map[string]string
+	AgentProxyChecksum    string
 }
 
 func toFeatureString(features map[string]bool) string {
@@ -122,6 +124,11 @@ func SystemTemplate(resp io.Writer, agentImage, authImage, namespace, token, url
 		}
 	}
 
+	agentProxyData, agentProxyChecksum, err := agentProxySecretData(cluster, secretLister)
+	if err != nil {
+		return err
+	}
+
 	context := &context{
 		Features:              toFeatureString(features),
 		CAChecksum:            CAChecksum(),
@@ -141,11 +148,36 @@ func SystemTemplate(resp io.Writer, agentImage, authImage, namespace, token, url
 		Affinity:              agentAffinity,
 		ResourceRequirements:  agentResourceRequirements,
 		ClusterRegistry:       registryURL,
+		AgentProxyData:        agentProxyData,
+		AgentProxyChecksum:    agentProxyChecksum,
 	}
 
 	return t.Execute(resp, context)
 }
 
+// agentProxySecretData returns the base64 encoded data of the cluster agent proxy Secret and a checksum of it, so
+// that the agent is rolled out again when the proxy configuration or its credentials change.
+func agentProxySecretData(cluster *apimgmtv3.Cluster, secretLister v1.SecretLister) (map[string]string, string, error) {
+	data, err := util.GetClusterAgentProxyData(cluster, secretLister)
+	if err != nil || len(data) == 0 {
+		return nil, "", err
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	digest := sha256.New()
+	encoded := make(map[string]string, len(data))
+	for _, k := range keys {
+		digest.Write([]byte(k + "=" + data[k] + "\n"))
+		encoded[k] = base64.StdEncoding.EncodeToString([]byte(data[k]))
+	}
+	return encoded, hex.EncodeToString(digest.Sum(nil)), nil
+}
+
 func GetDesiredFeatures(cluster *apimgmtv3.Cluster) map[string]bool {
 	return map[string]bool{
 		features.MCM.Name():                false,
@@ -270,3 +270,82 @@ func getHash(b []byte) string {
 	sum := sha256.Sum256(b)
 	return hex.EncodeToString(sum[:])
 }
+
+func TestSystemTemplate_agentProxy(t *testing.T) {
+	secretLister := &corefakes.SecretListerMock{
+		GetFunc: func(namespace string, name string) (*corev1.Secret, error) {
+			if namespace == "test-proxy" && name == "proxy-credentials" {
+				return &corev1.Secret{
+					Data: map[string][]byte{
+						corev1.BasicAuthUsernameKey: []byte("agent"),
+						corev1.BasicAuthPasswordKey: []byte("s3cret"),
+					},
+				}, nil
+			}
+			return nil, apierror.NewNotFound(schema.GroupResource{}, namespace+":"+name)
+		},
+	}
+
+	cluster := &apimgmtv3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-proxy",
+		},
+		Spec: apimgmtv3.ClusterSpec{
+			ClusterSpecBase: apimgmtv3.ClusterSpecBase{
+				ClusterAgentProxyConfig: &apimgmtv3.AgentProxyConfig{
+					URL:              "http://proxy.example.com:3128",
+					CredentialSecret: "test-proxy:proxy-credentials",
+					NoProxy:          []string{"10.0.0.0/8", ".svc"},
+				},
+			},
+		},
+	}
+
+	var b bytes.Buffer
+	err := SystemTemplate(&b, "", "", "", "", "", false, cluster, nil, nil, secretLister)
+	assert.Nil(t, err)
+
+	var (
+		proxySecret *corev1.Secret
+		deployment  *appsv1.Deployment
+	)
+	decoder := scheme.Codecs.UniversalDeserializer()
+	for _, r := range strings.Split(b.String(), "---") {
+		obj, _, err := decoder.Decode([]byte(r), nil, nil)
+		if err != nil {
+			continue
+		}
+		switch o := obj.(type) {
+		case *corev1.Secret:
+			if strings.HasPrefix(o.Name, "cattle-agent-proxy-") {
+				proxySecret = o
+			}
+		case *appsv1.Deployment:
+			if o.Name == "cattle-cluster-agent" {
+				deployment = o
+			}
+		}
+	}
+
+	if assert.NotNil(t, proxySecret) {
+		assert.Equal(t, "http://proxy.example.com:3128", string(proxySecret.Data["url"]))
+		assert.Equal(t, "agent", string(proxySecret.Data["username"]))
+		assert.Equal(t, "s3cret", string(proxySecret.Data["password"]))
+		assert.Equal(t, "10.0.0.0/8,.svc", string(proxySecret.Data["no-proxy"]))
+	}
+	if assert.NotNil(t, deployment) {
+		container := deployment.Spec.Template.Spec.Containers[0]
+		assert.Contains(t, container.VolumeMounts, corev1.VolumeMount{Name: "cattle-agent-proxy", MountPath: "/cattle-agent-proxy", ReadOnly: true})
+		var checksum string
+		for _, env := range container.Env {
+			if env.Name == "CATTLE_AGENT_PROXY_CHECKSUM" {
+				checksum = env.Value
+			}
+		}
+		assert.NotEmpty(t, checksum)
+	}
+
+	cluster.Spec.ClusterAgentProxyConfig.CredentialSecret = "test-proxy:missing"
+	err = SystemTemplate(&b, "", "", "", "", "", false, cluster, nil, nil, secretLister)
+	assert.Error(t, err)
+}
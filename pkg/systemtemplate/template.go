@@ -88,6 +88,21 @@
 ---
 {{- end }}
 
+{{- if .AgentProxyData}}
+apiVersion: v1
+kind: Secret
+metadata:
+  name: cattle-agent-proxy-{{.TokenKey}}
+  namespace: cattle-system
+type: Opaque
+data:
+{{- range $key, $value := .AgentProxyData }}
+  {{ $key }}: "{{ $value }}"
+{{- end }}
+
+---
+{{- end }}
+
 apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRole
 metadata:
@@ -172,6 +187,10 @@
             value: "true"
           - name: CATTLE_CLUSTER_REGISTRY
             value: "{{.ClusterRegistry}}"
+          {{- if .AgentProxyData }}
+          - name: CATTLE_AGENT_PROXY_CHECKSUM
+            value: "{{.AgentProxyChecksum}}"
+          {{- end }}
       {{- if .AgentEnvVars}}
 {{ .AgentEnvVars | indent 10 }}
       {{- end }}
@@ -180,6 +199,11 @@
           - name: cattle-credentials
             mountPath: /cattle-credentials
             readOnly: true
+          {{- if .AgentProxyData }}
+          - name: cattle-agent-proxy
+            mountPath: /cattle-agent-proxy
+            readOnly: true
+          {{- end }}
       {{- if .PrivateRegistryConfig}}
       imagePullSecrets:
       - name: cattle-private-registry
@@ -189,6 +213,12 @@
         secret:
           secretName: cattle-credentials-{{.TokenKey}}
           defaultMode: 320
+      {{- if .AgentProxyData }}
+      - name: cattle-agent-proxy
+        secret:
+          secretName: cattle-agent-proxy-{{.TokenKey}}
+          defaultMode: 320
+      {{- end }}
   strategy:
     type: RollingUpdate
     rollingUpdate:
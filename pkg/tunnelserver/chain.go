@@ -13,12 +13,7 @@ type Authorizers struct {
 }
 
 func ErrorWriter(rw http.ResponseWriter, req *http.Request, code int, err error) {
-	fullAddress := req.RemoteAddr
-	forwardedFor := req.Header.Get("X-Forwarded-For")
-	if forwardedFor != "" {
-		fullAddress = fmt.Sprintf("%s (X-Forwarded-For: %s)", req.RemoteAddr, forwardedFor)
-	}
-	logrus.Errorf("Failed to handle tunnel request from remote address %s: response %d: %v", fullAddress, code, err)
+	logrus.Errorf("Failed to handle tunnel request from remote address %s: response %d: %v", remoteAddress(req), code, err)
 	logrus.Tracef("ErrorWriter: response code: %d, request: %v", code, req)
 	remotedialer.DefaultErrorWriter(rw, req, code, err)
 }
@@ -36,12 +31,21 @@ func (a *Authorizers) Authorize(req *http.Request) (clientKey string, authed boo
 			}
 			continue
 		}
+		setSessionClientKey(req, key)
 		return key, authed, err
 	}
 
 	return "", false, firstErr
 }
 
+func remoteAddress(req *http.Request) string {
+	forwardedFor := req.Header.Get("X-Forwarded-For")
+	if forwardedFor != "" {
+		return fmt.Sprintf("%s (X-Forwarded-For: %s)", req.RemoteAddr, forwardedFor)
+	}
+	return req.RemoteAddr
+}
+
 func (a *Authorizers) Add(authorizer remotedialer.Authorizer) {
 	a.chain = append(a.chain, authorizer)
 }
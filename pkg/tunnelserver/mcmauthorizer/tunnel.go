@@ -54,9 +54,18 @@ type cluster struct {
 	CACert  string `json:"caCert"`
 }
 
+// proxyReport is sent by cluster agents that connect through a proxy, see tunnelproxy.Report.
+type proxyReport struct {
+	URL               string `json:"url"`
+	Rejections        int    `json:"rejections"`
+	LastRejection     string `json:"lastRejection"`
+	LastRejectionTime string `json:"lastRejectionTime"`
+}
+
 type input struct {
 	Node        *client.Node `json:"node"`
 	Cluster     *cluster     `json:"cluster"`
+	Proxy       *proxyReport `json:"proxy"`
 	NodeVersion int          `json:"nodeVersion"`
 }
 
@@ -153,6 +162,14 @@ func (t *Authorizer) Authorize(req *http.Request) (*Client, bool, error) {
 
 	if input.Cluster != nil {
 		cluster, ok, err := t.authorizeCluster(cluster, input.Cluster, req)
+		if ok && err == nil && input.Proxy != nil {
+			// failing to record the proxy condition must not keep the agent from connecting
+			if updated, updateErr := t.updateProxyCondition(cluster, input.Proxy); updateErr != nil {
+				logrus.Errorf("Failed to update agent proxy condition for cluster [%s]: %v", cluster.Name, updateErr)
+			} else {
+				cluster = updated
+			}
+		}
 		return &Client{
 			Cluster: cluster,
 			Token:   token,
@@ -340,6 +357,31 @@ func (t *Authorizer) authorizeCluster(cluster *v3.Cluster, inCluster *cluster, r
 	return cluster, true, err
 }
 
+// updateProxyCondition reflects on the cluster whether the proxy rejected tunnel connection attempts of the cluster
+// agent since its previous session. The agent can only report rejections once the proxy lets it through again, so
+// a False condition describes the outage that preceded the current connection.
+func (t *Authorizer) updateProxyCondition(cluster *v3.Cluster, report *proxyReport) (*v3.Cluster, error) {
+	updated := cluster.DeepCopy()
+	if report.Rejections > 0 {
+		v32.ClusterConditionAgentProxyConnected.False(updated)
+		v32.ClusterConditionAgentProxyConnected.Reason(updated, "ProxyRejected")
+		v32.ClusterConditionAgentProxyConnected.Message(updated, fmt.Sprintf("proxy %s rejected %d connection attempt(s) before the agent connected, last at %s: %s",
+			report.URL, report.Rejections, report.LastRejectionTime, report.LastRejection))
+	} else {
+		v32.ClusterConditionAgentProxyConnected.True(updated)
+		v32.ClusterConditionAgentProxyConnected.Reason(updated, "")
+		v32.ClusterConditionAgentProxyConnected.Message(updated, fmt.Sprintf("connected through proxy %s", report.URL))
+	}
+
+	if reflect.DeepEqual(cluster.Status.Conditions, updated.Status.Conditions) {
+		return cluster, nil
+	}
+	if report.Rejections > 0 {
+		logrus.Warnf("Cluster agent for cluster [%s] connected after proxy %s rejected %d attempt(s): %s", cluster.Name, report.URL, report.Rejections, report.LastRejection)
+	}
+	return t.clusters.Update(updated)
+}
+
 func tokenChanged(secret *corev1.Secret, token string) bool {
 	return secret != nil && string(secret.Data[secretmigrator.SecretKey]) != token
 }
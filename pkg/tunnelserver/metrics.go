@@ -0,0 +1,115 @@
+package tunnelserver
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var prometheusMetrics = false
+
+var (
+	TotalSessionConnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "total_session_connects",
+			Help:      "Total count of agent tunnel sessions established",
+		},
+		[]string{"clientkey"},
+	)
+
+	TotalSessionDisconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "total_session_disconnects",
+			Help:      "Total count of agent tunnel sessions closed, by reason",
+		},
+		[]string{"clientkey", "reason"},
+	)
+
+	TotalSessionReceiveBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "total_session_receive_bytes",
+			Help:      "Total bytes received from agents over tunnel sessions",
+		},
+		[]string{"clientkey"},
+	)
+
+	TotalSessionTransmitBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "tunnel_server",
+			Name:      "total_session_transmit_bytes",
+			Help:      "Total bytes transmitted to agents over tunnel sessions",
+		},
+		[]string{"clientkey"},
+	)
+
+	SessionRoundTripSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "tunnel_server",
+			Name:      "session_round_trip_seconds",
+			Help:      "Round trip time of requests to the agent through its tunnel session",
+			Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"clientkey"},
+	)
+)
+
+// RegisterMetrics registers the tunnel session metrics for Prometheus.
+func RegisterMetrics() {
+	prometheusMetrics = true
+
+	prometheus.MustRegister(TotalSessionConnects)
+	prometheus.MustRegister(TotalSessionDisconnects)
+	prometheus.MustRegister(TotalSessionReceiveBytes)
+	prometheus.MustRegister(TotalSessionTransmitBytes)
+	prometheus.MustRegister(SessionRoundTripSeconds)
+}
+
+func incSessionConnects(clientKey string) {
+	if prometheusMetrics {
+		TotalSessionConnects.With(
+			prometheus.Labels{
+				"clientkey": clientKey,
+			}).Inc()
+	}
+}
+
+func incSessionDisconnects(clientKey, reason string) {
+	if prometheusMetrics {
+		TotalSessionDisconnects.With(
+			prometheus.Labels{
+				"clientkey": clientKey,
+				"reason":    reason,
+			}).Inc()
+	}
+}
+
+func addSessionReceiveBytes(clientKey string, n int) {
+	if prometheusMetrics && n > 0 {
+		TotalSessionReceiveBytes.With(
+			prometheus.Labels{
+				"clientkey": clientKey,
+			}).Add(float64(n))
+	}
+}
+
+func addSessionTransmitBytes(clientKey string, n int) {
+	if prometheusMetrics && n > 0 {
+		TotalSessionTransmitBytes.With(
+			prometheus.Labels{
+				"clientkey": clientKey,
+			}).Add(float64(n))
+	}
+}
+
+// ObserveRoundTrip records the time a request to the agent behind clientKey took through its tunnel session.
+func ObserveRoundTrip(clientKey string, rtt time.Duration) {
+	if prometheusMetrics {
+		SessionRoundTripSeconds.With(
+			prometheus.Labels{
+				"clientkey": clientKey,
+			}).Observe(rtt.Seconds())
+	}
+}
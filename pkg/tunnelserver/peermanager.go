@@ -20,6 +20,7 @@
 	corecontrollers "github.com/rancher/wrangler/v2/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/client-go/kubernetes"
@@ -37,7 +38,10 @@ type peerManager struct {
 	token     string
 	urlFormat string
 	server    *remotedialer.Server
+	endpoints corecontrollers.EndpointsClient
+	selfName  string
 	peers     map[string]bool
+	names     map[string]string
 	listeners map[chan<- peermanager.Peers]bool
 }
 
@@ -108,11 +112,20 @@ func startPeerManager(ctx context.Context, endpoints corecontrollers.EndpointsCo
 	server.PeerID = ip.String()
 	server.PeerToken = string(tokenBytes)
 
+	// the hostname of a pod is its name, which identifies the replica across its peers
+	selfName, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting hostname")
+	}
+
 	pm := &peerManager{
 		token:     server.PeerToken,
 		urlFormat: "wss://%s/v3/connect",
 		server:    server,
+		endpoints: endpoints,
+		selfName:  selfName,
 		peers:     map[string]bool{},
+		names:     map[string]string{},
 		listeners: map[chan<- peermanager.Peers]bool{},
 	}
 
@@ -149,11 +162,27 @@ func (p *peerManager) syncService(key string, endpoint *v1.Endpoints) (*v1.Endpo
 	return nil, nil
 }
 
+// Resync reads the endpoints of the peer services from the API server, so that a replica whose cache missed an update
+// converges to the same peers as the others.
+func (p *peerManager) Resync() error {
+	for _, svc := range strings.Split(settings.PeerServices.Get(), ",") {
+		endpoint, err := p.endpoints.Get(settings.Namespace.Get(), strings.TrimSpace(svc), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		p.addRemovePeers(endpoint)
+	}
+	return nil
+}
+
 func (p *peerManager) addRemovePeers(endpoints *v1.Endpoints) {
 	p.Lock()
 	defer p.Unlock()
 
 	newSet := map[string]bool{}
+	names := map[string]string{}
 	ready := false
 	for _, subset := range endpoints.Subsets {
 		for _, addr := range subset.Addresses {
@@ -161,6 +190,9 @@ func (p *peerManager) addRemovePeers(endpoints *v1.Endpoints) {
 				ready = true
 			} else {
 				newSet[addr.IP] = true
+				if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+					names[addr.IP] = addr.TargetRef.Name
+				}
 			}
 		}
 	}
@@ -174,19 +206,25 @@ func (p *peerManager) addRemovePeers(endpoints *v1.Endpoints) {
 	}
 
 	p.peers = newSet
+	p.names = names
 	p.ready = ready
 	p.notify()
 }
 
 func (p *peerManager) notify() {
 	peers := peermanager.Peers{
-		Leader: p.leader,
-		Ready:  p.ready,
-		SelfID: p.server.PeerID,
+		Leader:   p.leader,
+		Ready:    p.ready,
+		SelfID:   p.server.PeerID,
+		SelfName: p.selfName,
+		Names:    map[string]string{},
 	}
 
 	for id := range p.peers {
 		peers.IDs = append(peers.IDs, id)
+		if name, ok := p.names[id]; ok {
+			peers.Names[id] = name
+		}
 	}
 
 	for c := range p.listeners {
@@ -205,8 +243,9 @@ func (p *peerManager) RemoveListener(c chan<- peermanager.Peers) {
 	defer p.Unlock()
 	delete(p.listeners, c)
 	c <- peermanager.Peers{
-		SelfID: p.server.PeerID,
-		Leader: p.leader,
+		SelfID:   p.server.PeerID,
+		SelfName: p.selfName,
+		Leader:   p.leader,
 	}
 }
 
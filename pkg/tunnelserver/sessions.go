@@ -0,0 +1,233 @@
+package tunnelserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	SessionConnected    = "Connected"
+	SessionDisconnected = "Disconnected"
+)
+
+// Reasons for a tunnel session to end, as reported in SessionEvent.Reason and the disconnect metric.
+const (
+	// DisconnectReasonClosed means the session ended without a transport error, either through a websocket close
+	// handshake or because Rancher closed it.
+	DisconnectReasonClosed = "Closed"
+	// DisconnectReasonConnectionReset means the connection to the agent was dropped, for example when the agent was
+	// killed or a load balancer in between closed the connection.
+	DisconnectReasonConnectionReset = "ConnectionReset"
+	// DisconnectReasonTimeout means the agent stopped sending pings.
+	DisconnectReasonTimeout = "Timeout"
+	DisconnectReasonError   = "Error"
+)
+
+// SessionEvent describes an agent tunnel session being established or closed on this server.
+type SessionEvent struct {
+	Type          string
+	ClientKey     string
+	RemoteAddress string
+	Time          time.Time
+	// Reason and Duration are only set when the session is closed.
+	Reason   string
+	Duration time.Duration
+}
+
+// SessionListener is notified about tunnel sessions. It is called synchronously from the tunnel handler and must not
+// block.
+type SessionListener func(event SessionEvent)
+
+// SessionMonitor wraps the tunnel server handler to collect metrics of agent sessions and notify listeners when
+// sessions are established or closed. Peer sessions between Rancher servers are not tracked.
+type SessionMonitor struct {
+	next      http.Handler
+	lock      sync.RWMutex
+	listeners []SessionListener
+}
+
+func NewSessionMonitor(next http.Handler) *SessionMonitor {
+	return &SessionMonitor{
+		next: next,
+	}
+}
+
+func (m *SessionMonitor) AddListener(listener SessionListener) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+func (m *SessionMonitor) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		m.next.ServeHTTP(rw, req)
+		return
+	}
+
+	key := &sessionKey{}
+	req = req.WithContext(context.WithValue(req.Context(), sessionKeyContextKey{}, key))
+	w := &sessionResponseWriter{
+		ResponseWriter: rw,
+		hijacker:       hijacker,
+		key:            key,
+		onHijack: func(conn *sessionConn) {
+			incSessionConnects(conn.clientKey)
+			m.notify(SessionEvent{
+				Type:          SessionConnected,
+				ClientKey:     conn.clientKey,
+				RemoteAddress: remoteAddress(req),
+				Time:          conn.start,
+			})
+		},
+	}
+
+	m.next.ServeHTTP(w, req)
+
+	if w.conn == nil {
+		return
+	}
+	reason := w.conn.disconnectReason()
+	incSessionDisconnects(w.conn.clientKey, reason)
+	m.notify(SessionEvent{
+		Type:          SessionDisconnected,
+		ClientKey:     w.conn.clientKey,
+		RemoteAddress: remoteAddress(req),
+		Time:          time.Now(),
+		Reason:        reason,
+		Duration:      time.Since(w.conn.start),
+	})
+}
+
+func (m *SessionMonitor) notify(event SessionEvent) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, listener := range m.listeners {
+		listener(event)
+	}
+}
+
+// sessionKey carries the client key from Authorizers.Authorize back to the SessionMonitor, as remotedialer does not
+// expose it.
+type sessionKey struct {
+	value string
+}
+
+type sessionKeyContextKey struct{}
+
+func setSessionClientKey(req *http.Request, clientKey string) {
+	if key, ok := req.Context().Value(sessionKeyContextKey{}).(*sessionKey); ok {
+		key.value = clientKey
+	}
+}
+
+type sessionResponseWriter struct {
+	http.ResponseWriter
+	hijacker http.Hijacker
+	key      *sessionKey
+	onHijack func(conn *sessionConn)
+	conn     *sessionConn
+}
+
+func (w *sessionResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := w.hijacker.Hijack()
+	if err != nil || w.key.value == "" {
+		return conn, brw, err
+	}
+
+	w.conn = &sessionConn{
+		Conn:      conn,
+		clientKey: w.key.value,
+		start:     time.Now(),
+	}
+	w.onHijack(w.conn)
+
+	// the websocket library reads through the returned reader, so it has to wrap the counting connection as well
+	return w.conn, bufio.NewReadWriter(w.conn.bufferedReader(brw.Reader), bufio.NewWriter(w.conn)), nil
+}
+
+// sessionConn counts the bytes of a tunnel session and remembers why it ended.
+type sessionConn struct {
+	net.Conn
+	clientKey string
+	start     time.Time
+
+	lock     sync.Mutex
+	closed   bool
+	transErr error
+}
+
+func (c *sessionConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	addSessionReceiveBytes(c.clientKey, n)
+	if err != nil {
+		c.recordError(err)
+	}
+	return n, err
+}
+
+// bufferedReader returns a reader over the connection that first returns the bytes already read into the hijacked
+// reader, so no data is lost and everything after it is read through the counting connection.
+func (c *sessionConn) bufferedReader(hijacked *bufio.Reader) *bufio.Reader {
+	n := hijacked.Buffered()
+	if n == 0 {
+		return bufio.NewReader(c)
+	}
+	buffered, _ := hijacked.Peek(n)
+	addSessionReceiveBytes(c.clientKey, n)
+	reader := bufio.NewReaderSize(io.MultiReader(bytes.NewReader(buffered), c), hijacked.Size())
+	// keep the bytes buffered, callers check Buffered to detect data sent ahead of the handshake
+	_, _ = reader.Peek(n)
+	return reader
+}
+
+func (c *sessionConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	addSessionTransmitBytes(c.clientKey, n)
+	if err != nil {
+		c.recordError(err)
+	}
+	return n, err
+}
+
+func (c *sessionConn) Close() error {
+	c.lock.Lock()
+	c.closed = true
+	c.lock.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *sessionConn) recordError(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// errors after the server closed the connection are a consequence of closing it
+	if c.transErr == nil && !c.closed {
+		c.transErr = err
+	}
+}
+
+func (c *sessionConn) disconnectReason() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var netErr net.Error
+	switch {
+	case c.transErr == nil:
+		return DisconnectReasonClosed
+	case errors.As(c.transErr, &netErr) && netErr.Timeout():
+		return DisconnectReasonTimeout
+	case errors.Is(c.transErr, io.EOF), errors.Is(c.transErr, io.ErrUnexpectedEOF),
+		errors.Is(c.transErr, syscall.ECONNRESET), errors.Is(c.transErr, syscall.EPIPE):
+		return DisconnectReasonConnectionReset
+	default:
+		return DisconnectReasonError
+	}
+}
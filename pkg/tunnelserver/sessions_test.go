@@ -0,0 +1,136 @@
+package tunnelserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rancher/remotedialer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionMonitor(t *testing.T) {
+	prometheusMetrics = true
+	defer func() { prometheusMetrics = false }()
+
+	auth := &Authorizers{}
+	auth.Add(func(req *http.Request) (string, bool, error) {
+		return req.Header.Get("X-Client-Key"), req.Header.Get("X-Client-Key") != "", nil
+	})
+	monitor := NewSessionMonitor(remotedialer.New(auth.Authorize, remotedialer.DefaultErrorWriter))
+	events := make(chan SessionEvent, 10)
+	monitor.AddListener(func(event SessionEvent) {
+		events <- event
+	})
+
+	server := httptest.NewServer(monitor)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// unauthorized requests are not sessions
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	headers := http.Header{"X-Client-Key": []string{"c-test"}}
+	go remotedialer.ClientConnect(ctx, wsURL, headers, nil, func(string, string) bool { return true }, nil)
+
+	connected := nextEvent(t, events)
+	assert.Equal(t, SessionConnected, connected.Type)
+	assert.Equal(t, "c-test", connected.ClientKey)
+	assert.NotEmpty(t, connected.RemoteAddress)
+
+	cancel()
+
+	disconnected := nextEvent(t, events)
+	assert.Equal(t, SessionDisconnected, disconnected.Type)
+	assert.Equal(t, "c-test", disconnected.ClientKey)
+	assert.NotEmpty(t, disconnected.Reason)
+	assert.Greater(t, disconnected.Duration, time.Duration(0))
+
+	assert.Equal(t, float64(1), counterValue(t, TotalSessionConnects.WithLabelValues("c-test")))
+	assert.Equal(t, float64(1), counterValue(t, TotalSessionDisconnects.WithLabelValues("c-test", disconnected.Reason)))
+	assert.Greater(t, counterValue(t, TotalSessionTransmitBytes.WithLabelValues("c-test")), float64(0))
+}
+
+func TestDisconnectReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		closed bool
+		want   string
+	}{
+		{name: "no error", want: DisconnectReasonClosed},
+		{name: "agent gone", err: io.EOF, want: DisconnectReasonConnectionReset},
+		{name: "timeout", err: os.ErrDeadlineExceeded, want: DisconnectReasonTimeout},
+		{name: "other error", err: errors.New("boom"), want: DisconnectReasonError},
+		{name: "error after closing", err: net.ErrClosed, closed: true, want: DisconnectReasonClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &sessionConn{closed: tt.closed}
+			if tt.err != nil {
+				conn.recordError(tt.err)
+			}
+			assert.Equal(t, tt.want, conn.disconnectReason())
+		})
+	}
+}
+
+func TestSessionConnBufferedReader(t *testing.T) {
+	prometheusMetrics = true
+	defer func() { prometheusMetrics = false }()
+
+	client, server := net.Pipe()
+	conn := &sessionConn{Conn: server, clientKey: "c-buffered"}
+
+	hijacked := bufio.NewReader(strings.NewReader("hello"))
+	_, err := hijacked.Peek(5)
+	require.NoError(t, err)
+
+	reader := conn.bufferedReader(hijacked)
+	assert.Equal(t, 5, reader.Buffered())
+
+	go func() {
+		_, _ = client.Write([]byte(" world"))
+		client.Close()
+	}()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	assert.Equal(t, float64(11), counterValue(t, TotalSessionReceiveBytes.WithLabelValues("c-buffered")))
+	assert.Equal(t, DisconnectReasonConnectionReset, conn.disconnectReason())
+}
+
+func nextEvent(t *testing.T, events chan SessionEvent) SessionEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for session event")
+		return SessionEvent{}
+	}
+}
+
+func counterValue(t *testing.T, counter interface{ Write(*dto.Metric) error }) float64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, counter.Write(metric))
+	return metric.GetCounter().GetValue()
+}
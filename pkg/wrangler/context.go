@@ -123,6 +123,7 @@ type Context struct {
 	ControllerFactory   controller.SharedControllerFactory
 	MultiClusterManager MultiClusterManager
 	TunnelServer        *remotedialer.Server
+	TunnelSessions      *tunnelserver.SessionMonitor
 	TunnelAuthorizer    *tunnelserver.Authorizers
 	PeerManager         peermanager.PeerManager
 	Provisioning        provisioningv1.Interface
@@ -442,6 +443,7 @@ func NewContext(ctx context.Context, clientConfig clientcmd.ClientConfig, restCo
 		SystemChartsManager:     systemCharts,
 		TunnelAuthorizer:        tunnelAuth,
 		TunnelServer:            tunnelServer,
+		TunnelSessions:          tunnelserver.NewSessionMonitor(tunnelServer),
 
 		mgmt:         mgmt,
 		apps:         apps,